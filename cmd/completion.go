@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/kartoza/kartoza-pg-ai/internal/config"
+	"github.com/kartoza/kartoza-pg-ai/internal/postgres"
+	"github.com/spf13/cobra"
+)
+
+var completionCmd = &cobra.Command{
+	Use:   "completion [bash|zsh|fish|powershell]",
+	Short: "Generate a shell completion script",
+	Long: `Generate a completion script for your shell. Besides commands and flags,
+it completes the names of services in pg_service.conf and of saved
+snapshots.
+
+Bash:
+  $ source <(kartoza-pg-ai completion bash)
+  # Or for every session (Linux):
+  $ kartoza-pg-ai completion bash > /etc/bash_completion.d/kartoza-pg-ai
+
+Zsh:
+  $ kartoza-pg-ai completion zsh > "${fpath[1]}/_kartoza-pg-ai"
+
+Fish:
+  $ kartoza-pg-ai completion fish > ~/.config/fish/completions/kartoza-pg-ai.fish
+
+PowerShell:
+  PS> kartoza-pg-ai completion powershell | Out-String | Invoke-Expression`,
+	ValidArgs:             []string{"bash", "zsh", "fish", "powershell"},
+	Args:                  cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+	DisableFlagsInUseLine: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		switch args[0] {
+		case "bash":
+			return rootCmd.GenBashCompletionV2(os.Stdout, true)
+		case "zsh":
+			return rootCmd.GenZshCompletion(os.Stdout)
+		case "fish":
+			return rootCmd.GenFishCompletion(os.Stdout, true)
+		default:
+			return rootCmd.GenPowerShellCompletionWithDesc(os.Stdout)
+		}
+	},
+}
+
+// completeServices completes service names: those in pg_service.conf and
+// those the config remembers schemas or profiles for
+func completeServices(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	seen := make(map[string]bool)
+	if services, err := postgres.ParsePGServiceFile(); err == nil {
+		for _, s := range services {
+			seen[s.Name] = true
+		}
+	}
+	if cfg, err := config.Load(); err == nil {
+		for name := range cfg.CachedSchemas {
+			seen[name] = true
+		}
+		for name := range cfg.ServiceProfiles {
+			seen[name] = true
+		}
+	}
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return withPrefix(names, toComplete), cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeSnapshot completes the name of a saved snapshot as the first
+// argument
+func completeSnapshot(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	names, err := config.SnapshotNames()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	return withPrefix(names, toComplete), cobra.ShellCompDirectiveNoFileComp
+}
+
+// withPrefix keeps the names starting with prefix
+func withPrefix(names []string, prefix string) []string {
+	var matches []string
+	for _, name := range names {
+		if strings.HasPrefix(name, prefix) {
+			matches = append(matches, name)
+		}
+	}
+	return matches
+}
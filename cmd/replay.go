@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"text/tabwriter"
+	"time"
+
+	"github.com/kartoza/kartoza-pg-ai/internal/config"
+	"github.com/kartoza/kartoza-pg-ai/internal/postgres"
+	"github.com/spf13/cobra"
+)
+
+// replayCountLimit is how many rows replayed queries count before taking
+// the planner's estimate, as the query screen does
+const replayCountLimit = 100000
+
+var (
+	replayExplain bool
+	replayTimeout time.Duration
+	replayLimit   int
+	replayAll     bool
+)
+
+var replayCmd = &cobra.Command{
+	Use:   "replay <service>",
+	Short: "Run a service's query history again to find queries that broke",
+	Long: `Run every query that once succeeded on a service again, read-only, and
+report the ones that now fail or return a different number of rows, e.g.
+after a schema migration. --explain only plans the queries, which finds
+missing tables and columns without reading any rows. Queries that change
+data or have template parameters are skipped. Exits non-zero when a query
+fails, or returns different rows, so it can gate scripts and CI jobs.`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeServices,
+	SilenceUsage:      true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.Load()
+		if err != nil {
+			return fmt.Errorf("loading config: %w", err)
+		}
+		services, err := postgres.ParsePGServiceFile()
+		if err != nil {
+			return fmt.Errorf("reading services: %w", err)
+		}
+		service, err := postgres.GetServiceByName(services, args[0])
+		if err != nil {
+			return err
+		}
+		store, err := config.History()
+		if err != nil {
+			return err
+		}
+		entries, err := store.List(config.HistoryFilter{ServiceName: service.Name, Limit: replayLimit})
+		if err != nil {
+			return err
+		}
+		db, err := service.Connect()
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		timeout := replayTimeout
+		if !cmd.Flags().Changed("timeout") {
+			timeout = cfg.SettingsFor(service.Name).StatementTimeout()
+		}
+		ctx = postgres.WithStatementTimeout(ctx, timeout)
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		listed := 0
+		results := postgres.ReplayHistory(ctx, db, entries, postgres.ReplayOptions{
+			ExplainOnly: replayExplain,
+			CountLimit:  replayCountLimit,
+		}, func(r postgres.ReplayResult) {
+			if !replayAll && (r.Outcome == postgres.ReplaySame || r.Outcome == postgres.ReplaySkipped) {
+				return
+			}
+			if listed++; listed == 1 {
+				fmt.Fprintln(w, "RESULT\tROWS\tQUESTION\tDETAIL")
+			}
+			rows := "-"
+			if ran := r.Outcome == postgres.ReplaySame || r.Outcome == postgres.ReplayChanged; ran && !replayExplain {
+				rows = fmt.Sprintf("%d", r.Rows)
+				if r.Estimated {
+					rows = "~" + config.HumanCount(int64(r.Rows))
+				}
+			}
+			question := r.Entry.NaturalQuery
+			if question == "" {
+				question = r.SQL
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", r.Outcome, rows, truncateText(question, 50), truncateText(r.Reason, 70))
+		})
+		w.Flush()
+		if ctx.Err() != nil {
+			return fmt.Errorf("replay interrupted")
+		}
+
+		counts := postgres.ReplaySummary(results)
+		if listed > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("%d queries replayed on %s: %d ok, %d changed, %d failed, %d skipped\n", len(results), service.Name,
+			counts[postgres.ReplaySame], counts[postgres.ReplayChanged], counts[postgres.ReplayFailed], counts[postgres.ReplaySkipped])
+		if broken := counts[postgres.ReplayChanged] + counts[postgres.ReplayFailed]; broken > 0 {
+			return fmt.Errorf("%d of the queries no longer run as they did", broken)
+		}
+		return nil
+	},
+}
+
+func init() {
+	replayCmd.Flags().BoolVar(&replayExplain, "explain", false, "Only plan the queries (EXPLAIN), without running them or comparing rows")
+	replayCmd.Flags().DurationVar(&replayTimeout, "timeout", 0, "Statement timeout of each query (default the service's setting; 0 for none)")
+	replayCmd.Flags().IntVar(&replayLimit, "limit", 0, "Replay only the newest n history entries (0 for all)")
+	replayCmd.Flags().BoolVar(&replayAll, "all", false, "List the queries that still run as before and the skipped ones too")
+}
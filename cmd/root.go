@@ -1,17 +1,21 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"time"
 
+	"github.com/kartoza/kartoza-pg-ai/internal/config"
+	"github.com/kartoza/kartoza-pg-ai/internal/tracing"
 	"github.com/kartoza/kartoza-pg-ai/internal/tui"
 	"github.com/spf13/cobra"
 )
 
 var (
-	appVersion = "dev"
-	noSplash   bool
+	appVersion  = "dev"
+	noSplash    bool
+	plainOutput bool
 )
 
 // SetVersion sets the application version
@@ -35,6 +39,14 @@ This tool allows you to:
 
 Built with love by Kartoza.`,
 	Run: func(cmd *cobra.Command, args []string) {
+		// Plain output for screen readers and logs has no splash animations
+		if plainOutput {
+			tui.SetPlainOutput(true)
+		}
+		if plainOutputSetting() {
+			noSplash = true
+		}
+
 		// Show entry splash screen (unless --nosplash)
 		if !noSplash {
 			if err := tui.ShowSplashScreen(1500 * time.Millisecond); err != nil {
@@ -42,8 +54,13 @@ Built with love by Kartoza.`,
 			}
 		}
 
+		// Export spans of the query pipeline when an OTLP endpoint is set
+		shutdownTracing := startTracing()
+
 		// Run main TUI application
-		if err := tui.RunApp(); err != nil {
+		err := tui.RunApp()
+		shutdownTracing()
+		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error running application: %v\n", err)
 			os.Exit(1)
 		}
@@ -57,13 +74,50 @@ Built with love by Kartoza.`,
 	},
 }
 
+// plainOutputSetting reports whether plain output is asked for, by the
+// --plain flag or the Plain Output setting
+func plainOutputSetting() bool {
+	if plainOutput {
+		return true
+	}
+	cfg, err := config.Load()
+	return err == nil && cfg.Settings.PlainOutput
+}
+
+// startTracing sets up OpenTelemetry export from the configured endpoint,
+// returning a function that flushes pending spans
+func startTracing() func() {
+	var endpoint string
+	if cfg, err := config.Load(); err == nil {
+		endpoint = cfg.Settings.OTLPEndpoint
+	}
+	shutdown, err := tracing.Setup(context.Background(), endpoint, appVersion)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error setting up tracing: %v\n", err)
+		return func() {}
+	}
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdown(ctx); err != nil {
+			fmt.Fprintf(os.Stderr, "Error exporting traces: %v\n", err)
+		}
+	}
+}
+
 // Execute adds all child commands to the root command and sets flags appropriately.
 func Execute() error {
 	return rootCmd.Execute()
 }
 
 func init() {
+	rootCmd.PersistentPreRunE = unlockConfig
 	rootCmd.Flags().BoolVar(&noSplash, "nosplash", false, "Skip the splash screen animations")
+	rootCmd.Flags().BoolVar(&plainOutput, "plain", false, "Plain ASCII output without colours, box drawing or emoji, for screen readers and logs")
 	rootCmd.AddCommand(versionCmd)
 	rootCmd.AddCommand(statusCmd)
+	rootCmd.AddCommand(serveCmd)
+	rootCmd.AddCommand(snapshotCmd)
+	rootCmd.AddCommand(replayCmd)
+	rootCmd.AddCommand(completionCmd)
 }
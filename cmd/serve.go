@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/kartoza/kartoza-pg-ai/internal/config"
+	"github.com/kartoza/kartoza-pg-ai/internal/logging"
+	"github.com/kartoza/kartoza-pg-ai/internal/postgres"
+	"github.com/kartoza/kartoza-pg-ai/internal/server"
+	"github.com/spf13/cobra"
+)
+
+// serveTokenEnv holds the API token when --token is not given
+const serveTokenEnv = "KARTOZA_PG_AI_TOKEN"
+
+var (
+	servePort    int
+	serveHost    string
+	serveToken   string
+	serveTimeout time.Duration
+	serveMaxRows int
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve the query engine over HTTP",
+	Long: `Serve a REST API answering natural language questions with the same
+engine, schema cache and history as the TUI:
+
+  POST /query            {"service": "...", "question": "...", "params": {...}}
+  GET  /schema/{service} cached schema; ?refresh=true harvests it again
+  GET  /history          ?service=...&search=...&limit=...
+
+Only read-only SQL is run. When a token is set, with --token or the
+` + serveTokenEnv + ` environment variable, every request must send it as
+"Authorization: Bearer <token>".`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.Load()
+		if err != nil {
+			return fmt.Errorf("loading config: %w", err)
+		}
+		if dir, err := config.LogDir(); err == nil {
+			if err := logging.Open(dir); err == nil {
+				defer logging.Close()
+			}
+		}
+		logging.SetLevel(logging.ParseLevel(cfg.Settings.LogLevel))
+
+		shutdownTracing := startTracing()
+		defer shutdownTracing()
+		defer postgres.CloseConnections()
+		defer config.CloseHistory()
+
+		token := serveToken
+		if token == "" {
+			token = os.Getenv(serveTokenEnv)
+		}
+		api := server.New(cfg, server.Options{Token: token, Timeout: serveTimeout, MaxRows: serveMaxRows})
+		addr := net.JoinHostPort(serveHost, strconv.Itoa(servePort))
+		srv := &http.Server{
+			Addr:              addr,
+			Handler:           api.Handler(),
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		errc := make(chan error, 1)
+		go func() { errc <- srv.ListenAndServe() }()
+
+		logging.Info("api started", "addr", addr, "auth", token != "")
+		fmt.Printf("Serving on http://%s\n", addr)
+		if token == "" && serveHost != "localhost" && serveHost != "127.0.0.1" {
+			fmt.Fprintf(os.Stderr, "Warning: serving on %s without a token\n", serveHost)
+		}
+
+		select {
+		case err := <-errc:
+			if !errors.Is(err, http.ErrServerClosed) {
+				return err
+			}
+		case <-ctx.Done():
+			// Let questions in flight finish
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			if err := srv.Shutdown(shutdownCtx); err != nil {
+				return err
+			}
+		}
+		logging.Info("api stopped")
+		return nil
+	},
+}
+
+func init() {
+	serveCmd.Flags().IntVar(&servePort, "port", 8080, "Port to listen on")
+	serveCmd.Flags().StringVar(&serveHost, "host", "localhost", "Address to listen on")
+	serveCmd.Flags().StringVar(&serveToken, "token", "", "Bearer token required on every request (default $"+serveTokenEnv+")")
+	serveCmd.Flags().DurationVar(&serveTimeout, "timeout", server.DefaultTimeout, "Limit on answering one question")
+	serveCmd.Flags().IntVar(&serveMaxRows, "max-rows", server.DefaultMaxRows, "Rows returned per query")
+}
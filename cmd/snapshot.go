@@ -0,0 +1,196 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/kartoza/kartoza-pg-ai/internal/config"
+	"github.com/kartoza/kartoza-pg-ai/internal/postgres"
+	"github.com/spf13/cobra"
+)
+
+var (
+	snapshotTimeout time.Duration
+	snapshotUpdate  bool
+	snapshotLimit   int
+	snapshotService string
+)
+
+var snapshotCmd = &cobra.Command{
+	Use:   "snapshot",
+	Short: "List and compare saved result snapshots",
+	Long: `Snapshots are the full rows of a result saved under a name from the
+query screen (press S on an entry). Comparing a fresh run of a snapshot's
+SQL with its rows makes a light-weight data regression check.`,
+}
+
+var snapshotListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List saved snapshots",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		names, err := config.SnapshotNames()
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		listed := 0
+		for _, name := range names {
+			snap, err := config.LoadSnapshot(name)
+			if err == nil && snapshotService != "" && snap.ServiceName != snapshotService {
+				continue
+			}
+			if listed++; listed == 1 {
+				fmt.Fprintln(w, "NAME\tSERVICE\tROWS\tTAKEN\tQUERY")
+			}
+			if err != nil {
+				fmt.Fprintf(w, "%s\t?\t?\t?\t%v\n", name, err)
+				continue
+			}
+			about := snap.Query
+			if about == "" {
+				about = strings.Join(strings.Fields(snap.SQL), " ")
+			}
+			fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\n", name, snap.ServiceName, len(snap.Rows),
+				snap.CreatedAt.Format("2006-01-02 15:04"), truncateText(about, 60))
+		}
+		if listed == 0 {
+			fmt.Println("No snapshots saved")
+			return nil
+		}
+		return w.Flush()
+	},
+}
+
+var snapshotDiffCmd = &cobra.Command{
+	Use:   "diff <name>",
+	Short: "Compare a fresh run of a snapshot's SQL with its rows",
+	Long: `Run a snapshot's SQL again on its service and print the rows added,
+removed and changed since the snapshot was taken. Rows are matched by the
+key columns recorded with the snapshot, or as whole rows. Exits non-zero
+when the rows differ, so it can gate scripts and CI jobs; --update saves
+the fresh rows as the snapshot instead.`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeSnapshot,
+	SilenceUsage:      true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.Load()
+		if err != nil {
+			return fmt.Errorf("loading config: %w", err)
+		}
+		snap, err := config.LoadSnapshot(args[0])
+		if err != nil {
+			return err
+		}
+		services, err := postgres.ParsePGServiceFile()
+		if err != nil {
+			return fmt.Errorf("reading services: %w", err)
+		}
+		service, err := postgres.GetServiceByName(services, snap.ServiceName)
+		if err != nil {
+			return err
+		}
+		db, err := service.Connect()
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		timeout := snapshotTimeout
+		if !cmd.Flags().Changed("timeout") {
+			timeout = cfg.SettingsFor(service.Name).StatementTimeout()
+		}
+		ctx = postgres.WithStatementTimeout(ctx, timeout)
+		columns, rows, err := postgres.QueryAll(ctx, db, snap.SQL, snap.Params)
+		if err != nil {
+			return fmt.Errorf("running snapshot %q: %w", snap.Name, err)
+		}
+
+		if snapshotUpdate {
+			snap.Columns, snap.Rows, snap.CreatedAt = columns, rows, time.Time{}
+			if err := config.SaveSnapshot(snap); err != nil {
+				return err
+			}
+			fmt.Printf("Updated snapshot %q with %d rows\n", snap.Name, len(rows))
+			return nil
+		}
+
+		d, err := postgres.DiffRows(snap.Columns, snap.Rows, columns, rows, snap.Key)
+		if err != nil {
+			return fmt.Errorf("comparing with snapshot %q: %w", snap.Name, err)
+		}
+		printRowDiff(snap, d)
+		if !d.Same() {
+			return fmt.Errorf("snapshot %q differs", snap.Name)
+		}
+		return nil
+	},
+}
+
+var snapshotDeleteCmd = &cobra.Command{
+	Use:               "delete <name>",
+	Short:             "Delete a saved snapshot",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeSnapshot,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return config.DeleteSnapshot(args[0])
+	},
+}
+
+// printRowDiff prints a summary of a diff and, up to the row limit, the
+// rows that differ as a table
+func printRowDiff(snap *config.Snapshot, d *postgres.RowDiff) {
+	key := "whole rows"
+	if d.Key != nil {
+		key = strings.Join(d.Key, ", ")
+	}
+	fmt.Printf("Snapshot %q on %s, taken %s; rows matched by %s\n",
+		snap.Name, snap.ServiceName, snap.CreatedAt.Format("2006-01-02 15:04"), key)
+	fmt.Printf("+%d added  -%d removed  ~%d changed  %d unchanged\n",
+		d.Count(postgres.RowAdded), d.Count(postgres.RowRemoved), d.Count(postgres.RowChanged), d.Unchanged)
+	if d.Same() {
+		return
+	}
+
+	fmt.Println()
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "\t"+strings.Join(d.Columns, "\t"))
+	for i, c := range d.Changes {
+		if snapshotLimit > 0 && i >= snapshotLimit {
+			fmt.Fprintf(w, "…\t%d more\n", len(d.Changes)-i)
+			break
+		}
+		marker := map[postgres.ChangeKind]string{postgres.RowAdded: "+", postgres.RowRemoved: "-", postgres.RowChanged: "~"}[c.Kind]
+		cells := make([]string, len(d.Columns))
+		for j := range cells {
+			cells[j] = strings.ReplaceAll(truncateText(c.Cell(j), 40), "\t", " ")
+		}
+		fmt.Fprintln(w, marker+"\t"+strings.Join(cells, "\t"))
+	}
+	w.Flush()
+}
+
+// truncateText shortens s to at most n characters on one line
+func truncateText(s string, n int) string {
+	runes := []rune(strings.ReplaceAll(s, "\n", " "))
+	if len(runes) > n {
+		return string(runes[:n-1]) + "…"
+	}
+	return string(runes)
+}
+
+func init() {
+	snapshotDiffCmd.Flags().DurationVar(&snapshotTimeout, "timeout", 0, "Statement timeout of the snapshot's SQL (default the service's setting; 0 for none)")
+	snapshotDiffCmd.Flags().BoolVar(&snapshotUpdate, "update", false, "Save the fresh rows as the snapshot instead of comparing")
+	snapshotDiffCmd.Flags().IntVar(&snapshotLimit, "limit", 50, "Differing rows printed (0 for all)")
+	snapshotListCmd.Flags().StringVar(&snapshotService, "service", "", "Only list snapshots of this service")
+	snapshotListCmd.RegisterFlagCompletionFunc("service", completeServices)
+	snapshotCmd.AddCommand(snapshotListCmd, snapshotDiffCmd, snapshotDeleteCmd)
+}
@@ -23,6 +23,10 @@ var statusCmd = &cobra.Command{
 		fmt.Printf("====================\n")
 		fmt.Printf("Active Database: %s\n", cfg.ActiveService)
 		fmt.Printf("Cached Schemas: %d\n", len(cfg.CachedSchemas))
-		fmt.Printf("Query History: %d queries\n", len(cfg.QueryHistory))
+		if store, err := config.History(); err == nil {
+			count, _ := store.Count("")
+			fmt.Printf("Query History: %d queries\n", count)
+			config.CloseHistory()
+		}
 	},
 }
@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/kartoza/kartoza-pg-ai/internal/config"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+// passphraseEnv holds the passphrase of an encrypted config where it
+// cannot be typed, e.g. for serve
+const passphraseEnv = "KARTOZA_PG_AI_PASSPHRASE"
+
+// unlockAttempts is how often a mistyped passphrase may be typed again
+const unlockAttempts = 3
+
+// unlockConfig gets the key of an encrypted config before a command reads it
+func unlockConfig(cmd *cobra.Command, args []string) error {
+	switch cmd.Name() {
+	case "version", "completion", cobra.ShellCompRequestCmd, cobra.ShellCompNoDescRequestCmd:
+		return nil
+	}
+	for attempt := 1; ; attempt++ {
+		err := config.Unlock(promptPassphrase)
+		if !errors.Is(err, config.ErrWrongKey) || attempt == unlockAttempts || os.Getenv(passphraseEnv) != "" {
+			return err
+		}
+		fmt.Fprintln(os.Stderr, "Wrong passphrase, try again")
+	}
+}
+
+// promptPassphrase reads the config passphrase from the terminal without
+// echoing it, twice when it is new
+func promptPassphrase(isNew bool) (string, error) {
+	if p := os.Getenv(passphraseEnv); p != "" {
+		return p, nil
+	}
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", fmt.Errorf("the config is encrypted with a passphrase; set %s", passphraseEnv)
+	}
+	read := func(label string) (string, error) {
+		fmt.Fprint(os.Stderr, label)
+		p, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		return string(p), err
+	}
+
+	if !isNew {
+		return read("Config passphrase: ")
+	}
+	p, err := read("New passphrase to encrypt the config with: ")
+	if err != nil {
+		return "", err
+	}
+	if p == "" {
+		return "", errors.New("the passphrase must not be empty")
+	}
+	again, err := read("Repeat the passphrase: ")
+	if err != nil {
+		return "", err
+	}
+	if again != p {
+		return "", errors.New("the passphrases do not match")
+	}
+	return p, nil
+}
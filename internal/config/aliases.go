@@ -0,0 +1,44 @@
+package config
+
+import (
+	"strings"
+	"time"
+)
+
+// Alias is a word of a question tied to a table or column by a user
+// correcting the SQL generated for it, e.g. "clients" to the customers
+// table after the engine chose invoices
+type Alias struct {
+	Word      string    `json:"word"` // Lower-cased
+	Schema    string    `json:"schema,omitempty"`
+	Table     string    `json:"table"`
+	Column    string    `json:"column,omitempty"` // Empty when the word names the table
+	LearnedAt time.Time `json:"learned_at"`
+}
+
+// Target returns the name of the table or column an alias stands for
+func (a Alias) Target() string {
+	if a.Column != "" {
+		return a.Column
+	}
+	return a.Table
+}
+
+// LearnAlias remembers an alias for a service, replacing the one the word
+// had
+func (c *Config) LearnAlias(service string, a Alias) {
+	a.Word = strings.ToLower(a.Word)
+	if a.LearnedAt.IsZero() {
+		a.LearnedAt = time.Now()
+	}
+	if c.Aliases == nil {
+		c.Aliases = make(map[string][]Alias)
+	}
+	aliases := c.Aliases[service][:0:0]
+	for _, old := range c.Aliases[service] {
+		if old.Word != a.Word {
+			aliases = append(aliases, old)
+		}
+	}
+	c.Aliases[service] = append(aliases, a)
+}
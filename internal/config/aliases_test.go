@@ -0,0 +1,25 @@
+package config
+
+import "testing"
+
+func TestLearnAlias(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.LearnAlias("crm", Alias{Word: "Clients", Schema: "public", Table: "invoices"})
+	cfg.LearnAlias("crm", Alias{Word: "town", Schema: "public", Table: "customers", Column: "city"})
+	cfg.LearnAlias("crm", Alias{Word: "clients", Schema: "public", Table: "customers"})
+	cfg.LearnAlias("gis", Alias{Word: "erf", Schema: "cadastre", Table: "parcels"})
+
+	aliases := cfg.Aliases["crm"]
+	if len(aliases) != 2 {
+		t.Fatalf("a word should have one alias per service, got %+v", aliases)
+	}
+	if aliases[0].Word != "town" || aliases[0].Target() != "city" {
+		t.Errorf("unexpected alias: %+v", aliases[0])
+	}
+	if a := aliases[1]; a.Word != "clients" || a.Target() != "customers" || a.LearnedAt.IsZero() {
+		t.Errorf("later correction should replace the earlier one: %+v", a)
+	}
+	if len(cfg.Aliases["gis"]) != 1 {
+		t.Errorf("aliases should be kept per service: %+v", cfg.Aliases)
+	}
+}
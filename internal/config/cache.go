@@ -0,0 +1,295 @@
+package config
+
+import (
+	"encoding/json"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// CacheDir returns the directory of data that can be rebuilt: schema
+// caches, geometry images, embeddings and models. It is XDG_CACHE_HOME
+// (~/.cache) on Linux and the platform's cache directory elsewhere.
+func CacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "kartoza-pg-ai"), nil
+}
+
+// cacheSubdir returns a directory or file under the cache directory
+func cacheSubdir(name string) (string, error) {
+	dir, err := CacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name), nil
+}
+
+// SchemaCacheDir returns the directory holding the harvested schema of
+// each service
+func SchemaCacheDir() (string, error) {
+	return cacheSubdir("schemas")
+}
+
+// NNModelDir returns the directory of the trained neural network
+func NNModelDir() (string, error) {
+	return cacheSubdir("nn_model")
+}
+
+// EmbeddingCachePath returns the file caching embeddings of schema text
+func EmbeddingCachePath() (string, error) {
+	return cacheSubdir("embeddings.json")
+}
+
+// schemaCachePath returns the file of a service's schema cache
+func schemaCachePath(service string) (string, error) {
+	dir, err := SchemaCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, unsafeFileChars.ReplaceAllString(service, "_")+".json"), nil
+}
+
+// loadSchemaCaches reads every cached schema, by service name
+func loadSchemaCaches() (map[string]*SchemaCache, error) {
+	dir, err := SchemaCacheDir()
+	if err != nil {
+		return nil, err
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	schemas := make(map[string]*SchemaCache, len(paths))
+	for _, path := range paths {
+		data, err := readFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var schema SchemaCache
+		if err := json.Unmarshal(data, &schema); err != nil {
+			// A damaged cache is harvested again
+			continue
+		}
+		schemas[schema.ServiceName] = &schema
+	}
+	return schemas, nil
+}
+
+// saveSchemaCaches writes the cached schemas, removing the files of
+// schemas no longer cached. known holds when each schema read or last
+// written was harvested, to tell apart changes of other instances since:
+// their harvests are added to schemas, and schemas they removed are
+// removed from it unless harvested again here.
+func saveSchemaCaches(schemas map[string]*SchemaCache, known map[string]time.Time) error {
+	dir, err := SchemaCacheDir()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	onDisk, err := loadSchemaCaches()
+	if err != nil {
+		return err
+	}
+	for service, theirs := range onDisk {
+		mine, ok := schemas[service]
+		if _, wasKnown := known[service]; ok && !mine.CachedAt.Before(theirs.CachedAt) || !ok && wasKnown {
+			continue
+		}
+		schemas[service] = theirs
+	}
+	for service, mine := range schemas {
+		if cachedAt, wasKnown := known[service]; wasKnown && onDisk[service] == nil && mine.CachedAt.Equal(cachedAt) {
+			delete(schemas, service)
+		}
+	}
+
+	keep := make(map[string]bool, len(schemas))
+	for service, schema := range schemas {
+		path, err := schemaCachePath(service)
+		if err != nil {
+			return err
+		}
+		keep[path] = true
+		if schema == onDisk[service] {
+			continue
+		}
+		if schema.ServiceName == "" {
+			copied := *schema
+			copied.ServiceName = service
+			schema = &copied
+		}
+		data, err := json.Marshal(schema)
+		if err != nil {
+			return err
+		}
+		if err := writeFile(path, data, 0600); err != nil {
+			return err
+		}
+	}
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		if !keep[path] {
+			os.Remove(path)
+		}
+	}
+	return nil
+}
+
+// migrateCacheDirs moves caches earlier versions kept in the config
+// directory into the cache directory, unless they are there already
+func migrateCacheDirs() {
+	configDir, err := ConfigDir()
+	if err != nil {
+		return
+	}
+	cacheDir, err := CacheDir()
+	if err != nil || cacheDir == configDir {
+		return
+	}
+	for _, name := range []string{"geometry_images", "nn_model", "models", "embeddings.json"} {
+		from, to := filepath.Join(configDir, name), filepath.Join(cacheDir, name)
+		if _, err := os.Stat(from); err != nil {
+			continue
+		}
+		if _, err := os.Stat(to); err == nil {
+			continue
+		}
+		if err := os.MkdirAll(cacheDir, 0755); err != nil {
+			return
+		}
+		// Renaming fails across file systems; the old copy is then left in place
+		os.Rename(from, to)
+	}
+}
+
+// CacheUsage is the disk space taken by each cache, in bytes
+type CacheUsage struct {
+	Schemas        int64
+	GeometryImages int64
+	Embeddings     int64
+	NNModel        int64
+	Models         int64 // Downloaded GGUF models
+}
+
+// Total returns the space taken by all caches
+func (u CacheUsage) Total() int64 {
+	return u.Schemas + u.GeometryImages + u.Embeddings + u.NNModel + u.Models
+}
+
+// DiskUsage measures the caches
+func DiskUsage() (CacheUsage, error) {
+	var u CacheUsage
+	for name, size := range map[string]*int64{
+		"schemas":         &u.Schemas,
+		"geometry_images": &u.GeometryImages,
+		"embeddings.json": &u.Embeddings,
+		"nn_model":        &u.NNModel,
+		"models":          &u.Models,
+	} {
+		path, err := cacheSubdir(name)
+		if err != nil {
+			return u, err
+		}
+		*size = pathSize(path)
+	}
+	return u, nil
+}
+
+// pathSize returns the size of a file, or of the files under a directory
+func pathSize(path string) int64 {
+	var size int64
+	filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info, err := d.Info(); err == nil && !d.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
+
+// ClearCaches removes the cached schemas, geometry images and embeddings.
+// The neural network and downloaded models are kept: they take long to
+// train or download and have their own screens. Callers drop the schemas
+// they hold so the next save does not write them back.
+func ClearCaches() error {
+	for _, name := range []string{"schemas", "geometry_images", "embeddings.json"} {
+		path, err := cacheSubdir(name)
+		if err != nil {
+			return err
+		}
+		if err := os.RemoveAll(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PruneGeometryImages removes the least recently used geometry images until
+// those left take at most maxBytes, returning how many were removed. Loading
+// an image marks it used. A limit of 0 or less keeps every image.
+func PruneGeometryImages(maxBytes int64) (int, error) {
+	if maxBytes <= 0 {
+		return 0, nil
+	}
+	dir, err := GeometryImagesDir()
+	if err != nil {
+		return 0, err
+	}
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+
+	type image struct {
+		path string
+		size int64
+		used time.Time
+	}
+	var images []image
+	var total int64
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil || e.IsDir() {
+			continue
+		}
+		images = append(images, image{filepath.Join(dir, e.Name()), info.Size(), info.ModTime()})
+		total += info.Size()
+	}
+	sort.Slice(images, func(i, j int) bool { return images[i].used.Before(images[j].used) })
+
+	removed := 0
+	for _, img := range images {
+		if total <= maxBytes {
+			break
+		}
+		if err := os.Remove(img.path); err != nil {
+			return removed, err
+		}
+		total -= img.size
+		removed++
+	}
+	return removed, nil
+}
+
+// markUsed records that a cached file was just used, for eviction
+func markUsed(path string) {
+	now := time.Now()
+	os.Chtimes(path, now, now)
+}
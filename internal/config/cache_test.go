@@ -0,0 +1,129 @@
+package config
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// cacheTestHome points the config and cache directories at a temporary home
+func cacheTestHome(t *testing.T) string {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CACHE_HOME", filepath.Join(home, ".cache"))
+	return home
+}
+
+func TestSchemaCachesLiveInCacheDir(t *testing.T) {
+	home := cacheTestHome(t)
+
+	// A config.json of an earlier version holding a schema
+	configPath, _ := ConfigPath()
+	os.MkdirAll(filepath.Dir(configPath), 0755)
+	legacy := `{"active_service": "prod", "cached_schemas": {"prod": {"service_name": "prod", "tables": [{"name": "roads"}]}}}`
+	if err := os.WriteFile(configPath, []byte(legacy), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.CachedSchemas["dev/local"] = &SchemaCache{Tables: []TableInfo{{Name: "parcels"}}}
+	if err := cfg.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, _ := os.ReadFile(configPath)
+	if strings.Contains(string(data), "cached_schemas") {
+		t.Error("schemas should no longer be written to config.json")
+	}
+	for _, name := range []string{"prod.json", "dev_local.json"} {
+		if _, err := os.Stat(filepath.Join(home, ".cache", "kartoza-pg-ai", "schemas", name)); err != nil {
+			t.Errorf("expected schema cache %s: %v", name, err)
+		}
+	}
+
+	loaded, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded.CachedSchemas) != 2 || loaded.CachedSchemas["dev/local"].Tables[0].Name != "parcels" {
+		t.Fatalf("unexpected schemas %v", loaded.CachedSchemas)
+	}
+
+	// Dropped schemas lose their files
+	delete(loaded.CachedSchemas, "prod")
+	if err := loaded.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if reloaded, _ := Load(); len(reloaded.CachedSchemas) != 1 {
+		t.Errorf("expected one schema left, got %d", len(reloaded.CachedSchemas))
+	}
+}
+
+func TestCachesMoveFromConfigDir(t *testing.T) {
+	home := cacheTestHome(t)
+
+	old := filepath.Join(home, ".config", "kartoza-pg-ai", "geometry_images", "abc.png")
+	os.MkdirAll(filepath.Dir(old), 0755)
+	if err := os.WriteFile(old, []byte("png"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := LoadGeometryImage("abc"); err != nil || data != base64.StdEncoding.EncodeToString([]byte("png")) {
+		t.Errorf("LoadGeometryImage = %q, %v", data, err)
+	}
+	if _, err := os.Stat(old); !os.IsNotExist(err) {
+		t.Error("expected the image to move out of the config directory")
+	}
+}
+
+func TestPruneGeometryImagesRemovesLeastRecentlyUsed(t *testing.T) {
+	cacheTestHome(t)
+
+	var ids []string
+	for i, data := range []string{"first image", "second image", "third image"} {
+		id, err := SaveGeometryImage(base64.StdEncoding.EncodeToString([]byte(data)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		path, _ := GeometryImagePath(id)
+		stamp := time.Now().Add(time.Duration(i-10) * time.Minute)
+		os.Chtimes(path, stamp, stamp)
+		ids = append(ids, id)
+	}
+	// Viewing the oldest makes it the most recently used
+	if _, err := LoadGeometryImage(ids[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	usage, err := DiskUsage()
+	if err != nil || usage.GeometryImages != int64(len("first image")+len("second image")+len("third image")) {
+		t.Fatalf("DiskUsage = %+v, %v", usage, err)
+	}
+	removed, err := PruneGeometryImages(usage.GeometryImages - 1)
+	if err != nil || removed != 1 {
+		t.Fatalf("PruneGeometryImages = %d, %v", removed, err)
+	}
+	if _, err := LoadGeometryImage(ids[1]); err == nil {
+		t.Error("expected the least recently used image to be removed")
+	}
+	for _, id := range []string{ids[0], ids[2]} {
+		if _, err := LoadGeometryImage(id); err != nil {
+			t.Errorf("image %s should be kept: %v", id, err)
+		}
+	}
+
+	if err := ClearCaches(); err != nil {
+		t.Fatal(err)
+	}
+	if usage, _ := DiskUsage(); usage.Total() != 0 {
+		t.Errorf("expected empty caches, got %+v", usage)
+	}
+}
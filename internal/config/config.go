@@ -1,21 +1,80 @@
 package config
 
 import (
+	"bytes"
 	"crypto/sha256"
 	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 )
 
 // Config represents the application configuration
 type Config struct {
 	ActiveService string                  `json:"active_service"`
-	CachedSchemas map[string]*SchemaCache `json:"cached_schemas"`
-	QueryHistory  []QueryHistoryEntry     `json:"query_history"`
+	CachedSchemas map[string]*SchemaCache `json:"cached_schemas,omitempty"` // Kept in SchemaCacheDir; read here from earlier versions
+	QueryHistory  []QueryHistoryEntry     `json:"query_history,omitempty"`  // Legacy; moved into history.db by Load
 	Settings      Settings                `json:"settings"`
+	// Last value entered for each {{name}} query template parameter
+	TemplateParams map[string]string `json:"template_params,omitempty"`
+	// Per-service overrides of Settings, keyed by service name
+	ServiceProfiles map[string]ServiceProfile `json:"service_profiles,omitempty"`
+	// Words tied to tables and columns by correcting generated SQL, keyed
+	// by service name
+	Aliases map[string][]Alias `json:"aliases,omitempty"`
+	// User-defined colour themes, keyed by the name Settings.Theme selects
+	Themes map[string]ThemePalette `json:"themes,omitempty"`
+	// When each service was last connected to, for listing the most
+	// recently used first
+	LastUsed map[string]time.Time `json:"last_used,omitempty"`
+
+	// config.json and the schemas as last read or written, so a save keeps
+	// what other running instances saved since
+	base        []byte
+	baseSchemas map[string]time.Time
+}
+
+// ServiceProfile overrides settings for one service, e.g. to keep a
+// production replica read-only while a local sandbox allows writes. Unset
+// fields keep the global setting.
+type ServiceProfile struct {
+	DefaultRowLimit     int      `json:"default_row_limit,omitempty"`
+	WriteModeEnabled    *bool    `json:"write_mode_enabled,omitempty"` // false keeps the service read-only
+	LLMProvider         string   `json:"llm_provider,omitempty"`
+	Schemas             []string `json:"schemas,omitempty"`               // Only these schemas are used to answer questions
+	StatementTimeoutSec int      `json:"statement_timeout_sec,omitempty"` // -1 for no limit
+
+	// Words added to the global glossary, replacing those defined in both
+	Glossary map[string]string `json:"glossary,omitempty"`
+}
+
+// ThemePalette is a colour theme of the interface. Colours are hex, e.g.
+// "#DDA036"; in user-defined themes unset colours come from the built-in
+// theme named by Base.
+type ThemePalette struct {
+	Base      string `json:"base,omitempty"`       // "dark" (default), "light" or "high-contrast"
+	Accent    string `json:"accent,omitempty"`     // Titles, selections and prompts
+	Secondary string `json:"secondary,omitempty"`  // Subtitles and SQL keywords
+	Muted     string `json:"muted,omitempty"`      // Labels, help and inactive items
+	Text      string `json:"text,omitempty"`       // Values and plain text
+	Surface   string `json:"surface,omitempty"`    // Background of selected rows and borders of panes
+	Error     string `json:"error,omitempty"`      // Errors and failures
+	Success   string `json:"success,omitempty"`    // Successes and SQL strings
+	Info      string `json:"info,omitempty"`       // SQL and function names
+	OnAccent  string `json:"on_accent,omitempty"`  // Text on accent backgrounds
+	StatusBar string `json:"status_bar,omitempty"` // Background of the editor status line
+
+	// Geometry image colours, taken over by the geometry settings when the
+	// theme is chosen
+	GeometryBackground string `json:"geometry_background,omitempty"`
+	GeometryLine       string `json:"geometry_line,omitempty"`
+	GeometryFill       string `json:"geometry_fill,omitempty"`
+	GeometryPoint      string `json:"geometry_point,omitempty"`
 }
 
 // Settings contains user preferences
@@ -23,10 +82,101 @@ type Settings struct {
 	MaxHistorySize    int    `json:"max_history_size"`
 	DefaultRowLimit   int    `json:"default_row_limit"`
 	EnableSpatialOps  bool   `json:"enable_spatial_ops"`
-	LLMModelPath      string `json:"llm_model_path"`
+	LLMModelPath      string `json:"llm_model_path"` // GGUF model the llamacpp provider's server runs
 	SchemaCacheTTLMin int    `json:"schema_cache_ttl_min"`
 	VimModeEnabled    bool   `json:"vim_mode_enabled"`
 	NeuralNetEnabled  bool   `json:"neural_net_enabled"`
+	WriteModeEnabled  bool   `json:"write_mode_enabled"`  // Allow INSERT/UPDATE/DELETE/DDL after confirmation
+	HarvestWorkers    int    `json:"harvest_workers"`     // Parallel column queries during schema harvest
+	HarvestStats      bool   `json:"harvest_stats"`       // Harvest pg_stats column statistics (sample values reach the LLM)
+	PoolMaxConns      int    `json:"pool_max_conns"`      // Maximum open connections per service
+	HealthCheckSec    int    `json:"health_check_sec"`    // Seconds between background connection pings
+	FreezeFirstColumn bool   `json:"freeze_first_column"` // Keep the first result column visible when scrolling sideways
+	LogLevel          string `json:"log_level"`           // "debug", "info", "warn" or "error"
+	Theme             string `json:"theme"`               // "dark", "light", "high-contrast" or a name in Config.Themes
+	PlainOutput       bool   `json:"plain_output"`        // No colours, box drawing or emoji, for screen readers and logs
+
+	// Connect to the last used service on startup instead of showing the
+	// menu
+	ConnectLastService bool `json:"connect_last_service"`
+
+	// Seconds a statement may run before PostgreSQL cancels it; 0 for no
+	// limit
+	StatementTimeoutSec int `json:"statement_timeout_sec"`
+
+	// Add DefaultRowLimit to generated queries listing rows without a
+	// LIMIT of their own
+	AutoLimit bool `json:"auto_limit"`
+
+	// How dates and times in results are shown: ISO (2024-03-31 14:05) when
+	// empty, "us" (03/31/2024 2:05 PM) or "european" (31/03/2024 14:05)
+	DateStyle string `json:"date_style,omitempty"`
+
+	// OpenTelemetry trace export URL, e.g. http://localhost:4318; falls
+	// back to the OTEL_EXPORTER_OTLP_ENDPOINT environment variable
+	OTLPEndpoint string `json:"otlp_endpoint,omitempty"`
+
+	// LLM provider settings
+	LLMProvider     string `json:"llm_provider"`             // "rules", "openai", "ollama", "llamacpp" or "claude"
+	OpenAIAPIKey    string `json:"openai_api_key,omitempty"` // Falls back to OPENAI_API_KEY env var
+	OpenAIModel     string `json:"openai_model"`
+	OpenAIBaseURL   string `json:"openai_base_url,omitempty"` // Override for OpenAI-compatible endpoints
+	OllamaBaseURL   string `json:"ollama_base_url"`
+	OllamaModel     string `json:"ollama_model"`
+	LlamaCppBaseURL string `json:"llamacpp_base_url,omitempty"` // OpenAI-compatible API of llama-server
+	AnthropicAPIKey string `json:"anthropic_api_key,omitempty"` // Falls back to ANTHROPIC_API_KEY env var
+	AnthropicModel  string `json:"anthropic_model"`
+
+	// Semantic schema search: "none", "ollama" or "openai", and the
+	// embedding model (empty for the provider's default)
+	EmbeddingProvider string `json:"embedding_provider,omitempty"`
+	EmbeddingModel    string `json:"embedding_model,omitempty"`
+
+	// Schema given to LLM providers: at most PromptMaxTables tables, the
+	// most relevant to the question, within PromptTokenBudget estimated
+	// tokens; 0 leaves either unlimited
+	PromptMaxTables   int `json:"prompt_max_tables"`
+	PromptTokenBudget int `json:"prompt_token_budget"`
+
+	// What answers a question when a session starts: "auto", "rules", "nn"
+	// or a provider name; the query screen switches it per question
+	QueryGenerator string `json:"query_generator,omitempty"`
+
+	// Prices of LLM models in US dollars per million tokens, by model name
+	// or name prefix, replacing the built-in prices costs are estimated with
+	ModelPrices map[string]ModelPrice `json:"model_prices,omitempty"`
+
+	// Where places named in distance questions ("within 1 km of Cape Town")
+	// are found: a table of place names with geometries, as "schema.table"
+	// (tables named like "places" or "geonames" are found without it), and a
+	// Nominatim-compatible search API for places it does not hold
+	GazetteerTable string `json:"gazetteer_table,omitempty"`
+	GeocoderURL    string `json:"geocoder_url,omitempty"`
+
+	// Words questions use for the schema's words, e.g. "client": "customer"
+	// or "erf": "parcel", looked up before words are matched to tables and
+	// columns
+	Glossary map[string]string `json:"glossary,omitempty"`
+
+	// Encrypt config.json, cached geometry images, conversations, snapshots
+	// and drafts with AES-GCM. The query history database is not encrypted.
+	// The key is kept in the OS keyring, or derived from a passphrase asked
+	// for at startup when the source is "passphrase" or there is no keyring.
+	EncryptAtRest       bool   `json:"encrypt_at_rest,omitempty"`
+	EncryptionKeySource string `json:"encryption_key_source,omitempty"` // "keyring" (default) or "passphrase"
+
+	// Megabytes of geometry images kept in the cache; the least recently
+	// used are removed beyond it (0 keeps them all)
+	GeometryCacheMB int `json:"geometry_cache_mb"`
+
+	// Geometry preview style, also used by the map view and reports
+	GeometryStrokeWidth float64 `json:"geometry_stroke_width"` // Outline width in pixels
+	GeometryPointSize   float64 `json:"geometry_point_size"`   // Point radius in pixels
+	GeometryLineColor   string  `json:"geometry_line_color"`   // Hex colours, e.g. "#ffa500"
+	GeometryFillColor   string  `json:"geometry_fill_color"`
+	GeometryFillOpacity float64 `json:"geometry_fill_opacity"` // 0 (outline only) to 1
+	GeometryPointColor  string  `json:"geometry_point_color"`
+	GeometryBackground  string  `json:"geometry_background"`
 }
 
 // SchemaCache represents cached database schema
@@ -38,14 +188,284 @@ type SchemaCache struct {
 	CachedAt    time.Time        `json:"cached_at"`
 	HasPostGIS  bool             `json:"has_postgis"`
 	Version     string           `json:"version"`
+	Sequences   []SequenceInfo   `json:"sequences,omitempty"`
+
+	// Extensions adding spatial types and functions beyond PostGIS geometries
+	HasRaster     bool `json:"has_raster,omitempty"`     // postgis_raster
+	HasPointCloud bool `json:"has_pointcloud,omitempty"` // pointcloud (pgPointCloud)
+	HasPgRouting  bool `json:"has_pgrouting,omitempty"`  // pgrouting
+}
+
+// FilterSchemas returns a copy of the cache holding only the tables, views,
+// functions and sequences in the given schemas; all of them when empty
+func (s *SchemaCache) FilterSchemas(schemas []string) *SchemaCache {
+	if s == nil || len(schemas) == 0 {
+		return s
+	}
+	keep := make(map[string]bool, len(schemas))
+	for _, name := range schemas {
+		keep[name] = true
+	}
+
+	filtered := *s
+	filtered.Tables, filtered.Views, filtered.Functions, filtered.Sequences = nil, nil, nil, nil
+	for _, t := range s.Tables {
+		if keep[t.Schema] {
+			filtered.Tables = append(filtered.Tables, t)
+		}
+	}
+	for _, v := range s.Views {
+		if keep[v.Schema] {
+			filtered.Views = append(filtered.Views, v)
+		}
+	}
+	for _, f := range s.Functions {
+		if keep[f.Schema] {
+			filtered.Functions = append(filtered.Functions, f)
+		}
+	}
+	for _, seq := range s.Sequences {
+		if keep[seq.Schema] {
+			filtered.Sequences = append(filtered.Sequences, seq)
+		}
+	}
+	return &filtered
+}
+
+// WithoutPartitions returns a copy of the cache without the partitions of
+// tables it also holds, so queries target the partitioned parent rather than
+// a single partition. The cache itself is returned when it has none.
+func (s *SchemaCache) WithoutPartitions() *SchemaCache {
+	if s == nil {
+		return s
+	}
+	known := make(map[string]bool, len(s.Tables))
+	for _, t := range s.Tables {
+		known[t.Schema+"."+t.Name] = true
+	}
+
+	var tables []TableInfo
+	for _, t := range s.Tables {
+		if !known[t.PartitionOf] {
+			tables = append(tables, t)
+		}
+	}
+	if len(tables) == len(s.Tables) {
+		return s
+	}
+	pruned := *s
+	pruned.Tables = tables
+	return &pruned
+}
+
+// Readable returns a copy of the cache without the tables the harvesting
+// role cannot select from, so no SQL is generated against them. The cache
+// itself is returned when every table is readable.
+func (s *SchemaCache) Readable() *SchemaCache {
+	if s == nil {
+		return s
+	}
+	var tables []TableInfo
+	for _, t := range s.Tables {
+		if t.CanRead() {
+			tables = append(tables, t)
+		}
+	}
+	if len(tables) == len(s.Tables) {
+		return s
+	}
+	readable := *s
+	readable.Tables = tables
+	return &readable
 }
 
 // TableInfo represents a database table
 type TableInfo struct {
-	Schema  string       `json:"schema"`
-	Name    string       `json:"name"`
-	Columns []ColumnInfo `json:"columns"`
-	Comment string       `json:"comment,omitempty"`
+	Schema      string           `json:"schema"`
+	Name        string           `json:"name"`
+	Columns     []ColumnInfo     `json:"columns"`
+	Comment     string           `json:"comment,omitempty"`
+	Indexes     []IndexInfo      `json:"indexes,omitempty"`
+	Constraints []ConstraintInfo `json:"constraints,omitempty"`
+	// Parent ("schema.table") of a partition or inheritance child
+	PartitionOf string `json:"partition_of,omitempty"`
+	// Partition key of a partitioned table, e.g. "RANGE (created_at)"
+	PartitionKey string     `json:"partition_key,omitempty"`
+	Size         *TableSize `json:"size,omitempty"`
+	// What the harvesting role may do with the table; nil in caches
+	// harvested before privileges were
+	Privileges  *TablePrivileges `json:"privileges,omitempty"`
+	RowSecurity *RowSecurity     `json:"row_security,omitempty"` // nil when not enabled
+}
+
+// RowSecurity is the row-level security enabled on a table
+type RowSecurity struct {
+	// Active is set when the policies apply to the harvesting role, which
+	// neither owns the table (unless security is forced) nor bypasses them
+	Active   bool         `json:"active"`
+	Policies []PolicyInfo `json:"policies,omitempty"`
+}
+
+// PolicyInfo is a row-level security policy of a table
+type PolicyInfo struct {
+	Name        string   `json:"name"`
+	Command     string   `json:"command"`     // ALL, SELECT, INSERT, UPDATE or DELETE
+	Restrictive bool     `json:"restrictive"` // Must pass as well as one permissive policy
+	Roles       []string `json:"roles,omitempty"`
+	Using       string   `json:"using,omitempty"`      // Rows visible or affected
+	WithCheck   string   `json:"with_check,omitempty"` // Rows that may be written
+}
+
+// RowSecurityActive reports whether policies limit the rows the harvesting
+// role sees in the table
+func (t *TableInfo) RowSecurityActive() bool {
+	return t.RowSecurity != nil && t.RowSecurity.Active
+}
+
+// PolicyNames returns the names of the policies limiting the rows selected
+func (r *RowSecurity) PolicyNames() []string {
+	var names []string
+	for _, p := range r.Policies {
+		if p.Command == "ALL" || p.Command == "SELECT" {
+			names = append(names, p.Name)
+		}
+	}
+	return names
+}
+
+// TablePrivileges are the privileges the role harvesting the schema has
+// on a table, directly, through PUBLIC or through the roles it belongs to,
+// on the whole table or, for Select, Insert and Update, on any column
+type TablePrivileges struct {
+	Select bool `json:"select"`
+	Insert bool `json:"insert"`
+	Update bool `json:"update"`
+	Delete bool `json:"delete"`
+}
+
+// CanRead reports whether the role may select from the table, which is
+// assumed when its privileges are unknown
+func (t *TableInfo) CanRead() bool {
+	return t.Privileges == nil || t.Privileges.Select
+}
+
+// TableSize holds the planner's row estimate and the on-disk size of a
+// table; for partitioned tables both cover all of its partitions
+type TableSize struct {
+	RowEstimate int64 `json:"row_estimate"` // pg_class.reltuples; -1 when never analyzed
+	TotalBytes  int64 `json:"total_bytes"`  // pg_total_relation_size, indexes and TOAST included
+}
+
+// Summary returns a compact description of the size for schema prompts
+func (s *TableSize) Summary() string {
+	if s == nil {
+		return ""
+	}
+	rows := "rows unknown"
+	if s.RowEstimate >= 0 {
+		rows = "~" + HumanCount(s.RowEstimate) + " rows"
+	}
+	return rows + ", " + HumanBytes(s.TotalBytes)
+}
+
+// HumanCount abbreviates a count, e.g. 1234567 as "1.2M"
+func HumanCount(n int64) string {
+	switch {
+	case n >= 1e9:
+		return fmt.Sprintf("%.1fB", float64(n)/1e9)
+	case n >= 1e6:
+		return fmt.Sprintf("%.1fM", float64(n)/1e6)
+	case n >= 1e3:
+		return fmt.Sprintf("%.1fk", float64(n)/1e3)
+	}
+	return strconv.FormatInt(n, 10)
+}
+
+// HumanBytes formats a byte count with a binary unit, e.g. "3.4 GB"
+func HumanBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "kMGTPE"[exp])
+}
+
+// HumanDuration formats a duration in its two largest units, e.g. "3m40s"
+// or "2d5h"; under a minute in seconds, e.g. "12.5s", or milliseconds
+func HumanDuration(d time.Duration) string {
+	if d < 0 {
+		return "-" + HumanDuration(-d)
+	}
+	switch {
+	case d < time.Second:
+		return fmt.Sprintf("%dms", d.Milliseconds())
+	case d < time.Minute:
+		return strings.TrimSuffix(fmt.Sprintf("%.1f", d.Seconds()), ".0") + "s"
+	}
+	units := []struct {
+		name string
+		size time.Duration
+	}{{"d", 24 * time.Hour}, {"h", time.Hour}, {"m", time.Minute}, {"s", time.Second}}
+	var b strings.Builder
+	shown := 0
+	for _, u := range units {
+		if n := d / u.size; n > 0 || shown > 0 {
+			if n > 0 {
+				fmt.Fprintf(&b, "%d%s", n, u.name)
+			}
+			d -= n * u.size
+			if shown++; shown == 2 {
+				break
+			}
+		}
+	}
+	return b.String()
+}
+
+// IndexInfo represents an index on a table
+type IndexInfo struct {
+	Name       string   `json:"name"`
+	Columns    []string `json:"columns"` // Empty for pure expression indexes
+	Method     string   `json:"method"`  // btree, gist, gin, brin, ...
+	IsUnique   bool     `json:"is_unique"`
+	IsPrimary  bool     `json:"is_primary"`
+	Definition string   `json:"definition"` // Output of pg_get_indexdef
+}
+
+// IsSpatial reports whether the index can serve spatial operators
+func (i IndexInfo) IsSpatial() bool {
+	return i.Method == "gist" || i.Method == "spgist" || i.Method == "brin"
+}
+
+// ConstraintInfo represents a CHECK or UNIQUE constraint
+type ConstraintInfo struct {
+	Name       string   `json:"name"`
+	Type       string   `json:"type"` // "CHECK" or "UNIQUE"
+	Columns    []string `json:"columns,omitempty"`
+	Definition string   `json:"definition"` // Output of pg_get_constraintdef
+}
+
+// SequenceInfo represents a sequence
+type SequenceInfo struct {
+	Schema   string `json:"schema"`
+	Name     string `json:"name"`
+	DataType string `json:"data_type"`
+	OwnedBy  string `json:"owned_by,omitempty"` // schema.table.column the sequence feeds
+}
+
+// HasSpatialIndex reports whether column is the leading column of a spatial index
+func (t TableInfo) HasSpatialIndex(column string) bool {
+	for _, idx := range t.Indexes {
+		if idx.IsSpatial() && len(idx.Columns) > 0 && idx.Columns[0] == column {
+			return true
+		}
+	}
+	return false
 }
 
 // ViewInfo represents a database view
@@ -55,21 +475,71 @@ type ViewInfo struct {
 	Columns    []ColumnInfo `json:"columns"`
 	Comment    string       `json:"comment,omitempty"`
 	Definition string       `json:"definition,omitempty"`
+	// Materialized views hold a snapshot refreshed with REFRESH MATERIALIZED VIEW
+	IsMaterialized bool `json:"is_materialized,omitempty"`
 }
 
 // ColumnInfo represents a table column
 type ColumnInfo struct {
-	Name         string `json:"name"`
-	DataType     string `json:"data_type"`
-	IsNullable   bool   `json:"is_nullable"`
-	IsPrimaryKey bool   `json:"is_primary_key"`
-	IsForeignKey bool   `json:"is_foreign_key"`
-	FKTable      string `json:"fk_table,omitempty"`
-	FKColumn     string `json:"fk_column,omitempty"`
-	Comment      string `json:"comment,omitempty"`
-	IsGeometry   bool   `json:"is_geometry"`
-	GeomType     string `json:"geom_type,omitempty"`
-	SRID         int    `json:"srid,omitempty"`
+	Name         string       `json:"name"`
+	DataType     string       `json:"data_type"`
+	IsNullable   bool         `json:"is_nullable"`
+	IsPrimaryKey bool         `json:"is_primary_key"`
+	IsForeignKey bool         `json:"is_foreign_key"`
+	FKTable      string       `json:"fk_table,omitempty"`
+	FKColumn     string       `json:"fk_column,omitempty"`
+	Comment      string       `json:"comment,omitempty"`
+	IsGeometry   bool         `json:"is_geometry"`
+	GeomType     string       `json:"geom_type,omitempty"`
+	SRID         int          `json:"srid,omitempty"`
+	Stats        *ColumnStats `json:"stats,omitempty"`
+	IsVector     bool         `json:"is_vector,omitempty"`     // pgvector vector, halfvec or sparsevec
+	VectorDims   int          `json:"vector_dims,omitempty"`   // Declared dimensions; 0 when unconstrained
+	IsRaster     bool         `json:"is_raster,omitempty"`     // PostGIS raster
+	RasterBands  int          `json:"raster_bands,omitempty"`  // Bands of each raster; 0 when not constrained
+	IsPointCloud bool         `json:"is_pointcloud,omitempty"` // pgPointCloud pcpatch or pcpoint
+	// Values the column is documented to hold, from a dbt accepted_values
+	// test or a "Values: a, b" comment
+	AcceptedValues []string `json:"accepted_values,omitempty"`
+}
+
+// IsJSON reports whether the column holds json or jsonb documents
+func (c ColumnInfo) IsJSON() bool {
+	return c.DataType == "json" || c.DataType == "jsonb"
+}
+
+// ColumnStats holds planner statistics for a column, taken from pg_stats
+type ColumnStats struct {
+	DistinctCount float64  `json:"distinct_count"`          // n_distinct: >0 is a count, <0 a fraction of rows
+	NullFraction  float64  `json:"null_fraction"`           // Fraction of rows that are NULL
+	MinValue      string   `json:"min_value,omitempty"`     // Lowest histogram bound (approximate minimum)
+	MaxValue      string   `json:"max_value,omitempty"`     // Highest histogram bound (approximate maximum)
+	CommonValues  []string `json:"common_values,omitempty"` // Most common values, most frequent first
+}
+
+// Summary returns a compact description of the statistics for schema prompts
+func (s *ColumnStats) Summary() string {
+	if s == nil {
+		return ""
+	}
+
+	var parts []string
+	if len(s.CommonValues) > 0 {
+		quoted := make([]string, len(s.CommonValues))
+		for i, v := range s.CommonValues {
+			quoted[i] = "'" + v + "'"
+		}
+		parts = append(parts, "values: "+strings.Join(quoted, ", "))
+	}
+	if s.MinValue != "" && s.MaxValue != "" {
+		parts = append(parts, "range: "+s.MinValue+" .. "+s.MaxValue)
+	}
+	if s.DistinctCount > 0 {
+		parts = append(parts, fmt.Sprintf("~%.0f distinct", s.DistinctCount))
+	} else if s.DistinctCount == -1 {
+		parts = append(parts, "unique")
+	}
+	return strings.Join(parts, "; ")
 }
 
 // FunctionInfo represents a database function
@@ -83,9 +553,11 @@ type FunctionInfo struct {
 
 // QueryHistoryEntry represents a query in history
 type QueryHistoryEntry struct {
+	ID              int64     `json:"id,omitempty"` // Row ID in the history database
 	Timestamp       time.Time `json:"timestamp"`
 	NaturalQuery    string    `json:"natural_query"`
 	GeneratedSQL    string    `json:"generated_sql"`
+	EditedSQL       string    `json:"edited_sql,omitempty"` // SQL actually run when the user edited GeneratedSQL
 	ServiceName     string    `json:"service_name"`
 	RowsAffected    int       `json:"rows_affected"`
 	ExecutionTime   float64   `json:"execution_time_ms"`
@@ -93,6 +565,10 @@ type QueryHistoryEntry struct {
 	ErrorMessage    string    `json:"error_message,omitempty"`
 	HasGeometry     bool      `json:"has_geometry,omitempty"`
 	GeometryImageID string    `json:"geometry_image_id,omitempty"` // Filename of cached PNG image
+	Generator       string    `json:"generator,omitempty"`         // What generated the SQL, e.g. "rules" or "ollama/llama3"
+	InputTokens     int       `json:"input_tokens,omitempty"`      // Prompt tokens sent to LLM providers answering it
+	OutputTokens    int       `json:"output_tokens,omitempty"`     // Completion tokens the providers replied with
+	Cost            float64   `json:"cost,omitempty"`              // Estimated cost of the provider requests in US dollars
 }
 
 // DefaultConfig returns a new config with default values
@@ -109,6 +585,34 @@ func DefaultConfig() *Config {
 			SchemaCacheTTLMin: 1440, // 24 hours
 			VimModeEnabled:    true,
 			NeuralNetEnabled:  true, // Enable NN by default
+			WriteModeEnabled:  false,
+			HarvestWorkers:    4,
+			HarvestStats:      true,
+			PoolMaxConns:      4,
+			HealthCheckSec:    30,
+			FreezeFirstColumn: true,
+			LogLevel:          "info",
+			Theme:             "dark",
+			GeometryCacheMB:   256,
+			LLMProvider:       "rules",
+			OpenAIModel:       "gpt-4o-mini",
+			OllamaBaseURL:     "http://localhost:11434",
+			OllamaModel:       "llama3",
+			AnthropicModel:    "claude-sonnet-4-5",
+
+			PromptMaxTables:   40,
+			PromptTokenBudget: 12000,
+
+			StatementTimeoutSec: 60,
+			AutoLimit:           true,
+
+			GeometryStrokeWidth: 1.5,
+			GeometryPointSize:   3,
+			GeometryLineColor:   "#ffa500",
+			GeometryFillColor:   "#ffa500",
+			GeometryFillOpacity: 0.3,
+			GeometryPointColor:  "#00c8ff",
+			GeometryBackground:  "#1e1e1e",
 		},
 	}
 }
@@ -122,6 +626,20 @@ func ConfigDir() (string, error) {
 	return filepath.Join(home, ".config", "kartoza-pg-ai"), nil
 }
 
+// LogDir returns the directory of the application log and its rotated files
+func LogDir() (string, error) {
+	dir, err := ConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "logs"), nil
+}
+
+// ModelsDir returns the directory local GGUF models are downloaded into
+func ModelsDir() (string, error) {
+	return cacheSubdir("models")
+}
+
 // ConfigPath returns the configuration file path
 func ConfigPath() (string, error) {
 	dir, err := ConfigDir()
@@ -138,7 +656,10 @@ func Load() (*Config, error) {
 		return nil, err
 	}
 
-	data, err := os.ReadFile(path)
+	// Caches earlier versions kept beside the config move out first
+	migrateCacheDirs()
+
+	data, err := readFile(path)
 	if err != nil {
 		if os.IsNotExist(err) {
 			return DefaultConfig(), nil
@@ -146,21 +667,52 @@ func Load() (*Config, error) {
 		return nil, err
 	}
 
-	var cfg Config
-	if err := json.Unmarshal(data, &cfg); err != nil {
+	// Start from defaults so settings added in newer versions get sane values
+	cfg := DefaultConfig()
+	if err := json.Unmarshal(data, cfg); err != nil {
 		return nil, err
 	}
+	cfg.base = data
 
 	// Ensure maps are initialized
 	if cfg.CachedSchemas == nil {
 		cfg.CachedSchemas = make(map[string]*SchemaCache)
 	}
 
-	return &cfg, nil
+	// Schemas live in the cache directory; ones still in config.json from
+	// earlier versions move there on the next save
+	schemas, err := loadSchemaCaches()
+	if err != nil {
+		return nil, err
+	}
+	cfg.baseSchemas = make(map[string]time.Time, len(schemas))
+	for service, schema := range schemas {
+		cfg.CachedSchemas[service] = schema
+		cfg.baseSchemas[service] = schema.CachedAt
+	}
+
+	// On failure the JSON history is kept and the migration retried next time
+	_ = cfg.migrateJSONHistory()
+
+	setEncryptionEnabled(cfg.Settings.EncryptAtRest)
+
+	return cfg, nil
 }
 
-// Save saves the configuration to disk
+// Save saves the configuration to disk. Other running instances may have
+// saved since it was read: settings changed only there keep their new
+// values and schemas harvested there are kept, and c picks both up.
 func (c *Config) Save() error {
+	unlock, err := lockConfig()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return c.save()
+}
+
+// save saves the configuration while the config is locked
+func (c *Config) save() error {
 	dir, err := ConfigDir()
 	if err != nil {
 		return err
@@ -175,22 +727,127 @@ func (c *Config) Save() error {
 		return err
 	}
 
-	data, err := json.MarshalIndent(c, "", "  ")
+	// Schemas are written to the cache directory instead
+	saved := *c
+	saved.CachedSchemas = nil
+	data, err := json.MarshalIndent(&saved, "", "  ")
 	if err != nil {
 		return err
 	}
+	if theirs, err := readFile(path); err == nil && !bytes.Equal(theirs, c.base) {
+		if data, err = c.merge(data, theirs); err != nil {
+			return err
+		}
+	}
+
+	// Turned on from the settings screen, encryption starts at once when
+	// the keyring has a key; a passphrase is asked for at the next start
+	if c.Settings.EncryptAtRest && c.Settings.EncryptionKeySource != KeySourcePassphrase && !hasEncryptionKey() {
+		if key, err := keyringKey(true); err == nil {
+			SetEncryptionKey(key, KeySourceKeyring)
+		}
+	}
+	wasEncrypted, _ := EncryptionActive()
+	if old, err := os.ReadFile(path); err == nil {
+		wasEncrypted = isEncrypted(old)
+	}
+	setEncryptionEnabled(c.Settings.EncryptAtRest)
+
+	if err := writeFile(path, data, 0600); err != nil {
+		return err
+	}
+	c.base = data
 
-	return os.WriteFile(path, data, 0644)
+	if c.CachedSchemas == nil {
+		c.CachedSchemas = make(map[string]*SchemaCache)
+	}
+	if err := saveSchemaCaches(c.CachedSchemas, c.baseSchemas); err != nil {
+		return err
+	}
+	c.baseSchemas = make(map[string]time.Time, len(c.CachedSchemas))
+	for service, schema := range c.CachedSchemas {
+		c.baseSchemas[service] = schema.CachedAt
+	}
+	if encrypted, _ := EncryptionActive(); encrypted != wasEncrypted {
+		return rewriteSealedFiles()
+	}
+	return nil
 }
 
-// AddQueryToHistory adds a query to the history
-func (c *Config) AddQueryToHistory(entry QueryHistoryEntry) {
-	c.QueryHistory = append([]QueryHistoryEntry{entry}, c.QueryHistory...)
+// MarkServiceUsed records connecting to a service now and makes it the
+// active service
+func (c *Config) MarkServiceUsed(service string) {
+	c.ActiveService = service
+	if c.LastUsed == nil {
+		c.LastUsed = make(map[string]time.Time)
+	}
+	c.LastUsed[service] = time.Now()
+}
 
-	// Trim to max size
-	if len(c.QueryHistory) > c.Settings.MaxHistorySize {
-		c.QueryHistory = c.QueryHistory[:c.Settings.MaxHistorySize]
+// SetTemplateParams remembers parameter values as defaults for the next prompt
+func (c *Config) SetTemplateParams(values map[string]string) {
+	if c.TemplateParams == nil {
+		c.TemplateParams = make(map[string]string)
 	}
+	for name, v := range values {
+		c.TemplateParams[name] = v
+	}
+}
+
+// Profile returns the overrides for a service (empty when it has none)
+func (c *Config) Profile(service string) ServiceProfile {
+	return c.ServiceProfiles[service]
+}
+
+// SetProfile stores the overrides for a service, dropping empty profiles
+func (c *Config) SetProfile(service string, p ServiceProfile) {
+	if p.DefaultRowLimit == 0 && p.WriteModeEnabled == nil && p.LLMProvider == "" && len(p.Schemas) == 0 &&
+		p.StatementTimeoutSec == 0 && len(p.Glossary) == 0 {
+		delete(c.ServiceProfiles, service)
+		return
+	}
+	if c.ServiceProfiles == nil {
+		c.ServiceProfiles = make(map[string]ServiceProfile)
+	}
+	c.ServiceProfiles[service] = p
+}
+
+// SettingsFor returns the settings in effect for a service: the global
+// settings with the service's profile applied
+func (c *Config) SettingsFor(service string) Settings {
+	s := c.Settings
+	p := c.Profile(service)
+	if p.DefaultRowLimit > 0 {
+		s.DefaultRowLimit = p.DefaultRowLimit
+	}
+	if p.WriteModeEnabled != nil {
+		s.WriteModeEnabled = *p.WriteModeEnabled
+	}
+	if p.LLMProvider != "" {
+		s.LLMProvider = p.LLMProvider
+	}
+	switch {
+	case p.StatementTimeoutSec > 0:
+		s.StatementTimeoutSec = p.StatementTimeoutSec
+	case p.StatementTimeoutSec < 0:
+		s.StatementTimeoutSec = 0
+	}
+	if len(p.Glossary) > 0 {
+		glossary := make(map[string]string, len(s.Glossary)+len(p.Glossary))
+		for word, term := range s.Glossary {
+			glossary[word] = term
+		}
+		for word, term := range p.Glossary {
+			glossary[word] = term
+		}
+		s.Glossary = glossary
+	}
+	return s
+}
+
+// StatementTimeout returns how long a statement may run, 0 for no limit
+func (s Settings) StatementTimeout() time.Duration {
+	return time.Duration(max(s.StatementTimeoutSec, 0)) * time.Second
 }
 
 // IsSchemaCacheValid checks if the cached schema exists (no TTL - persistent until manual refresh)
@@ -201,11 +858,7 @@ func (c *Config) IsSchemaCacheValid(serviceName string) bool {
 
 // GeometryImagesDir returns the directory path for cached geometry images
 func GeometryImagesDir() (string, error) {
-	dir, err := ConfigDir()
-	if err != nil {
-		return "", err
-	}
-	return filepath.Join(dir, "geometry_images"), nil
+	return cacheSubdir("geometry_images")
 }
 
 // SaveGeometryImage saves a base64-encoded PNG image to the cache folder
@@ -236,7 +889,7 @@ func SaveGeometryImage(base64Data string) (string, error) {
 
 	// Write the file
 	filePath := filepath.Join(dir, imageID+".png")
-	if err := os.WriteFile(filePath, pngData, 0644); err != nil {
+	if err := writeFile(filePath, pngData, 0644); err != nil {
 		return "", err
 	}
 
@@ -255,10 +908,11 @@ func LoadGeometryImage(imageID string) (string, error) {
 	}
 
 	filePath := filepath.Join(dir, imageID+".png")
-	pngData, err := os.ReadFile(filePath)
+	pngData, err := readFile(filePath)
 	if err != nil {
 		return "", err
 	}
+	markUsed(filePath)
 
 	return base64.StdEncoding.EncodeToString(pngData), nil
 }
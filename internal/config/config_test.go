@@ -86,6 +86,8 @@ func TestConfigSaveAndLoad(t *testing.T) {
 var configDir = ConfigDir
 
 func TestAddQueryToHistory(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	defer CloseHistory()
 	cfg := DefaultConfig()
 
 	entry := QueryHistoryEntry{
@@ -98,37 +100,58 @@ func TestAddQueryToHistory(t *testing.T) {
 		Success:       true,
 	}
 
-	cfg.AddQueryToHistory(entry)
+	if err := cfg.AddQueryToHistory(entry); err != nil {
+		t.Fatalf("AddQueryToHistory error: %v", err)
+	}
 
-	if len(cfg.QueryHistory) != 1 {
-		t.Errorf("expected 1 history entry, got %d", len(cfg.QueryHistory))
+	store, err := History()
+	if err != nil {
+		t.Fatalf("History error: %v", err)
+	}
+	entries, err := store.List(HistoryFilter{ServiceName: "test"})
+	if err != nil {
+		t.Fatalf("List error: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 history entry, got %d", len(entries))
 	}
 
-	if cfg.QueryHistory[0].NaturalQuery != "How many users?" {
-		t.Errorf("unexpected query: %s", cfg.QueryHistory[0].NaturalQuery)
+	if entries[0].NaturalQuery != "How many users?" || entries[0].ExecutionTime != 10.5 || !entries[0].Success {
+		t.Errorf("unexpected entry: %+v", entries[0])
 	}
 }
 
 func TestHistoryTrimming(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	defer CloseHistory()
 	cfg := DefaultConfig()
 	cfg.Settings.MaxHistorySize = 5
 
 	// Add more entries than max
+	start := time.Now()
 	for i := 0; i < 10; i++ {
 		cfg.AddQueryToHistory(QueryHistoryEntry{
-			Timestamp:    time.Now(),
+			Timestamp:    start.Add(time.Duration(i) * time.Second),
 			NaturalQuery: "Query " + string(rune('A'+i)),
 			Success:      true,
 		})
 	}
 
-	if len(cfg.QueryHistory) != 5 {
-		t.Errorf("expected 5 history entries, got %d", len(cfg.QueryHistory))
+	store, err := History()
+	if err != nil {
+		t.Fatalf("History error: %v", err)
+	}
+	entries, err := store.List(HistoryFilter{})
+	if err != nil {
+		t.Fatalf("List error: %v", err)
+	}
+	if len(entries) != 5 {
+		t.Errorf("expected 5 history entries, got %d", len(entries))
 	}
 
 	// Most recent should be first
-	if cfg.QueryHistory[0].NaturalQuery != "Query J" {
-		t.Errorf("expected most recent query first, got: %s", cfg.QueryHistory[0].NaturalQuery)
+	if entries[0].NaturalQuery != "Query J" {
+		t.Errorf("expected most recent query first, got: %s", entries[0].NaturalQuery)
 	}
 }
 
@@ -163,6 +186,204 @@ func TestIsSchemaCacheValid(t *testing.T) {
 	}
 }
 
+func TestServiceProfileOverridesSettings(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Settings.WriteModeEnabled = true
+
+	readOnly := false
+	cfg.SetProfile("prod", ServiceProfile{DefaultRowLimit: 20, WriteModeEnabled: &readOnly, LLMProvider: "ollama"})
+
+	prod := cfg.SettingsFor("prod")
+	if prod.DefaultRowLimit != 20 || prod.WriteModeEnabled || prod.LLMProvider != "ollama" {
+		t.Errorf("profile not applied: %+v", prod)
+	}
+
+	sandbox := cfg.SettingsFor("sandbox")
+	if sandbox.DefaultRowLimit != 50 || !sandbox.WriteModeEnabled || sandbox.LLMProvider != "rules" {
+		t.Errorf("service without profile should use global settings: %+v", sandbox)
+	}
+
+	cfg.SetProfile("prod", ServiceProfile{})
+	if _, ok := cfg.ServiceProfiles["prod"]; ok {
+		t.Error("empty profile should be removed")
+	}
+}
+
+func TestProfileGlossaryExtendsSettings(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Settings.Glossary = map[string]string{"client": "customer", "erf": "plot"}
+	cfg.SetProfile("cadastre", ServiceProfile{Glossary: map[string]string{"erf": "parcel"}})
+
+	got := cfg.SettingsFor("cadastre").Glossary
+	if len(got) != 2 || got["client"] != "customer" || got["erf"] != "parcel" {
+		t.Errorf("profile glossary not merged: %v", got)
+	}
+	if cfg.Settings.Glossary["erf"] != "plot" {
+		t.Error("merging must not change the global glossary")
+	}
+	if got := cfg.SettingsFor("other").Glossary; got["erf"] != "plot" {
+		t.Errorf("service without profile should use the global glossary: %v", got)
+	}
+}
+
+func TestStatementTimeout(t *testing.T) {
+	cfg := DefaultConfig()
+	if got := cfg.SettingsFor("any").StatementTimeout(); got != time.Minute {
+		t.Errorf("default timeout = %s, want 1m", got)
+	}
+
+	cfg.SetProfile("warehouse", ServiceProfile{StatementTimeoutSec: 900})
+	cfg.SetProfile("scratch", ServiceProfile{StatementTimeoutSec: -1})
+	if got := cfg.SettingsFor("warehouse").StatementTimeout(); got != 15*time.Minute {
+		t.Errorf("warehouse timeout = %s, want 15m", got)
+	}
+	if got := cfg.SettingsFor("scratch").StatementTimeout(); got != 0 {
+		t.Errorf("scratch timeout = %s, want no limit", got)
+	}
+}
+
+func TestMarkServiceUsed(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	cfg := DefaultConfig()
+	cfg.MarkServiceUsed("gis")
+	before := time.Now()
+	cfg.MarkServiceUsed("prod")
+	if cfg.ActiveService != "prod" {
+		t.Errorf("ActiveService = %q, want prod", cfg.ActiveService)
+	}
+	if err := cfg.Save(); err != nil {
+		t.Fatalf("failed to save config: %v", err)
+	}
+
+	loaded, err := Load()
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	if !loaded.LastUsed["prod"].After(loaded.LastUsed["gis"]) || loaded.LastUsed["prod"].Before(before) {
+		t.Errorf("LastUsed = %v, want prod used after gis", loaded.LastUsed)
+	}
+}
+
+func TestFilterSchemas(t *testing.T) {
+	cache := &SchemaCache{
+		Tables: []TableInfo{{Schema: "public", Name: "parcels"}, {Schema: "staging", Name: "parcels"}},
+		Views:  []ViewInfo{{Schema: "staging", Name: "recent"}},
+	}
+
+	filtered := cache.FilterSchemas([]string{"public"})
+	if len(filtered.Tables) != 1 || filtered.Tables[0].Schema != "public" || len(filtered.Views) != 0 {
+		t.Errorf("unexpected filtered schema: %+v", filtered)
+	}
+	if len(cache.Tables) != 2 {
+		t.Error("filtering must not modify the cached schema")
+	}
+	if cache.FilterSchemas(nil) != cache {
+		t.Error("an empty filter should keep every schema")
+	}
+}
+
+func TestWithoutPartitions(t *testing.T) {
+	cache := &SchemaCache{Tables: []TableInfo{
+		{Schema: "public", Name: "events", PartitionKey: "RANGE (created_at)"},
+		{Schema: "public", Name: "events_2024", PartitionOf: "public.events"},
+		{Schema: "public", Name: "orphan_2024", PartitionOf: "public.filtered_out"},
+	}}
+
+	pruned := cache.WithoutPartitions()
+	if len(pruned.Tables) != 2 || pruned.Tables[0].Name != "events" || pruned.Tables[1].Name != "orphan_2024" {
+		t.Errorf("unexpected tables: %+v", pruned.Tables)
+	}
+	if len(cache.Tables) != 3 {
+		t.Error("pruning must not modify the cached schema")
+	}
+
+	plain := &SchemaCache{Tables: []TableInfo{{Schema: "public", Name: "parcels"}}}
+	if plain.WithoutPartitions() != plain {
+		t.Error("a cache without partitions should be returned as is")
+	}
+}
+
+func TestReadable(t *testing.T) {
+	cache := &SchemaCache{Tables: []TableInfo{
+		{Schema: "public", Name: "parcels", Privileges: &TablePrivileges{Select: true}},
+		{Schema: "hr", Name: "salaries", Privileges: &TablePrivileges{Insert: true}},
+		{Schema: "public", Name: "roads"},
+	}}
+
+	readable := cache.Readable()
+	if len(readable.Tables) != 2 || readable.Tables[0].Name != "parcels" || readable.Tables[1].Name != "roads" {
+		t.Errorf("unexpected tables: %+v", readable.Tables)
+	}
+	if len(cache.Tables) != 3 {
+		t.Error("Readable must not modify the cached schema")
+	}
+
+	all := &SchemaCache{Tables: []TableInfo{{Schema: "public", Name: "parcels"}}}
+	if all.Readable() != all {
+		t.Error("a cache of readable tables should be returned as is")
+	}
+}
+
+func TestRowSecurity(t *testing.T) {
+	owned := TableInfo{Name: "orders", RowSecurity: &RowSecurity{}}
+	if owned.RowSecurityActive() {
+		t.Error("row security that does not apply to the role should not be active")
+	}
+	if (&TableInfo{Name: "roads"}).RowSecurityActive() {
+		t.Error("a table without row security should not report it")
+	}
+
+	rs := &RowSecurity{Active: true, Policies: []PolicyInfo{
+		{Name: "own_rows", Command: "ALL"},
+		{Name: "insert_own", Command: "INSERT"},
+		{Name: "managers", Command: "SELECT", Restrictive: true},
+	}}
+	if !(&TableInfo{Name: "orders", RowSecurity: rs}).RowSecurityActive() {
+		t.Error("expected row security to be active")
+	}
+	if got := rs.PolicyNames(); len(got) != 2 || got[0] != "own_rows" || got[1] != "managers" {
+		t.Errorf("PolicyNames() = %v, want the policies limiting reads", got)
+	}
+}
+
+func TestTableSizeSummary(t *testing.T) {
+	tests := []struct {
+		size     *TableSize
+		expected string
+	}{
+		{nil, ""},
+		{&TableSize{RowEstimate: 42, TotalBytes: 512}, "~42 rows, 512 B"},
+		{&TableSize{RowEstimate: 1234567, TotalBytes: 3 << 30}, "~1.2M rows, 3.0 GB"},
+		{&TableSize{RowEstimate: -1, TotalBytes: 8192}, "rows unknown, 8.0 kB"},
+	}
+	for _, tt := range tests {
+		if got := tt.size.Summary(); got != tt.expected {
+			t.Errorf("Summary() = %q, want %q", got, tt.expected)
+		}
+	}
+}
+
+func TestHumanDuration(t *testing.T) {
+	tests := []struct {
+		d        time.Duration
+		expected string
+	}{
+		{250 * time.Millisecond, "250ms"},
+		{12500 * time.Millisecond, "12.5s"},
+		{30 * time.Second, "30s"},
+		{3*time.Minute + 40*time.Second, "3m40s"},
+		{2*time.Hour + 30*time.Second, "2h"},
+		{50*time.Hour + 5*time.Minute, "2d2h"},
+		{-90 * time.Second, "-1m30s"},
+	}
+	for _, tt := range tests {
+		if got := HumanDuration(tt.d); got != tt.expected {
+			t.Errorf("HumanDuration(%s) = %q, want %q", tt.d, got, tt.expected)
+		}
+	}
+}
+
 func TestConfigPath(t *testing.T) {
 	path, err := ConfigPath()
 	if err != nil {
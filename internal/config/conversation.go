@@ -0,0 +1,183 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Limits on what is persisted per conversation, to keep the files small
+const (
+	MaxConversationTurns = 100 // Oldest turns are dropped beyond this
+	MaxConversationRows  = 50  // Result rows kept per turn
+)
+
+// DefaultSession is the name of the session every query screen starts with
+const DefaultSession = "main"
+
+// ConversationTurn is one persisted question/answer of a conversation
+type ConversationTurn struct {
+	Timestamp     time.Time         `json:"timestamp"`
+	Query         string            `json:"query"`
+	SQL           string            `json:"sql,omitempty"`
+	EditedSQL     string            `json:"edited_sql,omitempty"`
+	Params        map[string]string `json:"params,omitempty"` // Values bound to {{name}} placeholders
+	Error         string            `json:"error,omitempty"`
+	Columns       []string          `json:"columns,omitempty"`
+	ColumnTypes   []string          `json:"column_types,omitempty"` // Database type names, e.g. "JSONB"
+	Rows          [][]string        `json:"rows,omitempty"`         // First MaxConversationRows rows only
+	RowCount      int               `json:"row_count"`
+	RowsEstimated bool              `json:"rows_estimated,omitempty"` // RowCount is the planner's estimate
+	ExecutionTime float64           `json:"execution_time_ms"`
+	Mutating      bool              `json:"mutating,omitempty"`
+	Repairs       []FailedSQL       `json:"repairs,omitempty"` // Failed attempts before SQL, oldest first
+	Service       string            `json:"service,omitempty"` // Service answered on, when the question was asked on several
+}
+
+// FailedSQL is generated SQL that failed and the error it failed with
+type FailedSQL struct {
+	SQL   string `json:"sql"`
+	Error string `json:"error"`
+}
+
+// Conversation is the persisted conversation of one session of a service
+type Conversation struct {
+	ServiceName string             `json:"service_name"`
+	Session     string             `json:"session,omitempty"` // Empty means DefaultSession
+	UpdatedAt   time.Time          `json:"updated_at"`
+	Turns       []ConversationTurn `json:"turns"`
+}
+
+// unsafeFileChars matches characters not allowed in conversation file names
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+
+// ConversationsDir returns the directory holding saved conversations
+func ConversationsDir() (string, error) {
+	dir, err := ConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "conversations"), nil
+}
+
+// conversationPath returns the file path for a session's conversation. The
+// default session uses "<service>.json", others "<service>@<session>.json".
+func conversationPath(serviceName, session string) (string, error) {
+	dir, err := ConversationsDir()
+	if err != nil {
+		return "", err
+	}
+	name := unsafeFileChars.ReplaceAllString(serviceName, "_")
+	if session != "" && session != DefaultSession {
+		name += "@" + unsafeFileChars.ReplaceAllString(session, "_")
+	}
+	return filepath.Join(dir, name+".json"), nil
+}
+
+// SaveConversation writes a service's conversation, trimming it to the
+// persistence limits. An empty conversation removes the file.
+func SaveConversation(conv *Conversation) error {
+	path, err := conversationPath(conv.ServiceName, conv.Session)
+	if err != nil {
+		return err
+	}
+	if len(conv.Turns) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	trimmed := *conv
+	if len(trimmed.Turns) > MaxConversationTurns {
+		trimmed.Turns = trimmed.Turns[len(trimmed.Turns)-MaxConversationTurns:]
+	}
+	turns := make([]ConversationTurn, len(trimmed.Turns))
+	for i, turn := range trimmed.Turns {
+		if len(turn.Rows) > MaxConversationRows {
+			turn.Rows = turn.Rows[:MaxConversationRows]
+		}
+		turns[i] = turn
+	}
+	trimmed.Turns = turns
+	trimmed.UpdatedAt = time.Now()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(trimmed, "", "  ")
+	if err != nil {
+		return err
+	}
+	// Replaced whole so another instance never reads it half written
+	return writeFile(path, data, 0600)
+}
+
+// LoadConversation reads a session's saved conversation. It returns nil
+// without error when none has been saved.
+func LoadConversation(serviceName, session string) (*Conversation, error) {
+	path, err := conversationPath(serviceName, session)
+	if err != nil {
+		return nil, err
+	}
+	return readConversation(path)
+}
+
+// LoadConversations reads every saved session of a service, the default
+// session first and the others by name
+func LoadConversations(serviceName string) ([]*Conversation, error) {
+	dir, err := ConversationsDir()
+	if err != nil {
+		return nil, err
+	}
+	base := unsafeFileChars.ReplaceAllString(serviceName, "_")
+	paths, err := filepath.Glob(filepath.Join(dir, base+"@*.json"))
+	if err != nil {
+		return nil, err
+	}
+	paths = append([]string{filepath.Join(dir, base+".json")}, paths...)
+
+	var convs []*Conversation
+	for _, path := range paths {
+		conv, err := readConversation(path)
+		if err != nil {
+			return nil, err
+		}
+		// Sanitised names can collide; only keep this service's files
+		if conv != nil && conv.ServiceName == serviceName {
+			convs = append(convs, conv)
+		}
+	}
+
+	isDefault := func(c *Conversation) bool {
+		return c.Session == "" || c.Session == DefaultSession
+	}
+	sort.SliceStable(convs, func(i, j int) bool {
+		if isDefault(convs[i]) != isDefault(convs[j]) {
+			return isDefault(convs[i])
+		}
+		return strings.ToLower(convs[i].Session) < strings.ToLower(convs[j].Session)
+	})
+	return convs, nil
+}
+
+// readConversation reads a conversation file, returning nil if it does not exist
+func readConversation(path string) (*Conversation, error) {
+	data, err := readFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var conv Conversation
+	if err := json.Unmarshal(data, &conv); err != nil {
+		return nil, err
+	}
+	return &conv, nil
+}
@@ -0,0 +1,75 @@
+package config
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestSaveAndLoadConversation(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	conv := &Conversation{ServiceName: "prod/db 1"}
+	for i := 0; i < MaxConversationTurns+5; i++ {
+		conv.Turns = append(conv.Turns, ConversationTurn{Query: fmt.Sprintf("question %d", i)})
+	}
+	for i := 0; i < MaxConversationRows+10; i++ {
+		conv.Turns[len(conv.Turns)-1].Rows = append(conv.Turns[len(conv.Turns)-1].Rows, []string{"x"})
+	}
+
+	if err := SaveConversation(conv); err != nil {
+		t.Fatalf("SaveConversation error: %v", err)
+	}
+	if len(conv.Turns) != MaxConversationTurns+5 {
+		t.Error("SaveConversation should not modify the caller's conversation")
+	}
+
+	loaded, err := LoadConversation("prod/db 1", "")
+	if err != nil || loaded == nil {
+		t.Fatalf("LoadConversation = %v, %v", loaded, err)
+	}
+	if len(loaded.Turns) != MaxConversationTurns {
+		t.Errorf("expected %d turns, got %d", MaxConversationTurns, len(loaded.Turns))
+	}
+	if loaded.Turns[0].Query != "question 5" {
+		t.Errorf("expected oldest turns to be dropped, first is %q", loaded.Turns[0].Query)
+	}
+	if rows := len(loaded.Turns[len(loaded.Turns)-1].Rows); rows != MaxConversationRows {
+		t.Errorf("expected %d rows, got %d", MaxConversationRows, rows)
+	}
+
+	// Saving an empty conversation removes it
+	if err := SaveConversation(&Conversation{ServiceName: "prod/db 1"}); err != nil {
+		t.Fatalf("SaveConversation (empty) error: %v", err)
+	}
+	if loaded, err := LoadConversation("prod/db 1", ""); err != nil || loaded != nil {
+		t.Errorf("expected no conversation after clearing, got %v, %v", loaded, err)
+	}
+}
+
+func TestLoadConversationsListsSessions(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	for _, session := range []string{"spatial", DefaultSession, "Budget"} {
+		conv := &Conversation{ServiceName: "prod", Session: session, Turns: []ConversationTurn{{Query: session}}}
+		if err := SaveConversation(conv); err != nil {
+			t.Fatalf("SaveConversation(%s) error: %v", session, err)
+		}
+	}
+	// Another service must not leak into the list
+	if err := SaveConversation(&Conversation{ServiceName: "prod2", Turns: []ConversationTurn{{Query: "x"}}}); err != nil {
+		t.Fatalf("SaveConversation error: %v", err)
+	}
+
+	convs, err := LoadConversations("prod")
+	if err != nil {
+		t.Fatalf("LoadConversations error: %v", err)
+	}
+	var got []string
+	for _, c := range convs {
+		got = append(got, c.Turns[0].Query)
+	}
+	want := []string{DefaultSession, "Budget", "spatial"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("sessions = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,83 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Draft is the question left half written in a service's query editor
+type Draft struct {
+	ServiceName string    `json:"service_name"`
+	Text        string    `json:"text"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// DraftsDir returns the directory holding editor drafts
+func DraftsDir() (string, error) {
+	dir, err := ConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "drafts"), nil
+}
+
+// draftPath returns the file path of a service's draft
+func draftPath(serviceName string) (string, error) {
+	dir, err := DraftsDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, unsafeFileChars.ReplaceAllString(serviceName, "_")+".json"), nil
+}
+
+// SaveDraft writes the question being typed for a service, encrypted
+// when writes are. Empty text removes the draft.
+func SaveDraft(serviceName, text string) error {
+	path, err := draftPath(serviceName)
+	if err != nil {
+		return err
+	}
+	if text == "" {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(Draft{ServiceName: serviceName, Text: text, UpdatedAt: time.Now()}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFile(path, data, 0600)
+}
+
+// LoadDraft reads a service's draft. It returns "" without error when
+// none has been saved.
+func LoadDraft(serviceName string) (string, error) {
+	path, err := draftPath(serviceName)
+	if err != nil {
+		return "", err
+	}
+	data, err := readFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", err
+	}
+
+	var draft Draft
+	if err := json.Unmarshal(data, &draft); err != nil {
+		return "", err
+	}
+	// Sanitised names can collide; only another service's draft is there
+	if draft.ServiceName != serviceName {
+		return "", nil
+	}
+	return draft.Text, nil
+}
@@ -0,0 +1,34 @@
+package config
+
+import "testing"
+
+func TestSaveAndLoadDraft(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	if text, err := LoadDraft("prod/db"); err != nil || text != "" {
+		t.Fatalf("LoadDraft before saving = %q, %v", text, err)
+	}
+
+	if err := SaveDraft("prod/db", "How many roads are\nthere by "); err != nil {
+		t.Fatalf("SaveDraft error: %v", err)
+	}
+	if text, err := LoadDraft("prod/db"); err != nil || text != "How many roads are\nthere by " {
+		t.Errorf("LoadDraft = %q, %v", text, err)
+	}
+
+	// "prod_db" sanitises to the same file name but is another service
+	if text, err := LoadDraft("prod_db"); err != nil || text != "" {
+		t.Errorf("LoadDraft of a colliding service = %q, %v", text, err)
+	}
+
+	// Saving empty text removes the draft
+	if err := SaveDraft("prod/db", ""); err != nil {
+		t.Fatalf("SaveDraft (empty) error: %v", err)
+	}
+	if text, err := LoadDraft("prod/db"); err != nil || text != "" {
+		t.Errorf("LoadDraft after clearing = %q, %v", text, err)
+	}
+	if err := SaveDraft("prod/db", ""); err != nil {
+		t.Errorf("SaveDraft (empty) without a draft error: %v", err)
+	}
+}
@@ -0,0 +1,309 @@
+package config
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// Where the key encrypting files at rest comes from
+const (
+	KeySourceKeyring    = "keyring"
+	KeySourcePassphrase = "passphrase"
+)
+
+// encryptedMagic starts every encrypted file. It is followed by a byte
+// naming the key source ('k' keyring, 'p' passphrase), the GCM nonce and
+// the sealed data.
+const encryptedMagic = "PGAIENC1"
+
+// Errors unlocking encrypted files
+var (
+	ErrConfigLocked = errors.New("config is encrypted; unlock it with its key first")
+	ErrWrongKey     = errors.New("wrong encryption key or passphrase")
+)
+
+// encryption holds the key files at rest are read with and whether new
+// writes are encrypted
+var encryption struct {
+	sync.RWMutex
+	key     []byte // 32 bytes for AES-256; nil while locked
+	source  string
+	enabled bool // Writes are encrypted
+}
+
+// SetEncryptionKey sets the key encrypted files are read with, and where
+// it came from. A nil key locks them again.
+func SetEncryptionKey(key []byte, source string) {
+	encryption.Lock()
+	defer encryption.Unlock()
+	encryption.key = key
+	encryption.source = source
+	if key == nil {
+		encryption.enabled = false
+	}
+}
+
+// EncryptionActive reports whether files are written encrypted, and with
+// a key from which source
+func EncryptionActive() (bool, string) {
+	encryption.RLock()
+	defer encryption.RUnlock()
+	return encryption.enabled, encryption.source
+}
+
+// hasEncryptionKey reports whether a key is set
+func hasEncryptionKey() bool {
+	encryption.RLock()
+	defer encryption.RUnlock()
+	return encryption.key != nil
+}
+
+// setEncryptionEnabled turns encrypting writes on or off; it stays off
+// without a key
+func setEncryptionEnabled(on bool) {
+	encryption.Lock()
+	defer encryption.Unlock()
+	encryption.enabled = on && encryption.key != nil
+}
+
+// isEncrypted reports whether data is an encrypted file's contents
+func isEncrypted(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(encryptedMagic)) && len(data) > len(encryptedMagic)
+}
+
+// encryptedSource returns the key source recorded in encrypted data
+func encryptedSource(data []byte) string {
+	if data[len(encryptedMagic)] == 'p' {
+		return KeySourcePassphrase
+	}
+	return KeySourceKeyring
+}
+
+// seal encrypts data when writes are encrypted, else returns it as is
+func seal(data []byte) ([]byte, error) {
+	encryption.RLock()
+	key, source, enabled := encryption.key, encryption.source, encryption.enabled
+	encryption.RUnlock()
+	if !enabled {
+		return data, nil
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	tag := byte('k')
+	if source == KeySourcePassphrase {
+		tag = 'p'
+	}
+	out := append([]byte(encryptedMagic), tag)
+	out = append(out, nonce...)
+	return gcm.Seal(out, nonce, data, []byte(encryptedMagic)), nil
+}
+
+// open decrypts encrypted data; other data is returned as is, so files
+// written before encryption was turned on stay readable
+func open(data []byte) ([]byte, error) {
+	if !isEncrypted(data) {
+		return data, nil
+	}
+	encryption.RLock()
+	key := encryption.key
+	encryption.RUnlock()
+	if key == nil {
+		return nil, ErrConfigLocked
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	body := data[len(encryptedMagic)+1:]
+	if len(body) < gcm.NonceSize() {
+		return nil, fmt.Errorf("encrypted file is truncated")
+	}
+	plain, err := gcm.Open(nil, body[:gcm.NonceSize()], body[gcm.NonceSize():], []byte(encryptedMagic))
+	if err != nil {
+		return nil, ErrWrongKey
+	}
+	return plain, nil
+}
+
+// newGCM creates the AES-GCM cipher of a key
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// readFile reads a file that may be encrypted
+func readFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return open(data)
+}
+
+// writeFile replaces a file, encrypted when writes are
+func writeFile(path string, data []byte, perm os.FileMode) error {
+	sealed, err := seal(data)
+	if err != nil {
+		return err
+	}
+	return replaceFile(path, sealed, perm)
+}
+
+// saltPath is the file holding the salt passphrases are stretched with.
+// Losing it makes files encrypted with a passphrase unreadable.
+func saltPath() (string, error) {
+	dir, err := ConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "encryption.salt"), nil
+}
+
+// PassphraseKey derives an encryption key from a passphrase with scrypt,
+// creating the salt on first use
+func PassphraseKey(passphrase string) ([]byte, error) {
+	path, err := saltPath()
+	if err != nil {
+		return nil, err
+	}
+	salt, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		salt = make([]byte, 16)
+		if _, err := rand.Read(salt); err != nil {
+			return nil, err
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(path, salt, 0600); err != nil {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, err
+	}
+	return scrypt.Key([]byte(passphrase), salt, 1<<15, 8, 1, 32)
+}
+
+// Unlock prepares reading and writing encrypted files at startup. When
+// config.json is encrypted, or its settings ask for encryption, the key
+// is taken from the OS keyring or derived from the passphrase prompt
+// returns; prompt is told whether the passphrase is new, so it can be
+// asked for twice. A wrong passphrase returns ErrWrongKey.
+func Unlock(prompt func(isNew bool) (string, error)) error {
+	path, err := ConfigPath()
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	encrypted := isEncrypted(data)
+	var source string
+	if encrypted {
+		source = encryptedSource(data)
+	} else {
+		var cfg Config
+		if json.Unmarshal(data, &cfg) != nil || !cfg.Settings.EncryptAtRest {
+			return nil
+		}
+		source = cfg.Settings.EncryptionKeySource
+	}
+
+	if source != KeySourcePassphrase {
+		key, err := keyringKey(!encrypted)
+		if err == nil {
+			SetEncryptionKey(key, KeySourceKeyring)
+			return checkKey(data)
+		}
+		if encrypted {
+			return fmt.Errorf("reading the encryption key from the OS keyring: %w", err)
+		}
+		// Without a keyring, a new key comes from a passphrase instead
+	}
+
+	// Nothing encrypted can confirm a passphrase for a config still in the
+	// clear, even when a salt was made before, so it is new
+	passphrase, err := prompt(!encrypted)
+	if err != nil {
+		return err
+	}
+	key, err := PassphraseKey(passphrase)
+	if err != nil {
+		return err
+	}
+	SetEncryptionKey(key, KeySourcePassphrase)
+	return checkKey(data)
+}
+
+// checkKey makes sure the key set opens the config, locking it again if not
+func checkKey(data []byte) error {
+	if _, err := open(data); err != nil {
+		SetEncryptionKey(nil, "")
+		return err
+	}
+	return nil
+}
+
+// sealedFiles are the files besides config.json written with writeFile:
+// the directory holding them, their pattern and permissions
+var sealedFiles = []struct {
+	dir     func() (string, error)
+	pattern string
+	perm    os.FileMode
+}{
+	{GeometryImagesDir, "*.png", 0644},
+	{ConversationsDir, "*.json", 0600},
+	{SnapshotsDir, "*.json", 0600},
+	{DraftsDir, "*.json", 0600},
+}
+
+// rewriteSealedFiles rewrites the cached geometry images, conversations,
+// snapshots and drafts with the current encryption, after it was turned on
+// or off
+func rewriteSealedFiles() error {
+	for _, files := range sealedFiles {
+		dir, err := files.dir()
+		if err != nil {
+			return err
+		}
+		paths, err := filepath.Glob(filepath.Join(dir, files.pattern))
+		if err != nil {
+			return err
+		}
+		for _, path := range paths {
+			data, err := readFile(path)
+			if err != nil {
+				return fmt.Errorf("%s: %w", filepath.Base(path), err)
+			}
+			if err := writeFile(path, data, files.perm); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package config
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEncryptionAtRest(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Cleanup(func() { SetEncryptionKey(nil, "") })
+
+	png := []byte("\x89PNG fake image")
+	imageID, err := SaveGeometryImage(base64.StdEncoding.EncodeToString(png))
+	if err != nil {
+		t.Fatal(err)
+	}
+	imagePath, _ := GeometryImagePath(imageID)
+	configPath, _ := ConfigPath()
+	if err := SaveSnapshot(&Snapshot{Name: "secrets", ServiceName: "secret_db", SQL: "SELECT 1", Columns: []string{"n"}, Rows: [][]string{{"1"}}}); err != nil {
+		t.Fatal(err)
+	}
+	snapshotPath, _ := snapshotPath("secrets")
+
+	key, err := PassphraseKey("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetEncryptionKey(key, KeySourcePassphrase)
+	cfg := DefaultConfig()
+	cfg.ActiveService = "secret_db"
+	cfg.Settings.EncryptAtRest = true
+	cfg.Settings.EncryptionKeySource = KeySourcePassphrase
+	if err := cfg.Save(); err != nil {
+		t.Fatalf("Save error: %v", err)
+	}
+
+	// The config, and the image and snapshot saved before, are encrypted
+	if err := SaveConversation(&Conversation{ServiceName: "secret_db", Turns: []ConversationTurn{{Query: "secret question"}}}); err != nil {
+		t.Fatal(err)
+	}
+	conversationPath, _ := conversationPath("secret_db", "")
+	for _, path := range []string{configPath, imagePath, snapshotPath, conversationPath} {
+		data, _ := os.ReadFile(path)
+		if !bytes.HasPrefix(data, []byte(encryptedMagic)) || bytes.Contains(data, []byte("secret_db")) {
+			t.Errorf("%s is not encrypted", filepath.Base(path))
+		}
+	}
+	if loaded, err := LoadGeometryImage(imageID); err != nil || loaded != base64.StdEncoding.EncodeToString(png) {
+		t.Errorf("LoadGeometryImage = %q, %v", loaded, err)
+	}
+	if snap, err := LoadSnapshot("secrets"); err != nil || snap.ServiceName != "secret_db" {
+		t.Errorf("LoadSnapshot = %+v, %v", snap, err)
+	}
+	if conv, err := LoadConversation("secret_db", ""); err != nil || conv == nil || conv.Turns[0].Query != "secret question" {
+		t.Errorf("LoadConversation = %+v, %v", conv, err)
+	}
+
+	// Locked, the config cannot be read until unlocked with the passphrase
+	SetEncryptionKey(nil, "")
+	if _, err := Load(); !errors.Is(err, ErrConfigLocked) {
+		t.Errorf("expected ErrConfigLocked, got %v", err)
+	}
+	wrong := func(bool) (string, error) { return "wrong horse", nil }
+	if err := Unlock(wrong); !errors.Is(err, ErrWrongKey) {
+		t.Errorf("expected ErrWrongKey, got %v", err)
+	}
+	right := func(isNew bool) (string, error) {
+		if isNew {
+			t.Error("the passphrase of an encrypted config is not new")
+		}
+		return "correct horse", nil
+	}
+	if err := Unlock(right); err != nil {
+		t.Fatalf("Unlock error: %v", err)
+	}
+	loaded, err := Load()
+	if err != nil || loaded.ActiveService != "secret_db" {
+		t.Fatalf("Load = %+v, %v", loaded, err)
+	}
+	if active, source := EncryptionActive(); !active || source != KeySourcePassphrase {
+		t.Errorf("EncryptionActive = %v, %q", active, source)
+	}
+
+	// Turning encryption off writes everything in the clear again
+	loaded.Settings.EncryptAtRest = false
+	if err := loaded.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(imagePath); !bytes.Equal(data, png) {
+		t.Error("expected the image to be decrypted")
+	}
+	for _, path := range []string{snapshotPath, conversationPath} {
+		if data, _ := os.ReadFile(path); !bytes.Contains(data, []byte("secret_db")) {
+			t.Errorf("expected %s to be decrypted", filepath.Base(path))
+		}
+	}
+	if data, _ := os.ReadFile(configPath); !bytes.Contains(data, []byte("secret_db")) {
+		t.Error("expected the config to be decrypted")
+	}
+}
+
+func TestUnlockPlainConfig(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Cleanup(func() { SetEncryptionKey(nil, "") })
+
+	// Nothing to unlock without encryption
+	if err := DefaultConfig().Save(); err != nil {
+		t.Fatal(err)
+	}
+	prompted := false
+	prompt := func(isNew bool) (string, error) {
+		prompted = true
+		if !isNew {
+			t.Error("expected a new passphrase")
+		}
+		return "pass", nil
+	}
+	if err := Unlock(prompt); err != nil || prompted {
+		t.Errorf("Unlock = %v, prompted %v", err, prompted)
+	}
+
+	// Asked for in the settings, a new passphrase makes the key, even
+	// with a salt left from an earlier passphrase
+	if _, err := PassphraseKey("earlier"); err != nil {
+		t.Fatal(err)
+	}
+	cfg := DefaultConfig()
+	cfg.Settings.EncryptAtRest = true
+	cfg.Settings.EncryptionKeySource = KeySourcePassphrase
+	if err := cfg.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if err := Unlock(prompt); err != nil || !prompted {
+		t.Fatalf("Unlock = %v, prompted %v", err, prompted)
+	}
+	loaded, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := loaded.Save(); err != nil {
+		t.Fatal(err)
+	}
+	path, _ := ConfigPath()
+	if data, _ := os.ReadFile(path); !isEncrypted(data) {
+		t.Error("expected the config to be encrypted once unlocked")
+	}
+}
@@ -0,0 +1,439 @@
+package config
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	_ "modernc.org/sqlite" // Pure Go SQLite driver
+)
+
+// historySchemaVersion is stored in PRAGMA user_version; bump it with a
+// step in historyMigrations when the schema changes
+const historySchemaVersion = 3
+
+// historySchema creates the version 1 schema. Questions and SQL are indexed
+// with FTS5 so searching stays fast however long the history grows.
+const historySchema = `
+CREATE TABLE IF NOT EXISTS query_history (
+	id                INTEGER PRIMARY KEY AUTOINCREMENT,
+	timestamp         INTEGER NOT NULL, -- Unix nanoseconds
+	service_name      TEXT    NOT NULL,
+	natural_query     TEXT    NOT NULL,
+	generated_sql     TEXT    NOT NULL DEFAULT '',
+	edited_sql        TEXT    NOT NULL DEFAULT '',
+	rows_affected     INTEGER NOT NULL DEFAULT 0,
+	execution_time_ms REAL    NOT NULL DEFAULT 0,
+	success           INTEGER NOT NULL DEFAULT 1,
+	error_message     TEXT    NOT NULL DEFAULT '',
+	has_geometry      INTEGER NOT NULL DEFAULT 0,
+	geometry_image_id TEXT    NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS query_history_service_time ON query_history (service_name, timestamp DESC);
+CREATE INDEX IF NOT EXISTS query_history_time ON query_history (timestamp DESC);
+
+CREATE TABLE IF NOT EXISTS geometry_images (
+	id         TEXT PRIMARY KEY, -- File name without .png in the geometry images directory
+	bytes      INTEGER NOT NULL,
+	created_at INTEGER NOT NULL
+);
+
+CREATE VIRTUAL TABLE IF NOT EXISTS query_history_fts USING fts5 (
+	natural_query, generated_sql, edited_sql,
+	content = 'query_history', content_rowid = 'id'
+);
+CREATE TRIGGER IF NOT EXISTS query_history_ai AFTER INSERT ON query_history BEGIN
+	INSERT INTO query_history_fts (rowid, natural_query, generated_sql, edited_sql)
+	VALUES (new.id, new.natural_query, new.generated_sql, new.edited_sql);
+END;
+CREATE TRIGGER IF NOT EXISTS query_history_ad AFTER DELETE ON query_history BEGIN
+	INSERT INTO query_history_fts (query_history_fts, rowid, natural_query, generated_sql, edited_sql)
+	VALUES ('delete', old.id, old.natural_query, old.generated_sql, old.edited_sql);
+END;
+`
+
+// historyMigrations bring a database from the version of their index + 1
+// to the next one
+var historyMigrations = []string{
+	`ALTER TABLE query_history ADD COLUMN generator TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE query_history ADD COLUMN input_tokens INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE query_history ADD COLUMN output_tokens INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE query_history ADD COLUMN cost REAL NOT NULL DEFAULT 0;
+	` + usageSchema,
+}
+
+// historyColumns lists the columns read into a QueryHistoryEntry, in scan order
+const historyColumns = `h.id, h.timestamp, h.service_name, h.natural_query, h.generated_sql, h.edited_sql,
+	h.rows_affected, h.execution_time_ms, h.success, h.error_message, h.has_geometry, h.geometry_image_id, h.generator,
+	h.input_tokens, h.output_tokens, h.cost`
+
+// HistoryStore keeps the query history in an SQLite database, so adding a
+// query no longer rewrites config.json
+type HistoryStore struct {
+	db       *sql.DB
+	path     string
+	imageDir string // Geometry images referenced by the history
+}
+
+// HistoryFilter selects history entries; zero fields match everything
+type HistoryFilter struct {
+	ServiceName string
+	Search      string // Words that must all prefix-match the question or SQL
+	Limit       int
+}
+
+// sharedHistory is the process-wide store opened by History
+var (
+	sharedHistoryMu sync.Mutex
+	sharedHistory   *HistoryStore
+)
+
+// HistoryPath returns the history database path
+func HistoryPath() (string, error) {
+	dir, err := ConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "history.db"), nil
+}
+
+// History returns the shared history store, opening it on first use
+func History() (*HistoryStore, error) {
+	path, err := HistoryPath()
+	if err != nil {
+		return nil, err
+	}
+
+	sharedHistoryMu.Lock()
+	defer sharedHistoryMu.Unlock()
+
+	if sharedHistory != nil && sharedHistory.path == path {
+		return sharedHistory, nil
+	}
+	if sharedHistory != nil {
+		sharedHistory.Close()
+	}
+	h, err := OpenHistory(path)
+	if err != nil {
+		return nil, err
+	}
+	if dir, err := GeometryImagesDir(); err == nil {
+		h.imageDir = dir
+	}
+	sharedHistory = h
+	return h, nil
+}
+
+// CloseHistory closes the shared history store
+func CloseHistory() {
+	sharedHistoryMu.Lock()
+	defer sharedHistoryMu.Unlock()
+
+	if sharedHistory != nil {
+		sharedHistory.Close()
+		sharedHistory = nil
+	}
+}
+
+// OpenHistory opens (creating if needed) a history database. Geometry images
+// are expected in the geometry_images directory next to it.
+func OpenHistory(path string) (*HistoryStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
+	if err != nil {
+		return nil, err
+	}
+	// SQLite allows one writer; a single connection avoids SQLITE_BUSY between our own goroutines
+	db.SetMaxOpenConns(1)
+
+	if err := migrateHistorySchema(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("history database %s: %w", path, err)
+	}
+	return &HistoryStore{
+		db:       db,
+		path:     path,
+		imageDir: filepath.Join(filepath.Dir(path), "geometry_images"),
+	}, nil
+}
+
+// migrateHistorySchema brings the database up to historySchemaVersion
+func migrateHistorySchema(db *sql.DB) error {
+	var version int
+	if err := db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return err
+	}
+	if version > historySchemaVersion {
+		return fmt.Errorf("schema version %d is newer than this program supports", version)
+	}
+	if version == historySchemaVersion {
+		return nil
+	}
+	if version == 0 {
+		if _, err := db.Exec(historySchema); err != nil {
+			return err
+		}
+		version = 1
+	}
+	for ; version < historySchemaVersion; version++ {
+		if _, err := db.Exec(historyMigrations[version-1]); err != nil {
+			return fmt.Errorf("migrating history to version %d: %w", version+1, err)
+		}
+	}
+	_, err := db.Exec(fmt.Sprintf("PRAGMA user_version = %d", historySchemaVersion))
+	return err
+}
+
+// Close closes the database
+func (h *HistoryStore) Close() error {
+	return h.db.Close()
+}
+
+// Add records a query and trims the history to the newest maxSize entries
+// (no trimming when maxSize <= 0). It returns the new entry's ID.
+func (h *HistoryStore) Add(entry QueryHistoryEntry, maxSize int) (int64, error) {
+	tx, err := h.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	id, err := h.insert(tx, entry)
+	if err != nil {
+		return 0, err
+	}
+	if maxSize > 0 {
+		_, err = tx.Exec(`DELETE FROM query_history WHERE id NOT IN (
+			SELECT id FROM query_history ORDER BY timestamp DESC, id DESC LIMIT ?)`, maxSize)
+		if err != nil {
+			return 0, err
+		}
+	}
+	orphans, err := h.pruneImages(tx)
+	if err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	h.removeImageFiles(orphans)
+	return id, nil
+}
+
+// insert writes one entry and registers its geometry image
+func (h *HistoryStore) insert(tx *sql.Tx, e QueryHistoryEntry) (int64, error) {
+	if e.Timestamp.IsZero() {
+		e.Timestamp = time.Now()
+	}
+	res, err := tx.Exec(`INSERT INTO query_history (timestamp, service_name, natural_query, generated_sql,
+		edited_sql, rows_affected, execution_time_ms, success, error_message, has_geometry, geometry_image_id, generator,
+		input_tokens, output_tokens, cost)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		e.Timestamp.UnixNano(), e.ServiceName, e.NaturalQuery, e.GeneratedSQL, e.EditedSQL,
+		e.RowsAffected, e.ExecutionTime, e.Success, e.ErrorMessage, e.HasGeometry, e.GeometryImageID, e.Generator,
+		e.InputTokens, e.OutputTokens, e.Cost)
+	if err != nil {
+		return 0, err
+	}
+
+	if e.GeometryImageID != "" {
+		var size int64
+		if info, err := os.Stat(filepath.Join(h.imageDir, e.GeometryImageID+".png")); err == nil {
+			size = info.Size()
+		}
+		_, err = tx.Exec(`INSERT OR IGNORE INTO geometry_images (id, bytes, created_at) VALUES (?, ?, ?)`,
+			e.GeometryImageID, size, e.Timestamp.UnixNano())
+		if err != nil {
+			return 0, err
+		}
+	}
+	return res.LastInsertId()
+}
+
+// pruneImages forgets geometry images no longer referenced by any entry and
+// returns their IDs so the files can be removed after commit
+func (h *HistoryStore) pruneImages(tx *sql.Tx) ([]string, error) {
+	rows, err := tx.Query(`DELETE FROM geometry_images
+		WHERE id NOT IN (SELECT geometry_image_id FROM query_history) RETURNING id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// removeImageFiles deletes pruned geometry images from disk
+func (h *HistoryStore) removeImageFiles(ids []string) {
+	for _, id := range ids {
+		os.Remove(filepath.Join(h.imageDir, id+".png"))
+	}
+}
+
+// Import adds entries in one transaction without trimming, keeping their
+// timestamps. It returns the number of entries added.
+func (h *HistoryStore) Import(entries []QueryHistoryEntry) (int, error) {
+	tx, err := h.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	for _, e := range entries {
+		if _, err := h.insert(tx, e); err != nil {
+			return 0, err
+		}
+	}
+	return len(entries), tx.Commit()
+}
+
+// List returns matching entries, newest first
+func (h *HistoryStore) List(filter HistoryFilter) ([]QueryHistoryEntry, error) {
+	query := "SELECT " + historyColumns + " FROM query_history h"
+	var where []string
+	var args []any
+	if match := ftsMatchQuery(filter.Search); match != "" {
+		query += " JOIN query_history_fts f ON f.rowid = h.id"
+		where = append(where, "query_history_fts MATCH ?")
+		args = append(args, match)
+	}
+	if filter.ServiceName != "" {
+		where = append(where, "h.service_name = ?")
+		args = append(args, filter.ServiceName)
+	}
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY h.timestamp DESC, h.id DESC"
+	if filter.Limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", filter.Limit)
+	}
+
+	rows, err := h.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []QueryHistoryEntry
+	for rows.Next() {
+		var e QueryHistoryEntry
+		var ts int64
+		if err := rows.Scan(&e.ID, &ts, &e.ServiceName, &e.NaturalQuery, &e.GeneratedSQL, &e.EditedSQL,
+			&e.RowsAffected, &e.ExecutionTime, &e.Success, &e.ErrorMessage, &e.HasGeometry, &e.GeometryImageID, &e.Generator,
+			&e.InputTokens, &e.OutputTokens, &e.Cost); err != nil {
+			return nil, err
+		}
+		e.Timestamp = time.Unix(0, ts)
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// ftsMatchQuery turns free text into an FTS5 query requiring every word as
+// a prefix. Words are quoted so punctuation cannot form FTS5 syntax.
+func ftsMatchQuery(text string) string {
+	var terms []string
+	for _, word := range strings.Fields(text) {
+		terms = append(terms, `"`+strings.ReplaceAll(word, `"`, `""`)+`"*`)
+	}
+	return strings.Join(terms, " ")
+}
+
+// Delete removes an entry, and its geometry image if no other entry uses it
+func (h *HistoryStore) Delete(id int64) error {
+	tx, err := h.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM query_history WHERE id = ?", id); err != nil {
+		return err
+	}
+	orphans, err := h.pruneImages(tx)
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	h.removeImageFiles(orphans)
+	return nil
+}
+
+// Count returns the number of entries for a service, or for all services
+// when serviceName is empty
+func (h *HistoryStore) Count(serviceName string) (int, error) {
+	var n int
+	var err error
+	if serviceName == "" {
+		err = h.db.QueryRow("SELECT COUNT(*) FROM query_history").Scan(&n)
+	} else {
+		err = h.db.QueryRow("SELECT COUNT(*) FROM query_history WHERE service_name = ?", serviceName).Scan(&n)
+	}
+	return n, err
+}
+
+// AddQueryToHistory records a query in the shared history store, trimmed to
+// MaxHistorySize entries
+func (c *Config) AddQueryToHistory(entry QueryHistoryEntry) error {
+	h, err := History()
+	if err != nil {
+		return err
+	}
+	_, err = h.Add(entry, c.Settings.MaxHistorySize)
+	return err
+}
+
+// migrateJSONHistory moves history kept in config.json by earlier versions
+// into the history database. The JSON copy is dropped only once imported.
+func (c *Config) migrateJSONHistory() error {
+	if len(c.QueryHistory) == 0 {
+		return nil
+	}
+	// Instances started together must not both import it
+	unlock, err := lockConfig()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	h, err := History()
+	if err != nil {
+		return err
+	}
+
+	// A non-empty store means an earlier migration was imported but the
+	// config could not be saved; importing again would duplicate entries
+	n, err := h.Count("")
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		// config.json kept the newest entry first; import oldest first so IDs follow time
+		entries := make([]QueryHistoryEntry, len(c.QueryHistory))
+		for i, e := range c.QueryHistory {
+			entries[len(entries)-1-i] = e
+		}
+		if _, err := h.Import(entries); err != nil {
+			return err
+		}
+	}
+	c.QueryHistory = []QueryHistoryEntry{}
+	return c.save()
+}
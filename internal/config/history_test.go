@@ -0,0 +1,187 @@
+package config
+
+import (
+	"database/sql"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestHistorySearch(t *testing.T) {
+	store, err := OpenHistory(filepath.Join(t.TempDir(), "history.db"))
+	if err != nil {
+		t.Fatalf("OpenHistory error: %v", err)
+	}
+	defer store.Close()
+
+	start := time.Now()
+	for i, e := range []QueryHistoryEntry{
+		{ServiceName: "prod", NaturalQuery: "How many parcels per district?", GeneratedSQL: "SELECT district, COUNT(*) FROM parcels GROUP BY 1"},
+		{ServiceName: "prod", NaturalQuery: "List roads", GeneratedSQL: "SELECT * FROM roads"},
+		{ServiceName: "dev", NaturalQuery: "Parcels without owners", GeneratedSQL: "SELECT * FROM parcels WHERE owner IS NULL"},
+	} {
+		e.Timestamp = start.Add(time.Duration(i) * time.Second)
+		if _, err := store.Add(e, 0); err != nil {
+			t.Fatalf("Add error: %v", err)
+		}
+	}
+
+	tests := []struct {
+		filter HistoryFilter
+		want   []string
+	}{
+		{HistoryFilter{Search: "parcel"}, []string{"Parcels without owners", "How many parcels per district?"}},
+		{HistoryFilter{ServiceName: "prod", Search: "parcel"}, []string{"How many parcels per district?"}},
+		{HistoryFilter{Search: "roads"}, []string{"List roads"}},
+		{HistoryFilter{Search: "owner IS"}, []string{"Parcels without owners"}},
+		{HistoryFilter{Search: `"unbalanced ("`}, nil},
+		{HistoryFilter{ServiceName: "prod", Limit: 1}, []string{"List roads"}},
+	}
+	for _, tt := range tests {
+		entries, err := store.List(tt.filter)
+		if err != nil {
+			t.Errorf("List(%+v) error: %v", tt.filter, err)
+			continue
+		}
+		var got []string
+		for _, e := range entries {
+			got = append(got, e.NaturalQuery)
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("List(%+v) = %q, want %q", tt.filter, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("List(%+v) = %q, want %q", tt.filter, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestHistoryMigratesVersion1(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.db")
+	db, err := sql.Open("sqlite", "file:"+path)
+	if err != nil {
+		t.Fatalf("open error: %v", err)
+	}
+	for _, stmt := range []string{historySchema, "PRAGMA user_version = 1",
+		`INSERT INTO query_history (timestamp, service_name, natural_query) VALUES (1, 'prod', 'List roads')`} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("creating version 1 database: %v", err)
+		}
+	}
+	db.Close()
+
+	store, err := OpenHistory(path)
+	if err != nil {
+		t.Fatalf("OpenHistory error: %v", err)
+	}
+	defer store.Close()
+	if _, err := store.Add(QueryHistoryEntry{ServiceName: "prod", NaturalQuery: "Count roads", Generator: "ollama/llama3"}, 0); err != nil {
+		t.Fatalf("Add error: %v", err)
+	}
+	entries, err := store.List(HistoryFilter{})
+	if err != nil || len(entries) != 2 {
+		t.Fatalf("List = %+v, %v", entries, err)
+	}
+	if entries[0].Generator != "ollama/llama3" || entries[1].Generator != "" {
+		t.Errorf("generators = %q, %q", entries[0].Generator, entries[1].Generator)
+	}
+}
+
+func TestHistoryDeleteRemovesOrphanedImage(t *testing.T) {
+	dir := t.TempDir()
+	store, err := OpenHistory(filepath.Join(dir, "history.db"))
+	if err != nil {
+		t.Fatalf("OpenHistory error: %v", err)
+	}
+	defer store.Close()
+
+	imagePath := filepath.Join(dir, "geometry_images", "abc.png")
+	if err := os.MkdirAll(filepath.Dir(imagePath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(imagePath, []byte("png"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := store.Add(QueryHistoryEntry{NaturalQuery: "a", HasGeometry: true, GeometryImageID: "abc"}, 0)
+	if err != nil {
+		t.Fatalf("Add error: %v", err)
+	}
+	second, err := store.Add(QueryHistoryEntry{NaturalQuery: "b", HasGeometry: true, GeometryImageID: "abc"}, 0)
+	if err != nil {
+		t.Fatalf("Add error: %v", err)
+	}
+
+	if err := store.Delete(first); err != nil {
+		t.Fatalf("Delete error: %v", err)
+	}
+	if _, err := os.Stat(imagePath); err != nil {
+		t.Errorf("image still used by another entry was removed: %v", err)
+	}
+	if err := store.Delete(second); err != nil {
+		t.Fatalf("Delete error: %v", err)
+	}
+	if _, err := os.Stat(imagePath); !os.IsNotExist(err) {
+		t.Errorf("expected orphaned image to be removed, stat error: %v", err)
+	}
+	if n, _ := store.Count(""); n != 0 {
+		t.Errorf("expected empty history, got %d entries", n)
+	}
+}
+
+func TestLoadMigratesJSONHistory(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	defer CloseHistory()
+
+	// config.json as written by earlier versions, newest entry first
+	legacy := DefaultConfig()
+	now := time.Now()
+	legacy.QueryHistory = []QueryHistoryEntry{
+		{Timestamp: now, NaturalQuery: "newest", ServiceName: "prod", Success: true},
+		{Timestamp: now.Add(-time.Hour), NaturalQuery: "oldest", ServiceName: "prod", Success: true},
+	}
+	if err := legacy.Save(); err != nil {
+		t.Fatalf("Save error: %v", err)
+	}
+
+	for run := 0; run < 2; run++ {
+		cfg, err := Load()
+		if err != nil {
+			t.Fatalf("Load error: %v", err)
+		}
+		if len(cfg.QueryHistory) != 0 {
+			t.Errorf("expected JSON history to be cleared, got %d entries", len(cfg.QueryHistory))
+		}
+	}
+
+	path, _ := ConfigPath()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := raw["query_history"]; ok {
+		t.Error("config.json should no longer contain query_history")
+	}
+
+	store, err := History()
+	if err != nil {
+		t.Fatalf("History error: %v", err)
+	}
+	entries, err := store.List(HistoryFilter{ServiceName: "prod"})
+	if err != nil {
+		t.Fatalf("List error: %v", err)
+	}
+	if len(entries) != 2 || entries[0].NaturalQuery != "newest" || entries[1].NaturalQuery != "oldest" {
+		t.Errorf("unexpected migrated history: %+v", entries)
+	}
+}
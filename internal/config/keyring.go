@@ -0,0 +1,90 @@
+package config
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// Names the encryption key is stored under in the OS keyring
+const (
+	keyringService = "kartoza-pg-ai"
+	keyringAccount = "encryption-key"
+)
+
+// errNoKeyring is returned where no supported keyring tool is installed
+var errNoKeyring = errors.New("no OS keyring available (needs secret-tool on Linux or security on macOS)")
+
+// keyringKey reads the encryption key from the OS keyring, through
+// libsecret's secret-tool on Linux and the security tool on macOS. When
+// none is stored and create is set, a random key is made and stored.
+func keyringKey(create bool) ([]byte, error) {
+	encoded, err := keyringLookup()
+	if err == nil && encoded != "" {
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(key) != 32 {
+			return nil, fmt.Errorf("the keyring holds an invalid encryption key")
+		}
+		return key, nil
+	}
+	if errors.Is(err, errNoKeyring) || !create {
+		if err == nil {
+			err = fmt.Errorf("no encryption key in the keyring")
+		}
+		return nil, err
+	}
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	if err := keyringStore(base64.StdEncoding.EncodeToString(key)); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// keyringLookup returns the stored key, or "" with an error when there is none
+func keyringLookup() (string, error) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "linux", "freebsd", "openbsd":
+		cmd = exec.Command("secret-tool", "lookup", "service", keyringService, "account", keyringAccount)
+	case "darwin":
+		cmd = exec.Command("security", "find-generic-password", "-s", keyringService, "-a", keyringAccount, "-w")
+	default:
+		return "", errNoKeyring
+	}
+	if _, err := exec.LookPath(cmd.Path); err != nil {
+		return "", errNoKeyring
+	}
+	out, err := cmd.Output()
+	return strings.TrimSpace(string(out)), err
+}
+
+// keyringStore stores the encoded key in the keyring
+func keyringStore(encoded string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "linux", "freebsd", "openbsd":
+		// The secret is read from stdin, keeping it out of the process list
+		cmd = exec.Command("secret-tool", "store", "--label=Kartoza PG AI encryption key",
+			"service", keyringService, "account", keyringAccount)
+		cmd.Stdin = strings.NewReader(encoded)
+	case "darwin":
+		cmd = exec.Command("security", "add-generic-password", "-U", "-s", keyringService, "-a", keyringAccount, "-w", encoded)
+	default:
+		return errNoKeyring
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("storing the encryption key in the keyring: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
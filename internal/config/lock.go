@@ -0,0 +1,143 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+)
+
+// configMu serialises saves within this process; the lock file does the
+// same between processes
+var configMu sync.Mutex
+
+// lockPath returns the file other running instances are locked out with
+// while one writes the config
+func lockPath() (string, error) {
+	dir, err := ConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "config.lock"), nil
+}
+
+// lockConfig waits until no other instance writes the config and keeps
+// them out until the returned function is called
+func lockConfig() (func(), error) {
+	path, err := lockPath()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+
+	configMu.Lock()
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		configMu.Unlock()
+		return nil, err
+	}
+	if err := lockFile(f); err != nil {
+		f.Close()
+		configMu.Unlock()
+		return nil, err
+	}
+	return func() {
+		unlockFile(f)
+		f.Close()
+		configMu.Unlock()
+	}, nil
+}
+
+// replaceFile writes a file aside and renames it over path, so readers
+// never see it half written
+func replaceFile(path string, data []byte, perm os.FileMode) error {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp, perm)
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
+
+// merge combines the config this instance would write with the one another
+// instance saved since it was read. Values changed here win; the others
+// keep what was saved there. c takes the merged values, and the merged
+// file's contents are returned.
+func (c *Config) merge(mine, theirs []byte) ([]byte, error) {
+	var base, m, t any
+	if json.Unmarshal(c.base, &base) != nil {
+		base = map[string]any{}
+	}
+	if err := json.Unmarshal(mine, &m); err != nil {
+		return nil, err
+	}
+	if json.Unmarshal(theirs, &t) != nil {
+		// An unreadable file is overwritten, as before merging
+		return mine, nil
+	}
+	merged, err := json.Marshal(mergeJSON(base, m, t))
+	if err != nil {
+		return nil, err
+	}
+
+	fresh := DefaultConfig()
+	if err := json.Unmarshal(merged, fresh); err != nil {
+		return nil, err
+	}
+	fresh.CachedSchemas, fresh.base, fresh.baseSchemas = c.CachedSchemas, c.base, c.baseSchemas
+	*c = *fresh
+
+	saved := *c
+	saved.CachedSchemas = nil
+	return json.MarshalIndent(&saved, "", "  ")
+}
+
+// mergeJSON merges two decoded JSON values changed from base. Objects are
+// merged key by key; for anything else the value changed in mine wins.
+func mergeJSON(base, mine, theirs any) any {
+	m, mineIsObject := mine.(map[string]any)
+	t, theirsIsObject := theirs.(map[string]any)
+	if !mineIsObject || !theirsIsObject {
+		if reflect.DeepEqual(base, mine) {
+			return theirs
+		}
+		return mine
+	}
+
+	b, _ := base.(map[string]any)
+	out := make(map[string]any, len(t))
+	for key, tv := range t {
+		if mv, ok := m[key]; ok {
+			out[key] = mergeJSON(b[key], mv, tv)
+		} else if _, known := b[key]; !known {
+			// Added there
+			out[key] = tv
+		}
+	}
+	for key, mv := range m {
+		if _, ok := t[key]; ok {
+			continue
+		}
+		if bv, known := b[key]; !known || !reflect.DeepEqual(bv, mv) {
+			// Added or changed here; otherwise it was removed there
+			out[key] = mv
+		}
+	}
+	return out
+}
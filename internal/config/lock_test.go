@@ -0,0 +1,113 @@
+package config
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSaveKeepsChangesOfOtherInstances(t *testing.T) {
+	cacheTestHome(t)
+	if err := DefaultConfig().Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Two instances started from the same config
+	a, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a.Settings.DefaultRowLimit = 500
+	a.SetTemplateParams(map[string]string{"city": "Cape Town"})
+	a.CachedSchemas["prod"] = &SchemaCache{ServiceName: "prod", CachedAt: time.Now()}
+	if err := a.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	b.ActiveService = "dev"
+	b.SetTemplateParams(map[string]string{"year": "2024"})
+	b.CachedSchemas["dev"] = &SchemaCache{ServiceName: "dev", CachedAt: time.Now()}
+	if err := b.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if b.Settings.DefaultRowLimit != 500 || b.CachedSchemas["prod"] == nil {
+		t.Errorf("saving should pick up the other instance's changes, got %+v", b.Settings)
+	}
+
+	loaded, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.ActiveService != "dev" || loaded.Settings.DefaultRowLimit != 500 {
+		t.Errorf("lost a change: active %q, row limit %d", loaded.ActiveService, loaded.Settings.DefaultRowLimit)
+	}
+	if loaded.TemplateParams["city"] != "Cape Town" || loaded.TemplateParams["year"] != "2024" {
+		t.Errorf("template params not merged: %v", loaded.TemplateParams)
+	}
+	if len(loaded.CachedSchemas) != 2 {
+		t.Errorf("expected both harvested schemas, got %v", loaded.CachedSchemas)
+	}
+
+	// A schema dropped by one instance is not brought back by the other
+	delete(loaded.CachedSchemas, "prod")
+	if err := loaded.Save(); err != nil {
+		t.Fatal(err)
+	}
+	a.Settings.VimModeEnabled = true
+	if err := a.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if final, _ := Load(); final.CachedSchemas["prod"] != nil || !final.Settings.VimModeEnabled {
+		t.Errorf("unexpected final config: schemas %v, vim %v", final.CachedSchemas, final.Settings.VimModeEnabled)
+	}
+}
+
+func TestMergeJSON(t *testing.T) {
+	base := map[string]any{"a": 1.0, "b": 1.0, "c": 1.0, "gone": 1.0}
+	mine := map[string]any{"a": 2.0, "b": 1.0, "c": 2.0, "gone": 1.0, "new": 1.0}
+	theirs := map[string]any{"a": 1.0, "b": 3.0, "c": 3.0, "other": 1.0}
+
+	got := mergeJSON(base, mine, theirs)
+	want := map[string]any{"a": 2.0, "b": 3.0, "c": 2.0, "new": 1.0, "other": 1.0}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("mergeJSON = %v, want %v", got, want)
+	}
+}
+
+func TestConcurrentSaves(t *testing.T) {
+	cacheTestHome(t)
+	if err := DefaultConfig().Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		cfg, err := Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			cfg.SetTemplateParams(map[string]string{fmt.Sprintf("p%d", i): "x"})
+			if err := cfg.Save(); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	loaded, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded.TemplateParams) != 8 {
+		t.Errorf("expected every instance's parameter, got %v", loaded.TemplateParams)
+	}
+}
@@ -0,0 +1,124 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// snapshotNamePattern matches valid snapshot names, which are also their
+// file names
+var snapshotNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// Snapshot is every row of a result saved under a name, so later runs of
+// its SQL can be compared against it
+type Snapshot struct {
+	Name        string            `json:"name"`
+	ServiceName string            `json:"service_name"`
+	Query       string            `json:"query,omitempty"` // Question the SQL answered
+	SQL         string            `json:"sql"`
+	Params      map[string]string `json:"params,omitempty"` // Values bound to {{name}} placeholders
+	Key         []string          `json:"key,omitempty"`    // Columns rows are matched by; empty matches whole rows
+	Columns     []string          `json:"columns"`
+	Rows        [][]string        `json:"rows"`
+	CreatedAt   time.Time         `json:"created_at"`
+}
+
+// SnapshotsDir returns the directory holding saved snapshots
+func SnapshotsDir() (string, error) {
+	dir, err := ConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "snapshots"), nil
+}
+
+// snapshotPath returns the file path of a named snapshot
+func snapshotPath(name string) (string, error) {
+	if !snapshotNamePattern.MatchString(name) {
+		return "", fmt.Errorf("invalid snapshot name %q: use letters, digits, '.', '_' and '-'", name)
+	}
+	dir, err := SnapshotsDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name+".json"), nil
+}
+
+// SaveSnapshot writes a snapshot, replacing any of the same name
+func SaveSnapshot(snap *Snapshot) error {
+	path, err := snapshotPath(snap.Name)
+	if err != nil {
+		return err
+	}
+	if snap.CreatedAt.IsZero() {
+		snap.CreatedAt = time.Now()
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(snap, "", "  ")
+	if err != nil {
+		return err
+	}
+	// Replaced whole so an interrupted save keeps the old snapshot
+	return writeFile(path, data, 0600)
+}
+
+// LoadSnapshot reads a named snapshot
+func LoadSnapshot(name string) (*Snapshot, error) {
+	path, err := snapshotPath(name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := readFile(path)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("snapshot %q not found", name)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var snap Snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return nil, fmt.Errorf("reading snapshot %q: %w", name, err)
+	}
+	return &snap, nil
+}
+
+// SnapshotNames returns the names of the saved snapshots, sorted
+func SnapshotNames() ([]string, error) {
+	dir, err := SnapshotsDir()
+	if err != nil {
+		return nil, err
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(paths))
+	for _, path := range paths {
+		names = append(names, strings.TrimSuffix(filepath.Base(path), ".json"))
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// DeleteSnapshot removes a named snapshot
+func DeleteSnapshot(name string) error {
+	path, err := snapshotPath(name)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); os.IsNotExist(err) {
+		return fmt.Errorf("snapshot %q not found", name)
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSaveAndLoadSnapshot(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	snap := &Snapshot{
+		Name:        "daily-counts",
+		ServiceName: "prod",
+		SQL:         "SELECT id, total FROM counts WHERE day = {{day}}",
+		Params:      map[string]string{"day": "2026-01-01"},
+		Key:         []string{"id"},
+		Columns:     []string{"id", "total"},
+		Rows:        [][]string{{"1", "10"}, {"2", "NULL"}},
+	}
+	if err := SaveSnapshot(snap); err != nil {
+		t.Fatalf("SaveSnapshot error: %v", err)
+	}
+	if snap.CreatedAt.IsZero() {
+		t.Error("SaveSnapshot should set the creation time")
+	}
+	if err := SaveSnapshot(&Snapshot{Name: "a.first", Columns: []string{"x"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := LoadSnapshot("daily-counts")
+	if err != nil {
+		t.Fatalf("LoadSnapshot error: %v", err)
+	}
+	if !reflect.DeepEqual(loaded.Rows, snap.Rows) || !reflect.DeepEqual(loaded.Params, snap.Params) || loaded.SQL != snap.SQL {
+		t.Errorf("loaded %+v, want %+v", loaded, snap)
+	}
+
+	names, err := SnapshotNames()
+	if err != nil || !reflect.DeepEqual(names, []string{"a.first", "daily-counts"}) {
+		t.Errorf("SnapshotNames = %v, %v", names, err)
+	}
+
+	if err := DeleteSnapshot("daily-counts"); err != nil {
+		t.Fatalf("DeleteSnapshot error: %v", err)
+	}
+	if _, err := LoadSnapshot("daily-counts"); err == nil {
+		t.Error("expected an error loading a deleted snapshot")
+	}
+	if err := DeleteSnapshot("daily-counts"); err == nil {
+		t.Error("expected an error deleting a missing snapshot")
+	}
+}
+
+func TestSnapshotNamesAreValidated(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	for _, name := range []string{"", "../escape", "with space", ".hidden"} {
+		if err := SaveSnapshot(&Snapshot{Name: name}); err == nil {
+			t.Errorf("SaveSnapshot(%q) should fail", name)
+		}
+	}
+}
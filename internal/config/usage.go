@@ -0,0 +1,80 @@
+package config
+
+import "time"
+
+// usageSchema creates the table of LLM provider requests. It is kept apart
+// from query_history so trimming the history keeps the usage report whole.
+const usageSchema = `
+CREATE TABLE IF NOT EXISTS llm_usage (
+	id            INTEGER PRIMARY KEY AUTOINCREMENT,
+	timestamp     INTEGER NOT NULL, -- Unix nanoseconds
+	service_name  TEXT    NOT NULL,
+	provider      TEXT    NOT NULL,
+	model         TEXT    NOT NULL DEFAULT '',
+	input_tokens  INTEGER NOT NULL DEFAULT 0,
+	output_tokens INTEGER NOT NULL DEFAULT 0,
+	cost          REAL    NOT NULL DEFAULT 0 -- Estimated, in US dollars
+);
+CREATE INDEX IF NOT EXISTS llm_usage_time ON llm_usage (timestamp);
+`
+
+// ModelPrice is what an LLM model costs in US dollars per million tokens
+type ModelPrice struct {
+	Input  float64 `json:"input"`  // Per million prompt tokens
+	Output float64 `json:"output"` // Per million completion tokens
+}
+
+// UsageRecord is the tokens and estimated cost of one LLM provider request
+type UsageRecord struct {
+	Timestamp    time.Time
+	ServiceName  string
+	Provider     string
+	Model        string
+	InputTokens  int
+	OutputTokens int
+	Cost         float64
+}
+
+// UsageSummary adds up the provider requests made on one day for one service
+type UsageSummary struct {
+	Day          string // YYYY-MM-DD in local time
+	ServiceName  string
+	Requests     int
+	InputTokens  int
+	OutputTokens int
+	Cost         float64
+}
+
+// AddUsage records a provider request
+func (h *HistoryStore) AddUsage(u UsageRecord) error {
+	if u.Timestamp.IsZero() {
+		u.Timestamp = time.Now()
+	}
+	_, err := h.db.Exec(`INSERT INTO llm_usage (timestamp, service_name, provider, model, input_tokens, output_tokens, cost)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		u.Timestamp.UnixNano(), u.ServiceName, u.Provider, u.Model, u.InputTokens, u.OutputTokens, u.Cost)
+	return err
+}
+
+// UsageReport adds up the provider requests made since a time by day and
+// service, newest day first
+func (h *HistoryStore) UsageReport(since time.Time) ([]UsageSummary, error) {
+	rows, err := h.db.Query(`SELECT date(timestamp / 1000000000, 'unixepoch', 'localtime') AS day, service_name,
+		COUNT(*), SUM(input_tokens), SUM(output_tokens), SUM(cost)
+		FROM llm_usage WHERE timestamp >= ?
+		GROUP BY day, service_name ORDER BY day DESC, service_name`, since.UnixNano())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var report []UsageSummary
+	for rows.Next() {
+		var s UsageSummary
+		if err := rows.Scan(&s.Day, &s.ServiceName, &s.Requests, &s.InputTokens, &s.OutputTokens, &s.Cost); err != nil {
+			return nil, err
+		}
+		report = append(report, s)
+	}
+	return report, rows.Err()
+}
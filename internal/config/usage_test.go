@@ -0,0 +1,51 @@
+package config
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestUsageReport(t *testing.T) {
+	store, err := OpenHistory(filepath.Join(t.TempDir(), "history.db"))
+	if err != nil {
+		t.Fatalf("OpenHistory error: %v", err)
+	}
+	defer store.Close()
+
+	today := time.Now()
+	yesterday := today.AddDate(0, 0, -1)
+	for _, u := range []UsageRecord{
+		{Timestamp: yesterday, ServiceName: "prod", Provider: "openai", Model: "gpt-4o-mini", InputTokens: 1000, OutputTokens: 100, Cost: 0.01},
+		{Timestamp: today, ServiceName: "prod", Provider: "openai", Model: "gpt-4o-mini", InputTokens: 2000, OutputTokens: 200, Cost: 0.02},
+		{Timestamp: today, ServiceName: "prod", Provider: "claude", Model: "claude-sonnet-4-5", InputTokens: 500, OutputTokens: 50, Cost: 0.03},
+		{Timestamp: today, ServiceName: "dev", Provider: "ollama", Model: "llama3", InputTokens: 700, OutputTokens: 70},
+		{Timestamp: today.AddDate(0, 0, -40), ServiceName: "prod", Provider: "openai", InputTokens: 9999},
+	} {
+		if err := store.AddUsage(u); err != nil {
+			t.Fatalf("AddUsage error: %v", err)
+		}
+	}
+
+	report, err := store.UsageReport(today.AddDate(0, 0, -30))
+	if err != nil {
+		t.Fatalf("UsageReport error: %v", err)
+	}
+	day := func(ts time.Time) string { return ts.Format("2006-01-02") }
+	want := []UsageSummary{
+		{Day: day(today), ServiceName: "dev", Requests: 1, InputTokens: 700, OutputTokens: 70},
+		{Day: day(today), ServiceName: "prod", Requests: 2, InputTokens: 2500, OutputTokens: 250, Cost: 0.05},
+		{Day: day(yesterday), ServiceName: "prod", Requests: 1, InputTokens: 1000, OutputTokens: 100, Cost: 0.01},
+	}
+	if len(report) != len(want) {
+		t.Fatalf("report = %+v, want %+v", report, want)
+	}
+	for i := range want {
+		got := report[i]
+		if got.Day != want[i].Day || got.ServiceName != want[i].ServiceName || got.Requests != want[i].Requests ||
+			got.InputTokens != want[i].InputTokens || got.OutputTokens != want[i].OutputTokens ||
+			got.Cost < want[i].Cost-1e-9 || got.Cost > want[i].Cost+1e-9 {
+			t.Errorf("report[%d] = %+v, want %+v", i, got, want[i])
+		}
+	}
+}
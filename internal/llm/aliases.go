@@ -0,0 +1,115 @@
+package llm
+
+import (
+	"strings"
+
+	"github.com/kartoza/kartoza-pg-ai/internal/config"
+	"github.com/kartoza/kartoza-pg-ai/internal/postgres"
+)
+
+// correctionMinScore is how well a word of a question must match the table
+// or column a correction replaced to be taken as the word naming it, as
+// for findSemanticMatches
+const correctionMinScore = 0.35
+
+// SetAliases sets the words corrections tied to tables and columns. They
+// are looked up before the glossary, as the user's own choices.
+func (e *QueryEngine) SetAliases(aliases []config.Alias) {
+	e.aliases = make(Glossary, len(aliases))
+	for _, a := range aliases {
+		e.aliases[strings.ToLower(a.Word)] = a.Target()
+	}
+}
+
+// vocabulary returns the glossary with the aliases learned from
+// corrections in it, the aliases replacing words defined in both
+func (e *QueryEngine) vocabulary() Glossary {
+	if len(e.aliases) == 0 {
+		return e.glossary
+	}
+	return NewGlossary(e.glossary, e.aliases)
+}
+
+// LearnCorrections returns the words of a question that SQL edited from
+// the generated SQL ties to another table or column: when the edit swaps
+// one table of the schema for another, or one column for another, the word
+// of the question matching the one swapped out now stands for the one
+// swapped in. It returns nil for other edits.
+func (e *QueryEngine) LearnCorrections(question, generatedSQL, editedSQL string) []config.Alias {
+	if e.schema == nil || editedSQL == "" || editedSQL == generatedSQL {
+		return nil
+	}
+	before, after := sqlIdentifiers(generatedSQL), sqlIdentifiers(editedSQL)
+	var aliases []config.Alias
+
+	removed, added := e.namedTables(before, after), e.namedTables(after, before)
+	if len(removed) == 1 && len(added) == 1 {
+		if word := e.wordFor(question, removed[0].Name, added[0].Name); word != "" {
+			aliases = append(aliases, config.Alias{Word: word, Schema: added[0].Schema, Table: added[0].Name})
+		}
+	}
+
+	// Columns of the tables each SQL reads, named in one SQL but not the other
+	type column struct{ table, name string }
+	columns := func(names, other map[string]bool) []column {
+		var cols []column
+		for _, t := range e.namedTables(names, nil) {
+			for _, c := range t.Columns {
+				if names[c.Name] && !other[c.Name] {
+					cols = append(cols, column{t.Schema + "." + t.Name, c.Name})
+				}
+			}
+		}
+		return cols
+	}
+	removedCols, addedCols := columns(before, after), columns(after, before)
+	if len(removedCols) == 1 && len(addedCols) == 1 {
+		if word := e.wordFor(question, removedCols[0].name, addedCols[0].name); word != "" {
+			schema, table, _ := strings.Cut(addedCols[0].table, ".")
+			aliases = append(aliases, config.Alias{Word: word, Schema: schema, Table: table, Column: addedCols[0].name})
+		}
+	}
+	return aliases
+}
+
+// sqlIdentifiers returns the names of the identifiers in sql
+func sqlIdentifiers(sql string) map[string]bool {
+	names := make(map[string]bool)
+	for _, tok := range postgres.LexSQL(sql) {
+		if name, ok := identifierName(tok); ok {
+			names[name] = true
+		}
+	}
+	return names
+}
+
+// namedTables returns the schema's tables named in names but not in other
+func (e *QueryEngine) namedTables(names, other map[string]bool) []config.TableInfo {
+	var tables []config.TableInfo
+	for _, t := range e.schema.Tables {
+		if names[t.Name] && !other[t.Name] {
+			tables = append(tables, t)
+		}
+	}
+	return tables
+}
+
+// wordFor returns the word of a question that best matches the name a
+// correction replaced, or "" when none matches it well or the word already
+// matches the replacement as well
+func (e *QueryEngine) wordFor(question, replaced, replacement string) string {
+	matcher := &SemanticMatcher{glossary: e.vocabulary()}
+	best, bestScore := "", 0.0
+	for _, word := range keywordPattern.FindAllString(strings.ToLower(question), -1) {
+		if len(word) <= 2 || searchStopWords[word] {
+			continue
+		}
+		if score := matcher.calculateMatchScore(word, replaced).Score; score >= correctionMinScore && score > bestScore {
+			best, bestScore = word, score
+		}
+	}
+	if best == "" || matcher.calculateMatchScore(best, replacement).Score >= bestScore {
+		return ""
+	}
+	return best
+}
@@ -0,0 +1,74 @@
+package llm
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/kartoza/kartoza-pg-ai/internal/config"
+)
+
+func correctionSchema() *config.SchemaCache {
+	return &config.SchemaCache{
+		Tables: []config.TableInfo{
+			{Schema: "public", Name: "client_visits", Columns: []config.ColumnInfo{{Name: "id"}, {Name: "customer_id"}, {Name: "visited_at"}}},
+			{Schema: "public", Name: "customers", Columns: []config.ColumnInfo{{Name: "id"}, {Name: "name"}, {Name: "town"}, {Name: "city"}}},
+		},
+	}
+}
+
+func TestLearnCorrectionsTable(t *testing.T) {
+	engine := NewQueryEngine(correctionSchema())
+	aliases := engine.LearnCorrections("how many clients",
+		`SELECT COUNT(*) FROM "public"."client_visits"`,
+		`SELECT COUNT(*) FROM "public"."customers"`)
+	if len(aliases) != 1 {
+		t.Fatalf("expected one alias, got %+v", aliases)
+	}
+	if a := aliases[0]; a.Word != "clients" || a.Schema != "public" || a.Table != "customers" || a.Column != "" {
+		t.Errorf("unexpected alias: %+v", a)
+	}
+
+	engine.SetAliases(aliases)
+	sql, err := engine.GenerateSQL("how many clients", "")
+	if err != nil {
+		t.Fatalf("GenerateSQL failed: %v", err)
+	}
+	if !strings.Contains(sql, `"public"."customers"`) {
+		t.Errorf("the alias should pick customers, got %s", sql)
+	}
+	matches := engine.findSemanticMatches([]string{"clients"})
+	if len(matches) == 0 || matches[0].Table.Name != "customers" {
+		t.Errorf("clients should match customers first, got %+v", matches)
+	}
+}
+
+func TestLearnCorrectionsColumn(t *testing.T) {
+	engine := NewQueryEngine(correctionSchema())
+	aliases := engine.LearnCorrections("customers by town",
+		`SELECT "town", COUNT(*) FROM "public"."customers" GROUP BY "town"`,
+		`SELECT "city", COUNT(*) FROM "public"."customers" GROUP BY "city"`)
+	if len(aliases) != 1 {
+		t.Fatalf("expected one alias, got %+v", aliases)
+	}
+	if a := aliases[0]; a.Word != "town" || a.Table != "customers" || a.Column != "city" || a.Target() != "city" {
+		t.Errorf("unexpected alias: %+v", a)
+	}
+}
+
+func TestLearnCorrectionsIgnoresOtherEdits(t *testing.T) {
+	engine := NewQueryEngine(correctionSchema())
+	tests := []struct {
+		name, question, generated, edited string
+	}{
+		{"unchanged", "how many visits", `SELECT COUNT(*) FROM client_visits`, `SELECT COUNT(*) FROM client_visits`},
+		{"limit changed", "list visits", `SELECT * FROM client_visits LIMIT 50`, `SELECT * FROM client_visits LIMIT 10`},
+		{"table added", "list visits", `SELECT * FROM client_visits`, `SELECT * FROM client_visits JOIN customers ON customers.id = customer_id`},
+		{"no word for the table", "how many are there", `SELECT COUNT(*) FROM client_visits`, `SELECT COUNT(*) FROM customers`},
+		{"word already matches", "how many customers", `SELECT COUNT(*) FROM client_visits`, `SELECT COUNT(*) FROM customers`},
+	}
+	for _, tt := range tests {
+		if aliases := engine.LearnCorrections(tt.question, tt.generated, tt.edited); len(aliases) != 0 {
+			t.Errorf("%s: expected no alias, got %+v", tt.name, aliases)
+		}
+	}
+}
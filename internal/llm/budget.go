@@ -0,0 +1,127 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/kartoza/kartoza-pg-ai/internal/config"
+	"github.com/kartoza/kartoza-pg-ai/internal/logging"
+)
+
+// relatedTableWeight scales the relevance of a matched table onto the
+// tables it shares a foreign key with, so joins can still be written
+const relatedTableWeight = 0.5
+
+// keywordPattern splits a question or SQL into candidate keywords
+var keywordPattern = regexp.MustCompile(`[A-Za-z0-9_]+`)
+
+// SetPromptBudget limits the schema description providers are given to
+// the maxTables tables most relevant to the question whose descriptions
+// fit in maxTokens estimated tokens. 0 leaves either unlimited.
+func (e *QueryEngine) SetPromptBudget(maxTables, maxTokens int) {
+	e.promptMaxTables = maxTables
+	e.promptMaxTokens = maxTokens
+}
+
+// estimateTokens approximates the tokens a model reads for text, at about
+// four characters each
+func estimateTokens(text string) int {
+	return (len(text) + 3) / 4
+}
+
+// schemaContextFor returns the schema description for a provider request
+// about text: the whole schema when it fits the prompt budget, otherwise
+// the tables most relevant to text that do
+func (e *QueryEngine) schemaContextFor(ctx context.Context, text string) string {
+	full := e.GetSchemaContext()
+	if e.schema == nil || !e.overBudget(len(e.schema.Tables), estimateTokens(full)) {
+		return full
+	}
+
+	// What every description holds besides the tables
+	pruned := *e.schema
+	pruned.Tables = nil
+	tokens := estimateTokens(generateSchemaDescription(&pruned) + e.vocabulary().describe())
+
+	for _, t := range e.rankTables(ctx, text) {
+		if e.promptMaxTables > 0 && len(pruned.Tables) >= e.promptMaxTables {
+			break
+		}
+		cost := estimateTokens(describeTable(t))
+		// The most relevant table is kept even when it alone is over budget
+		if len(pruned.Tables) > 0 && e.promptMaxTokens > 0 && tokens+cost > e.promptMaxTokens {
+			continue
+		}
+		pruned.Tables = append(pruned.Tables, t)
+		tokens += cost
+	}
+
+	desc := generateSchemaDescription(&pruned) + e.vocabulary().describe()
+	desc += fmt.Sprintf("Only %d of the %d tables are shown, those most relevant to the question; others exist and can be looked up by name.\n",
+		len(pruned.Tables), len(e.schema.Tables))
+	logging.Debug("schema pruned for prompt", "tables", len(pruned.Tables), "of", len(e.schema.Tables),
+		"tokens", estimateTokens(desc), "full_tokens", estimateTokens(full))
+	return desc
+}
+
+// overBudget reports whether a description of tables tables and tokens
+// estimated tokens exceeds the prompt budget
+func (e *QueryEngine) overBudget(tables, tokens int) bool {
+	return (e.promptMaxTables > 0 && tables > e.promptMaxTables) ||
+		(e.promptMaxTokens > 0 && tokens > e.promptMaxTokens)
+}
+
+// rankTables orders the schema's tables by relevance to text, matching its
+// words against table and column names, comments and, with a semantic
+// index, their meaning. Tables sharing a foreign key with a match follow
+// it; unmatched tables keep their schema order at the end.
+func (e *QueryEngine) rankTables(ctx context.Context, text string) []config.TableInfo {
+	var keywords []string
+	seen := make(map[string]bool)
+	for _, word := range keywordPattern.FindAllString(strings.ToLower(text), -1) {
+		if len(word) > 2 && !searchStopWords[word] && !seen[word] {
+			seen[word] = true
+			keywords = append(keywords, word)
+		}
+	}
+
+	scores := make([]float64, len(e.schema.Tables))
+	if len(keywords) > 0 {
+		// Matching embeds the keywords, so it runs with the request's context
+		matcher := *e
+		matcher.ctx = ctx
+		index := make(map[string]int, len(e.schema.Tables))
+		for i, t := range e.schema.Tables {
+			index[t.Schema+"."+t.Name] = i
+		}
+		for _, m := range matcher.findSemanticMatches(keywords) {
+			scores[index[m.Table.Schema+"."+m.Table.Name]] = m.Score
+		}
+	}
+
+	related := make([]float64, len(scores))
+	for i, edges := range e.buildJoinGraph() {
+		for _, edge := range edges {
+			related[edge.to] = max(related[edge.to], scores[i]*relatedTableWeight)
+		}
+	}
+	for i := range scores {
+		scores[i] = max(scores[i], related[i])
+	}
+
+	order := make([]int, len(e.schema.Tables))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return scores[order[a]] > scores[order[b]]
+	})
+	tables := make([]config.TableInfo, len(order))
+	for i, idx := range order {
+		tables[i] = e.schema.Tables[idx]
+	}
+	return tables
+}
@@ -0,0 +1,87 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/kartoza/kartoza-pg-ai/internal/config"
+)
+
+// budgetSchema has a few related tables among many unrelated ones
+func budgetSchema() *config.SchemaCache {
+	schema := &config.SchemaCache{
+		Tables: []config.TableInfo{
+			{Schema: "public", Name: "customers", Columns: []config.ColumnInfo{{Name: "id", IsPrimaryKey: true}, {Name: "name"}}},
+			{Schema: "public", Name: "orders", Columns: []config.ColumnInfo{
+				{Name: "id", IsPrimaryKey: true},
+				{Name: "customer_id", IsForeignKey: true, FKTable: "customers", FKColumn: "id"},
+				{Name: "total"},
+			}},
+		},
+	}
+	for i := 0; i < 50; i++ {
+		schema.Tables = append(schema.Tables, config.TableInfo{
+			Schema:  "archive",
+			Name:    fmt.Sprintf("sensor_log_%02d", i),
+			Columns: []config.ColumnInfo{{Name: "reading"}, {Name: "recorded_at"}},
+		})
+	}
+	return schema
+}
+
+func TestSchemaContextWithinBudget(t *testing.T) {
+	engine := NewQueryEngine(budgetSchema())
+	full := engine.GetSchemaContext()
+
+	// Without a budget, or one the schema fits, everything is described
+	if got := engine.schemaContextFor(context.Background(), "total of orders"); got != full {
+		t.Errorf("unlimited budget pruned the schema")
+	}
+	engine.SetPromptBudget(100, estimateTokens(full))
+	if got := engine.schemaContextFor(context.Background(), "total of orders"); got != full {
+		t.Errorf("budget the schema fits pruned it")
+	}
+
+	engine.SetPromptBudget(5, 0)
+	got := engine.schemaContextFor(context.Background(), "what is the total of orders?")
+	if !strings.Contains(got, "public.orders") {
+		t.Errorf("matched table missing:\n%s", got)
+	}
+	if !strings.Contains(got, "public.customers") {
+		t.Errorf("table joined by foreign key missing:\n%s", got)
+	}
+	if n := strings.Count(got, "\n- "); n != 5 {
+		t.Errorf("described %d tables, want 5", n)
+	}
+	if !strings.Contains(got, "Only 5 of the 52 tables") {
+		t.Errorf("pruning not mentioned:\n%s", got)
+	}
+
+	engine.SetPromptBudget(0, 150)
+	got = engine.schemaContextFor(context.Background(), "what is the total of orders?")
+	if tokens := estimateTokens(got); tokens > 200 {
+		t.Errorf("description is %d tokens, budget 150", tokens)
+	}
+	if !strings.Contains(got, "public.orders") {
+		t.Errorf("matched table missing under token budget:\n%s", got)
+	}
+}
+
+func TestRankTables(t *testing.T) {
+	engine := NewQueryEngine(budgetSchema())
+	tables := engine.rankTables(context.Background(), "sensor_log_07 readings")
+	if tables[0].Name != "sensor_log_07" {
+		t.Errorf("first table = %s, want sensor_log_07", tables[0].Name)
+	}
+	if len(tables) != 52 {
+		t.Errorf("ranked %d tables, want all 52", len(tables))
+	}
+
+	// Nothing to match keeps the schema order
+	tables = engine.rankTables(context.Background(), "?")
+	if tables[0].Name != "customers" || tables[1].Name != "orders" {
+		t.Errorf("unmatched order = %s, %s", tables[0].Name, tables[1].Name)
+	}
+}
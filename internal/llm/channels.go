@@ -0,0 +1,90 @@
+package llm
+
+import (
+	"sort"
+	"strings"
+)
+
+// channelFillerWords are words of a description of a channel that say
+// nothing about which channel is meant
+var channelFillerWords = map[string]bool{
+	"the": true, "a": true, "an": true, "of": true, "on": true, "for": true,
+	"when": true, "to": true, "in": true, "me": true, "show": true, "watch": true,
+	"listen": true, "notify": true, "notification": true, "event": true,
+	"message": true, "channel": true, "about": true, "any": true, "all": true,
+}
+
+// MatchChannels ranks notification channels by how well their names match
+// a description such as "new orders", best first, leaving out those that
+// share no word with it. A description naming a channel exactly matches
+// only that channel.
+func MatchChannels(description string, channels []string) []string {
+	for _, c := range channels {
+		if c == description {
+			return []string{c}
+		}
+	}
+
+	var wanted []string
+	for _, w := range questionWords(description) {
+		if stem := stemWord(w); !channelFillerWords[w] && !channelFillerWords[stem] {
+			wanted = append(wanted, stem)
+		}
+	}
+	if len(wanted) == 0 {
+		return nil
+	}
+
+	type ranked struct {
+		channel string
+		score   float64
+	}
+	var matches []ranked
+	for _, c := range channels {
+		var words []string
+		for _, w := range questionWords(c) {
+			words = append(words, stemWord(w))
+		}
+		if len(words) == 0 {
+			continue
+		}
+		shared := 0
+		for _, w := range wanted {
+			for _, cw := range words {
+				if channelWordsMatch(w, cw) {
+					shared++
+					break
+				}
+			}
+		}
+		if shared > 0 {
+			// Covering more of both the description and the name is better
+			score := float64(shared)/float64(len(wanted)) + float64(shared)/float64(len(words))
+			matches = append(matches, ranked{c, score})
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].score != matches[j].score {
+			return matches[i].score > matches[j].score
+		}
+		return matches[i].channel < matches[j].channel
+	})
+	result := make([]string, len(matches))
+	for i, m := range matches {
+		result[i] = m.channel
+	}
+	return result
+}
+
+// channelWordsMatch reports whether two stems are the same word: equal,
+// one the start of the other, or for longer words one edit apart
+func channelWordsMatch(a, b string) bool {
+	if a == b {
+		return true
+	}
+	if len(a) >= 4 && len(b) >= 4 && (strings.HasPrefix(a, b) || strings.HasPrefix(b, a)) {
+		return true
+	}
+	return len(a) >= 5 && len(b) >= 5 && levenshteinDistance(a, b) <= 1
+}
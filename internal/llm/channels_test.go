@@ -0,0 +1,34 @@
+package llm
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMatchChannels(t *testing.T) {
+	channels := []string{"orders_changed", "order_shipped", "stock_alerts", "Price Updates", "sensor_readings"}
+
+	tests := []struct {
+		description string
+		expected    []string
+	}{
+		{"stock_alerts", []string{"stock_alerts"}},
+		{"order shipments", []string{"order_shipped", "orders_changed"}},
+		{"show me changed orders", []string{"orders_changed", "order_shipped"}},
+		{"price update events", []string{"Price Updates"}},
+		{"sensr readings", []string{"sensor_readings"}},
+		{"weather", nil},
+		{"the channel", nil},
+		{"notifications and events", nil},
+	}
+
+	for _, tt := range tests {
+		got := MatchChannels(tt.description, channels)
+		if len(got) == 0 && len(tt.expected) == 0 {
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("MatchChannels(%q): expected %q, got %q", tt.description, tt.expected, got)
+		}
+	}
+}
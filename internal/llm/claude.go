@@ -0,0 +1,245 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Default Anthropic settings
+const (
+	defaultClaudeBaseURL = "https://api.anthropic.com/v1"
+	defaultClaudeModel   = "claude-sonnet-4-5"
+	anthropicVersion     = "2023-06-01"
+	claudeMaxTokens      = 1024
+	claudeMaxToolTurns   = 8 // Upper bound on tool round trips per question
+)
+
+// ClaudeProvider generates SQL using the Anthropic Messages API. When schema
+// tools are available the model looks up tables on demand instead of
+// receiving the full schema description.
+type ClaudeProvider struct {
+	apiKey  string
+	model   string
+	baseURL string
+	client  *http.Client
+}
+
+// NewClaudeProvider creates a new Claude provider.
+// Empty model or baseURL fall back to the defaults.
+func NewClaudeProvider(apiKey, model, baseURL string) *ClaudeProvider {
+	if model == "" {
+		model = defaultClaudeModel
+	}
+	if baseURL == "" {
+		baseURL = defaultClaudeBaseURL
+	}
+	return &ClaudeProvider{
+		apiKey:  apiKey,
+		model:   model,
+		baseURL: strings.TrimRight(baseURL, "/"),
+		client:  &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+// Name returns the provider identifier
+func (p *ClaudeProvider) Name() string {
+	return ProviderClaude
+}
+
+// Model returns the model the provider asks
+func (p *ClaudeProvider) Model() string {
+	return p.model
+}
+
+// claudeContentBlock is a text, tool_use or tool_result content block
+type claudeContentBlock struct {
+	Type      string          `json:"type"`
+	Text      string          `json:"text,omitempty"`
+	ID        string          `json:"id,omitempty"`
+	Name      string          `json:"name,omitempty"`
+	Input     json.RawMessage `json:"input,omitempty"`
+	ToolUseID string          `json:"tool_use_id,omitempty"`
+	Content   string          `json:"content,omitempty"`
+	IsError   bool            `json:"is_error,omitempty"`
+}
+
+// claudeMessage is a single conversation turn
+type claudeMessage struct {
+	Role    string               `json:"role"`
+	Content []claudeContentBlock `json:"content"`
+}
+
+// claudeRequest is the request body for /messages
+type claudeRequest struct {
+	Model     string           `json:"model"`
+	MaxTokens int              `json:"max_tokens"`
+	System    string           `json:"system"`
+	Messages  []claudeMessage  `json:"messages"`
+	Tools     []ToolDefinition `json:"tools,omitempty"`
+}
+
+// claudeResponse is the subset of the /messages response we use
+type claudeResponse struct {
+	Content    []claudeContentBlock `json:"content"`
+	StopReason string               `json:"stop_reason"`
+	Usage      struct {
+		InputTokens  int `json:"input_tokens"`
+		OutputTokens int `json:"output_tokens"`
+	} `json:"usage"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// GenerateSQL runs the tool-use loop until the model answers with SQL
+func (p *ClaudeProvider) GenerateSQL(ctx context.Context, req GenerationRequest) (string, error) {
+	var tools []ToolDefinition
+	system := buildSystemPrompt(req)
+	if req.Tools != nil {
+		tools = req.Tools.Definitions()
+		system = buildToolSystemPrompt()
+	}
+
+	messages := []claudeMessage{
+		{Role: "user", Content: []claudeContentBlock{{Type: "text", Text: buildUserPrompt(req)}}},
+	}
+
+	for turn := 0; turn < claudeMaxToolTurns; turn++ {
+		resp, err := p.send(ctx, claudeRequest{
+			Model:     p.model,
+			MaxTokens: claudeMaxTokens,
+			System:    system,
+			Messages:  messages,
+			Tools:     tools,
+		})
+		if err != nil {
+			return "", err
+		}
+
+		if resp.StopReason != "tool_use" {
+			var text strings.Builder
+			for _, block := range resp.Content {
+				if block.Type == "text" {
+					text.WriteString(block.Text)
+				}
+			}
+			sql := extractSQL(text.String())
+			if sql == "" {
+				return "", fmt.Errorf("claude returned an empty response")
+			}
+			return sql, nil
+		}
+
+		// Answer every tool call and hand the results back
+		messages = append(messages, claudeMessage{Role: "assistant", Content: resp.Content})
+		var results []claudeContentBlock
+		for _, block := range resp.Content {
+			if block.Type != "tool_use" {
+				continue
+			}
+			result := claudeContentBlock{Type: "tool_result", ToolUseID: block.ID}
+			if req.Tools == nil {
+				result.Content = "tools are not available"
+				result.IsError = true
+			} else if out, err := req.Tools.Execute(ctx, block.Name, block.Input); err != nil {
+				result.Content = err.Error()
+				result.IsError = true
+			} else {
+				result.Content = out
+			}
+			results = append(results, result)
+		}
+		messages = append(messages, claudeMessage{Role: "user", Content: results})
+	}
+
+	return "", fmt.Errorf("claude did not produce SQL after %d tool calls", claudeMaxToolTurns)
+}
+
+// ExplainSQL asks the model to describe the SQL in plain English
+func (p *ClaudeProvider) ExplainSQL(ctx context.Context, req ExplanationRequest) (string, error) {
+	resp, err := p.send(ctx, claudeRequest{
+		Model:     p.model,
+		MaxTokens: claudeMaxTokens,
+		System:    buildExplainSystemPrompt(req),
+		Messages: []claudeMessage{
+			{Role: "user", Content: []claudeContentBlock{{Type: "text", Text: buildExplainUserPrompt(req)}}},
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+	var text strings.Builder
+	for _, block := range resp.Content {
+		if block.Type == "text" {
+			text.WriteString(block.Text)
+		}
+	}
+	explanation := strings.TrimSpace(text.String())
+	if explanation == "" {
+		return "", fmt.Errorf("claude returned an empty response")
+	}
+	return explanation, nil
+}
+
+// send posts a single request to the Messages API
+func (p *ClaudeProvider) send(ctx context.Context, req claudeRequest) (*claudeResponse, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/messages", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("x-api-key", p.apiKey)
+	httpReq.Header.Set("anthropic-version", anthropicVersion)
+
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("claude request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var msgResp claudeResponse
+	if err := json.Unmarshal(data, &msgResp); err != nil {
+		return nil, fmt.Errorf("invalid claude response (status %d): %w", resp.StatusCode, err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		if msgResp.Error != nil {
+			return nil, fmt.Errorf("claude error: %s", msgResp.Error.Message)
+		}
+		return nil, fmt.Errorf("claude returned status %d", resp.StatusCode)
+	}
+	reportUsage(ctx, ProviderClaude, p.model, msgResp.Usage.InputTokens, msgResp.Usage.OutputTokens)
+	return &msgResp, nil
+}
+
+// buildToolSystemPrompt is used instead of the schema dump when tools are available
+func buildToolSystemPrompt() string {
+	var prompt strings.Builder
+	prompt.WriteString("You are an expert PostgreSQL and PostGIS assistant. ")
+	prompt.WriteString("Translate the user's question into a read-only SQL query.\n")
+	prompt.WriteString("Use the list_tables and describe_table tools to find the relevant tables and columns ")
+	prompt.WriteString("before writing SQL; use sample_rows when you need to see actual values.\n")
+	prompt.WriteString("Rules:\n")
+	prompt.WriteString("- Only reference tables and columns you have confirmed with the tools.\n")
+	prompt.WriteString("- Respond with the SQL only, no explanations and no markdown.\n")
+	prompt.WriteString(scriptRule)
+	prompt.WriteString("- Always schema-qualify and double-quote table names.\n")
+	prompt.WriteString("- Do not end the query with a semicolon.\n")
+	return prompt.String()
+}
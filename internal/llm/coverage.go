@@ -0,0 +1,133 @@
+package llm
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/kartoza/kartoza-pg-ai/internal/config"
+)
+
+// Words that make a question about raster or point cloud coverages even
+// when no table of them is named
+var (
+	rasterWords     = regexp.MustCompile(`\b(?:rasters?|bands?|pixels?)\b`)
+	pointCloudWords = regexp.MustCompile(`\b(?:point ?clouds?|patch(?:es)?|lidar)\b`)
+)
+
+// Kinds of raster questions
+var (
+	rasterMetadataPattern = regexp.MustCompile(`\b(?:how many bands|number of bands|band count|resolution|pixel size|metadata|dimensions|srid)\b`)
+	rasterStatsPattern    = regexp.MustCompile(`\b(?:stats|statistics|summary|summarise|summarize|mean|average|min|max|minimum|maximum|range|stddev|standard deviation)\b`)
+	perTilePattern        = regexp.MustCompile(`\b(?:per|each|every|by) (?:tile|raster|row)\b`)
+	countPattern          = regexp.MustCompile(`\b(?:how many|count|number of)\b`)
+	bandPattern           = regexp.MustCompile(`\bband (\d+)\b`)
+	pointCountPattern     = regexp.MustCompile(`\b(?:how many|count|number of|total) (?:lidar )?points\b|\bpoint count\b`)
+)
+
+// coverage is a raster or point cloud column of a table
+type coverage struct {
+	table  config.TableInfo
+	column config.ColumnInfo
+}
+
+// matchCoverageQuery answers questions about raster and point cloud
+// tables: how many tiles or patches they hold, the metadata of rasters,
+// band statistics with ST_SummaryStats and point counts with PC_NumPoints
+func (e *QueryEngine) matchCoverageQuery(query string) string {
+	padded := padWords(query)
+	if c, ok := e.mentionedCoverage(padded, func(c config.ColumnInfo) bool { return c.IsRaster }, rasterWords.MatchString(query)); ok {
+		return e.rasterQuery(query, c)
+	}
+	if c, ok := e.mentionedCoverage(padded, func(c config.ColumnInfo) bool { return c.IsPointCloud }, pointCloudWords.MatchString(query)); ok {
+		return e.pointCloudQuery(query, c)
+	}
+	return ""
+}
+
+// mentionedCoverage returns the coverage column of the first table padded
+// mentions holding one; without a mention, the first coverage when the
+// question is about coverages anyway
+func (e *QueryEngine) mentionedCoverage(padded string, want func(config.ColumnInfo) bool, aboutCoverages bool) (coverage, bool) {
+	var found []coverage
+	for _, t := range e.schema.Tables {
+		for _, c := range t.Columns {
+			if want(c) {
+				found = append(found, coverage{table: t, column: c})
+				break
+			}
+		}
+	}
+
+	best, bestPos := -1, -1
+	for i, c := range found {
+		if pos := tableMention(padded, c.table.Name); pos >= 0 && (bestPos < 0 || pos < bestPos) {
+			best, bestPos = i, pos
+		}
+	}
+	switch {
+	case best >= 0:
+		return found[best], true
+	case aboutCoverages && len(found) > 0:
+		return found[0], true
+	default:
+		return coverage{}, false
+	}
+}
+
+// rasterQuery answers a question about a raster table
+func (e *QueryEngine) rasterQuery(query string, c coverage) string {
+	from := fmt.Sprintf(`FROM "%s"."%s" t`, c.table.Schema, c.table.Name)
+	rast := "t." + quoteIdent(c.column.Name)
+	band := "1"
+	if m := bandPattern.FindStringSubmatch(query); m != nil {
+		band = m[1]
+	}
+
+	switch {
+	case rasterMetadataPattern.MatchString(query):
+		// Checked before counting so "how many bands" isn't a tile count
+	case countPattern.MatchString(query) && !rasterStatsPattern.MatchString(query):
+		return fmt.Sprintf("SELECT COUNT(*) AS tiles %s", from)
+	case rasterStatsPattern.MatchString(query) && perTilePattern.MatchString(query):
+		return fmt.Sprintf("SELECT %s(ST_SummaryStats(%s, %s)).* %s%s",
+			keyColumns(c.table), rast, band, from, e.limitClause())
+	case rasterStatsPattern.MatchString(query):
+		// Statistics of the whole coverage, ignoring nodata pixels
+		return fmt.Sprintf("SELECT (ST_SummaryStatsAgg(%s, %s, true)).* %s", rast, band, from)
+	}
+	return fmt.Sprintf(`SELECT %sST_NumBands(%s) AS bands, ST_Width(%s) AS width_px, ST_Height(%s) AS height_px,
+		ST_PixelWidth(%s) AS pixel_width, ST_PixelHeight(%s) AS pixel_height, ST_SRID(%s) AS srid
+		%s%s`, keyColumns(c.table), rast, rast, rast, rast, rast, rast, from, e.limitClause())
+}
+
+// pointCloudQuery answers a question about a point cloud table
+func (e *QueryEngine) pointCloudQuery(query string, c coverage) string {
+	from := fmt.Sprintf(`FROM "%s"."%s" t`, c.table.Schema, c.table.Name)
+	pa := "t." + quoteIdent(c.column.Name)
+	patches := c.column.DataType != "pcpoint"
+
+	switch {
+	case pointCountPattern.MatchString(query) && patches:
+		return fmt.Sprintf("SELECT SUM(PC_NumPoints(%s)) AS points %s", pa, from)
+	case pointCountPattern.MatchString(query):
+		return fmt.Sprintf("SELECT COUNT(*) AS points %s", from)
+	case countPattern.MatchString(query):
+		return fmt.Sprintf("SELECT COUNT(*) AS patches %s", from)
+	case patches:
+		return fmt.Sprintf("SELECT %sPC_NumPoints(%s) AS points, PC_Summary(%s) AS summary %s%s",
+			keyColumns(c.table), pa, pa, from, e.limitClause())
+	}
+	return fmt.Sprintf("SELECT %sPC_AsText(%s) AS point %s%s", keyColumns(c.table), pa, from, e.limitClause())
+}
+
+// keyColumns lists a table's primary key columns for a select list, with
+// a trailing comma, so rows of coverage summaries can be told apart
+func keyColumns(t config.TableInfo) string {
+	var cols string
+	for _, c := range t.Columns {
+		if c.IsPrimaryKey {
+			cols += "t." + quoteIdent(c.Name) + ", "
+		}
+	}
+	return cols
+}
@@ -0,0 +1,70 @@
+package llm
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/kartoza/kartoza-pg-ai/internal/config"
+)
+
+func TestCoverageQueries(t *testing.T) {
+	schema := &config.SchemaCache{
+		HasPostGIS:    true,
+		HasRaster:     true,
+		HasPointCloud: true,
+		Tables: []config.TableInfo{
+			{Schema: "public", Name: "customers", Columns: []config.ColumnInfo{
+				{Name: "id", DataType: "integer", IsPrimaryKey: true},
+				{Name: "name", DataType: "text"},
+			}},
+			{Schema: "public", Name: "dem", Columns: []config.ColumnInfo{
+				{Name: "rid", DataType: "integer", IsPrimaryKey: true},
+				{Name: "rast", DataType: "raster", IsRaster: true, RasterBands: 3, SRID: 4326},
+			}},
+			{Schema: "lidar", Name: "patches", Columns: []config.ColumnInfo{
+				{Name: "id", DataType: "integer", IsPrimaryKey: true},
+				{Name: "pa", DataType: "pcpatch", IsPointCloud: true},
+			}},
+		},
+	}
+	engine := NewQueryEngine(schema)
+	engine.SetUseNN(false)
+
+	tests := []struct {
+		query    string
+		expected string
+	}{
+		{"how many rasters are there", `SELECT COUNT(*) AS tiles FROM "public"."dem" t`},
+		{"how many bands does the dem have", `SELECT t."rid", ST_NumBands(t."rast") AS bands`},
+		{"band 2 statistics of dem", `SELECT (ST_SummaryStatsAgg(t."rast", 2, true)).* FROM "public"."dem" t`},
+		{"min and max elevation per tile in dem", `SELECT t."rid", (ST_SummaryStats(t."rast", 1)).* FROM "public"."dem" t`},
+		{"how many points in the point cloud", `SELECT SUM(PC_NumPoints(t."pa")) AS points FROM "lidar"."patches" t`},
+		{"count patches", `SELECT COUNT(*) AS patches FROM "lidar"."patches" t`},
+		{"how many customers", `SELECT COUNT(*) as count FROM "public"."customers"`},
+	}
+	for _, tt := range tests {
+		sql, err := engine.GenerateSQL(tt.query, "")
+		if err != nil {
+			t.Fatalf("GenerateSQL(%q): %v", tt.query, err)
+		}
+		if !strings.Contains(sql, tt.expected) {
+			t.Errorf("GenerateSQL(%q) = %s\nwant it to contain %s", tt.query, sql, tt.expected)
+		}
+	}
+}
+
+func TestSchemaDescriptionMentionsCoverages(t *testing.T) {
+	desc := generateSchemaDescription(&config.SchemaCache{
+		HasRaster: true,
+		Tables: []config.TableInfo{
+			{Schema: "public", Name: "dem", Columns: []config.ColumnInfo{
+				{Name: "rast", DataType: "raster", IsRaster: true, RasterBands: 1, SRID: 32734},
+			}},
+		},
+	})
+	for _, want := range []string{"ST_SummaryStats", "[RASTER: 1 bands, SRID 32734]"} {
+		if !strings.Contains(desc, want) {
+			t.Errorf("schema description lacks %q:\n%s", want, desc)
+		}
+	}
+}
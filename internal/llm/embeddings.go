@@ -0,0 +1,437 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/kartoza/kartoza-pg-ai/internal/config"
+)
+
+// EmbeddingNone disables semantic search in Settings.EmbeddingProvider;
+// "ollama" and "openai" use the servers and keys of those SQL providers
+const EmbeddingNone = "none"
+
+// Default embedding settings
+const (
+	defaultOllamaEmbeddingModel = "nomic-embed-text"
+	defaultOpenAIEmbeddingModel = "text-embedding-3-small"
+
+	// embeddingBatchSize is the number of texts sent per embedding request
+	embeddingBatchSize = 64
+	// embeddingTimeout bounds embedding the keywords of one question
+	embeddingTimeout = 10 * time.Second
+	// minSemanticSimilarity is the cosine similarity below which a table
+	// or column is not considered related to a keyword
+	minSemanticSimilarity = 0.6
+)
+
+// Embedder turns texts into vectors whose cosine similarity reflects how
+// related the texts are
+type Embedder interface {
+	// Model identifies the provider and model; vectors of different models
+	// can't be compared
+	Model() string
+	// Embed returns one vector per text, in order
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// NewEmbedderFromSettings creates the embedder configured in settings.
+// Returns nil (and no error) when semantic search is disabled.
+func NewEmbedderFromSettings(settings config.Settings) (Embedder, error) {
+	switch strings.ToLower(settings.EmbeddingProvider) {
+	case "", EmbeddingNone:
+		return nil, nil
+	case ProviderOllama:
+		return NewOllamaEmbedder(settings.OllamaBaseURL, settings.EmbeddingModel), nil
+	case ProviderOpenAI:
+		apiKey := settings.OpenAIAPIKey
+		if apiKey == "" {
+			apiKey = os.Getenv("OPENAI_API_KEY")
+		}
+		if apiKey == "" {
+			return nil, fmt.Errorf("openai embeddings selected but no API key configured (set OPENAI_API_KEY)")
+		}
+		return NewOpenAIEmbedder(apiKey, settings.EmbeddingModel, settings.OpenAIBaseURL), nil
+	default:
+		return nil, fmt.Errorf("unknown embedding provider: %s", settings.EmbeddingProvider)
+	}
+}
+
+// OllamaEmbedder embeds texts with a model served by Ollama
+type OllamaEmbedder struct {
+	baseURL string
+	model   string
+	client  *http.Client
+}
+
+// NewOllamaEmbedder creates a new Ollama embedder.
+// Empty baseURL or model fall back to the defaults.
+func NewOllamaEmbedder(baseURL, model string) *OllamaEmbedder {
+	if baseURL == "" {
+		baseURL = defaultOllamaBaseURL
+	}
+	if model == "" {
+		model = defaultOllamaEmbeddingModel
+	}
+	return &OllamaEmbedder{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		model:   model,
+		client:  &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+// Model returns the provider and model name
+func (p *OllamaEmbedder) Model() string {
+	return ProviderOllama + ":" + p.model
+}
+
+// Embed returns the embeddings of texts from /api/embed
+func (p *OllamaEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	var resp struct {
+		Embeddings [][]float32 `json:"embeddings"`
+		Error      string      `json:"error,omitempty"`
+	}
+	status, err := postJSON(ctx, p.client, p.baseURL+"/api/embed", "", map[string]any{
+		"model": p.model,
+		"input": texts,
+	}, &resp)
+	if err != nil {
+		return nil, fmt.Errorf("ollama embedding request failed: %w", err)
+	}
+	if resp.Error != "" {
+		return nil, fmt.Errorf("ollama error: %s", resp.Error)
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("ollama returned status %d", status)
+	}
+	if len(resp.Embeddings) != len(texts) {
+		return nil, fmt.Errorf("ollama returned %d embeddings for %d texts", len(resp.Embeddings), len(texts))
+	}
+	return resp.Embeddings, nil
+}
+
+// OpenAIEmbedder embeds texts with the OpenAI embeddings API
+type OpenAIEmbedder struct {
+	apiKey  string
+	model   string
+	baseURL string
+	client  *http.Client
+}
+
+// NewOpenAIEmbedder creates a new OpenAI embedder.
+// Empty model or baseURL fall back to the defaults.
+func NewOpenAIEmbedder(apiKey, model, baseURL string) *OpenAIEmbedder {
+	if model == "" {
+		model = defaultOpenAIEmbeddingModel
+	}
+	if baseURL == "" {
+		baseURL = defaultOpenAIBaseURL
+	}
+	return &OpenAIEmbedder{
+		apiKey:  apiKey,
+		model:   model,
+		baseURL: strings.TrimRight(baseURL, "/"),
+		client:  &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+// Model returns the provider and model name
+func (p *OpenAIEmbedder) Model() string {
+	return ProviderOpenAI + ":" + p.model
+}
+
+// Embed returns the embeddings of texts from /embeddings
+func (p *OpenAIEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	var resp struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+		Error *struct {
+			Message string `json:"message"`
+		} `json:"error,omitempty"`
+	}
+	status, err := postJSON(ctx, p.client, p.baseURL+"/embeddings", p.apiKey, map[string]any{
+		"model": p.model,
+		"input": texts,
+	}, &resp)
+	if err != nil {
+		return nil, fmt.Errorf("openai embedding request failed: %w", err)
+	}
+	if status != http.StatusOK {
+		if resp.Error != nil {
+			return nil, fmt.Errorf("openai error: %s", resp.Error.Message)
+		}
+		return nil, fmt.Errorf("openai returned status %d", status)
+	}
+
+	vectors := make([][]float32, len(texts))
+	for _, d := range resp.Data {
+		if d.Index >= 0 && d.Index < len(vectors) {
+			vectors[d.Index] = d.Embedding
+		}
+	}
+	for i, v := range vectors {
+		if v == nil {
+			return nil, fmt.Errorf("openai returned no embedding for input %d", i)
+		}
+	}
+	return vectors, nil
+}
+
+// postJSON posts body as JSON, with a bearer token when apiKey is set, and
+// decodes the response into out, returning the HTTP status
+func postJSON(ctx context.Context, client *http.Client, url, apiKey string, body, out any) (int, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	data, err = io.ReadAll(resp.Body)
+	if err != nil {
+		return resp.StatusCode, err
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return resp.StatusCode, fmt.Errorf("invalid response (status %d): %w", resp.StatusCode, err)
+	}
+	return resp.StatusCode, nil
+}
+
+// SemanticIndex holds the embeddings of a schema's table and column
+// descriptions, so questions can be matched to tables by meaning
+type SemanticIndex struct {
+	model   string
+	entries []semanticEntry
+}
+
+// semanticEntry is one embedded table or column description
+type semanticEntry struct {
+	table  string // "schema.table"
+	column string // Empty for the table itself
+	text   string
+	vector []float32
+}
+
+// Len returns the number of embedded descriptions
+func (idx *SemanticIndex) Len() int {
+	return len(idx.entries)
+}
+
+// schemaDescriptions returns the entries to embed: every table and column
+// described by its name, with underscores as spaces, and its comment and
+// accepted values
+func schemaDescriptions(cache *config.SchemaCache) []semanticEntry {
+	words := func(name string) string {
+		return strings.ReplaceAll(name, "_", " ")
+	}
+	var entries []semanticEntry
+	for _, t := range cache.WithoutPartitions().Readable().Tables {
+		key := t.Schema + "." + t.Name
+		text := words(t.Name)
+		if t.Comment != "" {
+			text += ": " + t.Comment
+		}
+		entries = append(entries, semanticEntry{table: key, text: text})
+		for _, c := range t.Columns {
+			text := words(t.Name) + " " + words(c.Name)
+			if c.Comment != "" {
+				text += ": " + c.Comment
+			}
+			if len(c.AcceptedValues) > 0 {
+				text += " (" + strings.Join(c.AcceptedValues, ", ") + ")"
+			}
+			entries = append(entries, semanticEntry{table: key, column: c.Name, text: text})
+		}
+	}
+	return entries
+}
+
+// BuildSemanticIndex embeds the descriptions of the schema's tables and
+// columns, reusing vectors cached on disk by earlier runs
+func BuildSemanticIndex(ctx context.Context, embedder Embedder, cache *config.SchemaCache) (*SemanticIndex, error) {
+	idx := &SemanticIndex{model: embedder.Model(), entries: schemaDescriptions(cache)}
+	texts := make([]string, len(idx.entries))
+	for i, entry := range idx.entries {
+		texts[i] = entry.text
+	}
+	vectors, err := embedCached(ctx, embedder, texts)
+	if err != nil {
+		return nil, err
+	}
+	for i := range idx.entries {
+		idx.entries[i].vector = vectors[i]
+	}
+	return idx, nil
+}
+
+// embedCached embeds texts in batches, reusing and adding to the vectors
+// cached on disk for the embedder's model
+func embedCached(ctx context.Context, embedder Embedder, texts []string) ([][]float32, error) {
+	store := loadEmbeddingCache()
+	cached := store[embedder.Model()]
+	if cached == nil {
+		cached = make(map[string][]float32)
+		store[embedder.Model()] = cached
+	}
+
+	result := make([][]float32, len(texts))
+	var missing []int
+	for i, text := range texts {
+		if v, ok := cached[textKey(text)]; ok {
+			result[i] = v
+		} else {
+			missing = append(missing, i)
+		}
+	}
+
+	for start := 0; start < len(missing); start += embeddingBatchSize {
+		batch := missing[start:min(start+embeddingBatchSize, len(missing))]
+		batchTexts := make([]string, len(batch))
+		for i, text := range batch {
+			batchTexts[i] = texts[text]
+		}
+		embedded, err := embedder.Embed(ctx, batchTexts)
+		if err != nil {
+			return nil, err
+		}
+		for i, text := range batch {
+			result[text] = embedded[i]
+			cached[textKey(batchTexts[i])] = embedded[i]
+		}
+	}
+
+	if len(missing) > 0 {
+		// A lost cache only costs re-embedding next time
+		_ = saveEmbeddingCache(store)
+	}
+	return result, nil
+}
+
+// semanticMatch is the best match of a table's descriptions to a keyword
+type semanticMatch struct {
+	Score     float64
+	MatchType string
+	MatchedOn string
+	Keyword   string
+}
+
+// semanticMatches scores every table by the most similar of its table and
+// column descriptions to any keyword, keyed by "schema.table". It returns
+// nil when no index is loaded or the keywords can't be embedded, leaving
+// matching to names alone.
+func (e *QueryEngine) semanticMatches(keywords []string) map[string]semanticMatch {
+	if e.embedder == nil || e.index == nil || e.index.model != e.embedder.Model() || len(keywords) == 0 {
+		return nil
+	}
+	ctx := e.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancel := context.WithTimeout(ctx, embeddingTimeout)
+	defer cancel()
+
+	vectors, err := e.embedder.Embed(ctx, keywords)
+	if err != nil {
+		return nil
+	}
+
+	matches := make(map[string]semanticMatch)
+	for _, entry := range e.index.entries {
+		for k, v := range vectors {
+			score := cosineSimilarity(v, entry.vector)
+			if score < minSemanticSimilarity {
+				continue
+			}
+			match := semanticMatch{Score: score, MatchType: "semantic_table", MatchedOn: entry.table, Keyword: keywords[k]}
+			if entry.column != "" {
+				// Column matches weigh less, as with name matching
+				match.Score *= 0.85
+				match.MatchType = "semantic_column"
+				match.MatchedOn = entry.column
+			}
+			if match.Score > matches[entry.table].Score {
+				matches[entry.table] = match
+			}
+		}
+	}
+	return matches
+}
+
+// cosineSimilarity returns the cosine of the angle between a and b, or 0
+// when their lengths differ or either is zero
+func cosineSimilarity(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(normA*normB)
+}
+
+// textKey identifies an embedded text in the embedding cache
+func textKey(text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(sum[:])
+}
+
+// loadEmbeddingCache reads the embedding cache; a missing or unreadable
+// cache is empty
+func loadEmbeddingCache() map[string]map[string][]float32 {
+	store := make(map[string]map[string][]float32)
+	path, err := config.EmbeddingCachePath()
+	if err != nil {
+		return store
+	}
+	if data, err := os.ReadFile(path); err == nil {
+		_ = json.Unmarshal(data, &store)
+	}
+	return store
+}
+
+// saveEmbeddingCache writes the embedding cache
+func saveEmbeddingCache(store map[string]map[string][]float32) error {
+	path, err := config.EmbeddingCachePath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(store)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
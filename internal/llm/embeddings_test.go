@@ -0,0 +1,122 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kartoza/kartoza-pg-ai/internal/config"
+)
+
+// conceptEmbedder embeds texts as one-hot vectors of the concepts their
+// words belong to, standing in for a real embedding model
+type conceptEmbedder struct {
+	calls int
+}
+
+var testConcepts = [][]string{
+	{"customers", "clients", "buyers"},
+	{"admin", "boundaries", "municipality"},
+	{"roads", "streets"},
+}
+
+func (c *conceptEmbedder) Model() string { return "test:concepts" }
+
+func (c *conceptEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	c.calls++
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vectors[i] = make([]float32, len(testConcepts))
+		for _, word := range strings.Fields(strings.ToLower(text)) {
+			for k, concept := range testConcepts {
+				for _, w := range concept {
+					if strings.Trim(word, ":") == w {
+						vectors[i][k] = 1
+					}
+				}
+			}
+		}
+	}
+	return vectors, nil
+}
+
+func TestSemanticSearchMatchesByMeaning(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	schema := &config.SchemaCache{
+		Tables: []config.TableInfo{
+			{Schema: "public", Name: "customers"},
+			{Schema: "public", Name: "admin_boundaries"},
+			{Schema: "public", Name: "roads"},
+		},
+	}
+	embedder := &conceptEmbedder{}
+	index, err := BuildSemanticIndex(context.Background(), embedder, schema)
+	if err != nil {
+		t.Fatalf("BuildSemanticIndex failed: %v", err)
+	}
+	if index.Len() != 3 {
+		t.Errorf("expected 3 descriptions, got %d", index.Len())
+	}
+
+	engine := NewQueryEngine(schema)
+	engine.useNN = false
+	engine.SetEmbedder(embedder)
+	engine.SetSemanticIndex(index)
+
+	for question, table := range map[string]string{
+		"do i have any clients":      `"public"."customers"`,
+		"is there municipality data": `"public"."admin_boundaries"`,
+	} {
+		sql, err := engine.GenerateSQL(question, "")
+		if err != nil || !strings.Contains(sql, table) {
+			t.Errorf("%q: expected %s, got %q (%v)", question, table, sql, err)
+		}
+	}
+
+	// A second build reuses the cached vectors
+	embedder.calls = 0
+	if _, err := BuildSemanticIndex(context.Background(), embedder, schema); err != nil || embedder.calls != 0 {
+		t.Errorf("expected cached embeddings, got %d calls (%v)", embedder.calls, err)
+	}
+}
+
+func TestOllamaEmbedder(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/embed" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		var req struct {
+			Model string   `json:"model"`
+			Input []string `json:"input"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.Model != "nomic-embed-text" || len(req.Input) != 2 {
+			t.Errorf("unexpected request: %+v", req)
+		}
+		w.Write([]byte(`{"embeddings": [[1, 0], [0, 1]]}`))
+	}))
+	defer server.Close()
+
+	vectors, err := NewOllamaEmbedder(server.URL, "").Embed(context.Background(), []string{"a", "b"})
+	if err != nil {
+		t.Fatalf("Embed failed: %v", err)
+	}
+	if len(vectors) != 2 || vectors[1][1] != 1 {
+		t.Errorf("unexpected vectors: %v", vectors)
+	}
+}
+
+func TestCosineSimilarity(t *testing.T) {
+	if got := cosineSimilarity([]float32{1, 0}, []float32{2, 0}); got != 1 {
+		t.Errorf("parallel vectors: %v", got)
+	}
+	if got := cosineSimilarity([]float32{1, 0}, []float32{0, 1}); got != 0 {
+		t.Errorf("orthogonal vectors: %v", got)
+	}
+	if got := cosineSimilarity([]float32{1}, []float32{1, 0}); got != 0 {
+		t.Errorf("mismatched lengths: %v", got)
+	}
+}
@@ -1,12 +1,18 @@
 package llm
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/kartoza/kartoza-pg-ai/internal/config"
+	"github.com/kartoza/kartoza-pg-ai/internal/logging"
 	"github.com/kartoza/kartoza-pg-ai/internal/nn"
+	"github.com/kartoza/kartoza-pg-ai/internal/postgres"
+	"github.com/kartoza/kartoza-pg-ai/internal/tracing"
 )
 
 // QueryEngine handles natural language to SQL conversion
@@ -14,13 +20,56 @@ import (
 type QueryEngine struct {
 	schema    *config.SchemaCache
 	nnTrainer *nn.QueryTrainer
-	useNN     bool // Whether to use NN predictions when available
+	useNN     bool                   // Whether to use NN predictions when available
+	provider  LLMProvider            // Optional LLM backend; nil means rule-based only
+	providers map[string]LLMProvider // Providers a question can be routed to, by name
+	db        *sql.DB                // Live connection for provider tools (may be nil)
+	timeout   time.Duration          // Statement timeout of queries on db; 0 for none
+
+	limit         int                  // LIMIT of generated row queries; 0 uses defaultRowLimit
+	noAutoLimit   bool                 // Leave row queries without a LIMIT unless asked for one
+	defaultSchema string               // Schema preferred when a table name exists in several
+	schemaChoices map[string]string    // Schema chosen for each ambiguous table name
+	ambiguous     *AmbiguousTableError // Set by findTable during one rule-based match
+	glossary      Glossary             // Synonyms of the schema's words used in questions
+	aliases       Glossary             // Words tied to tables and columns by corrections
+
+	embedder Embedder        // Optional; enables semantic schema search with index
+	index    *SemanticIndex  // Embeddings of the schema's tables and columns
+	examples *ExampleStore   // Earlier questions given to providers as examples
+	ctx      context.Context // Context of the rule-based match in progress
+
+	gazetteer string   // Table place names are looked up in; "" detects one
+	geocoder  Geocoder // Optional; locates places no gazetteer holds
+
+	promptMaxTables int // Tables described to providers; 0 for all
+	promptMaxTokens int // Estimated tokens of the schema described to providers; 0 for no limit
+
+	prices       map[string]config.ModelPrice // Model prices replacing the defaults
+	usageService string                       // Service provider requests are logged under
+	logUsage     bool                         // Whether provider requests are logged for the usage report
 }
 
-// NewQueryEngine creates a new query engine
+// defaultRowLimit is the LIMIT of generated row queries unless SetRowLimit changes it
+const defaultRowLimit = 50
+
+// AmbiguousTableError reports that a question names a table found in
+// several schemas, none of which is preferred
+type AmbiguousTableError struct {
+	Table   string
+	Schemas []string
+}
+
+func (e *AmbiguousTableError) Error() string {
+	return fmt.Sprintf("table %q exists in several schemas (%s)", e.Table, strings.Join(e.Schemas, ", "))
+}
+
+// NewQueryEngine creates a new query engine. Partitions are left out of
+// the schema it matches questions against, so queries target their parents,
+// and so are tables the role cannot select from.
 func NewQueryEngine(schema *config.SchemaCache) *QueryEngine {
 	engine := &QueryEngine{
-		schema: schema,
+		schema: schema.WithoutPartitions().Readable(),
 		useNN:  true, // Enable NN by default
 	}
 
@@ -34,68 +83,212 @@ func NewQueryEngine(schema *config.SchemaCache) *QueryEngine {
 }
 
 // GenerateSQL converts natural language to SQL
-func (e *QueryEngine) GenerateSQL(query string, context string) (string, error) {
+func (e *QueryEngine) GenerateSQL(query string, conversation string) (string, error) {
+	return e.GenerateSQLContext(context.Background(), query, conversation)
+}
+
+// GenerateSQLContext converts natural language to SQL; ctx cancels provider requests
+func (e *QueryEngine) GenerateSQLContext(ctx context.Context, query string, conversation string) (string, error) {
+	return e.GenerateSQLWith(ctx, GeneratorAuto, query, conversation)
+}
+
+// GenerateSQLWith converts natural language to SQL with one generator:
+// GeneratorAuto tries the configured provider, then the neural network,
+// then the rules; ProviderRules, GeneratorNN or the name of a provider
+// added with AddProvider use only that one.
+func (e *QueryEngine) GenerateSQLWith(ctx context.Context, generator, query, conversation string) (result string, err error) {
+	if generator == "" {
+		generator = GeneratorAuto
+	}
+	ctx, span := tracing.Start(ctx, "generate_sql", tracing.Question.String(query), tracing.Generator.String(generator))
+	source := ""
+	defer func() {
+		if err == nil {
+			span.SetAttributes(tracing.DBQueryText.String(result), tracing.SQLSource.String(source))
+		}
+		tracing.End(span, err)
+	}()
+
 	if e.schema == nil {
 		return "", fmt.Errorf("no schema loaded")
 	}
 
-	// Try neural network prediction first if enabled and trained
+	switch generator {
+	case GeneratorAuto:
+	case ProviderRules:
+		source = "rules"
+		return e.generateWithRules(ctx, query)
+	case GeneratorNN:
+		source = "neural_network"
+		if e.nnTrainer == nil || !e.nnTrainer.IsTrained() {
+			return "", fmt.Errorf("the neural network has not been trained yet")
+		}
+		if sql, ok := e.predictSQL(query); ok {
+			return sql, nil
+		}
+		return "", fmt.Errorf("the neural network could not answer: %s", query)
+	default:
+		provider, ok := e.providers[generator]
+		if !ok {
+			return "", fmt.Errorf("LLM provider %s is not available", generator)
+		}
+		source = "provider"
+		return e.generateWithProvider(ctx, provider, query, conversation)
+	}
+
+	// Delegate to the configured LLM provider first
+	var providerErr error
+	if e.provider != nil {
+		sql, err := e.generateWithProvider(ctx, e.provider, query, conversation)
+		if err == nil {
+			source = "provider"
+			return sql, nil
+		}
+		providerErr = err
+		logging.Warn("provider failed, falling back to local generation", "provider", e.provider.Name(), "error", err)
+	}
+
+	// Try neural network prediction if enabled and trained
 	if e.useNN && e.nnTrainer != nil && e.nnTrainer.IsTrained() {
-		if nnSQL, confidence, err := e.nnTrainer.Predict(query); err == nil && confidence > 0.6 {
-			// Validate the NN-generated SQL is syntactically reasonable
-			if isValidSQLStructure(nnSQL) {
-				return nnSQL, nil
-			}
+		if sql, ok := e.predictSQL(query); ok {
+			source = "neural_network"
+			return sql, nil
 		}
 	}
 
-	// Fall back to rule-based matching
+	sql, err := e.generateWithRules(ctx, query)
+	if err == nil {
+		source = "rules"
+		return sql, nil
+	}
+	if _, ambiguous := err.(*AmbiguousTableError); !ambiguous && providerErr != nil {
+		return "", fmt.Errorf("could not understand query: %s (%v)", query, providerErr)
+	}
+	return "", err
+}
+
+// predictSQL asks the neural network for SQL, accepting only a confident
+// prediction that is syntactically reasonable
+func (e *QueryEngine) predictSQL(query string) (string, bool) {
+	nnSQL, confidence, err := e.nnTrainer.Predict(query)
+	if err != nil || confidence <= 0.6 || !isValidSQLStructure(nnSQL) {
+		return "", false
+	}
+	logging.Debug("sql predicted by neural network", "confidence", confidence)
+	return e.limitRows(nnSQL), true
+}
+
+// generateWithRules converts a question with the rule-based matchers.
+// Matching runs on a copy of the engine so each call records its own
+// ambiguous table names.
+func (e *QueryEngine) generateWithRules(ctx context.Context, query string) (string, error) {
+	rules := *e
+	rules.ambiguous = nil
+	rules.ctx = ctx
+	sql := rules.matchRules(query)
+	if a := rules.ambiguous; a != nil && (sql == "" || strings.Contains(sql, `"`+a.Table+`"`)) {
+		return "", a
+	}
+	if sql == "" {
+		return "", fmt.Errorf("could not understand query: %s", query)
+	}
+	return sql, nil
+}
+
+// matchRules converts a question to SQL with the rule-based matchers,
+// returning "" when none applies
+func (e *QueryEngine) matchRules(query string) string {
+	// Conditions on json/jsonb columns, matched before lowercasing because
+	// JSON keys and values are case sensitive
+	if jsonMatch := e.matchJSONQuery(strings.TrimSpace(query)); jsonMatch != "" {
+		return jsonMatch
+	}
+
+	// Similarity searches over pgvector columns, before lowercasing so the
+	// compared text is embedded as written
+	if similarMatch := e.matchSimilarityQuery(query); similarMatch != "" {
+		return similarMatch
+	}
+
 	query = strings.TrimSpace(strings.ToLower(query))
 
 	// Simple pattern matching for common queries
 	// In production, replace with actual LLM integration
 
+	// Routes between places over a pgRouting network
+	if e.schema.HasPgRouting {
+		if routeMatch := e.matchRouteQuery(query); routeMatch != "" {
+			return routeMatch
+		}
+	}
+
+	// Features within a distance of a place or of other features
+	if e.schema.HasPostGIS {
+		if distanceMatch := e.matchDistanceQuery(query); distanceMatch != "" {
+			return distanceMatch
+		}
+	}
+
+	// Raster and point cloud coverages
+	if e.schema.HasRaster || e.schema.HasPointCloud {
+		if coverageMatch := e.matchCoverageQuery(query); coverageMatch != "" {
+			return coverageMatch
+		}
+	}
+
+	// Queries naming a known column value ("orders with status shipped")
+	if valueMatch := e.matchValueFilterQuery(query); valueMatch != "" {
+		return valueMatch
+	}
+
 	// Count queries
 	if countMatch := e.matchCountQuery(query); countMatch != "" {
-		return countMatch, nil
+		return countMatch
+	}
+
+	// Questions spanning several tables, joined along foreign keys
+	if joinMatch := e.matchJoinQuery(query); joinMatch != "" {
+		return joinMatch
 	}
 
 	// Show/list queries
 	if showMatch := e.matchShowQuery(query); showMatch != "" {
-		return showMatch, nil
+		return showMatch
 	}
 
 	// Table info queries
 	if tableMatch := e.matchTableQuery(query); tableMatch != "" {
-		return tableMatch, nil
+		return tableMatch
 	}
 
 	// Spatial queries (if PostGIS available)
 	if e.schema.HasPostGIS {
 		if spatialMatch := e.matchSpatialQuery(query); spatialMatch != "" {
-			return spatialMatch, nil
+			return spatialMatch
 		}
 	}
 
 	// Generic select with limit
 	if selectMatch := e.matchSelectQuery(query); selectMatch != "" {
-		return selectMatch, nil
+		return selectMatch
 	}
 
 	// Search/find queries - look for tables/columns matching keywords
 	if searchMatch := e.matchSearchQuery(query); searchMatch != "" {
-		return searchMatch, nil
+		return searchMatch
 	}
 
 	// Fallback: try to extract table name and do basic select
 	for _, table := range e.schema.Tables {
 		tableName := strings.ToLower(table.Name)
 		if strings.Contains(query, tableName) {
-			return fmt.Sprintf("SELECT * FROM \"%s\".\"%s\" LIMIT 50", table.Schema, table.Name), nil
+			if match := e.findTable(tableName); match != nil {
+				return fmt.Sprintf("SELECT * FROM \"%s\".\"%s\"%s", match.Schema, match.Name, e.limitClause())
+			}
 		}
 	}
 
-	return "", fmt.Errorf("could not understand query: %s", query)
+	return ""
 }
 
 func (e *QueryEngine) matchCountQuery(query string) string {
@@ -106,8 +299,16 @@ func (e *QueryEngine) matchCountQuery(query string) string {
 		`how many (\w+)`,
 	}
 
-	// Check for "all tables" or "each table" pattern
+	// Check for "all tables" or "each table" pattern. Counting every table
+	// can take hours on a big warehouse, so planner estimates are used
+	// unless exact counts are asked for.
 	if strings.Contains(query, "each table") || strings.Contains(query, "all tables") {
+		if len(e.schema.Tables) == 0 {
+			return ""
+		}
+		if !exactCountPattern.MatchString(query) {
+			return rowEstimatesQuery(e.schema.Tables)
+		}
 		var queries []string
 		for _, table := range e.schema.Tables {
 			queries = append(queries, fmt.Sprintf(
@@ -115,9 +316,7 @@ func (e *QueryEngine) matchCountQuery(query string) string {
 				table.Schema, table.Name, table.Schema, table.Name,
 			))
 		}
-		if len(queries) > 0 {
-			return strings.Join(queries, " UNION ALL ") + " ORDER BY row_count DESC"
-		}
+		return strings.Join(queries, " UNION ALL ") + " ORDER BY row_count DESC"
 	}
 
 	for _, pattern := range countPatterns {
@@ -144,7 +343,7 @@ func (e *QueryEngine) matchShowQuery(query string) string {
 	for _, pattern := range showPatterns {
 		re := regexp.MustCompile(pattern)
 		if matches := re.FindStringSubmatch(query); len(matches) > 1 {
-			limit := "50"
+			limit := e.limitClause()
 			tableName := ""
 
 			for i, m := range matches[1:] {
@@ -153,7 +352,7 @@ func (e *QueryEngine) matchShowQuery(query string) string {
 				}
 				// Check if it's a number (limit) or table name
 				if regexp.MustCompile(`^\d+$`).MatchString(m) {
-					limit = m
+					limit = " LIMIT " + m
 				} else if i > 0 || !regexp.MustCompile(`^\d+$`).MatchString(matches[1]) {
 					tableName = m
 				}
@@ -161,7 +360,7 @@ func (e *QueryEngine) matchShowQuery(query string) string {
 
 			if tableName != "" {
 				if table := e.findTable(tableName); table != nil {
-					return fmt.Sprintf("SELECT * FROM \"%s\".\"%s\" LIMIT %s", table.Schema, table.Name, limit)
+					return fmt.Sprintf("SELECT * FROM \"%s\".\"%s\"%s", table.Schema, table.Name, limit)
 				}
 			}
 		}
@@ -171,12 +370,17 @@ func (e *QueryEngine) matchShowQuery(query string) string {
 }
 
 func (e *QueryEngine) matchTableQuery(query string) string {
+	// Largest tables, answered from the catalog without scanning any
+	if strings.Contains(query, "largest") && strings.Contains(query, "table") {
+		return largestTablesSQL
+	}
+
 	// Table structure queries
 	if strings.Contains(query, "tables") && (strings.Contains(query, "list") || strings.Contains(query, "show") || strings.Contains(query, "what")) {
 		return `SELECT table_schema, table_name,
 			(SELECT COUNT(*) FROM information_schema.columns c WHERE c.table_schema = t.table_schema AND c.table_name = t.table_name) as column_count
 			FROM information_schema.tables t
-			WHERE table_type = 'BASE TABLE' AND table_schema NOT IN ('pg_catalog', 'information_schema')
+			WHERE table_type IN ('BASE TABLE', 'FOREIGN') AND table_schema NOT IN ('pg_catalog', 'information_schema')
 			ORDER BY table_schema, table_name`
 	}
 
@@ -201,21 +405,72 @@ func (e *QueryEngine) matchTableQuery(query string) string {
 		}
 	}
 
-	// Largest tables
-	if strings.Contains(query, "largest") && strings.Contains(query, "table") {
-		var queries []string
-		for _, table := range e.schema.Tables {
-			queries = append(queries, fmt.Sprintf(
-				"SELECT '%s.%s' as table_name, COUNT(*) as row_count FROM \"%s\".\"%s\"",
-				table.Schema, table.Name, table.Schema, table.Name,
-			))
+	return ""
+}
+
+// exactCountPattern matches questions insisting on exact row counts
+var exactCountPattern = regexp.MustCompile(`\b(?:exact(?:ly)?|precise(?:ly)?|accurate)\b`)
+
+// rowEstimatesQuery returns one catalog query estimating the row count of
+// every table without scanning any: pg_class.reltuples once analyzed,
+// otherwise the live tuple count from pg_stat_user_tables. Partitioned
+// tables add up their partitions.
+func rowEstimatesQuery(tables []config.TableInfo) string {
+	names := make([]string, len(tables))
+	for i, t := range tables {
+		names[i] = "'" + strings.ReplaceAll(t.Schema+"."+t.Name, "'", "''") + "'"
+	}
+	return `SELECT n.nspname || '.' || c.relname as table_name, est.row_estimate
+			FROM pg_class c
+			JOIN pg_namespace n ON n.oid = c.relnamespace
+			CROSS JOIN LATERAL (
+				SELECT COALESCE(SUM(CASE WHEN l.reltuples >= 0 THEN l.reltuples::bigint ELSE COALESCE(s.n_live_tup, 0) END), 0)::bigint as row_estimate
+				FROM pg_partition_tree(c.oid) pt
+				JOIN pg_class l ON l.oid = pt.relid
+				LEFT JOIN pg_stat_user_tables s ON s.relid = l.oid
+				WHERE pt.isleaf
+			) est
+			WHERE c.relkind IN ('r', 'p')
+			  AND n.nspname || '.' || c.relname IN (` + strings.Join(names, ", ") + `)
+			ORDER BY est.row_estimate DESC`
+}
+
+// largestTablesSQL lists the ten largest tables by total size with their
+// row estimates; partitioned tables add up their partitions
+const largestTablesSQL = `SELECT n.nspname || '.' || c.relname as table_name,
+			sizes.row_estimate,
+			pg_size_pretty(sizes.total_bytes) as total_size
+			FROM pg_class c
+			JOIN pg_namespace n ON n.oid = c.relnamespace
+			CROSS JOIN LATERAL (
+				SELECT COALESCE(SUM(GREATEST(l.reltuples, 0)), 0)::bigint as row_estimate,
+					COALESCE(SUM(pg_total_relation_size(l.oid)), 0) as total_bytes
+				FROM pg_partition_tree(c.oid) pt
+				JOIN pg_class l ON l.oid = pt.relid
+				WHERE pt.isleaf
+			) sizes
+			WHERE c.relkind IN ('r', 'p') AND NOT c.relispartition
+			  AND n.nspname NOT IN ('pg_catalog', 'information_schema')
+			  AND n.nspname NOT LIKE 'pg_toast%'
+			ORDER BY sizes.total_bytes DESC
+			LIMIT 10`
+
+// pickIndexedGeometry returns the first table/geometry column backed by a
+// spatial index, falling back to the first geometry column found
+func pickIndexedGeometry(tables []config.TableInfo) (config.TableInfo, string) {
+	for _, table := range tables {
+		for _, col := range table.Columns {
+			if col.IsGeometry && table.HasSpatialIndex(col.Name) {
+				return table, col.Name
+			}
 		}
-		if len(queries) > 0 {
-			return strings.Join(queries, " UNION ALL ") + " ORDER BY row_count DESC LIMIT 10"
+	}
+	for _, col := range tables[0].Columns {
+		if col.IsGeometry {
+			return tables[0], col.Name
 		}
 	}
-
-	return ""
+	return tables[0], ""
 }
 
 func (e *QueryEngine) matchSpatialQuery(query string) string {
@@ -234,42 +489,6 @@ func (e *QueryEngine) matchSpatialQuery(query string) string {
 		return ""
 	}
 
-	// Distance queries
-	distancePatterns := []string{
-		`within (\d+(?:\.\d+)?)\s*(?:km|kilometers?|m|meters?|mi|miles?)`,
-		`(\d+(?:\.\d+)?)\s*(?:km|kilometers?|m|meters?|mi|miles?) (?:from|of|away)`,
-	}
-
-	for _, pattern := range distancePatterns {
-		re := regexp.MustCompile(pattern)
-		if matches := re.FindStringSubmatch(query); len(matches) > 1 {
-			// Simple spatial query template
-			table := geomTables[0]
-			geomCol := ""
-			for _, col := range table.Columns {
-				if col.IsGeometry {
-					geomCol = col.Name
-					break
-				}
-			}
-
-			if geomCol != "" {
-				// Convert distance to meters
-				dist := matches[1]
-				distMeters := dist
-				if strings.Contains(query, "km") || strings.Contains(query, "kilometer") {
-					distMeters = dist + " * 1000"
-				} else if strings.Contains(query, "mi") || strings.Contains(query, "mile") {
-					distMeters = dist + " * 1609.34"
-				}
-
-				return fmt.Sprintf(`SELECT * FROM "%s"."%s"
-					WHERE ST_DWithin("%s"::geography, ST_MakePoint(0, 0)::geography, %s)
-					LIMIT 50`, table.Schema, table.Name, geomCol, distMeters)
-			}
-		}
-	}
-
 	// Area queries
 	if strings.Contains(query, "area") || strings.Contains(query, "size") {
 		for _, table := range geomTables {
@@ -278,7 +497,7 @@ func (e *QueryEngine) matchSpatialQuery(query string) string {
 					return fmt.Sprintf(`SELECT *, ST_Area("%s"::geography) as area_sqm
 						FROM "%s"."%s"
 						ORDER BY ST_Area("%s"::geography) DESC
-						LIMIT 50`, col.Name, table.Schema, table.Name, col.Name)
+						LIMIT %d`, col.Name, table.Schema, table.Name, col.Name, e.rowLimit())
 				}
 			}
 		}
@@ -296,7 +515,7 @@ func (e *QueryEngine) matchSpatialQuery(query string) string {
 					return fmt.Sprintf(`SELECT *, ST_Length("%s"::geography) as length_meters
 						FROM "%s"."%s"
 						ORDER BY ST_Length("%s"::geography) DESC
-						LIMIT 50`, col.Name, table.Schema, table.Name, col.Name)
+						LIMIT %d`, col.Name, table.Schema, table.Name, col.Name, e.rowLimit())
 				}
 			}
 		}
@@ -305,6 +524,52 @@ func (e *QueryEngine) matchSpatialQuery(query string) string {
 	return ""
 }
 
+// matchValueFilterQuery filters a table mentioned in the query by a column
+// value, documented as accepted or from the harvested statistics, that
+// also appears in the query. When
+// several columns hold the value, one whose name is also mentioned wins.
+func (e *QueryEngine) matchValueFilterQuery(query string) string {
+	padded := padWords(query)
+	mentions := func(name string) bool {
+		return strings.Contains(padded, " "+strings.ToLower(name)+" ")
+	}
+
+	for _, table := range e.schema.Tables {
+		if tableMention(padded, table.Name) < 0 {
+			continue
+		}
+
+		column, value := "", ""
+		for _, c := range table.Columns {
+			values := c.AcceptedValues
+			if c.Stats != nil {
+				values = append(values[:len(values):len(values)], c.Stats.CommonValues...)
+			}
+			for _, v := range values {
+				// Short and numeric values match too easily by accident
+				if len(v) < 3 || regexp.MustCompile(`^[\d.\-]+$`).MatchString(v) || !mentions(v) {
+					continue
+				}
+				if column == "" || mentions(c.Name) {
+					column, value = c.Name, v
+				}
+				break
+			}
+		}
+		if column == "" {
+			continue
+		}
+
+		where := fmt.Sprintf("\"%s\" = '%s'", column, strings.ReplaceAll(value, "'", "''"))
+		if strings.Contains(query, "how many") || strings.HasPrefix(query, "count") {
+			return fmt.Sprintf("SELECT COUNT(*) as count FROM \"%s\".\"%s\" WHERE %s", table.Schema, table.Name, where)
+		}
+		return fmt.Sprintf("SELECT * FROM \"%s\".\"%s\" WHERE %s%s", table.Schema, table.Name, where, e.limitClause())
+	}
+
+	return ""
+}
+
 func (e *QueryEngine) matchSelectQuery(query string) string {
 	selectPatterns := []string{
 		`select (?:all )?(?:from )?(\w+)`,
@@ -317,7 +582,7 @@ func (e *QueryEngine) matchSelectQuery(query string) string {
 		if matches := re.FindStringSubmatch(query); len(matches) > 1 {
 			tableName := matches[1]
 			if table := e.findTable(tableName); table != nil {
-				return fmt.Sprintf("SELECT * FROM \"%s\".\"%s\" LIMIT 50", table.Schema, table.Name)
+				return fmt.Sprintf("SELECT * FROM \"%s\".\"%s\"%s", table.Schema, table.Name, e.limitClause())
 			}
 		}
 	}
@@ -326,12 +591,14 @@ func (e *QueryEngine) matchSelectQuery(query string) string {
 }
 
 // SemanticMatcher provides intelligent fuzzy matching between keywords and database entities
-type SemanticMatcher struct{}
+type SemanticMatcher struct {
+	glossary Glossary // Consulted before a keyword is matched fuzzily
+}
 
 // MatchScore represents how well a keyword matches an entity name
 type MatchScore struct {
 	Score      float64
-	MatchType  string // "exact", "contains", "prefix", "suffix", "stem", "fuzzy", "ngram"
+	MatchType  string // "exact", "contains", "prefix", "suffix", "stem", "fuzzy", "ngram", or "synonym_" and one of them
 	Keyword    string
 	EntityName string
 }
@@ -489,8 +756,23 @@ func splitEntityName(name string) []string {
 	return result
 }
 
-// calculateMatchScore calculates how well a keyword matches an entity name
+// calculateMatchScore calculates how well a keyword matches an entity name.
+// A keyword the glossary defines is matched as the schema word it stands
+// for first, which wins unless the keyword itself matches better.
 func (sm *SemanticMatcher) calculateMatchScore(keyword, entityName string) MatchScore {
+	score := sm.matchScore(keyword, entityName)
+	if term, ok := sm.glossary.Lookup(keyword); ok {
+		if synonym := sm.matchScore(term, entityName); synonym.Score > 0 && synonym.Score >= score.Score {
+			synonym.MatchType = "synonym_" + synonym.MatchType
+			synonym.Keyword = strings.ToLower(keyword)
+			return synonym
+		}
+	}
+	return score
+}
+
+// matchScore calculates how well a keyword matches an entity name as it is
+func (sm *SemanticMatcher) matchScore(keyword, entityName string) MatchScore {
 	keyword = strings.ToLower(keyword)
 	entityLower := strings.ToLower(entityName)
 
@@ -606,7 +888,7 @@ func (e *QueryEngine) findSemanticMatches(keywords []string) []struct {
 	MatchedOn string
 	Keyword   string
 } {
-	matcher := &SemanticMatcher{}
+	matcher := &SemanticMatcher{glossary: e.vocabulary()}
 	var matches []struct {
 		Table     config.TableInfo
 		Score     float64
@@ -618,6 +900,9 @@ func (e *QueryEngine) findSemanticMatches(keywords []string) []struct {
 	// Minimum score threshold for a match
 	const minScore = 0.35
 
+	// Matches by meaning ("clients" -> customers) when embeddings are loaded
+	semantic := e.semanticMatches(keywords)
+
 	for _, table := range e.schema.Tables {
 		var bestTableScore float64
 		var bestMatch struct {
@@ -676,6 +961,16 @@ func (e *QueryEngine) findSemanticMatches(keywords []string) []struct {
 			}
 		}
 
+		if s, ok := semantic[table.Schema+"."+table.Name]; ok && s.Score > bestTableScore {
+			bestTableScore = s.Score
+			bestMatch = struct {
+				Score     float64
+				MatchType string
+				MatchedOn string
+				Keyword   string
+			}{s.Score, s.MatchType, s.MatchedOn, s.Keyword}
+		}
+
 		if bestTableScore >= minScore {
 			matches = append(matches, struct {
 				Table     config.TableInfo
@@ -699,6 +994,16 @@ func (e *QueryEngine) findSemanticMatches(keywords []string) []struct {
 	return matches
 }
 
+// searchStopWords are left out of the keywords tables are matched against
+var searchStopWords = map[string]bool{
+	"the": true, "a": true, "an": true, "any": true, "some": true,
+	"data": true, "table": true, "tables": true, "related": true,
+	"information": true, "do": true, "i": true, "have": true, "is": true,
+	"there": true, "are": true, "find": true, "search": true, "for": true,
+	"look": true, "what": true, "which": true, "contain": true, "about": true,
+	"include": true, "with": true, "my": true, "in": true, "to": true,
+}
+
 func (e *QueryEngine) matchSearchQuery(query string) string {
 	// Check for search/find/have patterns with keywords
 	searchPatterns := []string{
@@ -707,16 +1012,6 @@ func (e *QueryEngine) matchSearchQuery(query string) string {
 		`(.+?)(?:\s+related)?\s+(?:tables?|data)`,
 	}
 
-	// Common words to exclude from keyword extraction
-	excludeWords := map[string]bool{
-		"the": true, "a": true, "an": true, "any": true, "some": true,
-		"data": true, "table": true, "tables": true, "related": true,
-		"information": true, "do": true, "i": true, "have": true, "is": true,
-		"there": true, "are": true, "find": true, "search": true, "for": true,
-		"look": true, "what": true, "which": true, "contain": true, "about": true,
-		"include": true, "with": true, "my": true, "in": true, "to": true,
-	}
-
 	// Extract keywords from query
 	var keywords []string
 	for _, pattern := range searchPatterns {
@@ -726,7 +1021,7 @@ func (e *QueryEngine) matchSearchQuery(query string) string {
 			words := strings.Fields(matches[1])
 			for _, word := range words {
 				word = strings.ToLower(strings.Trim(word, ".,?!"))
-				if len(word) > 2 && !excludeWords[word] {
+				if len(word) > 2 && !searchStopWords[word] {
 					keywords = append(keywords, word)
 				}
 			}
@@ -739,7 +1034,7 @@ func (e *QueryEngine) matchSearchQuery(query string) string {
 		words := strings.Fields(query)
 		for _, word := range words {
 			word = strings.ToLower(strings.Trim(word, ".,?!"))
-			if len(word) > 3 && !excludeWords[word] {
+			if len(word) > 3 && !searchStopWords[word] {
 				keywords = append(keywords, word)
 			}
 		}
@@ -758,14 +1053,14 @@ func (e *QueryEngine) matchSearchQuery(query string) string {
 			(SELECT string_agg(column_name, ', ') FROM information_schema.columns c
 			 WHERE c.table_schema = t.table_schema AND c.table_name = t.table_name) as columns
 			FROM information_schema.tables t
-			WHERE table_type = 'BASE TABLE' AND table_schema NOT IN ('pg_catalog', 'information_schema')
+			WHERE table_type IN ('BASE TABLE', 'FOREIGN') AND table_schema NOT IN ('pg_catalog', 'information_schema')
 			ORDER BY table_schema, table_name`
 	}
 
 	// If only one table matches with high confidence, show its data
 	if len(matches) == 1 {
 		t := matches[0].Table
-		return fmt.Sprintf("SELECT * FROM \"%s\".\"%s\" LIMIT 50", t.Schema, t.Name)
+		return fmt.Sprintf("SELECT * FROM \"%s\".\"%s\"%s", t.Schema, t.Name, e.limitClause())
 	}
 
 	// Check if top matches have similar scores (within 0.2 of each other)
@@ -829,7 +1124,7 @@ func (e *QueryEngine) matchSearchQuery(query string) string {
 	// Single high-confidence match
 	if matches[0].Score > 0.6 {
 		t := matches[0].Table
-		return fmt.Sprintf("SELECT * FROM \"%s\".\"%s\" LIMIT 50", t.Schema, t.Name)
+		return fmt.Sprintf("SELECT * FROM \"%s\".\"%s\"%s", t.Schema, t.Name, e.limitClause())
 	}
 
 	// Lower confidence matches - show a summary of what was found
@@ -903,7 +1198,56 @@ func (e *QueryEngine) findCommonColumns(matches []struct {
 	return result
 }
 
+// findTable finds the table a word in a question refers to. When the
+// table's name exists in several schemas the chosen or default schema wins;
+// without one the first is returned and the ambiguity recorded.
 func (e *QueryEngine) findTable(name string) *config.TableInfo {
+	table := e.matchTableName(name)
+	if table == nil {
+		return nil
+	}
+
+	var candidates []config.TableInfo
+	for _, t := range e.schema.Tables {
+		if strings.EqualFold(t.Name, table.Name) {
+			candidates = append(candidates, t)
+		}
+	}
+	if len(candidates) < 2 {
+		return table
+	}
+
+	preferred := e.defaultSchema
+	if chosen, ok := e.schemaChoices[strings.ToLower(table.Name)]; ok {
+		preferred = chosen
+	}
+	var schemas []string
+	for i, t := range candidates {
+		if t.Schema == preferred {
+			return &candidates[i]
+		}
+		schemas = append(schemas, t.Schema)
+	}
+	if e.ambiguous == nil {
+		e.ambiguous = &AmbiguousTableError{Table: table.Name, Schemas: schemas}
+	}
+	return table
+}
+
+// matchTableName finds the first table whose name matches a word exactly,
+// contains it, or matches it once plurals are stripped. A word the glossary
+// or a correction defines is looked for as the word it stands for first.
+func (e *QueryEngine) matchTableName(name string) *config.TableInfo {
+	if term, ok := e.vocabulary().Lookup(name); ok {
+		if table := e.matchTableWord(term); table != nil {
+			return table
+		}
+	}
+	return e.matchTableWord(name)
+}
+
+// matchTableWord finds the first table matching a word as it is
+func (e *QueryEngine) matchTableWord(name string) *config.TableInfo {
 	name = strings.ToLower(name)
 
 	// Exact match
@@ -934,7 +1278,109 @@ func (e *QueryEngine) findTable(name string) *config.TableInfo {
 
 // SetSchema updates the schema for the query engine
 func (e *QueryEngine) SetSchema(schema *config.SchemaCache) {
-	e.schema = schema
+	e.schema = schema.WithoutPartitions().Readable()
+}
+
+// SetEmbedder sets the embedder semantic schema search embeds questions with
+func (e *QueryEngine) SetEmbedder(embedder Embedder) {
+	e.embedder = embedder
+}
+
+// SetSemanticIndex sets the schema embeddings questions are matched against;
+// it must come from BuildSemanticIndex with the engine's embedder
+func (e *QueryEngine) SetSemanticIndex(idx *SemanticIndex) {
+	e.index = idx
+}
+
+// SetExampleStore sets the earlier questions retrieved as examples for
+// the provider; nil disables examples
+func (e *QueryEngine) SetExampleStore(store *ExampleStore) {
+	e.examples = store
+}
+
+// AddExample records the SQL that answered a question as an example for
+// later questions, when an example store is set
+func (e *QueryEngine) AddExample(question, sql string) {
+	if e.examples != nil {
+		e.examples.Add(question, sql)
+	}
+}
+
+// SetRowLimit sets the LIMIT of generated row queries; 0 restores the default
+func (e *QueryEngine) SetRowLimit(limit int) {
+	e.limit = limit
+}
+
+// rowLimit returns the LIMIT of generated row queries
+func (e *QueryEngine) rowLimit() int {
+	if e.limit > 0 {
+		return e.limit
+	}
+	return defaultRowLimit
+}
+
+// SetAutoLimit sets whether generated queries listing rows get the row
+// limit. Off, only questions asking for a number of rows or the top ones
+// are limited.
+func (e *QueryEngine) SetAutoLimit(enabled bool) {
+	e.noAutoLimit = !enabled
+}
+
+// limitClause returns the " LIMIT n" ending generated row queries, or ""
+// when auto-limiting is off
+func (e *QueryEngine) limitClause() string {
+	if e.noAutoLimit {
+		return ""
+	}
+	return fmt.Sprintf(" LIMIT %d", e.rowLimit())
+}
+
+// limitRows adds the row limit to SQL a provider or the neural network
+// generated when it lists rows without a limit of its own
+func (e *QueryEngine) limitRows(sql string) string {
+	if e.noAutoLimit {
+		return sql
+	}
+	limited, _ := postgres.InjectLimit(sql, e.rowLimit())
+	return limited
+}
+
+// SetDefaultSchema sets the schema preferred when a table name exists in
+// several schemas, e.g. from the service's default_schema option
+func (e *QueryEngine) SetDefaultSchema(schema string) {
+	e.defaultSchema = schema
+}
+
+// ChooseSchema remembers which schema a table name found in several
+// schemas refers to, overriding the default schema
+func (e *QueryEngine) ChooseSchema(table, schema string) {
+	choices := make(map[string]string, len(e.schemaChoices)+1)
+	for t, s := range e.schemaChoices {
+		choices[t] = s
+	}
+	choices[strings.ToLower(table)] = schema
+	e.schemaChoices = choices
+}
+
+// SetProvider sets the LLM provider used before falling back to the rule engine
+func (e *QueryEngine) SetProvider(p LLMProvider) {
+	e.provider = p
+}
+
+// SetDB sets the live connection used by provider schema tools
+func (e *QueryEngine) SetDB(db *sql.DB) {
+	e.db = db
+}
+
+// SetStatementTimeout sets the statement timeout of the queries the engine
+// runs on its connection; 0 for none
+func (e *QueryEngine) SetStatementTimeout(d time.Duration) {
+	e.timeout = d
+}
+
+// Provider returns the configured LLM provider (nil when rule-based only)
+func (e *QueryEngine) Provider() LLMProvider {
+	return e.provider
 }
 
 // TrainFromHistory trains the neural network from query history
@@ -964,6 +1410,12 @@ func (e *QueryEngine) IsNNTrained() bool {
 	return e.nnTrainer.IsTrained()
 }
 
+// NNNeedsRetraining reports whether the saved neural network could not be
+// loaded, as when an older version wrote it, and should be trained again
+func (e *QueryEngine) NNNeedsRetraining() bool {
+	return e.nnTrainer != nil && e.nnTrainer.NeedsRetraining()
+}
+
 // SetUseNN enables or disables neural network predictions
 func (e *QueryEngine) SetUseNN(use bool) {
 	e.useNN = use
@@ -1020,7 +1472,7 @@ func (e *QueryEngine) GetSchemaContext() string {
 	if e.schema == nil {
 		return ""
 	}
-	return generateSchemaDescription(e.schema)
+	return generateSchemaDescription(e.schema) + e.vocabulary().describe()
 }
 
 func generateSchemaDescription(cache *config.SchemaCache) string {
@@ -1032,24 +1484,27 @@ func generateSchemaDescription(cache *config.SchemaCache) string {
 	if cache.HasPostGIS {
 		desc.WriteString("PostGIS is installed - spatial queries are supported.\n\n")
 	}
+	if cache.HasRaster {
+		desc.WriteString("PostGIS raster is installed - use ST_SummaryStats, ST_Value and ST_Clip on raster columns.\n\n")
+	}
+	if cache.HasPointCloud {
+		desc.WriteString("pgPointCloud is installed - use PC_NumPoints, PC_Explode and PC_Get on point cloud columns.\n\n")
+	}
+	if cache.HasPgRouting {
+		desc.WriteString("pgRouting is installed - find routes with pgr_dijkstra over tables with source, target and cost columns.\n\n")
+	}
 
 	desc.WriteString("TABLES:\n")
 	for _, t := range cache.Tables {
-		desc.WriteString(fmt.Sprintf("- %s.%s", t.Schema, t.Name))
-		if t.Comment != "" {
-			desc.WriteString(fmt.Sprintf(" (%s)", t.Comment))
-		}
-		desc.WriteString("\n")
-		for _, c := range t.Columns {
-			desc.WriteString(fmt.Sprintf("    - %s (%s)", c.Name, c.DataType))
-			if c.IsPrimaryKey {
-				desc.WriteString(" [PK]")
-			}
-			if c.IsForeignKey {
-				desc.WriteString(fmt.Sprintf(" [FK -> %s.%s]", c.FKTable, c.FKColumn))
-			}
-			if c.IsGeometry {
-				desc.WriteString(fmt.Sprintf(" [GEOMETRY: %s]", c.GeomType))
+		desc.WriteString(describeTable(t))
+	}
+
+	if len(cache.Sequences) > 0 {
+		desc.WriteString("SEQUENCES:\n")
+		for _, seq := range cache.Sequences {
+			desc.WriteString(fmt.Sprintf("- %s.%s", seq.Schema, seq.Name))
+			if seq.OwnedBy != "" {
+				desc.WriteString(fmt.Sprintf(" (owned by %s)", seq.OwnedBy))
 			}
 			desc.WriteString("\n")
 		}
@@ -1058,3 +1513,83 @@ func generateSchemaDescription(cache *config.SchemaCache) string {
 
 	return desc.String()
 }
+
+// describeTable returns a table's entry in the schema description: its
+// columns, indexes and constraints followed by a blank line
+func describeTable(t config.TableInfo) string {
+	var desc strings.Builder
+	desc.WriteString(fmt.Sprintf("- %s.%s", t.Schema, t.Name))
+	if t.Comment != "" {
+		desc.WriteString(fmt.Sprintf(" (%s)", t.Comment))
+	}
+	if t.PartitionKey != "" {
+		desc.WriteString(fmt.Sprintf(" [PARTITIONED BY %s - query this table, not its partitions]", t.PartitionKey))
+	}
+	if summary := t.Size.Summary(); summary != "" {
+		desc.WriteString(fmt.Sprintf(" [%s]", summary))
+	}
+	desc.WriteString("\n")
+	for _, c := range t.Columns {
+		desc.WriteString(fmt.Sprintf("    - %s (%s)", c.Name, c.DataType))
+		if c.IsPrimaryKey {
+			desc.WriteString(" [PK]")
+		}
+		if c.IsForeignKey {
+			desc.WriteString(fmt.Sprintf(" [FK -> %s.%s]", c.FKTable, c.FKColumn))
+		}
+		if c.IsGeometry {
+			desc.WriteString(fmt.Sprintf(" [GEOMETRY: %s]", c.GeomType))
+		}
+		if c.IsRaster {
+			desc.WriteString(fmt.Sprintf(" [RASTER: %d bands, SRID %d]", c.RasterBands, c.SRID))
+		}
+		if c.IsPointCloud {
+			desc.WriteString(fmt.Sprintf(" [POINT CLOUD: SRID %d - PC_NumPoints counts a patch's points]", c.SRID))
+		}
+		if c.IsVector {
+			desc.WriteString(fmt.Sprintf(" [VECTOR(%d) - ORDER BY %s %s '[...]' for similarity]", c.VectorDims, c.Name, vectorOperator(t, c.Name)))
+		}
+		if c.DataType == "jsonb" {
+			desc.WriteString(" [JSON - use ->> / #>> for keys, @> for containment]")
+		} else if c.DataType == "json" {
+			desc.WriteString(" [JSON - use ->> / #>> for keys; cast to jsonb for @>]")
+		}
+		if len(c.AcceptedValues) > 0 {
+			desc.WriteString(fmt.Sprintf(" [values: %s]", strings.Join(c.AcceptedValues, ", ")))
+		}
+		if summary := c.Stats.Summary(); summary != "" {
+			desc.WriteString(fmt.Sprintf(" [%s]", summary))
+		}
+		if c.Comment != "" {
+			desc.WriteString(" -- " + c.Comment)
+		}
+		desc.WriteString("\n")
+	}
+	for _, idx := range t.Indexes {
+		if !idx.IsPrimary {
+			desc.WriteString("    " + describeIndex(idx) + "\n")
+		}
+	}
+	for _, con := range t.Constraints {
+		desc.WriteString(fmt.Sprintf("    %s %s\n", con.Type, con.Definition))
+	}
+	desc.WriteString("\n")
+	return desc.String()
+}
+
+// describeIndex returns a one-line summary of an index for the schema description
+func describeIndex(idx config.IndexInfo) string {
+	if len(idx.Columns) == 0 {
+		// Expression index: the definition is the only useful description
+		return "INDEX " + idx.Definition
+	}
+	kind := "INDEX"
+	if idx.IsUnique {
+		kind = "UNIQUE INDEX"
+	}
+	desc := fmt.Sprintf("%s %s (%s on %s)", kind, idx.Name, idx.Method, strings.Join(idx.Columns, ", "))
+	if idx.IsSpatial() {
+		desc += " [SPATIAL - use && / ST_DWithin / ST_Intersects on the raw column]"
+	}
+	return desc
+}
@@ -1,6 +1,7 @@
 package llm
 
 import (
+	"errors"
 	"strings"
 	"testing"
 
@@ -90,18 +91,40 @@ func TestGenerateSQLAllTablesCount(t *testing.T) {
 		t.Fatalf("query failed: %v", err)
 	}
 
-	// Should have UNION ALL for each table
-	if !strings.Contains(sql, "UNION ALL") {
-		t.Error("expected UNION ALL in SQL")
+	// Estimated from the catalog in one query by default
+	if strings.Contains(sql, "COUNT(*)") || !strings.Contains(sql, "reltuples") {
+		t.Errorf("expected a catalog estimate, got %s", sql)
 	}
 
-	if !strings.Contains(sql, "public.users") {
+	if !strings.Contains(sql, "'public.users'") {
 		t.Error("expected public.users in SQL")
 	}
 
-	if !strings.Contains(sql, "public.orders") {
+	if !strings.Contains(sql, "'public.orders'") {
 		t.Error("expected public.orders in SQL")
 	}
+
+	// Exact counts scan each table
+	sql, err = engine.GenerateSQL("exact number of records in all tables", "")
+	if err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	if !strings.Contains(sql, "UNION ALL") || !strings.Contains(sql, `COUNT(*) as row_count FROM "public"."orders"`) {
+		t.Errorf("expected exact counts, got %s", sql)
+	}
+}
+
+func TestLargestTablesFromCatalog(t *testing.T) {
+	schema := &config.SchemaCache{
+		Tables: []config.TableInfo{{Schema: "public", Name: "users"}},
+	}
+	engine := NewQueryEngine(schema)
+	engine.useNN = false
+
+	sql, err := engine.GenerateSQL("what are the largest tables", "")
+	if err != nil || !strings.Contains(sql, "pg_total_relation_size") || strings.Contains(sql, "COUNT(*)") {
+		t.Errorf("largest tables should come from the catalog: %q (%v)", sql, err)
+	}
 }
 
 func TestGenerateSQLShowQuery(t *testing.T) {
@@ -259,6 +282,127 @@ func TestFindTable(t *testing.T) {
 	}
 }
 
+func TestTableInSeveralSchemas(t *testing.T) {
+	schema := &config.SchemaCache{
+		Tables: []config.TableInfo{
+			{Schema: "public", Name: "parcels"},
+			{Schema: "staging", Name: "parcels"},
+			{Schema: "public", Name: "roads"},
+		},
+	}
+	engine := NewQueryEngine(schema)
+	engine.useNN = false
+
+	_, err := engine.GenerateSQL("show parcels", "")
+	var ambiguous *AmbiguousTableError
+	if !errors.As(err, &ambiguous) {
+		t.Fatalf("expected AmbiguousTableError, got %v", err)
+	}
+	if ambiguous.Table != "parcels" || strings.Join(ambiguous.Schemas, ",") != "public,staging" {
+		t.Errorf("unexpected ambiguity: %+v", ambiguous)
+	}
+
+	// Tables in one schema are unaffected
+	if sql, err := engine.GenerateSQL("show roads", ""); err != nil || !strings.Contains(sql, `"public"."roads"`) {
+		t.Errorf("show roads: %q, %v", sql, err)
+	}
+
+	engine.SetDefaultSchema("staging")
+	sql, err := engine.GenerateSQL("show parcels", "")
+	if err != nil || !strings.Contains(sql, `"staging"."parcels"`) {
+		t.Errorf("default schema: %q, %v", sql, err)
+	}
+
+	// A choice made for the table overrides the default
+	engine.ChooseSchema("parcels", "public")
+	sql, err = engine.GenerateSQL("how many parcels", "")
+	if err != nil || !strings.Contains(sql, `"public"."parcels"`) {
+		t.Errorf("chosen schema: %q, %v", sql, err)
+	}
+}
+
+func TestSetRowLimit(t *testing.T) {
+	schema := &config.SchemaCache{
+		Tables: []config.TableInfo{{Schema: "public", Name: "users"}},
+	}
+	engine := NewQueryEngine(schema)
+	engine.useNN = false
+
+	engine.SetRowLimit(20)
+	if sql, err := engine.GenerateSQL("show users", ""); err != nil || !strings.HasSuffix(sql, "LIMIT 20") {
+		t.Errorf("expected LIMIT 20, got %q (%v)", sql, err)
+	}
+
+	engine.SetRowLimit(0)
+	if sql, err := engine.GenerateSQL("show users", ""); err != nil || !strings.HasSuffix(sql, "LIMIT 50") {
+		t.Errorf("expected the default LIMIT 50, got %q (%v)", sql, err)
+	}
+}
+
+func TestAutoLimit(t *testing.T) {
+	schema := &config.SchemaCache{
+		Tables: []config.TableInfo{{Schema: "public", Name: "users"}},
+	}
+	engine := NewQueryEngine(schema)
+	engine.useNN = false
+
+	engine.SetProvider(&staticProvider{sql: `SELECT name FROM "public"."users" ORDER BY name`})
+	if sql, _ := engine.GenerateSQL("users by name", ""); sql != `SELECT name FROM "public"."users" ORDER BY name LIMIT 50` {
+		t.Errorf("expected the row limit added to provider SQL, got %q", sql)
+	}
+	engine.SetProvider(&staticProvider{sql: `SELECT name FROM "public"."users" FETCH FIRST 3 ROWS ONLY`})
+	if sql, _ := engine.GenerateSQL("three users", ""); sql != `SELECT name FROM "public"."users" FETCH FIRST 3 ROWS ONLY` {
+		t.Errorf("provider SQL with a row limit should be left alone, got %q", sql)
+	}
+
+	engine.SetAutoLimit(false)
+	engine.SetProvider(nil)
+	if sql, err := engine.GenerateSQL("show users", ""); err != nil || sql != `SELECT * FROM "public"."users"` {
+		t.Errorf("expected no LIMIT with auto-limiting off, got %q (%v)", sql, err)
+	}
+	if sql, err := engine.GenerateSQL("show 5 users", ""); err != nil || !strings.HasSuffix(sql, "LIMIT 5") {
+		t.Errorf("a number of rows asked for should still limit them, got %q (%v)", sql, err)
+	}
+}
+
+func TestPartitionsResolveToParent(t *testing.T) {
+	schema := &config.SchemaCache{
+		Tables: []config.TableInfo{
+			{Schema: "public", Name: "measurement_y2024", PartitionOf: "public.measurements"},
+			{Schema: "public", Name: "measurements", PartitionKey: "RANGE (taken_at)"},
+		},
+	}
+	engine := NewQueryEngine(schema)
+	engine.useNN = false
+
+	sql, err := engine.GenerateSQL("how many measurement", "")
+	if err != nil || !strings.Contains(sql, `"public"."measurements"`) {
+		t.Errorf("expected the partitioned parent, got %q (%v)", sql, err)
+	}
+	if desc := engine.GetSchemaContext(); strings.Contains(desc, "measurement_y2024") || !strings.Contains(desc, "PARTITIONED BY RANGE (taken_at)") {
+		t.Errorf("schema context should describe the parent only:\n%s", desc)
+	}
+}
+
+func TestUnreadableTablesLeftOut(t *testing.T) {
+	schema := &config.SchemaCache{
+		Tables: []config.TableInfo{
+			{Schema: "hr", Name: "salaries", Privileges: &config.TablePrivileges{Insert: true}},
+			{Schema: "public", Name: "salary_bands", Privileges: &config.TablePrivileges{Select: true}},
+		},
+	}
+	engine := NewQueryEngine(schema)
+	engine.useNN = false
+
+	sql, err := engine.GenerateSQL("how many salaries", "")
+	if err != nil || strings.Contains(sql, `"hr"."salaries"`) {
+		t.Errorf("expected no SQL against the unreadable table, got %q (%v)", sql, err)
+	}
+	if desc := engine.GetSchemaContext(); strings.Contains(desc, "hr.salaries") || !strings.Contains(desc, "public.salary_bands") {
+		t.Errorf("schema context should leave out the unreadable table:\n%s", desc)
+	}
+}
+
 func TestSetSchema(t *testing.T) {
 	engine := NewQueryEngine(nil)
 
@@ -316,3 +460,233 @@ func TestGetSchemaContext(t *testing.T) {
 		t.Error("expected [PK] marker in context")
 	}
 }
+
+func TestSchemaDescriptionIncludesIndexes(t *testing.T) {
+	schema := &config.SchemaCache{
+		Tables: []config.TableInfo{
+			{
+				Schema: "public",
+				Name:   "parcels",
+				Columns: []config.ColumnInfo{
+					{Name: "id", DataType: "integer", IsPrimaryKey: true},
+					{Name: "geom", DataType: "USER-DEFINED", IsGeometry: true, GeomType: "POLYGON"},
+				},
+				Indexes: []config.IndexInfo{
+					{Name: "parcels_pkey", Columns: []string{"id"}, Method: "btree", IsPrimary: true, IsUnique: true},
+					{Name: "parcels_geom_idx", Columns: []string{"geom"}, Method: "gist"},
+				},
+				Constraints: []config.ConstraintInfo{
+					{Name: "parcels_area_check", Type: "CHECK", Definition: "CHECK (area > 0)"},
+				},
+			},
+		},
+		Sequences: []config.SequenceInfo{
+			{Schema: "public", Name: "parcels_id_seq", DataType: "integer", OwnedBy: "public.parcels.id"},
+		},
+	}
+
+	desc := NewQueryEngine(schema).GetSchemaContext()
+
+	for _, want := range []string{
+		"INDEX parcels_geom_idx (gist on geom) [SPATIAL",
+		"CHECK CHECK (area > 0)",
+		"public.parcels_id_seq (owned by public.parcels.id)",
+	} {
+		if !strings.Contains(desc, want) {
+			t.Errorf("expected schema description to contain %q:\n%s", want, desc)
+		}
+	}
+	if strings.Contains(desc, "parcels_pkey") {
+		t.Error("primary key index should not be listed separately")
+	}
+}
+
+func TestSpatialQueryPrefersIndexedGeometry(t *testing.T) {
+	schema := &config.SchemaCache{
+		HasPostGIS: true,
+		Tables: []config.TableInfo{
+			{Schema: "public", Name: "a_unindexed", Columns: []config.ColumnInfo{
+				{Name: "geom", IsGeometry: true, GeomType: "POINT"},
+			}},
+			{Schema: "public", Name: "b_indexed", Columns: []config.ColumnInfo{
+				{Name: "the_geom", IsGeometry: true, GeomType: "POINT"},
+			}, Indexes: []config.IndexInfo{
+				{Name: "b_geom_idx", Columns: []string{"the_geom"}, Method: "gist"},
+			}},
+		},
+	}
+
+	engine := NewQueryEngine(schema)
+	sql := engine.matchDistanceQuery("features within 5 km")
+	if !strings.Contains(sql, `"b_indexed"`) || !strings.Contains(sql, `"the_geom"`) {
+		t.Errorf("expected indexed table to be used, got %s", sql)
+	}
+}
+
+func TestValueFilterQueryUsesColumnStats(t *testing.T) {
+	schema := &config.SchemaCache{
+		Tables: []config.TableInfo{
+			{Schema: "public", Name: "orders", Columns: []config.ColumnInfo{
+				{Name: "id", DataType: "integer", IsPrimaryKey: true},
+				{Name: "status", DataType: "text", Stats: &config.ColumnStats{
+					CommonValues: []string{"pending", "shipped", "cancelled"},
+				}},
+			}},
+		},
+	}
+
+	engine := NewQueryEngine(schema)
+	engine.SetUseNN(false)
+
+	tests := []struct {
+		query    string
+		expected string
+	}{
+		{
+			query:    "show orders with status shipped",
+			expected: `SELECT * FROM "public"."orders" WHERE "status" = 'shipped' LIMIT 50`,
+		},
+		{
+			query:    "how many orders are cancelled?",
+			expected: `SELECT COUNT(*) as count FROM "public"."orders" WHERE "status" = 'cancelled'`,
+		},
+	}
+
+	for _, tt := range tests {
+		sql, err := engine.GenerateSQL(tt.query, "")
+		if err != nil {
+			t.Errorf("GenerateSQL(%q) error: %v", tt.query, err)
+			continue
+		}
+		if sql != tt.expected {
+			t.Errorf("GenerateSQL(%q) = %q, want %q", tt.query, sql, tt.expected)
+		}
+	}
+
+	if desc := engine.GetSchemaContext(); !strings.Contains(desc, "values: 'pending', 'shipped', 'cancelled'") {
+		t.Errorf("expected common values in schema description:\n%s", desc)
+	}
+}
+
+func TestJoinQueryFollowsForeignKeys(t *testing.T) {
+	schema := &config.SchemaCache{
+		Tables: []config.TableInfo{
+			{Schema: "public", Name: "customers", Columns: []config.ColumnInfo{
+				{Name: "id", IsPrimaryKey: true},
+			}},
+			{Schema: "public", Name: "orders", Columns: []config.ColumnInfo{
+				{Name: "id", IsPrimaryKey: true},
+				{Name: "customer_id", IsForeignKey: true, FKTable: "customers", FKColumn: "id"},
+			}},
+			{Schema: "public", Name: "order_items", Columns: []config.ColumnInfo{
+				{Name: "order_id", IsForeignKey: true, FKTable: "orders", FKColumn: "id"},
+				{Name: "product_id", IsForeignKey: true, FKTable: "products", FKColumn: "id"},
+			}},
+			{Schema: "public", Name: "products", Columns: []config.ColumnInfo{
+				{Name: "id", IsPrimaryKey: true},
+			}},
+			{Schema: "public", Name: "suppliers", Columns: []config.ColumnInfo{
+				{Name: "id", IsPrimaryKey: true},
+			}},
+		},
+	}
+
+	engine := NewQueryEngine(schema)
+	engine.SetUseNN(false)
+
+	tests := []struct {
+		query    string
+		expected string
+	}{
+		{
+			query:    "show customers with their orders",
+			expected: `SELECT t1.*, t2.* FROM "public"."customers" t1 JOIN "public"."orders" t2 ON t1."id" = t2."customer_id" LIMIT 50`,
+		},
+		{
+			// order_items only bridges the path, so it is joined but not selected
+			query: "which products did each customer buy",
+			expected: `SELECT t1.*, t4.* FROM "public"."products" t1 ` +
+				`JOIN "public"."order_items" t2 ON t1."id" = t2."product_id" ` +
+				`JOIN "public"."orders" t3 ON t2."order_id" = t3."id" ` +
+				`JOIN "public"."customers" t4 ON t3."customer_id" = t4."id" LIMIT 50`,
+		},
+	}
+
+	for _, tt := range tests {
+		sql, err := engine.GenerateSQL(tt.query, "")
+		if err != nil {
+			t.Errorf("GenerateSQL(%q) error: %v", tt.query, err)
+			continue
+		}
+		if sql != tt.expected {
+			t.Errorf("GenerateSQL(%q) =\n%s\nwant\n%s", tt.query, sql, tt.expected)
+		}
+	}
+
+	// Unconnected tables don't produce a join
+	if sql := engine.matchJoinQuery("customers and suppliers"); sql != "" {
+		t.Errorf("expected no join between unconnected tables, got %s", sql)
+	}
+}
+
+func TestJSONQueryUsesOperators(t *testing.T) {
+	schema := &config.SchemaCache{
+		Tables: []config.TableInfo{
+			{Schema: "public", Name: "events", Columns: []config.ColumnInfo{
+				{Name: "id", DataType: "integer", IsPrimaryKey: true},
+				{Name: "payload", DataType: "jsonb"},
+			}},
+			{Schema: "public", Name: "logs", Columns: []config.ColumnInfo{
+				{Name: "details", DataType: "json"},
+			}},
+		},
+	}
+
+	engine := NewQueryEngine(schema)
+	engine.SetUseNN(false)
+
+	tests := []struct {
+		query    string
+		expected string
+	}{
+		{
+			query:    "events where payload contains status=Failed",
+			expected: `SELECT * FROM "public"."events" WHERE "payload" @> '{"status":"Failed"}' LIMIT 50`,
+		},
+		{
+			query:    "how many events where payload.user.age is 42?",
+			expected: `SELECT COUNT(*) as count FROM "public"."events" WHERE "payload" @> '{"user":{"age":42}}'`,
+		},
+		{
+			query:    "events where payload has key error",
+			expected: `SELECT * FROM "public"."events" WHERE "payload" ? 'error' LIMIT 50`,
+		},
+		{
+			query:    "logs where details.level is 'warn'",
+			expected: `SELECT * FROM "public"."logs" WHERE "details"->>'level' = 'warn' LIMIT 50`,
+		},
+		{
+			query:    "logs where details.request.path = /api/o'brien",
+			expected: `SELECT * FROM "public"."logs" WHERE "details"#>>'{request,path}' = '/api/o''brien' LIMIT 50`,
+		},
+		{
+			query:    "logs where details contains timeout",
+			expected: `SELECT * FROM "public"."logs" WHERE "details"::text ILIKE '%timeout%' LIMIT 50`,
+		},
+	}
+
+	for _, tt := range tests {
+		sql, err := engine.GenerateSQL(tt.query, "")
+		if err != nil {
+			t.Errorf("GenerateSQL(%q) error: %v", tt.query, err)
+			continue
+		}
+		if sql != tt.expected {
+			t.Errorf("GenerateSQL(%q) = %q, want %q", tt.query, sql, tt.expected)
+		}
+	}
+
+	if desc := engine.GetSchemaContext(); !strings.Contains(desc, "payload (jsonb) [JSON - use ->> / #>> for keys, @> for containment]") {
+		t.Errorf("expected JSON hint in schema description:\n%s", desc)
+	}
+}
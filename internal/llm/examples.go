@@ -0,0 +1,226 @@
+package llm
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/kartoza/kartoza-pg-ai/internal/config"
+)
+
+// Example retrieval settings
+const (
+	// fewShotExamples is the number of earlier questions given to a
+	// provider as examples
+	fewShotExamples = 3
+	// minExampleSimilarity is the similarity below which an earlier
+	// question is not worth showing as an example
+	minExampleSimilarity = 0.5
+	// MaxExamples bounds the history entries loaded into an example store
+	MaxExamples = 500
+)
+
+// Example is an earlier question and the SQL that answered it
+type Example struct {
+	Question   string
+	SQL        string
+	Similarity float64 // To the question the example was retrieved for
+}
+
+// ExampleStore holds earlier successful questions and their SQL, and
+// retrieves the ones most similar to a new question so providers can
+// follow how this database was queried before. Questions are compared by
+// embedding when an embedder is available and by shared words otherwise.
+// It is safe for concurrent use.
+type ExampleStore struct {
+	mu       sync.Mutex
+	examples []Example   // Oldest first
+	vectors  [][]float32 // Embedding of each question; nil until embedded
+	model    string      // Model of vectors
+}
+
+// NewExampleStore creates a store of the successful entries of a query
+// history, which lists the newest entries first. The SQL the user ran,
+// edited or not, is kept, and a repeated question keeps its latest SQL.
+func NewExampleStore(history []config.QueryHistoryEntry) *ExampleStore {
+	s := &ExampleStore{}
+	for i := len(history) - 1; i >= 0; i-- {
+		entry := history[i]
+		if !entry.Success {
+			continue
+		}
+		sql := entry.EditedSQL
+		if sql == "" {
+			sql = entry.GeneratedSQL
+		}
+		s.Add(entry.NaturalQuery, sql)
+	}
+	return s
+}
+
+// Add records the SQL that answered a question, replacing the SQL of an
+// earlier identical question. Questions typed as SQL are ignored.
+func (s *ExampleStore) Add(question, sql string) {
+	question, sql = strings.TrimSpace(question), strings.TrimSpace(sql)
+	if question == "" || sql == "" || strings.EqualFold(question, sql) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := normalizeQuestion(question)
+	for i, ex := range s.examples {
+		if normalizeQuestion(ex.Question) == key {
+			s.examples = slices.Delete(s.examples, i, i+1)
+			s.vectors = slices.Delete(s.vectors, i, i+1)
+			break
+		}
+	}
+	s.examples = append(s.examples, Example{Question: question, SQL: sql})
+	s.vectors = append(s.vectors, nil)
+	if len(s.examples) > MaxExamples {
+		s.examples = s.examples[1:]
+		s.vectors = s.vectors[1:]
+	}
+}
+
+// Len returns the number of stored examples
+func (s *ExampleStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.examples)
+}
+
+// Retrieve returns up to k examples most similar to question, most
+// similar first. Questions are embedded with embedder when it is not nil,
+// falling back to shared words if embedding fails.
+func (s *ExampleStore) Retrieve(ctx context.Context, embedder Embedder, question string, k int) []Example {
+	if s == nil || k <= 0 {
+		return nil
+	}
+
+	scores := s.embeddingScores(ctx, embedder, question)
+
+	s.mu.Lock()
+	examples := slices.Clone(s.examples)
+	s.mu.Unlock()
+	if len(scores) != len(examples) {
+		words := questionWords(question)
+		scores = make([]float64, len(examples))
+		for i, ex := range examples {
+			scores[i] = wordSimilarity(words, questionWords(ex.Question))
+		}
+	}
+
+	key := normalizeQuestion(question)
+	var matches []Example
+	for i, ex := range examples {
+		// The question itself teaches the provider nothing new
+		if scores[i] < minExampleSimilarity || normalizeQuestion(ex.Question) == key {
+			continue
+		}
+		ex.Similarity = scores[i]
+		matches = append(matches, ex)
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].Similarity > matches[j].Similarity
+	})
+	if len(matches) > k {
+		matches = matches[:k]
+	}
+	return matches
+}
+
+// embeddingScores returns the cosine similarity of every stored question
+// to question, embedding stored questions not embedded yet. It returns nil
+// without an embedder or when embedding fails.
+func (s *ExampleStore) embeddingScores(ctx context.Context, embedder Embedder, question string) []float64 {
+	if embedder == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, embeddingTimeout)
+	defer cancel()
+
+	s.mu.Lock()
+	if s.model != embedder.Model() {
+		s.model = embedder.Model()
+		s.vectors = make([][]float32, len(s.examples))
+	}
+	var missing []string
+	for i, v := range s.vectors {
+		if v == nil {
+			missing = append(missing, s.examples[i].Question)
+		}
+	}
+	s.mu.Unlock()
+
+	probe, err := embedder.Embed(ctx, []string{question})
+	if err != nil || len(probe) != 1 {
+		return nil
+	}
+	embedded, err := embedCached(ctx, embedder, missing)
+	if err != nil {
+		return nil
+	}
+	vectorOf := make(map[string][]float32, len(missing))
+	for i, text := range missing {
+		vectorOf[text] = embedded[i]
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.model != embedder.Model() {
+		return nil
+	}
+	scores := make([]float64, len(s.examples))
+	for i, ex := range s.examples {
+		if s.vectors[i] == nil {
+			// Nil for examples added while embedding, which score 0
+			s.vectors[i] = vectorOf[ex.Question]
+		}
+		scores[i] = cosineSimilarity(probe[0], s.vectors[i])
+	}
+	return scores
+}
+
+// questionWords returns the lowercase words of a question
+func questionWords(question string) []string {
+	return strings.FieldsFunc(strings.ToLower(question), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// normalizeQuestion returns a question's words joined by single spaces, so
+// questions differing only in case and punctuation compare equal
+func normalizeQuestion(question string) string {
+	return strings.Join(questionWords(question), " ")
+}
+
+// wordSimilarity returns the Jaccard similarity of two questions' word sets
+func wordSimilarity(a, b []string) float64 {
+	set := make(map[string]bool, len(a))
+	for _, w := range a {
+		set[w] = true
+	}
+	union := len(set)
+	shared := 0
+	seen := make(map[string]bool, len(b))
+	for _, w := range b {
+		if seen[w] {
+			continue
+		}
+		seen[w] = true
+		if set[w] {
+			shared++
+		} else {
+			union++
+		}
+	}
+	if union == 0 {
+		return 0
+	}
+	return float64(shared) / float64(union)
+}
@@ -0,0 +1,71 @@
+package llm
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/kartoza/kartoza-pg-ai/internal/config"
+)
+
+func TestNewExampleStore(t *testing.T) {
+	// History lists the newest entries first
+	store := NewExampleStore([]config.QueryHistoryEntry{
+		{NaturalQuery: "How many roads?", GeneratedSQL: `SELECT COUNT(*) FROM "public"."roads"`, Success: true},
+		{NaturalQuery: "list parcels", GeneratedSQL: `SELECT * FROM parcels`, EditedSQL: `SELECT * FROM "public"."parcels" LIMIT 10`, Success: true},
+		{NaturalQuery: "broken question", GeneratedSQL: `SELECT nope`, Success: false},
+		{NaturalQuery: "how many roads", GeneratedSQL: `SELECT 1`, Success: true},
+		{NaturalQuery: `SELECT 2`, GeneratedSQL: `SELECT 2`, Success: true},
+	})
+
+	if store.Len() != 2 {
+		t.Fatalf("expected 2 examples, got %d", store.Len())
+	}
+	examples := store.Retrieve(context.Background(), nil, "how many roads are paved", 5)
+	if len(examples) != 1 || examples[0].SQL != `SELECT COUNT(*) FROM "public"."roads"` {
+		t.Errorf("expected the latest roads SQL, got %+v", examples)
+	}
+	examples = store.Retrieve(context.Background(), nil, "list all parcels", 5)
+	if len(examples) != 1 || !strings.Contains(examples[0].SQL, "LIMIT 10") {
+		t.Errorf("expected the edited parcels SQL, got %+v", examples)
+	}
+}
+
+func TestExampleStoreRetrieveByMeaning(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	store := NewExampleStore(nil)
+	store.Add("top buyers by spend", `SELECT * FROM "public"."customers" ORDER BY spend DESC`)
+	store.Add("longest streets", `SELECT * FROM "public"."roads" ORDER BY length DESC`)
+	store.Add("roads per municipality", `SELECT 1`)
+
+	embedder := &conceptEmbedder{}
+	examples := store.Retrieve(context.Background(), embedder, "which clients ordered most", 2)
+	if len(examples) != 1 || !strings.Contains(examples[0].SQL, "customers") {
+		t.Fatalf("expected the customers example, got %+v", examples)
+	}
+
+	// Stored questions are embedded once; later retrievals embed the question only
+	embedder.calls = 0
+	store.Retrieve(context.Background(), embedder, "clients", 2)
+	if embedder.calls != 1 {
+		t.Errorf("expected 1 embedding call, got %d", embedder.calls)
+	}
+
+	// The question itself is not an example of how to answer it
+	if examples := store.Retrieve(context.Background(), nil, "Longest streets?", 3); len(examples) != 0 {
+		t.Errorf("expected no examples, got %+v", examples)
+	}
+}
+
+func TestSystemPromptIncludesExamples(t *testing.T) {
+	prompt := buildSystemPrompt(GenerationRequest{
+		SchemaContext: "DATABASE SCHEMA:\n",
+		Examples:      []Example{{Question: "how many roads", SQL: `SELECT COUNT(*) FROM "public"."roads"`}},
+	})
+	if !strings.Contains(prompt, "EXAMPLES") || !strings.Contains(prompt, "Question: how many roads\nSQL: SELECT COUNT(*)") {
+		t.Errorf("examples missing from prompt:\n%s", prompt)
+	}
+	if strings.Contains(buildSystemPrompt(GenerationRequest{}), "EXAMPLES") {
+		t.Error("prompt without examples should not have an examples section")
+	}
+}
@@ -0,0 +1,736 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/kartoza/kartoza-pg-ai/internal/postgres"
+)
+
+// maxDescribedColumns is how many selected expressions a description names
+// before summarising the rest
+const maxDescribedColumns = 6
+
+// ExplainSQL describes what sql does in plain English. The configured
+// provider is asked first; the rule-based description is used without one
+// or when the provider fails.
+func (e *QueryEngine) ExplainSQL(ctx context.Context, sql, question string) (string, error) {
+	if e.provider != nil {
+		providerCtx, cancel := context.WithTimeout(e.metered(ctx), providerTimeout)
+		explanation, err := e.provider.ExplainSQL(providerCtx, ExplanationRequest{
+			SQL:           sql,
+			Question:      question,
+			SchemaContext: e.schemaContextFor(ctx, question+"\n"+sql),
+		})
+		cancel()
+		if err == nil {
+			return explanation, nil
+		}
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+	}
+
+	if explanation := DescribeSQL(sql); explanation != "" {
+		return explanation, nil
+	}
+	return "", fmt.Errorf("nothing to explain")
+}
+
+// DescribeSQL describes a statement in plain English from its clauses,
+// returning "" for empty SQL
+func DescribeSQL(sql string) string {
+	var tokens []postgres.Token
+	for _, tok := range postgres.LexSQL(sql) {
+		if tok.Kind != postgres.TokenWhitespace && tok.Kind != postgres.TokenComment && tok.Text != ";" {
+			tokens = append(tokens, tok)
+		}
+	}
+	if len(tokens) == 0 {
+		return ""
+	}
+	return describeStatement(tokens)
+}
+
+// describeStatement describes the statement starting at tokens[0]
+func describeStatement(tokens []postgres.Token) string {
+	switch strings.ToLower(tokens[0].Text) {
+	case "with":
+		return describeWith(tokens)
+	case "select":
+		return describeSelect(splitClauses(tokens))
+	case "insert":
+		return describeInsert(splitClauses(tokens))
+	case "update":
+		return describeUpdate(splitClauses(tokens))
+	case "delete":
+		return describeDelete(splitClauses(tokens))
+	case "explain":
+		rest := tokens[1:]
+		for len(rest) > 0 {
+			if rest[0].Text == "(" {
+				rest = rest[closingParen(rest, 0)+1:]
+				continue
+			}
+			if word := strings.ToLower(rest[0].Text); word != "analyze" && word != "analyse" && word != "verbose" {
+				break
+			}
+			rest = rest[1:]
+		}
+		if len(rest) == 0 {
+			return "Shows how PostgreSQL runs a statement."
+		}
+		return "Shows how PostgreSQL runs the following statement. " + describeStatement(rest)
+	default:
+		return fmt.Sprintf("Runs a %s statement.", strings.ToUpper(tokens[0].Text))
+	}
+}
+
+// describeWith describes a statement with common table expressions,
+// naming the intermediate results before the main statement
+func describeWith(tokens []postgres.Token) string {
+	i := 1
+	if i < len(tokens) && strings.EqualFold(tokens[i].Text, "recursive") {
+		i++
+	}
+	var names []string
+	for i < len(tokens) {
+		names = append(names, phrase(tokens[i:i+1]))
+		i++
+		// Skip the column list and AS [NOT] MATERIALIZED before the body
+		if i < len(tokens) && tokens[i].Text == "(" {
+			i = closingParen(tokens, i) + 1
+		}
+		for i < len(tokens) && tokens[i].Text != "(" {
+			i++
+		}
+		if i >= len(tokens) {
+			break
+		}
+		i = closingParen(tokens, i) + 1
+		if i < len(tokens) && tokens[i].Text == "," {
+			i++
+			continue
+		}
+		break
+	}
+
+	intro := "First computes the intermediate result " + joinList(names) + "."
+	if len(names) > 1 {
+		intro = "First computes the intermediate results " + joinList(names) + "."
+	}
+	if i >= len(tokens) {
+		return intro
+	}
+	main := describeStatement(tokens[i:])
+	return intro + " Then " + strings.ToLower(main[:1]) + main[1:]
+}
+
+// clause is a top-level part of a statement, such as its WHERE condition
+type clause struct {
+	name   string // Lowercased keyword, with "group by" and "order by" shortened to "group" and "order"
+	join   string // Join keywords of a "join" clause, e.g. "left join"
+	tokens []postgres.Token
+}
+
+// clauseKeywords start a new clause when they appear outside parentheses
+var clauseKeywords = map[string]bool{
+	"select": true, "from": true, "where": true, "group": true, "having": true,
+	"order": true, "limit": true, "offset": true, "fetch": true, "window": true,
+	"union": true, "intersect": true, "except": true, "on": true, "using": true,
+	"set": true, "values": true, "returning": true, "into": true, "update": true,
+	"delete": true, "insert": true,
+}
+
+// joinKeywords make up the join type before a joined table
+var joinKeywords = map[string]bool{
+	"join": true, "left": true, "right": true, "full": true, "inner": true,
+	"outer": true, "cross": true, "natural": true, "lateral": true,
+}
+
+// splitClauses splits a statement into its top-level clauses
+func splitClauses(tokens []postgres.Token) []clause {
+	var clauses []clause
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+		word := strings.ToLower(tok.Text)
+		isFunction := i+1 < len(tokens) && tokens[i+1].Text == "("
+		switch {
+		case tok.Text == "(":
+			end := closingParen(tokens, i)
+			if len(clauses) > 0 {
+				clauses[len(clauses)-1].tokens = append(clauses[len(clauses)-1].tokens, tokens[i:end+1]...)
+			}
+			i = end
+			continue
+		case tok.Kind == postgres.TokenKeyword && joinKeywords[word] && word != "lateral" && !isFunction:
+			c := clause{name: "join"}
+			for ; i < len(tokens) && joinKeywords[strings.ToLower(tokens[i].Text)]; i++ {
+				c.join = strings.TrimSpace(c.join + " " + strings.ToLower(tokens[i].Text))
+			}
+			i--
+			clauses = append(clauses, c)
+			continue
+		case tok.Kind == postgres.TokenKeyword && clauseKeywords[word]:
+			// ON CONFLICT and DISTINCT ON belong to the clause they are in
+			if word != "on" || len(clauses) > 0 && clauses[len(clauses)-1].name == "join" {
+				clauses = append(clauses, clause{name: word})
+				if (word == "group" || word == "order") && i+1 < len(tokens) && strings.EqualFold(tokens[i+1].Text, "by") {
+					i++
+				}
+				continue
+			}
+		}
+		if len(clauses) > 0 {
+			clauses[len(clauses)-1].tokens = append(clauses[len(clauses)-1].tokens, tok)
+		}
+	}
+	return clauses
+}
+
+// describeSelect describes a SELECT statement
+func describeSelect(clauses []clause) string {
+	var sentences []string
+	var returns strings.Builder
+	var selected, where, group, having, order, limit, offset []postgres.Token
+	combined := false
+
+	for i, c := range clauses {
+		switch c.name {
+		case "select":
+			if returns.Len() == 0 {
+				selected = c.tokens
+				returns.WriteString("Returns " + describeSelectList(c.tokens))
+			}
+		case "from":
+			if !combined {
+				returns.WriteString(" from " + describeTables(c.tokens))
+			}
+		case "join":
+			if combined {
+				continue
+			}
+			text := ", joined with " + describeTables(c.tokens)
+			if strings.Contains(c.join, "left") || strings.Contains(c.join, "full") {
+				text += " (keeping rows without a match)"
+			}
+			if i+1 < len(clauses) && clauses[i+1].name == "on" {
+				text += " where " + phrase(clauses[i+1].tokens)
+			} else if i+1 < len(clauses) && clauses[i+1].name == "using" {
+				text += " on matching " + strings.Trim(phrase(clauses[i+1].tokens), "()")
+			}
+			returns.WriteString(text)
+		case "where":
+			if !combined {
+				where = c.tokens
+			}
+		case "group":
+			group = c.tokens
+		case "having":
+			having = c.tokens
+		case "order":
+			order = c.tokens
+		case "limit":
+			limit = c.tokens
+		case "offset":
+			offset = c.tokens
+		case "union", "intersect", "except":
+			combined = true
+		}
+	}
+	sentences = append(sentences, returns.String()+".")
+
+	if len(where) > 0 {
+		sentences = append(sentences, "It only includes rows where "+phrase(where)+".")
+	}
+	if len(group) > 0 {
+		text := "Rows are grouped by " + joinList(phrases(resolvePositions(splitTopLevel(group), selected)))
+		if len(having) > 0 {
+			text += ", keeping groups where " + phrase(having)
+		}
+		sentences = append(sentences, text+".")
+	}
+	if combined {
+		sentences = append(sentences, "The result is combined with the rows of another query.")
+	}
+
+	var ordering []string
+	if len(order) > 0 {
+		ordering = append(ordering, "sorted by "+describeOrder(order, selected))
+	}
+	if len(limit) > 0 && !strings.EqualFold(limit[0].Text, "all") {
+		ordering = append(ordering, "limited to "+phrase(limit)+" rows")
+	}
+	if len(offset) > 0 {
+		ordering = append(ordering, "skipping the first "+phrase(offset))
+	}
+	if len(ordering) > 0 {
+		sentences = append(sentences, "Results are "+joinList(ordering)+".")
+	}
+	return strings.Join(sentences, " ")
+}
+
+// describeSelectList describes the expressions a SELECT returns
+func describeSelectList(tokens []postgres.Token) string {
+	distinct := false
+	if len(tokens) > 0 && strings.EqualFold(tokens[0].Text, "distinct") {
+		distinct = true
+		tokens = tokens[1:]
+		if len(tokens) > 1 && strings.EqualFold(tokens[0].Text, "on") && tokens[1].Text == "(" {
+			tokens = tokens[closingParen(tokens, 1)+1:]
+		}
+	}
+
+	var parts []string
+	items := splitTopLevel(tokens)
+	for _, item := range items {
+		text := phrase(stripAlias(item))
+		switch {
+		case text == "*":
+			text = "all columns"
+		case strings.HasSuffix(text, ".*"):
+			text = "all columns of " + strings.TrimSuffix(text, ".*")
+		}
+		parts = append(parts, text)
+	}
+	if len(parts) > maxDescribedColumns {
+		parts = append(parts[:maxDescribedColumns-1], fmt.Sprintf("%d more columns", len(items)-maxDescribedColumns+1))
+	}
+
+	list := joinList(parts)
+	if distinct {
+		return "the distinct combinations of " + list
+	}
+	return list
+}
+
+// describeTables describes the tables of a FROM clause or join
+func describeTables(tokens []postgres.Token) string {
+	var parts []string
+	for _, item := range splitTopLevel(tokens) {
+		if len(item) > 0 && strings.EqualFold(item[0].Text, "lateral") {
+			item = item[1:]
+		}
+		parts = append(parts, phrase(stripAlias(item)))
+	}
+	return joinList(parts)
+}
+
+// describeOrder describes the sort keys of an ORDER BY clause
+func describeOrder(tokens, selected []postgres.Token) string {
+	var parts []string
+	for _, item := range resolvePositions(splitTopLevel(tokens), selected) {
+		for i, tok := range item {
+			if tok.Text == "<->" && i > 0 {
+				parts = append(parts, fmt.Sprintf("distance between %s and %s, nearest first", phrase(item[:i]), phrase(item[i+1:])))
+				item = nil
+				break
+			}
+		}
+		if item != nil {
+			parts = append(parts, phrase(item))
+		}
+	}
+	return joinList(parts)
+}
+
+// resolvePositions replaces GROUP BY and ORDER BY items that refer to a
+// selected expression by its position with that expression
+func resolvePositions(items [][]postgres.Token, selected []postgres.Token) [][]postgres.Token {
+	columns := splitTopLevel(selected)
+	resolved := make([][]postgres.Token, len(items))
+	for i, item := range items {
+		resolved[i] = item
+		if len(item) == 0 || item[0].Kind != postgres.TokenNumber {
+			continue
+		}
+		if n, err := strconv.Atoi(item[0].Text); err == nil && n >= 1 && n <= len(columns) {
+			resolved[i] = append(append([]postgres.Token{}, stripAlias(columns[n-1])...), item[1:]...)
+		}
+	}
+	return resolved
+}
+
+// describeInsert describes an INSERT statement
+func describeInsert(clauses []clause) string {
+	target := "a table"
+	rows := "rows"
+	fromQuery := false
+	for _, c := range clauses {
+		switch c.name {
+		case "into":
+			if len(c.tokens) > 0 {
+				target = phrase(stripColumnList(c.tokens))
+			}
+		case "values":
+			if n := len(splitTopLevel(c.tokens)); n == 1 {
+				rows = "a row"
+			} else {
+				rows = fmt.Sprintf("%d rows", n)
+			}
+		case "select":
+			fromQuery = true
+		}
+	}
+	text := "Inserts " + rows + " into " + target + "."
+	if fromQuery {
+		text = "Inserts the rows returned by a query into " + target + "."
+	}
+	return text + describeReturning(clauses)
+}
+
+// describeUpdate describes an UPDATE statement
+func describeUpdate(clauses []clause) string {
+	var target, where string
+	var assignments []string
+	for _, c := range clauses {
+		switch c.name {
+		case "update":
+			target = phrase(stripAlias(c.tokens))
+		case "set":
+			for _, item := range splitTopLevel(c.tokens) {
+				for i, tok := range item {
+					if tok.Text == "=" {
+						assignments = append(assignments, phrase(item[:i])+" to "+phrase(item[i+1:]))
+						break
+					}
+				}
+			}
+		case "where":
+			where = phrase(c.tokens)
+		}
+	}
+	text := "Updates " + target
+	if len(assignments) > 0 {
+		text += ", setting " + joinList(assignments) + ","
+	}
+	if where != "" {
+		text += " in rows where " + where + "."
+	} else {
+		text += " in every row."
+	}
+	return text + describeReturning(clauses)
+}
+
+// describeDelete describes a DELETE statement
+func describeDelete(clauses []clause) string {
+	var target, where string
+	for _, c := range clauses {
+		switch c.name {
+		case "from":
+			target = phrase(stripAlias(c.tokens))
+		case "where":
+			where = phrase(c.tokens)
+		}
+	}
+	text := "Deletes every row from " + target + "."
+	if where != "" {
+		text = "Deletes the rows of " + target + " where " + where + "."
+	}
+	return text + describeReturning(clauses)
+}
+
+// describeReturning notes a RETURNING clause of a data-modifying statement
+func describeReturning(clauses []clause) string {
+	for _, c := range clauses {
+		if c.name == "returning" {
+			return " It returns " + describeSelectList(c.tokens) + " of the changed rows."
+		}
+	}
+	return ""
+}
+
+// functionPhrase describes a function call; args is the number of
+// arguments format takes
+type functionPhrase struct {
+	args   int
+	format string
+}
+
+// functionPhrases describe common aggregate, PostGIS and helper functions.
+// Functions that only convert a value keep the description of their argument.
+var functionPhrases = map[string]functionPhrase{
+	"count":           {1, "the number of %s"},
+	"sum":             {1, "the total %s"},
+	"avg":             {1, "the average %s"},
+	"min":             {1, "the smallest %s"},
+	"max":             {1, "the largest %s"},
+	"array_agg":       {1, "the list of %s"},
+	"string_agg":      {1, "the list of %s"},
+	"lower":           {1, "%s"},
+	"upper":           {1, "%s"},
+	"coalesce":        {1, "%s"},
+	"round":           {1, "%s rounded"},
+	"now":             {0, "the current time"},
+	"st_area":         {1, "the area of %s"},
+	"st_length":       {1, "the length of %s"},
+	"st_perimeter":    {1, "the perimeter of %s"},
+	"st_distance":     {2, "the distance between %s and %s"},
+	"st_intersects":   {2, "%s intersects %s"},
+	"st_contains":     {2, "%s contains %s"},
+	"st_within":       {2, "%s lies within %s"},
+	"st_dwithin":      {3, "%[1]s is within %[3]s of %[2]s"},
+	"st_astext":       {1, "%s as text"},
+	"st_asgeojson":    {1, "%s as GeoJSON"},
+	"st_centroid":     {1, "the centre of %s"},
+	"st_buffer":       {2, "%s buffered by %s"},
+	"st_transform":    {1, "%s"},
+	"st_setsrid":      {1, "%s"},
+	"st_makepoint":    {2, "the point (%s, %s)"},
+	"st_point":        {2, "the point (%s, %s)"},
+	"st_makeenvelope": {0, "a bounding box"},
+	"date_trunc":      {2, "%[2]s truncated to the %[1]s"},
+}
+
+// operatorWords read comparison operators aloud
+var operatorWords = map[string]string{
+	"=":   "is",
+	"<>":  "is not",
+	"!=":  "is not",
+	">":   "is greater than",
+	"<":   "is less than",
+	">=":  "is at least",
+	"<=":  "is at most",
+	"<->": "distance to",
+	"&&":  "overlaps the bounding box of",
+	"~":   "matches the pattern",
+	"~*":  "matches the pattern",
+}
+
+// keywordWords read keywords inside expressions aloud; "" drops the keyword
+var keywordWords = map[string]string{
+	"like":     "matches",
+	"ilike":    "matches",
+	"in":       "is one of",
+	"between":  "is between",
+	"exists":   "a row exists in",
+	"null":     "empty",
+	"asc":      "",
+	"desc":     "descending",
+	"distinct": "distinct",
+}
+
+// negatedKeywordWords read NOT followed by a keyword aloud
+var negatedKeywordWords = map[string]string{
+	"like":    "does not match",
+	"ilike":   "does not match",
+	"in":      "is not one of",
+	"between": "is not between",
+	"exists":  "no row exists in",
+}
+
+// phrase renders an expression as words: identifiers lose their quotes,
+// operators and known functions are read aloud and subqueries are named
+func phrase(tokens []postgres.Token) string {
+	var words []string
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+		word := strings.ToLower(tok.Text)
+		next := ""
+		if i+1 < len(tokens) {
+			next = strings.ToLower(tokens[i+1].Text)
+		}
+
+		switch {
+		case tok.Text == "(" && next == "select":
+			words = append(words, "a subquery")
+			i = closingParen(tokens, i)
+
+		case tok.Kind == postgres.TokenIdentifier && next == "(":
+			end := closingParen(tokens, i+1)
+			words = append(words, describeCall(word, tokens[i+2:end]))
+			i = end
+
+		case word == "cast" && next == "(":
+			end := closingParen(tokens, i+1)
+			inner := tokens[i+2 : end]
+			for j, t := range inner {
+				if strings.EqualFold(t.Text, "as") {
+					inner = inner[:j]
+					break
+				}
+			}
+			words = append(words, phrase(inner))
+			i = end
+
+		case word == "over" && next == "(":
+			i = closingParen(tokens, i+1)
+
+		case tok.Text == "::":
+			// Casts do not change what a value means to the reader
+			i++
+			if i+1 < len(tokens) && tokens[i+1].Text == "(" {
+				i = closingParen(tokens, i+1)
+			}
+
+		case (tok.Text == "-" || tok.Text == "+") && i+1 < len(tokens) && tokens[i+1].Kind == postgres.TokenNumber &&
+			(i == 0 || tokens[i-1].Text == "(" || tokens[i-1].Text == "," || tokens[i-1].Kind == postgres.TokenOperator || tokens[i-1].Kind == postgres.TokenKeyword):
+			// A signed number
+			words = append(words, tok.Text+tokens[i+1].Text)
+			i++
+
+		case tok.Kind == postgres.TokenOperator:
+			if w, ok := operatorWords[tok.Text]; ok {
+				words = append(words, w)
+			} else {
+				words = append(words, tok.Text)
+			}
+
+		case tok.Kind == postgres.TokenKeyword:
+			switch {
+			case word == "is" && next == "not" && i+2 < len(tokens) && strings.EqualFold(tokens[i+2].Text, "null"):
+				words = append(words, "is set")
+				i += 2
+			case word == "is" && next == "null":
+				words = append(words, "is empty")
+				i++
+			case word == "not" && negatedKeywordWords[next] != "":
+				words = append(words, negatedKeywordWords[next])
+				i++
+			default:
+				if w, ok := keywordWords[word]; ok {
+					if w != "" {
+						words = append(words, w)
+					}
+				} else {
+					words = append(words, word)
+				}
+			}
+
+		case tok.Kind == postgres.TokenQuotedIdentifier:
+			words = append(words, strings.ReplaceAll(strings.Trim(tok.Text, `"`), `""`, `"`))
+
+		default:
+			words = append(words, tok.Text)
+		}
+	}
+	return joinWords(words)
+}
+
+// describeCall describes a call of the named function with the given
+// argument tokens
+func describeCall(name string, argTokens []postgres.Token) string {
+	args := phrases(splitTopLevel(argTokens))
+	if name == "count" && (len(args) == 0 || args[0] == "*") {
+		return "the number of rows"
+	}
+	switch name {
+	case "date_trunc":
+		if len(args) > 0 {
+			args[0] = strings.Trim(args[0], "'")
+		}
+	case "sum", "avg", "min", "max":
+		// "the total the length of geom" reads as "the total length of geom"
+		if len(args) > 0 {
+			args[0] = strings.TrimPrefix(args[0], "the ")
+		}
+	}
+	if fp, ok := functionPhrases[name]; ok && len(args) >= fp.args {
+		values := make([]any, fp.args)
+		for i := range values {
+			values[i] = args[i]
+		}
+		return fmt.Sprintf(fp.format, values...)
+	}
+	return name + "(" + strings.Join(args, ", ") + ")"
+}
+
+// joinWords joins words with spaces, except around dots and inside
+// parentheses and before commas
+func joinWords(words []string) string {
+	var b strings.Builder
+	prev := ""
+	for _, w := range words {
+		if b.Len() > 0 && w != "," && w != ")" && w != "." && prev != "(" && prev != "." {
+			b.WriteByte(' ')
+		}
+		b.WriteString(w)
+		prev = w
+	}
+	return b.String()
+}
+
+// joinList joins items as "a, b and c"
+func joinList(items []string) string {
+	switch len(items) {
+	case 0:
+		return ""
+	case 1:
+		return items[0]
+	}
+	return strings.Join(items[:len(items)-1], ", ") + " and " + items[len(items)-1]
+}
+
+// phrases renders each of a list of expressions
+func phrases(items [][]postgres.Token) []string {
+	out := make([]string, 0, len(items))
+	for _, item := range items {
+		out = append(out, phrase(item))
+	}
+	return out
+}
+
+// closingParen returns the index of the parenthesis closing the one at
+// open, or the last index when it is unbalanced
+func closingParen(tokens []postgres.Token, open int) int {
+	depth := 0
+	for i := open; i < len(tokens); i++ {
+		switch tokens[i].Text {
+		case "(":
+			depth++
+		case ")":
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return len(tokens) - 1
+}
+
+// splitTopLevel splits tokens at commas outside parentheses
+func splitTopLevel(tokens []postgres.Token) [][]postgres.Token {
+	var items [][]postgres.Token
+	start := 0
+	for i := 0; i < len(tokens); i++ {
+		switch tokens[i].Text {
+		case "(":
+			i = closingParen(tokens, i)
+		case ",":
+			items = append(items, tokens[start:i])
+			start = i + 1
+		}
+	}
+	if start < len(tokens) {
+		items = append(items, tokens[start:])
+	}
+	return items
+}
+
+// stripAlias drops an "AS name" or bare alias from the end of an
+// expression or table reference
+func stripAlias(item []postgres.Token) []postgres.Token {
+	n := len(item)
+	if n >= 3 && strings.EqualFold(item[n-2].Text, "as") {
+		return item[:n-2]
+	}
+	if n >= 2 && (item[n-1].Kind == postgres.TokenIdentifier || item[n-1].Kind == postgres.TokenQuotedIdentifier) {
+		switch prev := item[n-2]; {
+		case prev.Kind == postgres.TokenIdentifier, prev.Kind == postgres.TokenQuotedIdentifier, prev.Text == ")":
+			return item[:n-1]
+		}
+	}
+	return item
+}
+
+// stripColumnList drops the column list after an INSERT target table
+func stripColumnList(tokens []postgres.Token) []postgres.Token {
+	for i, tok := range tokens {
+		if tok.Text == "(" {
+			return tokens[:i]
+		}
+	}
+	return tokens
+}
@@ -0,0 +1,75 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/kartoza/kartoza-pg-ai/internal/config"
+)
+
+func TestDescribeSQL(t *testing.T) {
+	tests := []struct {
+		sql      string
+		expected string
+	}{
+		{
+			`SELECT COUNT(*) FROM "public"."roads" WHERE "type" = 'primary';`,
+			"Returns the number of rows from public.roads. It only includes rows where type is 'primary'.",
+		},
+		{
+			`SELECT "type", SUM(ST_Length(geom::geography)) AS total FROM "public"."roads" GROUP BY 1 ORDER BY total DESC LIMIT 10`,
+			"Returns type and the total length of geom from public.roads. Rows are grouped by type. " +
+				"Results are sorted by total descending and limited to 10 rows.",
+		},
+		{
+			`SELECT p.name FROM places p LEFT JOIN towns t ON ST_Within(p.geom, t.geom) WHERE t.name IS NULL`,
+			"Returns p.name from places, joined with towns (keeping rows without a match) where p.geom lies within t.geom. " +
+				"It only includes rows where t.name is empty.",
+		},
+		{
+			`SELECT name FROM shops ORDER BY geom <-> ST_SetSRID(ST_MakePoint(18.4, -33.9), 4326) LIMIT 5`,
+			"Returns name from shops. Results are sorted by distance between geom and the point (18.4, -33.9), nearest first and limited to 5 rows.",
+		},
+		{
+			`WITH busy AS (SELECT * FROM stops WHERE riders > 100) SELECT DISTINCT route FROM busy`,
+			"First computes the intermediate result busy. Then returns the distinct combinations of route from busy.",
+		},
+		{
+			`UPDATE "public"."roads" SET speed = 60 WHERE "type" NOT IN ('primary', 'trunk')`,
+			"Updates public.roads, setting speed to 60, in rows where type is not one of ('primary', 'trunk').",
+		},
+		{`INSERT INTO notes (body) VALUES ('a'), ('b')`, "Inserts 2 rows into notes."},
+		{`DELETE FROM notes`, "Deletes every row from notes."},
+		{`VACUUM notes`, "Runs a VACUUM statement."},
+		{"  -- nothing\n", ""},
+	}
+
+	for _, tt := range tests {
+		if got := DescribeSQL(tt.sql); got != tt.expected {
+			t.Errorf("DescribeSQL(%q):\n got %q\nwant %q", tt.sql, got, tt.expected)
+		}
+	}
+}
+
+func TestExplainSQLUsesProvider(t *testing.T) {
+	engine := NewQueryEngine(&config.SchemaCache{})
+	sql := `SELECT COUNT(*) FROM "public"."roads"`
+
+	engine.SetProvider(&staticProvider{explanation: "Counts the roads."})
+	explanation, err := engine.ExplainSQL(context.Background(), sql, "how many roads")
+	if err != nil || explanation != "Counts the roads." {
+		t.Errorf("expected the provider's explanation, got %q (%v)", explanation, err)
+	}
+
+	// A failing provider falls back to the rule-based description
+	engine.SetProvider(&staticProvider{err: errors.New("offline")})
+	explanation, err = engine.ExplainSQL(context.Background(), sql, "how many roads")
+	if err != nil || explanation != DescribeSQL(sql) {
+		t.Errorf("expected the rule-based description, got %q (%v)", explanation, err)
+	}
+
+	if _, err := engine.ExplainSQL(context.Background(), " ", ""); err == nil {
+		t.Error("expected an error for empty SQL")
+	}
+}
@@ -0,0 +1,89 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Geocoder locates places named in questions
+type Geocoder interface {
+	// Geocode returns the WGS 84 longitude and latitude of a place
+	Geocode(ctx context.Context, place string) (lon, lat float64, err error)
+}
+
+// NominatimGeocoder locates places with a Nominatim-compatible search API,
+// e.g. https://nominatim.openstreetmap.org. Places found are remembered, so
+// each is only asked for once.
+type NominatimGeocoder struct {
+	baseURL string
+	client  *http.Client
+
+	mu     sync.Mutex
+	places map[string][2]float64 // Longitude and latitude, by lowercased place
+}
+
+// NewNominatimGeocoder creates a geocoder asking the search API at baseURL
+func NewNominatimGeocoder(baseURL string) *NominatimGeocoder {
+	return &NominatimGeocoder{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		client:  &http.Client{Timeout: 10 * time.Second},
+		places:  make(map[string][2]float64),
+	}
+}
+
+// Geocode returns the location of the best match for place
+func (g *NominatimGeocoder) Geocode(ctx context.Context, place string) (lon, lat float64, err error) {
+	key := strings.ToLower(strings.TrimSpace(place))
+	g.mu.Lock()
+	found, ok := g.places[key]
+	g.mu.Unlock()
+	if ok {
+		return found[0], found[1], nil
+	}
+
+	query := url.Values{"q": {place}, "format": {"jsonv2"}, "limit": {"1"}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, g.baseURL+"/search?"+query.Encode(), nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	// Nominatim's usage policy asks for an identifying User-Agent
+	req.Header.Set("User-Agent", "kartoza-pg-ai")
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return 0, 0, fmt.Errorf("geocoder unreachable at %s: %w", g.baseURL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, 0, fmt.Errorf("geocoder returned status %d", resp.StatusCode)
+	}
+
+	var results []struct {
+		Lon string `json:"lon"`
+		Lat string `json:"lat"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&results); err != nil {
+		return 0, 0, fmt.Errorf("invalid geocoder response: %w", err)
+	}
+	if len(results) == 0 {
+		return 0, 0, fmt.Errorf("place %q not found", place)
+	}
+	lon, err = strconv.ParseFloat(results[0].Lon, 64)
+	if err == nil {
+		lat, err = strconv.ParseFloat(results[0].Lat, 64)
+	}
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid geocoder coordinates: %w", err)
+	}
+
+	g.mu.Lock()
+	g.places[key] = [2]float64{lon, lat}
+	g.mu.Unlock()
+	return lon, lat, nil
+}
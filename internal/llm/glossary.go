@@ -0,0 +1,97 @@
+package llm
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Glossary maps words questions use to the words the schema uses for the
+// same thing, e.g. client to customer or erf to parcel. Words are
+// lower-cased.
+type Glossary map[string]string
+
+// NewGlossary returns a glossary of the given entries, later ones
+// replacing earlier ones defining the same word
+func NewGlossary(entries ...map[string]string) Glossary {
+	g := make(Glossary)
+	for _, m := range entries {
+		for word, term := range m {
+			word, term = strings.ToLower(strings.TrimSpace(word)), strings.TrimSpace(term)
+			if word != "" && term != "" {
+				g[word] = term
+			}
+		}
+	}
+	return g
+}
+
+// LoadGlossary reads a glossary kept with a database as YAML, one
+// "word: schema word" per line:
+//
+//	client: customer
+//	erf: parcel
+//
+// The path may start with ~.
+func LoadGlossary(path string) (Glossary, error) {
+	if strings.HasPrefix(path, "~/") || strings.HasPrefix(path, `~\`) {
+		if home, err := os.UserHomeDir(); err == nil {
+			path = filepath.Join(home, path[2:])
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var entries map[string]string
+	if err := yaml.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("%s is not a glossary of words and schema words: %w", path, err)
+	}
+	return NewGlossary(entries), nil
+}
+
+// Lookup returns the schema word for a word of a question, trying its
+// singular when the plural is not defined ("clients" finds client)
+func (g Glossary) Lookup(word string) (string, bool) {
+	if len(g) == 0 {
+		return "", false
+	}
+	word = strings.ToLower(word)
+	for _, form := range []string{word, strings.TrimSuffix(word, "s"), strings.TrimSuffix(word, "es")} {
+		if term, ok := g[form]; ok {
+			return term, true
+		}
+	}
+	return "", false
+}
+
+// describe returns the glossary for the schema description providers are
+// given, empty without one
+func (g Glossary) describe() string {
+	if len(g) == 0 {
+		return ""
+	}
+	words := make([]string, 0, len(g))
+	for word := range g {
+		words = append(words, word)
+	}
+	sort.Strings(words)
+
+	var desc strings.Builder
+	desc.WriteString("GLOSSARY (words used in questions and what they are called in this schema):\n")
+	for _, word := range words {
+		desc.WriteString(fmt.Sprintf("- %s: %s\n", word, g[word]))
+	}
+	desc.WriteString("\n")
+	return desc.String()
+}
+
+// SetGlossary sets the synonyms questions are read with before their words
+// are matched to tables and columns; nil for none
+func (e *QueryEngine) SetGlossary(g Glossary) {
+	e.glossary = g
+}
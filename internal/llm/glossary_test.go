@@ -0,0 +1,92 @@
+package llm
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kartoza/kartoza-pg-ai/internal/config"
+)
+
+func TestLoadGlossary(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "glossary.yaml")
+	data := "# Words the planners use\nClient: customer\nerf: parcel\nstand: parcel\n"
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	g, err := LoadGlossary(path)
+	if err != nil {
+		t.Fatalf("LoadGlossary failed: %v", err)
+	}
+	if len(g) != 3 || g["client"] != "customer" || g["erf"] != "parcel" {
+		t.Errorf("unexpected glossary: %v", g)
+	}
+
+	if err := os.WriteFile(path, []byte("parcel: [erf, stand]\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadGlossary(path); err == nil {
+		t.Error("a list of words should be rejected")
+	}
+	if _, err := LoadGlossary(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("a missing glossary should fail")
+	}
+}
+
+func TestGlossaryLookup(t *testing.T) {
+	g := NewGlossary(map[string]string{"client": "customer", "erf": "plot"}, map[string]string{"ERF": "parcel", "address": "location"})
+	tests := []struct {
+		word, want string
+		ok         bool
+	}{
+		{"client", "customer", true},
+		{"Clients", "customer", true},
+		{"erfs", "parcel", true}, // The later map wins
+		{"addresses", "location", true},
+		{"customer", "", false},
+	}
+	for _, tt := range tests {
+		if got, ok := g.Lookup(tt.word); got != tt.want || ok != tt.ok {
+			t.Errorf("Lookup(%q) = %q, %v; want %q, %v", tt.word, got, ok, tt.want, tt.ok)
+		}
+	}
+	if _, ok := Glossary(nil).Lookup("client"); ok {
+		t.Error("an empty glossary should define nothing")
+	}
+}
+
+func TestGlossaryMatchesTables(t *testing.T) {
+	schema := &config.SchemaCache{
+		Tables: []config.TableInfo{
+			{Schema: "public", Name: "customers", Columns: []config.ColumnInfo{{Name: "id"}, {Name: "name"}}},
+			{Schema: "cadastre", Name: "parcels", Columns: []config.ColumnInfo{{Name: "id"}, {Name: "geom"}}},
+			{Schema: "public", Name: "invoices", Columns: []config.ColumnInfo{{Name: "id"}, {Name: "total"}}},
+		},
+	}
+	engine := NewQueryEngine(schema)
+	if table := engine.findTable("clients"); table != nil {
+		t.Fatalf("without a glossary clients should not match, got %s", table.Name)
+	}
+
+	engine.SetGlossary(NewGlossary(map[string]string{"client": "customer", "erf": "parcel"}))
+	sql, err := engine.GenerateSQL("how many clients", "")
+	if err != nil {
+		t.Fatalf("GenerateSQL failed: %v", err)
+	}
+	if want := `SELECT COUNT(*) as count FROM "public"."customers"`; sql != want {
+		t.Errorf("got %s, want %s", sql, want)
+	}
+
+	matches := engine.findSemanticMatches([]string{"erfs"})
+	if len(matches) == 0 || matches[0].Table.Name != "parcels" {
+		t.Fatalf("erfs should match parcels first, got %+v", matches)
+	}
+	if !strings.HasPrefix(matches[0].MatchType, "synonym_") || matches[0].Keyword != "erfs" {
+		t.Errorf("match should be a synonym of the keyword: %+v", matches[0])
+	}
+
+	if desc := engine.GetSchemaContext(); !strings.Contains(desc, "GLOSSARY") || !strings.Contains(desc, "- erf: parcel") {
+		t.Errorf("schema context should hold the glossary:\n%s", desc)
+	}
+}
@@ -0,0 +1,524 @@
+package llm
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/kartoza/kartoza-pg-ai/internal/config"
+	"github.com/kartoza/kartoza-pg-ai/internal/postgres"
+)
+
+// maxSuggestions is how many of the closest names an unknown identifier
+// suggests
+const maxSuggestions = 3
+
+// UnknownIdentifier is a table or column SQL names that the harvested
+// schema does not hold
+type UnknownIdentifier struct {
+	Column      bool     // A column, rather than a table or view
+	Name        string   // As written, qualified when it was
+	Table       string   // "schema.table" a column was looked for in, when there was one
+	Suggestions []string // Closest names in the schema, closest first
+}
+
+// String describes an unknown identifier the way PostgreSQL reports it,
+// so repairs read it like the server's errors, with the suggestions added
+func (u UnknownIdentifier) String() string {
+	var s string
+	switch {
+	case !u.Column:
+		s = fmt.Sprintf("relation %q does not exist in the harvested schema", u.Name)
+	case strings.Contains(u.Name, "."):
+		s = fmt.Sprintf("column %s does not exist", u.Name)
+	default:
+		s = fmt.Sprintf("column %q does not exist", u.Name)
+	}
+	if u.Column && u.Table != "" {
+		s += " in " + u.Table
+	}
+	if len(u.Suggestions) > 0 {
+		s += " (did you mean " + strings.Join(u.Suggestions, ", ") + "?)"
+	}
+	return s
+}
+
+// UnknownIdentifiersError is returned for SQL naming tables or columns the
+// harvested schema does not hold, before the SQL is run. Refreshing the
+// schema picks up tables created since it was harvested.
+type UnknownIdentifiersError struct {
+	Unknown []UnknownIdentifier
+}
+
+func (e *UnknownIdentifiersError) Error() string {
+	lines := make([]string, len(e.Unknown))
+	for i, u := range e.Unknown {
+		lines[i] = u.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// StatementError returns the error as the message of a statement that
+// failed, so it is repaired like one PostgreSQL rejected
+func (e *UnknownIdentifiersError) StatementError() string {
+	return e.Error()
+}
+
+// Unsure reports whether every unknown identifier is a column named without
+// its table that is close to no column of the schema. Such a name may be a
+// keyword or a column of an outer query the check does not know, so the SQL
+// is better run and left to PostgreSQL than blocked.
+func (e *UnknownIdentifiersError) Unsure() bool {
+	for _, u := range e.Unknown {
+		if !u.Column || strings.Contains(u.Name, ".") || len(u.Suggestions) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckIdentifiers verifies that the tables, views and columns SQL names
+// exist in the schema, returning an *UnknownIdentifiersError with the
+// closest names for those that do not. Only what can be told without the
+// database is checked: relations in schemas that were not harvested, such
+// as pg_catalog, functions and subqueries are taken on trust, and columns
+// not qualified by a table are only checked in queries reading tables and
+// views alone.
+func (e *QueryEngine) CheckIdentifiers(sql string) error {
+	if e.schema == nil {
+		return nil
+	}
+	c := &identifierCheck{
+		schema:  e.schema,
+		schemas: make(map[string]bool),
+		defined: make(map[string]bool),
+		seen:    make(map[string]bool),
+	}
+	for _, t := range e.schema.Tables {
+		c.schemas[t.Schema] = true
+	}
+	for _, v := range e.schema.Views {
+		c.schemas[v.Schema] = true
+	}
+	for _, stmt := range postgres.SplitStatements(sql) {
+		c.checkStatement(stmt)
+	}
+	if len(c.unknown) == 0 {
+		return nil
+	}
+	return &UnknownIdentifiersError{Unknown: c.unknown}
+}
+
+// relationRef is a table or view a statement reads or writes
+type relationRef struct {
+	name    string              // "schema.name"
+	columns []config.ColumnInfo // nil when they are not known
+	opaque  bool                // Columns are not known: a function, subquery, CTE or unharvested relation
+}
+
+// identifierCheck is the state of CheckIdentifiers across the statements
+// of a script
+type identifierCheck struct {
+	schema  *config.SchemaCache
+	schemas map[string]bool // Schemas the cache holds relations of
+	defined map[string]bool // Relations the script creates and CTEs, lower-cased
+	seen    map[string]bool // Unknown identifiers reported, to report each once
+	unknown []UnknownIdentifier
+}
+
+// ignoredWords are bare words in queries that are neither keywords of the
+// lexer nor columns: date parts, AT TIME ZONE, FOR UPDATE SKIP LOCKED and
+// other special forms, window frames and FOR UPDATE OF
+var ignoredWords = map[string]bool{
+	"at": true, "time": true, "zone": true, "epoch": true, "year": true, "month": true,
+	"day": true, "hour": true, "minute": true, "second": true, "week": true,
+	"quarter": true, "dow": true, "doy": true, "isodow": true, "isoyear": true,
+	"decade": true, "century": true, "millennium": true, "milliseconds": true,
+	"microseconds": true, "timezone": true, "grouping": true, "next": true,
+	"unknown": true, "localtime": true, "localtimestamp": true, "current_role": true,
+	"current_schema": true, "current_catalog": true, "session_user": true, "user": true,
+	"skip": true, "locked": true, "nowait": true, "share": true, "no": true, "key": true,
+	"of": true, "rows": true, "range": true, "groups": true, "current": true, "row": true,
+	"unbounded": true, "preceding": true, "following": true, "exclude": true, "ties": true,
+	"others": true,
+}
+
+// systemColumns are the columns every table has without listing them
+var systemColumns = map[string]bool{
+	"ctid": true, "xmin": true, "xmax": true, "cmin": true, "cmax": true, "tableoid": true,
+}
+
+// checkStatement checks the relations and columns of one statement
+func (c *identifierCheck) checkStatement(stmt string) {
+	var toks []postgres.Token
+	for _, tok := range postgres.LexSQL(stmt) {
+		if tok.Kind != postgres.TokenWhitespace && tok.Kind != postgres.TokenComment {
+			toks = append(toks, tok)
+		}
+	}
+	if len(toks) == 0 {
+		return
+	}
+	// word reports whether toks[i] is one of words, bare; keyword too
+	// requires it to be lexed as a keyword
+	word := func(i int, words ...string) bool {
+		if i < 0 || i >= len(toks) || toks[i].Kind != postgres.TokenKeyword && toks[i].Kind != postgres.TokenIdentifier {
+			return false
+		}
+		for _, w := range words {
+			if strings.EqualFold(toks[i].Text, w) {
+				return true
+			}
+		}
+		return false
+	}
+	keyword := func(i int, words ...string) bool {
+		if i < 0 || i >= len(toks) || toks[i].Kind != postgres.TokenKeyword {
+			return false
+		}
+		for _, w := range words {
+			if strings.EqualFold(toks[i].Text, w) {
+				return true
+			}
+		}
+		return false
+	}
+	punct := func(i int, text string) bool {
+		return i >= 0 && i < len(toks) && (toks[i].Kind == postgres.TokenPunctuation || toks[i].Kind == postgres.TokenOperator) && toks[i].Text == text
+	}
+	name := func(i int) (string, bool) {
+		if i < 0 || i >= len(toks) {
+			return "", false
+		}
+		return identifierName(toks[i])
+	}
+
+	// Names the statement defines: CTEs and WINDOW names (name AS (...)),
+	// relations the statement creates, and aliases
+	aliases := make(map[string]bool)
+	for i := range toks {
+		n, ok := name(i)
+		if !ok {
+			continue
+		}
+		switch {
+		case keyword(i+1, "as") && punct(i+2, "("),
+			keyword(i+1, "as") && keyword(i+2, "materialized", "not"):
+			c.defined[n] = true
+		case keyword(i-1, "table", "view") && word(i-2, "create", "temp", "temporary", "unlogged", "materialized", "replace") ||
+			keyword(i-1, "exists") && keyword(i-2, "not") && keyword(i-3, "if"):
+			c.defined[n] = true
+		case keyword(i-1, "as"):
+			aliases[n] = true
+		case i > 0 && endsValue(toks[i-1]) && !punct(i+1, "("):
+			// A bare alias follows a value: SELECT count(*) total
+			aliases[n] = true
+		}
+	}
+
+	// Relations read or written, by the names and aliases they go by. A
+	// name given to two relations is ambiguous and left unchecked.
+	var relations []*relationRef
+	byName := make(map[string]*relationRef)
+	ambiguous := make(map[string]bool)
+	consumed := make(map[int]bool) // Tokens naming relations
+	simple := keyword(0, "select") // Unqualified columns can be checked
+	register := func(n string, r *relationRef) {
+		if prev, ok := byName[n]; ok && prev != r {
+			ambiguous[n] = true
+		}
+		byName[n] = r
+	}
+
+	// relation reads the relation named at i, returning the index after it.
+	// A target of INSERT INTO may be followed by its column list.
+	relation := func(i int, target bool) int {
+		for keyword(i, "only", "lateral") {
+			i++
+		}
+		if punct(i, "(") {
+			// Subquery or parenthesised join, checked with the rest
+			simple = false
+			return i
+		}
+		parts := []string{}
+		start := i
+		for {
+			n, ok := name(i)
+			if !ok {
+				break
+			}
+			parts = append(parts, n)
+			i++
+			if !punct(i, ".") {
+				break
+			}
+			i++
+		}
+		if len(parts) == 0 {
+			return i
+		}
+		if punct(i, "(") && !target {
+			// A function returning rows
+			simple = false
+			return i
+		}
+		for j := start; j < i; j++ {
+			consumed[j] = true
+		}
+		r := c.resolve(parts)
+		if r.opaque {
+			simple = false
+		}
+		relations = append(relations, r)
+		register(parts[len(parts)-1], r)
+
+		// Alias, with a list of column aliases hiding the columns' names
+		if target {
+			return i
+		}
+		if keyword(i, "as") {
+			i++
+		}
+		if n, ok := name(i); ok && !keyword(i, "on", "using") {
+			consumed[i] = true
+			register(n, r)
+			i++
+			if punct(i, "(") {
+				r.opaque = true
+				simple = false
+			}
+		}
+		return i
+	}
+
+	var parens []bool // Whether each open parenthesis is a subquery
+	for i := 0; i < len(toks); i++ {
+		switch {
+		case punct(i, "("):
+			sub := keyword(i+1, "select", "with", "values")
+			if sub {
+				simple = false
+			}
+			parens = append(parens, sub)
+		case punct(i, ")"):
+			if len(parens) > 0 {
+				parens = parens[:len(parens)-1]
+			}
+		case keyword(i, "union", "intersect", "except"):
+			simple = false
+		}
+
+		inExpression := len(parens) > 0 && !parens[len(parens)-1]
+		if inExpression {
+			// FROM of EXTRACT, SUBSTRING or TRIM
+			continue
+		}
+		switch {
+		case keyword(i, "from") && !keyword(i-1, "distinct"): // Not IS DISTINCT FROM
+			j := relation(i+1, false)
+			for punct(j, ",") {
+				j = relation(j+1, false)
+			}
+		case keyword(i, "join"), keyword(i, "update") && i == 0:
+			relation(i+1, false)
+		case keyword(i, "into") && keyword(i-1, "insert"):
+			relation(i+1, true)
+		}
+	}
+
+	// Columns qualified by a table or alias: alias.column
+	for i := 0; i+2 < len(toks); i++ {
+		if consumed[i] || !punct(i+1, ".") || punct(i+3, "(") || punct(i+3, ".") || punct(i-1, ".") {
+			continue
+		}
+		qualifier, ok := name(i)
+		column, ok2 := name(i + 2)
+		if !ok || !ok2 || ambiguous[qualifier] {
+			continue
+		}
+		r := byName[qualifier]
+		if r == nil || r.opaque || r.columns == nil {
+			continue
+		}
+		if !namesColumn(r.columns, column) {
+			c.report(UnknownIdentifier{Column: true, Name: qualifier + "." + column, Table: r.name,
+				Suggestions: closestColumns(column, r.columns)})
+		}
+	}
+
+	if !simple || len(relations) == 0 {
+		return
+	}
+	var columns []config.ColumnInfo
+	table := ""
+	for _, r := range relations {
+		if r.columns == nil {
+			return
+		}
+		columns = append(columns, r.columns...)
+		table = r.name
+	}
+	if len(relations) > 1 {
+		table = ""
+	}
+
+	// Unqualified columns of a query reading tables and views alone
+	for i, tok := range toks {
+		n, ok := identifierName(tok)
+		if !ok || consumed[i] || aliases[n] || byName[n] != nil || c.defined[n] {
+			continue
+		}
+		if tok.Kind == postgres.TokenIdentifier && ignoredWords[n] {
+			continue
+		}
+		if punct(i+1, "(") || punct(i+1, ".") || punct(i-1, ".") || punct(i-1, "::") || punct(i+1, "=>") ||
+			keyword(i-1, "as", "collate") || i+1 < len(toks) && toks[i+1].Kind == postgres.TokenString {
+			continue
+		}
+		if !namesColumn(columns, n) {
+			c.report(UnknownIdentifier{Column: true, Name: n, Table: table, Suggestions: closestColumns(n, columns)})
+		}
+	}
+}
+
+// endsValue reports whether a token can end a value, so a name after it
+// is an alias
+func endsValue(tok postgres.Token) bool {
+	switch tok.Kind {
+	case postgres.TokenIdentifier, postgres.TokenQuotedIdentifier, postgres.TokenString, postgres.TokenNumber:
+		return true
+	case postgres.TokenPunctuation:
+		return tok.Text == ")"
+	case postgres.TokenKeyword:
+		return strings.EqualFold(tok.Text, "end")
+	}
+	return false
+}
+
+// resolve finds the relation named by the parts of a possibly qualified
+// name, reporting it when its schema was harvested but does not hold it
+func (c *identifierCheck) resolve(parts []string) *relationRef {
+	n := parts[len(parts)-1]
+	schema := ""
+	if len(parts) > 1 {
+		schema = parts[len(parts)-2]
+	}
+	opaque := &relationRef{name: strings.Join(parts, "."), opaque: true}
+	switch {
+	case schema == "" && (c.defined[n] || strings.HasPrefix(n, "pg_")):
+		return opaque
+	case schema != "" && !c.schemas[schema]:
+		return opaque
+	}
+
+	// Unqualified, a name found in several schemas is whichever the search
+	// path finds first, so its columns are not known
+	var found []*relationRef
+	for _, t := range c.schema.Tables {
+		if t.Name == n && (schema == "" || t.Schema == schema) {
+			found = append(found, &relationRef{name: t.Schema + "." + t.Name, columns: knownColumns(t.Columns)})
+		}
+	}
+	for _, v := range c.schema.Views {
+		if v.Name == n && (schema == "" || v.Schema == schema) {
+			found = append(found, &relationRef{name: v.Schema + "." + v.Name, columns: knownColumns(v.Columns)})
+		}
+	}
+	for _, s := range c.schema.Sequences {
+		if s.Name == n && (schema == "" || s.Schema == schema) {
+			found = append(found, opaque)
+		}
+	}
+	switch len(found) {
+	case 0:
+		c.report(UnknownIdentifier{Name: opaque.name, Suggestions: c.closestRelations(n)})
+		return opaque
+	case 1:
+		return found[0]
+	}
+	return opaque
+}
+
+// knownColumns returns columns, nil when none were harvested
+func knownColumns(columns []config.ColumnInfo) []config.ColumnInfo {
+	if len(columns) == 0 {
+		return nil
+	}
+	return columns
+}
+
+// report records an unknown identifier once
+func (c *identifierCheck) report(u UnknownIdentifier) {
+	key := fmt.Sprint(u.Column, u.Name, u.Table)
+	if !c.seen[key] {
+		c.seen[key] = true
+		c.unknown = append(c.unknown, u)
+	}
+}
+
+// namesColumn reports whether columns holds one named name, or name is a
+// system column
+func namesColumn(columns []config.ColumnInfo, name string) bool {
+	if systemColumns[name] {
+		return true
+	}
+	for _, col := range columns {
+		if col.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// closestColumns returns the names of the columns closest to a missing one
+func closestColumns(name string, columns []config.ColumnInfo) []string {
+	var names []string
+	for _, col := range columns {
+		names = append(names, col.Name)
+	}
+	return closestNames(name, names, func(n string) string { return n })
+}
+
+// closestRelations returns the qualified names of the tables and views
+// closest to a missing one: those of the same name in other schemas first
+func (c *identifierCheck) closestRelations(name string) []string {
+	var names []string
+	for _, t := range c.schema.Tables {
+		names = append(names, t.Schema+"."+t.Name)
+	}
+	for _, v := range c.schema.Views {
+		names = append(names, v.Schema+"."+v.Name)
+	}
+	return closestNames(name, names, func(n string) string { return n[strings.LastIndex(n, ".")+1:] })
+}
+
+// closestNames returns up to maxSuggestions names whose base, as key
+// gives it, is close to name, closest first
+func closestNames(name string, names []string, key func(string) string) []string {
+	type candidate struct {
+		name     string
+		distance int
+	}
+	var candidates []candidate
+	for _, n := range names {
+		d, ok := nameDistance(name, key(n))
+		if key(n) == name {
+			d, ok = 0, true
+		}
+		if ok {
+			candidates = append(candidates, candidate{n, d})
+		}
+	}
+	sort.SliceStable(candidates, func(a, b int) bool {
+		return candidates[a].distance < candidates[b].distance
+	})
+	var closest []string
+	for _, cand := range candidates {
+		if len(closest) == maxSuggestions {
+			break
+		}
+		closest = append(closest, cand.name)
+	}
+	return closest
+}
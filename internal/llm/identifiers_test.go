@@ -0,0 +1,129 @@
+package llm
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/kartoza/kartoza-pg-ai/internal/config"
+	"github.com/kartoza/kartoza-pg-ai/internal/postgres"
+)
+
+func identifierSchema() *config.SchemaCache {
+	return &config.SchemaCache{
+		Tables: []config.TableInfo{
+			{Schema: "public", Name: "customers", Columns: []config.ColumnInfo{
+				{Name: "id"}, {Name: "name"}, {Name: "city"}, {Name: "created_at"}, {Name: "geom"},
+			}},
+			{Schema: "public", Name: "orders", Columns: []config.ColumnInfo{
+				{Name: "id"}, {Name: "customer_id"}, {Name: "total"}, {Name: "status"}, {Name: "placed_at"},
+			}},
+			{Schema: "cadastre", Name: "parcels", Columns: []config.ColumnInfo{{Name: "id"}, {Name: "geom"}}},
+		},
+		Views:     []config.ViewInfo{{Schema: "public", Name: "order_totals", Columns: []config.ColumnInfo{{Name: "customer_id"}, {Name: "total"}}}},
+		Sequences: []config.SequenceInfo{{Schema: "public", Name: "orders_id_seq"}},
+	}
+}
+
+func TestCheckIdentifiersAcceptsValidSQL(t *testing.T) {
+	engine := NewQueryEngine(identifierSchema())
+	valid := []string{
+		`SELECT * FROM "public"."customers" LIMIT 50`,
+		`SELECT name, city FROM customers WHERE city = 'Durban' ORDER BY name`,
+		`SELECT count(*) AS n, city FROM customers GROUP BY city ORDER BY n DESC`,
+		`SELECT count(*) total FROM orders WHERE status IN ('placed', 'shipped')`,
+		`SELECT c.name, o.total FROM customers c JOIN orders o ON o.customer_id = c.id`,
+		`SELECT customers.name FROM public.customers JOIN public.orders ON orders.customer_id = customers.id`,
+		`SELECT EXTRACT(year FROM placed_at) AS year, sum(total) FROM orders GROUP BY 1`,
+		`SELECT date_trunc('month', placed_at) AT TIME ZONE 'UTC', total::numeric(10, 2) FROM orders`,
+		`SELECT * FROM orders WHERE placed_at > now() - interval '7 days' AND status IS DISTINCT FROM 'returned'`,
+		`SELECT CAST(total AS double precision) FROM orders WHERE placed_at::timestamp with time zone > DATE '2024-01-01'`,
+		`SELECT name FROM customers WHERE id IN (SELECT customer_id FROM orders WHERE total > 100)`,
+		`WITH big AS (SELECT customer_id FROM orders WHERE total > 100) SELECT * FROM big JOIN customers ON customers.id = big.customer_id`,
+		`SELECT ST_AsText(geom) FROM cadastre.parcels WHERE ST_Intersects(geom, ST_MakeEnvelope(0, 0, 1, 1, 4326))`,
+		`SELECT * FROM pg_catalog.pg_tables`,
+		`SELECT relname FROM pg_class`,
+		`SELECT * FROM information_schema.columns WHERE table_name = 'customers'`,
+		`SELECT * FROM generate_series(1, 10) g`,
+		`SELECT last_value FROM orders_id_seq`,
+		`SELECT customer_id, total FROM order_totals`,
+		`SELECT CASE WHEN total > 100 THEN 'big' ELSE 'small' END size, count(*) FROM orders GROUP BY size`,
+		`SELECT name FROM customers UNION SELECT status FROM orders`,
+		`CREATE TEMP TABLE recent AS SELECT * FROM orders WHERE placed_at > now() - interval '1 day'; SELECT count(*) FROM recent`,
+		`SELECT row_number() OVER (PARTITION BY city ORDER BY created_at) FROM customers`,
+		`SELECT * FROM some_schema.some_table`,
+		`INSERT INTO orders (customer_id, total) VALUES (1, 10)`,
+		`SELECT "name" FROM "customers" WHERE "city" ILIKE '%town%'`,
+		`SELECT customer_id, sum(total) OVER (ORDER BY placed_at ROWS BETWEEN UNBOUNDED PRECEDING AND CURRENT ROW) FROM orders`,
+		`SELECT sum(total) OVER (ORDER BY placed_at RANGE BETWEEN CURRENT ROW AND UNBOUNDED FOLLOWING EXCLUDE TIES) FROM orders`,
+		`SELECT ctid, tableoid FROM orders`,
+		`SELECT xmin FROM orders`,
+		`SELECT o.xmax FROM orders o`,
+		`SELECT name FROM customers FOR UPDATE OF customers SKIP LOCKED`,
+	}
+	for _, sql := range valid {
+		if err := engine.CheckIdentifiers(sql); err != nil {
+			t.Errorf("valid SQL rejected: %s\n%v", sql, err)
+		}
+	}
+}
+
+func TestCheckIdentifiersReportsUnknownNames(t *testing.T) {
+	engine := NewQueryEngine(identifierSchema())
+	tests := []struct {
+		sql  string
+		want []string
+	}{
+		{`SELECT * FROM cusomers`, []string{`relation "cusomers" does not exist in the harvested schema (did you mean public.customers?)`}},
+		{`SELECT * FROM public.parcels`, []string{`relation "public.parcels" does not exist in the harvested schema (did you mean cadastre.parcels?)`}},
+		{`SELECT nmae FROM customers`, []string{`column "nmae" does not exist in public.customers (did you mean name?)`}},
+		{`SELECT c.nmae FROM customers c JOIN orders o ON o.customer_id = c.id`, []string{`column c.nmae does not exist in public.customers (did you mean name?)`}},
+		{`SELECT o.amount FROM orders o`, []string{`column o.amount does not exist in public.orders`}},
+		{`SELECT revenue FROM customers JOIN orders ON orders.customer_id = customers.id`, []string{`column "revenue" does not exist`}},
+	}
+	for _, tt := range tests {
+		err := engine.CheckIdentifiers(tt.sql)
+		var unknown *UnknownIdentifiersError
+		if !errors.As(err, &unknown) {
+			t.Errorf("%s: expected unknown identifiers, got %v", tt.sql, err)
+			continue
+		}
+		if got := strings.Split(err.Error(), "\n"); strings.Join(got, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("%s:\n got %q\nwant %q", tt.sql, got, tt.want)
+		}
+	}
+}
+
+func TestUnknownIdentifiersUnsure(t *testing.T) {
+	engine := NewQueryEngine(identifierSchema())
+	for sql, want := range map[string]bool{
+		`SELECT revenue FROM customers JOIN orders ON orders.customer_id = customers.id`: true,
+		`SELECT nmae FROM customers`:    false,
+		`SELECT o.amount FROM orders o`: false,
+		`SELECT revenue FROM cusomers`:  false,
+	} {
+		var unknown *UnknownIdentifiersError
+		if err := engine.CheckIdentifiers(sql); !errors.As(err, &unknown) {
+			t.Errorf("%s: expected unknown identifiers, got %v", sql, err)
+		} else if unknown.Unsure() != want {
+			t.Errorf("%s: Unsure() = %v, want %v", sql, !want, want)
+		}
+	}
+}
+
+func TestUnknownIdentifiersAreRepaired(t *testing.T) {
+	engine := NewQueryEngine(identifierSchema())
+	sql := `SELECT nmae FROM customers`
+	err := engine.CheckIdentifiers(sql)
+	message, ok := postgres.StatementError(err)
+	if !ok {
+		t.Fatalf("unknown identifiers should be a statement error: %v", err)
+	}
+	repaired, err := engine.RepairSQL(t.Context(), "customer names", "", []config.FailedSQL{{SQL: sql, Error: message}})
+	if err != nil {
+		t.Fatalf("RepairSQL failed: %v", err)
+	}
+	if repaired != `SELECT "name" FROM customers` {
+		t.Errorf("repaired = %s", repaired)
+	}
+}
@@ -0,0 +1,164 @@
+package llm
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// fkEdge is one step across a foreign key between two tables, oriented in
+// the direction it is traversed. Tables are indices into the schema's Tables.
+type fkEdge struct {
+	from, to       int
+	fromCol, toCol string
+}
+
+// padWords normalises a query to space-separated words with a leading and
+// trailing space, so " word " lookups only match whole words
+func padWords(query string) string {
+	words := strings.Fields(regexp.MustCompile(`[^\w\s-]+`).ReplaceAllString(query, " "))
+	return " " + strings.Join(words, " ") + " "
+}
+
+// tableMention returns the position of the first whole-word mention of a
+// table (as written, singular or plural) in padded, or -1
+func tableMention(padded, tableName string) int {
+	name := strings.ToLower(tableName)
+	singular := strings.TrimSuffix(name, "s")
+	pos := -1
+	for _, form := range []string{name, singular, singular + "s"} {
+		if idx := strings.Index(padded, " "+form+" "); idx >= 0 && (pos < 0 || idx < pos) {
+			pos = idx
+		}
+	}
+	return pos
+}
+
+// buildJoinGraph builds an undirected graph of the schema's tables with an
+// edge per foreign key column
+func (e *QueryEngine) buildJoinGraph() map[int][]fkEdge {
+	graph := make(map[int][]fkEdge)
+	for i, table := range e.schema.Tables {
+		for _, c := range table.Columns {
+			if !c.IsForeignKey || c.FKTable == "" || c.FKColumn == "" {
+				continue
+			}
+			target := e.resolveFKTable(table.Schema, c.FKTable)
+			if target < 0 || target == i {
+				continue
+			}
+			graph[i] = append(graph[i], fkEdge{from: i, to: target, fromCol: c.Name, toCol: c.FKColumn})
+			graph[target] = append(graph[target], fkEdge{from: target, to: i, fromCol: c.FKColumn, toCol: c.Name})
+		}
+	}
+	return graph
+}
+
+// resolveFKTable finds the index of a referenced table, preferring the
+// referencing table's schema when the name is not schema-qualified
+func (e *QueryEngine) resolveFKTable(schema, name string) int {
+	if idx := strings.LastIndex(name, "."); idx >= 0 {
+		schema, name = name[:idx], name[idx+1:]
+	}
+	match := -1
+	for i, t := range e.schema.Tables {
+		if t.Name != name {
+			continue
+		}
+		if t.Schema == schema {
+			return i
+		}
+		if match < 0 {
+			match = i
+		}
+	}
+	return match
+}
+
+// joinPath returns the shortest chain of foreign keys from one table to
+// another (breadth-first search), or nil if they are not connected
+func joinPath(graph map[int][]fkEdge, from, to int) []fkEdge {
+	if from == to {
+		return nil
+	}
+
+	via := map[int]fkEdge{}
+	visited := map[int]bool{from: true}
+	queue := []int{from}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, edge := range graph[current] {
+			if visited[edge.to] {
+				continue
+			}
+			visited[edge.to] = true
+			via[edge.to] = edge
+			if edge.to == to {
+				var path []fkEdge
+				for node := to; node != from; node = via[node].from {
+					path = append([]fkEdge{via[node]}, path...)
+				}
+				return path
+			}
+			queue = append(queue, edge.to)
+		}
+	}
+	return nil
+}
+
+// matchJoinQuery handles questions naming two or more tables, such as "show
+// customers with their orders", by joining them along the shortest foreign
+// key paths from the first table mentioned. Tables only needed to bridge the
+// path are joined but not selected.
+func (e *QueryEngine) matchJoinQuery(query string) string {
+	padded := padWords(query)
+
+	type mention struct{ table, pos int }
+	var mentioned []mention
+	for i, t := range e.schema.Tables {
+		if pos := tableMention(padded, t.Name); pos >= 0 {
+			mentioned = append(mentioned, mention{table: i, pos: pos})
+		}
+	}
+	if len(mentioned) < 2 {
+		return ""
+	}
+	sort.SliceStable(mentioned, func(a, b int) bool { return mentioned[a].pos < mentioned[b].pos })
+
+	graph := e.buildJoinGraph()
+	root := mentioned[0].table
+	aliases := map[int]string{root: "t1"}
+	selected := []int{root}
+	var joins []string
+
+	for _, m := range mentioned[1:] {
+		path := joinPath(graph, root, m.table)
+		if path == nil {
+			continue
+		}
+		for _, edge := range path {
+			if _, ok := aliases[edge.to]; ok {
+				continue
+			}
+			alias := fmt.Sprintf("t%d", len(aliases)+1)
+			aliases[edge.to] = alias
+			t := e.schema.Tables[edge.to]
+			joins = append(joins, fmt.Sprintf("JOIN \"%s\".\"%s\" %s ON %s.\"%s\" = %s.\"%s\"",
+				t.Schema, t.Name, alias, aliases[edge.from], edge.fromCol, alias, edge.toCol))
+		}
+		selected = append(selected, m.table)
+	}
+	if len(joins) == 0 {
+		return ""
+	}
+
+	var cols []string
+	for _, idx := range selected {
+		cols = append(cols, aliases[idx]+".*")
+	}
+	t := e.schema.Tables[root]
+	return fmt.Sprintf("SELECT %s FROM \"%s\".\"%s\" t1 %s%s",
+		strings.Join(cols, ", "), t.Schema, t.Name, strings.Join(joins, " "), e.limitClause())
+}
@@ -0,0 +1,152 @@
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/kartoza/kartoza-pg-ai/internal/config"
+)
+
+// jsonColumn is a json or jsonb column together with its table
+type jsonColumn struct {
+	table  config.TableInfo
+	column config.ColumnInfo
+}
+
+// jsonFilter is a condition on a JSON document recognised in a question
+type jsonFilter struct {
+	path  []string // Keys from the document root
+	value string   // Compared value; empty for key existence and text search
+	kind  string   // "equals", "has key" or "text"
+}
+
+// jsonColumns returns the JSON columns of the schema, with those of tables
+// mentioned in the query first
+func (e *QueryEngine) jsonColumns(padded string) []jsonColumn {
+	var mentioned, others []jsonColumn
+	for _, table := range e.schema.Tables {
+		for _, c := range table.Columns {
+			if !c.IsJSON() {
+				continue
+			}
+			if tableMention(padded, table.Name) >= 0 {
+				mentioned = append(mentioned, jsonColumn{table, c})
+			} else {
+				others = append(others, jsonColumn{table, c})
+			}
+		}
+	}
+	return append(mentioned, others...)
+}
+
+// matchJSONQuery handles conditions on json/jsonb columns named in the
+// query, such as "events where payload.status is failed", "payload contains
+// status=failed", "payload has key error" or "payload contains timeout".
+// Original casing is kept because JSON keys and values are case sensitive.
+func (e *QueryEngine) matchJSONQuery(query string) string {
+	lower := strings.ToLower(query)
+	padded := padWords(lower)
+
+	for _, jc := range e.jsonColumns(padded) {
+		filter, ok := parseJSONFilter(query, jc.column.Name)
+		if !ok {
+			continue
+		}
+		where := jsonCondition(jc.column, filter)
+		if strings.Contains(lower, "how many") || strings.HasPrefix(lower, "count") {
+			return fmt.Sprintf("SELECT COUNT(*) as count FROM \"%s\".\"%s\" WHERE %s", jc.table.Schema, jc.table.Name, where)
+		}
+		return fmt.Sprintf("SELECT * FROM \"%s\".\"%s\" WHERE %s%s", jc.table.Schema, jc.table.Name, where, e.limitClause())
+	}
+	return ""
+}
+
+// parseJSONFilter finds a condition on the named column in the query
+func parseJSONFilter(query, column string) (jsonFilter, bool) {
+	col := regexp.QuoteMeta(column)
+	value := `['"]?(.+?)['"]?[?.!]*$`
+
+	// payload.status is failed / payload.user.name = 'bob'
+	if m := regexp.MustCompile(`(?i)\b` + col + `((?:\.\w+)+)\s+(?:is|=|==|equals)\s+` + value).FindStringSubmatch(query); m != nil {
+		return jsonFilter{path: strings.Split(strings.TrimPrefix(m[1], "."), "."), value: m[2], kind: "equals"}, true
+	}
+	// payload contains status=failed / payload with user.name: bob
+	if m := regexp.MustCompile(`(?i)\b` + col + `\s+(?:contains|has|with|where)\s+(\w+(?:\.\w+)*)\s*(?:=|:|\bis\b)\s*` + value).FindStringSubmatch(query); m != nil {
+		return jsonFilter{path: strings.Split(m[1], "."), value: m[2], kind: "equals"}, true
+	}
+	// payload has key error / payload with a key named error
+	if m := regexp.MustCompile(`(?i)\b` + col + `\s+(?:has|with|contains)\s+(?:an?\s+|the\s+)?key\s+(?:named\s+|called\s+)?['"]?(\w+(?:\.\w+)*)`).FindStringSubmatch(query); m != nil {
+		return jsonFilter{path: strings.Split(m[1], "."), kind: "has key"}, true
+	}
+	// payload contains timeout
+	if m := regexp.MustCompile(`(?i)\b` + col + `\s+(?:contains|mentions|includes)\s+` + value).FindStringSubmatch(query); m != nil {
+		return jsonFilter{value: m[1], kind: "text"}, true
+	}
+	return jsonFilter{}, false
+}
+
+// jsonCondition renders a filter as SQL. jsonb equality uses containment
+// (@>), which GIN indexes can serve; json has no operators beyond the path
+// extractors, so it compares extracted text.
+func jsonCondition(c config.ColumnInfo, f jsonFilter) string {
+	col := fmt.Sprintf("\"%s\"", c.Name)
+	switch f.kind {
+	case "equals":
+		if c.DataType == "jsonb" {
+			return fmt.Sprintf("%s @> %s", col, sqlString(containmentDocument(f.path, f.value)))
+		}
+		return fmt.Sprintf("%s = %s", jsonTextPath(col, f.path), sqlString(f.value))
+	case "has key":
+		if c.DataType == "jsonb" && len(f.path) == 1 {
+			return fmt.Sprintf("%s ? %s", col, sqlString(f.path[0]))
+		}
+		return fmt.Sprintf("%s IS NOT NULL", jsonPath(col, f.path))
+	default:
+		return fmt.Sprintf("%s::text ILIKE %s", col, sqlString("%"+f.value+"%"))
+	}
+}
+
+// jsonTextPath extracts the value at path as text: col->>'a' or col#>>'{a,b}'
+func jsonTextPath(col string, path []string) string {
+	if len(path) == 1 {
+		return fmt.Sprintf("%s->>%s", col, sqlString(path[0]))
+	}
+	return fmt.Sprintf("%s#>>%s", col, sqlString("{"+strings.Join(path, ",")+"}"))
+}
+
+// jsonPath extracts the JSON value at path: col->'a' or col#>'{a,b}'
+func jsonPath(col string, path []string) string {
+	if len(path) == 1 {
+		return fmt.Sprintf("%s->%s", col, sqlString(path[0]))
+	}
+	return fmt.Sprintf("%s#>%s", col, sqlString("{"+strings.Join(path, ",")+"}"))
+}
+
+// containmentDocument builds the JSON document {"a":{"b":value}} for @>.
+// Numbers, booleans and null keep their JSON type; anything else is a string.
+func containmentDocument(path []string, value string) string {
+	var doc any = value
+	switch lower := strings.ToLower(value); {
+	case lower == "true" || lower == "false":
+		doc = lower == "true"
+	case lower == "null":
+		doc = nil
+	default:
+		if n, err := strconv.ParseFloat(value, 64); err == nil {
+			doc = json.Number(strconv.FormatFloat(n, 'f', -1, 64))
+		}
+	}
+	for i := len(path) - 1; i >= 0; i-- {
+		doc = map[string]any{path[i]: doc}
+	}
+	data, _ := json.Marshal(doc)
+	return string(data)
+}
+
+// sqlString quotes s as an SQL string literal
+func sqlString(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
@@ -0,0 +1,313 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kartoza/kartoza-pg-ai/internal/config"
+)
+
+// LocalModel is a model recommended for answering questions without a
+// hosted provider
+type LocalModel struct {
+	Name        string // Ollama model tag, or the GGUF file name in config.ModelsDir
+	Provider    string // ProviderOllama or ProviderLlamaCpp
+	URL         string // Where a GGUF file is downloaded from
+	Size        int64  // Approximate download size in bytes
+	Description string
+}
+
+// RecommendedModels are local models that write SQL well, smallest first
+// for each provider
+var RecommendedModels = []LocalModel{
+	{
+		Name:        "qwen2.5-coder:1.5b",
+		Provider:    ProviderOllama,
+		Size:        986 << 20,
+		Description: "Small coding model, runs on most laptops",
+	},
+	{
+		Name:        "sqlcoder:7b",
+		Provider:    ProviderOllama,
+		Size:        4100 << 20,
+		Description: "Fine-tuned for text-to-SQL on PostgreSQL",
+	},
+	{
+		Name:        "qwen2.5-coder:7b",
+		Provider:    ProviderOllama,
+		Size:        4700 << 20,
+		Description: "Strong general coding model, good at joins",
+	},
+	{
+		Name:        "llama3.1:8b",
+		Provider:    ProviderOllama,
+		Size:        4900 << 20,
+		Description: "General model, also good at explaining SQL",
+	},
+	{
+		Name:        "qwen2.5-coder-1.5b-instruct-q4_k_m.gguf",
+		Provider:    ProviderLlamaCpp,
+		URL:         "https://huggingface.co/Qwen/Qwen2.5-Coder-1.5B-Instruct-GGUF/resolve/main/qwen2.5-coder-1.5b-instruct-q4_k_m.gguf",
+		Size:        1120 << 20,
+		Description: "Small coding model for llama.cpp",
+	},
+	{
+		Name:        "qwen2.5-coder-7b-instruct-q4_k_m.gguf",
+		Provider:    ProviderLlamaCpp,
+		URL:         "https://huggingface.co/Qwen/Qwen2.5-Coder-7B-Instruct-GGUF/resolve/main/qwen2.5-coder-7b-instruct-q4_k_m.gguf",
+		Size:        4680 << 20,
+		Description: "Strong coding model for llama.cpp",
+	},
+	{
+		Name:        "sqlcoder-7b-q5_k_m.gguf",
+		Provider:    ProviderLlamaCpp,
+		URL:         "https://huggingface.co/defog/sqlcoder-7b-2/resolve/main/sqlcoder-7b-q5_k_m.gguf",
+		Size:        4780 << 20,
+		Description: "Text-to-SQL fine-tune for llama.cpp",
+	},
+}
+
+// DownloadProgress reports how far a model download has got
+type DownloadProgress struct {
+	Status    string // What is being done, e.g. "downloading"
+	Completed int64  // Bytes done so far
+	Total     int64  // Bytes in all; 0 while unknown
+}
+
+// LocalModelPath returns where a GGUF model is downloaded to
+func LocalModelPath(m LocalModel) (string, error) {
+	dir, err := config.ModelsDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, m.Name), nil
+}
+
+// InstalledModels returns the names of the recommended models already
+// downloaded. Ollama is asked for its models at ollamaBaseURL; when it
+// cannot be reached the error is returned with the GGUF models found.
+func InstalledModels(ctx context.Context, ollamaBaseURL string) (map[string]bool, error) {
+	installed := make(map[string]bool)
+	for _, m := range RecommendedModels {
+		if m.Provider != ProviderLlamaCpp {
+			continue
+		}
+		if path, err := LocalModelPath(m); err == nil {
+			if _, err := os.Stat(path); err == nil {
+				installed[m.Name] = true
+			}
+		}
+	}
+
+	tags, err := ollamaModels(ctx, ollamaBaseURL)
+	for _, tag := range tags {
+		installed[tag] = true
+	}
+	return installed, err
+}
+
+// ollamaModels lists the models an Ollama server has pulled
+func ollamaModels(ctx context.Context, baseURL string) ([]string, error) {
+	if baseURL == "" {
+		baseURL = defaultOllamaBaseURL
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(baseURL, "/")+"/api/tags", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("ollama unreachable at %s: %w", baseURL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("ollama returned status %d", resp.StatusCode)
+	}
+
+	var tags struct {
+		Models []struct {
+			Name string `json:"name"`
+		} `json:"models"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tags); err != nil {
+		return nil, fmt.Errorf("invalid ollama response: %w", err)
+	}
+	var names []string
+	for _, m := range tags.Models {
+		names = append(names, m.Name)
+	}
+	return names, nil
+}
+
+// DownloadModel downloads a recommended model, pulling it into Ollama or
+// fetching its GGUF file into config.ModelsDir, and returns the Ollama tag
+// or the file's path. progress is called as the download advances.
+func DownloadModel(ctx context.Context, m LocalModel, ollamaBaseURL string, progress func(DownloadProgress)) (string, error) {
+	switch m.Provider {
+	case ProviderOllama:
+		return m.Name, PullOllamaModel(ctx, ollamaBaseURL, m.Name, progress)
+	case ProviderLlamaCpp:
+		path, err := LocalModelPath(m)
+		if err != nil {
+			return "", err
+		}
+		return path, DownloadFile(ctx, m.URL, path, progress)
+	default:
+		return "", fmt.Errorf("models of provider %s cannot be downloaded", m.Provider)
+	}
+}
+
+// UseLocalModel configures settings to answer questions with a downloaded
+// model; ref is what DownloadModel returned
+func UseLocalModel(settings *config.Settings, m LocalModel, ref string) {
+	settings.LLMProvider = m.Provider
+	switch m.Provider {
+	case ProviderOllama:
+		settings.OllamaModel = ref
+	case ProviderLlamaCpp:
+		settings.LLMModelPath = ref
+	}
+}
+
+// PullOllamaModel asks an Ollama server to pull a model, reporting the
+// progress it streams
+func PullOllamaModel(ctx context.Context, baseURL, tag string, progress func(DownloadProgress)) error {
+	if baseURL == "" {
+		baseURL = defaultOllamaBaseURL
+	}
+	body, err := json.Marshal(map[string]any{"model": tag, "stream": true})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(baseURL, "/")+"/api/pull", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("ollama unreachable at %s: %w", baseURL, err)
+	}
+	defer resp.Body.Close()
+
+	var line struct {
+		Status    string `json:"status"`
+		Total     int64  `json:"total"`
+		Completed int64  `json:"completed"`
+		Error     string `json:"error"`
+	}
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		line.Total, line.Completed = 0, 0
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			return fmt.Errorf("invalid ollama pull stream: %w", err)
+		}
+		if line.Error != "" {
+			return fmt.Errorf("ollama error: %s", line.Error)
+		}
+		if progress != nil {
+			progress(DownloadProgress{Status: line.Status, Completed: line.Completed, Total: line.Total})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("ollama pull interrupted: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("ollama returned status %d", resp.StatusCode)
+	}
+	if line.Status != "success" {
+		return fmt.Errorf("ollama pull of %s did not finish", tag)
+	}
+	return nil
+}
+
+// DownloadFile downloads url to path, reporting progress. The download
+// is written to path.part first, so an interrupted one resumes where it
+// stopped when the server supports ranges.
+func DownloadFile(ctx context.Context, url, path string, progress func(DownloadProgress)) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	part := path + ".part"
+	var offset int64
+	if info, err := os.Stat(part); err == nil {
+		offset = info.Size()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("download failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	flags := os.O_CREATE | os.O_WRONLY
+	switch {
+	case resp.StatusCode == http.StatusPartialContent:
+		flags |= os.O_APPEND
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && offset > 0:
+		// The partial file is already whole
+		return os.Rename(part, path)
+	case resp.StatusCode == http.StatusOK:
+		offset = 0
+		flags |= os.O_TRUNC
+	default:
+		return fmt.Errorf("download of %s returned status %d", url, resp.StatusCode)
+	}
+
+	f, err := os.OpenFile(part, flags, 0644)
+	if err != nil {
+		return err
+	}
+	total := int64(0)
+	if resp.ContentLength > 0 {
+		total = offset + resp.ContentLength
+	}
+	w := &progressWriter{w: f, done: offset, total: total, progress: progress}
+	_, err = io.Copy(w, resp.Body)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("download interrupted: %w", err)
+	}
+	if total > 0 && w.done != total {
+		return fmt.Errorf("download incomplete: %d of %d bytes", w.done, total)
+	}
+	return os.Rename(part, path)
+}
+
+// progressWriter reports the bytes written through it
+type progressWriter struct {
+	w        io.Writer
+	done     int64
+	total    int64
+	progress func(DownloadProgress)
+}
+
+func (p *progressWriter) Write(b []byte) (int, error) {
+	n, err := p.w.Write(b)
+	p.done += int64(n)
+	if p.progress != nil {
+		p.progress(DownloadProgress{Status: "downloading", Completed: p.done, Total: p.total})
+	}
+	return n, err
+}
@@ -0,0 +1,103 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kartoza/kartoza-pg-ai/internal/config"
+)
+
+func TestDownloadFileResumes(t *testing.T) {
+	content := bytes.Repeat([]byte("gguf"), 10000)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "model.gguf", time.Time{}, bytes.NewReader(content))
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "models", "model.gguf")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	// An earlier download stopped part way
+	if err := os.WriteFile(path+".part", content[:12345], 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var last DownloadProgress
+	if err := DownloadFile(context.Background(), server.URL, path, func(p DownloadProgress) { last = p }); err != nil {
+		t.Fatalf("DownloadFile error: %v", err)
+	}
+	got, err := os.ReadFile(path)
+	if err != nil || !bytes.Equal(got, content) {
+		t.Fatalf("downloaded %d bytes, want %d (%v)", len(got), len(content), err)
+	}
+	if last.Completed != int64(len(content)) || last.Total != int64(len(content)) {
+		t.Errorf("last progress = %+v", last)
+	}
+	if _, err := os.Stat(path + ".part"); !os.IsNotExist(err) {
+		t.Errorf("partial file left behind")
+	}
+}
+
+func TestPullOllamaModel(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Model string `json:"model"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		if r.URL.Path != "/api/pull" || req.Model != "sqlcoder:7b" {
+			t.Errorf("request %s for %q", r.URL.Path, req.Model)
+		}
+		w.Write([]byte(`{"status":"pulling manifest"}
+{"status":"pulling 6a0746a1ec1a","total":1000,"completed":400}
+{"status":"pulling 6a0746a1ec1a","total":1000,"completed":1000}
+{"status":"success"}
+`))
+	}))
+	defer server.Close()
+
+	var updates []DownloadProgress
+	if err := PullOllamaModel(context.Background(), server.URL, "sqlcoder:7b", func(p DownloadProgress) {
+		updates = append(updates, p)
+	}); err != nil {
+		t.Fatalf("PullOllamaModel error: %v", err)
+	}
+	if len(updates) != 4 || updates[1].Completed != 400 || updates[1].Total != 1000 || updates[3].Total != 0 {
+		t.Errorf("progress = %+v", updates)
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"error":"pull model manifest: file does not exist"}`))
+	}))
+	defer failing.Close()
+	err := PullOllamaModel(context.Background(), failing.URL, "nope:1b", nil)
+	if err == nil || !strings.Contains(err.Error(), "file does not exist") {
+		t.Errorf("error = %v", err)
+	}
+}
+
+func TestUseLocalModel(t *testing.T) {
+	settings := config.DefaultConfig().Settings
+	for _, m := range RecommendedModels {
+		if m.Provider == ProviderLlamaCpp {
+			UseLocalModel(&settings, m, "/models/"+m.Name)
+			if settings.LLMProvider != ProviderLlamaCpp || settings.LLMModelPath != "/models/"+m.Name {
+				t.Errorf("settings after %s: %s, %s", m.Name, settings.LLMProvider, settings.LLMModelPath)
+			}
+			break
+		}
+	}
+	UseLocalModel(&settings, RecommendedModels[0], RecommendedModels[0].Name)
+	if settings.LLMProvider != ProviderOllama || settings.OllamaModel != RecommendedModels[0].Name {
+		t.Errorf("settings after %s: %s, %s", RecommendedModels[0].Name, settings.LLMProvider, settings.OllamaModel)
+	}
+}
@@ -0,0 +1,165 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Default Ollama settings
+const (
+	defaultOllamaBaseURL = "http://localhost:11434"
+	defaultOllamaModel   = "llama3"
+)
+
+// OllamaProvider generates SQL using a locally hosted Ollama model
+type OllamaProvider struct {
+	baseURL string
+	model   string
+	client  *http.Client
+}
+
+// NewOllamaProvider creates a new Ollama provider.
+// Empty baseURL or model fall back to the defaults.
+func NewOllamaProvider(baseURL, model string) *OllamaProvider {
+	if baseURL == "" {
+		baseURL = defaultOllamaBaseURL
+	}
+	if model == "" {
+		model = defaultOllamaModel
+	}
+	return &OllamaProvider{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		model:   model,
+		// No client timeout: local models can be slow to stream, the
+		// request context bounds the total time instead
+		client: &http.Client{},
+	}
+}
+
+// Name returns the provider identifier
+func (p *OllamaProvider) Name() string {
+	return ProviderOllama
+}
+
+// Model returns the model the provider asks
+func (p *OllamaProvider) Model() string {
+	return p.model
+}
+
+// ollamaChatRequest is the request body for /api/chat
+type ollamaChatRequest struct {
+	Model    string         `json:"model"`
+	Messages []chatMessage  `json:"messages"`
+	Stream   bool           `json:"stream"`
+	Options  map[string]any `json:"options,omitempty"`
+}
+
+// ollamaChatChunk is a single line of the streamed /api/chat response
+type ollamaChatChunk struct {
+	Message chatMessage `json:"message"`
+	Done    bool        `json:"done"`
+	Error   string      `json:"error,omitempty"`
+
+	// Token counts, sent with the last chunk
+	PromptEvalCount int `json:"prompt_eval_count,omitempty"`
+	EvalCount       int `json:"eval_count,omitempty"`
+}
+
+// GenerateSQL streams a chat completion from Ollama and returns the SQL
+func (p *OllamaProvider) GenerateSQL(ctx context.Context, req GenerationRequest) (string, error) {
+	content, err := p.chat(ctx, buildSystemPrompt(req), buildUserPrompt(req))
+	if err != nil {
+		return "", err
+	}
+	sql := extractSQL(content)
+	if sql == "" {
+		return "", fmt.Errorf("ollama returned an empty response")
+	}
+	return sql, nil
+}
+
+// ExplainSQL asks the model to describe the SQL in plain English
+func (p *OllamaProvider) ExplainSQL(ctx context.Context, req ExplanationRequest) (string, error) {
+	content, err := p.chat(ctx, buildExplainSystemPrompt(req), buildExplainUserPrompt(req))
+	if err != nil {
+		return "", err
+	}
+	explanation := strings.TrimSpace(content)
+	if explanation == "" {
+		return "", fmt.Errorf("ollama returned an empty response")
+	}
+	return explanation, nil
+}
+
+// chat streams a completion of a system and user message and returns the
+// whole reply
+func (p *OllamaProvider) chat(ctx context.Context, system, user string) (string, error) {
+	body, err := json.Marshal(ollamaChatRequest{
+		Model: p.model,
+		Messages: []chatMessage{
+			{Role: "system", Content: system},
+			{Role: "user", Content: user},
+		},
+		Stream:  true,
+		Options: map[string]any{"temperature": 0},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/api/chat", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return "", fmt.Errorf("ollama unreachable at %s: %w", p.baseURL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(resp.Body)
+		var chunk ollamaChatChunk
+		if json.Unmarshal(data, &chunk) == nil && chunk.Error != "" {
+			return "", fmt.Errorf("ollama error: %s", chunk.Error)
+		}
+		return "", fmt.Errorf("ollama returned status %d", resp.StatusCode)
+	}
+
+	var content strings.Builder
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var chunk ollamaChatChunk
+		if err := json.Unmarshal(line, &chunk); err != nil {
+			return "", fmt.Errorf("invalid ollama stream: %w", err)
+		}
+		if chunk.Error != "" {
+			return "", fmt.Errorf("ollama error: %s", chunk.Error)
+		}
+
+		content.WriteString(chunk.Message.Content)
+		if chunk.Done {
+			reportUsage(ctx, ProviderOllama, p.model, chunk.PromptEvalCount, chunk.EvalCount)
+			break
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("ollama stream interrupted: %w", err)
+	}
+
+	return content.String(), nil
+}
@@ -0,0 +1,182 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Default OpenAI settings
+const (
+	defaultOpenAIBaseURL = "https://api.openai.com/v1"
+	defaultOpenAIModel   = "gpt-4o-mini"
+
+	defaultLlamaCppBaseURL = "http://localhost:8080/v1" // Where llama-server listens by default
+)
+
+// OpenAIProvider generates SQL using the OpenAI chat completions API, or
+// a server compatible with it
+type OpenAIProvider struct {
+	name    string // ProviderOpenAI, or ProviderLlamaCpp for a llama.cpp server
+	apiKey  string
+	model   string
+	baseURL string
+	client  *http.Client
+}
+
+// NewOpenAIProvider creates a new OpenAI provider.
+// Empty model or baseURL fall back to the defaults.
+func NewOpenAIProvider(apiKey, model, baseURL string) *OpenAIProvider {
+	if model == "" {
+		model = defaultOpenAIModel
+	}
+	if baseURL == "" {
+		baseURL = defaultOpenAIBaseURL
+	}
+	return &OpenAIProvider{
+		name:    ProviderOpenAI,
+		apiKey:  apiKey,
+		model:   model,
+		baseURL: strings.TrimRight(baseURL, "/"),
+		client:  &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+// NewLlamaCppProvider creates a provider asking a llama.cpp server
+// (llama-server) through its OpenAI-compatible API. modelPath is the GGUF
+// file the server was started with; it names the model. An empty baseURL
+// uses the server's default address.
+func NewLlamaCppProvider(baseURL, modelPath string) *OpenAIProvider {
+	if baseURL == "" {
+		baseURL = defaultLlamaCppBaseURL
+	}
+	model := strings.TrimSuffix(filepath.Base(modelPath), filepath.Ext(modelPath))
+	p := NewOpenAIProvider("", model, baseURL)
+	p.name = ProviderLlamaCpp
+	// Local models on a CPU can take minutes to answer
+	p.client.Timeout = 0
+	return p
+}
+
+// Name returns the provider identifier
+func (p *OpenAIProvider) Name() string {
+	return p.name
+}
+
+// Model returns the model the provider asks
+func (p *OpenAIProvider) Model() string {
+	return p.model
+}
+
+// chatMessage is a single message in a chat completion request
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// openAIChatRequest is the request body for /chat/completions
+type openAIChatRequest struct {
+	Model       string        `json:"model"`
+	Messages    []chatMessage `json:"messages"`
+	Temperature float64       `json:"temperature"`
+}
+
+// openAIChatResponse is the subset of the /chat/completions response we use
+type openAIChatResponse struct {
+	Choices []struct {
+		Message chatMessage `json:"message"`
+	} `json:"choices"`
+	Usage struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+	} `json:"usage"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// GenerateSQL sends the schema context and question to the model and returns the SQL
+func (p *OpenAIProvider) GenerateSQL(ctx context.Context, req GenerationRequest) (string, error) {
+	content, err := p.complete(ctx, buildSystemPrompt(req), buildUserPrompt(req))
+	if err != nil {
+		return "", err
+	}
+	sql := extractSQL(content)
+	if sql == "" {
+		return "", fmt.Errorf("%s returned an empty response", p.name)
+	}
+	return sql, nil
+}
+
+// ExplainSQL asks the model to describe the SQL in plain English
+func (p *OpenAIProvider) ExplainSQL(ctx context.Context, req ExplanationRequest) (string, error) {
+	content, err := p.complete(ctx, buildExplainSystemPrompt(req), buildExplainUserPrompt(req))
+	if err != nil {
+		return "", err
+	}
+	explanation := strings.TrimSpace(content)
+	if explanation == "" {
+		return "", fmt.Errorf("%s returned an empty response", p.name)
+	}
+	return explanation, nil
+}
+
+// complete sends a system and user message and returns the reply
+func (p *OpenAIProvider) complete(ctx context.Context, system, user string) (string, error) {
+	body, err := json.Marshal(openAIChatRequest{
+		Model: p.model,
+		Messages: []chatMessage{
+			{Role: "system", Content: system},
+			{Role: "user", Content: user},
+		},
+		Temperature: 0,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if p.apiKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return "", fmt.Errorf("%s request failed: %w", p.name, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	var chatResp openAIChatResponse
+	if err := json.Unmarshal(data, &chatResp); err != nil {
+		return "", fmt.Errorf("invalid %s response (status %d): %w", p.name, resp.StatusCode, err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		if chatResp.Error != nil {
+			return "", fmt.Errorf("%s error: %s", p.name, chatResp.Error.Message)
+		}
+		return "", fmt.Errorf("%s returned status %d", p.name, resp.StatusCode)
+	}
+	reportUsage(ctx, p.name, p.model, chatResp.Usage.PromptTokens, chatResp.Usage.CompletionTokens)
+
+	if len(chatResp.Choices) == 0 {
+		return "", fmt.Errorf("%s returned no choices", p.name)
+	}
+
+	return chatResp.Choices[0].Message.Content, nil
+}
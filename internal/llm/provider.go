@@ -0,0 +1,267 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/kartoza/kartoza-pg-ai/internal/config"
+	"github.com/kartoza/kartoza-pg-ai/internal/tracing"
+)
+
+// Provider names used in Settings.LLMProvider
+const (
+	ProviderRules    = "rules"
+	ProviderOpenAI   = "openai"
+	ProviderOllama   = "ollama"
+	ProviderClaude   = "claude"
+	ProviderLlamaCpp = "llamacpp" // A llama.cpp server running a downloaded GGUF model
+)
+
+// Generators a question can be answered with besides the provider names
+const (
+	GeneratorAuto = "auto" // The configured provider, then the neural network, then the rules
+	GeneratorNN   = "nn"   // The neural network trained on the query history
+)
+
+// modelProvider is implemented by providers that ask a named model
+type modelProvider interface {
+	Model() string
+}
+
+// AddProvider makes a provider available to GenerateSQLWith under its name
+func (e *QueryEngine) AddProvider(p LLMProvider) {
+	providers := make(map[string]LLMProvider, len(e.providers)+1)
+	for name, existing := range e.providers {
+		providers[name] = existing
+	}
+	providers[p.Name()] = p
+	e.providers = providers
+}
+
+// Generators returns what questions can be answered with: GeneratorAuto,
+// the rules, the neural network when it is available and the providers
+// added with AddProvider, in the order of ProviderNames
+func (e *QueryEngine) Generators() []string {
+	generators := []string{GeneratorAuto, ProviderRules}
+	if e.nnTrainer != nil {
+		generators = append(generators, GeneratorNN)
+	}
+	for _, name := range ProviderNames {
+		if _, ok := e.providers[name]; ok {
+			generators = append(generators, name)
+		}
+	}
+	return generators
+}
+
+// GeneratorLabel describes a generator for display and history, naming the
+// model of a provider, e.g. "ollama/llama3". The automatic choice is
+// described by what it tries first.
+func (e *QueryEngine) GeneratorLabel(generator string) string {
+	switch generator {
+	case "", GeneratorAuto:
+		if e.provider != nil {
+			return GeneratorAuto + " (" + providerLabel(e.provider) + ")"
+		}
+		return GeneratorAuto
+	case ProviderRules, GeneratorNN:
+		return generator
+	}
+	if p, ok := e.providers[generator]; ok {
+		return providerLabel(p)
+	}
+	return generator
+}
+
+// providerLabel names a provider with its model, when it has one
+func providerLabel(p LLMProvider) string {
+	if m, ok := p.(modelProvider); ok && m.Model() != "" {
+		return p.Name() + "/" + m.Model()
+	}
+	return p.Name()
+}
+
+// ProviderNames lists the LLM providers NewProviderFromSettings creates
+var ProviderNames = []string{ProviderOllama, ProviderLlamaCpp, ProviderOpenAI, ProviderClaude}
+
+// GenerationRequest holds everything a provider needs to turn a question into SQL
+type GenerationRequest struct {
+	Question            string
+	SchemaContext       string             // Output of GetSchemaContext
+	ConversationContext string             // Previous turns for follow-up questions
+	Tools               *SchemaTools       // Schema lookup tools for tool-calling providers
+	Examples            []Example          // Earlier similar questions and their SQL
+	Failed              []config.FailedSQL // Earlier SQL for this question that failed, oldest first
+}
+
+// ExplanationRequest holds the SQL a provider describes in plain English
+type ExplanationRequest struct {
+	SQL           string
+	Question      string // Question the SQL answered, if any
+	SchemaContext string // Output of GetSchemaContext
+}
+
+// LLMProvider generates SQL from natural language using a language model
+type LLMProvider interface {
+	// Name returns the provider identifier (e.g. "openai")
+	Name() string
+	// GenerateSQL returns SQL answering the request: one statement, or a
+	// script whose last statement returns the answer
+	GenerateSQL(ctx context.Context, req GenerationRequest) (string, error)
+	// ExplainSQL returns a plain-English description of what the SQL does
+	ExplainSQL(ctx context.Context, req ExplanationRequest) (string, error)
+}
+
+// NewProviderFromSettings creates the provider configured in settings.
+// Returns nil (and no error) when the rule-based engine is selected.
+func NewProviderFromSettings(settings config.Settings) (LLMProvider, error) {
+	switch strings.ToLower(settings.LLMProvider) {
+	case "", ProviderRules:
+		return nil, nil
+	case ProviderOpenAI:
+		apiKey := settings.OpenAIAPIKey
+		if apiKey == "" {
+			apiKey = os.Getenv("OPENAI_API_KEY")
+		}
+		if apiKey == "" {
+			return nil, fmt.Errorf("openai provider selected but no API key configured (set OPENAI_API_KEY)")
+		}
+		return NewOpenAIProvider(apiKey, settings.OpenAIModel, settings.OpenAIBaseURL), nil
+	case ProviderOllama:
+		return NewOllamaProvider(settings.OllamaBaseURL, settings.OllamaModel), nil
+	case ProviderLlamaCpp:
+		if settings.LLMModelPath == "" {
+			return nil, fmt.Errorf("llamacpp provider selected but no model configured (download one in Settings or set llm_model_path)")
+		}
+		return NewLlamaCppProvider(settings.LlamaCppBaseURL, settings.LLMModelPath), nil
+	case ProviderClaude:
+		apiKey := settings.AnthropicAPIKey
+		if apiKey == "" {
+			apiKey = os.Getenv("ANTHROPIC_API_KEY")
+		}
+		if apiKey == "" {
+			return nil, fmt.Errorf("claude provider selected but no API key configured (set ANTHROPIC_API_KEY)")
+		}
+		return NewClaudeProvider(apiKey, settings.AnthropicModel, ""), nil
+	default:
+		return nil, fmt.Errorf("unknown LLM provider: %s", settings.LLMProvider)
+	}
+}
+
+// providerTimeout bounds a single provider request
+const providerTimeout = 90 * time.Second
+
+// generateWithProvider asks a provider for SQL using the schema context,
+// rejecting a reply that is not SQL and limiting the rows of one that is
+func (e *QueryEngine) generateWithProvider(ctx context.Context, provider LLMProvider, question, conversation string) (string, error) {
+	ctx, span := tracing.Start(ctx, "provider.generate_sql", tracing.GenAISystem.String(provider.Name()))
+	ctx, cancel := context.WithTimeout(e.metered(ctx), providerTimeout)
+	defer cancel()
+
+	sql, err := provider.GenerateSQL(ctx, GenerationRequest{
+		Question:            question,
+		SchemaContext:       e.schemaContextFor(ctx, question+"\n"+conversation),
+		ConversationContext: conversation,
+		Tools:               NewSchemaTools(e.schema, e.db, e.timeout),
+		Examples:            e.examples.Retrieve(ctx, e.embedder, question, fewShotExamples),
+	})
+	if err == nil && !isValidSQLStructure(sql) {
+		err = fmt.Errorf("%s returned invalid SQL: %s", provider.Name(), sql)
+	}
+	if err == nil {
+		sql = e.limitRows(sql)
+	}
+	tracing.End(span, err)
+	return sql, err
+}
+
+// scriptRule tells models when several statements may be used
+const scriptRule = "- If the answer needs setup such as temporary tables, write several statements separated by semicolons; the last one must return the answer.\n"
+
+// buildSystemPrompt builds the system prompt containing the schema
+// description and any examples of earlier questions
+func buildSystemPrompt(req GenerationRequest) string {
+	var prompt strings.Builder
+	prompt.WriteString("You are an expert PostgreSQL and PostGIS assistant. ")
+	prompt.WriteString("Translate the user's question into a read-only SQL query for the database described below.\n")
+	prompt.WriteString("Rules:\n")
+	prompt.WriteString("- Respond with the SQL only, no explanations and no markdown.\n")
+	prompt.WriteString(scriptRule)
+	prompt.WriteString("- Always schema-qualify and double-quote table names.\n")
+	prompt.WriteString("- Do not end the query with a semicolon.\n\n")
+	prompt.WriteString(req.SchemaContext)
+	if len(req.Examples) > 0 {
+		prompt.WriteString("\nEXAMPLES (earlier questions on this database and the SQL that answered them):\n")
+		for _, ex := range req.Examples {
+			prompt.WriteString("Question: " + ex.Question + "\n")
+			prompt.WriteString("SQL: " + ex.SQL + "\n\n")
+		}
+	}
+	return prompt.String()
+}
+
+// buildExplainSystemPrompt builds the system prompt for describing SQL
+func buildExplainSystemPrompt(req ExplanationRequest) string {
+	var prompt strings.Builder
+	prompt.WriteString("You are an expert PostgreSQL and PostGIS teacher. ")
+	prompt.WriteString("Explain in plain English what the SQL query does, for someone learning SQL.\n")
+	prompt.WriteString("Rules:\n")
+	prompt.WriteString("- Use two to four short sentences and no markdown.\n")
+	prompt.WriteString("- Say which tables are read, how rows are filtered, grouped and sorted, and what is returned.\n")
+	prompt.WriteString("- Do not repeat the SQL or suggest changes to it.\n\n")
+	prompt.WriteString(req.SchemaContext)
+	return prompt.String()
+}
+
+// buildExplainUserPrompt builds the user message holding the SQL to describe
+func buildExplainUserPrompt(req ExplanationRequest) string {
+	if req.Question == "" {
+		return "SQL: " + req.SQL
+	}
+	return "Question: " + req.Question + "\nSQL: " + req.SQL
+}
+
+// buildUserPrompt builds the user message from the question, prior
+// conversation and any failed attempts at answering it
+func buildUserPrompt(req GenerationRequest) string {
+	prompt := req.Question
+	if req.ConversationContext != "" {
+		prompt = req.ConversationContext + "\nQuestion: " + req.Question
+	}
+	if len(req.Failed) == 0 {
+		return prompt
+	}
+
+	var b strings.Builder
+	b.WriteString(prompt)
+	b.WriteString("\n\nEarlier SQL for this question failed:\n")
+	for _, f := range req.Failed {
+		b.WriteString("SQL: " + f.SQL + "\n")
+		b.WriteString("Error: " + f.Error + "\n\n")
+	}
+	b.WriteString("Write corrected SQL that avoids these errors.")
+	return b.String()
+}
+
+// extractSQL strips markdown code fences and trailing semicolons from a model response
+func extractSQL(response string) string {
+	sql := strings.TrimSpace(response)
+
+	if start := strings.Index(sql, "```"); start >= 0 {
+		sql = sql[start+3:]
+		// Drop language tag on the opening fence (```sql)
+		if nl := strings.Index(sql, "\n"); nl >= 0 {
+			sql = sql[nl+1:]
+		}
+		if end := strings.Index(sql, "```"); end >= 0 {
+			sql = sql[:end]
+		}
+	}
+
+	sql = strings.TrimSpace(sql)
+	sql = strings.TrimRight(sql, "; \n\t")
+	return sql
+}
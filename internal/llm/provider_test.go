@@ -0,0 +1,335 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kartoza/kartoza-pg-ai/internal/config"
+)
+
+func TestExtractSQL(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"SELECT 1 FROM t;", "SELECT 1 FROM t"},
+		{"```sql\nSELECT * FROM users\n```", "SELECT * FROM users"},
+		{"Here you go:\n```\nSELECT id FROM orders;\n```", "SELECT id FROM orders"},
+		{"  SELECT 2  ", "SELECT 2"},
+	}
+
+	for _, tt := range tests {
+		if got := extractSQL(tt.input); got != tt.expected {
+			t.Errorf("extractSQL(%q): expected %q, got %q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestOpenAIProviderGenerateSQL(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/chat/completions" {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+		if r.Header.Get("Authorization") != "Bearer test-key" {
+			t.Errorf("missing bearer token")
+		}
+
+		var req openAIChatRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatalf("failed to decode request: %v", err)
+		}
+		if len(req.Messages) != 2 || !strings.Contains(req.Messages[0].Content, "DATABASE SCHEMA") {
+			t.Errorf("expected schema context in system prompt")
+		}
+
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"` +
+			"```sql\\nSELECT COUNT(*) FROM \\\"public\\\".\\\"users\\\";\\n```" + `"}}]}`))
+	}))
+	defer server.Close()
+
+	provider := NewOpenAIProvider("test-key", "", server.URL)
+	sql, err := provider.GenerateSQL(context.Background(), GenerationRequest{
+		Question:      "how many users",
+		SchemaContext: "DATABASE SCHEMA:\n",
+	})
+	if err != nil {
+		t.Fatalf("GenerateSQL failed: %v", err)
+	}
+
+	expected := `SELECT COUNT(*) FROM "public"."users"`
+	if sql != expected {
+		t.Errorf("expected %q, got %q", expected, sql)
+	}
+}
+
+func TestOpenAIProviderError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error":{"message":"invalid api key"}}`))
+	}))
+	defer server.Close()
+
+	provider := NewOpenAIProvider("bad-key", "", server.URL)
+	_, err := provider.GenerateSQL(context.Background(), GenerationRequest{Question: "test"})
+	if err == nil || !strings.Contains(err.Error(), "invalid api key") {
+		t.Errorf("expected api error, got %v", err)
+	}
+}
+
+func TestNewProviderFromSettings(t *testing.T) {
+	settings := config.DefaultConfig().Settings
+
+	provider, err := NewProviderFromSettings(settings)
+	if err != nil || provider != nil {
+		t.Errorf("expected no provider for rule engine, got %v, %v", provider, err)
+	}
+
+	settings.LLMProvider = ProviderOpenAI
+	settings.OpenAIAPIKey = "key"
+	provider, err = NewProviderFromSettings(settings)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if provider.Name() != ProviderOpenAI {
+		t.Errorf("expected openai provider, got %s", provider.Name())
+	}
+
+	settings.LLMProvider = ProviderLlamaCpp
+	settings.LLMModelPath = "/models/sqlcoder-7b-q5_k_m.gguf"
+	provider, err = NewProviderFromSettings(settings)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if label := providerLabel(provider); label != "llamacpp/sqlcoder-7b-q5_k_m" {
+		t.Errorf("llama.cpp provider = %s", label)
+	}
+
+	settings.LLMProvider = "bogus"
+	if _, err := NewProviderFromSettings(settings); err == nil {
+		t.Error("expected error for unknown provider")
+	}
+}
+
+// staticProvider returns a fixed response for engine delegation tests
+type staticProvider struct {
+	sql         string
+	explanation string
+	err         error
+}
+
+func (p *staticProvider) Name() string { return "static" }
+
+func (p *staticProvider) GenerateSQL(ctx context.Context, req GenerationRequest) (string, error) {
+	return p.sql, p.err
+}
+
+func (p *staticProvider) ExplainSQL(ctx context.Context, req ExplanationRequest) (string, error) {
+	return p.explanation, p.err
+}
+
+func TestGenerateSQLDelegatesToProvider(t *testing.T) {
+	schema := &config.SchemaCache{
+		Tables: []config.TableInfo{{Schema: "public", Name: "users"}},
+	}
+
+	engine := NewQueryEngine(schema)
+	engine.SetProvider(&staticProvider{sql: `SELECT name FROM "public"."users" WHERE active`})
+
+	sql, err := engine.GenerateSQL("which users are active", "")
+	if err != nil {
+		t.Fatalf("GenerateSQL failed: %v", err)
+	}
+	if !strings.Contains(sql, "WHERE active") {
+		t.Errorf("expected provider SQL, got %s", sql)
+	}
+
+	// Provider failure falls back to the rule engine
+	engine.SetProvider(&staticProvider{err: context.DeadlineExceeded})
+	sql, err = engine.GenerateSQL("how many users", "")
+	if err != nil {
+		t.Fatalf("expected rule engine fallback, got error: %v", err)
+	}
+	if sql != `SELECT COUNT(*) as count FROM "public"."users"` {
+		t.Errorf("unexpected fallback SQL: %s", sql)
+	}
+}
+
+func TestGenerateSQLWithChosenGenerator(t *testing.T) {
+	schema := &config.SchemaCache{
+		Tables: []config.TableInfo{{Schema: "public", Name: "users"}},
+	}
+	engine := NewQueryEngine(schema)
+	engine.SetProvider(&staticProvider{sql: `SELECT name FROM "public"."users" WHERE active`})
+	engine.AddProvider(NewOllamaProvider("http://127.0.0.1:1", "sqlcoder"))
+
+	generators := strings.Join(engine.Generators(), ",")
+	if !strings.HasPrefix(generators, "auto,rules") || !strings.HasSuffix(generators, ",ollama") {
+		t.Errorf("Generators() = %s", generators)
+	}
+	if got := engine.GeneratorLabel(ProviderOllama); got != "ollama/sqlcoder" {
+		t.Errorf("GeneratorLabel(ollama) = %q", got)
+	}
+	if got := engine.GeneratorLabel(GeneratorAuto); got != "auto (static)" {
+		t.Errorf("GeneratorLabel(auto) = %q", got)
+	}
+
+	// The rules answer alone, without asking the configured provider
+	sql, err := engine.GenerateSQLWith(context.Background(), ProviderRules, "how many users", "")
+	if err != nil || sql != `SELECT COUNT(*) as count FROM "public"."users"` {
+		t.Errorf("rules = %q, %v", sql, err)
+	}
+	if sql, _ := engine.GenerateSQLWith(context.Background(), ProviderRules, "which users are active", ""); strings.Contains(sql, "WHERE active") {
+		t.Error("rules asked the configured provider")
+	}
+
+	// A chosen provider that fails does not fall back
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err := engine.GenerateSQLWith(ctx, ProviderOllama, "how many users", ""); err == nil {
+		t.Error("unreachable provider answered")
+	}
+	if _, err := engine.GenerateSQLWith(ctx, ProviderClaude, "how many users", ""); err == nil {
+		t.Error("provider never added answered")
+	}
+}
+
+func TestOllamaProviderStreams(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/chat" {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+
+		var req ollamaChatRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatalf("failed to decode request: %v", err)
+		}
+		if !req.Stream || req.Model != "sqlcoder" {
+			t.Errorf("expected streaming request for sqlcoder, got %+v", req)
+		}
+
+		for _, part := range []string{"SELECT ", "* FROM ", `\"public\".\"roads\"`} {
+			w.Write([]byte(`{"message":{"role":"assistant","content":"` + part + `"},"done":false}` + "\n"))
+		}
+		w.Write([]byte(`{"message":{"role":"assistant","content":""},"done":true}` + "\n"))
+	}))
+	defer server.Close()
+
+	provider := NewOllamaProvider(server.URL, "sqlcoder")
+	sql, err := provider.GenerateSQL(context.Background(), GenerationRequest{Question: "show roads"})
+	if err != nil {
+		t.Fatalf("GenerateSQL failed: %v", err)
+	}
+	if sql != `SELECT * FROM "public"."roads"` {
+		t.Errorf("streamed chunks should make up the SQL, got: %s", sql)
+	}
+}
+
+func TestOllamaUnreachableFallsBack(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	url := server.URL
+	server.Close() // Nothing listening any more
+
+	schema := &config.SchemaCache{
+		Tables: []config.TableInfo{{Schema: "public", Name: "roads"}},
+	}
+	engine := NewQueryEngine(schema)
+	engine.SetProvider(NewOllamaProvider(url, ""))
+
+	sql, err := engine.GenerateSQL("how many roads", "")
+	if err != nil {
+		t.Fatalf("expected rule engine fallback, got error: %v", err)
+	}
+	if !strings.Contains(sql, "COUNT(*)") {
+		t.Errorf("unexpected fallback SQL: %s", sql)
+	}
+}
+
+func TestSchemaToolsDescribeTable(t *testing.T) {
+	schema := &config.SchemaCache{
+		Tables: []config.TableInfo{
+			{Schema: "public", Name: "roads", Columns: []config.ColumnInfo{
+				{Name: "id", DataType: "integer", IsPrimaryKey: true},
+				{Name: "geom", DataType: "geometry", IsGeometry: true, GeomType: "LINESTRING", SRID: 4326, IsNullable: true},
+			}},
+		},
+	}
+	tools := NewSchemaTools(schema, nil, 0)
+
+	for _, def := range tools.Definitions() {
+		if def.Name == ToolSampleRows {
+			t.Error("sample_rows should not be offered without a connection")
+		}
+	}
+
+	out, err := tools.Execute(context.Background(), ToolDescribeTable, json.RawMessage(`{"table":"public.roads"}`))
+	if err != nil {
+		t.Fatalf("describe_table failed: %v", err)
+	}
+	if !strings.Contains(out, "id integer PRIMARY KEY") || !strings.Contains(out, "LINESTRING, SRID 4326") {
+		t.Errorf("unexpected description:\n%s", out)
+	}
+
+	if _, err := tools.Execute(context.Background(), ToolDescribeTable, json.RawMessage(`{"table":"missing"}`)); err == nil {
+		t.Error("expected error for unknown table")
+	}
+
+	out, _ = tools.Execute(context.Background(), ToolListTables, json.RawMessage(`{"filter":"road"}`))
+	if !strings.Contains(out, "public.roads (2 columns) [spatial]") {
+		t.Errorf("unexpected table list:\n%s", out)
+	}
+}
+
+func TestClaudeProviderToolUse(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("x-api-key") != "test-key" {
+			t.Errorf("missing api key header")
+		}
+
+		var req claudeRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatalf("failed to decode request: %v", err)
+		}
+		calls++
+
+		if calls == 1 {
+			if len(req.Tools) == 0 {
+				t.Errorf("expected tools to be advertised")
+			}
+			w.Write([]byte(`{"stop_reason":"tool_use","content":[{"type":"tool_use","id":"t1","name":"describe_table","input":{"table":"roads"}}]}`))
+			return
+		}
+
+		// Second turn must carry the tool result back
+		last := req.Messages[len(req.Messages)-1]
+		if len(last.Content) != 1 || last.Content[0].ToolUseID != "t1" || !strings.Contains(last.Content[0].Content, "public.roads") {
+			t.Errorf("expected tool result in last message, got %+v", last)
+		}
+		w.Write([]byte(`{"stop_reason":"end_turn","content":[{"type":"text","text":"SELECT \"id\" FROM \"public\".\"roads\""}]}`))
+	}))
+	defer server.Close()
+
+	schema := &config.SchemaCache{
+		Tables: []config.TableInfo{{Schema: "public", Name: "roads", Columns: []config.ColumnInfo{{Name: "id", DataType: "integer"}}}},
+	}
+
+	provider := NewClaudeProvider("test-key", "", server.URL)
+	sql, err := provider.GenerateSQL(context.Background(), GenerationRequest{
+		Question: "list road ids",
+		Tools:    NewSchemaTools(schema, nil, 0),
+	})
+	if err != nil {
+		t.Fatalf("GenerateSQL failed: %v", err)
+	}
+	if sql != `SELECT "id" FROM "public"."roads"` {
+		t.Errorf("unexpected SQL: %s", sql)
+	}
+	if calls != 2 {
+		t.Errorf("expected 2 API calls, got %d", calls)
+	}
+}
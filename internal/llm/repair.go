@@ -0,0 +1,194 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/kartoza/kartoza-pg-ai/internal/config"
+	"github.com/kartoza/kartoza-pg-ai/internal/postgres"
+	"github.com/kartoza/kartoza-pg-ai/internal/tracing"
+)
+
+// MaxRepairAttempts is how many corrected versions of failing generated
+// SQL are tried before the error is shown
+const MaxRepairAttempts = 2
+
+// Messages of the errors the rule-based repair understands
+var (
+	undefinedColumnPattern = regexp.MustCompile(`column "?([^"\s]+)"? does not exist`)
+	undefinedTablePattern  = regexp.MustCompile(`relation "([^"]+)" does not exist`)
+)
+
+// RepairSQL returns a corrected version of SQL that failed. failed holds
+// every attempt at the question so far, the latest last. The configured
+// provider is shown the errors first; without one, or when it cannot fix
+// the SQL, misspelt table and column names are replaced by the closest
+// names in the schema.
+func (e *QueryEngine) RepairSQL(ctx context.Context, question, conversation string, failed []config.FailedSQL) (result string, err error) {
+	ctx, span := tracing.Start(ctx, "repair_sql", tracing.Attempt.Int(len(failed)))
+	defer func() {
+		if err == nil {
+			span.SetAttributes(tracing.DBQueryText.String(result))
+		}
+		tracing.End(span, err)
+	}()
+
+	if len(failed) == 0 {
+		return "", fmt.Errorf("no failed SQL to repair")
+	}
+	last := failed[len(failed)-1]
+	tried := func(sql string) bool {
+		for _, f := range failed {
+			if strings.Join(strings.Fields(f.SQL), " ") == strings.Join(strings.Fields(sql), " ") {
+				return true
+			}
+		}
+		return false
+	}
+
+	if e.provider != nil {
+		providerCtx, cancel := context.WithTimeout(e.metered(ctx), providerTimeout)
+		sql, err := e.provider.GenerateSQL(providerCtx, GenerationRequest{
+			Question:            question,
+			SchemaContext:       e.schemaContextFor(ctx, question+"\n"+last.SQL),
+			ConversationContext: conversation,
+			Tools:               NewSchemaTools(e.schema, e.db, e.timeout),
+			Failed:              failed,
+		})
+		cancel()
+		if err == nil && isValidSQLStructure(sql) && !tried(sql) {
+			return sql, nil
+		}
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+	}
+
+	if sql := e.repairIdentifiers(last.SQL, last.Error); sql != "" && !tried(sql) {
+		return sql, nil
+	}
+	return "", fmt.Errorf("no repair found for: %s", last.Error)
+}
+
+// repairIdentifiers replaces the table or column a "does not exist" error
+// names with the closest name in the schema, returning "" when the error
+// is of another kind or nothing is close enough
+func (e *QueryEngine) repairIdentifiers(sql, message string) string {
+	if e.schema == nil {
+		return ""
+	}
+
+	if m := undefinedTablePattern.FindStringSubmatch(message); m != nil {
+		qualifier, name := "", m[1]
+		if dot := strings.LastIndex(name, "."); dot >= 0 {
+			qualifier, name = name[:dot], name[dot+1:]
+		}
+		var best *config.TableInfo
+		bestDistance := 0
+		for i := range e.schema.Tables {
+			t := &e.schema.Tables[i]
+			d, ok := nameDistance(name, t.Name)
+			if t.Name == name && qualifier != "" && qualifier != t.Schema {
+				// The table exists in another schema
+				d, ok = 0, true
+			}
+			if ok && (best == nil || d < bestDistance) {
+				best, bestDistance = t, d
+			}
+		}
+		if best != nil {
+			return replaceIdentifier(sql, name, best.Name, best.Schema)
+		}
+		return ""
+	}
+
+	if m := undefinedColumnPattern.FindStringSubmatch(message); m != nil {
+		name := m[1][strings.LastIndex(m[1], ".")+1:]
+		best, bestDistance := "", 0
+		for _, t := range e.referencedTables(sql) {
+			for _, c := range t.Columns {
+				if d, ok := nameDistance(name, c.Name); ok && (best == "" || d < bestDistance) {
+					best, bestDistance = c.Name, d
+				}
+			}
+		}
+		if best != "" {
+			return replaceIdentifier(sql, name, best, "")
+		}
+	}
+	return ""
+}
+
+// referencedTables returns the schema's tables named in sql, or all of
+// them when none is
+func (e *QueryEngine) referencedTables(sql string) []config.TableInfo {
+	names := make(map[string]bool)
+	for _, tok := range postgres.LexSQL(sql) {
+		if name, ok := identifierName(tok); ok {
+			names[name] = true
+		}
+	}
+	var tables []config.TableInfo
+	for _, t := range e.schema.Tables {
+		if names[t.Name] {
+			tables = append(tables, t)
+		}
+	}
+	if len(tables) == 0 {
+		return e.schema.Tables
+	}
+	return tables
+}
+
+// nameDistance returns the edit distance between a missing name and a
+// candidate, and whether the candidate is close enough to be what was meant
+func nameDistance(missing, candidate string) (int, bool) {
+	if missing == candidate {
+		return 0, false
+	}
+	d := levenshteinDistance(strings.ToLower(missing), strings.ToLower(candidate))
+	return d, d <= max(1, (len([]rune(missing))+2)/3)
+}
+
+// identifierName returns the name a bare or quoted identifier token refers
+// to; bare names fold to lower case as in PostgreSQL
+func identifierName(tok postgres.Token) (string, bool) {
+	switch tok.Kind {
+	case postgres.TokenIdentifier:
+		return strings.ToLower(tok.Text), true
+	case postgres.TokenQuotedIdentifier:
+		return strings.ReplaceAll(strings.Trim(tok.Text, `"`), `""`, `"`), true
+	}
+	return "", false
+}
+
+// replaceIdentifier replaces every identifier naming from with to, and the
+// schema qualifying it with schema when that is set. It returns "" when sql
+// does not name from.
+func replaceIdentifier(sql, from, to, schema string) string {
+	tokens := postgres.LexSQL(sql)
+	replaced := false
+	for i, tok := range tokens {
+		if name, ok := identifierName(tok); !ok || name != from {
+			continue
+		}
+		tokens[i].Text = quoteIdent(to)
+		if schema != "" && i >= 2 && tokens[i-1].Text == "." {
+			if _, ok := identifierName(tokens[i-2]); ok {
+				tokens[i-2].Text = quoteIdent(schema)
+			}
+		}
+		replaced = true
+	}
+	if !replaced {
+		return ""
+	}
+
+	var b strings.Builder
+	for _, tok := range tokens {
+		b.WriteString(tok.Text)
+	}
+	return b.String()
+}
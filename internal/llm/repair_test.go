@@ -0,0 +1,100 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/kartoza/kartoza-pg-ai/internal/config"
+)
+
+func repairSchema() *config.SchemaCache {
+	return &config.SchemaCache{
+		Tables: []config.TableInfo{
+			{Schema: "transport", Name: "roads", Columns: []config.ColumnInfo{{Name: "name"}, {Name: "surface"}}},
+			{Schema: "public", Name: "towns", Columns: []config.ColumnInfo{{Name: "name"}, {Name: "population"}}},
+		},
+	}
+}
+
+func TestRepairSQLFixesMisspeltNames(t *testing.T) {
+	engine := NewQueryEngine(repairSchema())
+
+	tests := []struct {
+		sql      string
+		err      string
+		expected string
+	}{
+		{
+			`SELECT "nmae", surfce FROM "transport"."roads"`,
+			`column "nmae" does not exist`,
+			`SELECT "name", surfce FROM "transport"."roads"`,
+		},
+		{
+			`SELECT r.surfce FROM "transport"."roads" r`,
+			`column r.surfce does not exist`,
+			`SELECT r."surface" FROM "transport"."roads" r`,
+		},
+		{
+			`SELECT COUNT(*) FROM "public"."roads"`,
+			`relation "public.roads" does not exist`,
+			`SELECT COUNT(*) FROM "transport"."roads"`,
+		},
+		{
+			`SELECT * FROM "public"."towsn"`,
+			`relation "public.towsn" does not exist`,
+			`SELECT * FROM "public"."towns"`,
+		},
+	}
+	for _, tt := range tests {
+		sql, err := engine.RepairSQL(context.Background(), "q", "", []config.FailedSQL{{SQL: tt.sql, Error: tt.err}})
+		if err != nil || sql != tt.expected {
+			t.Errorf("RepairSQL(%q): got %q (%v), want %q", tt.sql, sql, err, tt.expected)
+		}
+	}
+
+	// Errors about something other than names cannot be repaired by rule
+	failed := []config.FailedSQL{{SQL: `SELECT 1/0`, Error: "division by zero"}}
+	if _, err := engine.RepairSQL(context.Background(), "q", "", failed); err == nil {
+		t.Error("expected no repair for division by zero")
+	}
+}
+
+// recordingProvider returns fixed SQL and keeps the last request
+type recordingProvider struct {
+	staticProvider
+	req GenerationRequest
+}
+
+func (p *recordingProvider) GenerateSQL(ctx context.Context, req GenerationRequest) (string, error) {
+	p.req = req
+	return p.sql, p.err
+}
+
+func TestRepairSQLAsksProvider(t *testing.T) {
+	engine := NewQueryEngine(repairSchema())
+	provider := &recordingProvider{staticProvider: staticProvider{sql: `SELECT "name" FROM "public"."towns"`}}
+	engine.SetProvider(provider)
+
+	failed := []config.FailedSQL{{SQL: `SELECT nme FROM "public"."towns"`, Error: `column "nme" does not exist`}}
+	sql, err := engine.RepairSQL(context.Background(), "town names", "", failed)
+	if err != nil || sql != `SELECT "name" FROM "public"."towns"` {
+		t.Fatalf("expected the provider's SQL, got %q (%v)", sql, err)
+	}
+	if prompt := buildUserPrompt(provider.req); !strings.Contains(prompt, `column "nme" does not exist`) ||
+		!strings.Contains(prompt, failed[0].SQL) {
+		t.Errorf("prompt does not show the failed attempt:\n%s", prompt)
+	}
+
+	// SQL that already failed is not offered again; the rules take over
+	provider.sql = failed[0].SQL
+	if sql, err = engine.RepairSQL(context.Background(), "town names", "", failed); err != nil || sql != `SELECT "name" FROM "public"."towns"` {
+		t.Errorf("expected the rule-based repair, got %q (%v)", sql, err)
+	}
+
+	provider.err = errors.New("offline")
+	if sql, err = engine.RepairSQL(context.Background(), "town names", "", failed); err != nil || sql != `SELECT "name" FROM "public"."towns"` {
+		t.Errorf("expected the rule-based repair when offline, got %q (%v)", sql, err)
+	}
+}
@@ -0,0 +1,211 @@
+package llm
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/kartoza/kartoza-pg-ai/internal/config"
+)
+
+// routeIntent matches questions asking for a route
+var routeIntent = regexp.MustCompile(`\b(?:route|routes|routing|shortest path|path|directions|navigate|drive|walk|cycle|travel)\b`)
+
+// Patterns finding a route's endpoints and, after them, the network it
+// follows, e.g. "from a to b on roads" or "between a and b along the ways"
+var routeEndpoints = []*regexp.Regexp{
+	regexp.MustCompile(`\bfrom (.+?) to (.+?)(?:\s+(?:on|along|via|using|over|through|across|by)\s+(.+))?$`),
+	regexp.MustCompile(`\bbetween (.+?) and (.+?)(?:\s+(?:on|along|via|using|over|through|across|by)\s+(.+))?$`),
+}
+
+// vertexPattern matches an endpoint given as a vertex id, e.g. "node 12"
+var vertexPattern = regexp.MustCompile(`^(?:vertex|node)?\s*(\d+)$`)
+
+// routeStepsPattern asks for the edges of a route rather than its line
+var routeStepsPattern = regexp.MustCompile(`\b(?:steps|edges|segments|turn by turn|each road|each edge)\b`)
+
+// Columns of a routing network's edges, by preference
+var (
+	edgeIDColumns   = []string{"id", "gid", "edge_id", "fid", "ogc_fid"}
+	edgeCostColumns = []string{"cost", "cost_s", "length_m", "length", "len", "distance"}
+)
+
+// routingNetwork is a table of edges pgRouting can route over
+type routingNetwork struct {
+	edges       config.TableInfo
+	id          string            // Edge id column
+	cost        string            // Cost column; "" to use the length of geom
+	reverseCost string            // Cost against the direction; "" for an undirected network
+	geom        config.ColumnInfo // Edge geometry
+	vertices    *config.TableInfo // Vertices made by pgr_createTopology, if any
+	vertexGeom  config.ColumnInfo
+}
+
+// matchRouteQuery answers questions asking for the shortest route between
+// two places over a pgRouting network, e.g. "route from the hospital to
+// cape town on roads" or "shortest path between node 12 and node 40". The
+// route is returned as a single line, route_geom, for the geometry
+// preview, or edge by edge when its steps are asked for.
+func (e *QueryEngine) matchRouteQuery(query string) string {
+	if !routeIntent.MatchString(query) {
+		return ""
+	}
+	var m []string
+	for _, re := range routeEndpoints {
+		if m = re.FindStringSubmatch(query); m != nil {
+			break
+		}
+	}
+	if m == nil {
+		return ""
+	}
+
+	networks := e.routingNetworks()
+	if len(networks) == 0 {
+		return ""
+	}
+	network := networks[0]
+	padded := padWords(query)
+	for _, n := range networks {
+		if tableMention(padded, n.edges.Name) >= 0 {
+			network = n
+			break
+		}
+	}
+
+	start := e.routeVertex(network, m[1], "from_")
+	end := e.routeVertex(network, m[2], "to_")
+	edges := fmt.Sprintf(`"%s"."%s"`, network.edges.Schema, network.edges.Name)
+	route := fmt.Sprintf("pgr_dijkstra(%s, %s, %s, directed => %t) r JOIN %s e ON e.%s = r.edge",
+		sqlString(network.edgesQuery()), start, end, network.reverseCost != "", edges, quoteIdent(network.id))
+	geom := geometryExpr("e", network.geom, 0)
+
+	if routeStepsPattern.MatchString(query) {
+		name := ""
+		for _, c := range network.edges.Columns {
+			if c.Name == "name" {
+				name = `e."name", `
+			}
+		}
+		return fmt.Sprintf("SELECT r.seq, r.node, r.edge, %sr.cost, r.agg_cost, %s AS geom FROM %s ORDER BY r.seq",
+			name, geom, route)
+	}
+	return fmt.Sprintf(`SELECT COUNT(*) AS edges, SUM(r.cost) AS total_cost, SUM(ST_Length(%s)) AS length_m,
+		ST_LineMerge(ST_Collect(%s ORDER BY r.seq)) AS route_geom
+		FROM %s`, geographyExpr("e", network.geom), geom, route)
+}
+
+// routeVertex returns the SQL of the network vertex an endpoint names: a
+// vertex id, or the vertex nearest to a place resolved like a distance
+// reference, with param prefixing the names of any coordinates asked for
+func (e *QueryEngine) routeVertex(n routingNetwork, endpoint, param string) string {
+	endpoint = referenceLead.ReplaceAllString(endpoint, "")
+	if m := vertexPattern.FindStringSubmatch(endpoint); m != nil {
+		return m[1]
+	}
+	// Coordinates hold the comma a description of a place would end at
+	if coords := coordinatePattern.FindString(endpoint); coords == "" || len(coords) < len(strings.TrimSpace(endpoint)) {
+		endpoint = referenceEnd.ReplaceAllString(endpoint, "")
+	}
+	ref := e.resolveReference(strings.TrimSpace(endpoint), param)
+
+	if n.vertices != nil {
+		return fmt.Sprintf(`(SELECT v."id" FROM "%s"."%s" v ORDER BY %s <-> %s LIMIT 1)`,
+			n.vertices.Schema, n.vertices.Name,
+			geometryExpr("v", n.vertexGeom, 0), ref.geometry(nativeSRID(n.vertexGeom)))
+	}
+	// Without a vertices table, the nearest edge's source stands in
+	return fmt.Sprintf(`(SELECT e."source" FROM "%s"."%s" e ORDER BY %s <-> %s LIMIT 1)`,
+		n.edges.Schema, n.edges.Name, geometryExpr("e", n.geom, 0), ref.geometry(nativeSRID(n.geom)))
+}
+
+// edgesQuery is the SQL pgr_dijkstra reads the network's edges with
+func (n routingNetwork) edgesQuery() string {
+	cost := "ST_Length(" + geographyExpr("e", n.geom) + ")"
+	if n.cost != "" {
+		cost = "e." + quoteIdent(n.cost)
+	}
+	sql := fmt.Sprintf(`SELECT e.%s AS id, e."source", e."target", %s AS cost`, quoteIdent(n.id), cost)
+	if n.reverseCost != "" {
+		sql += ", e." + quoteIdent(n.reverseCost) + " AS reverse_cost"
+	}
+	return sql + fmt.Sprintf(` FROM "%s"."%s" e`, n.edges.Schema, n.edges.Name)
+}
+
+// routingNetworks returns the tables with source and target vertex columns
+// and a geometry, as pgr_createTopology and osm2pgrouting make them
+func (e *QueryEngine) routingNetworks() []routingNetwork {
+	var networks []routingNetwork
+	for _, t := range e.schema.Tables {
+		names := make(map[string]bool, len(t.Columns))
+		for _, c := range t.Columns {
+			names[c.Name] = true
+		}
+		if !names["source"] || !names["target"] {
+			continue
+		}
+		_, geomCol := pickIndexedGeometry([]config.TableInfo{t})
+		if geomCol == "" {
+			continue
+		}
+
+		n := routingNetwork{edges: t, geom: columnNamed(t, geomCol)}
+		n.id = firstColumn(names, edgeIDColumns)
+		if n.id == "" {
+			for _, c := range t.Columns {
+				if c.IsPrimaryKey {
+					n.id = c.Name
+					break
+				}
+			}
+		}
+		if n.id == "" {
+			continue
+		}
+		n.cost = firstColumn(names, edgeCostColumns)
+		if n.cost != "" {
+			n.reverseCost = firstColumn(names, []string{"reverse_" + n.cost, "reverse_cost"})
+		}
+
+		for i, v := range e.schema.Tables {
+			if v.Schema != t.Schema || (v.Name != t.Name+"_vertices_pgr" && v.Name != t.Name+"_vertices") {
+				continue
+			}
+			if _, vertexGeom := pickIndexedGeometry(e.schema.Tables[i : i+1]); vertexGeom != "" && hasColumn(v, "id") {
+				n.vertices = &e.schema.Tables[i]
+				n.vertexGeom = columnNamed(v, vertexGeom)
+			}
+		}
+		networks = append(networks, n)
+	}
+	return networks
+}
+
+// firstColumn returns the first of candidates in names, or ""
+func firstColumn(names map[string]bool, candidates []string) string {
+	for _, c := range candidates {
+		if names[c] {
+			return c
+		}
+	}
+	return ""
+}
+
+// hasColumn reports whether a table has a column
+func hasColumn(t config.TableInfo, name string) bool {
+	for _, c := range t.Columns {
+		if c.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// nativeSRID is the SRID a geometry column's values are in, 4326 for
+// geography; 0 when unknown
+func nativeSRID(col config.ColumnInfo) int {
+	if col.SRID == 0 && strings.HasPrefix(col.DataType, "geography") {
+		return 4326
+	}
+	return col.SRID
+}
@@ -0,0 +1,113 @@
+package llm
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/kartoza/kartoza-pg-ai/internal/config"
+)
+
+// routingSchema has an osm2pgrouting network with its vertices, and
+// hospitals to route from
+func routingSchema() *config.SchemaCache {
+	return &config.SchemaCache{
+		HasPostGIS:   true,
+		HasPgRouting: true,
+		Tables: []config.TableInfo{
+			{Schema: "public", Name: "hospitals", Columns: []config.ColumnInfo{
+				{Name: "id", DataType: "integer", IsPrimaryKey: true},
+				{Name: "geom", DataType: "geometry", IsGeometry: true, GeomType: "POINT", SRID: 4326},
+			}},
+			{Schema: "public", Name: "ways", Columns: []config.ColumnInfo{
+				{Name: "gid", DataType: "bigint", IsPrimaryKey: true},
+				{Name: "name", DataType: "text"},
+				{Name: "source", DataType: "bigint"},
+				{Name: "target", DataType: "bigint"},
+				{Name: "cost", DataType: "double precision"},
+				{Name: "reverse_cost", DataType: "double precision"},
+				{Name: "the_geom", DataType: "geometry", IsGeometry: true, GeomType: "LINESTRING", SRID: 3857},
+			}},
+			{Schema: "public", Name: "ways_vertices_pgr", Columns: []config.ColumnInfo{
+				{Name: "id", DataType: "bigint", IsPrimaryKey: true},
+				{Name: "the_geom", DataType: "geometry", IsGeometry: true, GeomType: "POINT", SRID: 3857},
+			}},
+		},
+	}
+}
+
+func TestRouteQuery(t *testing.T) {
+	engine := NewQueryEngine(routingSchema())
+	engine.SetUseNN(false)
+
+	tests := []struct {
+		query    string
+		expected []string
+	}{
+		{
+			"shortest path from node 12 to node 40 on ways",
+			[]string{
+				`pgr_dijkstra('SELECT e."gid" AS id, e."source", e."target", e."cost" AS cost, e."reverse_cost" AS reverse_cost FROM "public"."ways" e', 12, 40, directed => true)`,
+				`JOIN "public"."ways" e ON e."gid" = r.edge`,
+				`ST_LineMerge(ST_Collect(e."the_geom" ORDER BY r.seq)) AS route_geom`,
+			},
+		},
+		{
+			// Places become their nearest vertex, in the network's SRID
+			"route from the hospital to -33.92, 18.42",
+			[]string{
+				`(SELECT v."id" FROM "public"."ways_vertices_pgr" v ORDER BY v."the_geom" <-> (SELECT ST_Transform(r."geom", 3857) FROM "public"."hospitals" r LIMIT 1) LIMIT 1)`,
+				`ORDER BY v."the_geom" <-> ST_Transform(ST_SetSRID(ST_MakePoint(18.42, -33.92), 4326), 3857) LIMIT 1)`,
+			},
+		},
+		{
+			// Unknown places are asked for
+			"directions between home and work with each edge",
+			[]string{
+				"ST_MakePoint({{from_longitude}}, {{from_latitude}})",
+				"ST_MakePoint({{to_longitude}}, {{to_latitude}})",
+				`SELECT r.seq, r.node, r.edge, e."name", r.cost, r.agg_cost, e."the_geom" AS geom`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		sql, err := engine.GenerateSQL(tt.query, "")
+		if err != nil {
+			t.Fatalf("GenerateSQL(%q): %v", tt.query, err)
+		}
+		for _, want := range tt.expected {
+			if !strings.Contains(sql, want) {
+				t.Errorf("GenerateSQL(%q) = %s\nwant it to contain %s", tt.query, sql, want)
+			}
+		}
+	}
+}
+
+func TestRouteQueryWithoutCostColumn(t *testing.T) {
+	schema := &config.SchemaCache{
+		HasPostGIS:   true,
+		HasPgRouting: true,
+		Tables: []config.TableInfo{
+			{Schema: "net", Name: "roads", Columns: []config.ColumnInfo{
+				{Name: "id", DataType: "integer", IsPrimaryKey: true},
+				{Name: "source", DataType: "integer"},
+				{Name: "target", DataType: "integer"},
+				{Name: "geom", DataType: "geometry", IsGeometry: true, GeomType: "LINESTRING", SRID: 4326},
+			}},
+		},
+	}
+	engine := NewQueryEngine(schema)
+	engine.SetUseNN(false)
+
+	sql, err := engine.GenerateSQL("route from 1 to 9 on roads", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`ST_Length(e."geom"::geography) AS cost FROM "net"."roads" e', 1, 9, directed => false)`,
+	} {
+		if !strings.Contains(sql, want) {
+			t.Errorf("expected %s in %s", want, sql)
+		}
+	}
+}
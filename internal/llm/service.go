@@ -0,0 +1,84 @@
+package llm
+
+import (
+	"github.com/kartoza/kartoza-pg-ai/internal/config"
+	"github.com/kartoza/kartoza-pg-ai/internal/postgres"
+)
+
+// NewServiceEngine creates the query engine answering questions on a
+// service, applying its profile on top of the global settings: the schemas
+// the profile allows, the configured provider and embedder, the row limit,
+// the glossary of the settings and the service's glossary file, the words
+// corrections tied to tables, and earlier questions on the service as
+// examples. cfg may be nil for the
+// defaults. A provider or embedder that cannot be set up is left out and
+// described in warning; the embedder is returned so its index can be built.
+func NewServiceEngine(cfg *config.Config, service *postgres.ServiceEntry, schema *config.SchemaCache) (engine *QueryEngine, embedder Embedder, warning string) {
+	serviceName := ""
+	if service != nil {
+		serviceName = service.Name
+	}
+	engineSchema := schema
+	if cfg != nil {
+		engineSchema = schema.FilterSchemas(cfg.Profile(serviceName).Schemas)
+	}
+	engine = NewQueryEngine(engineSchema)
+	var glossary []map[string]string
+	if cfg != nil {
+		glossary = append(glossary, cfg.SettingsFor(serviceName).Glossary)
+	}
+	if service != nil {
+		engine.SetDefaultSchema(service.DefaultSchema)
+		if service.Glossary != "" {
+			// The service's own glossary wins over the settings'
+			file, err := LoadGlossary(service.Glossary)
+			if err != nil {
+				warning = "Glossary unavailable: " + err.Error()
+			}
+			glossary = append(glossary, file)
+		}
+	}
+	engine.SetGlossary(NewGlossary(glossary...))
+	if cfg != nil {
+		settings := cfg.SettingsFor(serviceName)
+		engine.SetAliases(cfg.Aliases[serviceName])
+		engine.SetRowLimit(settings.DefaultRowLimit)
+		engine.SetStatementTimeout(settings.StatementTimeout())
+		engine.SetAutoLimit(settings.AutoLimit)
+		engine.SetPromptBudget(settings.PromptMaxTables, settings.PromptTokenBudget)
+		engine.SetUseNN(settings.NeuralNetEnabled)
+		engine.SetUsageLog(serviceName, settings.ModelPrices)
+		engine.SetGazetteer(settings.GazetteerTable)
+		if settings.GeocoderURL != "" {
+			engine.SetGeocoder(NewNominatimGeocoder(settings.GeocoderURL))
+		}
+		provider, err := NewProviderFromSettings(settings)
+		if err != nil {
+			warning = "LLM provider unavailable, using rule engine: " + err.Error()
+		} else if provider != nil {
+			engine.SetProvider(provider)
+		}
+		// Every provider with its settings complete can answer a single question
+		for _, name := range ProviderNames {
+			s := settings
+			s.LLMProvider = name
+			if p, err := NewProviderFromSettings(s); err == nil && p != nil {
+				engine.AddProvider(p)
+			}
+		}
+		if embedder, err = NewEmbedderFromSettings(settings); err != nil {
+			warning = "Semantic schema search unavailable: " + err.Error()
+		} else if embedder != nil {
+			engine.SetEmbedder(embedder)
+		} else if similarity, _ := NewSimilarityEmbedder(settings); similarity != nil {
+			// Only embeds the text of pgvector similarity questions
+			engine.SetEmbedder(similarity)
+		}
+	}
+	if store, err := config.History(); err == nil {
+		// Earlier questions on this service become examples for the provider
+		entries, _ := store.List(config.HistoryFilter{ServiceName: serviceName, Limit: MaxExamples})
+		engine.SetExampleStore(NewExampleStore(entries))
+	}
+	return engine, embedder, warning
+}
@@ -0,0 +1,409 @@
+package llm
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kartoza/kartoza-pg-ai/internal/config"
+	"github.com/kartoza/kartoza-pg-ai/internal/logging"
+	"github.com/kartoza/kartoza-pg-ai/internal/postgres"
+)
+
+// placeLookupTimeout bounds looking a place up in the gazetteer or geocoder
+const placeLookupTimeout = 5 * time.Second
+
+// distanceUnits converts the units a distance can be written in to metres
+var distanceUnits = map[string]float64{
+	"m": 1, "meter": 1, "meters": 1, "metre": 1, "metres": 1,
+	"km": 1000, "kilometer": 1000, "kilometers": 1000, "kilometre": 1000, "kilometres": 1000,
+	"mi": 1609.344, "mile": 1609.344, "miles": 1609.344,
+	"ft": 0.3048, "foot": 0.3048, "feet": 0.3048,
+	"yd": 0.9144, "yard": 0.9144, "yards": 0.9144,
+}
+
+// distancePattern finds a distance and its unit, e.g. "1.5 km"
+var distancePattern = regexp.MustCompile(`(\d+(?:\.\d+)?)\s*(kilometres?|kilometers?|km|metres?|meters?|miles?|mi|feet|foot|ft|yards?|yd|m)\b`)
+
+// referenceLead matches the words between a distance and what it is
+// measured from, e.g. "away from the"
+var referenceLead = regexp.MustCompile(`^(?:\s*(?:away|from|of|around|near|to|the|a|an|any|another|nearest|closest)\b)*\s*`)
+
+// referenceEnd matches where the description of a reference ends and the
+// rest of the question begins
+var referenceEnd = regexp.MustCompile(`\s*(?:,|\?|\.$|!|\bsorted by\b|\bordered by\b|\border by\b|\bwhere\b|\bwhich\b|\bthat\b).*$`)
+
+// coordinatePattern matches a leading pair of decimal degrees, e.g. "-33.92, 18.42"
+var coordinatePattern = regexp.MustCompile(`^\(?\s*(-?\d{1,3}(?:\.\d+)?)\s*[,\s]\s*(-?\d{1,3}(?:\.\d+)?)\s*\)?`)
+
+// proximityLead matches the words a distance limit follows, e.g. "within"
+var proximityLead = regexp.MustCompile(`\b(?:within|inside|under|less than|closer than|up to|no more than|no further than)\s*$`)
+
+// proximityWords are the words after a distance that make it a limit on
+// how far features are from a reference, as in "5 km from"
+var proximityWords = regexp.MustCompile(`\b(?:away|from|of|around|near)\b`)
+
+// gazetteerNames are table names detected as gazetteers of place names
+var gazetteerNames = map[string]bool{
+	"gazetteer": true, "gazetteers": true, "places": true, "place_names": true,
+	"placenames": true, "geonames": true, "toponyms": true,
+}
+
+// gazetteerColumns are the columns holding a gazetteer's place names, by preference
+var gazetteerColumns = []string{"name", "place_name", "placename", "toponym", "name_en"}
+
+// SetGazetteer sets the table ("schema.table" or "table") place names in
+// distance questions are looked up in. When unset, a table named like a
+// gazetteer, e.g. "places" or "geonames", is used.
+func (e *QueryEngine) SetGazetteer(table string) {
+	e.gazetteer = table
+}
+
+// SetGeocoder sets the geocoder locating place names no gazetteer holds;
+// nil leaves them to be entered as coordinates
+func (e *QueryEngine) SetGeocoder(g Geocoder) {
+	e.geocoder = g
+}
+
+// parseDistance finds a limit on distance in text, e.g. "within 2 km of",
+// returning it in metres with the text before it and the description of
+// what it is measured from
+func parseDistance(text string) (meters float64, subject, reference string, ok bool) {
+	loc := distancePattern.FindStringSubmatchIndex(text)
+	if loc == nil {
+		return 0, "", "", false
+	}
+	value, err := strconv.ParseFloat(text[loc[2]:loc[3]], 64)
+	if err != nil {
+		return 0, "", "", false
+	}
+	meters = value * distanceUnits[text[loc[4]:loc[5]]]
+	subject, rest := text[:loc[0]], text[loc[1]:]
+
+	// "roads longer than 5 km" is about length, not proximity
+	lead := referenceLead.FindString(rest)
+	if !proximityLead.MatchString(subject) && !proximityWords.MatchString(lead) {
+		return 0, "", "", false
+	}
+
+	rest = rest[len(lead):]
+	if coords := coordinatePattern.FindString(rest); coords != "" {
+		return meters, subject, strings.TrimSpace(coords), true
+	}
+	reference = strings.TrimSpace(referenceEnd.ReplaceAllString(rest, ""))
+	return meters, subject, reference, true
+}
+
+// spatialReference is what a distance or route is measured from: a WGS 84
+// point, or the rows of a table matching a condition
+type spatialReference struct {
+	lon, lat string            // SQL of the point's coordinates, when table is nil
+	table    *config.TableInfo // Table of reference features, aliased r
+	column   config.ColumnInfo // Geometry column of table
+	filter   string            // Condition on r selecting the features; "" for all
+}
+
+// geography returns the geography of a point reference
+func (r spatialReference) geography() string {
+	return fmt.Sprintf("ST_SetSRID(ST_MakePoint(%s, %s), 4326)::geography", r.lon, r.lat)
+}
+
+// geometry returns the geometry of the reference in srid (0 when unknown),
+// the first matching feature's for a table reference
+func (r spatialReference) geometry(srid int) string {
+	if r.table != nil {
+		where := ""
+		if r.filter != "" {
+			where = " WHERE " + r.filter
+		}
+		return fmt.Sprintf(`(SELECT %s FROM "%s"."%s" r%s LIMIT 1)`,
+			geometryExpr("r", r.column, srid), r.table.Schema, r.table.Name, where)
+	}
+	point := fmt.Sprintf("ST_MakePoint(%s, %s)", r.lon, r.lat)
+	switch srid {
+	case 0:
+		return point
+	case 4326:
+		return "ST_SetSRID(" + point + ", 4326)"
+	default:
+		return fmt.Sprintf("ST_Transform(ST_SetSRID(%s, 4326), %d)", point, srid)
+	}
+}
+
+// matchDistanceQuery answers questions about features within a distance of
+// a place or of other features, e.g. "schools within 1 km of the hospital",
+// "parcels within 500m of -33.92, 18.42" or "how many stops within 2 miles
+// of Cape Town". Distances are measured in metres on the spheroid, casting
+// geometries to geography from WGS 84.
+func (e *QueryEngine) matchDistanceQuery(query string) string {
+	meters, subject, reference, ok := parseDistance(query)
+	if !ok {
+		return ""
+	}
+	geomTables := e.geometryTables()
+	if len(geomTables) == 0 {
+		return ""
+	}
+
+	// The table asked about is named before the distance
+	table, geomCol := mentionedGeometry(geomTables, subject)
+	if geomCol == "" {
+		table, geomCol = pickIndexedGeometry(geomTables)
+		if geomCol == "" {
+			return ""
+		}
+	}
+	target := geographyExpr("t", columnNamed(table, geomCol))
+	distance := strconv.FormatFloat(meters, 'f', -1, 64)
+	counting := strings.HasPrefix(query, "how many") || strings.HasPrefix(query, "count")
+	from := fmt.Sprintf(`FROM "%s"."%s" t`, table.Schema, table.Name)
+
+	ref := e.resolveReference(reference, "")
+	if ref.table != nil {
+		conditions := []string{}
+		if ref.filter != "" {
+			conditions = append(conditions, ref.filter)
+		}
+		if ref.table.Schema == table.Schema && ref.table.Name == table.Name {
+			// A feature is not near itself
+			conditions = append(conditions, "r.ctid <> t.ctid")
+		}
+		conditions = append(conditions, fmt.Sprintf("ST_DWithin(%s, %s, %s)", target, geographyExpr("r", ref.column), distance))
+		where := fmt.Sprintf(`WHERE EXISTS (SELECT 1 FROM "%s"."%s" r WHERE %s)`,
+			ref.table.Schema, ref.table.Name, strings.Join(conditions, " AND "))
+		if counting {
+			return fmt.Sprintf("SELECT COUNT(*) AS count %s %s", from, where)
+		}
+		return fmt.Sprintf("SELECT t.* %s %s%s", from, where, e.limitClause())
+	}
+
+	point := ref.geography()
+	where := fmt.Sprintf("WHERE ST_DWithin(%s, %s, %s)", target, point, distance)
+	if counting {
+		return fmt.Sprintf("SELECT COUNT(*) AS count %s %s", from, where)
+	}
+	return fmt.Sprintf("SELECT t.*, ST_Distance(%s, %s) AS distance_m %s %s ORDER BY distance_m LIMIT %d",
+		target, point, from, where, e.rowLimit())
+}
+
+// resolveReference works out what a distance is measured from: written
+// coordinates, reference features named by their table or by a value of
+// one of its columns, a place in the gazetteer, or a geocoded place.
+// Anything else becomes a point whose coordinates are asked for when the
+// SQL runs, as {{longitude}} and {{latitude}} parameters named with the
+// prefix param, e.g. {{from_longitude}}.
+func (e *QueryEngine) resolveReference(reference, param string) spatialReference {
+	asked := spatialReference{lon: "{{" + param + "longitude}}", lat: "{{" + param + "latitude}}"}
+	if reference == "" {
+		return asked
+	}
+
+	if m := coordinatePattern.FindStringSubmatch(reference); m != nil && len(m[0]) == len(reference) {
+		a, _ := strconv.ParseFloat(m[1], 64)
+		b, _ := strconv.ParseFloat(m[2], 64)
+		// Written coordinates are usually latitude first, unless that cannot be
+		lon, lat := b, a
+		if a < -90 || a > 90 {
+			lon, lat = a, b
+		}
+		return pointReference(lon, lat)
+	}
+
+	geomTables := e.geometryTables()
+	padded := padWords(reference)
+	if table, geomCol := mentionedGeometry(geomTables, reference); geomCol != "" {
+		ref := spatialReference{table: &table, column: columnNamed(table, geomCol)}
+		if column, value := mentionedValue(table, padded); column != "" {
+			ref.filter = fmt.Sprintf("r.%s = %s", quoteIdent(column), sqlString(value))
+		}
+		return ref
+	}
+	for i := range geomTables {
+		if column, value := mentionedValue(geomTables[i], padded); column != "" {
+			_, geomCol := pickIndexedGeometry(geomTables[i : i+1])
+			return spatialReference{
+				table:  &geomTables[i],
+				column: columnNamed(geomTables[i], geomCol),
+				filter: fmt.Sprintf("r.%s = %s", quoteIdent(column), sqlString(value)),
+			}
+		}
+	}
+
+	if gazetteer, nameCol, geomCol := e.findGazetteer(); nameCol != "" && e.gazetteerHas(gazetteer, nameCol, reference) {
+		return spatialReference{
+			table:  gazetteer,
+			column: columnNamed(*gazetteer, geomCol),
+			filter: fmt.Sprintf("lower(r.%s) = %s", quoteIdent(nameCol), sqlString(strings.ToLower(reference))),
+		}
+	}
+
+	if e.geocoder != nil {
+		ctx, cancel := e.lookupContext()
+		lon, lat, err := e.geocoder.Geocode(ctx, reference)
+		cancel()
+		if err == nil {
+			return pointReference(lon, lat)
+		}
+		logging.Warn("place could not be geocoded", "place", reference, "error", err)
+	}
+	return asked
+}
+
+// findGazetteer returns the table place names are looked up in, with its
+// name and geometry columns; nameCol is "" without one
+func (e *QueryEngine) findGazetteer() (table *config.TableInfo, nameCol, geomCol string) {
+	for i := range e.schema.Tables {
+		t := &e.schema.Tables[i]
+		if e.gazetteer != "" {
+			if !strings.EqualFold(e.gazetteer, t.Name) && !strings.EqualFold(e.gazetteer, t.Schema+"."+t.Name) {
+				continue
+			}
+		} else if !gazetteerNames[strings.ToLower(t.Name)] {
+			continue
+		}
+		_, geomCol = pickIndexedGeometry([]config.TableInfo{*t})
+		if geomCol == "" {
+			continue
+		}
+		for _, name := range gazetteerColumns {
+			for _, c := range t.Columns {
+				if strings.EqualFold(c.Name, name) {
+					return t, c.Name, geomCol
+				}
+			}
+		}
+	}
+	return nil, "", ""
+}
+
+// gazetteerHas reports whether the gazetteer holds a place, looking it up
+// in a read-only transaction bound by the statement timeout. Without a
+// database connection the place is assumed to be there.
+func (e *QueryEngine) gazetteerHas(table *config.TableInfo, nameCol, place string) bool {
+	if e.db == nil {
+		return true
+	}
+	ctx, cancel := e.lookupContext()
+	defer cancel()
+	var found bool
+	tx, err := postgres.BeginTx(postgres.WithStatementTimeout(ctx, e.timeout), e.db, &sql.TxOptions{ReadOnly: true})
+	if err == nil {
+		defer tx.Rollback()
+		err = tx.QueryRowContext(ctx, fmt.Sprintf(`SELECT EXISTS (SELECT 1 FROM "%s"."%s" WHERE lower(%s) = $1)`,
+			table.Schema, table.Name, quoteIdent(nameCol)), strings.ToLower(place)).Scan(&found)
+	}
+	if err != nil {
+		logging.Warn("gazetteer lookup failed", "table", table.Schema+"."+table.Name, "error", err)
+		return e.geocoder == nil
+	}
+	return found
+}
+
+// lookupContext bounds a gazetteer or geocoder lookup made while matching
+func (e *QueryEngine) lookupContext() (context.Context, context.CancelFunc) {
+	ctx := e.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return context.WithTimeout(ctx, placeLookupTimeout)
+}
+
+// geometryTables returns the tables with a geometry or geography column
+func (e *QueryEngine) geometryTables() []config.TableInfo {
+	var tables []config.TableInfo
+	for _, table := range e.schema.Tables {
+		for _, col := range table.Columns {
+			if col.IsGeometry {
+				tables = append(tables, table)
+				break
+			}
+		}
+	}
+	return tables
+}
+
+// mentionedGeometry returns the geometry table text mentions first, with
+// its preferred geometry column; the column is "" when none is mentioned
+func mentionedGeometry(tables []config.TableInfo, text string) (config.TableInfo, string) {
+	padded := padWords(text)
+	best, bestPos := -1, -1
+	for i, table := range tables {
+		if pos := tableMention(padded, table.Name); pos >= 0 && (bestPos < 0 || pos < bestPos) {
+			best, bestPos = i, pos
+		}
+	}
+	if best < 0 {
+		return config.TableInfo{}, ""
+	}
+	return pickIndexedGeometry(tables[best : best+1])
+}
+
+// mentionedValue returns a column of table whose harvested common values
+// include a word or phrase of padded, and that value
+func mentionedValue(table config.TableInfo, padded string) (column, value string) {
+	for _, c := range table.Columns {
+		if c.Stats == nil {
+			continue
+		}
+		for _, v := range c.Stats.CommonValues {
+			if len(v) >= 3 && strings.Contains(padded, " "+strings.ToLower(v)+" ") {
+				return c.Name, v
+			}
+		}
+	}
+	return "", ""
+}
+
+// columnNamed returns the column of table with a name
+func columnNamed(table config.TableInfo, name string) config.ColumnInfo {
+	for _, c := range table.Columns {
+		if c.Name == name {
+			return c
+		}
+	}
+	return config.ColumnInfo{Name: name}
+}
+
+// geographyExpr casts a geometry column of the table aliased alias to
+// geography, so distances are in metres. Geography needs longitude and
+// latitude, so geometries in another SRID are transformed to WGS 84 first.
+func geographyExpr(alias string, col config.ColumnInfo) string {
+	ref := alias + "." + quoteIdent(col.Name)
+	switch {
+	case strings.HasPrefix(col.DataType, "geography"):
+		return ref
+	case col.SRID != 0 && col.SRID != 4326:
+		return "ST_Transform(" + ref + ", 4326)::geography"
+	default:
+		return ref + "::geography"
+	}
+}
+
+// geometryExpr returns a geometry or geography column of the table aliased
+// alias as geometry in srid; with srid 0 it is left in its own
+func geometryExpr(alias string, col config.ColumnInfo, srid int) string {
+	ref := alias + "." + quoteIdent(col.Name)
+	from := col.SRID
+	if strings.HasPrefix(col.DataType, "geography") {
+		ref += "::geometry"
+		if from == 0 {
+			from = 4326
+		}
+	}
+	if srid == 0 || srid == from {
+		return ref
+	}
+	return fmt.Sprintf("ST_Transform(%s, %d)", ref, srid)
+}
+
+// pointReference is a reference to a WGS 84 point
+func pointReference(lon, lat float64) spatialReference {
+	return spatialReference{
+		lon: strconv.FormatFloat(lon, 'f', -1, 64),
+		lat: strconv.FormatFloat(lat, 'f', -1, 64),
+	}
+}
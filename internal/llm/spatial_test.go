@@ -0,0 +1,151 @@
+package llm
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kartoza/kartoza-pg-ai/internal/config"
+)
+
+// spatialSchema has schools in Web Mercator, points of interest with an
+// amenity column and a gazetteer of places
+func spatialSchema() *config.SchemaCache {
+	return &config.SchemaCache{
+		HasPostGIS: true,
+		Tables: []config.TableInfo{
+			{Schema: "public", Name: "schools", Columns: []config.ColumnInfo{
+				{Name: "id", DataType: "integer", IsPrimaryKey: true},
+				{Name: "geom", DataType: "geometry", IsGeometry: true, GeomType: "POINT", SRID: 3857},
+			}},
+			{Schema: "public", Name: "pois", Columns: []config.ColumnInfo{
+				{Name: "id", DataType: "integer", IsPrimaryKey: true},
+				{Name: "amenity", DataType: "text", Stats: &config.ColumnStats{
+					CommonValues: []string{"hospital", "pharmacy"},
+				}},
+				{Name: "location", DataType: "geography", IsGeometry: true, GeomType: "POINT", SRID: 4326},
+			}},
+			{Schema: "ref", Name: "places", Columns: []config.ColumnInfo{
+				{Name: "name", DataType: "text"},
+				{Name: "geom", DataType: "geometry", IsGeometry: true, GeomType: "POINT", SRID: 4326},
+			}},
+		},
+	}
+}
+
+func TestParseDistance(t *testing.T) {
+	tests := []struct {
+		text      string
+		meters    float64
+		reference string
+		ok        bool
+	}{
+		{"schools within 1.5 km of the hospital", 1500, "hospital", true},
+		{"schools within 500m of -33.92, 18.42 sorted by name", 500, "-33.92, 18.42", true},
+		{"schools 2 miles away from cape town", 3218.688, "cape town", true},
+		{"stops within 300 feet", 91.44, "", true},
+		{"roads longer than 5 km", 0, "", false},
+		{"show 5 movies", 0, "", false},
+	}
+	for _, tt := range tests {
+		meters, _, reference, ok := parseDistance(tt.text)
+		if ok != tt.ok || reference != tt.reference || fmt.Sprintf("%.3f", meters) != fmt.Sprintf("%.3f", tt.meters) {
+			t.Errorf("parseDistance(%q) = %v, %q, %v; want %v, %q, %v",
+				tt.text, meters, reference, ok, tt.meters, tt.reference, tt.ok)
+		}
+	}
+}
+
+func TestDistanceQueryReferences(t *testing.T) {
+	engine := NewQueryEngine(spatialSchema())
+	engine.SetUseNN(false)
+
+	tests := []struct {
+		query    string
+		expected []string
+	}{
+		{
+			// Coordinates, latitude first, against a transformed geometry
+			"schools within 2 km of -33.92, 18.42",
+			[]string{
+				`ST_DWithin(ST_Transform(t."geom", 4326)::geography, ST_SetSRID(ST_MakePoint(18.42, -33.92), 4326)::geography, 2000)`,
+				`FROM "public"."schools" t`,
+				"ORDER BY distance_m",
+			},
+		},
+		{
+			// A value of another table's column names the reference features
+			"schools within 1 km of the hospital",
+			[]string{
+				`EXISTS (SELECT 1 FROM "public"."pois" r WHERE r."amenity" = 'hospital'`,
+				`ST_DWithin(ST_Transform(t."geom", 4326)::geography, r."location", 1000)`,
+			},
+		},
+		{
+			// Features near others of the same table
+			"how many schools within 500 m of another school",
+			[]string{
+				"SELECT COUNT(*) AS count",
+				`FROM "public"."schools" r WHERE r.ctid <> t.ctid`,
+			},
+		},
+		{
+			// A place in the gazetteer
+			"pois within 3 miles of cape town",
+			[]string{
+				`FROM "ref"."places" r WHERE lower(r."name") = 'cape town'`,
+				`ST_DWithin(t."location", r."geom"::geography, 4828.032)`,
+			},
+		},
+		{
+			// No reference: its coordinates are asked for
+			"pois within 200 m",
+			[]string{"ST_MakePoint({{longitude}}, {{latitude}})"},
+		},
+	}
+
+	for _, tt := range tests {
+		sql, err := engine.GenerateSQL(tt.query, "")
+		if err != nil {
+			t.Fatalf("GenerateSQL(%q): %v", tt.query, err)
+		}
+		for _, want := range tt.expected {
+			if !strings.Contains(sql, want) {
+				t.Errorf("GenerateSQL(%q) = %s\nwant it to contain %s", tt.query, sql, want)
+			}
+		}
+	}
+}
+
+func TestDistanceQueryGeocodesUnknownPlaces(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path != "/search" || r.URL.Query().Get("q") != "stellenbosch" {
+			t.Errorf("unexpected geocoder request %s", r.URL)
+		}
+		fmt.Fprint(w, `[{"lat": "-33.9321", "lon": "18.8602"}]`)
+	}))
+	defer server.Close()
+
+	schema := spatialSchema()
+	schema.Tables = schema.Tables[:2] // No gazetteer
+	engine := NewQueryEngine(schema)
+	engine.SetUseNN(false)
+	engine.SetGeocoder(NewNominatimGeocoder(server.URL))
+
+	for range 2 {
+		sql, err := engine.GenerateSQL("schools within 10 km of stellenbosch", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(sql, "ST_MakePoint(18.8602, -33.9321)") {
+			t.Errorf("expected the geocoded point, got %s", sql)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("expected the place to be geocoded once, got %d requests", requests)
+	}
+}
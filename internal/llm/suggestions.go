@@ -0,0 +1,203 @@
+package llm
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/kartoza/kartoza-pg-ai/internal/config"
+)
+
+// maxCategories is the number of distinct values above which a text column
+// no longer reads as a category to group by
+const maxCategories = 50
+
+// categoryNameSuffixes mark columns grouped by even without statistics
+var categoryNameSuffixes = []string{"type", "category", "class", "status", "kind", "state"}
+
+// numericTypes are the data types whose columns can be summed and averaged
+var numericTypes = map[string]bool{
+	"smallint": true, "integer": true, "bigint": true, "numeric": true,
+	"real": true, "double precision": true, "money": true,
+}
+
+// SuggestQuestions returns up to limit example questions about the tables
+// of a schema, biggest tables first: counts, listings, totals and
+// averages of numeric columns by a category, lengths and areas of
+// geometries and filters on common values. Every kind of question is
+// offered once before any is repeated for another table.
+func SuggestQuestions(schema *config.SchemaCache, limit int) []string {
+	if schema == nil || limit <= 0 {
+		return nil
+	}
+	tables := append([]config.TableInfo{}, schema.WithoutPartitions().Readable().Tables...)
+	sort.SliceStable(tables, func(i, j int) bool {
+		return rowEstimate(tables[i]) > rowEstimate(tables[j])
+	})
+
+	// One list of questions for each kind, in the order the kinds are offered
+	kinds := make([][]string, 6)
+	for _, t := range tables {
+		category := categoryColumn(t)
+		measure := measureColumn(t)
+		kinds[0] = append(kinds[0], fmt.Sprintf("How many rows are in %s?", t.Name))
+		if category != "" {
+			kinds[1] = append(kinds[1], fmt.Sprintf("How many %s are there by %s?", t.Name, category))
+		}
+		for _, c := range t.Columns {
+			if !c.IsGeometry {
+				continue
+			}
+			switch strings.TrimPrefix(c.GeomType, "MULTI") {
+			case "LINESTRING":
+				if category != "" {
+					kinds[2] = append(kinds[2], fmt.Sprintf("Total length of %s by %s", t.Name, category))
+				} else {
+					kinds[2] = append(kinds[2], fmt.Sprintf("What is the total length of %s?", t.Name))
+				}
+			case "POLYGON":
+				kinds[2] = append(kinds[2], fmt.Sprintf("Which %s have the largest area?", t.Name))
+			}
+			break
+		}
+		if measure != "" {
+			if category != "" {
+				kinds[3] = append(kinds[3], fmt.Sprintf("Average %s of %s by %s", measure, t.Name, category))
+			} else {
+				kinds[3] = append(kinds[3], fmt.Sprintf("What is the total %s of %s?", measure, t.Name))
+			}
+		}
+		if column, value := commonValue(t); value != "" {
+			kinds[4] = append(kinds[4], fmt.Sprintf("Show %s where %s is %s", t.Name, column, value))
+		}
+		kinds[5] = append(kinds[5], fmt.Sprintf("Show the first 10 rows of %s", t.Name))
+	}
+
+	var questions []string
+	for round := 0; len(questions) < limit; round++ {
+		added := false
+		for _, kind := range kinds {
+			if round < len(kind) && len(questions) < limit {
+				questions = append(questions, kind[round])
+				added = true
+			}
+		}
+		if !added {
+			break
+		}
+	}
+	return questions
+}
+
+// MatchSuggestions returns up to limit of the suggestions that every word
+// typed so far starts a word of, case-insensitively; the last word may be
+// incomplete. Suggestions starting with the text typed come first.
+func MatchSuggestions(suggestions []string, typed string, limit int) []string {
+	typed = strings.ToLower(strings.TrimSpace(typed))
+	if typed == "" {
+		return nil
+	}
+	words := strings.Fields(typed)
+	var prefixed, others []string
+	for _, s := range suggestions {
+		lower := strings.ToLower(s)
+		if lower == typed {
+			continue
+		}
+		suggestionWords := strings.FieldsFunc(lower, func(r rune) bool {
+			return r == ' ' || r == '?' || r == ','
+		})
+		matches := true
+		for _, w := range words {
+			found := false
+			for _, sw := range suggestionWords {
+				if strings.HasPrefix(sw, w) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				matches = false
+				break
+			}
+		}
+		switch {
+		case !matches:
+		case strings.HasPrefix(lower, typed):
+			prefixed = append(prefixed, s)
+		default:
+			others = append(others, s)
+		}
+	}
+	matched := append(prefixed, others...)
+	if len(matched) > limit {
+		matched = matched[:limit]
+	}
+	return matched
+}
+
+// rowEstimate returns the planner's row estimate of a table, 0 when unknown
+func rowEstimate(t config.TableInfo) int64 {
+	if t.Size == nil {
+		return 0
+	}
+	return max(t.Size.RowEstimate, 0)
+}
+
+// categoryColumn returns a text column of t with few distinct values, or
+// named like a category when no statistics were harvested
+func categoryColumn(t config.TableInfo) string {
+	var named string
+	for _, c := range t.Columns {
+		if c.IsPrimaryKey || c.IsForeignKey || !isTextType(c.DataType) {
+			continue
+		}
+		if c.Stats != nil && c.Stats.DistinctCount > 1 && c.Stats.DistinctCount <= maxCategories {
+			return c.Name
+		}
+		if named == "" && c.Stats == nil {
+			for _, suffix := range categoryNameSuffixes {
+				if strings.HasSuffix(strings.ToLower(c.Name), suffix) {
+					named = c.Name
+					break
+				}
+			}
+		}
+	}
+	return named
+}
+
+// measureColumn returns a numeric column of t worth adding up: not a key
+// or an identifier
+func measureColumn(t config.TableInfo) string {
+	for _, c := range t.Columns {
+		name := strings.ToLower(c.Name)
+		if c.IsPrimaryKey || c.IsForeignKey || !numericTypes[c.DataType] ||
+			name == "id" || strings.HasSuffix(name, "_id") || name == "gid" || name == "fid" {
+			continue
+		}
+		return c.Name
+	}
+	return ""
+}
+
+// commonValue returns a text column of t and its most common value, when
+// statistics were harvested and the value is short enough to type
+func commonValue(t config.TableInfo) (string, string) {
+	for _, c := range t.Columns {
+		if c.IsPrimaryKey || c.IsForeignKey || !isTextType(c.DataType) || c.Stats == nil {
+			continue
+		}
+		for _, v := range c.Stats.CommonValues {
+			if v != "" && len(v) <= 30 && !strings.ContainsAny(v, "\n\"") {
+				return c.Name, v
+			}
+		}
+	}
+	return "", ""
+}
+
+// isTextType reports whether a data type holds text
+func isTextType(dataType string) bool {
+	return dataType == "text" || strings.HasPrefix(dataType, "character")
+}
@@ -0,0 +1,80 @@
+package llm
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/kartoza/kartoza-pg-ai/internal/config"
+)
+
+// suggestionSchema has roads with a road type, parcels with an area and
+// owner, and a small lookup table without statistics
+func suggestionSchema() *config.SchemaCache {
+	return &config.SchemaCache{
+		HasPostGIS: true,
+		Tables: []config.TableInfo{
+			{Schema: "public", Name: "lookup", Columns: []config.ColumnInfo{
+				{Name: "code", DataType: "text", IsPrimaryKey: true},
+				{Name: "status", DataType: "character varying"},
+			}},
+			{Schema: "public", Name: "parcels", Size: &config.TableSize{RowEstimate: 50000}, Columns: []config.ColumnInfo{
+				{Name: "id", DataType: "integer", IsPrimaryKey: true},
+				{Name: "owner", DataType: "text", Stats: &config.ColumnStats{DistinctCount: -0.8, CommonValues: []string{"City of Cape Town"}}},
+				{Name: "area_ha", DataType: "double precision"},
+				{Name: "geom", DataType: "geometry", IsGeometry: true, GeomType: "MULTIPOLYGON"},
+			}},
+			{Schema: "public", Name: "roads", Size: &config.TableSize{RowEstimate: 1000}, Columns: []config.ColumnInfo{
+				{Name: "gid", DataType: "integer", IsPrimaryKey: true},
+				{Name: "road_type", DataType: "text", Stats: &config.ColumnStats{DistinctCount: 6, CommonValues: []string{"residential"}}},
+				{Name: "geom", DataType: "geometry", IsGeometry: true, GeomType: "LINESTRING"},
+			}},
+		},
+	}
+}
+
+func TestSuggestQuestions(t *testing.T) {
+	got := SuggestQuestions(suggestionSchema(), 8)
+	want := []string{
+		"How many rows are in parcels?",
+		"How many roads are there by road_type?",
+		"Which parcels have the largest area?",
+		"What is the total area_ha of parcels?",
+		"Show parcels where owner is City of Cape Town",
+		"Show the first 10 rows of parcels",
+		"How many rows are in roads?",
+		"How many lookup are there by status?",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("SuggestQuestions() =\n%q\nwant\n%q", got, want)
+	}
+
+	all := SuggestQuestions(suggestionSchema(), 100)
+	if len(all) != 13 {
+		t.Errorf("SuggestQuestions() offered %d questions, want 13: %q", len(all), all)
+	}
+	if SuggestQuestions(nil, 5) != nil {
+		t.Error("SuggestQuestions(nil) should offer nothing")
+	}
+}
+
+func TestMatchSuggestions(t *testing.T) {
+	suggestions := SuggestQuestions(suggestionSchema(), 100)
+	tests := []struct {
+		typed string
+		want  []string
+	}{
+		{"total len", []string{"Total length of roads by road_type"}},
+		{"how many ro", []string{"How many rows are in parcels?", "How many roads are there by road_type?", "How many rows are in roads?"}},
+		{"rows parcels", []string{"How many rows are in parcels?", "Show the first 10 rows of parcels"}},
+		{"area", []string{"Which parcels have the largest area?", "What is the total area_ha of parcels?"}},
+		{"owner cape", []string{"Show parcels where owner is City of Cape Town"}},
+		{"Show the first 10 rows of roads", nil},
+		{"bridges", nil},
+		{"  ", nil},
+	}
+	for _, tt := range tests {
+		if got := MatchSuggestions(suggestions, tt.typed, 3); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("MatchSuggestions(%q) = %q, want %q", tt.typed, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,359 @@
+package llm
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/kartoza/kartoza-pg-ai/internal/config"
+	"github.com/kartoza/kartoza-pg-ai/internal/postgres"
+)
+
+// Tool names exposed to tool-calling providers
+const (
+	ToolListTables    = "list_tables"
+	ToolDescribeTable = "describe_table"
+	ToolSampleRows    = "sample_rows"
+)
+
+// maxSampleRows caps how many rows sample_rows may return
+const maxSampleRows = 10
+
+// ToolDefinition describes a tool a model may call
+type ToolDefinition struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	InputSchema map[string]any `json:"input_schema"`
+}
+
+// SchemaTools answers schema lookup tool calls from the SchemaCache and,
+// for sample_rows, the live database connection
+type SchemaTools struct {
+	schema  *config.SchemaCache
+	db      *sql.DB       // May be nil; sample_rows is then unavailable
+	timeout time.Duration // Statement timeout of sample_rows; 0 for none
+}
+
+// NewSchemaTools creates tools backed by the given schema and connection,
+// whose queries are bound by timeout
+func NewSchemaTools(schema *config.SchemaCache, db *sql.DB, timeout time.Duration) *SchemaTools {
+	return &SchemaTools{schema: schema, db: db, timeout: timeout}
+}
+
+// Definitions returns the tool definitions to advertise to the model
+func (t *SchemaTools) Definitions() []ToolDefinition {
+	tableParam := map[string]any{
+		"type":        "string",
+		"description": "Table or view name, optionally schema-qualified (e.g. public.roads)",
+	}
+
+	defs := []ToolDefinition{
+		{
+			Name:        ToolListTables,
+			Description: "List tables and views in the database with their column counts. Optionally filter by schema or a name substring.",
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"schema": map[string]any{"type": "string", "description": "Only list objects in this schema"},
+					"filter": map[string]any{"type": "string", "description": "Case-insensitive substring the name must contain"},
+				},
+			},
+		},
+		{
+			Name:        ToolDescribeTable,
+			Description: "Describe the columns of a table or view: data types, nullability, keys, foreign key targets, geometry types and common values.",
+			InputSchema: map[string]any{
+				"type":       "object",
+				"properties": map[string]any{"table": tableParam},
+				"required":   []string{"table"},
+			},
+		},
+	}
+
+	if t.db != nil {
+		defs = append(defs, ToolDefinition{
+			Name:        ToolSampleRows,
+			Description: fmt.Sprintf("Fetch up to %d example rows from a table or view to see what the data looks like.", maxSampleRows),
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"table": tableParam,
+					"limit": map[string]any{"type": "integer", "description": "Number of rows (default 5)"},
+				},
+				"required": []string{"table"},
+			},
+		})
+	}
+
+	return defs
+}
+
+// Execute runs the named tool with JSON input and returns a text result
+func (t *SchemaTools) Execute(ctx context.Context, name string, input json.RawMessage) (string, error) {
+	var args struct {
+		Schema string `json:"schema"`
+		Filter string `json:"filter"`
+		Table  string `json:"table"`
+		Limit  int    `json:"limit"`
+	}
+	if len(input) > 0 {
+		if err := json.Unmarshal(input, &args); err != nil {
+			return "", fmt.Errorf("invalid tool input: %w", err)
+		}
+	}
+
+	switch name {
+	case ToolListTables:
+		return t.listTables(args.Schema, args.Filter), nil
+	case ToolDescribeTable:
+		return t.describeTable(args.Table)
+	case ToolSampleRows:
+		return t.sampleRows(ctx, args.Table, args.Limit)
+	default:
+		return "", fmt.Errorf("unknown tool: %s", name)
+	}
+}
+
+// listTables returns one line per table/view matching the filters
+func (t *SchemaTools) listTables(schemaName, filter string) string {
+	if t.schema == nil {
+		return "No schema loaded"
+	}
+
+	filter = strings.ToLower(filter)
+	matches := func(schema, name string) bool {
+		if schemaName != "" && !strings.EqualFold(schema, schemaName) {
+			return false
+		}
+		return filter == "" || strings.Contains(strings.ToLower(name), filter)
+	}
+
+	var out strings.Builder
+	for _, tbl := range t.schema.Tables {
+		if !matches(tbl.Schema, tbl.Name) {
+			continue
+		}
+		out.WriteString(fmt.Sprintf("table %s.%s (%d columns)", tbl.Schema, tbl.Name, len(tbl.Columns)))
+		if hasGeometryColumn(tbl.Columns) {
+			out.WriteString(" [spatial]")
+		}
+		if tbl.PartitionKey != "" {
+			out.WriteString(" [partitioned by " + tbl.PartitionKey + "]")
+		}
+		if summary := tbl.Size.Summary(); summary != "" {
+			out.WriteString(" [" + summary + "]")
+		}
+		if tbl.Comment != "" {
+			out.WriteString(" - " + tbl.Comment)
+		}
+		out.WriteString("\n")
+	}
+	for _, v := range t.schema.Views {
+		if !matches(v.Schema, v.Name) {
+			continue
+		}
+		kind := "view"
+		if v.IsMaterialized {
+			kind = "materialized view"
+		}
+		out.WriteString(fmt.Sprintf("%s %s.%s (%d columns)\n", kind, v.Schema, v.Name, len(v.Columns)))
+	}
+
+	if out.Len() == 0 {
+		return "No matching tables or views"
+	}
+	return out.String()
+}
+
+// describeTable returns the column details for a table or view
+func (t *SchemaTools) describeTable(name string) (string, error) {
+	schemaName, tableName, columns, comment, ok := t.lookup(name)
+	if !ok {
+		return "", fmt.Errorf("table not found: %s", name)
+	}
+
+	var out strings.Builder
+	out.WriteString(fmt.Sprintf("%s.%s", schemaName, tableName))
+	if comment != "" {
+		out.WriteString(" - " + comment)
+	}
+	out.WriteString("\n")
+
+	for _, c := range columns {
+		out.WriteString(fmt.Sprintf("- %s %s", c.Name, c.DataType))
+		if c.IsGeometry {
+			out.WriteString(fmt.Sprintf(" (%s, SRID %d)", c.GeomType, c.SRID))
+		}
+		if c.IsVector && c.VectorDims > 0 {
+			out.WriteString(fmt.Sprintf(" (%d dimensions)", c.VectorDims))
+		}
+		if c.IsPrimaryKey {
+			out.WriteString(" PRIMARY KEY")
+		}
+		if c.IsForeignKey && c.FKTable != "" {
+			out.WriteString(fmt.Sprintf(" REFERENCES %s(%s)", c.FKTable, c.FKColumn))
+		}
+		if !c.IsNullable {
+			out.WriteString(" NOT NULL")
+		}
+		if c.Comment != "" {
+			out.WriteString(" -- " + c.Comment)
+		}
+		if len(c.AcceptedValues) > 0 {
+			out.WriteString(" [values: " + strings.Join(c.AcceptedValues, ", ") + "]")
+		}
+		if summary := c.Stats.Summary(); summary != "" {
+			out.WriteString(" [" + summary + "]")
+		}
+		out.WriteString("\n")
+	}
+
+	for _, tbl := range t.schema.Tables {
+		if tbl.Schema != schemaName || tbl.Name != tableName {
+			continue
+		}
+		for _, idx := range tbl.Indexes {
+			if !idx.IsPrimary {
+				out.WriteString(describeIndex(idx) + "\n")
+			}
+		}
+		for _, con := range tbl.Constraints {
+			out.WriteString(fmt.Sprintf("%s %s\n", con.Type, con.Definition))
+		}
+	}
+	return out.String(), nil
+}
+
+// sampleRows fetches example rows inside a read-only transaction bound by
+// the statement timeout
+func (t *SchemaTools) sampleRows(ctx context.Context, name string, limit int) (string, error) {
+	if t.db == nil {
+		return "", fmt.Errorf("no database connection available")
+	}
+
+	// Only tables known to the schema cache are queried, so the identifiers
+	// below never come straight from the model
+	schemaName, tableName, _, _, ok := t.lookup(name)
+	if !ok {
+		return "", fmt.Errorf("table not found: %s", name)
+	}
+
+	if limit <= 0 {
+		limit = 5
+	}
+	if limit > maxSampleRows {
+		limit = maxSampleRows
+	}
+
+	ctx = postgres.WithStatementTimeout(ctx, t.timeout)
+	tx, err := postgres.BeginTx(ctx, t.db, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	query := fmt.Sprintf("SELECT * FROM %s.%s LIMIT %d", quoteIdent(schemaName), quoteIdent(tableName), limit)
+	rows, err := tx.QueryContext(ctx, query)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return "", err
+	}
+
+	var out strings.Builder
+	out.WriteString(strings.Join(cols, " | "))
+	out.WriteString("\n")
+
+	values := make([]interface{}, len(cols))
+	ptrs := make([]interface{}, len(cols))
+	for i := range values {
+		ptrs[i] = &values[i]
+	}
+
+	count := 0
+	for rows.Next() {
+		if err := rows.Scan(ptrs...); err != nil {
+			return "", err
+		}
+		cells := make([]string, len(cols))
+		for i, v := range values {
+			cells[i] = sampleValue(v)
+		}
+		out.WriteString(strings.Join(cells, " | "))
+		out.WriteString("\n")
+		count++
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+
+	if count == 0 {
+		out.WriteString("(no rows)\n")
+	}
+	return out.String(), nil
+}
+
+// lookup finds a table or view by name, accepting "schema.name" or a bare name
+func (t *SchemaTools) lookup(name string) (string, string, []config.ColumnInfo, string, bool) {
+	if t.schema == nil {
+		return "", "", nil, "", false
+	}
+
+	name = strings.ReplaceAll(strings.TrimSpace(name), `"`, "")
+	schemaName := ""
+	if idx := strings.LastIndex(name, "."); idx >= 0 {
+		schemaName = name[:idx]
+		name = name[idx+1:]
+	}
+
+	for _, tbl := range t.schema.Tables {
+		if strings.EqualFold(tbl.Name, name) && (schemaName == "" || strings.EqualFold(tbl.Schema, schemaName)) {
+			return tbl.Schema, tbl.Name, tbl.Columns, tbl.Comment, true
+		}
+	}
+	for _, v := range t.schema.Views {
+		if strings.EqualFold(v.Name, name) && (schemaName == "" || strings.EqualFold(v.Schema, schemaName)) {
+			return v.Schema, v.Name, v.Columns, v.Comment, true
+		}
+	}
+	return "", "", nil, "", false
+}
+
+// hasGeometryColumn reports whether any column is a geometry
+func hasGeometryColumn(columns []config.ColumnInfo) bool {
+	for _, c := range columns {
+		if c.IsGeometry {
+			return true
+		}
+	}
+	return false
+}
+
+// quoteIdent double-quotes a SQL identifier
+func quoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// sampleValue formats a scanned value compactly for the model
+func sampleValue(v interface{}) string {
+	var s string
+	switch val := v.(type) {
+	case nil:
+		return "NULL"
+	case []byte:
+		s = string(val)
+	default:
+		s = fmt.Sprintf("%v", val)
+	}
+	if len(s) > 80 {
+		s = s[:77] + "..."
+	}
+	return s
+}
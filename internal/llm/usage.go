@@ -0,0 +1,184 @@
+package llm
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kartoza/kartoza-pg-ai/internal/config"
+	"github.com/kartoza/kartoza-pg-ai/internal/logging"
+)
+
+// defaultModelPrices are the published prices of hosted models in US
+// dollars per million tokens, by model name prefix. Settings.ModelPrices
+// replaces them as prices change.
+var defaultModelPrices = map[string]config.ModelPrice{
+	"gpt-4o-mini":       {Input: 0.15, Output: 0.60},
+	"gpt-4o":            {Input: 2.50, Output: 10.00},
+	"gpt-4.1-nano":      {Input: 0.10, Output: 0.40},
+	"gpt-4.1-mini":      {Input: 0.40, Output: 1.60},
+	"gpt-4.1":           {Input: 2.00, Output: 8.00},
+	"gpt-3.5-turbo":     {Input: 0.50, Output: 1.50},
+	"o3-mini":           {Input: 1.10, Output: 4.40},
+	"o4-mini":           {Input: 1.10, Output: 4.40},
+	"claude-3-haiku":    {Input: 0.25, Output: 1.25},
+	"claude-3-5-haiku":  {Input: 0.80, Output: 4.00},
+	"claude-haiku-4":    {Input: 1.00, Output: 5.00},
+	"claude-3-5-sonnet": {Input: 3.00, Output: 15.00},
+	"claude-3-7-sonnet": {Input: 3.00, Output: 15.00},
+	"claude-sonnet-4":   {Input: 3.00, Output: 15.00},
+	"claude-3-opus":     {Input: 15.00, Output: 75.00},
+	"claude-opus-4":     {Input: 15.00, Output: 75.00},
+	"claude-opus-4-5":   {Input: 5.00, Output: 25.00},
+}
+
+// Usage is what LLM provider requests used
+type Usage struct {
+	Requests     int
+	InputTokens  int     // Prompt tokens
+	OutputTokens int     // Completion tokens
+	Cost         float64 // Estimated, in US dollars; 0 for local and unpriced models
+}
+
+// Tokens returns the prompt and completion tokens together
+func (u Usage) Tokens() int {
+	return u.InputTokens + u.OutputTokens
+}
+
+// Add returns the usage of both u and other
+func (u Usage) Add(other Usage) Usage {
+	return Usage{
+		Requests:     u.Requests + other.Requests,
+		InputTokens:  u.InputTokens + other.InputTokens,
+		OutputTokens: u.OutputTokens + other.OutputTokens,
+		Cost:         u.Cost + other.Cost,
+	}
+}
+
+// UsageMeter adds up the provider requests made with the context it was
+// started in. It is safe for concurrent use.
+type UsageMeter struct {
+	parent *UsageMeter                           // Meter of the enclosing context, also counting these requests
+	prices map[string]config.ModelPrice          // Prices replacing the defaults for requests made within it
+	record func(provider, model string, u Usage) // Called for each request
+
+	mu    sync.Mutex
+	total Usage
+}
+
+// usageMeterKey is the context key of the innermost UsageMeter
+type usageMeterKey struct{}
+
+// MeterUsage starts counting the provider requests made with the returned
+// context. They are also counted by any meter already started in ctx.
+func MeterUsage(ctx context.Context) (context.Context, *UsageMeter) {
+	m := &UsageMeter{parent: usageMeterFrom(ctx)}
+	return context.WithValue(ctx, usageMeterKey{}, m), m
+}
+
+// usageMeterFrom returns the innermost meter started in ctx, or nil
+func usageMeterFrom(ctx context.Context) *UsageMeter {
+	m, _ := ctx.Value(usageMeterKey{}).(*UsageMeter)
+	return m
+}
+
+// Total returns what the requests counted so far used
+func (m *UsageMeter) Total() Usage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.total
+}
+
+// reportUsage counts a provider request in the meters started in ctx,
+// priced with the innermost prices set
+func reportUsage(ctx context.Context, provider, model string, inputTokens, outputTokens int) {
+	m := usageMeterFrom(ctx)
+	if m == nil || inputTokens+outputTokens == 0 {
+		return
+	}
+	var prices map[string]config.ModelPrice
+	for p := m; p != nil && prices == nil; p = p.parent {
+		prices = p.prices
+	}
+	u := Usage{
+		Requests:     1,
+		InputTokens:  inputTokens,
+		OutputTokens: outputTokens,
+		Cost:         EstimateCost(provider, model, inputTokens, outputTokens, prices),
+	}
+	for ; m != nil; m = m.parent {
+		m.mu.Lock()
+		m.total = m.total.Add(u)
+		m.mu.Unlock()
+		if m.record != nil {
+			m.record(provider, model, u)
+		}
+	}
+}
+
+// EstimateCost returns what a request to a model is estimated to cost in
+// US dollars. The price of the longest model name prefix is used, from
+// prices before the defaults; local models and unknown ones cost 0.
+func EstimateCost(provider, model string, inputTokens, outputTokens int, prices map[string]config.ModelPrice) float64 {
+	if provider == ProviderOllama || provider == ProviderLlamaCpp {
+		return 0
+	}
+	price, ok := modelPrice(model, prices)
+	if !ok {
+		price, ok = modelPrice(model, defaultModelPrices)
+	}
+	if !ok {
+		return 0
+	}
+	return (float64(inputTokens)*price.Input + float64(outputTokens)*price.Output) / 1e6
+}
+
+// modelPrice finds the price of the longest prefix of model in prices
+func modelPrice(model string, prices map[string]config.ModelPrice) (config.ModelPrice, bool) {
+	var best config.ModelPrice
+	bestLen := -1
+	for prefix, price := range prices {
+		if strings.HasPrefix(model, prefix) && len(prefix) > bestLen {
+			best, bestLen = price, len(prefix)
+		}
+	}
+	return best, bestLen >= 0
+}
+
+// SetUsageLog records every provider request the engine makes in the
+// history database under serviceName, priced with prices before the
+// defaults, for the usage report
+func (e *QueryEngine) SetUsageLog(serviceName string, prices map[string]config.ModelPrice) {
+	e.usageService = serviceName
+	e.prices = prices
+	e.logUsage = true
+}
+
+// metered returns ctx with a meter pricing the provider requests made with
+// it with the engine's prices and, after SetUsageLog, logging them
+func (e *QueryEngine) metered(ctx context.Context) context.Context {
+	ctx, m := MeterUsage(ctx)
+	m.prices = e.prices
+	if e.logUsage {
+		service := e.usageService
+		m.record = func(provider, model string, u Usage) {
+			store, err := config.History()
+			if err == nil {
+				err = store.AddUsage(config.UsageRecord{
+					Timestamp:    time.Now(),
+					ServiceName:  service,
+					Provider:     provider,
+					Model:        model,
+					InputTokens:  u.InputTokens,
+					OutputTokens: u.OutputTokens,
+					Cost:         u.Cost,
+				})
+			}
+			if err != nil {
+				logging.Warn("could not record provider usage", "provider", provider, "error", err)
+			}
+		}
+	}
+	return ctx
+}
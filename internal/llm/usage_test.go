@@ -0,0 +1,62 @@
+package llm
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kartoza/kartoza-pg-ai/internal/config"
+)
+
+func TestEstimateCost(t *testing.T) {
+	prices := map[string]config.ModelPrice{"gpt-4o-mini-2024": {Input: 1, Output: 2}}
+	tests := []struct {
+		provider, model string
+		want            float64
+	}{
+		{ProviderOpenAI, "gpt-4o-mini", 0.15 + 0.60},        // Longest default prefix
+		{ProviderOpenAI, "gpt-4o-2024-08-06", 2.50 + 10.00}, // Dated snapshot of a priced model
+		{ProviderOpenAI, "gpt-4o-mini-2024-07-18", 1 + 2},   // Settings replace the defaults
+		{ProviderClaude, "claude-sonnet-4-5", 3 + 15},
+		{ProviderOpenAI, "my-finetune", 0},
+		{ProviderOllama, "gpt-4o", 0}, // Local models are free
+	}
+	for _, tt := range tests {
+		got := EstimateCost(tt.provider, tt.model, 1_000_000, 1_000_000, prices)
+		if math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("EstimateCost(%s, %s) = %v, want %v", tt.provider, tt.model, got, tt.want)
+		}
+	}
+}
+
+func TestUsageMeterCountsProviderRequests(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"SELECT name FROM \"public\".\"users\""}}],` +
+			`"usage":{"prompt_tokens":1200,"completion_tokens":30}}`))
+	}))
+	defer server.Close()
+
+	engine := NewQueryEngine(&config.SchemaCache{Tables: []config.TableInfo{{Schema: "public", Name: "users"}}})
+	engine.SetProvider(NewOpenAIProvider("test-key", "gpt-4o", server.URL))
+
+	sessionCtx, session := MeterUsage(context.Background())
+	for i := 0; i < 2; i++ {
+		ctx, question := MeterUsage(sessionCtx)
+		if _, err := engine.GenerateSQLContext(ctx, "list users", ""); err != nil {
+			t.Fatalf("GenerateSQLContext failed: %v", err)
+		}
+		if got := question.Total(); got.Requests != 1 || got.InputTokens != 1200 || got.OutputTokens != 30 {
+			t.Errorf("question usage = %+v", got)
+		}
+	}
+
+	got := session.Total()
+	if got.Requests != 2 || got.Tokens() != 2460 {
+		t.Errorf("session usage = %+v, want 2 requests of 1230 tokens", got)
+	}
+	if want := 2 * (1200*2.50 + 30*10.00) / 1e6; math.Abs(got.Cost-want) > 1e-9 {
+		t.Errorf("session cost = %v, want %v", got.Cost, want)
+	}
+}
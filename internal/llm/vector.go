@@ -0,0 +1,155 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/kartoza/kartoza-pg-ai/internal/config"
+)
+
+// similarityPattern matches questions such as "find documents similar to
+// flood risk", "5 articles most like 'solar power'" or "products closest to
+// item 42": an optional count, the table, and the text or row to compare to
+var similarityPattern = regexp.MustCompile(`(?i)^(?:find|show|get|list|search for)?\s*(?:me\s+)?(?:the\s+)?(?:top\s+)?(\d+)?\s*(\w+)\s+(?:that are\s+)?(?:most\s+)?(?:similar to|closest to|nearest to|most like)\s+(.+?)[.?!]*$`)
+
+// rowReferencePattern matches a reference to a row by its key, such as
+// "document 42", "id 42" or "#42"
+var rowReferencePattern = regexp.MustCompile(`^(?:\w+\s+)?#?(\d+)$`)
+
+// NewSimilarityEmbedder returns the embedder that turns the text of
+// similarity questions into vectors: the configured embedder, or else one
+// on the LLM provider's server when the provider serves embeddings
+func NewSimilarityEmbedder(settings config.Settings) (Embedder, error) {
+	if embedder, err := NewEmbedderFromSettings(settings); embedder != nil || err != nil {
+		return embedder, err
+	}
+	switch strings.ToLower(settings.LLMProvider) {
+	case ProviderOllama, ProviderOpenAI:
+		settings.EmbeddingProvider = settings.LLMProvider
+		return NewEmbedderFromSettings(settings)
+	}
+	return nil, nil
+}
+
+// vectorColumn returns the first pgvector column of a table
+func vectorColumn(t config.TableInfo) (config.ColumnInfo, bool) {
+	for _, c := range t.Columns {
+		if c.IsVector {
+			return c, true
+		}
+	}
+	return config.ColumnInfo{}, false
+}
+
+// vectorOperator returns the distance operator matching the operator class
+// of the column's index, so the index can serve the ordering, falling back
+// to Euclidean distance
+func vectorOperator(t config.TableInfo, column string) string {
+	for _, idx := range t.Indexes {
+		if !slices.Contains(idx.Columns, column) {
+			continue
+		}
+		switch {
+		case strings.Contains(idx.Definition, "_cosine_ops"):
+			return "<=>"
+		case strings.Contains(idx.Definition, "_ip_ops"):
+			return "<#>"
+		case strings.Contains(idx.Definition, "_l1_ops"):
+			return "<+>"
+		}
+	}
+	return "<->"
+}
+
+// matchSimilarityQuery orders a table with a pgvector column by distance to
+// a row of the table or to the embedding of a text. Texts are embedded with
+// the engine's embedder, so without one only row references are handled.
+func (e *QueryEngine) matchSimilarityQuery(query string) string {
+	m := similarityPattern.FindStringSubmatch(strings.TrimSpace(query))
+	if m == nil {
+		return ""
+	}
+	table := e.findTable(m[2])
+	if table == nil {
+		return ""
+	}
+	column, ok := vectorColumn(*table)
+	if !ok {
+		return ""
+	}
+	limit := e.rowLimit()
+	if m[1] != "" {
+		limit, _ = strconv.Atoi(m[1])
+	}
+
+	qualified := quoteIdent(table.Schema) + "." + quoteIdent(table.Name)
+	target := strings.Trim(strings.TrimSpace(m[3]), `"'`)
+	var probe, where string
+	if ref := rowReferencePattern.FindStringSubmatch(target); ref != nil && primaryKey(*table) != "" {
+		pk := quoteIdent(primaryKey(*table))
+		probe = fmt.Sprintf("(SELECT %s FROM %s WHERE %s = %s)", quoteIdent(column.Name), qualified, pk, ref[1])
+		where = fmt.Sprintf(" WHERE %s <> %s", pk, ref[1])
+	} else {
+		vector := e.embedText(target)
+		// Sparse vectors have their own literal format
+		if vector == nil || column.DataType == "sparsevec" || (column.VectorDims > 0 && len(vector) != column.VectorDims) {
+			return ""
+		}
+		probe = fmt.Sprintf("'%s'::%s", vectorLiteral(vector), column.DataType)
+	}
+
+	var columns []string
+	for _, c := range table.Columns {
+		if !c.IsVector {
+			columns = append(columns, quoteIdent(c.Name))
+		}
+	}
+	if len(columns) == 0 {
+		columns = []string{"*"}
+	}
+	return fmt.Sprintf("SELECT %s, %s %s %s AS distance FROM %s%s ORDER BY distance LIMIT %d",
+		strings.Join(columns, ", "), quoteIdent(column.Name), vectorOperator(*table, column.Name), probe, qualified, where, limit)
+}
+
+// primaryKey returns the name of the table's first primary key column
+func primaryKey(t config.TableInfo) string {
+	for _, c := range t.Columns {
+		if c.IsPrimaryKey {
+			return c.Name
+		}
+	}
+	return ""
+}
+
+// embedText embeds a text with the engine's embedder, returning nil when
+// there is none or embedding fails
+func (e *QueryEngine) embedText(text string) []float32 {
+	if e.embedder == nil || text == "" {
+		return nil
+	}
+	ctx := e.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancel := context.WithTimeout(ctx, embeddingTimeout)
+	defer cancel()
+
+	vectors, err := e.embedder.Embed(ctx, []string{text})
+	if err != nil || len(vectors) != 1 {
+		return nil
+	}
+	return vectors[0]
+}
+
+// vectorLiteral formats a vector as a pgvector literal, e.g. "[0.1,0.2]"
+func vectorLiteral(v []float32) string {
+	parts := make([]string, len(v))
+	for i, x := range v {
+		parts[i] = strconv.FormatFloat(float64(x), 'g', -1, 32)
+	}
+	return "[" + strings.Join(parts, ",") + "]"
+}
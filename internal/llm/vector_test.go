@@ -0,0 +1,44 @@
+package llm
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/kartoza/kartoza-pg-ai/internal/config"
+)
+
+func TestSimilarityQueryOverVectorColumn(t *testing.T) {
+	schema := &config.SchemaCache{
+		Tables: []config.TableInfo{{
+			Schema: "public",
+			Name:   "documents",
+			Columns: []config.ColumnInfo{
+				{Name: "id", DataType: "integer", IsPrimaryKey: true},
+				{Name: "title", DataType: "text"},
+				{Name: "embedding", DataType: "vector", IsVector: true, VectorDims: 3},
+			},
+			Indexes: []config.IndexInfo{{
+				Name:       "documents_embedding_idx",
+				Columns:    []string{"embedding"},
+				Method:     "hnsw",
+				Definition: "CREATE INDEX documents_embedding_idx ON public.documents USING hnsw (embedding vector_cosine_ops)",
+			}},
+		}},
+	}
+	engine := NewQueryEngine(schema)
+	engine.useNN = false
+
+	// Rows can be compared without an embedder
+	sql, err := engine.GenerateSQL("find 5 documents similar to document 42", "")
+	want := `SELECT "id", "title", "embedding" <=> (SELECT "embedding" FROM "public"."documents" WHERE "id" = 42) AS distance FROM "public"."documents" WHERE "id" <> 42 ORDER BY distance LIMIT 5`
+	if err != nil || sql != want {
+		t.Errorf("row reference:\n got %q (%v)\nwant %q", sql, err, want)
+	}
+
+	// Texts are embedded with the configured embedder
+	engine.SetEmbedder(&conceptEmbedder{})
+	sql, err = engine.GenerateSQL(`find documents similar to "Clients"`, "")
+	if err != nil || !strings.Contains(sql, `"embedding" <=> '[1,0,0]'::vector AS distance`) {
+		t.Errorf("text similarity: %q (%v)", sql, err)
+	}
+}
@@ -0,0 +1,321 @@
+// Package logging writes the application log: leveled, structured JSON
+// lines in a file that is rotated as it grows.
+package logging
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FileName is the name of the current log file; rotated files add .1, .2, ...
+const FileName = "kartoza-pg-ai.log"
+
+// Rotation limits
+const (
+	MaxFileSize = 5 << 20 // Bytes written before the file is rotated
+	MaxBackups  = 3       // Rotated files kept
+)
+
+var (
+	mu     sync.Mutex
+	level  = new(slog.LevelVar) // Info by default
+	logger = slog.New(slog.NewJSONHandler(io.Discard, nil))
+	file   *rotatingFile
+)
+
+// Open starts logging to FileName in dir, creating dir if needed. Until it
+// is called, log calls are discarded.
+func Open(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	f, err := openRotating(filepath.Join(dir, FileName), MaxFileSize, MaxBackups)
+	if err != nil {
+		return err
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if file != nil {
+		file.Close()
+	}
+	file = f
+	logger = slog.New(slog.NewJSONHandler(f, &slog.HandlerOptions{Level: level}))
+	return nil
+}
+
+// Close stops logging to the file
+func Close() error {
+	mu.Lock()
+	defer mu.Unlock()
+	logger = slog.New(slog.NewJSONHandler(io.Discard, nil))
+	if file == nil {
+		return nil
+	}
+	err := file.Close()
+	file = nil
+	return err
+}
+
+// Path returns the file being logged to, or "" when logging is not open
+func Path() string {
+	mu.Lock()
+	defer mu.Unlock()
+	if file == nil {
+		return ""
+	}
+	return file.path
+}
+
+// Levels lists the level names ParseLevel understands, most verbose first
+var Levels = []string{"debug", "info", "warn", "error"}
+
+// ParseLevel returns the level of a name in Levels, defaulting to info
+func ParseLevel(name string) slog.Level {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(name)); err != nil {
+		return slog.LevelInfo
+	}
+	return l
+}
+
+// SetLevel sets the least severe level that is logged
+func SetLevel(l slog.Level) {
+	level.Set(l)
+}
+
+// Logger returns the current logger
+func Logger() *slog.Logger {
+	mu.Lock()
+	defer mu.Unlock()
+	return logger
+}
+
+// Debug logs at debug level; args are alternating keys and values
+func Debug(msg string, args ...any) { Logger().Debug(msg, args...) }
+
+// Info logs at info level
+func Info(msg string, args ...any) { Logger().Info(msg, args...) }
+
+// Warn logs at warn level
+func Warn(msg string, args ...any) { Logger().Warn(msg, args...) }
+
+// Error logs at error level
+func Error(msg string, args ...any) { Logger().Error(msg, args...) }
+
+// rotatingFile is a log file that is renamed to path.1 once it reaches
+// maxSize, shifting older files up and dropping the oldest
+type rotatingFile struct {
+	mu      sync.Mutex
+	path    string
+	maxSize int64
+	backups int
+	f       *os.File
+	size    int64
+}
+
+// openRotating opens path for appending
+func openRotating(path string, maxSize int64, backups int) (*rotatingFile, error) {
+	r := &rotatingFile{path: path, maxSize: maxSize, backups: backups}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *rotatingFile) open() error {
+	f, err := os.OpenFile(r.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.f = f
+	r.size = info.Size()
+	return nil
+}
+
+// Write appends p, rotating first when it would take the file past maxSize.
+// The handler writes one record per call, so records are never split.
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.f == nil {
+		return 0, os.ErrClosed
+	}
+	if r.size > 0 && r.size+int64(len(p)) > r.maxSize {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.f.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// rotate shifts path.N-1 to path.N, ..., path to path.1 and starts afresh
+func (r *rotatingFile) rotate() error {
+	if err := r.f.Close(); err != nil {
+		return err
+	}
+	r.f = nil
+	os.Remove(backupPath(r.path, r.backups))
+	for i := r.backups - 1; i >= 1; i-- {
+		os.Rename(backupPath(r.path, i), backupPath(r.path, i+1))
+	}
+	if r.backups > 0 {
+		if err := os.Rename(r.path, backupPath(r.path, 1)); err != nil {
+			return err
+		}
+	} else if err := os.Remove(r.path); err != nil {
+		return err
+	}
+	return r.open()
+}
+
+// Close closes the file
+func (r *rotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.f == nil {
+		return nil
+	}
+	err := r.f.Close()
+	r.f = nil
+	return err
+}
+
+// backupPath returns the name of the nth rotated file
+func backupPath(path string, n int) string {
+	return fmt.Sprintf("%s.%d", path, n)
+}
+
+// Attr is a key and value of a log entry, the value as written
+type Attr struct {
+	Key   string
+	Value string
+}
+
+// Entry is one record read back from the log
+type Entry struct {
+	Time    time.Time
+	Level   slog.Level
+	Message string
+	Attrs   []Attr // In the order they were logged
+}
+
+// ReadEntries returns up to the last limit entries of the log at path,
+// oldest first, reading on into its rotated files when path alone holds
+// fewer. Lines that are not log records are skipped, and a log not yet
+// written reads as empty.
+func ReadEntries(path string, limit int) ([]Entry, error) {
+	var entries []Entry
+	for n := 0; n <= MaxBackups && len(entries) < limit; n++ {
+		name := path
+		if n > 0 {
+			name = backupPath(path, n)
+		}
+		older, err := readFile(name)
+		if err != nil {
+			if os.IsNotExist(err) {
+				break
+			}
+			return nil, err
+		}
+		entries = append(older, entries...)
+	}
+	if len(entries) > limit {
+		entries = entries[len(entries)-limit:]
+	}
+	return entries, nil
+}
+
+// readFile parses every record of one log file
+func readFile(name string) ([]Entry, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 4<<20)
+	for scanner.Scan() {
+		if entry, ok := ParseEntry(scanner.Bytes()); ok {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, scanner.Err()
+}
+
+// ParseEntry parses a JSON log line, keeping the order of its attributes
+func ParseEntry(line []byte) (Entry, bool) {
+	dec := json.NewDecoder(bytes.NewReader(line))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return Entry{}, false
+	}
+
+	var entry Entry
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return Entry{}, false
+		}
+		key, _ := tok.(string)
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return Entry{}, false
+		}
+		value := string(raw)
+		var s string
+		if json.Unmarshal(raw, &s) == nil {
+			value = s
+		}
+
+		switch key {
+		case slog.TimeKey:
+			entry.Time, _ = time.Parse(time.RFC3339Nano, value)
+		case slog.LevelKey:
+			if entry.Level.UnmarshalText([]byte(value)) != nil {
+				return Entry{}, false
+			}
+		case slog.MessageKey:
+			entry.Message = value
+		default:
+			entry.Attrs = append(entry.Attrs, Attr{Key: key, Value: value})
+		}
+	}
+	if entry.Time.IsZero() && entry.Message == "" {
+		return Entry{}, false
+	}
+	return entry, true
+}
+
+// Line renders an entry as a single line of text
+func (e Entry) Line() string {
+	var b strings.Builder
+	b.WriteString(e.Message)
+	for _, a := range e.Attrs {
+		b.WriteString(" " + a.Key + "=")
+		if strings.ContainsAny(a.Value, " \t\n\"") {
+			b.WriteString(strconv.Quote(a.Value))
+		} else {
+			b.WriteString(a.Value)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,123 @@
+package logging
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLogAndReadEntries(t *testing.T) {
+	dir := t.TempDir()
+	if err := Open(dir); err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+	defer Close()
+	defer SetLevel(slog.LevelInfo)
+
+	SetLevel(slog.LevelInfo)
+	Debug("hidden")
+	Info("sql executed", "service", "prod", "sql", "SELECT 1", "rows", 1)
+	SetLevel(slog.LevelDebug)
+	Debug("shown")
+	Error("sql failed", "error", `relation "x" does not exist`)
+
+	entries, err := ReadEntries(Path(), 10)
+	if err != nil {
+		t.Fatalf("ReadEntries error: %v", err)
+	}
+	var messages []string
+	for _, e := range entries {
+		messages = append(messages, e.Message)
+	}
+	if got := strings.Join(messages, ","); got != "sql executed,shown,sql failed" {
+		t.Fatalf("messages = %q", got)
+	}
+
+	first := entries[0]
+	if first.Level != slog.LevelInfo || first.Time.IsZero() {
+		t.Errorf("first entry = %+v", first)
+	}
+	want := []Attr{{"service", "prod"}, {"sql", "SELECT 1"}, {"rows", "1"}}
+	if len(first.Attrs) != len(want) {
+		t.Fatalf("attrs = %+v, want %+v", first.Attrs, want)
+	}
+	for i := range want {
+		if first.Attrs[i] != want[i] {
+			t.Errorf("attr %d = %+v, want %+v", i, first.Attrs[i], want[i])
+		}
+	}
+	if got := entries[2].Line(); got != `sql failed error="relation \"x\" does not exist"` {
+		t.Errorf("Line() = %q", got)
+	}
+
+	if entries, err := ReadEntries(Path(), 2); err != nil || len(entries) != 2 || entries[1].Message != "sql failed" {
+		t.Errorf("ReadEntries limit 2 = %+v, %v", entries, err)
+	}
+}
+
+func TestRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), FileName)
+	r, err := openRotating(path, 100, 2)
+	if err != nil {
+		t.Fatalf("openRotating error: %v", err)
+	}
+	defer r.Close()
+
+	line := `{"time":"2024-01-02T03:04:05Z","level":"INFO","msg":"record","n":"` + strings.Repeat("x", 20) + "\"}\n"
+	for i := 0; i < 10; i++ {
+		if _, err := r.Write([]byte(line)); err != nil {
+			t.Fatalf("Write error: %v", err)
+		}
+	}
+
+	for _, name := range []string{path, path + ".1", path + ".2"} {
+		info, err := os.Stat(name)
+		if err != nil {
+			t.Fatalf("stat %s: %v", name, err)
+		}
+		if info.Size() > 100 {
+			t.Errorf("%s is %d bytes, over the limit", name, info.Size())
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("expected only 2 backups, stat .3: %v", err)
+	}
+
+	// Each file holds one record of 80-odd bytes; reading continues into
+	// the backups for older ones
+	entries, err := ReadEntries(path, 10)
+	if err != nil {
+		t.Fatalf("ReadEntries error: %v", err)
+	}
+	if len(entries) != 3 {
+		t.Errorf("read %d entries across rotated files, want 3", len(entries))
+	}
+}
+
+func TestParseEntry(t *testing.T) {
+	tests := []struct {
+		line string
+		ok   bool
+	}{
+		{`{"time":"2024-01-02T03:04:05.123Z","level":"WARN","msg":"slow query","ms":1500.5}`, true},
+		{`{"time":"2024-01-02T03:04:05Z","level":"LOUD","msg":"x"}`, false},
+		{`not json`, false},
+		{`{}`, false},
+	}
+	for _, tt := range tests {
+		entry, ok := ParseEntry([]byte(tt.line))
+		if ok != tt.ok {
+			t.Errorf("ParseEntry(%s) ok = %v, want %v", tt.line, ok, tt.ok)
+			continue
+		}
+		if ok && (entry.Level != slog.LevelWarn || entry.Attrs[0] != (Attr{"ms", "1500.5"})) {
+			t.Errorf("ParseEntry(%s) = %+v", tt.line, entry)
+		}
+	}
+
+	if entries, err := ReadEntries(filepath.Join(t.TempDir(), FileName), 10); err != nil || len(entries) != 0 {
+		t.Errorf("ReadEntries of a missing log = %v, %v", entries, err)
+	}
+}
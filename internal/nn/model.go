@@ -1,7 +1,9 @@
 package nn
 
 import (
+	"context"
 	"encoding/gob"
+	"errors"
 	"fmt"
 	"math"
 	"math/rand"
@@ -11,48 +13,36 @@ import (
 	"gorgonia.org/tensor"
 )
 
-// Seq2SeqModel represents a sequence-to-sequence neural network for NL to SQL
+// Seq2SeqModel represents a sequence-to-sequence neural network for NL to SQL.
+// An RNN encoder reads the question and its final hidden state starts an RNN
+// decoder that emits the SQL one token at a time.
 type Seq2SeqModel struct {
-	g            *gorgonia.ExprGraph
-	vm           gorgonia.VM
-
 	// Model parameters
 	vocabSize    int
 	embeddingDim int
 	hiddenDim    int
 	maxSeqLen    int
+	learningRate float64
 
-	// Encoder weights
-	encoderEmbed *gorgonia.Node // Embedding matrix
-	encoderWih   *gorgonia.Node // Input to hidden weights
-	encoderBih   *gorgonia.Node // Input to hidden bias
-	encoderWhh   *gorgonia.Node // Hidden to hidden weights
-	encoderBhh   *gorgonia.Node // Hidden to hidden bias
+	// Encoder weights, kept across the graphs built for each example
+	encoderEmbed *tensor.Dense // Embedding matrix (vocab x embedding)
+	encoderWih   *tensor.Dense // Input to hidden weights
+	encoderBih   *tensor.Dense // Input to hidden bias
+	encoderWhh   *tensor.Dense // Hidden to hidden weights
+	encoderBhh   *tensor.Dense // Hidden to hidden bias
 
 	// Decoder weights
-	decoderEmbed *gorgonia.Node // Embedding matrix (shared or separate)
-	decoderWih   *gorgonia.Node // Input to hidden weights
-	decoderBih   *gorgonia.Node // Input to hidden bias
-	decoderWhh   *gorgonia.Node // Hidden to hidden weights
-	decoderBhh   *gorgonia.Node // Hidden to hidden bias
-	decoderWho   *gorgonia.Node // Hidden to output weights
-	decoderBho   *gorgonia.Node // Hidden to output bias
-
-	// Attention weights
-	attnW        *gorgonia.Node // Attention weight matrix
-
-	// Learnables (for gradient descent)
-	learnables   gorgonia.Nodes
-
-	// Input/output nodes
-	input        *gorgonia.Node
-	target       *gorgonia.Node
-	output       *gorgonia.Node
-	loss         *gorgonia.Node
+	decoderEmbed *tensor.Dense // Embedding matrix (vocab x embedding)
+	decoderWih   *tensor.Dense // Input to hidden weights
+	decoderBih   *tensor.Dense // Input to hidden bias
+	decoderWhh   *tensor.Dense // Hidden to hidden weights
+	decoderBhh   *tensor.Dense // Hidden to hidden bias
+	decoderWho   *tensor.Dense // Hidden to output weights (vocab x hidden)
+	decoderBho   *tensor.Dense // Hidden to output bias
 
 	// Training state
-	solver       gorgonia.Solver
-	trained      bool
+	solver  gorgonia.Solver
+	trained bool
 }
 
 // ModelConfig holds configuration for the model
@@ -77,23 +67,25 @@ func DefaultModelConfig() ModelConfig {
 
 // NewSeq2SeqModel creates a new sequence-to-sequence model
 func NewSeq2SeqModel(config ModelConfig) *Seq2SeqModel {
-	g := gorgonia.NewGraph()
-
 	m := &Seq2SeqModel{
-		g:            g,
 		vocabSize:    config.VocabSize,
 		embeddingDim: config.EmbeddingDim,
 		hiddenDim:    config.HiddenDim,
 		maxSeqLen:    config.MaxSeqLen,
+		learningRate: config.LearningRate,
 	}
+	m.Reset(config.VocabSize)
+	return m
+}
 
-	// Initialize weights with Xavier initialization
+// Reset reinitializes the weights for a vocabulary of vocabSize tokens,
+// discarding anything learned
+func (m *Seq2SeqModel) Reset(vocabSize int) {
+	m.vocabSize = vocabSize
 	m.initWeights()
-
-	// Create solver (Adam optimizer)
-	m.solver = gorgonia.NewAdamSolver(gorgonia.WithLearnRate(config.LearningRate))
-
-	return m
+	// Adam keeps per-weight state, so it starts over with the weights
+	m.solver = gorgonia.NewAdamSolver(gorgonia.WithLearnRate(m.learningRate), gorgonia.WithClip(5))
+	m.trained = false
 }
 
 // initWeights initializes all model weights
@@ -103,77 +95,47 @@ func (m *Seq2SeqModel) initWeights() {
 	rnnScale := math.Sqrt(2.0 / float64(m.embeddingDim+m.hiddenDim))
 	outScale := math.Sqrt(2.0 / float64(m.hiddenDim+m.vocabSize))
 
-	// Encoder embedding
-	encoderEmbedT := tensor.New(
-		tensor.WithShape(m.vocabSize, m.embeddingDim),
-		tensor.WithBacking(randomFloat64(m.vocabSize*m.embeddingDim, embedScale)),
-	)
-	m.encoderEmbed = gorgonia.NewMatrix(m.g, tensor.Float64,
-		gorgonia.WithShape(m.vocabSize, m.embeddingDim),
-		gorgonia.WithName("encoder_embed"),
-		gorgonia.WithValue(encoderEmbedT),
-	)
-
-	// Encoder RNN weights
-	m.encoderWih = m.newWeight("encoder_wih", m.embeddingDim, m.hiddenDim, rnnScale)
-	m.encoderBih = m.newBias("encoder_bih", m.hiddenDim)
-	m.encoderWhh = m.newWeight("encoder_whh", m.hiddenDim, m.hiddenDim, rnnScale)
-	m.encoderBhh = m.newBias("encoder_bhh", m.hiddenDim)
+	// Encoder
+	m.encoderEmbed = newWeight(m.vocabSize, m.embeddingDim, embedScale)
+	m.encoderWih = newWeight(m.hiddenDim, m.embeddingDim, rnnScale)
+	m.encoderBih = newBias(m.hiddenDim)
+	m.encoderWhh = newWeight(m.hiddenDim, m.hiddenDim, rnnScale)
+	m.encoderBhh = newBias(m.hiddenDim)
 
-	// Decoder embedding (can share with encoder)
-	decoderEmbedT := tensor.New(
-		tensor.WithShape(m.vocabSize, m.embeddingDim),
-		tensor.WithBacking(randomFloat64(m.vocabSize*m.embeddingDim, embedScale)),
-	)
-	m.decoderEmbed = gorgonia.NewMatrix(m.g, tensor.Float64,
-		gorgonia.WithShape(m.vocabSize, m.embeddingDim),
-		gorgonia.WithName("decoder_embed"),
-		gorgonia.WithValue(decoderEmbedT),
-	)
-
-	// Decoder RNN weights
-	m.decoderWih = m.newWeight("decoder_wih", m.embeddingDim, m.hiddenDim, rnnScale)
-	m.decoderBih = m.newBias("decoder_bih", m.hiddenDim)
-	m.decoderWhh = m.newWeight("decoder_whh", m.hiddenDim, m.hiddenDim, rnnScale)
-	m.decoderBhh = m.newBias("decoder_bhh", m.hiddenDim)
+	// Decoder
+	m.decoderEmbed = newWeight(m.vocabSize, m.embeddingDim, embedScale)
+	m.decoderWih = newWeight(m.hiddenDim, m.embeddingDim, rnnScale)
+	m.decoderBih = newBias(m.hiddenDim)
+	m.decoderWhh = newWeight(m.hiddenDim, m.hiddenDim, rnnScale)
+	m.decoderBhh = newBias(m.hiddenDim)
 
 	// Output projection
-	m.decoderWho = m.newWeight("decoder_who", m.hiddenDim, m.vocabSize, outScale)
-	m.decoderBho = m.newBias("decoder_bho", m.vocabSize)
-
-	// Attention weights
-	m.attnW = m.newWeight("attn_w", m.hiddenDim, m.hiddenDim, rnnScale)
+	m.decoderWho = newWeight(m.vocabSize, m.hiddenDim, outScale)
+	m.decoderBho = newBias(m.vocabSize)
+}
 
-	// Collect learnables
-	m.learnables = gorgonia.Nodes{
+// weights returns the weights in a fixed order, which the solver relies on
+// to match its state to each weight
+func (m *Seq2SeqModel) weights() []*tensor.Dense {
+	return []*tensor.Dense{
 		m.encoderEmbed, m.encoderWih, m.encoderBih, m.encoderWhh, m.encoderBhh,
 		m.decoderEmbed, m.decoderWih, m.decoderBih, m.decoderWhh, m.decoderBhh,
-		m.decoderWho, m.decoderBho, m.attnW,
+		m.decoderWho, m.decoderBho,
 	}
 }
 
-func (m *Seq2SeqModel) newWeight(name string, rows, cols int, scale float64) *gorgonia.Node {
-	t := tensor.New(
+func newWeight(rows, cols int, scale float64) *tensor.Dense {
+	return tensor.New(
 		tensor.WithShape(rows, cols),
 		tensor.WithBacking(randomFloat64(rows*cols, scale)),
 	)
-	return gorgonia.NewMatrix(m.g, tensor.Float64,
-		gorgonia.WithShape(rows, cols),
-		gorgonia.WithName(name),
-		gorgonia.WithValue(t),
-	)
 }
 
-func (m *Seq2SeqModel) newBias(name string, size int) *gorgonia.Node {
-	t := tensor.New(
+func newBias(size int) *tensor.Dense {
+	return tensor.New(
 		tensor.WithShape(size),
 		tensor.WithBacking(make([]float64, size)),
 	)
-	return gorgonia.NewVector(m.g, tensor.Float64,
-		gorgonia.WithShape(size),
-		gorgonia.WithName(name),
-		gorgonia.WithValue(t),
-	)
 }
 
 func randomFloat64(size int, scale float64) []float64 {
@@ -184,344 +146,161 @@ func randomFloat64(size int, scale float64) []float64 {
 	return result
 }
 
-// Forward performs a forward pass through the network
-func (m *Seq2SeqModel) Forward(inputSeq, targetSeq []int) (*gorgonia.Node, error) {
-	// Rebuild graph for this forward pass
-	m.g = gorgonia.NewGraph()
-	m.initWeights()
-
-	batchSize := 1
-
-	// Create input tensor (one-hot or index-based)
-	inputT := tensor.New(
-		tensor.WithShape(batchSize, m.maxSeqLen),
-		tensor.WithBacking(toFloat64(inputSeq)),
-	)
-	m.input = gorgonia.NewMatrix(m.g, tensor.Float64,
-		gorgonia.WithShape(batchSize, m.maxSeqLen),
-		gorgonia.WithName("input"),
-		gorgonia.WithValue(inputT),
-	)
+// exampleGraph is the computation graph of the loss of one example
+type exampleGraph struct {
+	g          *gorgonia.ExprGraph
+	learnables gorgonia.Nodes
+	loss       *gorgonia.Node
+	steps      int // Predicted target tokens
+}
 
-	// Encode
-	encoderOutputs, encoderHidden, err := m.encode(m.input)
-	if err != nil {
-		return nil, fmt.Errorf("encoding failed: %w", err)
+// buildGraph builds the graph computing the summed negative log likelihood
+// of the target tokens, feeding the decoder the true previous token
+func (m *Seq2SeqModel) buildGraph(inputSeq, targetSeq []int) (*exampleGraph, error) {
+	inputSeq, targetSeq = trimPadding(inputSeq), trimPadding(targetSeq)
+	if len(inputSeq) == 0 || len(targetSeq) < 2 {
+		return nil, fmt.Errorf("empty example")
 	}
 
-	// Decode with attention
-	decoderOutput, err := m.decode(encoderOutputs, encoderHidden, targetSeq)
-	if err != nil {
-		return nil, fmt.Errorf("decoding failed: %w", err)
+	g := gorgonia.NewGraph()
+	nodes := make(gorgonia.Nodes, 0, len(m.weights()))
+	node := func(w *tensor.Dense, name string) *gorgonia.Node {
+		var n *gorgonia.Node
+		if len(w.Shape()) == 1 {
+			n = gorgonia.NewVector(g, tensor.Float64, gorgonia.WithShape(w.Shape()...), gorgonia.WithName(name), gorgonia.WithValue(w))
+		} else {
+			n = gorgonia.NewMatrix(g, tensor.Float64, gorgonia.WithShape(w.Shape()...), gorgonia.WithName(name), gorgonia.WithValue(w))
+		}
+		nodes = append(nodes, n)
+		return n
 	}
+	encEmbed, encWih, encBih, encWhh, encBhh := node(m.encoderEmbed, "encoder_embed"), node(m.encoderWih, "encoder_wih"),
+		node(m.encoderBih, "encoder_bih"), node(m.encoderWhh, "encoder_whh"), node(m.encoderBhh, "encoder_bhh")
+	decEmbed, decWih, decBih, decWhh, decBhh := node(m.decoderEmbed, "decoder_embed"), node(m.decoderWih, "decoder_wih"),
+		node(m.decoderBih, "decoder_bih"), node(m.decoderWhh, "decoder_whh"), node(m.decoderBhh, "decoder_bhh")
+	who, bho := node(m.decoderWho, "decoder_who"), node(m.decoderBho, "decoder_bho")
 
-	m.output = decoderOutput
-	return decoderOutput, nil
-}
-
-// encode runs the encoder RNN
-func (m *Seq2SeqModel) encode(input *gorgonia.Node) ([]*gorgonia.Node, *gorgonia.Node, error) {
-	var outputs []*gorgonia.Node
-
-	// Initialize hidden state
-	hiddenT := tensor.New(
-		tensor.WithShape(m.hiddenDim),
-		tensor.WithBacking(make([]float64, m.hiddenDim)),
-	)
-	hidden := gorgonia.NewVector(m.g, tensor.Float64,
-		gorgonia.WithShape(m.hiddenDim),
-		gorgonia.WithName("encoder_h0"),
-		gorgonia.WithValue(hiddenT),
-	)
-
-	// Process each timestep
-	for t := 0; t < m.maxSeqLen; t++ {
-		// Get embedding for this timestep
-		// For simplicity, we'll use a simple lookup
-		embedded, err := m.lookupEmbedding(m.encoderEmbed, input, t)
+	// Encode
+	hidden := gorgonia.NewVector(g, tensor.Float64, gorgonia.WithShape(m.hiddenDim), gorgonia.WithName("h0"), gorgonia.WithInit(gorgonia.Zeroes()))
+	for t, token := range inputSeq {
+		embedded, err := gorgonia.Slice(encEmbed, gorgonia.S(token))
 		if err != nil {
-			return nil, nil, err
+			return nil, fmt.Errorf("encoder embedding: %w", err)
 		}
-
-		// RNN cell: h_t = tanh(W_ih * x_t + b_ih + W_hh * h_{t-1} + b_hh)
-		hidden, err = m.rnnCell(embedded, hidden,
-			m.encoderWih, m.encoderBih, m.encoderWhh, m.encoderBhh,
-			fmt.Sprintf("enc_%d", t))
-		if err != nil {
-			return nil, nil, err
+		if hidden, err = rnnCell(embedded, hidden, encWih, encBih, encWhh, encBhh); err != nil {
+			return nil, fmt.Errorf("encoder step %d: %w", t, err)
 		}
-
-		outputs = append(outputs, hidden)
 	}
 
-	return outputs, hidden, nil
-}
-
-// decode runs the decoder RNN with attention
-func (m *Seq2SeqModel) decode(encoderOutputs []*gorgonia.Node, encoderHidden *gorgonia.Node, targetSeq []int) (*gorgonia.Node, error) {
-	hidden := encoderHidden
-	var outputLogits []*gorgonia.Node
-
-	// Start token
-	prevToken := 2 // START token index
-
-	for t := 0; t < m.maxSeqLen && t < len(targetSeq); t++ {
-		// Get embedding for previous token
-		prevTokenT := tensor.New(
-			tensor.WithShape(1),
-			tensor.WithBacking([]float64{float64(prevToken)}),
-		)
-		prevTokenNode := gorgonia.NewVector(m.g, tensor.Float64,
-			gorgonia.WithShape(1),
-			gorgonia.WithName(fmt.Sprintf("prev_token_%d", t)),
-			gorgonia.WithValue(prevTokenT),
-		)
-
-		embedded, err := m.lookupEmbeddingDirect(m.decoderEmbed, prevTokenNode)
+	// Decode with teacher forcing
+	var logProbs gorgonia.Nodes
+	for t := 0; t+1 < len(targetSeq); t++ {
+		embedded, err := gorgonia.Slice(decEmbed, gorgonia.S(targetSeq[t]))
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("decoder embedding: %w", err)
 		}
-
-		// Compute attention context
-		context, err := m.attention(hidden, encoderOutputs, t)
-		if err != nil {
-			return nil, err
+		if hidden, err = rnnCell(embedded, hidden, decWih, decBih, decWhh, decBhh); err != nil {
+			return nil, fmt.Errorf("decoder step %d: %w", t, err)
 		}
-
-		// Combine embedding and context
-		combined, err := gorgonia.Add(embedded, context)
+		logits, err := gorgonia.Mul(who, hidden)
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("projection: %w", err)
 		}
-
-		// RNN cell
-		hidden, err = m.rnnCell(combined, hidden,
-			m.decoderWih, m.decoderBih, m.decoderWhh, m.decoderBhh,
-			fmt.Sprintf("dec_%d", t))
-		if err != nil {
-			return nil, err
+		if logits, err = gorgonia.Add(logits, bho); err != nil {
+			return nil, fmt.Errorf("projection bias: %w", err)
 		}
-
-		// Project to vocabulary
-		logits, err := m.project(hidden, t)
+		logProb, err := gorgonia.LogSoftMax(logits)
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("log softmax: %w", err)
 		}
-
-		outputLogits = append(outputLogits, logits)
-
-		// Teacher forcing: use actual target as next input during training
-		if t < len(targetSeq)-1 {
-			prevToken = targetSeq[t+1]
+		next, err := gorgonia.Slice(logProb, gorgonia.S(targetSeq[t+1]))
+		if err != nil {
+			return nil, fmt.Errorf("target log probability: %w", err)
 		}
+		logProbs = append(logProbs, next)
 	}
 
-	// Stack outputs
-	if len(outputLogits) == 0 {
-		return nil, fmt.Errorf("no output logits generated")
-	}
-
-	return outputLogits[0], nil // Return first for now; proper impl would stack all
-}
-
-// lookupEmbedding performs embedding lookup
-func (m *Seq2SeqModel) lookupEmbedding(embedMatrix, input *gorgonia.Node, timestep int) (*gorgonia.Node, error) {
-	// Simplified: return a slice of the embedding matrix
-	// In a proper implementation, this would index into the matrix
-	sliced, err := gorgonia.Slice(embedMatrix, gorgonia.S(0)) // Get first row as placeholder
+	total, err := gorgonia.ReduceAdd(logProbs)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("loss sum: %w", err)
 	}
-	return sliced, nil
-}
-
-// lookupEmbeddingDirect performs direct embedding lookup
-func (m *Seq2SeqModel) lookupEmbeddingDirect(embedMatrix, tokenIdx *gorgonia.Node) (*gorgonia.Node, error) {
-	// Simplified embedding lookup
-	sliced, err := gorgonia.Slice(embedMatrix, gorgonia.S(0))
+	loss, err := gorgonia.Neg(total)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("loss: %w", err)
 	}
-	return sliced, nil
+	return &exampleGraph{g: g, learnables: nodes, loss: loss, steps: len(logProbs)}, nil
 }
 
-// rnnCell performs one step of RNN computation
-func (m *Seq2SeqModel) rnnCell(input, hidden, wih, bih, whh, bhh *gorgonia.Node, name string) (*gorgonia.Node, error) {
-	// h_t = tanh(W_ih * x_t + b_ih + W_hh * h_{t-1} + b_hh)
-
-	// W_ih * x_t
-	ih, err := gorgonia.Mul(input, wih)
-	if err != nil {
-		return nil, fmt.Errorf("rnn %s ih mul: %w", name, err)
-	}
-
-	// + b_ih
-	ih, err = gorgonia.BroadcastAdd(ih, bih, nil, []byte{0})
-	if err != nil {
-		return nil, fmt.Errorf("rnn %s ih bias: %w", name, err)
-	}
-
-	// W_hh * h_{t-1}
-	hh, err := gorgonia.Mul(hidden, whh)
+// rnnCell computes h_t = tanh(W_ih * x_t + b_ih + W_hh * h_{t-1} + b_hh)
+func rnnCell(input, hidden, wih, bih, whh, bhh *gorgonia.Node) (*gorgonia.Node, error) {
+	ih, err := gorgonia.Mul(wih, input)
 	if err != nil {
-		return nil, fmt.Errorf("rnn %s hh mul: %w", name, err)
+		return nil, err
 	}
-
-	// + b_hh
-	hh, err = gorgonia.BroadcastAdd(hh, bhh, nil, []byte{0})
+	hh, err := gorgonia.Mul(whh, hidden)
 	if err != nil {
-		return nil, fmt.Errorf("rnn %s hh bias: %w", name, err)
+		return nil, err
 	}
-
-	// sum
 	sum, err := gorgonia.Add(ih, hh)
-	if err != nil {
-		return nil, fmt.Errorf("rnn %s sum: %w", name, err)
-	}
-
-	// tanh activation
-	newHidden, err := gorgonia.Tanh(sum)
-	if err != nil {
-		return nil, fmt.Errorf("rnn %s tanh: %w", name, err)
-	}
-
-	return newHidden, nil
-}
-
-// attention computes attention over encoder outputs
-func (m *Seq2SeqModel) attention(decoderHidden *gorgonia.Node, encoderOutputs []*gorgonia.Node, timestep int) (*gorgonia.Node, error) {
-	if len(encoderOutputs) == 0 {
-		// Return zeros if no encoder outputs
-		zeroT := tensor.New(
-			tensor.WithShape(m.hiddenDim),
-			tensor.WithBacking(make([]float64, m.hiddenDim)),
-		)
-		return gorgonia.NewVector(m.g, tensor.Float64,
-			gorgonia.WithShape(m.hiddenDim),
-			gorgonia.WithName(fmt.Sprintf("attn_ctx_%d", timestep)),
-			gorgonia.WithValue(zeroT),
-		), nil
-	}
-
-	// Simplified attention: just use the last encoder output
-	// A proper implementation would compute attention scores
-	return encoderOutputs[len(encoderOutputs)-1], nil
-}
-
-// project projects hidden state to vocabulary
-func (m *Seq2SeqModel) project(hidden *gorgonia.Node, timestep int) (*gorgonia.Node, error) {
-	// hidden * W_ho + b_ho
-	projected, err := gorgonia.Mul(hidden, m.decoderWho)
 	if err != nil {
 		return nil, err
 	}
-
-	projected, err = gorgonia.BroadcastAdd(projected, m.decoderBho, nil, []byte{0})
-	if err != nil {
+	if sum, err = gorgonia.Add(sum, bih); err != nil {
 		return nil, err
 	}
-
-	// Softmax for probabilities
-	output, err := gorgonia.SoftMax(projected)
-	if err != nil {
+	if sum, err = gorgonia.Add(sum, bhh); err != nil {
 		return nil, err
 	}
-
-	return output, nil
+	return gorgonia.Tanh(sum)
 }
 
-// ComputeLoss computes cross-entropy loss
-func (m *Seq2SeqModel) ComputeLoss(output *gorgonia.Node, target []int) (*gorgonia.Node, error) {
-	// Create target tensor
-	targetT := tensor.New(
-		tensor.WithShape(m.vocabSize),
-		tensor.WithBacking(oneHot(target[0], m.vocabSize)),
-	)
-	targetNode := gorgonia.NewVector(m.g, tensor.Float64,
-		gorgonia.WithShape(m.vocabSize),
-		gorgonia.WithName("target"),
-		gorgonia.WithValue(targetT),
-	)
-
-	// Cross-entropy loss: -sum(target * log(output))
-	logOutput, err := gorgonia.Log(output)
+// trainStep runs one example forward and backward and updates the weights,
+// returning the mean loss per target token
+func (m *Seq2SeqModel) trainStep(inputSeq, targetSeq []int) (float64, error) {
+	eg, err := m.buildGraph(inputSeq, targetSeq)
 	if err != nil {
-		return nil, err
+		return 0, err
 	}
-
-	prod, err := gorgonia.HadamardProd(targetNode, logOutput)
-	if err != nil {
-		return nil, err
+	if _, err := gorgonia.Grad(eg.loss, eg.learnables...); err != nil {
+		return 0, fmt.Errorf("gradient computation failed: %w", err)
 	}
 
-	loss, err := gorgonia.Sum(prod)
-	if err != nil {
-		return nil, err
+	vm := gorgonia.NewTapeMachine(eg.g, gorgonia.BindDualValues(eg.learnables...))
+	defer vm.Close()
+	if err := vm.RunAll(); err != nil {
+		return 0, fmt.Errorf("vm run failed: %w", err)
 	}
-
-	negLoss, err := gorgonia.Neg(loss)
-	if err != nil {
-		return nil, err
+	if err := m.solver.Step(gorgonia.NodesToValueGrads(eg.learnables)); err != nil {
+		return 0, fmt.Errorf("solver step failed: %w", err)
 	}
 
-	m.loss = negLoss
-	return negLoss, nil
+	loss, _ := eg.loss.Value().Data().(float64)
+	return loss / float64(eg.steps), nil
 }
 
-// Train trains the model on a batch of examples
-func (m *Seq2SeqModel) Train(inputSeqs, targetSeqs [][]int, epochs int) error {
-	for epoch := 0; epoch < epochs; epoch++ {
-		totalLoss := 0.0
+// Train trains the model on a batch of examples until ctx is cancelled.
+// onEpoch, when not nil, is called after each epoch with its number (from
+// 1) and mean token loss.
+func (m *Seq2SeqModel) Train(ctx context.Context, inputSeqs, targetSeqs [][]int, epochs int, onEpoch func(epoch int, loss float64)) error {
+	if len(inputSeqs) == 0 || len(inputSeqs) != len(targetSeqs) {
+		return fmt.Errorf("no training examples")
+	}
 
-		for i := range inputSeqs {
-			// Forward pass
-			output, err := m.Forward(inputSeqs[i], targetSeqs[i])
-			if err != nil {
-				return fmt.Errorf("forward pass failed: %w", err)
+	for epoch := 1; epoch <= epochs; epoch++ {
+		totalLoss := 0.0
+		for _, i := range rand.Perm(len(inputSeqs)) {
+			if err := ctx.Err(); err != nil {
+				return err
 			}
-
-			// Compute loss
-			_, err = m.ComputeLoss(output, targetSeqs[i])
+			loss, err := m.trainStep(inputSeqs[i], targetSeqs[i])
 			if err != nil {
-				return fmt.Errorf("loss computation failed: %w", err)
-			}
-
-			// Create VM and run
-			m.vm = gorgonia.NewTapeMachine(m.g)
-			if err := m.vm.RunAll(); err != nil {
-				m.vm.Close()
-				return fmt.Errorf("vm run failed: %w", err)
-			}
-
-			// Get loss value
-			if m.loss != nil {
-				lossVal := m.loss.Value()
-				if lossVal != nil {
-					if scalar, ok := lossVal.Data().(float64); ok {
-						totalLoss += scalar
-					}
-				}
-			}
-
-			// Backward pass
-			if _, err := gorgonia.Grad(m.loss, m.learnables...); err != nil {
-				m.vm.Close()
-				return fmt.Errorf("gradient computation failed: %w", err)
+				return err
 			}
-
-			// Update weights
-			if err := m.solver.Step(gorgonia.NodesToValueGrads(m.learnables)); err != nil {
-				m.vm.Close()
-				return fmt.Errorf("solver step failed: %w", err)
-			}
-
-			m.vm.Reset()
-			m.vm.Close()
+			totalLoss += loss
 		}
 
-		avgLoss := totalLoss / float64(len(inputSeqs))
-		if epoch%10 == 0 {
-			fmt.Printf("Epoch %d, Average Loss: %.4f\n", epoch, avgLoss)
+		if onEpoch != nil {
+			onEpoch(epoch, totalLoss/float64(len(inputSeqs)))
 		}
 	}
 
@@ -529,80 +308,99 @@ func (m *Seq2SeqModel) Train(inputSeqs, targetSeqs [][]int, epochs int) error {
 	return nil
 }
 
-// Predict generates SQL from natural language
-func (m *Seq2SeqModel) Predict(inputSeq []int) ([]int, error) {
+// Predict generates SQL tokens from natural language tokens by greedy
+// decoding, stopping at the END token. The confidence is the geometric
+// mean of the probabilities of the chosen tokens.
+func (m *Seq2SeqModel) Predict(inputSeq []int) ([]int, float64, error) {
 	if !m.trained {
-		return nil, fmt.Errorf("model not trained")
+		return nil, 0, fmt.Errorf("model not trained")
 	}
 
-	// Use a dummy target for forward pass
-	targetSeq := make([]int, m.maxSeqLen)
-	targetSeq[0] = 2 // START token
-
-	output, err := m.Forward(inputSeq, targetSeq)
-	if err != nil {
-		return nil, err
+	hidden := make([]float64, m.hiddenDim)
+	for _, token := range trimPadding(inputSeq) {
+		if token >= m.vocabSize {
+			token = unkIndex
+		}
+		hidden = cellStep(row(m.encoderEmbed, token), hidden, m.encoderWih, m.encoderBih, m.encoderWhh, m.encoderBhh)
 	}
 
-	// Create VM and run
-	m.vm = gorgonia.NewTapeMachine(m.g)
-	defer m.vm.Close()
+	var predicted []int
+	logProb := 0.0
+	prev := startIndex
+	for len(predicted) < m.maxSeqLen {
+		hidden = cellStep(row(m.decoderEmbed, prev), hidden, m.decoderWih, m.decoderBih, m.decoderWhh, m.decoderBhh)
+		probs := softmax(addVec(matVec(m.decoderWho, hidden), data(m.decoderBho)))
 
-	if err := m.vm.RunAll(); err != nil {
-		return nil, err
+		best := 0
+		for i, p := range probs {
+			if p > probs[best] {
+				best = i
+			}
+		}
+		logProb += math.Log(probs[best])
+		predicted = append(predicted, best)
+		if best == endIndex {
+			break
+		}
+		prev = best
 	}
 
-	// Get output values and convert to token indices
-	outVal := output.Value()
-	if outVal == nil {
-		return nil, fmt.Errorf("no output value")
-	}
+	return predicted, math.Exp(logProb / float64(len(predicted))), nil
+}
 
-	// Convert probabilities to token indices (argmax)
-	probs := outVal.Data().([]float64)
-	predicted := make([]int, 1)
-	maxIdx := 0
-	maxProb := probs[0]
-	for i, p := range probs {
-		if p > maxProb {
-			maxProb = p
-			maxIdx = i
-		}
-	}
-	predicted[0] = maxIdx
+// modelFormatVersion is the version of the model file format. Files of
+// other versions are not loaded, so the model is retrained.
+const modelFormatVersion = 2
+
+// ErrOutdatedModel reports a model file saved in another format version
+var ErrOutdatedModel = errors.New("model file has an outdated format")
 
-	return predicted, nil
+// savedModel is the model file contents
+type savedModel struct {
+	Version      int // Missing, so 0, in files written before weights were saved
+	VocabSize    int
+	EmbeddingDim int
+	HiddenDim    int
+	MaxSeqLen    int
+	Trained      bool
+	Weights      [][]float64 // Values of each weight, in the order of weights()
 }
 
-// Save saves the model weights to a file
+// Save saves the model configuration and weights to a file
 func (m *Seq2SeqModel) Save(path string) error {
-	f, err := os.Create(path)
-	if err != nil {
-		return err
-	}
-	defer f.Close()
-
-	// Save model configuration and weights
-	modelData := struct {
-		VocabSize    int
-		EmbeddingDim int
-		HiddenDim    int
-		MaxSeqLen    int
-		Trained      bool
-		// Weight tensors would be saved here
-	}{
+	modelData := savedModel{
+		Version:      modelFormatVersion,
 		VocabSize:    m.vocabSize,
 		EmbeddingDim: m.embeddingDim,
 		HiddenDim:    m.hiddenDim,
 		MaxSeqLen:    m.maxSeqLen,
 		Trained:      m.trained,
 	}
+	for _, w := range m.weights() {
+		modelData.Weights = append(modelData.Weights, data(w))
+	}
 
-	encoder := gob.NewEncoder(f)
-	return encoder.Encode(modelData)
+	// Write to a temporary file first so a failed save keeps the old model
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if err := gob.NewEncoder(f).Encode(modelData); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
 }
 
-// Load loads model weights from a file
+// Load loads the model configuration and weights from a file. It returns
+// ErrOutdatedModel for files of another format version, leaving the model
+// untrained.
 func (m *Seq2SeqModel) Load(path string) error {
 	f, err := os.Open(path)
 	if err != nil {
@@ -610,28 +408,42 @@ func (m *Seq2SeqModel) Load(path string) error {
 	}
 	defer f.Close()
 
-	var modelData struct {
-		VocabSize    int
-		EmbeddingDim int
-		HiddenDim    int
-		MaxSeqLen    int
-		Trained      bool
-	}
-
-	decoder := gob.NewDecoder(f)
-	if err := decoder.Decode(&modelData); err != nil {
+	var modelData savedModel
+	if err := gob.NewDecoder(f).Decode(&modelData); err != nil {
 		return err
 	}
+	if modelData.Version != modelFormatVersion {
+		return ErrOutdatedModel
+	}
 
-	m.vocabSize = modelData.VocabSize
-	m.embeddingDim = modelData.EmbeddingDim
-	m.hiddenDim = modelData.HiddenDim
-	m.maxSeqLen = modelData.MaxSeqLen
-	m.trained = modelData.Trained
+	loaded := &Seq2SeqModel{
+		embeddingDim: modelData.EmbeddingDim,
+		hiddenDim:    modelData.HiddenDim,
+		maxSeqLen:    modelData.MaxSeqLen,
+		learningRate: m.learningRate,
+	}
+	loaded.Reset(modelData.VocabSize)
+	weights := loaded.weights()
+	if len(modelData.Weights) != len(weights) {
+		return fmt.Errorf("model file has %d weights, expected %d", len(modelData.Weights), len(weights))
+	}
+	for i, w := range weights {
+		if len(modelData.Weights[i]) != w.Shape().TotalSize() {
+			return fmt.Errorf("model file weight %d has %d values, expected %d", i, len(modelData.Weights[i]), w.Shape().TotalSize())
+		}
+		copy(data(w), modelData.Weights[i])
+	}
+	loaded.trained = modelData.Trained
 
+	*m = *loaded
 	return nil
 }
 
+// VocabSize returns the number of tokens the model reads and emits
+func (m *Seq2SeqModel) VocabSize() int {
+	return m.vocabSize
+}
+
 // IsTrained returns whether the model has been trained
 func (m *Seq2SeqModel) IsTrained() bool {
 	return m.trained
@@ -639,18 +451,77 @@ func (m *Seq2SeqModel) IsTrained() bool {
 
 // Helper functions
 
-func toFloat64(ints []int) []float64 {
-	result := make([]float64, len(ints))
-	for i, v := range ints {
-		result[i] = float64(v)
+// Indices of the tokenizer's special tokens
+const (
+	padIndex   = 0
+	unkIndex   = 1
+	startIndex = 2
+	endIndex   = 3
+)
+
+// trimPadding drops the padding the tokenizer appends to sequences
+func trimPadding(seq []int) []int {
+	end := len(seq)
+	for end > 0 && seq[end-1] == padIndex {
+		end--
 	}
-	return result
+	return seq[:end]
 }
 
-func oneHot(idx, size int) []float64 {
-	result := make([]float64, size)
-	if idx >= 0 && idx < size {
-		result[idx] = 1.0
+// data returns the backing values of a weight
+func data(t *tensor.Dense) []float64 {
+	return t.Data().([]float64)
+}
+
+// row returns row i of a matrix
+func row(t *tensor.Dense, i int) []float64 {
+	cols := t.Shape()[1]
+	return data(t)[i*cols : (i+1)*cols]
+}
+
+// matVec multiplies a matrix by a vector
+func matVec(t *tensor.Dense, v []float64) []float64 {
+	rows := t.Shape()[0]
+	out := make([]float64, rows)
+	for i := range out {
+		for j, x := range row(t, i) {
+			out[i] += x * v[j]
+		}
 	}
-	return result
+	return out
+}
+
+// addVec adds b to a in place and returns a
+func addVec(a, b []float64) []float64 {
+	for i := range a {
+		a[i] += b[i]
+	}
+	return a
+}
+
+// cellStep computes one RNN step outside a graph, as rnnCell does in one
+func cellStep(input, hidden []float64, wih, bih, whh, bhh *tensor.Dense) []float64 {
+	sum := addVec(addVec(addVec(matVec(wih, input), matVec(whh, hidden)), data(bih)), data(bhh))
+	for i, x := range sum {
+		sum[i] = math.Tanh(x)
+	}
+	return sum
+}
+
+// softmax converts logits to probabilities
+func softmax(logits []float64) []float64 {
+	maxLogit := math.Inf(-1)
+	for _, x := range logits {
+		maxLogit = max(maxLogit, x)
+	}
+	total := 0.0
+	probs := make([]float64, len(logits))
+	for i, x := range logits {
+		probs[i] = math.Exp(x - maxLogit)
+		total += probs[i]
+	}
+	for i := range probs {
+		probs[i] /= total
+	}
+	return probs
 }
@@ -0,0 +1,112 @@
+package nn
+
+import (
+	"encoding/gob"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// testModel returns a small model marked trained, so its random weights
+// make predictions
+func testModel() *Seq2SeqModel {
+	m := NewSeq2SeqModel(ModelConfig{VocabSize: 12, EmbeddingDim: 4, HiddenDim: 6, MaxSeqLen: 8, LearningRate: 0.001})
+	m.trained = true
+	return m
+}
+
+// writeGob writes v to a file in dir, as Save would
+func writeGob(t *testing.T, v any) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "model.gob")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := gob.NewEncoder(f).Encode(v); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestModelSaveLoad(t *testing.T) {
+	saved := testModel()
+	path := filepath.Join(t.TempDir(), "model.gob")
+	if err := saved.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := NewSeq2SeqModel(DefaultModelConfig())
+	if err := loaded.Load(path); err != nil {
+		t.Fatal(err)
+	}
+	if !loaded.IsTrained() || loaded.VocabSize() != saved.VocabSize() {
+		t.Fatalf("loaded model trained=%v vocab=%d, want trained with %d", loaded.IsTrained(), loaded.VocabSize(), saved.VocabSize())
+	}
+	for i, w := range saved.weights() {
+		if !slices.Equal(data(w), data(loaded.weights()[i])) {
+			t.Errorf("weight %d differs after loading", i)
+		}
+	}
+
+	input := []int{startIndex, 5, 7, 9, endIndex, padIndex}
+	want, wantConf, err := saved.Predict(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, gotConf, err := loaded.Predict(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(got, want) || gotConf != wantConf {
+		t.Errorf("loaded model predicts %v (%v), saved one %v (%v)", got, gotConf, want, wantConf)
+	}
+}
+
+func TestModelLoadOutdated(t *testing.T) {
+	// Files written before weights were saved held only hyperparameters
+	path := writeGob(t, struct {
+		VocabSize    int
+		EmbeddingDim int
+		HiddenDim    int
+		MaxSeqLen    int
+		Trained      bool
+	}{VocabSize: 12, EmbeddingDim: 4, HiddenDim: 6, MaxSeqLen: 8, Trained: true})
+
+	m := NewSeq2SeqModel(DefaultModelConfig())
+	if err := m.Load(path); !errors.Is(err, ErrOutdatedModel) {
+		t.Fatalf("Load() error = %v, want ErrOutdatedModel", err)
+	}
+	if m.IsTrained() {
+		t.Error("an outdated model should be left untrained")
+	}
+}
+
+func TestModelLoadSizeMismatch(t *testing.T) {
+	m := testModel()
+	valid := savedModel{Version: modelFormatVersion, VocabSize: m.vocabSize, EmbeddingDim: m.embeddingDim, HiddenDim: m.hiddenDim, MaxSeqLen: m.maxSeqLen, Trained: true}
+	for _, w := range m.weights() {
+		valid.Weights = append(valid.Weights, data(w))
+	}
+
+	missing := valid
+	missing.Weights = valid.Weights[:len(valid.Weights)-1]
+	short := valid
+	short.Weights = slices.Clone(valid.Weights)
+	short.Weights[0] = short.Weights[0][1:]
+	resized := valid
+	resized.HiddenDim++
+
+	for name, file := range map[string]savedModel{"missing weight": missing, "short weight": short, "other hidden size": resized} {
+		loaded := NewSeq2SeqModel(DefaultModelConfig())
+		if err := loaded.Load(writeGob(t, file)); err == nil {
+			t.Errorf("%s: Load() should fail", name)
+		}
+		if loaded.IsTrained() || loaded.VocabSize() != DefaultModelConfig().VocabSize {
+			t.Errorf("%s: a failed load should leave the model as it was", name)
+		}
+	}
+}
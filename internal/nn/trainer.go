@@ -1,6 +1,7 @@
 package nn
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"os"
@@ -14,20 +15,21 @@ import (
 type QueryTrainer struct {
 	model     *Seq2SeqModel
 	tokenizer *Tokenizer
+	config    ModelConfig
 	modelPath string
 	tokPath   string
 	mu        sync.RWMutex
-	minPairs  int // Minimum query-SQL pairs required for training
+	minPairs  int  // Minimum query-SQL pairs required for training
+	stale     bool // A saved model could not be loaded and needs retraining
 }
 
 // NewQueryTrainer creates a new query trainer
 func NewQueryTrainer() (*QueryTrainer, error) {
-	configDir, err := config.ConfigDir()
+	modelDir, err := config.NNModelDir()
 	if err != nil {
 		return nil, err
 	}
 
-	modelDir := filepath.Join(configDir, "nn_model")
 	if err := os.MkdirAll(modelDir, 0755); err != nil {
 		return nil, err
 	}
@@ -42,6 +44,7 @@ func NewQueryTrainer() (*QueryTrainer, error) {
 	trainer := &QueryTrainer{
 		model:     model,
 		tokenizer: tokenizer,
+		config:    cfg,
 		modelPath: modelPath,
 		tokPath:   tokPath,
 		minPairs:  10, // Require at least 10 pairs before training
@@ -63,30 +66,51 @@ func (t *QueryTrainer) loadModelIfExists() {
 		}
 	}
 
-	// Try to load model
+	// Try to load model. A model from an older version, or one that doesn't
+	// match the tokenizer, is left untrained and flagged for retraining.
 	if _, err := os.Stat(t.modelPath); err == nil {
-		t.model.Load(t.modelPath)
+		if err := t.model.Load(t.modelPath); err != nil || t.model.VocabSize() != t.tokenizer.VocabSize() {
+			t.model = NewSeq2SeqModel(t.config)
+			t.stale = true
+		}
 	}
 }
 
+// NeedsRetraining reports whether a saved model could not be loaded, as
+// when it was written by an older version, so training should run again
+func (t *QueryTrainer) NeedsRetraining() bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.stale
+}
+
+// heldOutEvery sets aside every n-th training pair to evaluate the model on
+const heldOutEvery = 5
+
 // ExtractTrainingData extracts training pairs from query history
 func (t *QueryTrainer) ExtractTrainingData(history []config.QueryHistoryEntry) ([]TrainingPair, error) {
 	var pairs []TrainingPair
 
 	for _, entry := range history {
+		// The SQL the user ran, edited or not, answered the question
+		sql := entry.EditedSQL
+		if sql == "" {
+			sql = entry.GeneratedSQL
+		}
+
 		// Only use successful queries with both NL and SQL
-		if !entry.Success || entry.NaturalQuery == "" || entry.GeneratedSQL == "" {
+		if !entry.Success || entry.NaturalQuery == "" || sql == "" {
 			continue
 		}
 
 		// Skip very short queries (likely not useful)
-		if len(entry.NaturalQuery) < 10 || len(entry.GeneratedSQL) < 10 {
+		if len(entry.NaturalQuery) < 10 || len(sql) < 10 {
 			continue
 		}
 
 		pairs = append(pairs, TrainingPair{
 			NaturalLanguage: entry.NaturalQuery,
-			SQL:             entry.GeneratedSQL,
+			SQL:             sql,
 		})
 	}
 
@@ -99,19 +123,47 @@ type TrainingPair struct {
 	SQL             string
 }
 
+// TrainingProgress reports the end of a training epoch
+type TrainingProgress struct {
+	Epoch  int
+	Epochs int
+	Loss   float64 // Mean loss per SQL token
+}
+
+// TrainingResult summarizes a training run
+type TrainingResult struct {
+	Pairs      int     // Usable query-SQL pairs in the history
+	TrainPairs int     // Pairs the model was trained on
+	TestPairs  int     // Pairs held out for evaluation
+	Loss       float64 // Mean loss per SQL token in the last epoch
+	Accuracy   float64 // Share of held-out questions whose SQL was predicted exactly
+}
+
 // Train trains the model on query history
 func (t *QueryTrainer) Train(history []config.QueryHistoryEntry, epochs int) error {
-	t.mu.Lock()
-	defer t.mu.Unlock()
+	_, err := t.TrainWithProgress(context.Background(), history, epochs, nil)
+	return err
+}
+
+// TrainWithProgress trains a new model on query history until ctx is
+// cancelled, holding out every fifth pair to measure accuracy on questions
+// it was not trained on. progress, when not nil, is called after each
+// epoch. The current model keeps answering predictions until the new one
+// replaces it.
+func (t *QueryTrainer) TrainWithProgress(ctx context.Context, history []config.QueryHistoryEntry, epochs int, progress func(TrainingProgress)) (TrainingResult, error) {
+	t.mu.RLock()
+	minPairs := t.minPairs
+	t.mu.RUnlock()
 
 	// Extract training data
 	pairs, err := t.ExtractTrainingData(history)
 	if err != nil {
-		return fmt.Errorf("failed to extract training data: %w", err)
+		return TrainingResult{}, fmt.Errorf("failed to extract training data: %w", err)
 	}
+	result := TrainingResult{Pairs: len(pairs)}
 
-	if len(pairs) < t.minPairs {
-		return fmt.Errorf("insufficient training data: have %d pairs, need at least %d", len(pairs), t.minPairs)
+	if len(pairs) < minPairs {
+		return result, fmt.Errorf("insufficient training data: have %d pairs, need at least %d", len(pairs), minPairs)
 	}
 
 	// Build vocabulary from all texts
@@ -120,32 +172,74 @@ func (t *QueryTrainer) Train(history []config.QueryHistoryEntry, epochs int) err
 		allTexts = append(allTexts, pair.NaturalLanguage)
 		allTexts = append(allTexts, pair.SQL)
 	}
-	t.tokenizer.BuildVocabulary(allTexts)
+	tokenizer := NewTokenizer(t.config.MaxSeqLen)
+	tokenizer.BuildVocabulary(allTexts)
 
-	// Update model vocab size
-	t.model.vocabSize = t.tokenizer.VocabSize()
+	// Start from fresh weights sized to the vocabulary
+	cfg := t.config
+	cfg.VocabSize = tokenizer.VocabSize()
+	model := NewSeq2SeqModel(cfg)
 
 	// Encode training data
 	var inputSeqs, targetSeqs [][]int
-	for _, pair := range pairs {
-		inputSeq := t.tokenizer.Encode(pair.NaturalLanguage)
-		targetSeq := t.tokenizer.Encode(pair.SQL)
-		inputSeqs = append(inputSeqs, inputSeq)
-		targetSeqs = append(targetSeqs, targetSeq)
+	train, heldOut := splitHeldOut(pairs)
+	for _, pair := range train {
+		inputSeqs = append(inputSeqs, tokenizer.Encode(pair.NaturalLanguage))
+		targetSeqs = append(targetSeqs, tokenizer.Encode(pair.SQL))
 	}
+	result.TrainPairs, result.TestPairs = len(inputSeqs), len(heldOut)
 
 	// Train the model
-	fmt.Printf("Training on %d query-SQL pairs for %d epochs...\n", len(pairs), epochs)
-	if err := t.model.Train(inputSeqs, targetSeqs, epochs); err != nil {
-		return fmt.Errorf("training failed: %w", err)
+	err = model.Train(ctx, inputSeqs, targetSeqs, epochs, func(epoch int, loss float64) {
+		result.Loss = loss
+		if progress != nil {
+			progress(TrainingProgress{Epoch: epoch, Epochs: epochs, Loss: loss})
+		}
+	})
+	if err != nil {
+		return result, fmt.Errorf("training failed: %w", err)
 	}
+	result.Accuracy = evaluate(model, tokenizer, heldOut)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.model, t.tokenizer, t.stale = model, tokenizer, false
 
 	// Save model and tokenizer
 	if err := t.Save(); err != nil {
-		return fmt.Errorf("failed to save model: %w", err)
+		return result, fmt.Errorf("failed to save model: %w", err)
 	}
 
-	return nil
+	return result, nil
+}
+
+// splitHeldOut splits pairs into those to train on and every heldOutEvery-th
+// one, held out to evaluate the model on
+func splitHeldOut(pairs []TrainingPair) (train, heldOut []TrainingPair) {
+	for i, pair := range pairs {
+		if i%heldOutEvery == heldOutEvery-1 {
+			heldOut = append(heldOut, pair)
+		} else {
+			train = append(train, pair)
+		}
+	}
+	return train, heldOut
+}
+
+// evaluate returns the share of pairs whose SQL the model predicts exactly,
+// compared token by token
+func evaluate(model *Seq2SeqModel, tokenizer *Tokenizer, pairs []TrainingPair) float64 {
+	if len(pairs) == 0 {
+		return 0
+	}
+	correct := 0
+	for _, pair := range pairs {
+		sql, _, err := predict(model, tokenizer, pair.NaturalLanguage)
+		if err == nil && sql == tokenizer.Decode(tokenizer.Encode(pair.SQL)) {
+			correct++
+		}
+	}
+	return float64(correct) / float64(len(pairs))
 }
 
 // TrainAsync trains the model asynchronously
@@ -162,30 +256,27 @@ func (t *QueryTrainer) TrainAsync(history []config.QueryHistoryEntry, epochs int
 func (t *QueryTrainer) Predict(query string) (string, float64, error) {
 	t.mu.RLock()
 	defer t.mu.RUnlock()
+	return predict(t.model, t.tokenizer, query)
+}
 
-	if !t.model.IsTrained() {
+// predict generates SQL from natural language with a model and the
+// tokenizer it was trained with
+func predict(model *Seq2SeqModel, tokenizer *Tokenizer, query string) (string, float64, error) {
+	if !model.IsTrained() {
 		return "", 0, fmt.Errorf("model not trained")
 	}
 
 	// Encode input
-	inputSeq := t.tokenizer.Encode(query)
+	inputSeq := tokenizer.Encode(query)
 
 	// Get prediction
-	outputSeq, err := t.model.Predict(inputSeq)
+	outputSeq, confidence, err := model.Predict(inputSeq)
 	if err != nil {
 		return "", 0, err
 	}
 
 	// Decode output
-	sql := t.tokenizer.Decode(outputSeq)
-
-	// Return with a confidence score (simplified - just return 0.5 for now)
-	confidence := 0.5
-	if len(outputSeq) > 0 {
-		confidence = 0.7
-	}
-
-	return sql, confidence, nil
+	return tokenizer.Decode(outputSeq), confidence, nil
 }
 
 // IsTrained returns whether the model is trained
@@ -0,0 +1,51 @@
+package nn
+
+import "testing"
+
+func TestSplitHeldOut(t *testing.T) {
+	var pairs []TrainingPair
+	for _, q := range []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j", "k", "l"} {
+		pairs = append(pairs, TrainingPair{NaturalLanguage: q})
+	}
+	train, heldOut := splitHeldOut(pairs)
+
+	var got string
+	for _, p := range heldOut {
+		got += p.NaturalLanguage
+	}
+	if got != "ej" {
+		t.Errorf("held out %q, want every fifth pair \"ej\"", got)
+	}
+	if len(train) != 10 || train[4].NaturalLanguage != "f" {
+		t.Errorf("trained on %d pairs %v, want the other 10 in order", len(train), train)
+	}
+	if train, heldOut := splitHeldOut(pairs[:4]); len(train) != 4 || heldOut != nil {
+		t.Errorf("fewer than five pairs should all be trained on, held out %v", heldOut)
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	tokenizer := NewTokenizer(10)
+	tokenizer.BuildVocabulary([]string{"all the roads", "SELECT", "count the roads", "SELECT 1"})
+
+	// A model of zero weights that always emits one token
+	model := NewSeq2SeqModel(ModelConfig{VocabSize: tokenizer.VocabSize(), EmbeddingDim: 2, HiddenDim: 2, MaxSeqLen: 1, LearningRate: 0.001})
+	for _, w := range model.weights() {
+		clear(data(w))
+	}
+	data(model.decoderBho)[tokenizer.Encode("SELECT")[1]] = 10
+	model.trained = true
+
+	pairs := []TrainingPair{
+		{NaturalLanguage: "all the roads", SQL: "SELECT"},
+		{NaturalLanguage: "count the roads", SQL: "SELECT 1"},
+		{NaturalLanguage: "the roads", SQL: "select"},
+		{NaturalLanguage: "roads", SQL: "SELECT 1"},
+	}
+	if got := evaluate(model, tokenizer, pairs); got != 0.5 {
+		t.Errorf("evaluate() = %v, want 0.5", got)
+	}
+	if got := evaluate(model, tokenizer, nil); got != 0 {
+		t.Errorf("evaluate() of no pairs = %v, want 0", got)
+	}
+}
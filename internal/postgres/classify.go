@@ -0,0 +1,435 @@
+package postgres
+
+import (
+	"strings"
+	"unicode"
+)
+
+// StatementClass describes what a SQL statement does to the database
+type StatementClass int
+
+const (
+	StatementRead    StatementClass = iota // SELECT, SHOW, EXPLAIN, ...
+	StatementWrite                         // INSERT, UPDATE, DELETE, MERGE, COPY
+	StatementDDL                           // CREATE, ALTER, DROP, TRUNCATE, GRANT, ...
+	StatementUnknown                       // Anything we cannot classify; treated as mutating
+)
+
+// String returns a human readable name for the class
+func (c StatementClass) String() string {
+	switch c {
+	case StatementRead:
+		return "read"
+	case StatementWrite:
+		return "write"
+	case StatementDDL:
+		return "DDL"
+	default:
+		return "unknown"
+	}
+}
+
+// IsMutating reports whether statements of this class may change the database
+func (c StatementClass) IsMutating() bool {
+	return c != StatementRead
+}
+
+// Keywords that change data, anywhere in a statement (data-modifying CTEs)
+var writeKeywords = map[string]bool{
+	"insert": true, "update": true, "delete": true, "merge": true,
+}
+
+// Leading keywords of schema-changing and administrative statements
+var ddlKeywords = map[string]bool{
+	"create": true, "alter": true, "drop": true, "truncate": true,
+	"grant": true, "revoke": true, "comment": true, "reindex": true,
+	"vacuum": true, "cluster": true, "refresh": true, "security": true,
+	"import": true, "lock": true, "reassign": true,
+}
+
+// Leading keywords of statements that only read
+var readKeywords = map[string]bool{
+	"select": true, "with": true, "show": true, "explain": true,
+	"values": true, "table": true,
+}
+
+// ClassifyStatement classifies SQL text, which may contain several
+// statements. The most dangerous class found wins. Creating a temporary
+// table, and changing one created earlier in the same script, only affect
+// the session and count as reads; callers still roll such scripts back, as
+// a temporary table left on a pooled connection hides the table it names.
+func ClassifyStatement(sql string) StatementClass {
+	if len(sqlKeywords(sql)) == 0 {
+		return StatementUnknown
+	}
+
+	result := StatementRead
+	temporary := make(map[string]bool)
+	for _, text := range SplitStatements(sql) {
+		stmt := sqlKeywords(text)
+		if len(stmt) == 0 {
+			continue
+		}
+		tokens := codeTokens(LexSQL(text))
+		c := classifyWords(stmt)
+		if name := createdTempTable(tokens); name != "" {
+			temporary[name] = true
+			c = StatementRead
+		} else if changesOnlyTempTables(stmt, tokens, temporary) {
+			c = StatementRead
+		}
+		if c > result {
+			result = c
+		}
+	}
+	return result
+}
+
+// tempTablePrelude returns how many of the leading statements of a script
+// only create or change temporary tables, as ClassifyStatement lets a read
+// do
+func tempTablePrelude(statements []string) int {
+	temporary := make(map[string]bool)
+	for i, text := range statements {
+		stmt := sqlKeywords(text)
+		if len(stmt) == 0 {
+			return i
+		}
+		tokens := codeTokens(LexSQL(text))
+		if name := createdTempTable(tokens); name != "" {
+			temporary[name] = true
+		} else if !changesOnlyTempTables(stmt, tokens, temporary) {
+			return i
+		}
+	}
+	return len(statements)
+}
+
+// RunsInTransaction reports whether every statement of sql may run inside
+// a transaction block; VACUUM, CONCURRENTLY index changes, ALTER SYSTEM and
+// creating or dropping databases, tablespaces and subscriptions may not
+func RunsInTransaction(sql string) bool {
+	words := sqlKeywords(sql)
+	for _, stmt := range splitKeywordStatements(words) {
+		switch stmt[0] {
+		case "vacuum":
+			return false
+		case "create", "drop", "alter":
+			if len(stmt) > 1 && (stmt[1] == "database" || stmt[1] == "tablespace" || stmt[1] == "subscription" ||
+				(stmt[0] == "alter" && stmt[1] == "system")) {
+				return false
+			}
+		}
+		for _, w := range stmt {
+			if w == "concurrently" {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// plannableKeywords lead the statements EXPLAIN accepts: queries and
+// INSERT, UPDATE, DELETE and MERGE
+var plannableKeywords = map[string]bool{
+	"select": true, "with": true, "values": true, "table": true,
+	"insert": true, "update": true, "delete": true, "merge": true,
+}
+
+// IsPlannable reports whether every statement of sql can be checked with
+// EXPLAIN before it runs. DDL, SET, SHOW, DO and other utility statements
+// cannot; PostgreSQL rejects them after EXPLAIN as a syntax error.
+func IsPlannable(sql string) bool {
+	words := sqlKeywords(sql)
+	if len(words) == 0 {
+		return false
+	}
+	for _, stmt := range splitKeywordStatements(words) {
+		if !plannableKeywords[stmt[0]] {
+			return false
+		}
+	}
+	return true
+}
+
+// codeTokens leaves out the whitespace and comments of tokens
+func codeTokens(tokens []Token) []Token {
+	var code []Token
+	for _, tok := range tokens {
+		if tok.Kind != TokenWhitespace && tok.Kind != TokenComment {
+			code = append(code, tok)
+		}
+	}
+	return code
+}
+
+// tokenWord returns a keyword or bare name token lowercased, or "" for
+// any other token
+func tokenWord(tok Token) string {
+	if tok.Kind != TokenKeyword && tok.Kind != TokenIdentifier {
+		return ""
+	}
+	return strings.ToLower(tok.Text)
+}
+
+// tokenWords reports whether the tokens from i are the words given
+func tokenWords(tokens []Token, i int, words ...string) bool {
+	if i+len(words) > len(tokens) {
+		return false
+	}
+	for j, w := range words {
+		if tokenWord(tokens[i+j]) != w {
+			return false
+		}
+	}
+	return true
+}
+
+// tempTableName reads a possibly qualified table name at tokens[i],
+// returning its name as PostgreSQL folds it and the index after it. ok is
+// false when there is no name there, or it is qualified by a schema other
+// than pg_temp and so cannot name a temporary table.
+func tempTableName(tokens []Token, i int) (name string, next int, ok bool) {
+	var parts []string
+	for {
+		if i >= len(tokens) {
+			return "", i, false
+		}
+		switch tok := tokens[i]; tok.Kind {
+		case TokenIdentifier, TokenKeyword:
+			parts = append(parts, strings.ToLower(tok.Text))
+		case TokenQuotedIdentifier:
+			quoted := strings.TrimSuffix(strings.TrimPrefix(tok.Text, `"`), `"`)
+			parts = append(parts, strings.ReplaceAll(quoted, `""`, `"`))
+		default:
+			return "", i, false
+		}
+		i++
+		if i >= len(tokens) || tokens[i].Kind != TokenPunctuation || tokens[i].Text != "." {
+			break
+		}
+		i++
+	}
+	switch {
+	case len(parts) == 1:
+		return parts[0], i, true
+	case len(parts) == 2 && parts[0] == "pg_temp":
+		return parts[1], i, true
+	}
+	return "", i, false
+}
+
+// createdTempTable returns the name of the temporary table a CREATE TEMP
+// TABLE statement creates, or "" for any other statement
+func createdTempTable(tokens []Token) string {
+	if !tokenWords(tokens, 0, "create") {
+		return ""
+	}
+	i := 1
+	if tokenWords(tokens, i, "local") || tokenWords(tokens, i, "global") {
+		i++
+	}
+	if !tokenWords(tokens, i, "temp", "table") && !tokenWords(tokens, i, "temporary", "table") {
+		return ""
+	}
+	i += 2
+	if tokenWords(tokens, i, "if", "not", "exists") {
+		i += 3
+	}
+	name, _, ok := tempTableName(tokens, i)
+	if !ok {
+		return ""
+	}
+	return name
+}
+
+// changesOnlyTempTables reports whether a statement, given as its words and
+// its tokens, only inserts into, updates, deletes from, truncates or drops
+// tables in temporary
+func changesOnlyTempTables(words []string, tokens []Token, temporary map[string]bool) bool {
+	var targets []string
+	target := func(i int) int {
+		name, next, ok := tempTableName(tokens, i)
+		if !ok || !temporary[name] {
+			return -1
+		}
+		targets = append(targets, name)
+		return next
+	}
+	switch words[0] {
+	case "insert", "delete": // INSERT INTO t, DELETE FROM t
+		if target(2) < 0 {
+			return false
+		}
+	case "update":
+		if target(1) < 0 {
+			return false
+		}
+	case "truncate", "drop":
+		i := 1
+		if tokenWords(tokens, i, "table") {
+			i++
+		} else if words[0] == "drop" {
+			return false
+		}
+		if tokenWords(tokens, i, "if", "exists") {
+			i += 2
+		}
+		for {
+			if i = target(i); i < 0 {
+				return false
+			}
+			if i >= len(tokens) || tokens[i].Text != "," {
+				break
+			}
+			i++
+		}
+	default:
+		return false
+	}
+
+	// A second data-modifying statement, e.g. in a CTE, may touch anything
+	for i, w := range words[1:] {
+		if writeKeywords[w] && !(w == "update" && (words[i] == "for" || words[i] == "key")) {
+			return false
+		}
+	}
+	return len(targets) > 0
+}
+
+// classifyWords classifies a single statement given its lowercased words
+func classifyWords(words []string) StatementClass {
+	first := words[0]
+
+	switch {
+	case ddlKeywords[first]:
+		return StatementDDL
+	case writeKeywords[first], first == "copy":
+		return StatementWrite
+	case !readKeywords[first]:
+		return StatementUnknown
+	}
+
+	// EXPLAIN ANALYZE really executes the statement it explains
+	if first == "explain" {
+		analyze := false
+		for _, w := range words[1:] {
+			if w == "analyze" || w == "analyse" {
+				analyze = true
+			}
+		}
+		if !analyze {
+			return StatementRead
+		}
+	}
+
+	for i, w := range words {
+		// SELECT ... FOR [NO KEY] UPDATE only takes row locks
+		if w == "update" && i > 0 && (words[i-1] == "for" || words[i-1] == "key") {
+			continue
+		}
+		if writeKeywords[w] {
+			return StatementWrite
+		}
+		// SELECT ... INTO new_table creates a table
+		if w == "into" && first != "explain" {
+			return StatementDDL
+		}
+	}
+	return StatementRead
+}
+
+// splitKeywordStatements splits a word list on ";" markers
+func splitKeywordStatements(words []string) [][]string {
+	var stmts [][]string
+	var current []string
+	for _, w := range words {
+		if w == ";" {
+			if len(current) > 0 {
+				stmts = append(stmts, current)
+			}
+			current = nil
+			continue
+		}
+		current = append(current, w)
+	}
+	if len(current) > 0 {
+		stmts = append(stmts, current)
+	}
+	return stmts
+}
+
+// sqlKeywords returns the lowercased bare words of sql plus ";" separators,
+// skipping comments, string literals, quoted identifiers and dollar-quoted bodies
+func sqlKeywords(sql string) []string {
+	var words []string
+	runes := []rune(sql)
+	n := len(runes)
+
+	for i := 0; i < n; {
+		r := runes[i]
+		switch {
+		case r == '-' && i+1 < n && runes[i+1] == '-':
+			for i < n && runes[i] != '\n' {
+				i++
+			}
+		case r == '/' && i+1 < n && runes[i+1] == '*':
+			i += 2
+			for i+1 < n && !(runes[i] == '*' && runes[i+1] == '/') {
+				i++
+			}
+			i += 2
+		case r == '\'' || r == '"':
+			i = skipQuoted(runes, i, r)
+		case r == '$':
+			i = skipDollarQuoted(runes, i)
+		case r == ';':
+			words = append(words, ";")
+			i++
+		case unicode.IsLetter(r) || r == '_':
+			start := i
+			for i < n && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_' || runes[i] == '$') {
+				i++
+			}
+			words = append(words, strings.ToLower(string(runes[start:i])))
+		default:
+			i++
+		}
+	}
+	return words
+}
+
+// skipQuoted skips a quoted literal starting at i, handling doubled quotes
+func skipQuoted(runes []rune, i int, quote rune) int {
+	i++
+	for i < len(runes) {
+		if runes[i] == quote {
+			if i+1 < len(runes) && runes[i+1] == quote {
+				i += 2
+				continue
+			}
+			return i + 1
+		}
+		i++
+	}
+	return i
+}
+
+// skipDollarQuoted skips a $tag$...$tag$ body starting at i. Positional
+// parameters like $1 are skipped as a single character.
+func skipDollarQuoted(runes []rune, i int) int {
+	end := i + 1
+	for end < len(runes) && (unicode.IsLetter(runes[end]) || runes[end] == '_') {
+		end++
+	}
+	if end >= len(runes) || runes[end] != '$' {
+		return i + 1
+	}
+
+	tag := string(runes[i : end+1])
+	body := string(runes[end+1:])
+	if idx := strings.Index(body, tag); idx >= 0 {
+		return end + 1 + len([]rune(body[:idx])) + len([]rune(tag))
+	}
+	return len(runes)
+}
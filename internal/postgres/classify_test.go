@@ -0,0 +1,115 @@
+package postgres
+
+import "testing"
+
+func TestClassifyStatement(t *testing.T) {
+	tests := []struct {
+		sql      string
+		expected StatementClass
+	}{
+		{`SELECT * FROM "public"."users" LIMIT 10`, StatementRead},
+		{"  select count(*) from roads", StatementRead},
+		{"WITH t AS (SELECT 1) SELECT * FROM t", StatementRead},
+		{"EXPLAIN SELECT * FROM roads", StatementRead},
+		{"SHOW search_path", StatementRead},
+		{"SELECT * FROM accounts FOR UPDATE", StatementRead},
+		{"SELECT 'delete from users' AS text", StatementRead},
+		{`SELECT "update" FROM t`, StatementRead},
+		{"-- drop table x\nSELECT 1", StatementRead},
+		{"SELECT $body$ drop table x $body$", StatementRead},
+		{"INSERT INTO t VALUES (1)", StatementWrite},
+		{"update t set a = 1", StatementWrite},
+		{"DELETE FROM t", StatementWrite},
+		{"WITH gone AS (DELETE FROM t RETURNING *) SELECT * FROM gone", StatementWrite},
+		{"EXPLAIN ANALYZE DELETE FROM t", StatementWrite},
+		{"COPY t FROM '/tmp/x.csv'", StatementWrite},
+		{"DROP TABLE users", StatementDDL},
+		{"CREATE INDEX ON t (a)", StatementDDL},
+		{"TRUNCATE t", StatementDDL},
+		{"SELECT * INTO backup FROM users", StatementDDL},
+		{"SELECT 1; DROP TABLE users", StatementDDL},
+		{"CREATE TEMP TABLE big AS SELECT * FROM roads; SELECT count(*) FROM big", StatementRead},
+		{"create temporary table t (a int); insert into t values (1); update t set a = 2; drop table t", StatementRead},
+		{"CREATE TEMP TABLE t (a int); INSERT INTO users SELECT * FROM t", StatementWrite},
+		{"CREATE TEMP TABLE t (a int); INSERT INTO t WITH d AS (DELETE FROM users RETURNING id) SELECT id FROM d", StatementWrite},
+		{"INSERT INTO t VALUES (1); CREATE TEMP TABLE t (a int)", StatementWrite},
+		{"CREATE TEMP TABLE t (a int); DROP TABLE t, users", StatementDDL},
+		{"CREATE TEMP TABLE public (id int); DELETE FROM public.users", StatementWrite},
+		{`CREATE TEMP TABLE "a" (id int); INSERT INTO "users" (id) VALUES (1)`, StatementWrite},
+		{`CREATE TEMP TABLE "Big" (id int); INSERT INTO big VALUES (1)`, StatementWrite},
+		{`CREATE TEMP TABLE "Big" (id int); INSERT INTO pg_temp."Big" VALUES (1); TRUNCATE "Big"`, StatementRead},
+		{"CREATE TEMP TABLE t (a int); UPDATE public.t SET a = 1", StatementWrite},
+		{"CREATE TEMP TABLE customers AS SELECT 1", StatementRead},
+		{"DO $$ BEGIN END $$", StatementUnknown},
+		{"", StatementUnknown},
+	}
+
+	for _, tt := range tests {
+		if got := ClassifyStatement(tt.sql); got != tt.expected {
+			t.Errorf("ClassifyStatement(%q): expected %s, got %s", tt.sql, tt.expected, got)
+		}
+	}
+}
+
+func TestRunsInTransaction(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want bool
+	}{
+		{"SELECT * FROM roads", true},
+		{"UPDATE roads SET name = 'Main' WHERE gid = 1", true},
+		{"CREATE INDEX roads_geom_idx ON roads USING gist (geom)", true},
+		{"SELECT 'vacuum' AS word", true},
+		{"VACUUM ANALYZE roads", false},
+		{"CREATE INDEX CONCURRENTLY roads_geom_idx ON roads USING gist (geom)", false},
+		{"CREATE DATABASE scratch", false},
+		{"ALTER SYSTEM SET work_mem = '64MB'", false},
+		{"ALTER TABLE roads ADD COLUMN lanes int; VACUUM roads", false},
+	}
+	for _, tt := range tests {
+		if got := RunsInTransaction(tt.sql); got != tt.want {
+			t.Errorf("RunsInTransaction(%q) = %v, want %v", tt.sql, got, tt.want)
+		}
+	}
+}
+
+func TestStatementClassIsMutating(t *testing.T) {
+	if StatementRead.IsMutating() {
+		t.Error("read statements should not be mutating")
+	}
+	for _, c := range []StatementClass{StatementWrite, StatementDDL, StatementUnknown} {
+		if !c.IsMutating() {
+			t.Errorf("%s statements should be mutating", c)
+		}
+	}
+}
+
+func TestIsPlannable(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want bool
+	}{
+		{"SELECT * FROM roads", true},
+		{"WITH r AS (SELECT 1) SELECT * FROM r", true},
+		{"INSERT INTO roads (name) VALUES ('Main')", true},
+		{"update roads set name = 'Main'", true},
+		{"DELETE FROM roads", true},
+		{"MERGE INTO roads USING closures ON true WHEN MATCHED THEN DELETE", true},
+		{"CREATE TABLE roads (gid int)", false},
+		{"ALTER TABLE roads ADD COLUMN lanes int", false},
+		{"DROP TABLE roads", false},
+		{"TRUNCATE roads", false},
+		{"GRANT SELECT ON roads TO reader", false},
+		{"CREATE INDEX ON roads (name)", false},
+		{"SET search_path = public", false},
+		{"SHOW search_path", false},
+		{"DO $$ BEGIN END $$", false},
+		{"SELECT 1; DROP TABLE roads", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := IsPlannable(tt.sql); got != tt.want {
+			t.Errorf("IsPlannable(%q) = %v, want %v", tt.sql, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// aggregateFunctions are the aggregates whose calls make a query without
+// GROUP BY return a single row
+var aggregateFunctions = map[string]bool{
+	"count": true, "sum": true, "avg": true, "min": true, "max": true,
+	"bool_and": true, "bool_or": true, "every": true, "bit_and": true, "bit_or": true,
+	"string_agg": true, "array_agg": true, "json_agg": true, "jsonb_agg": true,
+	"json_object_agg": true, "jsonb_object_agg": true,
+	"stddev": true, "stddev_pop": true, "stddev_samp": true,
+	"variance": true, "var_pop": true, "var_samp": true,
+	"corr": true, "covar_pop": true, "covar_samp": true,
+	"percentile_cont": true, "percentile_disc": true, "mode": true,
+	"st_extent": true, "st_3dextent": true, "st_union": true, "st_collect": true,
+	"st_memunion": true, "st_makeline": true, "st_polygonize": true, "st_summarystatsagg": true,
+}
+
+// RowCount is how many rows a query returns
+type RowCount struct {
+	Rows      int
+	Estimated bool // Counting stopped at the cap; Rows is the planner's estimate
+}
+
+// CountRows counts the rows the query returns, in a read-only transaction
+// bound by the statement timeout of ctx. A query of aggregates without
+// GROUP BY returns one row and is not run. Counting stops after limit
+// rows, beyond which the planner's estimate is returned instead, and the
+// query's own ORDER BY is dropped since it does not change the count.
+// query is a single statement.
+func CountRows(ctx context.Context, db *sql.DB, query string, limit int, args ...any) (RowCount, error) {
+	if ReturnsOneRow(query) {
+		return RowCount{Rows: 1}, nil
+	}
+
+	tx, err := BeginTx(ctx, db, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return RowCount{}, err
+	}
+	defer tx.Rollback()
+
+	var count int
+	countQuery := fmt.Sprintf("SELECT COUNT(*) FROM (SELECT 1 FROM (%s) AS count_query LIMIT %d) AS capped", countedQuery(query), limit+1)
+	if err := tx.QueryRowContext(ctx, countQuery, args...).Scan(&count); err != nil {
+		return RowCount{}, err
+	}
+	if count <= limit {
+		return RowCount{Rows: count}, nil
+	}
+
+	var plan []byte
+	if err := tx.QueryRowContext(ctx, "EXPLAIN (FORMAT JSON) "+query, args...).Scan(&plan); err != nil {
+		return RowCount{}, err
+	}
+	explained, err := ParseExplainJSON(plan)
+	if err != nil {
+		return RowCount{}, err
+	}
+	// The estimate can be far off; it is never below what was counted
+	return RowCount{Rows: max(int(explained.Plan.PlanRows), count), Estimated: true}, nil
+}
+
+// ReturnsOneRow reports whether the last statement of sql always returns
+// a single row: a SELECT of aggregates, such as COUNT(*) or ST_Extent,
+// without GROUP BY, HAVING, window functions or set operations
+func ReturnsOneRow(sql string) bool {
+	tokens := LexSQL(sql)
+	start, end := lastStatement(tokens)
+	if start < 0 || ClassifyStatement(sql) != StatementRead {
+		return false
+	}
+	tokens = tokens[start:end]
+
+	for _, word := range topLevelWords(tokens) {
+		switch word {
+		case "group", "having", "union", "intersect", "except", "limit", "offset", "fetch":
+			return false
+		}
+	}
+
+	// The select list of the main SELECT, outside the WITH queries
+	depth, selectAt := 0, -1
+	for i, tok := range tokens {
+		switch {
+		case tok.Kind == TokenPunctuation && tok.Text == "(":
+			depth++
+		case tok.Kind == TokenPunctuation && tok.Text == ")":
+			depth--
+		case depth == 0 && tok.Kind == TokenKeyword && strings.EqualFold(tok.Text, "select"):
+			selectAt = i
+		}
+		if selectAt >= 0 {
+			break
+		}
+	}
+	if selectAt < 0 {
+		return false
+	}
+
+	// Aggregate calls outside subqueries; any window function (OVER) makes
+	// the aggregates run per row
+	aggregate := false
+	var subquery []bool // Whether each open parenthesis holds a subquery
+	prev := ""
+	for _, tok := range tokens[selectAt+1:] {
+		if tok.Kind == TokenWhitespace || tok.Kind == TokenComment {
+			continue
+		}
+		word := strings.ToLower(tok.Text)
+		inSubquery := false
+		for _, s := range subquery {
+			inSubquery = inSubquery || s
+		}
+		switch {
+		case tok.Kind == TokenPunctuation && tok.Text == "(":
+			subquery = append(subquery, false)
+			if !inSubquery && aggregateFunctions[prev] {
+				aggregate = true
+			}
+		case tok.Kind == TokenPunctuation && tok.Text == ")":
+			if len(subquery) > 0 {
+				subquery = subquery[:len(subquery)-1]
+			}
+		case word == "select" && prev == "(":
+			subquery[len(subquery)-1] = true
+		case word == "over" && !inSubquery:
+			return false
+		case word == "from" && len(subquery) == 0:
+			return aggregate
+		}
+		prev = word
+	}
+	return aggregate
+}
+
+// countedQuery returns sql to count the rows of as a subquery: without
+// trailing semicolons and comments, which would break out of it, and
+// without the ORDER BY ending its last statement unless LIMIT, OFFSET,
+// FETCH or FOR follow and depend on it
+func countedQuery(sql string) string {
+	tokens := LexSQL(sql)
+	start, end := lastStatement(tokens)
+	if start < 0 {
+		return sql
+	}
+
+	cut, depth := end, 0
+	for i := start; i < end; i++ {
+		tok := tokens[i]
+		switch {
+		case tok.Kind == TokenPunctuation && tok.Text == "(":
+			depth++
+		case tok.Kind == TokenPunctuation && tok.Text == ")":
+			depth--
+		case depth == 0 && tok.Kind == TokenKeyword:
+			switch strings.ToLower(tok.Text) {
+			case "order":
+				cut = i
+			case "limit", "offset", "fetch", "for":
+				cut = end
+			}
+		}
+	}
+
+	var b strings.Builder
+	for _, tok := range tokens[:cut] {
+		b.WriteString(tok.Text)
+	}
+	return strings.TrimRight(b.String(), " \t\n")
+}
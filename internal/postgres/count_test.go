@@ -0,0 +1,46 @@
+package postgres
+
+import "testing"
+
+func TestReturnsOneRow(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want bool
+	}{
+		{`SELECT COUNT(*) AS count FROM "public"."roads"`, true},
+		{"SELECT round(avg(length)::numeric, 2), max(speed) FROM roads WHERE kind = 'primary'", true},
+		{"SELECT ST_Extent(geom) FROM parcels;", true},
+		{"WITH r AS (SELECT * FROM roads GROUP BY kind) SELECT count(*) FROM r", true},
+		{"SELECT kind, count(*) FROM roads GROUP BY kind", false},
+		{"SELECT count(*) FROM roads HAVING count(*) > 100", false},
+		{"SELECT name, count(*) OVER (PARTITION BY kind) FROM roads", false},
+		{"SELECT name, (SELECT count(*) FROM parcels p WHERE p.road = r.gid) FROM roads r", false},
+		{"SELECT count(*) FROM roads UNION ALL SELECT count(*) FROM rails", false},
+		{"SELECT * FROM roads", false},
+		{"SELECT generate_series(1, 10)", false},
+		{"SHOW search_path", false},
+	}
+	for _, tt := range tests {
+		if got := ReturnsOneRow(tt.sql); got != tt.want {
+			t.Errorf("ReturnsOneRow(%q) = %v, want %v", tt.sql, got, tt.want)
+		}
+	}
+}
+
+func TestCountedQuery(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want string
+	}{
+		{"SELECT * FROM roads ORDER BY name", "SELECT * FROM roads"},
+		{"SELECT * FROM roads ORDER BY name LIMIT 10", "SELECT * FROM roads ORDER BY name LIMIT 10"},
+		{"SELECT * FROM roads; -- everything", "SELECT * FROM roads"},
+		{"SELECT string_agg(name, ',' ORDER BY name) FROM roads", "SELECT string_agg(name, ',' ORDER BY name) FROM roads"},
+		{"SELECT rank() OVER (ORDER BY length) FROM roads\nORDER BY 1 DESC\n", "SELECT rank() OVER (ORDER BY length) FROM roads"},
+	}
+	for _, tt := range tests {
+		if got := countedQuery(tt.sql); got != tt.want {
+			t.Errorf("countedQuery(%q) = %q, want %q", tt.sql, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync/atomic"
+)
+
+// cursorSeq makes cursor names unique within the process
+var cursorSeq atomic.Int64
+
+// ResultCursor streams the rows of a query through a server-side cursor.
+// The cursor lives in its own read-only transaction, so it holds one pooled
+// connection until Close is called.
+type ResultCursor struct {
+	tx      *sql.Tx
+	name    string
+	columns []string
+	types   []string
+	done    bool
+}
+
+// OpenCursor declares a cursor for query in a new read-only transaction.
+// Only statements valid in DECLARE (SELECT, VALUES, ...) are accepted.
+// ctx bounds the DECLARE only; the transaction outlives it until Close.
+// Its statement timeout bounds the DECLARE and every FETCH.
+// args are bound to the query's $n parameters.
+func OpenCursor(ctx context.Context, db *sql.DB, query string, args ...any) (*ResultCursor, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec("SET TRANSACTION READ ONLY"); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := setLocalTimeout(ctx, tx); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	name := fmt.Sprintf("pgai_cursor_%d", cursorSeq.Add(1))
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf("DECLARE %s NO SCROLL CURSOR FOR %s", name, query), args...); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	return &ResultCursor{tx: tx, name: name}, nil
+}
+
+// Columns returns the result column names (available after the first Fetch)
+func (c *ResultCursor) Columns() []string {
+	return c.columns
+}
+
+// ColumnTypes returns the database type name of each column, e.g. "JSONB"
+// (available after the first Fetch)
+func (c *ResultCursor) ColumnTypes() []string {
+	return c.types
+}
+
+// ColumnTypeNames returns the database type name of each result column,
+// upper case as reported by the driver; unknown types are empty
+func ColumnTypeNames(rows *sql.Rows) []string {
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		return nil
+	}
+	names := make([]string, len(types))
+	for i, t := range types {
+		names[i] = t.DatabaseTypeName()
+	}
+	return names
+}
+
+// Done reports whether the cursor has been exhausted or closed
+func (c *ResultCursor) Done() bool {
+	return c.done
+}
+
+// Fetch returns up to n further rows as raw scanned values. When fewer than
+// n rows come back the cursor is exhausted and closed. Any error, including
+// cancellation through ctx, also closes it.
+func (c *ResultCursor) Fetch(ctx context.Context, n int) ([][]interface{}, error) {
+	if c.done {
+		return nil, nil
+	}
+
+	results, err := c.fetch(ctx, n)
+	if err != nil || len(results) < n {
+		c.Close()
+	}
+	return results, err
+}
+
+// fetch runs a single FETCH and scans its rows
+func (c *ResultCursor) fetch(ctx context.Context, n int) ([][]interface{}, error) {
+	rows, err := c.tx.QueryContext(ctx, fmt.Sprintf("FETCH FORWARD %d FROM %s", n, c.name))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if c.columns == nil {
+		if c.columns, err = rows.Columns(); err != nil {
+			return nil, err
+		}
+		c.types = ColumnTypeNames(rows)
+	}
+
+	var results [][]interface{}
+	for rows.Next() {
+		values := make([]interface{}, len(c.columns))
+		valuePtrs := make([]interface{}, len(c.columns))
+		for i := range values {
+			valuePtrs[i] = &values[i]
+		}
+		if err := rows.Scan(valuePtrs...); err != nil {
+			return nil, err
+		}
+		results = append(results, values)
+	}
+	return results, rows.Err()
+}
+
+// Close closes the cursor and ends its transaction, releasing the connection
+func (c *ResultCursor) Close() error {
+	if c.done {
+		return nil
+	}
+	c.done = true
+	// Rolling back the read-only transaction also closes the cursor
+	return c.tx.Rollback()
+}
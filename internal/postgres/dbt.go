@@ -0,0 +1,220 @@
+package postgres
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/kartoza/kartoza-pg-ai/internal/config"
+)
+
+// DbtDocs are the descriptions and accepted values a dbt project documents
+// for its models, seeds, snapshots and sources, keyed by lower-cased
+// "schema.relation"
+type DbtDocs map[string]*DbtRelation
+
+// DbtRelation documents one relation built or read by a dbt project
+type DbtRelation struct {
+	Description string
+	Columns     map[string]*DbtColumn // Keyed by lower-cased column name
+}
+
+// DbtColumn documents one column of a relation
+type DbtColumn struct {
+	Description    string
+	AcceptedValues []string // From an accepted_values test
+}
+
+// dbtNode is the part of a manifest.json node or source read here
+type dbtNode struct {
+	ResourceType string `json:"resource_type"`
+	Schema       string `json:"schema"`
+	Name         string `json:"name"`
+	Alias        string `json:"alias"`      // Relation of a model, when not its name
+	Identifier   string `json:"identifier"` // Relation of a source, when not its name
+	Description  string `json:"description"`
+	Columns      map[string]struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+	} `json:"columns"`
+
+	// Tests only
+	TestMetadata *struct {
+		Name   string `json:"name"`
+		Kwargs struct {
+			ColumnName string `json:"column_name"`
+			Values     []any  `json:"values"`
+		} `json:"kwargs"`
+	} `json:"test_metadata"`
+	ColumnName   string `json:"column_name"`
+	AttachedNode string `json:"attached_node"`
+	DependsOn    struct {
+		Nodes []string `json:"nodes"`
+	} `json:"depends_on"`
+}
+
+// relation returns the lower-cased "schema.relation" a node builds or reads
+func (n *dbtNode) relation() string {
+	name := n.Name
+	if n.Alias != "" {
+		name = n.Alias
+	}
+	if n.Identifier != "" {
+		name = n.Identifier
+	}
+	return strings.ToLower(n.Schema + "." + name)
+}
+
+// LoadDbtManifest reads the documentation of a dbt project from its
+// compiled manifest.json (target/manifest.json after dbt docs generate or
+// dbt compile). The path may start with ~.
+func LoadDbtManifest(path string) (DbtDocs, error) {
+	data, err := os.ReadFile(expandHome(path))
+	if err != nil {
+		return nil, err
+	}
+	var manifest struct {
+		Nodes   map[string]*dbtNode `json:"nodes"`
+		Sources map[string]*dbtNode `json:"sources"`
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("%s is not a dbt manifest: %w", path, err)
+	}
+
+	docs := make(DbtDocs)
+	relations := make(map[string]string) // Unique ID to relation
+	add := func(id string, n *dbtNode) {
+		r := &DbtRelation{Description: strings.TrimSpace(n.Description), Columns: make(map[string]*DbtColumn)}
+		for key, c := range n.Columns {
+			name := c.Name
+			if name == "" {
+				name = key
+			}
+			r.Columns[strings.ToLower(name)] = &DbtColumn{Description: strings.TrimSpace(c.Description)}
+		}
+		docs[n.relation()] = r
+		relations[id] = n.relation()
+	}
+	for id, n := range manifest.Nodes {
+		switch n.ResourceType {
+		case "model", "seed", "snapshot":
+			add(id, n)
+		}
+	}
+	for id, n := range manifest.Sources {
+		add(id, n)
+	}
+
+	for _, n := range manifest.Nodes {
+		if n.ResourceType != "test" || n.TestMetadata == nil || n.TestMetadata.Name != "accepted_values" {
+			continue
+		}
+		tested := n.AttachedNode
+		if tested == "" && len(n.DependsOn.Nodes) > 0 {
+			tested = n.DependsOn.Nodes[len(n.DependsOn.Nodes)-1]
+		}
+		column := n.ColumnName
+		if column == "" {
+			column = n.TestMetadata.Kwargs.ColumnName
+		}
+		r := docs[relations[tested]]
+		if r == nil || column == "" {
+			continue
+		}
+		c := r.Columns[strings.ToLower(column)]
+		if c == nil {
+			c = &DbtColumn{}
+			r.Columns[strings.ToLower(column)] = c
+		}
+		for _, v := range n.TestMetadata.Kwargs.Values {
+			c.AcceptedValues = append(c.AcceptedValues, fmt.Sprint(v))
+		}
+	}
+	return docs, nil
+}
+
+// Apply documents the tables and views of cache from the dbt project:
+// descriptions fill in missing comments, as the database's own comments
+// win, and accepted values are taken over. It returns how many relations
+// were documented.
+func (d DbtDocs) Apply(cache *config.SchemaCache) int {
+	documented := 0
+	apply := func(schema, name string, comment *string, columns []config.ColumnInfo) {
+		r := d[strings.ToLower(schema+"."+name)]
+		if r == nil {
+			return
+		}
+		documented++
+		if *comment == "" {
+			*comment = r.Description
+		}
+		for i := range columns {
+			c := r.Columns[strings.ToLower(columns[i].Name)]
+			if c == nil {
+				continue
+			}
+			if columns[i].Comment == "" {
+				columns[i].Comment = c.Description
+			}
+			if len(c.AcceptedValues) > 0 {
+				columns[i].AcceptedValues = c.AcceptedValues
+			}
+		}
+	}
+	for i := range cache.Tables {
+		t := &cache.Tables[i]
+		apply(t.Schema, t.Name, &t.Comment, t.Columns)
+	}
+	for i := range cache.Views {
+		v := &cache.Views[i]
+		apply(v.Schema, v.Name, &v.Comment, v.Columns)
+	}
+	return documented
+}
+
+// commentValuesPattern finds a list of values in a column comment, written
+// as "Values: a, b, c", "Accepted values: a | b" or "One of: 'a', 'b'"
+var commentValuesPattern = regexp.MustCompile(`(?im)(?:^|[.;(]\s*)(?:accepted values|allowed values|values|one of)\s*:\s*([^\n)]+)`)
+
+// CommentValues returns the values a column comment says the column holds,
+// nil when it lists none
+func CommentValues(comment string) []string {
+	m := commentValuesPattern.FindStringSubmatch(comment)
+	if m == nil {
+		return nil
+	}
+	list := strings.TrimSpace(m[1])
+	list = strings.TrimSuffix(list, ".")
+	sep := ","
+	if strings.Contains(list, "|") {
+		sep = "|"
+	}
+	var values []string
+	for _, v := range strings.Split(list, sep) {
+		v = strings.Trim(strings.TrimSpace(v), `'"`)
+		if v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// applyCommentValues takes the accepted values of columns whose comments
+// list them, following the COMMENT ON convention of CommentValues
+func applyCommentValues(cache *config.SchemaCache) {
+	apply := func(columns []config.ColumnInfo) {
+		for i := range columns {
+			if columns[i].AcceptedValues == nil {
+				columns[i].AcceptedValues = CommentValues(columns[i].Comment)
+			}
+		}
+	}
+	for i := range cache.Tables {
+		apply(cache.Tables[i].Columns)
+	}
+	for i := range cache.Views {
+		apply(cache.Views[i].Columns)
+	}
+}
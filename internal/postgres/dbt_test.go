@@ -0,0 +1,164 @@
+package postgres
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/kartoza/kartoza-pg-ai/internal/config"
+)
+
+const testManifest = `{
+  "nodes": {
+    "model.shop.orders": {
+      "resource_type": "model",
+      "schema": "analytics",
+      "name": "orders",
+      "description": "One row per order placed in the web shop",
+      "columns": {
+        "status": {"name": "status", "description": "Where the order is in fulfilment"},
+        "total": {"name": "total", "description": "Order total in rand"}
+      }
+    },
+    "model.shop.stg_customers": {
+      "resource_type": "model",
+      "schema": "staging",
+      "name": "stg_customers",
+      "alias": "customers",
+      "description": "Customers cleaned up from the CRM"
+    },
+    "test.shop.accepted_values_orders_status": {
+      "resource_type": "test",
+      "column_name": "status",
+      "attached_node": "model.shop.orders",
+      "test_metadata": {"name": "accepted_values", "kwargs": {"column_name": "status", "values": ["placed", "shipped", "returned"]}}
+    },
+    "test.shop.accepted_values_orders_priority": {
+      "resource_type": "test",
+      "depends_on": {"nodes": ["model.shop.orders"]},
+      "test_metadata": {"name": "accepted_values", "kwargs": {"column_name": "priority", "values": [1, 2, 3]}}
+    },
+    "test.shop.not_null_orders_total": {
+      "resource_type": "test",
+      "column_name": "total",
+      "attached_node": "model.shop.orders",
+      "test_metadata": {"name": "not_null", "kwargs": {"column_name": "total"}}
+    }
+  },
+  "sources": {
+    "source.shop.crm.accounts": {
+      "resource_type": "source",
+      "schema": "raw",
+      "name": "accounts",
+      "identifier": "crm_accounts",
+      "description": "Accounts as exported from the CRM"
+    }
+  }
+}`
+
+func TestLoadDbtManifest(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "manifest.json")
+	if err := os.WriteFile(path, []byte(testManifest), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	docs, err := LoadDbtManifest(path)
+	if err != nil {
+		t.Fatalf("LoadDbtManifest failed: %v", err)
+	}
+	for _, relation := range []string{"analytics.orders", "staging.customers", "raw.crm_accounts"} {
+		if docs[relation] == nil {
+			t.Errorf("%s not documented; got %v", relation, docs)
+		}
+	}
+
+	orders := docs["analytics.orders"]
+	if got := orders.Columns["status"].AcceptedValues; !reflect.DeepEqual(got, []string{"placed", "shipped", "returned"}) {
+		t.Errorf("status accepted values = %v", got)
+	}
+	if got := orders.Columns["priority"].AcceptedValues; !reflect.DeepEqual(got, []string{"1", "2", "3"}) {
+		t.Errorf("priority accepted values = %v", got)
+	}
+	if got := orders.Columns["total"].AcceptedValues; got != nil {
+		t.Errorf("a not_null test must not give accepted values: %v", got)
+	}
+
+	cache := &config.SchemaCache{
+		Tables: []config.TableInfo{{
+			Schema:  "analytics",
+			Name:    "orders",
+			Comment: "Orders, from the database",
+			Columns: []config.ColumnInfo{
+				{Name: "status", DataType: "text"},
+				{Name: "total", DataType: "numeric", Comment: "Total including VAT"},
+				{Name: "placed_at", DataType: "timestamptz"},
+			},
+		}},
+		Views: []config.ViewInfo{{Schema: "staging", Name: "customers"}},
+	}
+	if n := docs.Apply(cache); n != 2 {
+		t.Errorf("Apply documented %d relations, want 2", n)
+	}
+	table := cache.Tables[0]
+	if table.Comment != "Orders, from the database" {
+		t.Errorf("the database's comment should win, got %q", table.Comment)
+	}
+	if table.Columns[0].Comment != "Where the order is in fulfilment" || len(table.Columns[0].AcceptedValues) != 3 {
+		t.Errorf("status not documented: %+v", table.Columns[0])
+	}
+	if table.Columns[1].Comment != "Total including VAT" {
+		t.Errorf("the database's column comment should win, got %q", table.Columns[1].Comment)
+	}
+	if cache.Views[0].Comment != "Customers cleaned up from the CRM" {
+		t.Errorf("view comment = %q", cache.Views[0].Comment)
+	}
+}
+
+func TestLoadDbtManifestErrors(t *testing.T) {
+	if _, err := LoadDbtManifest(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("a missing manifest should fail")
+	}
+	path := filepath.Join(t.TempDir(), "manifest.json")
+	if err := os.WriteFile(path, []byte("not json"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadDbtManifest(path); err == nil {
+		t.Error("a manifest that is not JSON should fail")
+	}
+}
+
+func TestCommentValues(t *testing.T) {
+	tests := []struct {
+		comment string
+		want    []string
+	}{
+		{"Status of the order. Values: placed, shipped, returned", []string{"placed", "shipped", "returned"}},
+		{"Accepted values: 'low' | 'medium' | 'high'.", []string{"low", "medium", "high"}},
+		{"Road class (one of: primary, secondary)", []string{"primary", "secondary"}},
+		{"allowed values: yes, no", []string{"yes", "no"}},
+		{"Number of values recorded", nil},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		if got := CommentValues(tt.comment); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("CommentValues(%q) = %v, want %v", tt.comment, got, tt.want)
+		}
+	}
+}
+
+func TestApplyCommentValues(t *testing.T) {
+	cache := &config.SchemaCache{
+		Tables: []config.TableInfo{{Name: "roads", Columns: []config.ColumnInfo{
+			{Name: "class", Comment: "Values: primary, secondary"},
+			{Name: "surface", Comment: "Values: tar, gravel", AcceptedValues: []string{"paved", "unpaved"}},
+		}}},
+	}
+	applyCommentValues(cache)
+	columns := cache.Tables[0].Columns
+	if !reflect.DeepEqual(columns[0].AcceptedValues, []string{"primary", "secondary"}) {
+		t.Errorf("class accepted values = %v", columns[0].AcceptedValues)
+	}
+	if !reflect.DeepEqual(columns[1].AcceptedValues, []string{"paved", "unpaved"}) {
+		t.Errorf("values from dbt should win over the comment, got %v", columns[1].AcceptedValues)
+	}
+}
@@ -0,0 +1,172 @@
+package postgres
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ChangeKind is how a row differs between two results
+type ChangeKind int
+
+const (
+	RowAdded ChangeKind = iota
+	RowRemoved
+	RowChanged
+)
+
+// RowChange is a row that differs between two results. Values are in the
+// column order of the later result; Before is nil for added rows and
+// After for removed ones.
+type RowChange struct {
+	Kind   ChangeKind
+	Before []string
+	After  []string
+}
+
+// RowDiff holds the rows that differ between an earlier and a later
+// result with the same columns
+type RowDiff struct {
+	Columns   []string
+	Key       []string // Columns rows are matched by; nil matches whole rows
+	Changes   []RowChange
+	Unchanged int
+}
+
+// Count returns how many rows differ in a way
+func (d *RowDiff) Count(kind ChangeKind) int {
+	n := 0
+	for _, c := range d.Changes {
+		if c.Kind == kind {
+			n++
+		}
+	}
+	return n
+}
+
+// Same reports whether no rows differ
+func (d *RowDiff) Same() bool {
+	return len(d.Changes) == 0
+}
+
+// DiffRows compares the rows of two results, matching rows by the key
+// columns (whole rows when key is nil or names no column). Rows whose key
+// is in only one result are added or removed; rows with the same key and
+// different values are changed. Rows sharing a key are paired in order.
+// The results must have the same columns, in any order.
+func DiffRows(beforeColumns []string, before [][]string, afterColumns []string, after [][]string, key []string) (*RowDiff, error) {
+	index := make(map[string]int, len(beforeColumns))
+	for i, col := range beforeColumns {
+		index[col] = i
+	}
+	beforeIdx := make([]int, len(afterColumns))
+	for i, col := range afterColumns {
+		j, ok := index[col]
+		if !ok || len(beforeColumns) != len(afterColumns) {
+			return nil, fmt.Errorf("columns differ: %s vs %s",
+				strings.Join(beforeColumns, ", "), strings.Join(afterColumns, ", "))
+		}
+		beforeIdx[i] = j
+	}
+
+	var keyIdx []int // In the later result's column order
+	for _, k := range key {
+		for i, col := range afterColumns {
+			if col == k {
+				keyIdx = append(keyIdx, i)
+			}
+		}
+	}
+	if len(keyIdx) == 0 {
+		key = nil
+		for i := range afterColumns {
+			keyIdx = append(keyIdx, i)
+		}
+	}
+	keyOf := func(row []string) string {
+		parts := make([]string, len(keyIdx))
+		for i, idx := range keyIdx {
+			if idx < len(row) {
+				parts[i] = row[idx]
+			}
+		}
+		return strings.Join(parts, "\x00")
+	}
+
+	// Earlier rows, reordered to the later columns and grouped by key
+	earlier := make(map[string][][]string)
+	var order []string // Keys in the order earlier rows came
+	for _, row := range before {
+		aligned := make([]string, len(beforeIdx))
+		for i, j := range beforeIdx {
+			if j < len(row) {
+				aligned[i] = row[j]
+			}
+		}
+		k := keyOf(aligned)
+		if _, ok := earlier[k]; !ok {
+			order = append(order, k)
+		}
+		earlier[k] = append(earlier[k], aligned)
+	}
+
+	d := &RowDiff{Columns: afterColumns, Key: key}
+	for _, row := range after {
+		k := keyOf(row)
+		matches := earlier[k]
+		if len(matches) == 0 {
+			d.Changes = append(d.Changes, RowChange{Kind: RowAdded, After: row})
+			continue
+		}
+		earlier[k] = matches[1:]
+		if sameRow(matches[0], row) {
+			d.Unchanged++
+		} else {
+			d.Changes = append(d.Changes, RowChange{Kind: RowChanged, Before: matches[0], After: row})
+		}
+	}
+	for _, k := range order {
+		for _, row := range earlier[k] {
+			d.Changes = append(d.Changes, RowChange{Kind: RowRemoved, Before: row})
+		}
+	}
+	return d, nil
+}
+
+// sameRow reports whether two rows hold the same values
+func sameRow(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Cell is what column i of a change shows: the value added or removed,
+// or "before → after" for a changed value
+func (c RowChange) Cell(i int) string {
+	value := func(row []string) string {
+		if i < len(row) {
+			return row[i]
+		}
+		return ""
+	}
+	switch c.Kind {
+	case RowAdded:
+		return value(c.After)
+	case RowRemoved:
+		return value(c.Before)
+	}
+	if value(c.Before) != value(c.After) {
+		return value(c.Before) + " → " + value(c.After)
+	}
+	return value(c.After)
+}
+
+// CellChanged reports whether column i of a changed row differs
+func (c RowChange) CellChanged(i int) bool {
+	return c.Kind == RowChanged && i < len(c.Before) && i < len(c.After) && c.Before[i] != c.After[i]
+}
@@ -0,0 +1,65 @@
+package postgres
+
+import (
+	"testing"
+)
+
+func TestDiffRowsByKey(t *testing.T) {
+	before := [][]string{{"1", "alice"}, {"2", "bob"}, {"3", "carol"}}
+	// Columns reordered; bob renamed, carol removed and dave added
+	after := [][]string{{"alice", "1"}, {"robert", "2"}, {"dave", "4"}}
+
+	d, err := DiffRows([]string{"id", "name"}, before, []string{"name", "id"}, after, []string{"id"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Unchanged != 1 || d.Count(RowAdded) != 1 || d.Count(RowRemoved) != 1 || d.Count(RowChanged) != 1 {
+		t.Fatalf("unexpected diff %+v", d)
+	}
+	for _, c := range d.Changes {
+		switch c.Kind {
+		case RowChanged:
+			if c.Cell(0) != "bob → robert" || !c.CellChanged(0) || c.CellChanged(1) || c.Cell(1) != "2" {
+				t.Errorf("unexpected change %+v", c)
+			}
+		case RowRemoved:
+			if c.Cell(0) != "carol" || c.Cell(1) != "3" {
+				t.Errorf("removed row should be in the later column order, got %v", c.Before)
+			}
+		case RowAdded:
+			if c.Cell(0) != "dave" {
+				t.Errorf("unexpected added row %v", c.After)
+			}
+		}
+	}
+}
+
+func TestDiffRowsWholeRows(t *testing.T) {
+	before := [][]string{{"a"}, {"a"}, {"b"}}
+	after := [][]string{{"a"}, {"b"}, {"c"}}
+
+	// An unknown key falls back to matching whole rows
+	d, err := DiffRows([]string{"v"}, before, []string{"v"}, after, []string{"missing"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Key != nil {
+		t.Errorf("expected no key, got %v", d.Key)
+	}
+	if d.Unchanged != 2 || d.Count(RowAdded) != 1 || d.Count(RowRemoved) != 1 || d.Same() {
+		t.Errorf("unexpected diff %+v", d)
+	}
+
+	if d, _ := DiffRows([]string{"v"}, before, []string{"v"}, before, nil); !d.Same() {
+		t.Errorf("identical rows should not differ, got %+v", d)
+	}
+}
+
+func TestDiffRowsNeedsSameColumns(t *testing.T) {
+	if _, err := DiffRows([]string{"a", "b"}, nil, []string{"a", "c"}, nil, nil); err == nil {
+		t.Error("expected an error for different columns")
+	}
+	if _, err := DiffRows([]string{"a"}, nil, []string{"a", "b"}, nil, nil); err == nil {
+		t.Error("expected an error for a different number of columns")
+	}
+}
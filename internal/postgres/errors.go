@@ -0,0 +1,45 @@
+package postgres
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// repairableErrorClasses are the SQLSTATE classes of errors caused by the
+// text of a statement rather than by the connection or the server
+var repairableErrorClasses = map[pq.ErrorClass]bool{
+	"0A": true, // Feature not supported
+	"21": true, // Cardinality violation
+	"22": true, // Data exception
+	"42": true, // Syntax error or access rule violation
+}
+
+// IsStatementTimeout reports whether err is PostgreSQL cancelling a
+// statement that ran longer than statement_timeout
+func IsStatementTimeout(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "57014" && strings.Contains(pqErr.Message, "statement timeout")
+}
+
+// StatementError returns the server's message for an error caused by the
+// SQL itself, such as a misspelt column, with its hint when there is one.
+// Errors found in the SQL before it was run, which have a StatementError
+// method, count too. ok is false for connection failures, cancellations and
+// other errors a different statement would not avoid.
+func StatementError(err error) (message string, ok bool) {
+	var checked interface{ StatementError() string }
+	if errors.As(err, &checked) {
+		return checked.StatementError(), true
+	}
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) || !repairableErrorClasses[pqErr.Code.Class()] {
+		return "", false
+	}
+	message = pqErr.Message
+	if pqErr.Hint != "" {
+		message += " (hint: " + pqErr.Hint + ")"
+	}
+	return message, true
+}
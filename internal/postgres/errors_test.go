@@ -0,0 +1,65 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+func TestIsStatementTimeout(t *testing.T) {
+	timeout := &pq.Error{Code: "57014", Message: "canceling statement due to statement timeout"}
+	if !IsStatementTimeout(fmt.Errorf("query failed: %w", timeout)) {
+		t.Error("expected a statement timeout")
+	}
+	for _, err := range []error{
+		&pq.Error{Code: "57014", Message: "canceling statement due to user request"},
+		context.DeadlineExceeded,
+		nil,
+	} {
+		if IsStatementTimeout(err) {
+			t.Errorf("%v is not a statement timeout", err)
+		}
+	}
+
+	ctx := WithStatementTimeout(context.Background(), time.Minute)
+	if got := StatementTimeout(ctx); got != time.Minute {
+		t.Errorf("StatementTimeout() = %s, want 1m", got)
+	}
+	if got := StatementTimeout(context.Background()); got != 0 {
+		t.Errorf("StatementTimeout() = %s without a timeout, want 0", got)
+	}
+}
+
+func TestStatementError(t *testing.T) {
+	undefined := &pq.Error{Code: "42703", Message: `column "nmae" does not exist`, Hint: `Perhaps you meant "name".`}
+	message, ok := StatementError(fmt.Errorf("query failed: %w", undefined))
+	if !ok || message != `column "nmae" does not exist (hint: Perhaps you meant "name".)` {
+		t.Errorf("unexpected result %q, %v", message, ok)
+	}
+	message, ok = StatementError(fmt.Errorf("%w\nSQL: SELECT nmae FROM customers", checkedError{}))
+	if !ok || message != `column "nmae" does not exist in public.customers` {
+		t.Errorf("unexpected result %q, %v for an error found before running", message, ok)
+	}
+
+	for _, err := range []error{
+		&pq.Error{Code: "57014", Message: "canceling statement due to user request"},
+		&pq.Error{Code: "08006", Message: "connection failure"},
+		context.DeadlineExceeded,
+	} {
+		if _, ok := StatementError(err); ok {
+			t.Errorf("%v should not be repairable", err)
+		}
+	}
+}
+
+// checkedError is an error found in SQL before it runs
+type checkedError struct{}
+
+func (checkedError) Error() string { return "unknown names" }
+
+func (checkedError) StatementError() string {
+	return `column "nmae" does not exist in public.customers`
+}
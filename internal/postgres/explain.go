@@ -0,0 +1,96 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+)
+
+// Thresholds for flagging a sequential scan as a missing-index candidate
+const (
+	indexCandidateMinRows     = 1000 // Rows the scan must read
+	indexCandidateRemovedFrac = 0.9  // Fraction of those rows the filter must discard
+)
+
+// PlanNode is one node of an EXPLAIN (FORMAT JSON) plan
+type PlanNode struct {
+	NodeType            string      `json:"Node Type"`
+	RelationName        string      `json:"Relation Name"`
+	Schema              string      `json:"Schema"`
+	Alias               string      `json:"Alias"`
+	IndexName           string      `json:"Index Name"`
+	JoinType            string      `json:"Join Type"`
+	Filter              string      `json:"Filter"`
+	IndexCond           string      `json:"Index Cond"`
+	HashCond            string      `json:"Hash Cond"`
+	StartupCost         float64     `json:"Startup Cost"`
+	TotalCost           float64     `json:"Total Cost"`
+	PlanRows            float64     `json:"Plan Rows"`
+	ActualTotalTime     float64     `json:"Actual Total Time"`
+	ActualRows          float64     `json:"Actual Rows"`
+	ActualLoops         float64     `json:"Actual Loops"`
+	RowsRemovedByFilter float64     `json:"Rows Removed by Filter"`
+	Plans               []*PlanNode `json:"Plans"`
+}
+
+// ExplainResult is the parsed output of EXPLAIN (ANALYZE, FORMAT JSON)
+type ExplainResult struct {
+	Plan          *PlanNode `json:"Plan"`
+	PlanningTime  float64   `json:"Planning Time"`
+	ExecutionTime float64   `json:"Execution Time"`
+}
+
+// IsSeqScan reports whether the node reads a whole table sequentially
+func (n *PlanNode) IsSeqScan() bool {
+	return n.NodeType == "Seq Scan" || n.NodeType == "Parallel Seq Scan"
+}
+
+// IsIndexCandidate reports whether the node is a sequential scan whose
+// filter discards most of a large table, which an index could avoid
+func (n *PlanNode) IsIndexCandidate() bool {
+	if !n.IsSeqScan() || n.Filter == "" {
+		return false
+	}
+	loops := n.ActualLoops
+	if loops < 1 {
+		loops = 1
+	}
+	scanned := (n.ActualRows + n.RowsRemovedByFilter) * loops
+	return scanned >= indexCandidateMinRows && n.RowsRemovedByFilter*loops >= scanned*indexCandidateRemovedFrac
+}
+
+// ParseExplainJSON parses the JSON document returned by EXPLAIN (FORMAT JSON)
+func ParseExplainJSON(data []byte) (*ExplainResult, error) {
+	var results []ExplainResult
+	if err := json.Unmarshal(data, &results); err != nil {
+		return nil, fmt.Errorf("invalid EXPLAIN output: %w", err)
+	}
+	if len(results) == 0 || results[0].Plan == nil {
+		return nil, fmt.Errorf("EXPLAIN returned no plan")
+	}
+	return &results[0], nil
+}
+
+// ExplainAnalyze runs EXPLAIN (ANALYZE, FORMAT JSON) for a read-only query.
+// ANALYZE really executes the statement, so mutating SQL is refused and the
+// query runs in a read-only transaction that is always rolled back, bound
+// by the statement timeout of ctx. args are bound to the query's $n
+// parameters.
+func ExplainAnalyze(ctx context.Context, db *sql.DB, query string, args ...any) (*ExplainResult, error) {
+	if class := ClassifyStatement(query); class.IsMutating() {
+		return nil, fmt.Errorf("refusing to EXPLAIN ANALYZE a %s statement", class)
+	}
+
+	tx, err := BeginTx(ctx, db, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var data []byte
+	if err := tx.QueryRowContext(ctx, "EXPLAIN (ANALYZE, FORMAT JSON) "+query, args...).Scan(&data); err != nil {
+		return nil, err
+	}
+	return ParseExplainJSON(data)
+}
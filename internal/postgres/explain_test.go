@@ -0,0 +1,78 @@
+package postgres
+
+import "testing"
+
+func TestParseExplainJSON(t *testing.T) {
+	data := []byte(`[{
+		"Plan": {
+			"Node Type": "Hash Join",
+			"Join Type": "Inner",
+			"Actual Rows": 12,
+			"Actual Loops": 1,
+			"Plans": [
+				{
+					"Node Type": "Seq Scan",
+					"Relation Name": "orders",
+					"Filter": "(status = 'shipped'::text)",
+					"Actual Rows": 12,
+					"Actual Loops": 1,
+					"Rows Removed by Filter": 49988
+				},
+				{
+					"Node Type": "Index Scan",
+					"Relation Name": "customers",
+					"Index Name": "customers_pkey",
+					"Actual Rows": 1,
+					"Actual Loops": 12
+				}
+			]
+		},
+		"Planning Time": 0.2,
+		"Execution Time": 14.5
+	}]`)
+
+	result, err := ParseExplainJSON(data)
+	if err != nil {
+		t.Fatalf("ParseExplainJSON error: %v", err)
+	}
+	if result.ExecutionTime != 14.5 {
+		t.Errorf("ExecutionTime = %v, want 14.5", result.ExecutionTime)
+	}
+	if result.Plan.NodeType != "Hash Join" || len(result.Plan.Plans) != 2 {
+		t.Fatalf("unexpected plan root: %+v", result.Plan)
+	}
+
+	scan := result.Plan.Plans[0]
+	if !scan.IsSeqScan() || !scan.IsIndexCandidate() {
+		t.Errorf("expected selective seq scan on orders to be an index candidate")
+	}
+	if idx := result.Plan.Plans[1]; idx.IsSeqScan() || idx.IsIndexCandidate() {
+		t.Errorf("index scan should not be flagged")
+	}
+}
+
+func TestIsIndexCandidateSmallOrUnfilteredScans(t *testing.T) {
+	tests := []struct {
+		name string
+		node PlanNode
+	}{
+		{"small table", PlanNode{NodeType: "Seq Scan", Filter: "(a = 1)", ActualRows: 1, RowsRemovedByFilter: 50, ActualLoops: 1}},
+		{"no filter", PlanNode{NodeType: "Seq Scan", ActualRows: 100000, ActualLoops: 1}},
+		{"unselective filter", PlanNode{NodeType: "Seq Scan", Filter: "(a > 1)", ActualRows: 9000, RowsRemovedByFilter: 1000, ActualLoops: 1}},
+	}
+
+	for _, tt := range tests {
+		if tt.node.IsIndexCandidate() {
+			t.Errorf("%s: should not be an index candidate", tt.name)
+		}
+	}
+}
+
+func TestParseExplainJSONInvalid(t *testing.T) {
+	if _, err := ParseExplainJSON([]byte(`[]`)); err == nil {
+		t.Error("expected error for empty plan list")
+	}
+	if _, err := ParseExplainJSON([]byte(`not json`)); err == nil {
+		t.Error("expected error for invalid JSON")
+	}
+}
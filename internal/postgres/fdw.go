@@ -0,0 +1,139 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// Federation links the tables of a schema of a remote service into a local
+// database with postgres_fdw, so one query can join data of both
+type Federation struct {
+	Remote       ServiceEntry // Service the foreign tables read from
+	Server       string       // Foreign server created on the local database
+	RemoteSchema string       // Schema of the remote database imported
+	LocalSchema  string       // Local schema the foreign tables are created in
+}
+
+// NewFederation returns the federation of the default schema of a remote
+// service (public when it has none), the server and local schema named
+// after the service
+func NewFederation(remote ServiceEntry) Federation {
+	schema := remote.DefaultSchema
+	if schema == "" {
+		schema = "public"
+	}
+	name := ImportIdentifier(remote.Name)
+	return Federation{
+		Remote:       remote,
+		Server:       "pgai_" + name,
+		RemoteSchema: schema,
+		LocalSchema:  name,
+	}
+}
+
+// federationSteps names the statements of a federation, for errors
+var federationSteps = []string{"create extension", "create server", "create user mapping", "create schema", "import foreign schema"}
+
+// Statements returns the SQL setting up the federation, in order: the
+// extension, the foreign server, the current user's mapping to the remote
+// user, the local schema and the import. The remote password, filled in
+// from the environment or password file when the service has none, is
+// shown as ******** when masked. Statements of objects that exist already
+// leave them as they are.
+func (f Federation) Statements(masked bool) []string {
+	remote := f.Remote.Resolved()
+
+	var serverOptions []string
+	option := func(options *[]string, name, value string) {
+		if value != "" {
+			*options = append(*options, name+" "+pq.QuoteLiteral(value))
+		}
+	}
+	option(&serverOptions, "host", remote.Host)
+	option(&serverOptions, "port", remote.Port)
+	option(&serverOptions, "dbname", remote.DBName)
+	option(&serverOptions, "sslmode", remote.SSLMode)
+	option(&serverOptions, "sslrootcert", remote.SSLRootCert)
+
+	password := remote.Password
+	if masked && password != "" {
+		password = "********"
+	}
+	var userOptions []string
+	option(&userOptions, "user", remote.User)
+	option(&userOptions, "password", password)
+
+	server := pq.QuoteIdentifier(f.Server)
+	return []string{
+		"CREATE EXTENSION IF NOT EXISTS postgres_fdw",
+		"CREATE SERVER IF NOT EXISTS " + server + " FOREIGN DATA WRAPPER postgres_fdw" + optionList(serverOptions),
+		"CREATE USER MAPPING IF NOT EXISTS FOR CURRENT_USER SERVER " + server + optionList(userOptions),
+		"CREATE SCHEMA IF NOT EXISTS " + pq.QuoteIdentifier(f.LocalSchema),
+		fmt.Sprintf("IMPORT FOREIGN SCHEMA %s FROM SERVER %s INTO %s",
+			pq.QuoteIdentifier(f.RemoteSchema), server, pq.QuoteIdentifier(f.LocalSchema)),
+	}
+}
+
+// optionList returns the OPTIONS clause of options, empty without any
+func optionList(options []string) string {
+	if len(options) == 0 {
+		return ""
+	}
+	return " OPTIONS (" + strings.Join(options, ", ") + ")"
+}
+
+// Federate sets up the federation on db in one transaction, so a failed
+// step leaves nothing behind, and returns the foreign tables of the local
+// schema afterwards. Creating the extension and server needs privileges
+// a read-only role lacks. Each statement is bound by the statement timeout
+// of ctx.
+func Federate(ctx context.Context, db *sql.DB, f Federation) ([]string, error) {
+	if f.Server == "" || f.RemoteSchema == "" || f.LocalSchema == "" {
+		return nil, fmt.Errorf("the server, remote schema and local schema must all be named")
+	}
+
+	tx, err := BeginTx(ctx, db, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	for i, statement := range f.Statements(false) {
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			return nil, fmt.Errorf("%s: %w", federationSteps[i], err)
+		}
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT c.relname
+		FROM pg_class c
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE c.relkind = 'f' AND n.nspname = $1
+		ORDER BY c.relname
+	`, f.LocalSchema)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var tables []string
+	for rows.Next() {
+		var table string
+		if err := rows.Scan(&table); err != nil {
+			return nil, err
+		}
+		tables = append(tables, table)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return tables, nil
+}
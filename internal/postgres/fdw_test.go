@@ -0,0 +1,47 @@
+package postgres
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestNewFederation(t *testing.T) {
+	f := NewFederation(ServiceEntry{Name: "Prod GIS"})
+	if f.Server != "pgai_prod_gis" || f.RemoteSchema != "public" || f.LocalSchema != "prod_gis" {
+		t.Errorf("NewFederation = %+v", f)
+	}
+	if f := NewFederation(ServiceEntry{Name: "prod", DefaultSchema: "sales"}); f.RemoteSchema != "sales" {
+		t.Errorf("remote schema = %q, want the service's default schema", f.RemoteSchema)
+	}
+}
+
+func TestFederationStatements(t *testing.T) {
+	t.Setenv("PGPASSFILE", filepath.Join(t.TempDir(), "none"))
+	t.Setenv("PGSSLROOTCERT", "")
+	f := Federation{
+		Remote: ServiceEntry{
+			Name: "prod", Host: "db.example.com", Port: "5433", DBName: "gis",
+			User: "reader", Password: "it's secret", SSLMode: "require",
+		},
+		Server:       "pgai_prod",
+		RemoteSchema: "public",
+		LocalSchema:  "Prod",
+	}
+
+	want := []string{
+		"CREATE EXTENSION IF NOT EXISTS postgres_fdw",
+		`CREATE SERVER IF NOT EXISTS "pgai_prod" FOREIGN DATA WRAPPER postgres_fdw OPTIONS (host 'db.example.com', port '5433', dbname 'gis', sslmode 'require')`,
+		`CREATE USER MAPPING IF NOT EXISTS FOR CURRENT_USER SERVER "pgai_prod" OPTIONS (user 'reader', password 'it''s secret')`,
+		`CREATE SCHEMA IF NOT EXISTS "Prod"`,
+		`IMPORT FOREIGN SCHEMA "public" FROM SERVER "pgai_prod" INTO "Prod"`,
+	}
+	if got := f.Statements(false); !reflect.DeepEqual(got, want) {
+		t.Errorf("Statements(false) =\n%q\nwant\n%q", got, want)
+	}
+
+	masked := f.Statements(true)[2]
+	if masked != `CREATE USER MAPPING IF NOT EXISTS FOR CURRENT_USER SERVER "pgai_prod" OPTIONS (user 'reader', password '********')` {
+		t.Errorf("masked user mapping = %q", masked)
+	}
+}
@@ -0,0 +1,97 @@
+package postgres
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// HarvestFilter selects the schemas and tables a harvest covers, so
+// databases with thousands of partitions keep a small cache. Patterns are
+// globs (* and ?) matched against "schema.name"; a pattern without a dot
+// matches every object in the schemas it names.
+type HarvestFilter struct {
+	Include []string // Only matching objects are harvested; all when empty
+	Exclude []string // Matching objects are skipped
+}
+
+// ParseHarvestFilter builds a filter from comma-separated include and
+// exclude pattern lists, as stored in pg_service.conf
+func ParseHarvestFilter(include, exclude string) HarvestFilter {
+	return HarvestFilter{Include: splitPatterns(include), Exclude: splitPatterns(exclude)}
+}
+
+// splitPatterns splits a comma-separated pattern list, dropping blanks
+func splitPatterns(list string) []string {
+	var patterns []string
+	for _, p := range strings.Split(list, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			patterns = append(patterns, p)
+		}
+	}
+	return patterns
+}
+
+// IsEmpty reports whether the filter lets everything through
+func (f HarvestFilter) IsEmpty() bool {
+	return len(f.Include) == 0 && len(f.Exclude) == 0
+}
+
+// Matches reports whether the object schema.name is harvested
+func (f HarvestFilter) Matches(schema, name string) bool {
+	qualified := schema + "." + name
+	matchAny := func(patterns []string) bool {
+		for _, p := range patterns {
+			if regexp.MustCompile(globRegexp(p)).MatchString(qualified) {
+				return true
+			}
+		}
+		return false
+	}
+	return (len(f.Include) == 0 || matchAny(f.Include)) && !matchAny(f.Exclude)
+}
+
+// globRegexp converts a pattern to an anchored regular expression that
+// both Go and PostgreSQL's ~ operator understand
+func globRegexp(pattern string) string {
+	if !strings.Contains(pattern, ".") {
+		pattern += ".*"
+	}
+	var re strings.Builder
+	re.WriteString("^")
+	for _, r := range pattern {
+		switch r {
+		case '*':
+			re.WriteString(".*")
+		case '?':
+			re.WriteString(".")
+		default:
+			re.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	re.WriteString("$")
+	return re.String()
+}
+
+// condition returns an SQL condition, starting with AND, restricting the
+// object named by the schema and name expressions to the filter, with its
+// arguments numbered from $1. It is empty when the filter is.
+func (f HarvestFilter) condition(schemaExpr, nameExpr string) (string, []any) {
+	if f.IsEmpty() {
+		return "", nil
+	}
+	include := make([]string, len(f.Include))
+	for i, p := range f.Include {
+		include[i] = globRegexp(p)
+	}
+	exclude := make([]string, len(f.Exclude))
+	for i, p := range f.Exclude {
+		exclude[i] = globRegexp(p)
+	}
+
+	qualified := "(" + schemaExpr + " || '.' || " + nameExpr + ")"
+	cond := " AND (cardinality($1::text[]) = 0 OR " + qualified + " ~ ANY($1::text[]))" +
+		" AND NOT " + qualified + " ~ ANY($2::text[]) "
+	return cond, []any{pq.Array(include), pq.Array(exclude)}
+}
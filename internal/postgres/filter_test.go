@@ -0,0 +1,64 @@
+package postgres
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseHarvestFilter(t *testing.T) {
+	f := ParseHarvestFilter(" public, gis.* ,, ", "")
+	if !reflect.DeepEqual(f.Include, []string{"public", "gis.*"}) {
+		t.Errorf("Include = %q", f.Include)
+	}
+	if f.Exclude != nil {
+		t.Errorf("Exclude = %q, want none", f.Exclude)
+	}
+	if f.IsEmpty() {
+		t.Error("filter with include patterns reported empty")
+	}
+	if !ParseHarvestFilter("", " , ").IsEmpty() {
+		t.Error("blank patterns should give an empty filter")
+	}
+}
+
+func TestHarvestFilterMatches(t *testing.T) {
+	f := ParseHarvestFilter("public, gis.road*", "*.*_p20*, public.audit_?")
+
+	tests := []struct {
+		schema, name string
+		expected     bool
+	}{
+		{"public", "parcels", true},
+		{"gis", "roads", true},
+		{"gis", "rivers", false},
+		{"publicity", "parcels", false},
+		{"public", "events_p2024_01", false},
+		{"public", "audit_1", false},
+		{"public", "audit_10", true},
+		{"gis", "road_p2023", false},
+	}
+	for _, tt := range tests {
+		if got := f.Matches(tt.schema, tt.name); got != tt.expected {
+			t.Errorf("Matches(%q, %q) = %v, want %v", tt.schema, tt.name, got, tt.expected)
+		}
+	}
+
+	if !(HarvestFilter{}).Matches("any", "thing") {
+		t.Error("empty filter should match everything")
+	}
+}
+
+func TestHarvestFilterCondition(t *testing.T) {
+	if cond, args := (HarvestFilter{}).condition("s", "n"); cond != "" || args != nil {
+		t.Errorf("empty filter condition = %q %v, want none", cond, args)
+	}
+
+	cond, args := ParseHarvestFilter("", "audit").condition("n.nspname", "c.relname")
+	if !strings.Contains(cond, "(n.nspname || '.' || c.relname)") || len(args) != 2 {
+		t.Errorf("unexpected condition %q with %d args", cond, len(args))
+	}
+	if re := globRegexp("a.b+c"); re != `^a\.b\+c$` {
+		t.Errorf("globRegexp escaped to %q", re)
+	}
+}
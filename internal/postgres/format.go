@@ -0,0 +1,96 @@
+package postgres
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// FormatValue formats a scanned SQL value as text, as results are shown
+// and snapshotted
+func FormatValue(val interface{}) string {
+	if val == nil {
+		return "NULL"
+	}
+
+	switch v := val.(type) {
+	case []byte:
+		return string(v)
+	case time.Time:
+		return v.Format("2006-01-02 15:04:05")
+	case sql.NullString:
+		if v.Valid {
+			return v.String
+		}
+		return "NULL"
+	case sql.NullInt64:
+		if v.Valid {
+			return fmt.Sprintf("%d", v.Int64)
+		}
+		return "NULL"
+	case sql.NullFloat64:
+		if v.Valid {
+			return fmt.Sprintf("%.2f", v.Float64)
+		}
+		return "NULL"
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+// FormatRow formats a row of scanned values as text
+func FormatRow(values []interface{}) []string {
+	row := make([]string, len(values))
+	for i, val := range values {
+		row[i] = FormatValue(val)
+	}
+	return row
+}
+
+// FormatRows formats rows of scanned values as text
+func FormatRows(rows [][]interface{}) [][]string {
+	formatted := make([][]string, 0, len(rows))
+	for _, values := range rows {
+		formatted = append(formatted, FormatRow(values))
+	}
+	return formatted
+}
+
+// IsNull reports whether a scanned SQL value is NULL, which FormatValue
+// shows as the same text as the string "NULL"
+func IsNull(val interface{}) bool {
+	switch v := val.(type) {
+	case nil:
+		return true
+	case sql.NullString:
+		return !v.Valid
+	case sql.NullInt64:
+		return !v.Valid
+	case sql.NullFloat64:
+		return !v.Valid
+	}
+	return false
+}
+
+// NullCells reports which values of a row are NULL, or nil when none is
+func NullCells(values []interface{}) []bool {
+	var nulls []bool
+	for i, val := range values {
+		if IsNull(val) {
+			if nulls == nil {
+				nulls = make([]bool, len(values))
+			}
+			nulls[i] = true
+		}
+	}
+	return nulls
+}
+
+// NullRows returns the NullCells of rows of scanned values
+func NullRows(rows [][]interface{}) [][]bool {
+	nulls := make([][]bool, 0, len(rows))
+	for _, values := range rows {
+		nulls = append(nulls, NullCells(values))
+	}
+	return nulls
+}
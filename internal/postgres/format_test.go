@@ -0,0 +1,23 @@
+package postgres
+
+import (
+	"database/sql"
+	"testing"
+)
+
+func TestNullCells(t *testing.T) {
+	if got := NullCells([]interface{}{int64(1), []byte("NULL")}); got != nil {
+		t.Errorf("NullCells without NULLs = %v, want nil", got)
+	}
+
+	got := NullCells([]interface{}{nil, "x", sql.NullString{}, sql.NullInt64{Int64: 1, Valid: true}})
+	want := []bool{true, false, true, false}
+	if len(got) != len(want) {
+		t.Fatalf("NullCells = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("NullCells[%d] = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// HealthPanel is one section of the health dashboard: the rows of a query
+// on the statistics views, rendered as text
+type HealthPanel struct {
+	Title   string
+	Columns []string
+	Rows    [][]string
+	Err     error // The panel could not be read, e.g. for lack of privileges
+
+	Backends bool // Each row is a backend, with its pid in the first column
+}
+
+// healthQuery is the query behind a health panel. Every column is cast to
+// text so rows can be shown as they are.
+type healthQuery struct {
+	title    string
+	sql      string
+	backends bool
+}
+
+// healthQueries are the panels of the dashboard, in display order
+var healthQueries = []healthQuery{
+	{
+		title: "Server",
+		sql: `SELECT current_setting('server_version') AS version,
+       date_trunc('second', now() - pg_postmaster_start_time())::text AS uptime,
+       (SELECT count(*) FROM pg_stat_activity WHERE backend_type = 'client backend')::text AS connections,
+       current_setting('max_connections') AS max_connections,
+       coalesce((SELECT round(100.0 * sum(blks_hit) / nullif(sum(blks_hit) + sum(blks_read), 0), 2)
+                 FROM pg_stat_database)::text || '%', 'n/a') AS cache_hit,
+       CASE WHEN pg_is_in_recovery() THEN 'standby' ELSE 'primary' END AS role`,
+	},
+	{
+		title: "Connections",
+		sql: `SELECT coalesce(datname, '') AS database,
+       coalesce(usename, '') AS "user",
+       coalesce(state, '') AS state,
+       count(*)::text AS connections,
+       coalesce(date_trunc('second', max(now() - state_change))::text, '') AS longest_in_state
+FROM pg_stat_activity
+WHERE backend_type = 'client backend'
+GROUP BY datname, usename, state
+ORDER BY count(*) DESC, datname, usename, state`,
+	},
+	{
+		title:    "Running queries",
+		backends: true,
+		sql: `SELECT pid::text,
+       coalesce(usename, '') AS "user",
+       coalesce(datname, '') AS database,
+       state,
+       date_trunc('second', now() - query_start)::text AS duration,
+       coalesce(wait_event_type || ': ' || wait_event, '') AS waiting_on,
+       left(regexp_replace(query, '\s+', ' ', 'g'), 200) AS query
+FROM pg_stat_activity
+WHERE state IS NOT NULL AND state <> 'idle'
+  AND backend_type = 'client backend'
+  AND pid <> pg_backend_pid()
+ORDER BY query_start
+LIMIT 50`,
+	},
+	{
+		title: "Blocked queries",
+		sql: `SELECT pid::text,
+       coalesce(usename, '') AS "user",
+       array_to_string(pg_blocking_pids(pid), ', ') AS blocked_by,
+       date_trunc('second', now() - query_start)::text AS waiting,
+       left(regexp_replace(query, '\s+', ' ', 'g'), 200) AS query
+FROM pg_stat_activity
+WHERE cardinality(pg_blocking_pids(pid)) > 0
+ORDER BY query_start`,
+	},
+	{
+		title: "Locks",
+		sql: `SELECT coalesce(d.datname, '') AS database,
+       l.locktype,
+       l.mode,
+       CASE WHEN l.granted THEN 'granted' ELSE 'waiting' END AS status,
+       count(*)::text AS locks
+FROM pg_locks l
+LEFT JOIN pg_database d ON d.oid = l.database
+WHERE l.pid <> pg_backend_pid()
+GROUP BY d.datname, l.locktype, l.mode, l.granted
+ORDER BY l.granted, count(*) DESC`,
+	},
+	{
+		title: "Databases",
+		sql: `SELECT d.datname AS database,
+       CASE WHEN has_database_privilege(d.datname, 'CONNECT')
+            THEN pg_size_pretty(pg_database_size(d.datname)) ELSE '' END AS size,
+       coalesce(s.numbackends, 0)::text AS connections,
+       coalesce(round(100.0 * s.blks_hit / nullif(s.blks_hit + s.blks_read, 0), 2)::text || '%', 'n/a') AS cache_hit,
+       coalesce(s.xact_commit, 0)::text AS commits,
+       coalesce(s.xact_rollback, 0)::text AS rollbacks,
+       coalesce(s.deadlocks, 0)::text AS deadlocks
+FROM pg_database d
+LEFT JOIN pg_stat_database s ON s.datid = d.oid
+WHERE d.datallowconn
+ORDER BY CASE WHEN has_database_privilege(d.datname, 'CONNECT') THEN pg_database_size(d.datname) END DESC NULLS LAST`,
+	},
+	{
+		title: "Replication",
+		sql: `SELECT coalesce(application_name, '') AS replica,
+       coalesce(client_addr::text, '') AS address,
+       coalesce(state, '') AS state,
+       coalesce(sync_state, '') AS sync,
+       coalesce(pg_size_pretty(pg_wal_lsn_diff(pg_current_wal_lsn(), replay_lsn)), '') AS lag_bytes,
+       coalesce(date_trunc('milliseconds', replay_lag)::text, '') AS lag_time
+FROM pg_stat_replication
+WHERE NOT pg_is_in_recovery()
+UNION ALL
+SELECT 'this server (standby)', '', coalesce(status, 'not streaming'), '',
+       '', coalesce(date_trunc('second', now() - pg_last_xact_replay_timestamp())::text, '')
+FROM (SELECT 1) one
+LEFT JOIN pg_stat_wal_receiver ON true
+WHERE pg_is_in_recovery()`,
+	},
+}
+
+// CollectHealth reads every panel of the health dashboard. A panel that
+// fails keeps its error and the others are still read.
+func CollectHealth(ctx context.Context, db *sql.DB) []HealthPanel {
+	panels := make([]HealthPanel, len(healthQueries))
+	for i, q := range healthQueries {
+		panels[i] = readHealthPanel(ctx, db, q)
+	}
+	return panels
+}
+
+// readHealthPanel runs the query of one panel
+func readHealthPanel(ctx context.Context, db *sql.DB, q healthQuery) HealthPanel {
+	panel := HealthPanel{Title: q.title, Backends: q.backends}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	rows, err := db.QueryContext(ctx, q.sql)
+	if err != nil {
+		panel.Err = err
+		return panel
+	}
+	defer rows.Close()
+
+	panel.Columns, err = rows.Columns()
+	if err != nil {
+		panel.Err = err
+		return panel
+	}
+	for rows.Next() {
+		values := make([]sql.NullString, len(panel.Columns))
+		ptrs := make([]any, len(values))
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			panel.Err = err
+			return panel
+		}
+		row := make([]string, len(values))
+		for i, v := range values {
+			row[i] = v.String
+		}
+		panel.Rows = append(panel.Rows, row)
+	}
+	panel.Err = rows.Err()
+	return panel
+}
+
+// SignalBackend cancels the running query of a backend, or with terminate
+// ends its session. It reports false when no such backend is running.
+// Signalling another role's backends needs superuser or pg_signal_backend.
+func SignalBackend(ctx context.Context, db *sql.DB, pid int, terminate bool) (bool, error) {
+	fn := "pg_cancel_backend"
+	if terminate {
+		fn = "pg_terminate_backend"
+	}
+	var ok bool
+	err := db.QueryRowContext(ctx, "SELECT "+fn+"($1)", pid).Scan(&ok)
+	return ok, err
+}
@@ -0,0 +1,14 @@
+package postgres
+
+import "testing"
+
+func TestHealthQueriesAreReadOnly(t *testing.T) {
+	for _, q := range healthQueries {
+		if class := ClassifyStatement(q.sql); class.IsMutating() {
+			t.Errorf("health query %q is classified as %s", q.title, class)
+		}
+		if len(SplitStatements(q.sql)) != 1 {
+			t.Errorf("health query %q is not a single statement", q.title)
+		}
+	}
+}
@@ -0,0 +1,907 @@
+package postgres
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// ImportFormat is the kind of file an import reads
+type ImportFormat string
+
+const (
+	ImportCSV     ImportFormat = "csv"
+	ImportGeoJSON ImportFormat = "geojson"
+)
+
+// ImportTypes are the column types an imported column can be given
+var ImportTypes = []string{"text", "bigint", "double precision", "numeric", "boolean", "date", "timestamptz", "jsonb"}
+
+// importSRID is the SRID of GeoJSON geometries, longitude/latitude points
+// and WKT without one
+const importSRID = 4326
+
+// importStagingTable receives the file's values as text before they are
+// converted into the new table
+const importStagingTable = "pgai_import_staging"
+
+// geometrySource is how a geometry column is built from the file
+type geometrySource int
+
+const (
+	geometryNone    geometrySource = iota
+	geometryWKT                    // WKT, EWKT or hex WKB text
+	geometryGeoJSON                // A GeoJSON geometry object
+	geometryLonLat                 // A point from longitude and latitude fields
+)
+
+// ImportColumn is a column of the table an import creates
+type ImportColumn struct {
+	Name   string // Column of the new table
+	Source string // Header or property it is read from
+	Type   string // PostgreSQL type
+	Sample string // First non-empty value
+
+	field    int // Staged field the column is read from
+	latField int // Staged latitude field of a longitude/latitude point
+	geometry geometrySource
+	srid     int
+}
+
+// IsGeometry reports whether the column is built by PostGIS from the file
+// rather than cast from text, so its type cannot be changed
+func (c ImportColumn) IsGeometry() bool {
+	return c.geometry != geometryNone
+}
+
+// ImportPlan describes how a CSV or GeoJSON file is loaded into a new table
+type ImportPlan struct {
+	Path      string
+	Format    ImportFormat
+	Schema    string
+	Table     string
+	Columns   []ImportColumn
+	Rows      int  // Records in the file
+	Delimiter rune // Field separator of a CSV file
+
+	fields   []string // Names of the staged fields, in file order
+	rawJSON  []bool   // Staged fields holding GeoJSON property values as JSON
+	features []geoJSONFeature
+}
+
+// PlanImport reads a CSV (.csv, .tsv, .txt) or GeoJSON (.geojson, .json)
+// file and proposes a table for it in schema: a column per field with the
+// narrowest type every value fits, and a geometry column for GeoJSON
+// geometries, WKT fields and longitude/latitude pairs. The table is named
+// after the file.
+func PlanImport(path, schema string) (*ImportPlan, error) {
+	plan := &ImportPlan{
+		Path:   path,
+		Schema: schema,
+		Table:  ImportIdentifier(strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))),
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".geojson", ".json":
+		plan.Format = ImportGeoJSON
+		if err := plan.readGeoJSON(); err != nil {
+			return nil, err
+		}
+	case ".csv", ".tsv", ".txt":
+		plan.Format = ImportCSV
+		if err := plan.readCSVHeader(); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported file type %q: import reads CSV or GeoJSON", filepath.Ext(path))
+	}
+
+	if err := plan.infer(); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// ImportIdentifier turns a header, property or file name into a lower-case
+// PostgreSQL identifier that needs no quoting in most queries
+func ImportIdentifier(name string) string {
+	var b strings.Builder
+	underscore := false
+	for _, r := range strings.ToLower(strings.TrimSpace(name)) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+			underscore = false
+		} else if !underscore && b.Len() > 0 {
+			b.WriteByte('_')
+			underscore = true
+		}
+	}
+	id := strings.TrimRight(b.String(), "_")
+	if id == "" {
+		return "column"
+	}
+	if id[0] >= '0' && id[0] <= '9' {
+		id = "c_" + id
+	}
+	if len(id) > 63 {
+		id = strings.TrimRight(id[:63], "_")
+	}
+	return id
+}
+
+// uniqueNames makes names unique by numbering repeats
+func uniqueNames(names []string) []string {
+	seen := make(map[string]bool)
+	unique := make([]string, len(names))
+	for i, name := range names {
+		candidate := name
+		for n := 2; seen[candidate]; n++ {
+			candidate = fmt.Sprintf("%s_%d", name, n)
+		}
+		seen[candidate] = true
+		unique[i] = candidate
+	}
+	return unique
+}
+
+// QualifiedTable returns the quoted schema-qualified name of the new table
+func (p *ImportPlan) QualifiedTable() string {
+	if p.Schema == "" {
+		return pq.QuoteIdentifier(p.Table)
+	}
+	return pq.QuoteIdentifier(p.Schema) + "." + pq.QuoteIdentifier(p.Table)
+}
+
+// HasGeometry reports whether the plan has a geometry column
+func (p *ImportPlan) HasGeometry() bool {
+	for _, c := range p.Columns {
+		if c.IsGeometry() {
+			return true
+		}
+	}
+	return false
+}
+
+// DropGeometry turns geometry columns into plain ones for databases
+// without PostGIS: WKT stays text, GeoJSON geometries become jsonb and
+// points made from longitude/latitude are left out
+func (p *ImportPlan) DropGeometry() {
+	columns := p.Columns[:0]
+	for _, c := range p.Columns {
+		switch c.geometry {
+		case geometryLonLat:
+			continue
+		case geometryWKT:
+			c.Type = "text"
+		case geometryGeoJSON:
+			c.Type = "jsonb"
+		}
+		c.geometry = geometryNone
+		columns = append(columns, c)
+	}
+	p.Columns = columns
+}
+
+// CreateTableSQL returns the statement that creates the new table
+func (p *ImportPlan) CreateTableSQL() string {
+	defs := make([]string, len(p.Columns))
+	for i, c := range p.Columns {
+		defs[i] = fmt.Sprintf("    %s %s", pq.QuoteIdentifier(c.Name), c.Type)
+	}
+	return fmt.Sprintf("CREATE TABLE %s (\n%s\n)", p.QualifiedTable(), strings.Join(defs, ",\n"))
+}
+
+// insertSQL returns the statement that converts the staged text into the
+// new table
+func (p *ImportPlan) insertSQL() string {
+	names := make([]string, len(p.Columns))
+	exprs := make([]string, len(p.Columns))
+	for i, c := range p.Columns {
+		names[i] = pq.QuoteIdentifier(c.Name)
+		exprs[i] = c.expression()
+	}
+	return fmt.Sprintf("INSERT INTO %s (%s)\nSELECT %s\nFROM %s",
+		p.QualifiedTable(), strings.Join(names, ", "), strings.Join(exprs, ", "), importStagingTable)
+}
+
+// stagedField returns the name of the staging column of field i
+func stagedField(i int) string {
+	return fmt.Sprintf("f%d", i+1)
+}
+
+// expression converts the column's staged text; empty values become NULL
+func (c ImportColumn) expression() string {
+	value := fmt.Sprintf("NULLIF(%s, '')", stagedField(c.field))
+	switch c.geometry {
+	case geometryWKT:
+		return fmt.Sprintf("ST_SetSRID(%s::geometry, %d)", value, c.srid)
+	case geometryGeoJSON:
+		return fmt.Sprintf("ST_SetSRID(ST_GeomFromGeoJSON(%s), %d)", value, c.srid)
+	case geometryLonLat:
+		return fmt.Sprintf("ST_SetSRID(ST_MakePoint(%s::double precision, NULLIF(%s, '')::double precision), %d)",
+			value, stagedField(c.latField), c.srid)
+	}
+	if c.Type == "text" {
+		return value
+	}
+	return value + "::" + c.Type
+}
+
+// Load creates the table and copies the file into it in one transaction:
+// the values are streamed with COPY FROM into a temporary table of text
+// columns, then converted into the new table, so nothing is left behind
+// when any value does not fit its column. progress, when set, is called
+// with the number of records copied so far. Each statement is bound by the
+// statement timeout of ctx. It returns the rows inserted.
+func (p *ImportPlan) Load(ctx context.Context, db *sql.DB, progress func(rows int)) (int64, error) {
+	if len(p.Columns) == 0 {
+		return 0, fmt.Errorf("nothing to import: the file has no columns")
+	}
+
+	tx, err := BeginTx(ctx, db, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, p.CreateTableSQL()); err != nil {
+		return 0, fmt.Errorf("create table: %w", err)
+	}
+
+	staged := make([]string, len(p.fields))
+	for i := range p.fields {
+		staged[i] = stagedField(i) + " text"
+	}
+	createStaging := fmt.Sprintf("CREATE TEMP TABLE %s (%s) ON COMMIT DROP", importStagingTable, strings.Join(staged, ", "))
+	if _, err := tx.ExecContext(ctx, createStaging); err != nil {
+		return 0, fmt.Errorf("create staging table: %w", err)
+	}
+
+	names := make([]string, len(p.fields))
+	for i := range p.fields {
+		names[i] = stagedField(i)
+	}
+	stmt, err := tx.PrepareContext(ctx, pq.CopyIn(importStagingTable, names...))
+	if err != nil {
+		return 0, fmt.Errorf("copy: %w", err)
+	}
+
+	jsonbField := make([]bool, len(p.fields))
+	for _, c := range p.Columns {
+		if c.geometry == geometryNone && c.Type == "jsonb" {
+			jsonbField[c.field] = true
+		}
+	}
+	copied := 0
+	err = p.records(func(values []string) error {
+		args := make([]any, len(values))
+		for i, v := range values {
+			if p.rawJSON[i] && !jsonbField[i] {
+				v = jsonScalarText(v)
+			}
+			args[i] = v
+		}
+		if _, err := stmt.ExecContext(ctx, args...); err != nil {
+			return err
+		}
+		copied++
+		if progress != nil && copied%1000 == 0 {
+			progress(copied)
+		}
+		return nil
+	})
+	if err == nil {
+		_, err = stmt.ExecContext(ctx)
+	}
+	if closeErr := stmt.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return 0, fmt.Errorf("copy record %d: %w", copied+1, err)
+	}
+	if progress != nil {
+		progress(copied)
+	}
+
+	res, err := tx.ExecContext(ctx, p.insertSQL())
+	if err != nil {
+		return 0, fmt.Errorf("convert values: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// records calls fn with the staged text of every record in the file
+func (p *ImportPlan) records(fn func(values []string) error) error {
+	if p.Format == ImportGeoJSON {
+		return p.geoJSONRecords(fn)
+	}
+	return p.csvRecords(fn)
+}
+
+// openCSV opens the plan's file as CSV, past a UTF-8 byte order mark
+func (p *ImportPlan) openCSV() (*csv.Reader, io.Closer, error) {
+	f, err := os.Open(p.Path)
+	if err != nil {
+		return nil, nil, err
+	}
+	br := bufio.NewReader(f)
+	if bom, err := br.Peek(3); err == nil && bytes.Equal(bom, []byte{0xEF, 0xBB, 0xBF}) {
+		br.Discard(3)
+	}
+	r := csv.NewReader(br)
+	r.Comma = p.Delimiter
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	return r, f, nil
+}
+
+// readCSVHeader detects the delimiter and reads the field names from the
+// first line
+func (p *ImportPlan) readCSVHeader() error {
+	f, err := os.Open(p.Path)
+	if err != nil {
+		return err
+	}
+	line, err := bufio.NewReader(f).ReadString('\n')
+	f.Close()
+	if err != nil && err != io.EOF {
+		return err
+	}
+	p.Delimiter = sniffDelimiter(line)
+
+	r, closer, err := p.openCSV()
+	if err != nil {
+		return err
+	}
+	defer closer.Close()
+	header, err := r.Read()
+	if err == io.EOF {
+		return fmt.Errorf("%s is empty", filepath.Base(p.Path))
+	}
+	if err != nil {
+		return fmt.Errorf("read header: %w", err)
+	}
+	p.fields = header
+	p.rawJSON = make([]bool, len(header))
+	return nil
+}
+
+// sniffDelimiter picks the most frequent of the usual field separators in
+// the header line outside quotes, defaulting to a comma
+func sniffDelimiter(line string) rune {
+	counts := make(map[rune]int)
+	quoted := false
+	for _, r := range line {
+		switch r {
+		case '"':
+			quoted = !quoted
+		case ',', ';', '\t', '|':
+			if !quoted {
+				counts[r]++
+			}
+		}
+	}
+	best := ','
+	for _, r := range []rune{';', '\t', '|'} {
+		if counts[r] > counts[best] {
+			best = r
+		}
+	}
+	return best
+}
+
+// csvRecords calls fn with every record after the header, padded or cut
+// to the header's width
+func (p *ImportPlan) csvRecords(fn func(values []string) error) error {
+	r, closer, err := p.openCSV()
+	if err != nil {
+		return err
+	}
+	defer closer.Close()
+	if _, err := r.Read(); err != nil {
+		return err
+	}
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if len(record) == 1 && record[0] == "" && len(p.fields) > 1 {
+			continue // Blank line
+		}
+		values := make([]string, len(p.fields))
+		copy(values, record)
+		if err := fn(values); err != nil {
+			return err
+		}
+	}
+}
+
+// geoJSONFeature is a feature of a GeoJSON file
+type geoJSONFeature struct {
+	Type       string           `json:"type"`
+	Geometry   json.RawMessage  `json:"geometry"`
+	Properties json.RawMessage  `json:"properties"`
+	Features   []geoJSONFeature `json:"features"`
+}
+
+// readGeoJSON reads the features of a FeatureCollection, a single Feature
+// or a bare geometry, and names a field for every property in the order
+// they first appear, followed by the geometry
+func (p *ImportPlan) readGeoJSON() error {
+	data, err := os.ReadFile(p.Path)
+	if err != nil {
+		return err
+	}
+	var root geoJSONFeature
+	if err := json.Unmarshal(data, &root); err != nil {
+		return fmt.Errorf("invalid GeoJSON: %w", err)
+	}
+	switch root.Type {
+	case "FeatureCollection":
+		p.features = root.Features
+	case "Feature":
+		p.features = []geoJSONFeature{root}
+	case "":
+		return fmt.Errorf("invalid GeoJSON: no type")
+	default:
+		p.features = []geoJSONFeature{{Type: "Feature", Geometry: data}}
+	}
+
+	seen := make(map[string]bool)
+	for _, f := range p.features {
+		keys, err := objectKeys(f.Properties)
+		if err != nil {
+			return fmt.Errorf("invalid GeoJSON properties: %w", err)
+		}
+		for _, k := range keys {
+			if !seen[k] {
+				seen[k] = true
+				p.fields = append(p.fields, k)
+				p.rawJSON = append(p.rawJSON, true)
+			}
+		}
+	}
+	p.fields = append(p.fields, "geometry")
+	p.rawJSON = append(p.rawJSON, false)
+	return nil
+}
+
+// objectKeys returns the keys of a JSON object in document order; null
+// has none
+func objectKeys(raw json.RawMessage) ([]string, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return nil, fmt.Errorf("not an object")
+	}
+	var keys []string
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, tok.(string))
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return nil, err
+		}
+	}
+	return keys, nil
+}
+
+// geoJSONRecords calls fn with the property values of every feature as
+// JSON text, then its geometry; missing and null values are empty
+func (p *ImportPlan) geoJSONRecords(fn func(values []string) error) error {
+	last := len(p.fields) - 1
+	index := make(map[string]int, last)
+	for i, name := range p.fields[:last] {
+		index[name] = i
+	}
+	for _, f := range p.features {
+		values := make([]string, len(p.fields))
+		var props map[string]json.RawMessage
+		if len(f.Properties) > 0 {
+			if err := json.Unmarshal(f.Properties, &props); err != nil {
+				return err
+			}
+		}
+		for k, v := range props {
+			if s := strings.TrimSpace(string(v)); s != "null" {
+				values[index[k]] = s
+			}
+		}
+		if g := strings.TrimSpace(string(f.Geometry)); g != "" && g != "null" {
+			values[last] = g
+		}
+		if err := fn(values); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// jsonScalarText returns the text of a JSON string, number or boolean;
+// objects and arrays stay JSON
+func jsonScalarText(raw string) string {
+	if strings.HasPrefix(raw, `"`) {
+		var s string
+		if err := json.Unmarshal([]byte(raw), &s); err == nil {
+			return s
+		}
+	}
+	return raw
+}
+
+// Patterns recognising values of the inferred types
+var (
+	wktPattern     = regexp.MustCompile(`(?i)^\s*(?:SRID=(\d+);)?\s*(POINT|LINESTRING|POLYGON|MULTIPOINT|MULTILINESTRING|MULTIPOLYGON|GEOMETRYCOLLECTION)\s*(ZM|Z|M)?\s*(\(|EMPTY)`)
+	hexWKBPattern  = regexp.MustCompile(`^(?:00|01)[0-9A-Fa-f]{40,}$`)
+	integerPattern = regexp.MustCompile(`^[+-]?(0|[1-9][0-9]*)$`)
+	decimalPattern = regexp.MustCompile(`^[+-]?((0|[1-9][0-9]*)(\.[0-9]+)?|\.[0-9]+)([eE][+-]?[0-9]+)?$`)
+)
+
+// Layouts of the timestamps recognised; fractional seconds are accepted
+// after the seconds of each
+var timestampLayouts = []string{
+	"2006-01-02T15:04:05Z07:00",
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05-07",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04",
+}
+
+// geoJSONTypes maps GeoJSON and WKT geometry type names to PostGIS ones
+var geoJSONTypes = map[string]string{
+	"POINT":              "Point",
+	"LINESTRING":         "LineString",
+	"POLYGON":            "Polygon",
+	"MULTIPOINT":         "MultiPoint",
+	"MULTILINESTRING":    "MultiLineString",
+	"MULTIPOLYGON":       "MultiPolygon",
+	"GEOMETRYCOLLECTION": "GeometryCollection",
+}
+
+// fieldStats collects what the values of a field could be
+type fieldStats struct {
+	values   int
+	sample   string
+	integer  bool
+	decimal  bool
+	fraction bool    // Some number has a fraction or exponent
+	lo, hi   float64 // Range of the numbers
+	boolean  bool
+	date     bool
+	stamp    bool
+	json     bool // Some value is a JSON object or array
+	wkt      bool
+	geojson  bool
+
+	geomType  string // PostGIS type shared by every geometry, or "Geometry"
+	geomDims  string // "", "Z", "M" or "ZM"; "?" when they differ
+	srid      int
+	mixedSRID bool
+}
+
+func newFieldStats() *fieldStats {
+	return &fieldStats{integer: true, decimal: true, boolean: true, date: true, stamp: true, wkt: true, geojson: true}
+}
+
+// add narrows the possible types by a non-empty value
+func (s *fieldStats) add(value string, rawJSON bool) {
+	if rawJSON {
+		if strings.HasPrefix(value, "{") || strings.HasPrefix(value, "[") {
+			s.json = true
+		}
+		value = jsonScalarText(value)
+	}
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return
+	}
+	if s.values == 0 {
+		s.sample = value
+	}
+	s.values++
+
+	if s.integer && !integerPattern.MatchString(value) {
+		s.integer = false
+	}
+	if s.integer {
+		if _, err := strconv.ParseInt(value, 10, 64); err != nil {
+			s.integer = false // Too large for bigint; numeric holds it
+		}
+	}
+	if s.decimal && !decimalPattern.MatchString(value) {
+		s.decimal = false
+	}
+	if s.decimal {
+		n, _ := strconv.ParseFloat(value, 64)
+		if s.values == 1 || n < s.lo {
+			s.lo = n
+		}
+		if s.values == 1 || n > s.hi {
+			s.hi = n
+		}
+		if strings.ContainsAny(value, ".eE") {
+			s.fraction = true
+		}
+	}
+	if s.boolean {
+		switch strings.ToLower(value) {
+		case "true", "false", "t", "f", "yes", "no":
+		default:
+			s.boolean = false
+		}
+	}
+	if s.date {
+		if _, err := time.Parse("2006-01-02", value); err != nil {
+			s.date = false
+		}
+	}
+	if s.stamp && !parsesAsTimestamp(value) {
+		s.stamp = false
+	}
+	if s.wkt {
+		s.addWKT(value)
+	}
+	if s.geojson {
+		s.addGeoJSON(value)
+	}
+}
+
+// parsesAsTimestamp reports whether value is a date and time in one of the
+// recognised layouts
+func parsesAsTimestamp(value string) bool {
+	for _, layout := range timestampLayouts {
+		if _, err := time.Parse(layout, value); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// addWKT records the type, dimensions and SRID of a WKT or EWKT value
+func (s *fieldStats) addWKT(value string) {
+	m := wktPattern.FindStringSubmatch(value)
+	if m == nil {
+		if hexWKBPattern.MatchString(value) {
+			// Hex WKB says nothing readable about its type
+			s.addGeometry("Geometry", "?", 0)
+			return
+		}
+		s.wkt = false
+		return
+	}
+	srid := 0
+	if m[1] != "" {
+		srid, _ = strconv.Atoi(m[1])
+	}
+	dims := strings.ToUpper(m[3])
+	if dims == "" && m[4] == "(" {
+		dims = coordinateDims(len(strings.Fields(firstCoordinate(value[len(m[0])-1:]))))
+	}
+	s.addGeometry(geoJSONTypes[strings.ToUpper(m[2])], dims, srid)
+}
+
+// firstCoordinate returns the first coordinate of the WKT coordinate list
+// that starts body
+func firstCoordinate(body string) string {
+	rest := strings.TrimLeft(body, "( \t\r\n")
+	if end := strings.IndexAny(rest, ",)"); end >= 0 {
+		return rest[:end]
+	}
+	return rest
+}
+
+// coordinateDims returns the dimension suffix of coordinates of n ordinates
+func coordinateDims(n int) string {
+	switch n {
+	case 3:
+		return "Z"
+	case 4:
+		return "ZM"
+	}
+	return ""
+}
+
+// geoJSONGeometry is the part of a GeoJSON geometry inference reads
+type geoJSONGeometry struct {
+	Type        string          `json:"type"`
+	Coordinates json.RawMessage `json:"coordinates"`
+}
+
+// addGeoJSON records the type and dimensions of a GeoJSON geometry
+func (s *fieldStats) addGeoJSON(value string) {
+	var g geoJSONGeometry
+	typ, known := "", false
+	if strings.HasPrefix(value, "{") && json.Unmarshal([]byte(value), &g) == nil {
+		typ, known = geoJSONTypes[strings.ToUpper(g.Type)]
+	}
+	if !known {
+		s.geojson = false
+		return
+	}
+	dims := "?"
+	var coords any
+	if json.Unmarshal(g.Coordinates, &coords) == nil {
+		for {
+			list, ok := coords.([]any)
+			if !ok || len(list) == 0 {
+				break
+			}
+			if _, nested := list[0].([]any); !nested {
+				dims = coordinateDims(len(list))
+				break
+			}
+			coords = list[0]
+		}
+	}
+	s.addGeometry(typ, dims, importSRID)
+}
+
+// addGeometry merges one geometry's type, dimensions and SRID into those
+// seen so far
+func (s *fieldStats) addGeometry(typ, dims string, srid int) {
+	switch {
+	case s.geomType == "":
+		s.geomType, s.geomDims, s.srid = typ, dims, srid
+		return
+	case s.geomType != typ:
+		s.geomType = "Geometry"
+	}
+	if s.geomDims != dims {
+		s.geomDims = "?"
+	}
+	if s.srid != srid {
+		s.mixedSRID = true
+	}
+}
+
+// geometryType returns the column type of the geometries seen
+func (s *fieldStats) geometryType(srid int) string {
+	if s.geomDims == "?" {
+		return "geometry"
+	}
+	return fmt.Sprintf("geometry(%s%s, %d)", s.geomType, s.geomDims, srid)
+}
+
+// columnType returns the narrowest type of a plain field
+func (s *fieldStats) columnType() string {
+	switch {
+	case s.json:
+		return "jsonb"
+	case s.values == 0:
+		return "text"
+	case s.integer:
+		return "bigint"
+	case s.decimal && s.fraction:
+		return "double precision"
+	case s.decimal:
+		return "numeric" // Integers too large for bigint
+	case s.boolean:
+		return "boolean"
+	case s.date:
+		return "date"
+	case s.stamp:
+		return "timestamptz"
+	}
+	return "text"
+}
+
+// Field names taken for the longitude and latitude of a point
+var (
+	longitudeNames = map[string]bool{"lon": true, "lng": true, "long": true, "longitude": true, "x": true}
+	latitudeNames  = map[string]bool{"lat": true, "latitude": true, "y": true}
+)
+
+// infer reads every record to choose the column types and builds the
+// columns of the plan
+func (p *ImportPlan) infer() error {
+	stats := make([]*fieldStats, len(p.fields))
+	for i := range stats {
+		stats[i] = newFieldStats()
+	}
+	err := p.records(func(values []string) error {
+		p.Rows++
+		for i, v := range values {
+			if v != "" {
+				stats[i].add(v, p.rawJSON[i])
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, len(p.fields))
+	for i, f := range p.fields {
+		names[i] = ImportIdentifier(f)
+		if strings.TrimSpace(f) == "" {
+			names[i] = fmt.Sprintf("column_%d", i+1)
+		}
+	}
+
+	lon, lat := -1, -1
+	for i, s := range stats {
+		column := ImportColumn{Name: names[i], Source: p.fields[i], Sample: s.sample, field: i, latField: -1}
+		switch {
+		case p.Format == ImportGeoJSON && i == len(p.fields)-1:
+			if s.values == 0 {
+				continue // Features without geometries
+			}
+			column.Name = "geom"
+			column.geometry = geometryGeoJSON
+			column.srid = importSRID
+			column.Type = s.geometryType(importSRID)
+			column.Sample = s.geomType
+		case p.Format == ImportCSV && s.values > 0 && s.wkt && !s.decimal:
+			column.geometry = geometryWKT
+			column.srid = s.srid
+			if s.srid == 0 || s.mixedSRID {
+				column.srid = importSRID
+			}
+			column.Type = s.geometryType(column.srid)
+		case p.Format == ImportCSV && s.values > 0 && s.geojson:
+			column.geometry = geometryGeoJSON
+			column.srid = importSRID
+			column.Type = s.geometryType(importSRID)
+		default:
+			column.Type = s.columnType()
+		}
+		if (column.Type == "bigint" || column.Type == "numeric" || column.Type == "double precision") && s.values > 0 {
+			if longitudeNames[names[i]] && lon < 0 && s.lo >= -180 && s.hi <= 180 {
+				lon = i
+			} else if latitudeNames[names[i]] && lat < 0 && s.lo >= -90 && s.hi <= 90 {
+				lat = i
+			}
+		}
+		p.Columns = append(p.Columns, column)
+	}
+
+	if lon >= 0 && lat >= 0 && !p.HasGeometry() {
+		p.Columns = append(p.Columns, ImportColumn{
+			Name:     "geom",
+			Source:   p.fields[lon] + ", " + p.fields[lat],
+			Type:     fmt.Sprintf("geometry(Point, %d)", importSRID),
+			Sample:   "Point",
+			field:    lon,
+			latField: lat,
+			geometry: geometryLonLat,
+			srid:     importSRID,
+		})
+	}
+
+	columnNames := make([]string, len(p.Columns))
+	for i, c := range p.Columns {
+		columnNames[i] = c.Name
+	}
+	for i, name := range uniqueNames(columnNames) {
+		p.Columns[i].Name = name
+	}
+	return nil
+}
@@ -0,0 +1,194 @@
+package postgres
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeImportFile writes content to a file called name in a temporary
+// directory and returns its path
+func writeImportFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// columnTypes returns the name and type of each column of a plan
+func columnTypes(plan *ImportPlan) []string {
+	var types []string
+	for _, c := range plan.Columns {
+		types = append(types, c.Name+" "+c.Type)
+	}
+	return types
+}
+
+func TestPlanImportCSV(t *testing.T) {
+	path := writeImportFile(t, "Cape Towns.csv", "\ufeffName,Population,Area km2,Founded,Is Capital,Lon,Lat,Code,Updated\n"+
+		"Cape Town,4710000,2461.5,1652-04-06,yes,18.42,-33.92,021,2024-01-02 10:00:00\n"+
+		"\"Stellenbosch, WC\",,831,1679-11-08,no,18.86,-33.93,007,2024-01-02T10:00:00Z\n")
+
+	plan, err := PlanImport(path, "public")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Format != ImportCSV || plan.Table != "cape_towns" || plan.Rows != 2 || plan.Delimiter != ',' {
+		t.Errorf("unexpected plan: format %q, table %q, rows %d, delimiter %q", plan.Format, plan.Table, plan.Rows, plan.Delimiter)
+	}
+
+	expected := []string{
+		"name text",
+		"population bigint",
+		"area_km2 double precision",
+		"founded date",
+		"is_capital boolean",
+		"lon double precision",
+		"lat double precision",
+		"code text",
+		"updated timestamptz",
+		"geom geometry(Point, 4326)",
+	}
+	if got := columnTypes(plan); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected columns %q, got %q", expected, got)
+	}
+
+	create := plan.CreateTableSQL()
+	if !strings.HasPrefix(create, `CREATE TABLE "public"."cape_towns" (`) || !strings.Contains(create, `"geom" geometry(Point, 4326)`) {
+		t.Errorf("unexpected CREATE TABLE:\n%s", create)
+	}
+	insert := plan.insertSQL()
+	if !strings.Contains(insert, "ST_SetSRID(ST_MakePoint(NULLIF(f6, '')::double precision, NULLIF(f7, '')::double precision), 4326)") ||
+		!strings.Contains(insert, "NULLIF(f2, '')::bigint") || !strings.Contains(insert, "NULLIF(f1, ''), ") {
+		t.Errorf("unexpected INSERT:\n%s", insert)
+	}
+
+	var records [][]string
+	plan.records(func(values []string) error {
+		records = append(records, values)
+		return nil
+	})
+	if len(records) != 2 || records[1][0] != "Stellenbosch, WC" || records[1][1] != "" {
+		t.Errorf("unexpected records %q", records)
+	}
+}
+
+func TestPlanImportCSVGeometry(t *testing.T) {
+	tests := []struct {
+		content  string
+		expected []string
+	}{
+		{
+			"id;shape\n1;POLYGON((0 0,1 0,1 1,0 0))\n2;MULTIPOLYGON(((0 0,1 0,1 1,0 0)))\n",
+			[]string{"id bigint", "shape geometry(Geometry, 4326)"},
+		},
+		{
+			"id,wkt\n1,SRID=3857;POINT(1 2)\n2,SRID=3857;POINT(3 4)\n",
+			[]string{"id bigint", "wkt geometry(Point, 3857)"},
+		},
+		{
+			"id\twkt\n1\tPOINT Z (1 2 3)\n2\tPOINT(1 2 3)\n",
+			[]string{"id bigint", "wkt geometry(PointZ, 4326)"},
+		},
+		{
+			"id,wkt\n1,POINT(1 2)\n2,POINT(1 2 3)\n",
+			[]string{"id bigint", "wkt geometry"},
+		},
+		{
+			// Projected coordinates are not taken for longitude and latitude
+			"x,y\n250000,6000000\n",
+			[]string{"x bigint", "y bigint"},
+		},
+		{
+			"big,ratio,flag,1st\n99999999999999999999,1e-3,t,\n1,2,F,\n",
+			[]string{"big numeric", "ratio double precision", "flag boolean", "c_1st text"},
+		},
+	}
+
+	for _, tt := range tests {
+		plan, err := PlanImport(writeImportFile(t, "data.csv", tt.content), "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := columnTypes(plan); !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("%q: expected columns %q, got %q", tt.content, tt.expected, got)
+		}
+	}
+}
+
+func TestPlanImportGeoJSON(t *testing.T) {
+	path := writeImportFile(t, "parks.geojson", `{
+  "type": "FeatureCollection",
+  "features": [
+    {"type": "Feature", "properties": {"name": "Kirstenbosch", "area": 528, "tags": ["garden"]},
+     "geometry": {"type": "Point", "coordinates": [18.43, -33.99, 40]}},
+    {"type": "Feature", "properties": {"name": "Company's Garden", "open": true, "area": null},
+     "geometry": {"type": "Point", "coordinates": [18.42, -33.93, 30]}}
+  ]
+}`)
+
+	plan, err := PlanImport(path, "public")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Format != ImportGeoJSON || plan.Rows != 2 {
+		t.Errorf("unexpected plan: format %q, rows %d", plan.Format, plan.Rows)
+	}
+
+	expected := []string{"name text", "area bigint", "tags jsonb", "open boolean", "geom geometry(PointZ, 4326)"}
+	if got := columnTypes(plan); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected columns %q, got %q", expected, got)
+	}
+	if insert := plan.insertSQL(); !strings.Contains(insert, "ST_SetSRID(ST_GeomFromGeoJSON(NULLIF(f5, '')), 4326)") {
+		t.Errorf("unexpected INSERT:\n%s", insert)
+	}
+
+	var records [][]string
+	plan.records(func(values []string) error {
+		records = append(records, values)
+		return nil
+	})
+	if len(records) != 2 || records[0][2] != `["garden"]` || records[1][0] != `"Company's Garden"` || records[1][1] != "" {
+		t.Errorf("unexpected records %q", records)
+	}
+
+	plan.DropGeometry()
+	expected = []string{"name text", "area bigint", "tags jsonb", "open boolean", "geom jsonb"}
+	if got := columnTypes(plan); !reflect.DeepEqual(got, expected) || plan.HasGeometry() {
+		t.Errorf("expected columns %q without geometry, got %q", expected, got)
+	}
+}
+
+func TestPlanImportErrors(t *testing.T) {
+	if _, err := PlanImport(writeImportFile(t, "data.xlsx", "x"), ""); err == nil {
+		t.Error("expected an error for an unsupported file type")
+	}
+	if _, err := PlanImport(writeImportFile(t, "data.csv", ""), ""); err == nil {
+		t.Error("expected an error for an empty CSV file")
+	}
+	if _, err := PlanImport(writeImportFile(t, "data.geojson", `{"features": []}`), ""); err == nil {
+		t.Error("expected an error for GeoJSON without a type")
+	}
+}
+
+func TestImportIdentifier(t *testing.T) {
+	for name, expected := range map[string]string{
+		"Population 2021": "population_2021",
+		"  Area (km²) ":   "area_km",
+		"2nd-name":        "c_2nd_name",
+		"__id__":          "id",
+		"***":             "column",
+	} {
+		if got := ImportIdentifier(name); got != expected {
+			t.Errorf("ImportIdentifier(%q): expected %q, got %q", name, expected, got)
+		}
+	}
+
+	if got := uniqueNames([]string{"a", "b", "a", "a"}); !reflect.DeepEqual(got, []string{"a", "b", "a_2", "a_3"}) {
+		t.Errorf("unexpected unique names %q", got)
+	}
+}
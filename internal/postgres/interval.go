@@ -0,0 +1,79 @@
+package postgres
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// intervalUnits are the lengths of the units PostgreSQL writes intervals
+// in, with months of 30 days and years of 365.25 as EXTRACT(EPOCH) counts
+// them
+var intervalUnits = map[string]time.Duration{
+	"year": 8766 * time.Hour, "years": 8766 * time.Hour,
+	"mon": 720 * time.Hour, "mons": 720 * time.Hour,
+	"day": 24 * time.Hour, "days": 24 * time.Hour,
+}
+
+// ParseInterval reads an interval as PostgreSQL writes it by default, such
+// as "1 year 2 mons 3 days 04:05:06.5" or "-00:03:40", as a duration. ok
+// is false for text in another style.
+func ParseInterval(s string) (d time.Duration, ok bool) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return 0, false
+	}
+	for i := 0; i < len(fields); i++ {
+		field := fields[i]
+		if strings.Contains(field, ":") {
+			clock, ok := parseClock(field)
+			if !ok {
+				return 0, false
+			}
+			d += clock
+			continue
+		}
+		n, err := strconv.ParseInt(field, 10, 64)
+		if err != nil || i+1 >= len(fields) {
+			return 0, false
+		}
+		unit, known := intervalUnits[fields[i+1]]
+		if !known {
+			return 0, false
+		}
+		d += time.Duration(n) * unit
+		i++
+	}
+	return d, true
+}
+
+// parseClock reads the [-+]hh:mm:ss[.frac] part of an interval
+func parseClock(s string) (time.Duration, bool) {
+	sign := time.Duration(1)
+	if rest, neg := strings.CutPrefix(s, "-"); neg {
+		sign, s = -1, rest
+	} else {
+		s = strings.TrimPrefix(s, "+")
+	}
+	parts := strings.Split(s, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, false
+	}
+	hours, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	minutes, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	var seconds float64
+	if len(parts) == 3 {
+		if seconds, err = strconv.ParseFloat(parts[2], 64); err != nil {
+			return 0, false
+		}
+	}
+	d := time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute +
+		time.Duration(seconds*float64(time.Second))
+	return sign * d, true
+}
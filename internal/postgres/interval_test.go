@@ -0,0 +1,30 @@
+package postgres
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseInterval(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Duration
+		ok   bool
+	}{
+		{"00:03:40", 3*time.Minute + 40*time.Second, true},
+		{"-00:00:01.5", -1500 * time.Millisecond, true},
+		{"3 days", 72 * time.Hour, true},
+		{"1 day 02:00:00", 26 * time.Hour, true},
+		{"-1 days +02:00:00", -22 * time.Hour, true},
+		{"1 year 2 mons", 8766*time.Hour + 1440*time.Hour, true},
+		{"P1D", 0, false},
+		{"3 fortnights", 0, false},
+		{"", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := ParseInterval(tt.in)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("ParseInterval(%q) = %s, %v, want %s, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package postgres
+
+import (
+	"strings"
+	"unicode"
+)
+
+// TokenKind classifies a lexed piece of SQL
+type TokenKind int
+
+const (
+	TokenWhitespace       TokenKind = iota
+	TokenKeyword                    // Reserved and common non-reserved words
+	TokenIdentifier                 // Bare names of tables, columns, functions, ...
+	TokenQuotedIdentifier           // "Name"
+	TokenString                     // 'text', E'text', $tag$text$tag$
+	TokenNumber                     // 42, 3.14, 1e-3
+	TokenParameter                  // $1 or a {{name}} template placeholder
+	TokenComment                    // -- line or /* block */
+	TokenOperator                   // =, <>, ::, ||, ...
+	TokenPunctuation                // ( ) , ; . [ ] and anything else
+)
+
+// Token is a piece of SQL text and its kind
+type Token struct {
+	Kind TokenKind
+	Text string
+}
+
+// sqlKeywordSet holds the words lexed as keywords, lowercased
+var sqlKeywordSet = map[string]bool{}
+
+func init() {
+	for _, w := range strings.Fields(`
+		all alter analyze and any array as asc between both by case cast check collate
+		column constraint create cross current_date current_time current_timestamp
+		current_user default delete desc distinct do drop else end except exists
+		explain false fetch filter first following for foreign from full grant group
+		having ilike in inner insert intersect interval into is join lateral last
+		leading left like limit local materialized natural not nothing null nulls
+		offset on only or order outer over partition preceding primary range
+		recursive references returning right rows select set similar some symmetric
+		table then ties to trailing true truncate unbounded union unique update
+		using values view when where window with within without`) {
+		sqlKeywordSet[w] = true
+	}
+}
+
+// IsSQLKeyword reports whether a word is lexed as a keyword
+func IsSQLKeyword(word string) bool {
+	return sqlKeywordSet[strings.ToLower(word)]
+}
+
+// operatorChars are the characters PostgreSQL operators are made of
+const operatorChars = "+-*/<>=~!@#%^&|`?:"
+
+// LexSQL splits SQL into tokens. Concatenating the tokens' text gives back
+// the input exactly, so the lexer can drive highlighting and rewriting.
+// Unterminated strings and comments run to the end of the input.
+func LexSQL(sql string) []Token {
+	var tokens []Token
+	runes := []rune(sql)
+	n := len(runes)
+	emit := func(kind TokenKind, start, end int) {
+		tokens = append(tokens, Token{Kind: kind, Text: string(runes[start:end])})
+	}
+
+	for i := 0; i < n; {
+		r := runes[i]
+		start := i
+		switch {
+		case unicode.IsSpace(r):
+			for i < n && unicode.IsSpace(runes[i]) {
+				i++
+			}
+			emit(TokenWhitespace, start, i)
+
+		case r == '-' && i+1 < n && runes[i+1] == '-':
+			for i < n && runes[i] != '\n' {
+				i++
+			}
+			emit(TokenComment, start, i)
+
+		case r == '/' && i+1 < n && runes[i+1] == '*':
+			i = blockCommentEnd(runes, i)
+			emit(TokenComment, start, i)
+
+		case r == '\'':
+			i = skipQuoted(runes, i, '\'')
+			emit(TokenString, start, i)
+
+		case (r == 'e' || r == 'E') && i+1 < n && runes[i+1] == '\'':
+			i = escapeStringEnd(runes, i+1)
+			emit(TokenString, start, i)
+
+		case r == '"':
+			i = skipQuoted(runes, i, '"')
+			emit(TokenQuotedIdentifier, start, i)
+
+		case r == '$' && i+1 < n && unicode.IsDigit(runes[i+1]):
+			i++
+			for i < n && unicode.IsDigit(runes[i]) {
+				i++
+			}
+			emit(TokenParameter, start, i)
+
+		case r == '$':
+			if i = skipDollarQuoted(runes, i); i > start+1 {
+				emit(TokenString, start, i)
+			} else {
+				emit(TokenPunctuation, start, i)
+			}
+
+		case r == '{' && i+1 < n && runes[i+1] == '{':
+			if end := strings.Index(string(runes[i:]), "}}"); end > 0 {
+				i += len([]rune(string(runes[i:])[:end])) + 2
+				emit(TokenParameter, start, i)
+			} else {
+				i += 2
+				emit(TokenPunctuation, start, i)
+			}
+
+		case unicode.IsDigit(r) || (r == '.' && i+1 < n && unicode.IsDigit(runes[i+1])):
+			i = numberEnd(runes, i)
+			emit(TokenNumber, start, i)
+
+		case unicode.IsLetter(r) || r == '_':
+			for i < n && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_' || runes[i] == '$') {
+				i++
+			}
+			kind := TokenIdentifier
+			if IsSQLKeyword(string(runes[start:i])) {
+				kind = TokenKeyword
+			}
+			emit(kind, start, i)
+
+		case strings.ContainsRune(operatorChars, r):
+			for i < n && strings.ContainsRune(operatorChars, runes[i]) {
+				// A comment starts a new token
+				if i > start && i+1 < n && (runes[i] == '-' && runes[i+1] == '-' || runes[i] == '/' && runes[i+1] == '*') {
+					break
+				}
+				i++
+			}
+			emit(TokenOperator, start, i)
+
+		default:
+			i++
+			emit(TokenPunctuation, start, i)
+		}
+	}
+	return tokens
+}
+
+// blockCommentEnd returns the index after the /* comment starting at i,
+// which may nest as in PostgreSQL
+func blockCommentEnd(runes []rune, i int) int {
+	depth := 0
+	for i < len(runes) {
+		switch {
+		case runes[i] == '/' && i+1 < len(runes) && runes[i+1] == '*':
+			depth++
+			i += 2
+		case runes[i] == '*' && i+1 < len(runes) && runes[i+1] == '/':
+			depth--
+			i += 2
+			if depth == 0 {
+				return i
+			}
+		default:
+			i++
+		}
+	}
+	return i
+}
+
+// escapeStringEnd returns the index after the E'...' string whose quote is
+// at i, where backslashes escape the next character
+func escapeStringEnd(runes []rune, i int) int {
+	for i++; i < len(runes); i++ {
+		switch {
+		case runes[i] == '\\':
+			i++
+		case runes[i] == '\'':
+			if i+1 < len(runes) && runes[i+1] == '\'' {
+				i++
+				continue
+			}
+			return i + 1
+		}
+	}
+	return len(runes)
+}
+
+// numberEnd returns the index after the numeric literal starting at i
+func numberEnd(runes []rune, i int) int {
+	digits := func() {
+		for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '_') {
+			i++
+		}
+	}
+	digits()
+	if i < len(runes) && runes[i] == '.' {
+		i++
+		digits()
+	}
+	if i < len(runes) && (runes[i] == 'e' || runes[i] == 'E') {
+		j := i + 1
+		if j < len(runes) && (runes[j] == '+' || runes[j] == '-') {
+			j++
+		}
+		if j < len(runes) && unicode.IsDigit(runes[j]) {
+			i = j
+			digits()
+		}
+	}
+	return i
+}
@@ -0,0 +1,60 @@
+package postgres
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLexSQL(t *testing.T) {
+	sql := "SELECT \"Name\", count(*)::int AS n, 'it''s' -- note\n" +
+		"FROM public.roads /* a /* nested */ comment */ WHERE len >= 1.5e3 AND id = $1 AND k = {{key}} AND s = E'a\\'b' AND b = $q$x$q$;"
+
+	tokens := LexSQL(sql)
+
+	var rebuilt strings.Builder
+	for _, tok := range tokens {
+		rebuilt.WriteString(tok.Text)
+	}
+	if rebuilt.String() != sql {
+		t.Fatalf("tokens do not rebuild the input:\n%q", rebuilt.String())
+	}
+
+	kinds := make(map[string]TokenKind)
+	for _, tok := range tokens {
+		if tok.Kind != TokenWhitespace {
+			kinds[tok.Text] = tok.Kind
+		}
+	}
+	want := map[string]TokenKind{
+		"SELECT":                       TokenKeyword,
+		`"Name"`:                       TokenQuotedIdentifier,
+		"count":                        TokenIdentifier,
+		"::":                           TokenOperator,
+		"'it''s'":                      TokenString,
+		"-- note":                      TokenComment,
+		"/* a /* nested */ comment */": TokenComment,
+		"roads":                        TokenIdentifier,
+		">=":                           TokenOperator,
+		"1.5e3":                        TokenNumber,
+		"$1":                           TokenParameter,
+		"{{key}}":                      TokenParameter,
+		`E'a\'b'`:                      TokenString,
+		"$q$x$q$":                      TokenString,
+		";":                            TokenPunctuation,
+	}
+	for text, kind := range want {
+		if got, ok := kinds[text]; !ok || got != kind {
+			t.Errorf("%q: kind %v (found %v), want %v", text, got, ok, kind)
+		}
+	}
+}
+
+func TestLexSQLUnterminated(t *testing.T) {
+	for _, sql := range []string{"SELECT 'open", "SELECT /* open", `SELECT "open`, "SELECT $tag$ open"} {
+		tokens := LexSQL(sql)
+		last := tokens[len(tokens)-1]
+		if last.Kind == TokenPunctuation || !strings.HasSuffix(last.Text, "open") {
+			t.Errorf("%q: expected the literal to run to the end, got %+v", sql, last)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package postgres
+
+import (
+	"fmt"
+	"strings"
+)
+
+// InjectLimit adds LIMIT n to the last statement of sql when it is a query
+// without a row limit of its own, reporting whether it did. A statement
+// that already has LIMIT or FETCH FIRST at its top level, locks rows (FOR
+// UPDATE, which a LIMIT would have to precede), changes the database or is
+// not a query, such as SHOW or EXPLAIN, is left alone. LIMITs of
+// subqueries do not count, and the LIMIT goes before any trailing
+// semicolon and comments.
+func InjectLimit(sql string, n int) (string, bool) {
+	if n <= 0 {
+		return sql, false
+	}
+	tokens := LexSQL(sql)
+	start, end := lastStatement(tokens)
+	if start < 0 || !limitable(tokens[start:end]) {
+		return sql, false
+	}
+
+	var b strings.Builder
+	for _, tok := range tokens[:end] {
+		b.WriteString(tok.Text)
+	}
+	fmt.Fprintf(&b, " LIMIT %d", n)
+	for _, tok := range tokens[end:] {
+		b.WriteString(tok.Text)
+	}
+	return b.String(), true
+}
+
+// HasRowLimit reports whether the last statement of sql limits its rows
+// with LIMIT or FETCH FIRST at its top level
+func HasRowLimit(sql string) bool {
+	tokens := LexSQL(sql)
+	start, end := lastStatement(tokens)
+	if start < 0 {
+		return false
+	}
+	for _, word := range topLevelWords(tokens[start:end]) {
+		if word == "limit" || word == "fetch" {
+			return true
+		}
+	}
+	return false
+}
+
+// lastStatement returns the tokens of the last statement of sql as the
+// index of its first and one past its last token, leaving out trailing
+// semicolons, comments and whitespace. start is -1 when there is none.
+func lastStatement(tokens []Token) (start, end int) {
+	start, end = -1, -1
+	stmtStart, stmtEnd, depth := -1, -1, 0
+	for i, tok := range tokens {
+		switch {
+		case tok.Kind == TokenWhitespace || tok.Kind == TokenComment:
+			continue
+		case tok.Kind == TokenPunctuation && tok.Text == "(":
+			depth++
+		case tok.Kind == TokenPunctuation && tok.Text == ")":
+			depth = max(depth-1, 0)
+		case tok.Kind == TokenPunctuation && tok.Text == ";" && depth == 0:
+			if stmtEnd >= 0 {
+				start, end = stmtStart, stmtEnd
+			}
+			stmtStart, stmtEnd = -1, -1
+			continue
+		}
+		if stmtStart < 0 {
+			stmtStart = i
+		}
+		stmtEnd = i + 1
+	}
+	if stmtEnd >= 0 {
+		start, end = stmtStart, stmtEnd
+	}
+	return start, end
+}
+
+// topLevelWords returns the lowercased keywords and names of a statement
+// outside parentheses
+func topLevelWords(tokens []Token) []string {
+	var words []string
+	depth := 0
+	for _, tok := range tokens {
+		switch {
+		case tok.Kind == TokenPunctuation && tok.Text == "(":
+			depth++
+		case tok.Kind == TokenPunctuation && tok.Text == ")":
+			depth = max(depth-1, 0)
+		case depth == 0 && (tok.Kind == TokenKeyword || tok.Kind == TokenIdentifier):
+			words = append(words, strings.ToLower(tok.Text))
+		}
+	}
+	return words
+}
+
+// limitable reports whether a LIMIT may be added to the end of a statement
+func limitable(tokens []Token) bool {
+	var text strings.Builder
+	for _, tok := range tokens {
+		text.WriteString(tok.Text)
+	}
+	if ClassifyStatement(text.String()) != StatementRead {
+		return false
+	}
+
+	first := strings.ToLower(tokens[0].Text)
+	if first != "select" && first != "with" && first != "values" && first != "table" && first != "(" {
+		return false
+	}
+	for _, word := range topLevelWords(tokens) {
+		switch word {
+		case "limit", "fetch", "for":
+			return false
+		}
+	}
+	return true
+}
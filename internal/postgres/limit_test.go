@@ -0,0 +1,49 @@
+package postgres
+
+import "testing"
+
+func TestInjectLimit(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want string // "" when no LIMIT is added
+	}{
+		{`SELECT * FROM "public"."roads"`, `SELECT * FROM "public"."roads" LIMIT 50`},
+		{"SELECT * FROM roads;", "SELECT * FROM roads LIMIT 50;"},
+		{"SELECT * FROM roads -- all of them\n", "SELECT * FROM roads LIMIT 50 -- all of them\n"},
+		{"SELECT * FROM roads ORDER BY name OFFSET 10", "SELECT * FROM roads ORDER BY name OFFSET 10 LIMIT 50"},
+		{"SELECT * FROM roads WHERE gid IN (SELECT gid FROM closures LIMIT 5)", "SELECT * FROM roads WHERE gid IN (SELECT gid FROM closures LIMIT 5) LIMIT 50"},
+		{"WITH r AS (SELECT * FROM roads) SELECT * FROM r", "WITH r AS (SELECT * FROM roads) SELECT * FROM r LIMIT 50"},
+		{"CREATE TEMP TABLE t AS SELECT 1; SELECT * FROM t", "CREATE TEMP TABLE t AS SELECT 1; SELECT * FROM t LIMIT 50"},
+		{"SELECT * FROM roads LIMIT 10", ""},
+		{"SELECT * FROM roads ORDER BY name FETCH FIRST 10 ROWS ONLY", ""},
+		{"SELECT * FROM roads ORDER BY name\nfetch next 5 rows with ties", ""},
+		{"SELECT * FROM roads FOR UPDATE", ""},
+		{"SELECT 'LIMIT' AS word FROM roads", "SELECT 'LIMIT' AS word FROM roads LIMIT 50"},
+		{"SELECT * INTO backup FROM roads", ""},
+		{"WITH gone AS (DELETE FROM roads RETURNING *) SELECT * FROM gone", ""},
+		{"UPDATE roads SET name = 'Main'", ""},
+		{"SHOW search_path", ""},
+		{"EXPLAIN SELECT * FROM roads", ""},
+		{"", ""},
+		{";", ""},
+	}
+	for _, tt := range tests {
+		got, ok := InjectLimit(tt.sql, 50)
+		if tt.want == "" {
+			if ok || got != tt.sql {
+				t.Errorf("InjectLimit(%q) = %q, %v; want it left alone", tt.sql, got, ok)
+			}
+		} else if !ok || got != tt.want {
+			t.Errorf("InjectLimit(%q) = %q, %v; want %q", tt.sql, got, ok, tt.want)
+		}
+	}
+}
+
+func TestHasRowLimit(t *testing.T) {
+	if !HasRowLimit("SELECT * FROM roads LIMIT 10;") || !HasRowLimit("SELECT * FROM roads FETCH FIRST ROW ONLY") {
+		t.Error("expected a row limit")
+	}
+	if HasRowLimit("SELECT * FROM roads WHERE gid = (SELECT gid FROM closures LIMIT 1)") {
+		t.Error("a subquery's LIMIT does not limit the rows")
+	}
+}
@@ -0,0 +1,236 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Reconnect delays of a notification listener
+const (
+	listenerMinReconnect = 2 * time.Second
+	listenerMaxReconnect = time.Minute
+)
+
+// Notification is a message received on a channel being listened to
+type Notification struct {
+	Channel  string
+	Payload  string
+	PID      int // Backend process that sent it
+	Received time.Time
+}
+
+// ListenerEvent reports a change in the connection of a listener
+type ListenerEvent struct {
+	State ConnState
+	Err   error
+}
+
+// NotificationListener receives NOTIFY messages for a service on its own
+// connection, reconnecting when it drops
+type NotificationListener struct {
+	listener *pq.Listener
+	out      chan Notification
+	done     chan struct{}
+	once     sync.Once
+
+	mu       sync.Mutex
+	channels map[string]bool
+}
+
+// Listen opens a listener for the service, dialing through its SSH tunnel
+// when one is set. onEvent, when set, is called from the listener's
+// goroutine as the connection is made, lost and restored.
+func (s *ServiceEntry) Listen(onEvent func(ListenerEvent)) *NotificationListener {
+	callback := func(event pq.ListenerEventType, err error) {
+		if onEvent == nil {
+			return
+		}
+		switch event {
+		case pq.ListenerEventConnected, pq.ListenerEventReconnected:
+			onEvent(ListenerEvent{State: ConnConnected})
+		case pq.ListenerEventDisconnected:
+			onEvent(ListenerEvent{State: ConnReconnecting, Err: err})
+		case pq.ListenerEventConnectionAttemptFailed:
+			onEvent(ListenerEvent{State: ConnDisconnected, Err: err})
+		}
+	}
+
+	var listener *pq.Listener
+	if s.SSHHost == "" {
+		listener = pq.NewListener(s.ConnectionString(), listenerMinReconnect, listenerMaxReconnect, callback)
+	} else {
+		listener = pq.NewDialListener(sshDialer{target: sshTargetFor(s)}, s.ConnectionString(),
+			listenerMinReconnect, listenerMaxReconnect, callback)
+	}
+
+	l := &NotificationListener{
+		listener: listener,
+		out:      make(chan Notification, 100),
+		done:     make(chan struct{}),
+		channels: make(map[string]bool),
+	}
+	go l.run()
+	return l
+}
+
+// run forwards notifications until the listener is closed. The nil
+// notification pq sends after reconnecting is dropped; the reconnect is
+// reported through the event callback instead.
+func (l *NotificationListener) run() {
+	defer close(l.out)
+	for {
+		select {
+		case n, ok := <-l.listener.Notify:
+			if !ok {
+				return
+			}
+			if n == nil {
+				continue
+			}
+			notification := Notification{Channel: n.Channel, Payload: n.Extra, PID: n.BePid, Received: time.Now()}
+			select {
+			case l.out <- notification:
+			case <-l.done:
+				return
+			}
+		case <-l.done:
+			return
+		}
+	}
+}
+
+// Notifications returns the channel notifications arrive on; it is closed
+// when the listener is
+func (l *NotificationListener) Notifications() <-chan Notification {
+	return l.out
+}
+
+// ListenTo starts listening on a channel. Channel names are case-sensitive,
+// as when quoted in LISTEN.
+func (l *NotificationListener) ListenTo(channel string) error {
+	if err := l.listener.Listen(channel); err != nil && err != pq.ErrChannelAlreadyOpen {
+		return err
+	}
+	l.mu.Lock()
+	l.channels[channel] = true
+	l.mu.Unlock()
+	return nil
+}
+
+// StopListening stops listening on a channel
+func (l *NotificationListener) StopListening(channel string) error {
+	if err := l.listener.Unlisten(channel); err != nil && err != pq.ErrChannelNotOpen {
+		return err
+	}
+	l.mu.Lock()
+	delete(l.channels, channel)
+	l.mu.Unlock()
+	return nil
+}
+
+// Channels returns the channels being listened on, sorted
+func (l *NotificationListener) Channels() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	channels := make([]string, 0, len(l.channels))
+	for c := range l.channels {
+		channels = append(channels, c)
+	}
+	sort.Strings(channels)
+	return channels
+}
+
+// Close stops listening and closes the connection
+func (l *NotificationListener) Close() error {
+	var err error
+	l.once.Do(func() {
+		close(l.done)
+		err = l.listener.Close()
+	})
+	return err
+}
+
+// Patterns finding the channels a function notifies
+var (
+	pgNotifyPattern  = regexp.MustCompile(`(?i)\bpg_notify\s*\(\s*'((?:[^']|'')+)'`)
+	notifyCmdPattern = regexp.MustCompile(`(?i)\bNOTIFY\s+("(?:[^"]|"")+"|[a-z_][a-z0-9_$]*)`)
+)
+
+// DiscoverChannels returns the channels notified by functions in the
+// database, found in their source by pg_notify calls and NOTIFY commands
+// with literal channel names
+func DiscoverChannels(ctx context.Context, db *sql.DB) ([]string, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT p.prosrc
+		FROM pg_proc p
+		JOIN pg_namespace n ON n.oid = p.pronamespace
+		WHERE n.nspname NOT IN ('pg_catalog', 'information_schema')
+		  AND p.prosrc ILIKE '%notify%'`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	seen := make(map[string]bool)
+	var channels []string
+	for rows.Next() {
+		var src string
+		if err := rows.Scan(&src); err != nil {
+			return nil, err
+		}
+		for _, c := range NotifiedChannels(src) {
+			if !seen[c] {
+				seen[c] = true
+				channels = append(channels, c)
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	sort.Strings(channels)
+	return channels, nil
+}
+
+// NotifiedChannels returns the channels a function body notifies, in the
+// order they appear. Unquoted NOTIFY names fold to lower case.
+func NotifiedChannels(src string) []string {
+	var channels []string
+	seen := make(map[string]bool)
+	add := func(c string) {
+		if c != "" && !seen[c] {
+			seen[c] = true
+			channels = append(channels, c)
+		}
+	}
+
+	type match struct {
+		at      int
+		channel string
+	}
+	var matches []match
+	for _, m := range pgNotifyPattern.FindAllStringSubmatchIndex(src, -1) {
+		matches = append(matches, match{m[0], strings.ReplaceAll(src[m[2]:m[3]], "''", "'")})
+	}
+	for _, m := range notifyCmdPattern.FindAllStringSubmatchIndex(src, -1) {
+		name := src[m[2]:m[3]]
+		if strings.HasPrefix(name, `"`) {
+			name = strings.ReplaceAll(name[1:len(name)-1], `""`, `"`)
+		} else {
+			name = strings.ToLower(name)
+		}
+		matches = append(matches, match{m[0], name})
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i].at < matches[j].at })
+	for _, m := range matches {
+		add(m.channel)
+	}
+	return channels
+}
@@ -0,0 +1,30 @@
+package postgres
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNotifiedChannels(t *testing.T) {
+	tests := []struct {
+		src      string
+		expected []string
+	}{
+		{
+			`BEGIN PERFORM pg_notify('orders_changed', row_to_json(NEW)::text); RETURN NEW; END;`,
+			[]string{"orders_changed"},
+		},
+		{
+			"BEGIN\n  NOTIFY Stock_Alerts;\n  NOTIFY \"Price Updates\", 'x';\n  PERFORM PG_NOTIFY( 'it''s', '');\n  NOTIFY stock_alerts;\nEND",
+			[]string{"stock_alerts", "Price Updates", "it's"},
+		},
+		{`SELECT pg_notify(channel_name, payload)`, nil},
+		{`SELECT 1`, nil},
+	}
+
+	for _, tt := range tests {
+		if got := NotifiedChannels(tt.src); !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("NotifiedChannels(%q): expected %q, got %q", tt.src, tt.expected, got)
+		}
+	}
+}
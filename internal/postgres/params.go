@@ -0,0 +1,176 @@
+package postgres
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// templateParamName matches a valid {{name}} placeholder name
+var templateParamName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// QueryTemplate is SQL whose {{name}} placeholders have been rewritten to
+// $n bind parameters, so values are never interpolated into the SQL text
+type QueryTemplate struct {
+	SQL    string   // SQL with placeholders replaced by $1..$n
+	Params []string // Parameter names in $n order; a repeated name reuses its number
+}
+
+// ParseTemplate rewrites the {{name}} placeholders of a query. Placeholders
+// inside comments, quoted identifiers and dollar-quoted strings are left
+// alone; a string literal holding only a placeholder, such as '{{region}}',
+// becomes a parameter too.
+func ParseTemplate(query string) QueryTemplate {
+	var t QueryTemplate
+	var out strings.Builder
+	index := make(map[string]int)
+
+	placeholder := func(name string) {
+		n, ok := index[name]
+		if !ok {
+			t.Params = append(t.Params, name)
+			n = len(t.Params)
+			index[name] = n
+		}
+		fmt.Fprintf(&out, "$%d", n)
+	}
+
+	for i := 0; i < len(query); {
+		rest := query[i:]
+		switch {
+		case strings.HasPrefix(rest, "{{"):
+			if end := strings.Index(rest, "}}"); end > 0 {
+				if name := strings.TrimSpace(rest[2:end]); templateParamName.MatchString(name) {
+					placeholder(name)
+					i += end + 2
+					continue
+				}
+			}
+			out.WriteString("{{")
+			i += 2
+
+		case rest[0] == '\'':
+			end := stringLiteralEnd(rest)
+			literal := rest[:end]
+			if name, ok := literalPlaceholder(literal); ok {
+				placeholder(name)
+			} else {
+				out.WriteString(literal)
+			}
+			i += end
+
+		case rest[0] == '"':
+			end := strings.IndexByte(rest[1:], '"')
+			if end < 0 {
+				end = len(rest) - 2
+			}
+			out.WriteString(rest[:end+2])
+			i += end + 2
+
+		case strings.HasPrefix(rest, "--"):
+			end := strings.IndexByte(rest, '\n')
+			if end < 0 {
+				end = len(rest)
+			}
+			out.WriteString(rest[:end])
+			i += end
+
+		case strings.HasPrefix(rest, "/*"):
+			end := strings.Index(rest, "*/")
+			if end < 0 {
+				end = len(rest)
+			} else {
+				end += 2
+			}
+			out.WriteString(rest[:end])
+			i += end
+
+		case rest[0] == '$':
+			end := dollarQuoteEnd(rest)
+			out.WriteString(rest[:end])
+			i += end
+
+		default:
+			out.WriteByte(rest[0])
+			i++
+		}
+	}
+
+	t.SQL = out.String()
+	return t
+}
+
+// stringLiteralEnd returns the length of the '...' literal at the start of
+// s, allowing for doubled quotes
+func stringLiteralEnd(s string) int {
+	for i := 1; i < len(s); i++ {
+		if s[i] == '\'' {
+			if i+1 < len(s) && s[i+1] == '\'' {
+				i++
+				continue
+			}
+			return i + 1
+		}
+	}
+	return len(s)
+}
+
+// literalPlaceholder reports whether a string literal is exactly '{{name}}'
+func literalPlaceholder(literal string) (string, bool) {
+	inner := strings.TrimSpace(strings.Trim(literal, "'"))
+	if len(literal) < 2 || !strings.HasPrefix(inner, "{{") || !strings.HasSuffix(inner, "}}") {
+		return "", false
+	}
+	name := strings.TrimSpace(inner[2 : len(inner)-2])
+	return name, templateParamName.MatchString(name)
+}
+
+// dollarQuoteEnd returns the length of the dollar-quoted string at the start
+// of s, or 1 when the $ does not open one (e.g. a $1 parameter)
+func dollarQuoteEnd(s string) int {
+	tagEnd := strings.IndexByte(s[1:], '$')
+	if tagEnd < 0 {
+		return 1
+	}
+	tag := s[:tagEnd+2]
+	if tagEnd > 0 && !templateParamName.MatchString(tag[1:len(tag)-1]) {
+		return 1
+	}
+	closing := strings.Index(s[len(tag):], tag)
+	if closing < 0 {
+		return len(s)
+	}
+	return len(tag) + closing + len(tag)
+}
+
+// HasTemplateParams reports whether a query contains {{name}} placeholders
+func HasTemplateParams(query string) bool {
+	return len(ParseTemplate(query).Params) > 0
+}
+
+// Args returns the bind arguments for the template in $n order
+func (t QueryTemplate) Args(values map[string]string) ([]any, error) {
+	args := make([]any, 0, len(t.Params))
+	for _, name := range t.Params {
+		v, ok := values[name]
+		if !ok {
+			return nil, fmt.Errorf("missing value for {{%s}}", name)
+		}
+		args = append(args, v)
+	}
+	return args, nil
+}
+
+// BindTemplate rewrites a query's placeholders and returns it with its
+// bind arguments. Queries without placeholders are returned unchanged.
+func BindTemplate(query string, values map[string]string) (string, []any, error) {
+	t := ParseTemplate(query)
+	if len(t.Params) == 0 {
+		return query, nil, nil
+	}
+	args, err := t.Args(values)
+	if err != nil {
+		return "", nil, err
+	}
+	return t.SQL, args, nil
+}
@@ -0,0 +1,74 @@
+package postgres
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTemplate(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      string
+		wantSQL    string
+		wantParams []string
+	}{
+		{
+			name:       "plain placeholders",
+			query:      "SELECT * FROM parcels WHERE region = {{region}} AND area > {{ min_area }}",
+			wantSQL:    "SELECT * FROM parcels WHERE region = $1 AND area > $2",
+			wantParams: []string{"region", "min_area"},
+		},
+		{
+			name:       "repeated name reuses its number",
+			query:      "SELECT {{a}}, {{b}}, {{a}}",
+			wantSQL:    "SELECT $1, $2, $1",
+			wantParams: []string{"a", "b"},
+		},
+		{
+			name:       "quoted placeholder becomes a parameter",
+			query:      "SELECT * FROM t WHERE name = '{{name}}'",
+			wantSQL:    "SELECT * FROM t WHERE name = $1",
+			wantParams: []string{"name"},
+		},
+		{
+			name:    "literals, identifiers and comments are left alone",
+			query:   "SELECT 'it''s {{x}} here', \"{{col}}\", $q${{y}}$q$ -- {{z}}\n/* {{w}} */",
+			wantSQL: "SELECT 'it''s {{x}} here', \"{{col}}\", $q${{y}}$q$ -- {{z}}\n/* {{w}} */",
+		},
+		{
+			name:    "invalid names are not placeholders",
+			query:   "SELECT '{{}}', {{1bad}}",
+			wantSQL: "SELECT '{{}}', {{1bad}}",
+		},
+	}
+
+	for _, tt := range tests {
+		got := ParseTemplate(tt.query)
+		if got.SQL != tt.wantSQL {
+			t.Errorf("%s: SQL = %q, want %q", tt.name, got.SQL, tt.wantSQL)
+		}
+		if !reflect.DeepEqual(got.Params, tt.wantParams) {
+			t.Errorf("%s: Params = %v, want %v", tt.name, got.Params, tt.wantParams)
+		}
+	}
+}
+
+func TestBindTemplate(t *testing.T) {
+	query, args, err := BindTemplate("SELECT * FROM t WHERE a = {{a}} OR b = {{b}}", map[string]string{"a": "1", "b": "x'; DROP TABLE t; --"})
+	if err != nil {
+		t.Fatalf("BindTemplate error: %v", err)
+	}
+	if query != "SELECT * FROM t WHERE a = $1 OR b = $2" {
+		t.Errorf("query = %q", query)
+	}
+	if !reflect.DeepEqual(args, []any{"1", "x'; DROP TABLE t; --"}) {
+		t.Errorf("args = %v", args)
+	}
+
+	if _, _, err := BindTemplate("SELECT {{missing}}", nil); err == nil {
+		t.Error("expected error for a missing value")
+	}
+	if query, args, _ := BindTemplate("SELECT 1", nil); query != "SELECT 1" || args != nil {
+		t.Errorf("query without placeholders should be unchanged, got %q %v", query, args)
+	}
+}
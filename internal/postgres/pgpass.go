@@ -0,0 +1,203 @@
+package postgres
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// EnvServiceName is the name of the service built from libpq environment variables
+const EnvServiceName = "environment"
+
+// libpq defaults used when neither the service nor the environment sets a value
+const (
+	defaultHost = "localhost"
+	defaultPort = "5432"
+)
+
+// PgpassEntry is one host:port:database:username:password line of a
+// password file. Any of the first four fields may be the wildcard "*".
+type PgpassEntry struct {
+	Host     string
+	Port     string
+	Database string
+	User     string
+	Password string
+}
+
+// PgpassPath returns the password file location: PGPASSFILE if set,
+// otherwise ~/.pgpass (%APPDATA%\postgresql\pgpass.conf on Windows)
+func PgpassPath() string {
+	if path := os.Getenv("PGPASSFILE"); path != "" {
+		return path
+	}
+	if runtime.GOOS == "windows" {
+		if appData := os.Getenv("APPDATA"); appData != "" {
+			return filepath.Join(appData, "postgresql", "pgpass.conf")
+		}
+		return ""
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		return filepath.Join(home, ".pgpass")
+	}
+	return ""
+}
+
+// parsePgpass reads password file entries, skipping comments and malformed lines
+func parsePgpass(r io.Reader) ([]PgpassEntry, error) {
+	var entries []PgpassEntry
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := splitPgpassLine(line)
+		if len(fields) != 5 {
+			continue
+		}
+		entries = append(entries, PgpassEntry{
+			Host:     fields[0],
+			Port:     fields[1],
+			Database: fields[2],
+			User:     fields[3],
+			Password: fields[4],
+		})
+	}
+	return entries, scanner.Err()
+}
+
+// splitPgpassLine splits a line on unescaped colons, unescaping \: and \\
+func splitPgpassLine(line string) []string {
+	var fields []string
+	var field strings.Builder
+	escaped := false
+	for _, r := range line {
+		switch {
+		case escaped:
+			field.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == ':' && len(fields) < 4:
+			fields = append(fields, field.String())
+			field.Reset()
+		default:
+			field.WriteRune(r)
+		}
+	}
+	return append(fields, field.String())
+}
+
+// matches reports whether the entry applies to the given connection parameters
+func (e PgpassEntry) matches(host, port, database, username string) bool {
+	match := func(pattern, value string) bool {
+		return pattern == "*" || pattern == value
+	}
+	return match(e.Host, host) && match(e.Port, port) && match(e.Database, database) && match(e.User, username)
+}
+
+// LookupPgpass returns the password of the first matching password file
+// entry. Like libpq, a file readable by group or others is ignored.
+func LookupPgpass(host, port, database, username string) (string, bool) {
+	path := PgpassPath()
+	if path == "" {
+		return "", false
+	}
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return "", false
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm()&0077 != 0 {
+		return "", false
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return "", false
+	}
+	defer file.Close()
+
+	entries, err := parsePgpass(file)
+	if err != nil {
+		return "", false
+	}
+	for _, e := range entries {
+		if e.matches(host, port, database, username) {
+			return e.Password, true
+		}
+	}
+	return "", false
+}
+
+// Resolved returns a copy of the service with missing connection parameters
+// filled in the way libpq does: from PGHOST, PGPORT, PGDATABASE, PGUSER,
+// PGPASSWORD, PGSSLMODE and the PGSSLCERT, PGSSLKEY and PGSSLROOTCERT
+// certificate files, then the password from the password file.
+func (s *ServiceEntry) Resolved() ServiceEntry {
+	r := *s
+	fill := func(field *string, env string) {
+		if *field == "" {
+			*field = os.Getenv(env)
+		}
+	}
+	fill(&r.Host, "PGHOST")
+	fill(&r.Port, "PGPORT")
+	fill(&r.DBName, "PGDATABASE")
+	fill(&r.User, "PGUSER")
+	fill(&r.Password, "PGPASSWORD")
+	fill(&r.SSLMode, "PGSSLMODE")
+	fill(&r.SSLCert, "PGSSLCERT")
+	fill(&r.SSLKey, "PGSSLKEY")
+	fill(&r.SSLRootCert, "PGSSLROOTCERT")
+
+	if r.Password == "" {
+		host := r.Host
+		if host == "" || strings.HasPrefix(host, "/") {
+			// Unix socket connections match "localhost" entries
+			host = defaultHost
+		}
+		port := r.Port
+		if port == "" {
+			port = defaultPort
+		}
+		username := r.User
+		if username == "" {
+			if u, err := user.Current(); err == nil {
+				username = u.Username
+			}
+		}
+		database := r.DBName
+		if database == "" {
+			database = username
+		}
+		if password, ok := LookupPgpass(host, port, database, username); ok {
+			r.Password = password
+		}
+	}
+	return r
+}
+
+// EnvServiceEntry returns a service built purely from libpq environment
+// variables, for environments without a pg_service.conf. It reports false
+// when neither PGHOST nor PGDATABASE is set.
+func EnvServiceEntry() (ServiceEntry, bool) {
+	if os.Getenv("PGHOST") == "" && os.Getenv("PGDATABASE") == "" {
+		return ServiceEntry{}, false
+	}
+	// The password is left to Resolved so it is never copied into the
+	// service list, and so never written out if the entry is edited
+	return ServiceEntry{
+		Name:    EnvServiceName,
+		Host:    os.Getenv("PGHOST"),
+		Port:    os.Getenv("PGPORT"),
+		DBName:  os.Getenv("PGDATABASE"),
+		User:    os.Getenv("PGUSER"),
+		SSLMode: os.Getenv("PGSSLMODE"),
+		Options: make(map[string]string),
+	}, true
+}
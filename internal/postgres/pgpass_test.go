@@ -0,0 +1,106 @@
+package postgres
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParsePgpass(t *testing.T) {
+	input := `# comment
+db.example.com:5432:sales:alice:s3cret
+*:*:*:bob:pa\:ss\\word
+malformed:line
+
+localhost:5433:*:*:wild
+`
+	entries, err := parsePgpass(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("parsePgpass error: %v", err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("expected 3 entries, got %d", len(entries))
+	}
+	if entries[1].Password != `pa:ss\word` {
+		t.Errorf("escaped password = %q, want %q", entries[1].Password, `pa:ss\word`)
+	}
+
+	tests := []struct {
+		host, port, db, user string
+		want                 int // index of the first matching entry, -1 for none
+	}{
+		{"db.example.com", "5432", "sales", "alice", 0},
+		{"db.example.com", "5432", "sales", "bob", 1},
+		{"localhost", "5433", "any", "carol", 2},
+		{"localhost", "5432", "any", "carol", -1},
+	}
+	for _, tt := range tests {
+		got := -1
+		for i, e := range entries {
+			if e.matches(tt.host, tt.port, tt.db, tt.user) {
+				got = i
+				break
+			}
+		}
+		if got != tt.want {
+			t.Errorf("match(%s,%s,%s,%s) = %d, want %d", tt.host, tt.port, tt.db, tt.user, got, tt.want)
+		}
+	}
+}
+
+func TestResolvedUsesEnvironmentAndPgpass(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pgpass")
+	if err := os.WriteFile(path, []byte("envhost:6432:envdb:envuser:from-pgpass\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PGPASSFILE", path)
+	t.Setenv("PGHOST", "envhost")
+	t.Setenv("PGPORT", "6432")
+	t.Setenv("PGDATABASE", "envdb")
+	t.Setenv("PGUSER", "envuser")
+	t.Setenv("PGPASSWORD", "")
+	t.Setenv("PGSSLMODE", "")
+
+	service := ServiceEntry{Name: "svc"}
+	r := service.Resolved()
+	if r.Host != "envhost" || r.DBName != "envdb" || r.User != "envuser" {
+		t.Errorf("environment not applied: %+v", r)
+	}
+	if r.Password != "from-pgpass" {
+		t.Errorf("Password = %q, want password from pgpass file", r.Password)
+	}
+
+	// Values from the service file win over the environment
+	service = ServiceEntry{Name: "svc", Host: "filehost"}
+	if r := service.Resolved(); r.Host != "filehost" || r.Password != "" {
+		t.Errorf("service values should take precedence: %+v", r)
+	}
+
+	// PGPASSWORD wins over the password file
+	t.Setenv("PGPASSWORD", "from-env")
+	service = ServiceEntry{Name: "svc"}
+	if r := service.Resolved(); r.Password != "from-env" {
+		t.Errorf("Password = %q, want PGPASSWORD", r.Password)
+	}
+}
+
+func TestLookupPgpassIgnoresInsecureFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pgpass")
+	if err := os.WriteFile(path, []byte("*:*:*:*:secret\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PGPASSFILE", path)
+
+	if _, ok := LookupPgpass("localhost", "5432", "db", "user"); ok {
+		t.Error("expected world-readable password file to be ignored")
+	}
+}
+
+func TestConnectionStringQuotesValues(t *testing.T) {
+	t.Setenv("PGPASSFILE", filepath.Join(t.TempDir(), "none"))
+	service := ServiceEntry{Name: "q", Host: "localhost", Password: "it's a secret"}
+	if connStr := service.ConnectionString(); !strings.Contains(connStr, `password='it\'s a secret'`) {
+		t.Errorf("password not quoted: %s", connStr)
+	}
+}
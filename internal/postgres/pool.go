@@ -0,0 +1,287 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Pool defaults used when PoolOptions leaves a value unset
+const (
+	DefaultPoolMaxConns      = 4
+	DefaultHealthCheckPeriod = 30 * time.Second
+	healthCheckTimeout       = 5 * time.Second
+	maxReconnectBackoff      = time.Minute
+)
+
+// ConnState is the health of a managed connection pool
+type ConnState int
+
+const (
+	ConnConnecting ConnState = iota
+	ConnConnected
+	ConnReconnecting
+	ConnDisconnected
+)
+
+// String returns the display name of the state
+func (s ConnState) String() string {
+	switch s {
+	case ConnConnecting:
+		return "connecting"
+	case ConnConnected:
+		return "connected"
+	case ConnReconnecting:
+		return "reconnecting"
+	default:
+		return "disconnected"
+	}
+}
+
+// ConnStatus is a snapshot of a pool's health
+type ConnStatus struct {
+	State     ConnState
+	Err       error         // Last health check error (nil when connected)
+	Latency   time.Duration // Round trip of the last successful ping
+	CheckedAt time.Time
+	OpenConns int
+	InUse     int
+}
+
+// PoolOptions configures a managed connection pool
+type PoolOptions struct {
+	MaxConns          int           // Maximum open connections (idle connections are capped at half)
+	HealthCheckPeriod time.Duration // Interval between background pings
+}
+
+// ConnectionManager owns the connection pool of one service. The *sql.DB it
+// hands out never changes, so callers may keep it; the manager pings it in
+// the background and, after a failure, flushes dead idle connections and
+// retries with backoff until the server is reachable again.
+type ConnectionManager struct {
+	service ServiceEntry
+	opts    PoolOptions
+
+	mu     sync.RWMutex
+	db     *sql.DB
+	status ConnStatus
+	closed bool
+
+	stop      chan struct{}
+	closeOnce sync.Once
+}
+
+// managers holds the shared connection manager of each service
+var (
+	managersMu sync.Mutex
+	managers   = make(map[string]*ConnectionManager)
+)
+
+// SharedConnection returns the connection manager for a service, creating
+// and starting it if needed. A manager whose service settings have changed
+// is closed and replaced.
+func SharedConnection(service ServiceEntry, opts PoolOptions) *ConnectionManager {
+	managersMu.Lock()
+	defer managersMu.Unlock()
+
+	if m, ok := managers[service.Name]; ok {
+		if sameService(m.service, service) && m.opts == opts {
+			return m
+		}
+		m.Close()
+	}
+	m := newConnectionManager(service, opts)
+	managers[service.Name] = m
+	go m.run()
+	return m
+}
+
+// CloseConnections closes every shared connection manager
+func CloseConnections() {
+	managersMu.Lock()
+	defer managersMu.Unlock()
+
+	for name, m := range managers {
+		m.Close()
+		delete(managers, name)
+	}
+}
+
+// sameService reports whether two entries describe the same connection
+func sameService(a, b ServiceEntry) bool {
+	if len(a.Options) != len(b.Options) {
+		return false
+	}
+	for k, v := range a.Options {
+		if b.Options[k] != v {
+			return false
+		}
+	}
+	return a.Host == b.Host && a.Port == b.Port && a.DBName == b.DBName &&
+		a.User == b.User && a.Password == b.Password && a.SSLMode == b.SSLMode &&
+		a.SSLCert == b.SSLCert && a.SSLKey == b.SSLKey && a.SSLRootCert == b.SSLRootCert &&
+		a.SSHHost == b.SSHHost && a.SSHUser == b.SSHUser && a.SSHKey == b.SSHKey
+}
+
+// newConnectionManager creates a manager without starting its health loop
+func newConnectionManager(service ServiceEntry, opts PoolOptions) *ConnectionManager {
+	if opts.MaxConns <= 0 {
+		opts.MaxConns = DefaultPoolMaxConns
+	}
+	if opts.HealthCheckPeriod <= 0 {
+		opts.HealthCheckPeriod = DefaultHealthCheckPeriod
+	}
+	return &ConnectionManager{
+		service: service,
+		opts:    opts,
+		status:  ConnStatus{State: ConnConnecting},
+		stop:    make(chan struct{}),
+	}
+}
+
+// DB returns the pool, opening it on first use. It fails only if the
+// service cannot be opened at all; reachability is reported by Status.
+func (m *ConnectionManager) DB() (*sql.DB, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return nil, fmt.Errorf("connection to %s is closed", m.service.Name)
+	}
+	if m.db != nil {
+		return m.db, nil
+	}
+	db, err := m.service.Connect()
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(m.opts.MaxConns)
+	db.SetMaxIdleConns(max(m.opts.MaxConns/2, 1))
+	db.SetConnMaxIdleTime(5 * time.Minute)
+	m.db = db
+	return db, nil
+}
+
+// Connect opens the pool and waits for a successful ping
+func (m *ConnectionManager) Connect(ctx context.Context) (*sql.DB, error) {
+	db, err := m.DB()
+	if err != nil {
+		m.setStatus(ConnDisconnected, err, 0)
+		return nil, err
+	}
+	if err := m.Check(ctx); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+// Check pings the server and updates the status. After a failure, idle
+// connections are flushed so the next queries open fresh ones.
+func (m *ConnectionManager) Check(ctx context.Context) error {
+	db, err := m.DB()
+	if err != nil {
+		m.setStatus(ConnDisconnected, err, 0)
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+	start := time.Now()
+	if err := db.PingContext(ctx); err != nil {
+		m.flushIdle(db)
+		state := ConnReconnecting
+		if m.Status().State == ConnConnecting {
+			state = ConnDisconnected
+		}
+		m.setStatus(state, err, 0)
+		return err
+	}
+	m.setStatus(ConnConnected, nil, time.Since(start))
+	return nil
+}
+
+// flushIdle closes idle connections, which may all be dead after an outage
+func (m *ConnectionManager) flushIdle(db *sql.DB) {
+	db.SetMaxIdleConns(0)
+	db.SetMaxIdleConns(max(m.opts.MaxConns/2, 1))
+}
+
+// setStatus records the outcome of a connection attempt
+func (m *ConnectionManager) setStatus(state ConnState, err error, latency time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.status.State = state
+	m.status.Err = err
+	m.status.CheckedAt = time.Now()
+	if err == nil {
+		m.status.Latency = latency
+	}
+}
+
+// Status returns a snapshot of the pool's health
+func (m *ConnectionManager) Status() ConnStatus {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	status := m.status
+	if m.db != nil {
+		stats := m.db.Stats()
+		status.OpenConns = stats.OpenConnections
+		status.InUse = stats.InUse
+	}
+	return status
+}
+
+// Summary returns a short human readable status, e.g. "connected 3ms"
+func (s ConnStatus) Summary() string {
+	switch s.State {
+	case ConnConnected:
+		return fmt.Sprintf("connected %dms", s.Latency.Milliseconds())
+	case ConnReconnecting:
+		return "reconnecting…"
+	default:
+		return s.State.String()
+	}
+}
+
+// run pings the server every HealthCheckPeriod, retrying failures with
+// exponential backoff until Close is called
+func (m *ConnectionManager) run() {
+	var backoff time.Duration // Non-zero while the server is unreachable
+	for {
+		wait := m.opts.HealthCheckPeriod
+		if backoff > 0 {
+			wait = backoff
+		}
+		select {
+		case <-m.stop:
+			return
+		case <-time.After(wait):
+		}
+
+		if err := m.Check(context.Background()); err != nil {
+			backoff = min(max(backoff*2, time.Second), maxReconnectBackoff)
+		} else {
+			backoff = 0
+		}
+	}
+}
+
+// Close stops the health checks and closes the pool
+func (m *ConnectionManager) Close() {
+	m.closeOnce.Do(func() {
+		close(m.stop)
+
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		m.closed = true
+		if m.db != nil {
+			m.db.Close()
+			m.db = nil
+		}
+		m.status.State = ConnDisconnected
+	})
+}
@@ -0,0 +1,56 @@
+package postgres
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSharedConnectionReusesManager(t *testing.T) {
+	defer CloseConnections()
+
+	service := ServiceEntry{Name: "pool-test", Host: "localhost", DBName: "db", Options: map[string]string{}}
+	opts := PoolOptions{MaxConns: 2, HealthCheckPeriod: time.Hour}
+
+	first := SharedConnection(service, opts)
+	if again := SharedConnection(service, opts); again != first {
+		t.Error("expected the same manager for an unchanged service")
+	}
+
+	service.Port = "6543"
+	replaced := SharedConnection(service, opts)
+	if replaced == first {
+		t.Fatal("expected a new manager after the service changed")
+	}
+	if _, err := first.DB(); err == nil {
+		t.Error("replaced manager should be closed")
+	}
+	if state := first.Status().State; state != ConnDisconnected {
+		t.Errorf("replaced manager state = %v, want disconnected", state)
+	}
+}
+
+func TestConnectionManagerDefaults(t *testing.T) {
+	m := newConnectionManager(ServiceEntry{Name: "defaults"}, PoolOptions{})
+	if m.opts.MaxConns != DefaultPoolMaxConns || m.opts.HealthCheckPeriod != DefaultHealthCheckPeriod {
+		t.Errorf("unexpected defaults: %+v", m.opts)
+	}
+	if m.Status().State != ConnConnecting {
+		t.Errorf("new manager should start connecting")
+	}
+}
+
+func TestConnStatusSummary(t *testing.T) {
+	tests := []struct {
+		status ConnStatus
+		want   string
+	}{
+		{ConnStatus{State: ConnConnected, Latency: 3 * time.Millisecond}, "connected 3ms"},
+		{ConnStatus{State: ConnReconnecting}, "reconnecting…"},
+		{ConnStatus{State: ConnDisconnected}, "disconnected"},
+	}
+	for _, tt := range tests {
+		if got := tt.status.Summary(); got != tt.want {
+			t.Errorf("Summary() = %q, want %q", got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/kartoza/kartoza-pg-ai/internal/config"
+)
+
+// ReplayOutcome is what running a query of the history again found
+type ReplayOutcome int
+
+const (
+	ReplaySame    ReplayOutcome = iota // Runs, returning as many rows as before
+	ReplayChanged                      // Runs, returning a different number of rows
+	ReplayFailed                       // No longer runs
+	ReplaySkipped                      // Not run: it changes data, has parameters, ...
+)
+
+// String returns the outcome as replays are reported
+func (o ReplayOutcome) String() string {
+	switch o {
+	case ReplaySame:
+		return "ok"
+	case ReplayChanged:
+		return "changed"
+	case ReplayFailed:
+		return "failed"
+	default:
+		return "skipped"
+	}
+}
+
+// ReplayOptions set how history is replayed
+type ReplayOptions struct {
+	ExplainOnly bool // Only plan the queries, which finds missing tables and columns
+	CountLimit  int  // Rows counted before the planner's estimate is taken, as CountRows
+}
+
+// ReplayResult is a history entry run again
+type ReplayResult struct {
+	Entry     config.QueryHistoryEntry
+	SQL       string // The SQL run: as edited, if it was
+	Outcome   ReplayOutcome
+	Rows      int  // Rows returned now; 0 when only planned or not run
+	Estimated bool // Rows is the planner's estimate
+	Reason    string
+}
+
+// ReplayHistory runs the successful queries of history entries again on
+// db, in read-only transactions bound by the statement timeout of ctx,
+// and compares the rows they return with the rows recorded. A query asked
+// several times is run once, for its first entry. Queries that change data
+// or have template parameters, scripts of several statements and
+// statements EXPLAIN does not accept, such as SHOW, are skipped. progress,
+// when not nil, is called with each result as it is found. Replaying stops
+// when ctx is done.
+func ReplayHistory(ctx context.Context, db *sql.DB, entries []config.QueryHistoryEntry, opts ReplayOptions, progress func(ReplayResult)) []ReplayResult {
+	var results []ReplayResult
+	seen := make(map[string]bool)
+	for _, entry := range entries {
+		if ctx.Err() != nil {
+			break
+		}
+		sqlText := entry.EditedSQL
+		if sqlText == "" {
+			sqlText = entry.GeneratedSQL
+		}
+		if !entry.Success || sqlText == "" || seen[sqlText] {
+			continue
+		}
+		seen[sqlText] = true
+
+		result := replayEntry(ctx, db, entry, sqlText, opts)
+		results = append(results, result)
+		if progress != nil {
+			progress(result)
+		}
+	}
+	return results
+}
+
+// replayEntry runs the SQL of one history entry again
+func replayEntry(ctx context.Context, db *sql.DB, entry config.QueryHistoryEntry, sqlText string, opts ReplayOptions) ReplayResult {
+	result := ReplayResult{Entry: entry, SQL: sqlText, Outcome: ReplaySkipped}
+	switch class := ClassifyStatement(sqlText); {
+	case class.IsMutating():
+		result.Reason = fmt.Sprintf("%s statement", class)
+		return result
+	case HasTemplateParams(sqlText):
+		result.Reason = "has template parameters"
+		return result
+	case len(SplitStatements(sqlText)) != 1:
+		result.Reason = "several statements"
+		return result
+	case !IsPlannable(sqlText):
+		result.Reason = "cannot be explained"
+		return result
+	}
+
+	if err := explainReadOnly(ctx, db, sqlText); err != nil {
+		result.Outcome, result.Reason = ReplayFailed, err.Error()
+		return result
+	}
+	if opts.ExplainOnly {
+		result.Outcome = ReplaySame
+		return result
+	}
+
+	count, err := CountRows(ctx, db, sqlText, opts.CountLimit)
+	if err != nil {
+		result.Outcome, result.Reason = ReplayFailed, err.Error()
+		return result
+	}
+	result.Rows, result.Estimated = count.Rows, count.Estimated
+	result.Outcome = ReplaySame
+	// Counts beyond the limit are estimates either side and not compared
+	if count.Rows != entry.RowsAffected && !(count.Estimated && entry.RowsAffected > opts.CountLimit) {
+		result.Outcome = ReplayChanged
+		result.Reason = fmt.Sprintf("%d rows before", entry.RowsAffected)
+	}
+	return result
+}
+
+// explainReadOnly plans a query in a read-only transaction, failing as
+// running it would for missing tables, columns and functions
+func explainReadOnly(ctx context.Context, db *sql.DB, query string) error {
+	tx, err := BeginTx(ctx, db, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	rows, err := tx.QueryContext(ctx, "EXPLAIN "+query)
+	if err != nil {
+		return err
+	}
+	return rows.Close()
+}
+
+// ReplaySummary counts the results of a replay by outcome
+func ReplaySummary(results []ReplayResult) map[ReplayOutcome]int {
+	counts := make(map[ReplayOutcome]int)
+	for _, r := range results {
+		counts[r.Outcome]++
+	}
+	return counts
+}
@@ -0,0 +1,45 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+
+	"github.com/kartoza/kartoza-pg-ai/internal/config"
+)
+
+func TestReplayHistorySkips(t *testing.T) {
+	entries := []config.QueryHistoryEntry{
+		{NaturalQuery: "close the main road", GeneratedSQL: "UPDATE roads SET closed = true WHERE name = 'Main'", Success: true},
+		{NaturalQuery: "roads of a suburb", GeneratedSQL: "SELECT * FROM roads", EditedSQL: "SELECT * FROM roads WHERE suburb = {{suburb}}", Success: true},
+		{NaturalQuery: "roads of a suburb", GeneratedSQL: "SELECT * FROM roads WHERE suburb = {{suburb}}", Success: true},
+		{NaturalQuery: "temp roads", GeneratedSQL: "SET search_path = roads; SHOW search_path", Success: true},
+		{NaturalQuery: "broken", GeneratedSQL: "SELECT * FROM nowhere", Success: false},
+		{NaturalQuery: "nothing", Success: true},
+		{NaturalQuery: "search path", GeneratedSQL: "SHOW search_path", Success: true},
+	}
+	var progressed int
+	results := ReplayHistory(context.Background(), nil, entries, ReplayOptions{}, func(ReplayResult) { progressed++ })
+
+	if len(results) != 4 || progressed != 4 {
+		t.Fatalf("got %d results, %d reported; want 4 of each: %+v", len(results), progressed, results)
+	}
+	for _, r := range results {
+		if r.Outcome != ReplaySkipped || r.Reason == "" {
+			t.Errorf("%q should be skipped with a reason: %+v", r.SQL, r)
+		}
+	}
+	if results[1].SQL != entries[1].EditedSQL {
+		t.Errorf("the edited SQL should be replayed, got %q", results[1].SQL)
+	}
+	if counts := ReplaySummary(results); counts[ReplaySkipped] != 4 || counts[ReplayFailed] != 0 {
+		t.Errorf("unexpected summary %v", counts)
+	}
+}
+
+func TestReplayOutcomeString(t *testing.T) {
+	for outcome, want := range map[ReplayOutcome]string{ReplaySame: "ok", ReplayChanged: "changed", ReplayFailed: "failed", ReplaySkipped: "skipped"} {
+		if got := outcome.String(); got != want {
+			t.Errorf("%d.String() = %q, want %q", outcome, got, want)
+		}
+	}
+}
@@ -2,23 +2,61 @@ package postgres
 
 import (
 	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/kartoza/kartoza-pg-ai/internal/config"
+	"github.com/lib/pq"
 )
 
 // ProgressCallback is called with progress updates during schema harvesting
 type ProgressCallback func(current, total int, message string)
 
+// DefaultHarvestConcurrency is the number of tables whose columns are harvested in parallel
+const DefaultHarvestConcurrency = 4
+
 // SchemaHarvester harvests database schema information
 type SchemaHarvester struct {
-	db       *sql.DB
-	progress ProgressCallback
+	db          *sql.DB
+	progress    ProgressCallback
+	progressMu  sync.Mutex // Serializes progress callbacks from column workers
+	concurrency int
+	columnStats bool          // Whether to harvest pg_stats column statistics
+	filter      HarvestFilter // Schemas and tables to harvest
+	dbtManifest string        // dbt manifest.json documenting the schema (none when empty)
 }
 
 // NewSchemaHarvester creates a new schema harvester
 func NewSchemaHarvester(db *sql.DB) *SchemaHarvester {
-	return &SchemaHarvester{db: db}
+	return &SchemaHarvester{db: db, concurrency: DefaultHarvestConcurrency, columnStats: true}
+}
+
+// SetConcurrency sets how many column queries run in parallel (minimum 1)
+func (h *SchemaHarvester) SetConcurrency(n int) {
+	if n < 1 {
+		n = 1
+	}
+	h.concurrency = n
+}
+
+// SetColumnStats enables or disables harvesting of pg_stats column statistics
+func (h *SchemaHarvester) SetColumnStats(enabled bool) {
+	h.columnStats = enabled
+}
+
+// SetFilter restricts the harvest, and the object counts, to the schemas
+// and tables the filter matches
+func (h *SchemaHarvester) SetFilter(f HarvestFilter) {
+	h.filter = f
+}
+
+// SetDbtManifest documents the harvested tables and views from a dbt
+// project's manifest.json; see DbtDocs.Apply
+func (h *SchemaHarvester) SetDbtManifest(path string) {
+	h.dbtManifest = path
 }
 
 // SetProgressCallback sets a callback function for progress updates
@@ -33,6 +71,43 @@ func (h *SchemaHarvester) reportProgress(current, total int, message string) {
 	}
 }
 
+// relationRef identifies a table or view whose columns need harvesting
+type relationRef struct {
+	schema string
+	name   string
+}
+
+// harvestColumnsParallel harvests columns for every relation using a pool of
+// workers. Results and errors are returned in the same order as refs. done is
+// called once per finished relation, never concurrently.
+func (h *SchemaHarvester) harvestColumnsParallel(refs []relationRef, done func(i int)) ([][]config.ColumnInfo, []error) {
+	columns := make([][]config.ColumnInfo, len(refs))
+	errs := make([]error, len(refs))
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < h.concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				columns[i], errs[i] = h.harvestColumns(refs[i].schema, refs[i].name)
+				h.progressMu.Lock()
+				done(i)
+				h.progressMu.Unlock()
+			}
+		}()
+	}
+
+	for i := range refs {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return columns, errs
+}
+
 // SchemaCounts holds the counts of schema objects
 type SchemaCounts struct {
 	Tables    int
@@ -45,13 +120,14 @@ type SchemaCounts struct {
 func (h *SchemaHarvester) CountSchemaObjects() (*SchemaCounts, error) {
 	counts := &SchemaCounts{}
 
-	// Count tables
+	// Count tables, foreign tables included
+	cond, args := h.filter.condition("table_schema", "table_name")
 	err := h.db.QueryRow(`
 		SELECT COUNT(*)
 		FROM information_schema.tables
-		WHERE table_type = 'BASE TABLE'
+		WHERE table_type IN ('BASE TABLE', 'FOREIGN')
 		  AND table_schema NOT IN ('pg_catalog', 'information_schema')
-	`).Scan(&counts.Tables)
+	`+cond, args...).Scan(&counts.Tables)
 	if err != nil {
 		return nil, err
 	}
@@ -61,12 +137,20 @@ func (h *SchemaHarvester) CountSchemaObjects() (*SchemaCounts, error) {
 		SELECT COUNT(*)
 		FROM information_schema.views
 		WHERE table_schema NOT IN ('pg_catalog', 'information_schema')
-	`).Scan(&counts.Views)
+	`+cond, args...).Scan(&counts.Views)
 	if err != nil {
 		return nil, err
 	}
 
+	// Count materialized views, which information_schema leaves out
+	var matviews int
+	cond, args = h.filter.condition("schemaname", "matviewname")
+	if err := h.db.QueryRow(`SELECT COUNT(*) FROM pg_matviews WHERE true`+cond, args...).Scan(&matviews); err == nil {
+		counts.Views += matviews
+	}
+
 	// Count functions (limited)
+	cond, args = h.filter.condition("n.nspname", "p.proname")
 	err = h.db.QueryRow(`
 		SELECT COUNT(*)
 		FROM (
@@ -74,9 +158,10 @@ func (h *SchemaHarvester) CountSchemaObjects() (*SchemaCounts, error) {
 			JOIN pg_namespace n ON p.pronamespace = n.oid
 			WHERE n.nspname NOT IN ('pg_catalog', 'information_schema')
 			  AND p.prokind = 'f'
+			`+cond+`
 			LIMIT 500
 		) AS funcs
-	`).Scan(&counts.Functions)
+	`, args...).Scan(&counts.Functions)
 	if err != nil {
 		return nil, err
 	}
@@ -109,6 +194,11 @@ func (h *SchemaHarvester) Harvest(serviceName string) (*config.SchemaCache, erro
 	if err == nil {
 		cache.HasPostGIS = hasPostGIS
 	}
+	if raster, pointcloud, pgrouting, err := h.checkSpatialExtensions(); err == nil {
+		cache.HasRaster = raster
+		cache.HasPointCloud = pointcloud
+		cache.HasPgRouting = pgrouting
+	}
 
 	// Get PostgreSQL version
 	version, err := h.getVersion()
@@ -125,6 +215,55 @@ func (h *SchemaHarvester) Harvest(serviceName string) (*config.SchemaCache, erro
 	}
 	cache.Tables = tables
 
+	// Indexes, constraints and sequences are extra context for query
+	// generation, so failures here don't abort the harvest
+	h.reportProgress(current, counts.Total, "Harvesting indexes and constraints...")
+	if indexes, err := h.harvestIndexes(); err == nil {
+		for i := range cache.Tables {
+			cache.Tables[i].Indexes = indexes[cache.Tables[i].Schema+"."+cache.Tables[i].Name]
+		}
+	}
+	if constraints, err := h.harvestConstraints(); err == nil {
+		for i := range cache.Tables {
+			cache.Tables[i].Constraints = constraints[cache.Tables[i].Schema+"."+cache.Tables[i].Name]
+		}
+	}
+	if sequences, err := h.harvestSequences(); err == nil {
+		cache.Sequences = sequences
+	}
+	if parents, keys, err := h.harvestPartitions(); err == nil {
+		for i := range cache.Tables {
+			name := cache.Tables[i].Schema + "." + cache.Tables[i].Name
+			cache.Tables[i].PartitionOf = parents[name]
+			cache.Tables[i].PartitionKey = keys[name]
+		}
+	}
+	if sizes, err := h.harvestTableSizes(); err == nil {
+		for i := range cache.Tables {
+			cache.Tables[i].Size = sizes[cache.Tables[i].Schema+"."+cache.Tables[i].Name]
+		}
+	}
+	if privileges, err := h.harvestPrivileges(); err == nil {
+		for i := range cache.Tables {
+			p := privileges[cache.Tables[i].Schema+"."+cache.Tables[i].Name]
+			if p == nil {
+				p = &config.TablePrivileges{}
+			}
+			cache.Tables[i].Privileges = p
+		}
+	}
+	if security, err := h.harvestRowSecurity(); err == nil {
+		for i := range cache.Tables {
+			cache.Tables[i].RowSecurity = security[cache.Tables[i].Schema+"."+cache.Tables[i].Name]
+		}
+	}
+	if h.columnStats {
+		h.reportProgress(current, counts.Total, "Harvesting column statistics...")
+		if stats, err := h.harvestColumnStats(); err == nil {
+			applyColumnStats(cache.Tables, stats)
+		}
+	}
+
 	h.reportProgress(current, counts.Total, "Harvesting views...")
 
 	// Harvest views with progress
@@ -133,6 +272,9 @@ func (h *SchemaHarvester) Harvest(serviceName string) (*config.SchemaCache, erro
 		return nil, err
 	}
 	cache.Views = views
+	if matviews, err := h.harvestMaterializedViews(counts, &current); err == nil {
+		cache.Views = append(cache.Views, matviews...)
+	}
 
 	h.reportProgress(current, counts.Total, "Harvesting functions...")
 
@@ -143,6 +285,17 @@ func (h *SchemaHarvester) Harvest(serviceName string) (*config.SchemaCache, erro
 	}
 	cache.Functions = functions
 
+	// Descriptions and accepted values documented outside the database
+	if h.dbtManifest != "" {
+		h.reportProgress(current, counts.Total, "Reading dbt manifest...")
+		docs, err := LoadDbtManifest(h.dbtManifest)
+		if err != nil {
+			return nil, fmt.Errorf("reading dbt manifest: %w", err)
+		}
+		docs.Apply(cache)
+	}
+	applyCommentValues(cache)
+
 	h.reportProgress(counts.Total, counts.Total, "Schema harvesting complete!")
 
 	return cache, nil
@@ -159,6 +312,19 @@ func (h *SchemaHarvester) checkPostGIS() (bool, error) {
 	return exists, err
 }
 
+// checkSpatialExtensions checks if PostGIS raster, pgPointCloud and
+// pgRouting are installed. PostGIS before 3.0 bundles raster support, so the raster type
+// is looked for rather than the postgis_raster extension.
+func (h *SchemaHarvester) checkSpatialExtensions() (raster, pointcloud, pgrouting bool, err error) {
+	err = h.db.QueryRow(`
+		SELECT
+			EXISTS (SELECT 1 FROM pg_type WHERE typname = 'raster'),
+			EXISTS (SELECT 1 FROM pg_extension WHERE extname = 'pointcloud'),
+			EXISTS (SELECT 1 FROM pg_extension WHERE extname = 'pgrouting')
+	`).Scan(&raster, &pointcloud, &pgrouting)
+	return raster, pointcloud, pgrouting, err
+}
+
 // getVersion gets the PostgreSQL version
 func (h *SchemaHarvester) getVersion() (string, error) {
 	var version string
@@ -166,7 +332,9 @@ func (h *SchemaHarvester) getVersion() (string, error) {
 	return version, err
 }
 
-// harvestTablesWithProgress harvests all user tables with progress reporting
+// harvestTablesWithProgress harvests all user tables with progress reporting.
+// Foreign tables, such as those of a postgres_fdw federation, are
+// harvested as tables so questions can join them with local ones.
 func (h *SchemaHarvester) harvestTablesWithProgress(counts *SchemaCounts, current *int) ([]config.TableInfo, error) {
 	query := `
 		SELECT
@@ -174,38 +342,46 @@ func (h *SchemaHarvester) harvestTablesWithProgress(counts *SchemaCounts, curren
 			table_name,
 			COALESCE(obj_description((quote_ident(table_schema) || '.' || quote_ident(table_name))::regclass), '') as comment
 		FROM information_schema.tables
-		WHERE table_type = 'BASE TABLE'
+		WHERE table_type IN ('BASE TABLE', 'FOREIGN')
 		  AND table_schema NOT IN ('pg_catalog', 'information_schema')
-		ORDER BY table_schema, table_name
 	`
+	cond, args := h.filter.condition("table_schema", "table_name")
+	query += cond + " ORDER BY table_schema, table_name"
 
-	rows, err := h.db.Query(query)
+	rows, err := h.db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
 	var tables []config.TableInfo
+	var refs []relationRef
 	for rows.Next() {
 		var t config.TableInfo
 		if err := rows.Scan(&t.Schema, &t.Name, &t.Comment); err != nil {
 			return nil, err
 		}
-
-		// Get columns for this table (metadata only, no data)
-		columns, err := h.harvestColumns(t.Schema, t.Name)
-		if err != nil {
-			return nil, err
-		}
-		t.Columns = columns
-
 		tables = append(tables, t)
+		refs = append(refs, relationRef{schema: t.Schema, name: t.Name})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
 
+	// Get columns for each table (metadata only, no data)
+	columns, errs := h.harvestColumnsParallel(refs, func(i int) {
 		*current++
-		h.reportProgress(*current, counts.Total, "Table: "+t.Schema+"."+t.Name)
+		h.reportProgress(*current, counts.Total, "Table: "+tables[i].Schema+"."+tables[i].Name)
+	})
+	for i := range tables {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		tables[i].Columns = columns[i]
 	}
 
-	return tables, rows.Err()
+	return tables, nil
 }
 
 // harvestColumns harvests columns for a specific table
@@ -219,7 +395,8 @@ func (h *SchemaHarvester) harvestColumns(schema, table string) ([]config.ColumnI
 			COALESCE(tc.constraint_type = 'FOREIGN KEY', false) as is_fk,
 			COALESCE(ccu.table_name, '') as fk_table,
 			COALESCE(ccu.column_name, '') as fk_column,
-			COALESCE(col_description((quote_ident(c.table_schema) || '.' || quote_ident(c.table_name))::regclass, c.ordinal_position), '') as comment
+			COALESCE(col_description((quote_ident(c.table_schema) || '.' || quote_ident(c.table_name))::regclass, c.ordinal_position), '') as comment,
+			c.udt_name
 		FROM information_schema.columns c
 		LEFT JOIN information_schema.key_column_usage kcu
 			ON c.table_schema = kcu.table_schema
@@ -246,10 +423,11 @@ func (h *SchemaHarvester) harvestColumns(schema, table string) ([]config.ColumnI
 
 	for rows.Next() {
 		var col config.ColumnInfo
+		var udtName string
 		if err := rows.Scan(
 			&col.Name, &col.DataType, &col.IsNullable,
 			&col.IsPrimaryKey, &col.IsForeignKey,
-			&col.FKTable, &col.FKColumn, &col.Comment,
+			&col.FKTable, &col.FKColumn, &col.Comment, &udtName,
 		); err != nil {
 			return nil, err
 		}
@@ -260,14 +438,23 @@ func (h *SchemaHarvester) harvestColumns(schema, table string) ([]config.ColumnI
 		}
 		seen[col.Name] = true
 
-		// Check for geometry columns
-		if col.DataType == "USER-DEFINED" || col.DataType == "geometry" || col.DataType == "geography" {
+		// Check for pgvector and geometry columns
+		if isVectorType(udtName) {
+			col.DataType = udtName
+			col.IsVector = true
+			col.VectorDims = h.getVectorDims(schema, table, col.Name)
+		} else if udtName == "geometry" || udtName == "geography" {
+			// Kept as the type name, so geography columns are told apart
+			col.DataType = udtName
 			col.IsGeometry = true
 			geomInfo, _ := h.getGeometryInfo(schema, table, col.Name)
 			if geomInfo != nil {
 				col.GeomType = geomInfo.GeomType
 				col.SRID = geomInfo.SRID
 			}
+		} else if udtName == "raster" || udtName == "pcpatch" || udtName == "pcpoint" {
+			col.DataType = udtName
+			h.applyCoverageInfo(&col, schema, table)
 		}
 
 		columns = append(columns, col)
@@ -282,12 +469,17 @@ type GeometryInfo struct {
 	SRID     int
 }
 
-// getGeometryInfo gets geometry column information from PostGIS
+// getGeometryInfo gets geometry or geography column information from PostGIS
 func (h *SchemaHarvester) getGeometryInfo(schema, table, column string) (*GeometryInfo, error) {
 	query := `
 		SELECT type, srid
 		FROM geometry_columns
 		WHERE f_table_schema = $1 AND f_table_name = $2 AND f_geometry_column = $3
+		UNION ALL
+		SELECT type, srid
+		FROM geography_columns
+		WHERE f_table_schema = $1 AND f_table_name = $2 AND f_geography_column = $3
+		LIMIT 1
 	`
 
 	var info GeometryInfo
@@ -298,6 +490,402 @@ func (h *SchemaHarvester) getGeometryInfo(schema, table, column string) (*Geomet
 	return &info, nil
 }
 
+// applyCoverageInfo marks a raster or point cloud column and reads its
+// SRID, and a raster's bands, from the raster_columns or pointcloud_columns
+// views. They only know columns with constraints, so both may stay 0.
+func (h *SchemaHarvester) applyCoverageInfo(col *config.ColumnInfo, schema, table string) {
+	if col.DataType == "raster" {
+		col.IsRaster = true
+		_ = h.db.QueryRow(`
+			SELECT COALESCE(srid, 0), COALESCE(num_bands, 0)
+			FROM raster_columns
+			WHERE r_table_schema = $1 AND r_table_name = $2 AND r_raster_column = $3
+		`, schema, table, col.Name).Scan(&col.SRID, &col.RasterBands)
+		return
+	}
+	col.IsPointCloud = true
+	_ = h.db.QueryRow(`
+		SELECT COALESCE(srid, 0)
+		FROM pointcloud_columns
+		WHERE "schema" = $1 AND "table" = $2 AND "column" = $3
+	`, schema, table, col.Name).Scan(&col.SRID)
+}
+
+// isVectorType reports whether a type name is one of pgvector's
+func isVectorType(name string) bool {
+	return name == "vector" || name == "halfvec" || name == "sparsevec"
+}
+
+// getVectorDims returns the declared dimensions of a pgvector column, or 0
+// when the column accepts any
+func (h *SchemaHarvester) getVectorDims(schema, table, column string) int {
+	var dims int
+	err := h.db.QueryRow(`
+		SELECT atttypmod
+		FROM pg_attribute
+		WHERE attrelid = (quote_ident($1) || '.' || quote_ident($2))::regclass AND attname = $3
+	`, schema, table, column).Scan(&dims)
+	if err != nil || dims < 0 {
+		return 0
+	}
+	return dims
+}
+
+// harvestIndexes harvests all user indexes, keyed by "schema.table"
+func (h *SchemaHarvester) harvestIndexes() (map[string][]config.IndexInfo, error) {
+	query := `
+		SELECT
+			n.nspname,
+			t.relname,
+			i.relname,
+			am.amname,
+			ix.indisunique,
+			ix.indisprimary,
+			pg_get_indexdef(ix.indexrelid),
+			COALESCE(array_to_string(ARRAY(
+				SELECT a.attname
+				FROM unnest(ix.indkey) WITH ORDINALITY AS k(attnum, ord)
+				JOIN pg_attribute a ON a.attrelid = ix.indrelid AND a.attnum = k.attnum
+				ORDER BY k.ord
+			), ','), '') as columns
+		FROM pg_index ix
+		JOIN pg_class i ON i.oid = ix.indexrelid
+		JOIN pg_class t ON t.oid = ix.indrelid
+		JOIN pg_namespace n ON n.oid = t.relnamespace
+		JOIN pg_am am ON am.oid = i.relam
+		WHERE n.nspname NOT IN ('pg_catalog', 'information_schema')
+		  AND n.nspname NOT LIKE 'pg_toast%'
+	`
+	cond, args := h.filter.condition("n.nspname", "t.relname")
+	query += cond + " ORDER BY n.nspname, t.relname, i.relname"
+
+	rows, err := h.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	indexes := make(map[string][]config.IndexInfo)
+	for rows.Next() {
+		var schema, table, columns string
+		var idx config.IndexInfo
+		if err := rows.Scan(&schema, &table, &idx.Name, &idx.Method, &idx.IsUnique, &idx.IsPrimary, &idx.Definition, &columns); err != nil {
+			return nil, err
+		}
+		idx.Columns = splitNameList(columns)
+		indexes[schema+"."+table] = append(indexes[schema+"."+table], idx)
+	}
+
+	return indexes, rows.Err()
+}
+
+// harvestConstraints harvests CHECK and UNIQUE constraints, keyed by "schema.table".
+// Primary and foreign keys are already recorded on the columns.
+func (h *SchemaHarvester) harvestConstraints() (map[string][]config.ConstraintInfo, error) {
+	query := `
+		SELECT
+			n.nspname,
+			t.relname,
+			c.conname,
+			CASE c.contype WHEN 'c' THEN 'CHECK' ELSE 'UNIQUE' END,
+			pg_get_constraintdef(c.oid),
+			COALESCE(array_to_string(ARRAY(
+				SELECT a.attname
+				FROM unnest(c.conkey) WITH ORDINALITY AS k(attnum, ord)
+				JOIN pg_attribute a ON a.attrelid = c.conrelid AND a.attnum = k.attnum
+				ORDER BY k.ord
+			), ','), '') as columns
+		FROM pg_constraint c
+		JOIN pg_class t ON t.oid = c.conrelid
+		JOIN pg_namespace n ON n.oid = t.relnamespace
+		WHERE c.contype IN ('c', 'u')
+		  AND n.nspname NOT IN ('pg_catalog', 'information_schema')
+	`
+	cond, args := h.filter.condition("n.nspname", "t.relname")
+	query += cond + " ORDER BY n.nspname, t.relname, c.conname"
+
+	rows, err := h.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	constraints := make(map[string][]config.ConstraintInfo)
+	for rows.Next() {
+		var schema, table, columns string
+		var con config.ConstraintInfo
+		if err := rows.Scan(&schema, &table, &con.Name, &con.Type, &con.Definition, &columns); err != nil {
+			return nil, err
+		}
+		con.Columns = splitNameList(columns)
+		constraints[schema+"."+table] = append(constraints[schema+"."+table], con)
+	}
+
+	return constraints, rows.Err()
+}
+
+// harvestSequences harvests all user sequences and the columns they feed
+func (h *SchemaHarvester) harvestSequences() ([]config.SequenceInfo, error) {
+	query := `
+		SELECT
+			n.nspname,
+			c.relname,
+			format_type(s.seqtypid, NULL),
+			COALESCE((
+				SELECT tn.nspname || '.' || t.relname || '.' || a.attname
+				FROM pg_depend d
+				JOIN pg_class t ON t.oid = d.refobjid
+				JOIN pg_namespace tn ON tn.oid = t.relnamespace
+				JOIN pg_attribute a ON a.attrelid = d.refobjid AND a.attnum = d.refobjsubid
+				WHERE d.objid = c.oid
+				  AND d.classid = 'pg_class'::regclass
+				  AND d.deptype IN ('a', 'i')
+				LIMIT 1
+			), '') as owned_by
+		FROM pg_class c
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		JOIN pg_sequence s ON s.seqrelid = c.oid
+		WHERE c.relkind = 'S'
+		  AND n.nspname NOT IN ('pg_catalog', 'information_schema')
+	`
+	cond, args := h.filter.condition("n.nspname", "c.relname")
+	query += cond + " ORDER BY n.nspname, c.relname"
+
+	rows, err := h.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sequences []config.SequenceInfo
+	for rows.Next() {
+		var seq config.SequenceInfo
+		if err := rows.Scan(&seq.Schema, &seq.Name, &seq.DataType, &seq.OwnedBy); err != nil {
+			return nil, err
+		}
+		sequences = append(sequences, seq)
+	}
+
+	return sequences, rows.Err()
+}
+
+// splitNameList splits a comma-separated list of names, returning nil when empty
+func splitNameList(list string) []string {
+	if list == "" {
+		return nil
+	}
+	return strings.Split(list, ",")
+}
+
+// harvestPartitions harvests the parent of every partition and inheritance
+// child, and the partition key of every partitioned table, keyed by
+// "schema.table"
+func (h *SchemaHarvester) harvestPartitions() (parents, keys map[string]string, err error) {
+	query := `
+		SELECT cn.nspname, c.relname, pn.nspname || '.' || p.relname
+		FROM pg_inherits i
+		JOIN pg_class c ON c.oid = i.inhrelid
+		JOIN pg_namespace cn ON cn.oid = c.relnamespace
+		JOIN pg_class p ON p.oid = i.inhparent
+		JOIN pg_namespace pn ON pn.oid = p.relnamespace
+		WHERE c.relkind IN ('r', 'p', 'f')
+		  AND cn.nspname NOT IN ('pg_catalog', 'information_schema')
+	`
+	cond, args := h.filter.condition("cn.nspname", "c.relname")
+
+	rows, err := h.db.Query(query+cond, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	parents = make(map[string]string)
+	for rows.Next() {
+		var schema, table, parent string
+		if err := rows.Scan(&schema, &table, &parent); err != nil {
+			return nil, nil, err
+		}
+		parents[schema+"."+table] = parent
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+	rows.Close()
+
+	// Declarative partitioning (PostgreSQL 10+); older servers only have
+	// inheritance, so a failure here keeps the parents found above
+	keys = make(map[string]string)
+	query = `
+		SELECT n.nspname, c.relname, pg_get_partkeydef(c.oid)
+		FROM pg_partitioned_table pt
+		JOIN pg_class c ON c.oid = pt.partrelid
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE n.nspname NOT IN ('pg_catalog', 'information_schema')
+	`
+	cond, args = h.filter.condition("n.nspname", "c.relname")
+	keyRows, err := h.db.Query(query+cond, args...)
+	if err != nil {
+		return parents, keys, nil
+	}
+	defer keyRows.Close()
+	for keyRows.Next() {
+		var schema, table, key string
+		if err := keyRows.Scan(&schema, &table, &key); err != nil {
+			return nil, nil, err
+		}
+		keys[schema+"."+table] = key
+	}
+
+	return parents, keys, keyRows.Err()
+}
+
+// harvestTableSizes harvests the row estimate and total size of every
+// table, keyed by "schema.table". Partitioned tables are empty themselves,
+// so theirs add up their leaf partitions.
+func (h *SchemaHarvester) harvestTableSizes() (map[string]*config.TableSize, error) {
+	query := `
+		SELECT
+			n.nspname,
+			c.relname,
+			CASE WHEN c.relkind = 'p' THEN sizes.row_total ELSE c.reltuples END::bigint as row_estimate,
+			sizes.byte_total as total_bytes
+		FROM pg_class c
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		CROSS JOIN LATERAL (
+			SELECT COALESCE(SUM(GREATEST(l.reltuples, 0)), 0) as row_total,
+				COALESCE(SUM(pg_total_relation_size(l.oid)), 0)::bigint as byte_total
+			FROM pg_partition_tree(c.oid) pt
+			JOIN pg_class l ON l.oid = pt.relid
+			WHERE pt.isleaf
+		) sizes
+		WHERE c.relkind IN ('r', 'p')
+		  AND n.nspname NOT IN ('pg_catalog', 'information_schema')
+	`
+	cond, args := h.filter.condition("n.nspname", "c.relname")
+
+	rows, err := h.db.Query(query+cond, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sizes := make(map[string]*config.TableSize)
+	for rows.Next() {
+		var schema, table string
+		var size config.TableSize
+		if err := rows.Scan(&schema, &table, &size.RowEstimate, &size.TotalBytes); err != nil {
+			return nil, err
+		}
+		sizes[schema+"."+table] = &size
+	}
+
+	return sizes, rows.Err()
+}
+
+// harvestPrivileges harvests what the connecting role may do with every
+// table, keyed by "schema.table". PostgreSQL's privilege functions count
+// ownership, grants to the role, to PUBLIC and to the roles it belongs to,
+// predefined roles such as pg_read_all_data and superuser. A table counts
+// as readable, insertable or updatable when any of its columns is.
+func (h *SchemaHarvester) harvestPrivileges() (map[string]*config.TablePrivileges, error) {
+	query := `
+		SELECT
+			n.nspname,
+			c.relname,
+			has_any_column_privilege(c.oid, 'SELECT') as can_select,
+			has_any_column_privilege(c.oid, 'INSERT') as can_insert,
+			has_any_column_privilege(c.oid, 'UPDATE') as can_update,
+			has_table_privilege(c.oid, 'DELETE') as can_delete
+		FROM pg_class c
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE c.relkind IN ('r', 'p', 'f')
+		  AND n.nspname NOT IN ('pg_catalog', 'information_schema')
+	`
+	cond, args := h.filter.condition("n.nspname", "c.relname")
+
+	rows, err := h.db.Query(query+cond, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	privileges := make(map[string]*config.TablePrivileges)
+	for rows.Next() {
+		var schema, table string
+		var p config.TablePrivileges
+		if err := rows.Scan(&schema, &table, &p.Select, &p.Insert, &p.Update, &p.Delete); err != nil {
+			return nil, err
+		}
+		privileges[schema+"."+table] = &p
+	}
+
+	return privileges, rows.Err()
+}
+
+// harvestRowSecurity harvests the tables with row-level security enabled
+// and their policies, keyed by "schema.table"
+func (h *SchemaHarvester) harvestRowSecurity() (map[string]*config.RowSecurity, error) {
+	tableQuery := `
+		SELECT n.nspname, c.relname, row_security_active(c.oid)
+		FROM pg_class c
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE c.relkind IN ('r', 'p')
+		  AND c.relrowsecurity
+		  AND n.nspname NOT IN ('pg_catalog', 'information_schema')
+	`
+	cond, args := h.filter.condition("n.nspname", "c.relname")
+
+	rows, err := h.db.Query(tableQuery+cond, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	security := make(map[string]*config.RowSecurity)
+	for rows.Next() {
+		var schema, table string
+		var rs config.RowSecurity
+		if err := rows.Scan(&schema, &table, &rs.Active); err != nil {
+			return nil, err
+		}
+		security[schema+"."+table] = &rs
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(security) == 0 {
+		return security, nil
+	}
+
+	policyQuery := `
+		SELECT schemaname, tablename, policyname, permissive = 'RESTRICTIVE', cmd,
+			roles::text[], COALESCE(qual, ''), COALESCE(with_check, '')
+		FROM pg_policies
+		WHERE schemaname NOT IN ('pg_catalog', 'information_schema')
+	`
+	cond, args = h.filter.condition("schemaname", "tablename")
+
+	policyRows, err := h.db.Query(policyQuery+cond+" ORDER BY schemaname, tablename, policyname", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer policyRows.Close()
+
+	for policyRows.Next() {
+		var schema, table string
+		var p config.PolicyInfo
+		if err := policyRows.Scan(&schema, &table, &p.Name, &p.Restrictive, &p.Command,
+			pq.Array(&p.Roles), &p.Using, &p.WithCheck); err != nil {
+			return nil, err
+		}
+		if rs := security[schema+"."+table]; rs != nil {
+			rs.Policies = append(rs.Policies, p)
+		}
+	}
+
+	return security, policyRows.Err()
+}
+
 // harvestViewsWithProgress harvests all user views with progress reporting
 func (h *SchemaHarvester) harvestViewsWithProgress(counts *SchemaCounts, current *int) ([]config.ViewInfo, error) {
 	query := `
@@ -307,37 +895,151 @@ func (h *SchemaHarvester) harvestViewsWithProgress(counts *SchemaCounts, current
 			COALESCE(obj_description((quote_ident(table_schema) || '.' || quote_ident(table_name))::regclass), '') as comment
 		FROM information_schema.views
 		WHERE table_schema NOT IN ('pg_catalog', 'information_schema')
-		ORDER BY table_schema, table_name
 	`
+	cond, args := h.filter.condition("table_schema", "table_name")
+	query += cond + " ORDER BY table_schema, table_name"
 
-	rows, err := h.db.Query(query)
+	rows, err := h.db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
 	var views []config.ViewInfo
+	var refs []relationRef
 	for rows.Next() {
 		var v config.ViewInfo
 		if err := rows.Scan(&v.Schema, &v.Name, &v.Comment); err != nil {
 			return nil, err
 		}
+		views = append(views, v)
+		refs = append(refs, relationRef{schema: v.Schema, name: v.Name})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
 
-		// Get columns for this view (metadata only, no data)
-		columns, err := h.harvestColumns(v.Schema, v.Name)
-		if err != nil {
+	// Get columns for each view (metadata only, no data)
+	columns, errs := h.harvestColumnsParallel(refs, func(i int) {
+		*current++
+		h.reportProgress(*current, counts.Total, "View: "+views[i].Schema+"."+views[i].Name)
+	})
+	for i := range views {
+		if errs[i] != nil {
 			// Views might have issues, skip columns
-			columns = []config.ColumnInfo{}
+			columns[i] = []config.ColumnInfo{}
 		}
-		v.Columns = columns
+		views[i].Columns = columns[i]
+	}
 
+	return views, nil
+}
+
+// harvestMaterializedViews harvests materialized views with progress
+// reporting. information_schema doesn't list them or their columns, so both
+// come from the catalogs.
+func (h *SchemaHarvester) harvestMaterializedViews(counts *SchemaCounts, current *int) ([]config.ViewInfo, error) {
+	query := `
+		SELECT
+			n.nspname,
+			c.relname,
+			COALESCE(obj_description(c.oid), '') as comment,
+			pg_get_viewdef(c.oid) as definition
+		FROM pg_class c
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE c.relkind = 'm'
+		  AND n.nspname NOT IN ('pg_catalog', 'information_schema')
+	`
+	cond, args := h.filter.condition("n.nspname", "c.relname")
+	query += cond + " ORDER BY n.nspname, c.relname"
+
+	rows, err := h.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var views []config.ViewInfo
+	for rows.Next() {
+		v := config.ViewInfo{IsMaterialized: true}
+		if err := rows.Scan(&v.Schema, &v.Name, &v.Comment, &v.Definition); err != nil {
+			return nil, err
+		}
 		views = append(views, v)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	for i := range views {
+		columns, err := h.harvestMaterializedViewColumns(views[i].Schema, views[i].Name)
+		if err != nil {
+			columns = []config.ColumnInfo{}
+		}
+		views[i].Columns = columns
 
 		*current++
-		h.reportProgress(*current, counts.Total, "View: "+v.Schema+"."+v.Name)
+		h.reportProgress(*current, counts.Total, "Materialized view: "+views[i].Schema+"."+views[i].Name)
+	}
+
+	return views, nil
+}
+
+// harvestMaterializedViewColumns harvests the columns of a materialized view
+func (h *SchemaHarvester) harvestMaterializedViewColumns(schema, view string) ([]config.ColumnInfo, error) {
+	query := `
+		SELECT
+			a.attname,
+			format_type(a.atttypid, a.atttypmod),
+			NOT a.attnotnull,
+			COALESCE(col_description(c.oid, a.attnum), ''),
+			t.typname IN ('geometry', 'geography'),
+			t.typname,
+			a.atttypmod
+		FROM pg_attribute a
+		JOIN pg_class c ON c.oid = a.attrelid
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		JOIN pg_type t ON t.oid = a.atttypid
+		WHERE n.nspname = $1 AND c.relname = $2
+		  AND a.attnum > 0 AND NOT a.attisdropped
+		ORDER BY a.attnum
+	`
+
+	rows, err := h.db.Query(query, schema, view)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var columns []config.ColumnInfo
+	for rows.Next() {
+		var col config.ColumnInfo
+		var typeName string
+		var typmod int
+		if err := rows.Scan(&col.Name, &col.DataType, &col.IsNullable, &col.Comment, &col.IsGeometry, &typeName, &typmod); err != nil {
+			return nil, err
+		}
+		if isVectorType(typeName) {
+			col.DataType = typeName
+			col.IsVector = true
+			col.VectorDims = max(typmod, 0)
+		}
+		if col.IsGeometry {
+			if geomInfo, _ := h.getGeometryInfo(schema, view, col.Name); geomInfo != nil {
+				col.GeomType = geomInfo.GeomType
+				col.SRID = geomInfo.SRID
+			}
+		}
+		if typeName == "raster" || typeName == "pcpatch" || typeName == "pcpoint" {
+			col.DataType = typeName
+			h.applyCoverageInfo(&col, schema, view)
+		}
+		columns = append(columns, col)
 	}
 
-	return views, rows.Err()
+	return columns, rows.Err()
 }
 
 // harvestFunctionsWithProgress harvests commonly used functions with progress reporting
@@ -353,11 +1055,11 @@ func (h *SchemaHarvester) harvestFunctionsWithProgress(counts *SchemaCounts, cur
 		JOIN pg_namespace n ON p.pronamespace = n.oid
 		WHERE n.nspname NOT IN ('pg_catalog', 'information_schema')
 		  AND p.prokind = 'f'
-		ORDER BY n.nspname, p.proname
-		LIMIT 500
 	`
+	cond, args := h.filter.condition("n.nspname", "p.proname")
+	query += cond + " ORDER BY n.nspname, p.proname LIMIT 500"
 
-	rows, err := h.db.Query(query)
+	rows, err := h.db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -388,13 +1090,31 @@ func GenerateSchemaDescription(cache *config.SchemaCache) string {
 	if cache.HasPostGIS {
 		desc += "PostGIS is installed - spatial queries are supported.\n\n"
 	}
+	if cache.HasRaster {
+		desc += "PostGIS raster is installed - use ST_SummaryStats, ST_Value and ST_Clip on raster columns.\n\n"
+	}
+	if cache.HasPointCloud {
+		desc += "pgPointCloud is installed - use PC_NumPoints, PC_Explode and PC_Get on point cloud columns.\n\n"
+	}
+	if cache.HasPgRouting {
+		desc += "pgRouting is installed - find routes with pgr_dijkstra over tables with source, target and cost columns.\n\n"
+	}
 
 	desc += "TABLES:\n"
-	for _, t := range cache.Tables {
+	for _, t := range cache.WithoutPartitions().Tables {
 		desc += "- " + t.Schema + "." + t.Name
 		if t.Comment != "" {
 			desc += " (" + t.Comment + ")"
 		}
+		if t.PartitionKey != "" {
+			desc += " [PARTITIONED BY " + t.PartitionKey + "]"
+		}
+		if summary := t.Size.Summary(); summary != "" {
+			desc += " [" + summary + "]"
+		}
+		if t.RowSecurityActive() {
+			desc += " [ROW LEVEL SECURITY: only rows the policies allow are visible]"
+		}
 		desc += "\n"
 		for _, c := range t.Columns {
 			desc += "    - " + c.Name + " (" + c.DataType + ")"
@@ -407,9 +1127,43 @@ func GenerateSchemaDescription(cache *config.SchemaCache) string {
 			if c.IsGeometry {
 				desc += " [GEOMETRY: " + c.GeomType + ", SRID: " + string(rune(c.SRID)) + "]"
 			}
+			if c.IsRaster {
+				desc += " [RASTER: " + strconv.Itoa(c.RasterBands) + " bands, SRID: " + strconv.Itoa(c.SRID) + "]"
+			}
+			if c.IsPointCloud {
+				desc += " [POINT CLOUD: SRID: " + strconv.Itoa(c.SRID) + "]"
+			}
 			if c.Comment != "" {
 				desc += " - " + c.Comment
 			}
+			if summary := c.Stats.Summary(); summary != "" {
+				desc += " [" + summary + "]"
+			}
+			desc += "\n"
+		}
+		for _, idx := range t.Indexes {
+			if idx.IsPrimary {
+				continue
+			}
+			if len(idx.Columns) == 0 {
+				desc += "    INDEX " + idx.Definition + "\n"
+			} else {
+				desc += "    INDEX " + idx.Name + " (" + idx.Method + " on " + strings.Join(idx.Columns, ", ") + ")\n"
+			}
+		}
+		for _, con := range t.Constraints {
+			desc += "    " + con.Type + " " + con.Definition + "\n"
+		}
+		desc += "\n"
+	}
+
+	if len(cache.Sequences) > 0 {
+		desc += "SEQUENCES:\n"
+		for _, seq := range cache.Sequences {
+			desc += "- " + seq.Schema + "." + seq.Name
+			if seq.OwnedBy != "" {
+				desc += " (owned by " + seq.OwnedBy + ")"
+			}
 			desc += "\n"
 		}
 		desc += "\n"
@@ -419,6 +1173,9 @@ func GenerateSchemaDescription(cache *config.SchemaCache) string {
 		desc += "VIEWS:\n"
 		for _, v := range cache.Views {
 			desc += "- " + v.Schema + "." + v.Name
+			if v.IsMaterialized {
+				desc += " [MATERIALIZED]"
+			}
 			if v.Comment != "" {
 				desc += " (" + v.Comment + ")"
 			}
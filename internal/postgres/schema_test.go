@@ -0,0 +1,131 @@
+package postgres
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/kartoza/kartoza-pg-ai/internal/config"
+)
+
+// grantsDriver is a database/sql driver answering the privileges query as
+// PostgreSQL would for tables granted to the role as a whole or only on
+// some of their columns
+type grantsDriver struct {
+	tables map[string]grants // By "schema.table"
+}
+
+// grants are a table's privileges granted on the table and on its columns
+type grants struct {
+	table, column map[string]bool
+}
+
+func (d grantsDriver) Open(string) (driver.Conn, error) { return grantsConn(d), nil }
+
+type grantsConn grantsDriver
+
+func (c grantsConn) Prepare(query string) (driver.Stmt, error) {
+	return grantsStmt{conn: c, query: query}, nil
+}
+func (grantsConn) Close() error              { return nil }
+func (grantsConn) Begin() (driver.Tx, error) { return nil, fmt.Errorf("not supported") }
+
+type grantsStmt struct {
+	conn  grantsConn
+	query string
+}
+
+func (grantsStmt) Close() error  { return nil }
+func (grantsStmt) NumInput() int { return -1 }
+func (grantsStmt) Exec([]driver.Value) (driver.Result, error) {
+	return nil, fmt.Errorf("not supported")
+}
+
+// Query answers each has_table_privilege and has_any_column_privilege
+// call of the query's select list for every table
+func (s grantsStmt) Query([]driver.Value) (driver.Rows, error) {
+	var checks []func(grants) bool
+	for _, line := range strings.Split(s.query, "\n") {
+		line = strings.TrimSpace(line)
+		for _, privilege := range []string{"SELECT", "INSERT", "UPDATE", "DELETE"} {
+			switch {
+			case strings.HasPrefix(line, "has_table_privilege(c.oid, '"+privilege+"')"):
+				checks = append(checks, func(g grants) bool { return g.table[privilege] })
+			case strings.HasPrefix(line, "has_any_column_privilege(c.oid, '"+privilege+"')"):
+				checks = append(checks, func(g grants) bool { return g.table[privilege] || g.column[privilege] })
+			}
+		}
+	}
+	rows := &grantsRows{columns: 2 + len(checks)}
+	for name, g := range s.conn.tables {
+		schema, table, _ := strings.Cut(name, ".")
+		row := []driver.Value{schema, table}
+		for _, check := range checks {
+			row = append(row, check(g))
+		}
+		rows.rows = append(rows.rows, row)
+	}
+	return rows, nil
+}
+
+type grantsRows struct {
+	columns int
+	rows    [][]driver.Value
+}
+
+func (r *grantsRows) Columns() []string { return make([]string, r.columns) }
+func (r *grantsRows) Close() error      { return nil }
+func (r *grantsRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+func TestHarvestPrivilegesCountsColumnGrants(t *testing.T) {
+	sql.Register("grants", grantsDriver{tables: map[string]grants{
+		"public.parcels":  {table: map[string]bool{"SELECT": true, "UPDATE": true}},
+		"public.owners":   {column: map[string]bool{"SELECT": true}},
+		"hr.salaries":     {table: map[string]bool{"INSERT": true}},
+		"public.invoices": {column: map[string]bool{"UPDATE": true}},
+	}})
+	db, err := sql.Open("grants", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	privileges, err := NewSchemaHarvester(db).harvestPrivileges()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]config.TablePrivileges{
+		"public.parcels":  {Select: true, Update: true},
+		"public.owners":   {Select: true},
+		"hr.salaries":     {Insert: true},
+		"public.invoices": {Update: true},
+	}
+	for name, p := range want {
+		if got := privileges[name]; got == nil || *got != p {
+			t.Errorf("%s: got %+v, want %+v", name, got, p)
+		}
+	}
+
+	cache := &config.SchemaCache{}
+	for name, p := range privileges {
+		schema, table, _ := strings.Cut(name, ".")
+		cache.Tables = append(cache.Tables, config.TableInfo{Schema: schema, Name: table, Privileges: p})
+	}
+	readable := make(map[string]bool)
+	for _, table := range cache.Readable().Tables {
+		readable[table.Schema+"."+table.Name] = true
+	}
+	if !readable["public.owners"] || !readable["public.parcels"] || readable["hr.salaries"] || readable["public.invoices"] {
+		t.Errorf("a table readable through a column grant should stay visible, got %v", readable)
+	}
+}
@@ -0,0 +1,209 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// SplitStatements splits SQL into its statements at semicolons outside
+// strings, comments, quoted identifiers and dollar-quoted bodies. Statements
+// are trimmed and empty ones are left out.
+func SplitStatements(sql string) []string {
+	var statements []string
+	var current strings.Builder
+	flush := func() {
+		if stmt := strings.TrimSpace(current.String()); stmt != "" && hasCode(stmt) {
+			statements = append(statements, stmt)
+		}
+		current.Reset()
+	}
+	for _, tok := range LexSQL(sql) {
+		if tok.Kind == TokenPunctuation && tok.Text == ";" {
+			flush()
+			continue
+		}
+		current.WriteString(tok.Text)
+	}
+	flush()
+	return statements
+}
+
+// hasCode reports whether a statement holds more than comments
+func hasCode(stmt string) bool {
+	for _, tok := range LexSQL(stmt) {
+		if tok.Kind != TokenWhitespace && tok.Kind != TokenComment {
+			return true
+		}
+	}
+	return false
+}
+
+// returnsRows reports whether a statement is one that returns rows: a
+// query, or a data-modifying statement with RETURNING
+func returnsRows(stmt string) bool {
+	words := sqlKeywords(stmt)
+	if len(words) == 0 {
+		return false
+	}
+	if readKeywords[words[0]] {
+		return true
+	}
+	for _, w := range words {
+		if w == "returning" {
+			return true
+		}
+	}
+	return false
+}
+
+// ScriptStatement is one statement of a script and its parameters
+type ScriptStatement struct {
+	SQL  string
+	Args []any // Bound to the statement's $n parameters
+}
+
+// StatementResult is the outcome of one statement of a script
+type StatementResult struct {
+	SQL          string
+	Columns      []string // Nil for statements that return no rows
+	ColumnTypes  []string
+	Rows         [][]interface{}
+	Truncated    bool  // More rows were returned than were kept
+	RowsAffected int64 // For statements that return no rows; -1 when unknown
+	Duration     time.Duration
+}
+
+// RunScript runs statements in order on one connection and in one
+// transaction, so temporary tables and settings made by one statement are
+// seen by the next. The transaction commits only when commit is true and
+// every statement succeeds; otherwise it is rolled back, which also drops
+// the temporary tables of a read-only script. A script that is not
+// committed is a read: after the statements leading it that create or
+// change temporary tables, which PostgreSQL refuses in a read-only
+// transaction, the transaction turns read only, so a write the classifier
+// missed fails instead of running. The statement timeout of
+// ctx bounds each statement. At most maxRows rows are kept per statement.
+// On failure the results of the statements before the failing one are
+// returned with the error.
+func RunScript(ctx context.Context, db *sql.DB, statements []ScriptStatement, commit bool, maxRows int) ([]StatementResult, error) {
+	tx, err := BeginTx(ctx, db, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	readOnlyFrom := len(statements)
+	if !commit {
+		texts := make([]string, len(statements))
+		for i, stmt := range statements {
+			texts[i] = stmt.SQL
+		}
+		readOnlyFrom = tempTablePrelude(texts)
+	}
+
+	var results []StatementResult
+	for i, stmt := range statements {
+		if i == readOnlyFrom {
+			if _, err := tx.ExecContext(ctx, "SET TRANSACTION READ ONLY"); err != nil {
+				return results, err
+			}
+		}
+		result, err := runScriptStatement(ctx, tx, stmt, maxRows)
+		if err != nil {
+			return results, fmt.Errorf("statement %d of %d failed: %w", i+1, len(statements), err)
+		}
+		results = append(results, result)
+	}
+
+	if commit {
+		if err := tx.Commit(); err != nil {
+			return results, err
+		}
+	}
+	return results, nil
+}
+
+// runScriptStatement runs a single statement of a script. Statements that
+// return no rows report the rows they affected instead.
+func runScriptStatement(ctx context.Context, tx *sql.Tx, stmt ScriptStatement, maxRows int) (StatementResult, error) {
+	result := StatementResult{SQL: stmt.SQL, RowsAffected: -1}
+	start := time.Now()
+
+	if !returnsRows(stmt.SQL) {
+		res, err := tx.ExecContext(ctx, stmt.SQL, stmt.Args...)
+		if err != nil {
+			return result, err
+		}
+		if n, err := res.RowsAffected(); err == nil {
+			result.RowsAffected = n
+		}
+		result.Duration = time.Since(start)
+		return result, nil
+	}
+
+	rows, err := tx.QueryContext(ctx, stmt.SQL, stmt.Args...)
+	if err != nil {
+		return result, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return result, err
+	}
+	if len(columns) > 0 {
+		result.Columns = columns
+		result.ColumnTypes = ColumnTypeNames(rows)
+	}
+	for rows.Next() {
+		if len(result.Rows) >= maxRows {
+			result.Truncated = true
+			break
+		}
+		values := make([]interface{}, len(columns))
+		valuePtrs := make([]interface{}, len(columns))
+		for i := range values {
+			valuePtrs[i] = &values[i]
+		}
+		if err := rows.Scan(valuePtrs...); err != nil {
+			return result, err
+		}
+		result.Rows = append(result.Rows, values)
+	}
+	if err := rows.Err(); err != nil {
+		return result, err
+	}
+	result.Duration = time.Since(start)
+	return result, nil
+}
+
+// QueryAll runs SQL, a query or a script, with its {{name}} placeholders
+// bound from params and returns every row of the last statement returning
+// rows, formatted as text. The SQL runs as a read script: nothing it
+// changes is committed, and writes to tables other than its temporary ones
+// fail.
+func QueryAll(ctx context.Context, db *sql.DB, sqlText string, params map[string]string) ([]string, [][]string, error) {
+	var script []ScriptStatement
+	for _, stmt := range SplitStatements(sqlText) {
+		bound, args, err := BindTemplate(stmt, params)
+		if err != nil {
+			return nil, nil, err
+		}
+		script = append(script, ScriptStatement{SQL: bound, Args: args})
+	}
+
+	results, err := RunScript(ctx, db, script, false, math.MaxInt)
+	if err != nil {
+		return nil, nil, err
+	}
+	for i := len(results) - 1; i >= 0; i-- {
+		if results[i].Columns != nil {
+			return results[i].Columns, FormatRows(results[i].Rows), nil
+		}
+	}
+	return nil, nil, fmt.Errorf("the SQL returns no rows")
+}
@@ -0,0 +1,145 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitStatements(t *testing.T) {
+	tests := []struct {
+		sql      string
+		expected []string
+	}{
+		{"SELECT 1", []string{"SELECT 1"}},
+		{"SELECT 1;", []string{"SELECT 1"}},
+		{
+			"CREATE TEMP TABLE t AS SELECT 'a;b' AS x;\n-- count; them\nSELECT count(*) FROM t;",
+			[]string{"CREATE TEMP TABLE t AS SELECT 'a;b' AS x", "-- count; them\nSELECT count(*) FROM t"},
+		},
+		{`SELECT ";" FROM t; SELECT $f$ ; $f$`, []string{`SELECT ";" FROM t`, "SELECT $f$ ; $f$"}},
+		{"SELECT 1; ; -- trailing comment", []string{"SELECT 1"}},
+		{"  ", nil},
+	}
+
+	for _, tt := range tests {
+		if got := SplitStatements(tt.sql); !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("SplitStatements(%q): expected %q, got %q", tt.sql, tt.expected, got)
+		}
+	}
+}
+
+func TestReturnsRows(t *testing.T) {
+	for sql, expected := range map[string]bool{
+		"SELECT 1":                             true,
+		"WITH t AS (SELECT 1) SELECT * FROM t": true,
+		"INSERT INTO t VALUES (1)":             false,
+		"DELETE FROM t RETURNING id":           true,
+		"CREATE TEMP TABLE t (a int)":          false,
+	} {
+		if got := returnsRows(sql); got != expected {
+			t.Errorf("returnsRows(%q): expected %v, got %v", sql, expected, got)
+		}
+	}
+}
+
+func TestTempTablePrelude(t *testing.T) {
+	tests := []struct {
+		statements []string
+		expected   int
+	}{
+		{[]string{"SELECT 1"}, 0},
+		{[]string{"CREATE TEMP TABLE t AS SELECT 1", "SELECT * FROM t"}, 1},
+		{[]string{"CREATE TEMP TABLE t (a int)", "INSERT INTO t VALUES (1)", "CREATE TEMP TABLE u AS SELECT * FROM t", "SELECT * FROM u"}, 3},
+		{[]string{"CREATE TEMP TABLE t (a int)", "INSERT INTO users VALUES (1)"}, 1},
+		{[]string{"SELECT 1", "CREATE TEMP TABLE t (a int)"}, 0},
+		{[]string{"CREATE TEMP TABLE t (a int)", "DROP TABLE t"}, 2},
+	}
+
+	for _, tt := range tests {
+		if got := tempTablePrelude(tt.statements); got != tt.expected {
+			t.Errorf("tempTablePrelude(%q): expected %d, got %d", tt.statements, tt.expected, got)
+		}
+	}
+}
+
+// readOnlyDriver is a database/sql driver refusing writes once its
+// transaction is read only, as PostgreSQL does
+type readOnlyDriver struct{}
+
+func (readOnlyDriver) Open(string) (driver.Conn, error) { return &readOnlyConn{}, nil }
+
+type readOnlyConn struct {
+	readOnly bool
+}
+
+func (c *readOnlyConn) Prepare(query string) (driver.Stmt, error) {
+	return readOnlyStmt{conn: c, query: query}, nil
+}
+func (c *readOnlyConn) Close() error              { return nil }
+func (c *readOnlyConn) Begin() (driver.Tx, error) { return readOnlyTx{c}, nil }
+
+type readOnlyTx struct {
+	conn *readOnlyConn
+}
+
+func (tx readOnlyTx) Commit() error   { tx.conn.readOnly = false; return nil }
+func (tx readOnlyTx) Rollback() error { tx.conn.readOnly = false; return nil }
+
+type readOnlyStmt struct {
+	conn  *readOnlyConn
+	query string
+}
+
+func (readOnlyStmt) Close() error  { return nil }
+func (readOnlyStmt) NumInput() int { return -1 }
+func (s readOnlyStmt) Exec([]driver.Value) (driver.Result, error) {
+	if s.query == "SET TRANSACTION READ ONLY" {
+		s.conn.readOnly = true
+		return driver.RowsAffected(0), nil
+	}
+	command := strings.ToUpper(strings.Fields(s.query)[0])
+	if s.conn.readOnly && command != "SELECT" {
+		return nil, fmt.Errorf("cannot execute %s in a read-only transaction", command)
+	}
+	return driver.RowsAffected(1), nil
+}
+func (readOnlyStmt) Query([]driver.Value) (driver.Rows, error) { return readOnlyRows{}, nil }
+
+type readOnlyRows struct{}
+
+func (readOnlyRows) Columns() []string         { return []string{"?column?"} }
+func (readOnlyRows) Close() error              { return nil }
+func (readOnlyRows) Next([]driver.Value) error { return io.EOF }
+
+func TestRunScriptRefusesWritesOfReads(t *testing.T) {
+	sql.Register("readonly", readOnlyDriver{})
+	db, err := sql.Open("readonly", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	script := []ScriptStatement{
+		{SQL: "CREATE TEMP TABLE picks AS SELECT 1"},
+		{SQL: "INSERT INTO picks VALUES (2)"},
+		{SQL: "SELECT * FROM picks"},
+		{SQL: "DELETE FROM users"},
+	}
+	results, err := RunScript(context.Background(), db, script, false, 10)
+	if err == nil || !strings.Contains(err.Error(), "read-only transaction") {
+		t.Fatalf("expected the write of a read script to fail, got %v", err)
+	}
+	if len(results) != 3 {
+		t.Errorf("expected the 3 statements before the write to run, got %d", len(results))
+	}
+
+	if _, err := RunScript(context.Background(), db, script, true, 10); err != nil {
+		t.Errorf("expected a committed script to write, got %v", err)
+	}
+}
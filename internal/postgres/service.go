@@ -6,9 +6,10 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
 // ServiceEntry represents a PostgreSQL service configuration
@@ -20,7 +21,28 @@ type ServiceEntry struct {
 	User     string
 	Password string
 	SSLMode  string
-	Options  map[string]string
+	// TLS client certificate and key (~/.postgresql/postgresql.crt and .key
+	// when empty) and the CA certificate checked by verify-ca and verify-full
+	SSLCert     string
+	SSLKey      string
+	SSLRootCert string
+	// SSH tunnel through a bastion host; disabled when SSHHost is empty
+	SSHHost string // host or host:port
+	SSHUser string // Defaults to the current user
+	SSHKey  string // Private key path; the SSH agent or ~/.ssh keys are used if empty
+	// Schema preferred when a question names a table found in several schemas
+	DefaultSchema string
+	// Comma-separated glob patterns limiting which schemas and tables are
+	// harvested; see HarvestFilter
+	HarvestInclude string
+	HarvestExclude string
+	// dbt manifest.json documenting the database's models (optional)
+	DbtManifest string
+	// YAML glossary of words questions use for the schema's (optional)
+	Glossary string
+	// Labels such as prod or gis from a "# tags:" comment, lower-cased
+	Tags    []string
+	Options map[string]string
 }
 
 // ParsePGServiceFile parses the pg_service.conf file
@@ -45,18 +67,38 @@ func getPGServicePaths() []string {
 		paths = append(paths, envPath)
 	}
 
-	// User's home directory
-	if home, err := os.UserHomeDir(); err == nil {
-		paths = append(paths, filepath.Join(home, ".pg_service.conf"))
+	// User's own file
+	if path := userServiceFilePath(); path != "" {
+		paths = append(paths, path)
 	}
 
-	// System-wide location
-	paths = append(paths, "/etc/pg_service.conf")
-	paths = append(paths, "/etc/postgresql-common/pg_service.conf")
+	// System-wide locations, PGSYSCONFDIR first as libpq does
+	if dir := os.Getenv("PGSYSCONFDIR"); dir != "" {
+		paths = append(paths, filepath.Join(dir, "pg_service.conf"))
+	}
+	if runtime.GOOS != "windows" {
+		paths = append(paths, "/etc/pg_service.conf")
+		paths = append(paths, "/etc/postgresql-common/pg_service.conf")
+	}
 
 	return paths
 }
 
+// userServiceFilePath returns the user's own service file as libpq finds
+// it: ~/.pg_service.conf, or %APPDATA%\postgresql\.pg_service.conf on
+// Windows
+func userServiceFilePath() string {
+	if runtime.GOOS == "windows" {
+		if appData := os.Getenv("APPDATA"); appData != "" {
+			return filepath.Join(appData, "postgresql", ".pg_service.conf")
+		}
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		return filepath.Join(home, ".pg_service.conf")
+	}
+	return ""
+}
+
 // parsePGServiceFileAt parses a pg_service.conf file at the given path
 func parsePGServiceFileAt(path string) ([]ServiceEntry, error) {
 	file, err := os.Open(path)
@@ -67,11 +109,21 @@ func parsePGServiceFileAt(path string) ([]ServiceEntry, error) {
 
 	var services []ServiceEntry
 	var current *ServiceEntry
+	// Tags of a "# tags:" comment, for the service of the header below it
+	// or, before the first parameter, of the section it is in
+	var pendingTags []string
 
 	scanner := bufio.NewScanner(file)
 	for scanner.Scan() {
 		line := strings.TrimSpace(scanner.Text())
 
+		if tags, ok := strings.CutPrefix(line, "#"); ok {
+			if tags, ok = cutPrefixFold(strings.TrimSpace(tags), "tags:"); ok {
+				pendingTags = ParseTags(tags)
+				continue
+			}
+		}
+
 		// Skip empty lines and comments
 		if line == "" || strings.HasPrefix(line, "#") {
 			continue
@@ -85,11 +137,17 @@ func parsePGServiceFileAt(path string) ([]ServiceEntry, error) {
 			serviceName := strings.TrimSuffix(strings.TrimPrefix(line, "["), "]")
 			current = &ServiceEntry{
 				Name:    serviceName,
+				Tags:    pendingTags,
 				Options: make(map[string]string),
 			}
+			pendingTags = nil
 			continue
 		}
 
+		if current != nil && pendingTags != nil {
+			current.Tags, pendingTags = pendingTags, nil
+		}
+
 		// Parse key=value pairs
 		if current != nil && strings.Contains(line, "=") {
 			parts := strings.SplitN(line, "=", 2)
@@ -110,6 +168,28 @@ func parsePGServiceFileAt(path string) ([]ServiceEntry, error) {
 					current.Password = value
 				case "sslmode":
 					current.SSLMode = value
+				case "sslcert":
+					current.SSLCert = value
+				case "sslkey":
+					current.SSLKey = value
+				case "sslrootcert":
+					current.SSLRootCert = value
+				case "ssh_host":
+					current.SSHHost = value
+				case "ssh_user":
+					current.SSHUser = value
+				case "ssh_key":
+					current.SSHKey = value
+				case "default_schema":
+					current.DefaultSchema = value
+				case "harvest_include":
+					current.HarvestInclude = value
+				case "harvest_exclude":
+					current.HarvestExclude = value
+				case "dbt_manifest":
+					current.DbtManifest = value
+				case "glossary":
+					current.Glossary = value
 				default:
 					current.Options[key] = value
 				}
@@ -119,6 +199,9 @@ func parsePGServiceFileAt(path string) ([]ServiceEntry, error) {
 
 	// Don't forget the last service
 	if current != nil {
+		if pendingTags != nil {
+			current.Tags = pendingTags
+		}
 		services = append(services, *current)
 	}
 
@@ -129,41 +212,107 @@ func parsePGServiceFileAt(path string) ([]ServiceEntry, error) {
 	return services, nil
 }
 
-// ConnectionString returns a PostgreSQL connection string for the service
+// ParseTags splits a comma-separated tag list, such as "Prod, gis", into
+// lower-cased tags
+func ParseTags(list string) []string {
+	var tags []string
+	for _, tag := range strings.Split(list, ",") {
+		if tag = strings.ToLower(strings.TrimSpace(tag)); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// HasTag reports whether the service is tagged tag, ignoring case
+func (s *ServiceEntry) HasTag(tag string) bool {
+	for _, t := range s.Tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsProduction reports whether the service is tagged prod or production,
+// which the interface warns about when connecting
+func (s *ServiceEntry) IsProduction() bool {
+	return s.HasTag("prod") || s.HasTag("production")
+}
+
+// HarvestFilter returns the schemas and tables to harvest for the service
+func (s *ServiceEntry) HarvestFilter() HarvestFilter {
+	return ParseHarvestFilter(s.HarvestInclude, s.HarvestExclude)
+}
+
+// ConnectionString returns a PostgreSQL connection string for the service.
+// Parameters missing from the service come from the libpq environment and
+// the password file (see Resolved).
 func (s *ServiceEntry) ConnectionString() string {
+	r := s.Resolved()
 	parts := []string{}
 
-	if s.Host != "" {
-		parts = append(parts, fmt.Sprintf("host=%s", s.Host))
+	if r.Host != "" {
+		parts = append(parts, "host="+connValue(r.Host))
 	}
-	if s.Port != "" {
-		parts = append(parts, fmt.Sprintf("port=%s", s.Port))
+	if r.Port != "" {
+		parts = append(parts, "port="+connValue(r.Port))
 	}
-	if s.DBName != "" {
-		parts = append(parts, fmt.Sprintf("dbname=%s", s.DBName))
+	if r.DBName != "" {
+		parts = append(parts, "dbname="+connValue(r.DBName))
 	}
-	if s.User != "" {
-		parts = append(parts, fmt.Sprintf("user=%s", s.User))
+	if r.User != "" {
+		parts = append(parts, "user="+connValue(r.User))
 	}
-	if s.Password != "" {
-		parts = append(parts, fmt.Sprintf("password=%s", s.Password))
+	if r.Password != "" {
+		parts = append(parts, "password="+connValue(r.Password))
 	}
-	if s.SSLMode != "" {
-		parts = append(parts, fmt.Sprintf("sslmode=%s", s.SSLMode))
+	if r.SSLMode != "" {
+		parts = append(parts, "sslmode="+connValue(r.SSLMode))
 	} else {
 		parts = append(parts, "sslmode=prefer")
 	}
+	if r.SSLCert != "" {
+		parts = append(parts, "sslcert="+connValue(expandHome(r.SSLCert)))
+	}
+	if r.SSLKey != "" {
+		parts = append(parts, "sslkey="+connValue(expandHome(r.SSLKey)))
+	}
+	if r.SSLRootCert != "" {
+		parts = append(parts, "sslrootcert="+connValue(expandHome(r.SSLRootCert)))
+	}
 
-	for k, v := range s.Options {
-		parts = append(parts, fmt.Sprintf("%s=%s", k, v))
+	for k, v := range r.Options {
+		parts = append(parts, k+"="+connValue(v))
 	}
 
 	return strings.Join(parts, " ")
 }
 
-// Connect creates a database connection to this service
+// connValue quotes a connection string value if it is empty or contains
+// spaces, quotes or backslashes
+func connValue(v string) string {
+	if v != "" && !strings.ContainsAny(v, " \t'\\") {
+		return v
+	}
+	v = strings.ReplaceAll(v, `\`, `\\`)
+	v = strings.ReplaceAll(v, `'`, `\'`)
+	return "'" + v + "'"
+}
+
+// Connect creates a database connection to this service, dialing through
+// an SSH tunnel when ssh_host is set
 func (s *ServiceEntry) Connect() (*sql.DB, error) {
-	return sql.Open("postgres", s.ConnectionString())
+	if s.SSHHost == "" {
+		return sql.Open("postgres", s.ConnectionString())
+	}
+
+	connector, err := pq.NewConnector(s.ConnectionString())
+	if err != nil {
+		return nil, err
+	}
+	connector.Dialer(sshDialer{target: sshTargetFor(s)})
+	return sql.OpenDB(connector), nil
 }
 
 // TestConnection tests if a connection can be established
@@ -193,11 +342,7 @@ func GetPGServiceFilePath() string {
 	if envPath := os.Getenv("PGSERVICEFILE"); envPath != "" {
 		return envPath
 	}
-	// Default to user's home directory
-	if home, err := os.UserHomeDir(); err == nil {
-		return filepath.Join(home, ".pg_service.conf")
-	}
-	return ""
+	return userServiceFilePath()
 }
 
 // PGServiceFileExists checks if the pg_service.conf file exists
@@ -278,6 +423,9 @@ func writePGServiceFile(path string, services []ServiceEntry) error {
 
 	for _, s := range services {
 		content.WriteString(fmt.Sprintf("[%s]\n", s.Name))
+		if len(s.Tags) > 0 {
+			content.WriteString(fmt.Sprintf("# tags: %s\n", strings.Join(s.Tags, ", ")))
+		}
 		if s.Host != "" {
 			content.WriteString(fmt.Sprintf("host=%s\n", s.Host))
 		}
@@ -296,6 +444,39 @@ func writePGServiceFile(path string, services []ServiceEntry) error {
 		if s.SSLMode != "" {
 			content.WriteString(fmt.Sprintf("sslmode=%s\n", s.SSLMode))
 		}
+		if s.SSLCert != "" {
+			content.WriteString(fmt.Sprintf("sslcert=%s\n", s.SSLCert))
+		}
+		if s.SSLKey != "" {
+			content.WriteString(fmt.Sprintf("sslkey=%s\n", s.SSLKey))
+		}
+		if s.SSLRootCert != "" {
+			content.WriteString(fmt.Sprintf("sslrootcert=%s\n", s.SSLRootCert))
+		}
+		if s.SSHHost != "" {
+			content.WriteString(fmt.Sprintf("ssh_host=%s\n", s.SSHHost))
+		}
+		if s.SSHUser != "" {
+			content.WriteString(fmt.Sprintf("ssh_user=%s\n", s.SSHUser))
+		}
+		if s.SSHKey != "" {
+			content.WriteString(fmt.Sprintf("ssh_key=%s\n", s.SSHKey))
+		}
+		if s.DefaultSchema != "" {
+			content.WriteString(fmt.Sprintf("default_schema=%s\n", s.DefaultSchema))
+		}
+		if s.HarvestInclude != "" {
+			content.WriteString(fmt.Sprintf("harvest_include=%s\n", s.HarvestInclude))
+		}
+		if s.HarvestExclude != "" {
+			content.WriteString(fmt.Sprintf("harvest_exclude=%s\n", s.HarvestExclude))
+		}
+		if s.DbtManifest != "" {
+			content.WriteString(fmt.Sprintf("dbt_manifest=%s\n", s.DbtManifest))
+		}
+		if s.Glossary != "" {
+			content.WriteString(fmt.Sprintf("glossary=%s\n", s.Glossary))
+		}
 		for k, v := range s.Options {
 			content.WriteString(fmt.Sprintf("%s=%s\n", k, v))
 		}
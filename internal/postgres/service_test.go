@@ -3,6 +3,8 @@ package postgres
 import (
 	"os"
 	"path/filepath"
+	"reflect"
+	"runtime"
 	"testing"
 )
 
@@ -110,6 +112,95 @@ func TestServiceConnectionString(t *testing.T) {
 	}
 }
 
+func TestServiceClientCertificates(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	path := filepath.Join(t.TempDir(), "pg_service.conf")
+	entry := ServiceEntry{
+		Name:        "secure",
+		Host:        "db.example.com",
+		DBName:      "gis",
+		SSLMode:     "verify-full",
+		SSLCert:     "~/certs/client.crt",
+		SSLKey:      "~/certs/client.key",
+		SSLRootCert: "/etc/ssl/ca.pem",
+		Options:     map[string]string{},
+	}
+	if err := writePGServiceFile(path, []ServiceEntry{entry}); err != nil {
+		t.Fatalf("writePGServiceFile error: %v", err)
+	}
+	services, err := parsePGServiceFileAt(path)
+	if err != nil || len(services) != 1 {
+		t.Fatalf("parsePGServiceFileAt = %v, %v", services, err)
+	}
+	got := services[0]
+	if got.SSLCert != entry.SSLCert || got.SSLKey != entry.SSLKey || got.SSLRootCert != entry.SSLRootCert {
+		t.Errorf("certificate fields not preserved: %+v", got)
+	}
+	if len(got.Options) != 0 {
+		t.Errorf("certificate fields should not be stored as options: %v", got.Options)
+	}
+
+	connStr := got.ConnectionString()
+	for _, e := range []string{
+		"sslcert=" + connValue(filepath.Join(home, "certs", "client.crt")),
+		"sslkey=" + connValue(filepath.Join(home, "certs", "client.key")),
+		"sslrootcert=/etc/ssl/ca.pem",
+	} {
+		if !contains(connStr, e) {
+			t.Errorf("connection string missing '%s': %s", e, connStr)
+		}
+	}
+}
+
+func TestParsePGServiceFileTags(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pg_service.conf")
+	content := `# Shared services
+
+# tags: Prod, gis
+[live]
+host=db.example.com
+
+[staging]
+# Tags: staging
+host=staging.example.com
+
+[local]
+host=localhost
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write test file: %v", err)
+	}
+	services, err := parsePGServiceFileAt(path)
+	if err != nil || len(services) != 3 {
+		t.Fatalf("parsePGServiceFileAt = %v, %v", services, err)
+	}
+	want := [][]string{{"prod", "gis"}, {"staging"}, nil}
+	for i, s := range services {
+		if !reflect.DeepEqual(s.Tags, want[i]) {
+			t.Errorf("%s tags = %q, want %q", s.Name, s.Tags, want[i])
+		}
+	}
+	if !services[0].IsProduction() || services[1].IsProduction() || services[2].IsProduction() {
+		t.Errorf("only live should be production")
+	}
+
+	// Tags survive writing the file back
+	if err := writePGServiceFile(path, services); err != nil {
+		t.Fatalf("writePGServiceFile error: %v", err)
+	}
+	again, err := parsePGServiceFileAt(path)
+	if err != nil || len(again) != 3 {
+		t.Fatalf("parsePGServiceFileAt after writing = %v, %v", again, err)
+	}
+	for i, s := range again {
+		if !reflect.DeepEqual(s.Tags, want[i]) {
+			t.Errorf("%s tags after writing = %q, want %q", s.Name, s.Tags, want[i])
+		}
+	}
+}
+
 func TestServiceConnectionStringDefaults(t *testing.T) {
 	service := ServiceEntry{
 		Name:   "minimal",
@@ -225,3 +316,88 @@ func contains(s, substr string) bool {
 	}
 	return false
 }
+
+func TestSSHServiceFieldsRoundTrip(t *testing.T) {
+	serviceFile := filepath.Join(t.TempDir(), "pg_service.conf")
+	entry := ServiceEntry{
+		Name:    "behind-bastion",
+		Host:    "10.0.0.5",
+		DBName:  "gis",
+		SSHHost: "bastion.example.com:2222",
+		SSHUser: "deploy",
+		SSHKey:  "~/.ssh/bastion",
+		Options: map[string]string{},
+
+		DefaultSchema:  "staging",
+		HarvestInclude: "public, gis.*",
+		HarvestExclude: "*.*_p20*",
+		DbtManifest:    "~/analytics/target/manifest.json",
+		Glossary:       "~/analytics/glossary.yaml",
+	}
+	if err := writePGServiceFile(serviceFile, []ServiceEntry{entry}); err != nil {
+		t.Fatalf("writePGServiceFile failed: %v", err)
+	}
+
+	services, err := parsePGServiceFileAt(serviceFile)
+	if err != nil {
+		t.Fatalf("parsePGServiceFileAt failed: %v", err)
+	}
+	if len(services) != 1 {
+		t.Fatalf("expected 1 service, got %d", len(services))
+	}
+	got := services[0]
+	if got.SSHHost != entry.SSHHost || got.SSHUser != entry.SSHUser || got.SSHKey != entry.SSHKey {
+		t.Errorf("SSH fields not preserved: %+v", got)
+	}
+	if got.DefaultSchema != "staging" {
+		t.Errorf("default_schema not preserved: %q", got.DefaultSchema)
+	}
+	if got.HarvestInclude != entry.HarvestInclude || got.HarvestExclude != entry.HarvestExclude {
+		t.Errorf("harvest filter not preserved: %q / %q", got.HarvestInclude, got.HarvestExclude)
+	}
+	if got.DbtManifest != entry.DbtManifest {
+		t.Errorf("dbt_manifest not preserved: %q", got.DbtManifest)
+	}
+	if got.Glossary != entry.Glossary {
+		t.Errorf("glossary not preserved: %q", got.Glossary)
+	}
+	if len(got.Options) != 0 {
+		t.Errorf("SSH fields should not be stored as options: %v", got.Options)
+	}
+	if connStr := got.ConnectionString(); contains(connStr, "ssh") || contains(connStr, "default_schema") || contains(connStr, "harvest") || contains(connStr, "dbt") || contains(connStr, "glossary") {
+		t.Errorf("application fields must not reach the connection string: %s", connStr)
+	}
+}
+
+func TestSSHTargetDefaults(t *testing.T) {
+	target := sshTargetFor(&ServiceEntry{SSHHost: "bastion", SSHUser: "deploy"})
+	if target.addr != "bastion:22" {
+		t.Errorf("addr = %q, want default port 22", target.addr)
+	}
+
+	target = sshTargetFor(&ServiceEntry{SSHHost: "bastion:2222", SSHUser: "deploy"})
+	if target.addr != "bastion:2222" || target.user != "deploy" {
+		t.Errorf("unexpected target: %+v", target)
+	}
+}
+
+func TestGetPGServicePaths(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	t.Setenv("APPDATA", filepath.Join(home, "AppData"))
+	t.Setenv("PGSERVICEFILE", "")
+	t.Setenv("PGSYSCONFDIR", filepath.Join(home, "etc"))
+
+	user := filepath.Join(home, ".pg_service.conf")
+	if runtime.GOOS == "windows" {
+		user = filepath.Join(home, "AppData", "postgresql", ".pg_service.conf")
+	}
+	paths := getPGServicePaths()
+	if len(paths) < 2 || paths[0] != user || paths[1] != filepath.Join(home, "etc", "pg_service.conf") {
+		t.Errorf("getPGServicePaths() = %v, want the user's file then PGSYSCONFDIR first", paths)
+	}
+	if got := GetPGServiceFilePath(); got != user {
+		t.Errorf("GetPGServiceFilePath() = %q, want %q", got, user)
+	}
+}
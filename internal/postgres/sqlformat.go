@@ -0,0 +1,349 @@
+package postgres
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// formatIndent indents the lists and conditions of a formatted clause, and
+// the clauses of a subquery
+const formatIndent = "    "
+
+// sqlNode is a token of SQL being formatted, or a parenthesised group of
+// them
+type sqlNode struct {
+	text     string // The token's text, or "(" for a group
+	space    bool   // Whether whitespace preceded it
+	group    []sqlNode
+	isGroup  bool
+	closed   bool // Whether the group's ) was found
+	subquery bool // Whether the group holds a SELECT, WITH or VALUES
+}
+
+// word returns the node's text lower-cased, or "" for a group
+func (n sqlNode) word() string {
+	if n.isGroup {
+		return ""
+	}
+	return strings.ToLower(n.text)
+}
+
+// sqlClause is a clause of a statement: the keywords starting it, such as
+// GROUP BY, and the rest of it
+type sqlClause struct {
+	head, body []sqlNode
+	conditions bool // Whether the body is joined by AND and OR
+}
+
+// FormatSQL lays out SQL written on one line for reading: each clause
+// starts a line, lists and conditions of clauses longer than width are put
+// one to a line, and subqueries are indented. SQL already spread over
+// several lines, or with comments, is returned as it is, so layouts users
+// chose are kept; so is the text of every token.
+func FormatSQL(sql string, width int) string {
+	trimmed := strings.TrimSpace(sql)
+	if trimmed == "" || strings.Contains(trimmed, "\n") {
+		return sql
+	}
+	tokens := LexSQL(trimmed)
+	for _, tok := range tokens {
+		if tok.Kind == TokenComment {
+			return sql
+		}
+	}
+
+	nodes := parseNodes(tokens)
+	var statements []string
+	start := 0
+	for i, n := range nodes {
+		if !n.isGroup && n.text == ";" {
+			statements = append(statements, formatStatement(nodes[start:i], "", width)+";")
+			start = i + 1
+		}
+	}
+	if start < len(nodes) {
+		statements = append(statements, formatStatement(nodes[start:], "", width))
+	}
+	return strings.Join(statements, "\n")
+}
+
+// parseNodes groups tokens by parentheses, dropping whitespace but noting
+// where it was
+func parseNodes(tokens []Token) []sqlNode {
+	stack := [][]sqlNode{nil}
+	space := false
+	for _, tok := range tokens {
+		top := len(stack) - 1
+		switch {
+		case tok.Kind == TokenWhitespace:
+			space = true
+			continue
+		case tok.Kind == TokenPunctuation && tok.Text == "(":
+			stack = append(stack, []sqlNode{{space: space}})
+		case tok.Kind == TokenPunctuation && tok.Text == ")" && top > 0:
+			stack = closeGroup(stack, true)
+		default:
+			stack[top] = append(stack[top], sqlNode{text: tok.Text, space: space})
+		}
+		space = false
+	}
+	for len(stack) > 1 {
+		stack = closeGroup(stack, false)
+	}
+	return stack[0]
+}
+
+// closeGroup ends the innermost group of the stack parseNodes keeps, the
+// group's first element holding where it started
+func closeGroup(stack [][]sqlNode, closed bool) [][]sqlNode {
+	top := len(stack) - 1
+	group := sqlNode{text: "(", space: stack[top][0].space, group: stack[top][1:], isGroup: true, closed: closed}
+	if len(group.group) > 0 {
+		switch group.group[0].word() {
+		case "select", "with", "values":
+			group.subquery = true
+		}
+	}
+	stack = stack[:top]
+	stack[top-1] = append(stack[top-1], group)
+	return stack
+}
+
+// joinModifiers are the words that can come before JOIN
+var joinModifiers = map[string]bool{
+	"natural": true, "left": true, "right": true, "full": true, "inner": true, "cross": true, "outer": true,
+}
+
+// clauseHead returns how many nodes from i are the keywords starting a
+// clause, 0 when no clause starts there
+func clauseHead(nodes []sqlNode, i int) int {
+	word := nodes[i].word()
+	next := func(j int) string {
+		if i+j < len(nodes) {
+			return nodes[i+j].word()
+		}
+		return ""
+	}
+	switch word {
+	case "select":
+		if next(1) == "distinct" {
+			if next(2) == "on" && i+3 < len(nodes) && nodes[i+3].isGroup {
+				return 4
+			}
+			return 2
+		}
+		return 1
+	case "from":
+		// IS DISTINCT FROM compares
+		if i > 0 && nodes[i-1].word() == "distinct" {
+			return 0
+		}
+		return 1
+	case "where", "having", "limit", "offset", "fetch", "returning", "window", "values", "set", "join":
+		return 1
+	case "group", "order":
+		if next(1) == "by" {
+			return 2
+		}
+	case "union", "intersect", "except":
+		if next(1) == "all" || next(1) == "distinct" {
+			return 2
+		}
+		return 1
+	case "with":
+		if i == 0 {
+			if next(1) == "recursive" {
+				return 2
+			}
+			return 1
+		}
+	case "insert":
+		if i == 0 && next(1) == "into" {
+			return 2
+		}
+	case "delete":
+		if i == 0 && next(1) == "from" {
+			return 2
+		}
+	case "update":
+		// Not FOR UPDATE or DO UPDATE
+		if i == 0 {
+			return 1
+		}
+	default:
+		if joinModifiers[word] {
+			n := 1
+			for joinModifiers[next(n)] {
+				n++
+			}
+			if next(n) == "join" {
+				return n + 1
+			}
+		}
+	}
+	return 0
+}
+
+// splitClauses splits the nodes of a statement into its clauses. Nodes
+// before the first clause keyword, as in CREATE TABLE ... AS, make a clause
+// without a head.
+func splitClauses(nodes []sqlNode) []sqlClause {
+	var clauses []sqlClause
+	var current *sqlClause
+	for i := 0; i < len(nodes); {
+		if n := clauseHead(nodes, i); n > 0 {
+			clauses = append(clauses, sqlClause{head: nodes[i : i+n]})
+			current = &clauses[len(clauses)-1]
+			switch nodes[i+n-1].word() {
+			case "where", "having", "join":
+				current.conditions = true
+			}
+			i += n
+			continue
+		}
+		if current == nil {
+			clauses = append(clauses, sqlClause{})
+			current = &clauses[len(clauses)-1]
+		}
+		current.body = append(current.body, nodes[i])
+		i++
+	}
+	return clauses
+}
+
+// formatStatement lays out a statement's clauses, one to a line
+func formatStatement(nodes []sqlNode, indent string, width int) string {
+	clauses := splitClauses(nodes)
+	lines := make([]string, 0, len(clauses))
+	for _, c := range clauses {
+		lines = append(lines, formatClause(c, indent, width))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// formatClause lays out a clause on a line when it fits, else its list one
+// item to a line or its conditions one to a line
+func formatClause(c sqlClause, indent string, width int) string {
+	head := inlineNodes(c.head)
+	if fitsWidth(indent+joinText(head, inlineNodes(c.body)), width) {
+		return formatItem(head, c.body, indent, width)
+	}
+
+	if items, _ := splitNodes(c.body, func(n sqlNode) bool { return n.text == "," }); len(items) > 1 && head != "" {
+		lines := []string{indent + head}
+		for i, item := range items {
+			line := formatItem("", item, indent+formatIndent, width)
+			if i < len(items)-1 {
+				line += ","
+			}
+			lines = append(lines, line)
+		}
+		return strings.Join(lines, "\n")
+	}
+
+	if c.conditions {
+		between := false
+		items, seps := splitNodes(c.body, func(n sqlNode) bool {
+			switch n.word() {
+			case "between":
+				between = true
+			case "and":
+				if between {
+					between = false
+					return false
+				}
+				return true
+			case "or":
+				return true
+			}
+			return false
+		})
+		if len(items) > 1 {
+			lines := []string{formatItem(head, items[0], indent, width)}
+			for i, item := range items[1:] {
+				lines = append(lines, formatItem(seps[i].text, item, indent+formatIndent, width))
+			}
+			return strings.Join(lines, "\n")
+		}
+	}
+	return formatItem(head, c.body, indent, width)
+}
+
+// formatItem lays out nodes after prefix on a line when they fit, else
+// with their subqueries indented on lines of their own
+func formatItem(prefix string, nodes []sqlNode, indent string, width int) string {
+	text := joinText(prefix, inlineNodes(nodes))
+	if fitsWidth(indent+text, width) {
+		return indent + text
+	}
+
+	var b strings.Builder
+	b.WriteString(indent + prefix)
+	for i, n := range nodes {
+		if (i > 0 || prefix != "") && n.space {
+			b.WriteByte(' ')
+		}
+		if !n.subquery {
+			b.WriteString(n.String())
+			continue
+		}
+		b.WriteString("(\n")
+		b.WriteString(formatStatement(n.group, indent+formatIndent, width))
+		if n.closed {
+			b.WriteString("\n" + indent + ")")
+		}
+	}
+	return b.String()
+}
+
+// splitNodes splits nodes at the separators sep picks, returning the nodes
+// between them and the separators
+func splitNodes(nodes []sqlNode, sep func(sqlNode) bool) (items [][]sqlNode, seps []sqlNode) {
+	start := 0
+	for i, n := range nodes {
+		if !n.isGroup && sep(n) {
+			items = append(items, nodes[start:i])
+			seps = append(seps, n)
+			start = i + 1
+		}
+	}
+	return append(items, nodes[start:]), seps
+}
+
+// String returns the node's text, with a group's on one line
+func (n sqlNode) String() string {
+	if !n.isGroup {
+		return n.text
+	}
+	if n.closed {
+		return "(" + inlineNodes(n.group) + ")"
+	}
+	return "(" + inlineNodes(n.group)
+}
+
+// inlineNodes returns the text of nodes on one line, separated where the
+// SQL had whitespace by a single space
+func inlineNodes(nodes []sqlNode) string {
+	var b strings.Builder
+	for i, n := range nodes {
+		if i > 0 && n.space {
+			b.WriteByte(' ')
+		}
+		b.WriteString(n.String())
+	}
+	return b.String()
+}
+
+// joinText joins two pieces of a line with a space, leaving out empty ones
+func joinText(a, b string) string {
+	if a == "" || b == "" {
+		return a + b
+	}
+	return a + " " + b
+}
+
+// fitsWidth reports whether a line is at most width characters long; any
+// line fits a width of 0 or less
+func fitsWidth(line string, width int) bool {
+	return width <= 0 || utf8.RuneCountInString(line) <= width
+}
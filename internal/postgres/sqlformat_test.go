@@ -0,0 +1,61 @@
+package postgres
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFormatSQL(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want string
+	}{
+		{`SELECT COUNT(*) as count FROM "public"."roads"`, "SELECT COUNT(*) as count\nFROM \"public\".\"roads\""},
+		{
+			"SELECT r.name, r.surface, r.lanes, r.speed_limit, ST_Length(r.geom) AS length FROM roads r LEFT JOIN closures c ON c.road_id = r.gid AND c.ends_at > now() WHERE r.lanes BETWEEN 2 AND 4 AND r.surface = 'tar' ORDER BY length DESC LIMIT 10;",
+			"SELECT\n    r.name,\n    r.surface,\n    r.lanes,\n    r.speed_limit,\n    ST_Length(r.geom) AS length\n" +
+				"FROM roads r\nLEFT JOIN closures c ON c.road_id = r.gid AND c.ends_at > now()\n" +
+				"WHERE r.lanes BETWEEN 2 AND 4 AND r.surface = 'tar'\nORDER BY length DESC\nLIMIT 10;",
+		},
+		{
+			"WITH closed AS (SELECT road_id, max(ends_at) AS until FROM closures GROUP BY road_id) SELECT name FROM roads WHERE gid IN (SELECT road_id FROM closed WHERE until > now() - interval '7 days' AND road_id > 100)",
+			"WITH closed AS (\n    SELECT road_id, max(ends_at) AS until\n    FROM closures\n    GROUP BY road_id\n)\n" +
+				"SELECT name\nFROM roads\nWHERE gid IN (\n    SELECT road_id\n    FROM closed\n" +
+				"    WHERE until > now() - interval '7 days' AND road_id > 100\n)",
+		},
+		{"SELECT extract(year FROM built) FROM roads UNION ALL SELECT 1", "SELECT extract(year FROM built)\nFROM roads\nUNION ALL\nSELECT 1"},
+		{"SELECT a FROM t WHERE a IS DISTINCT FROM b", "SELECT a\nFROM t\nWHERE a IS DISTINCT FROM b"},
+		{"INSERT INTO roads (name) VALUES ('Main'); DELETE FROM roads WHERE gid = 1", "INSERT INTO roads (name)\nVALUES ('Main');\nDELETE FROM roads\nWHERE gid = 1"},
+		{"SELECT * FROM roads FOR UPDATE", "SELECT *\nFROM roads FOR UPDATE"},
+		{"SELECT (name", "SELECT (name"},
+	}
+	for _, tt := range tests {
+		if got := FormatSQL(tt.sql, 70); got != tt.want {
+			t.Errorf("FormatSQL(%q) =\n%s\nwant\n%s", tt.sql, got, tt.want)
+		}
+	}
+}
+
+func TestFormatSQLWrapsConditions(t *testing.T) {
+	sql := "SELECT name FROM roads WHERE surface = 'tar' AND lanes BETWEEN 2 AND 4 OR speed_limit > 100"
+	want := "SELECT name\nFROM roads\nWHERE surface = 'tar'\n    AND lanes BETWEEN 2 AND 4\n    OR speed_limit > 100"
+	if got := FormatSQL(sql, 40); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+	if got := FormatSQL(sql, 0); strings.Count(got, "\n") != 2 {
+		t.Errorf("without a width only clauses should start lines:\n%s", got)
+	}
+}
+
+func TestFormatSQLKeepsLayouts(t *testing.T) {
+	for _, sql := range []string{
+		"SELECT name\n  FROM roads",
+		"SELECT name FROM roads -- the lot",
+		"SELECT /* all */ name FROM roads",
+		"",
+	} {
+		if got := FormatSQL(sql, 70); got != sql {
+			t.Errorf("FormatSQL(%q) = %q, want it left alone", sql, got)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package postgres
+
+import (
+	"strings"
+
+	"github.com/kartoza/kartoza-pg-ai/internal/config"
+)
+
+// Limits on the statistics kept per column, so prompts stay small
+const (
+	maxCommonValues  = 10
+	maxStatValueSize = 64
+)
+
+// harvestColumnStats reads pg_stats for all user tables, keyed by
+// "schema.table" and then column name. Only columns the planner has analysed
+// appear; geometry and oversized values are dropped.
+func (h *SchemaHarvester) harvestColumnStats() (map[string]map[string]*config.ColumnStats, error) {
+	// Parent rows of inheritance trees come last so a table's own stats win
+	query := `
+		SELECT
+			schemaname,
+			tablename,
+			attname,
+			COALESCE(n_distinct, 0),
+			COALESCE(null_frac, 0),
+			COALESCE(most_common_vals::text, ''),
+			COALESCE(histogram_bounds::text, '')
+		FROM pg_stats
+		WHERE schemaname NOT IN ('pg_catalog', 'information_schema')
+	`
+	cond, args := h.filter.condition("schemaname", "tablename")
+	query += cond + " ORDER BY schemaname, tablename, attname, inherited"
+
+	rows, err := h.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stats := make(map[string]map[string]*config.ColumnStats)
+	for rows.Next() {
+		var schema, table, column, commonVals, histogram string
+		var s config.ColumnStats
+		if err := rows.Scan(&schema, &table, &column, &s.DistinctCount, &s.NullFraction, &commonVals, &histogram); err != nil {
+			return nil, err
+		}
+
+		key := schema + "." + table
+		if stats[key] == nil {
+			stats[key] = make(map[string]*config.ColumnStats)
+		}
+		if stats[key][column] != nil {
+			continue
+		}
+
+		for _, v := range parseArrayLiteral(commonVals) {
+			if len(s.CommonValues) == maxCommonValues {
+				break
+			}
+			if len(v) <= maxStatValueSize {
+				s.CommonValues = append(s.CommonValues, v)
+			}
+		}
+		if bounds := parseArrayLiteral(histogram); len(bounds) > 1 {
+			lo, hi := bounds[0], bounds[len(bounds)-1]
+			if len(lo) <= maxStatValueSize && len(hi) <= maxStatValueSize {
+				s.MinValue, s.MaxValue = lo, hi
+			}
+		}
+		stats[key][column] = &s
+	}
+
+	return stats, rows.Err()
+}
+
+// applyColumnStats attaches harvested statistics to non-geometry table columns
+func applyColumnStats(tables []config.TableInfo, stats map[string]map[string]*config.ColumnStats) {
+	for i := range tables {
+		tableStats := stats[tables[i].Schema+"."+tables[i].Name]
+		if tableStats == nil {
+			continue
+		}
+		for j := range tables[i].Columns {
+			col := &tables[i].Columns[j]
+			if col.IsGeometry || col.IsVector || col.DataType == "bytea" {
+				continue
+			}
+			col.Stats = tableStats[col.Name]
+		}
+	}
+}
+
+// parseArrayLiteral splits a one-dimensional PostgreSQL array literal such as
+// {a,"b c",NULL} into its elements. Unquoted NULL elements are skipped.
+func parseArrayLiteral(literal string) []string {
+	literal = strings.TrimSpace(literal)
+	if len(literal) < 2 || literal[0] != '{' || literal[len(literal)-1] != '}' {
+		return nil
+	}
+	body := literal[1 : len(literal)-1]
+	if body == "" {
+		return nil
+	}
+
+	var values []string
+	var current strings.Builder
+	quoted := false    // Inside a quoted element
+	wasQuoted := false // Current element was quoted (so NULL is literal text)
+	for i := 0; i < len(body); i++ {
+		c := body[i]
+		switch {
+		case c == '\\' && i+1 < len(body):
+			i++
+			current.WriteByte(body[i])
+		case c == '"':
+			quoted = !quoted
+			wasQuoted = true
+		case c == ',' && !quoted:
+			if v := current.String(); wasQuoted || v != "NULL" {
+				values = append(values, v)
+			}
+			current.Reset()
+			wasQuoted = false
+		default:
+			current.WriteByte(c)
+		}
+	}
+	if v := current.String(); wasQuoted || v != "NULL" {
+		values = append(values, v)
+	}
+	return values
+}
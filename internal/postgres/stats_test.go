@@ -0,0 +1,28 @@
+package postgres
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseArrayLiteral(t *testing.T) {
+	tests := []struct {
+		literal  string
+		expected []string
+	}{
+		{`{shipped,pending}`, []string{"shipped", "pending"}},
+		{`{"in transit",done}`, []string{"in transit", "done"}},
+		{`{"say \"hi\"","a,b"}`, []string{`say "hi"`, "a,b"}},
+		{`{1,NULL,"NULL"}`, []string{"1", "NULL"}},
+		{`{}`, nil},
+		{``, nil},
+		{`not an array`, nil},
+	}
+
+	for _, tt := range tests {
+		got := parseArrayLiteral(tt.literal)
+		if !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("parseArrayLiteral(%q) = %q, want %q", tt.literal, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// statementTimeoutKey is the context key of the statement timeout
+type statementTimeoutKey struct{}
+
+// WithStatementTimeout returns a context whose statements PostgreSQL
+// cancels after d, when run in transactions begun by BeginTx, OpenCursor
+// or RunScript. d of 0 leaves them unlimited.
+func WithStatementTimeout(ctx context.Context, d time.Duration) context.Context {
+	return context.WithValue(ctx, statementTimeoutKey{}, d)
+}
+
+// StatementTimeout returns the statement timeout of ctx, 0 when it has none
+func StatementTimeout(ctx context.Context) time.Duration {
+	d, _ := ctx.Value(statementTimeoutKey{}).(time.Duration)
+	return d
+}
+
+// BeginTx begins a transaction whose statements are bound by the
+// statement timeout of ctx. Cancelling ctx rolls the transaction back.
+func BeginTx(ctx context.Context, db *sql.DB, opts *sql.TxOptions) (*sql.Tx, error) {
+	tx, err := db.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := setLocalTimeout(ctx, tx); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	return tx, nil
+}
+
+// setLocalTimeout sets the statement timeout of ctx for the rest of the
+// transaction, so it ends with it and never leaks into the pooled
+// connection
+func setLocalTimeout(ctx context.Context, tx *sql.Tx) error {
+	d := StatementTimeout(ctx)
+	if d <= 0 {
+		return nil
+	}
+	// SET takes no parameters; the value is a number of milliseconds
+	_, err := tx.ExecContext(ctx, fmt.Sprintf("SET LOCAL statement_timeout = %d", d.Milliseconds()))
+	return err
+}
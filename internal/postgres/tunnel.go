@@ -0,0 +1,260 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// defaultSSHPort is used when ssh_host does not name a port
+const defaultSSHPort = "22"
+
+// sshConnectTimeout bounds the SSH handshake with the bastion
+const sshConnectTimeout = 15 * time.Second
+
+// defaultSSHKeys are tried, in order, when no ssh_key is configured and no
+// agent is available
+var defaultSSHKeys = []string{"id_ed25519", "id_ecdsa", "id_rsa"}
+
+// sshTarget identifies a bastion login; services sharing one share a client
+type sshTarget struct {
+	addr string // host:port of the bastion
+	user string
+	key  string // Private key path, empty for agent/default keys
+}
+
+// tunnelManager keeps one SSH client per bastion for the life of the process.
+// Database connections are dialed through the client, so no local port is
+// opened and pooled connections reuse the same SSH session.
+type tunnelManager struct {
+	mu      sync.Mutex
+	clients map[sshTarget]*ssh.Client
+}
+
+// tunnels is the process-wide tunnel manager
+var tunnels = &tunnelManager{clients: make(map[sshTarget]*ssh.Client)}
+
+// sshTargetFor returns the bastion login for a service, applying defaults
+func sshTargetFor(s *ServiceEntry) sshTarget {
+	addr := s.SSHHost
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, defaultSSHPort)
+	}
+	username := s.SSHUser
+	if username == "" {
+		if u, err := user.Current(); err == nil {
+			// Windows names the user DOMAIN\user
+			username = u.Username[strings.LastIndex(u.Username, `\`)+1:]
+		}
+	}
+	return sshTarget{addr: addr, user: username, key: expandHome(s.SSHKey)}
+}
+
+// expandHome replaces a leading ~ with the user's home directory, also
+// written ~\ on Windows
+func expandHome(path string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") || strings.HasPrefix(path, `~\`) {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, path[1:])
+		}
+	}
+	return path
+}
+
+// client returns the SSH client for a target, connecting if needed. The
+// lock is not held while connecting, so a slow bastion does not hold up
+// the others; when two dials race, the first client stored is kept.
+func (t *tunnelManager) client(target sshTarget) (*ssh.Client, error) {
+	t.mu.Lock()
+	c, ok := t.clients[target]
+	t.mu.Unlock()
+	if ok {
+		return c, nil
+	}
+
+	c, err := dialSSH(target)
+	if err != nil {
+		return nil, err
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if stored, ok := t.clients[target]; ok {
+		c.Close()
+		return stored, nil
+	}
+	t.clients[target] = c
+	return c, nil
+}
+
+// drop forgets a client that stopped working so the next dial reconnects
+func (t *tunnelManager) drop(target sshTarget, c *ssh.Client) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.clients[target] == c {
+		delete(t.clients, target)
+		c.Close()
+	}
+}
+
+// CloseTunnels closes every open SSH tunnel
+func CloseTunnels() {
+	tunnels.mu.Lock()
+	defer tunnels.mu.Unlock()
+
+	for target, c := range tunnels.clients {
+		c.Close()
+		delete(tunnels.clients, target)
+	}
+}
+
+// dialSSH connects and authenticates to a bastion. The host key must be in
+// ~/.ssh/known_hosts; unknown hosts are refused rather than trusted blindly.
+func dialSSH(target sshTarget) (*ssh.Client, error) {
+	auth, closeAgent, err := sshAuthMethods(target.key)
+	if err != nil {
+		return nil, err
+	}
+	// The agent signs only during the handshake
+	defer closeAgent()
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+	hostKeys, err := knownhosts.New(filepath.Join(home, ".ssh", "known_hosts"))
+	if err != nil {
+		return nil, fmt.Errorf("cannot verify SSH host %s: %w", target.addr, err)
+	}
+
+	config := &ssh.ClientConfig{
+		User:            target.user,
+		Auth:            auth,
+		HostKeyCallback: hostKeys,
+		Timeout:         sshConnectTimeout,
+	}
+	c, err := ssh.Dial("tcp", target.addr, config)
+	if err != nil {
+		return nil, fmt.Errorf("SSH connection to %s failed: %w", target.addr, err)
+	}
+	return c, nil
+}
+
+// sshAuthMethods returns the configured key, or else the SSH agent and the
+// default keys in ~/.ssh. Passphrase-protected keys must be loaded in the
+// agent. closeAgent closes the connection to the agent once authentication
+// is over; it is never nil.
+func sshAuthMethods(keyPath string) (methods []ssh.AuthMethod, closeAgent func(), err error) {
+	closeAgent = func() {}
+	if keyPath != "" {
+		signer, err := loadSSHKey(keyPath)
+		if err != nil {
+			return nil, closeAgent, err
+		}
+		return []ssh.AuthMethod{ssh.PublicKeys(signer)}, closeAgent, nil
+	}
+
+	if sock := os.Getenv("SSH_AUTH_SOCK"); sock != "" {
+		if conn, err := net.Dial("unix", sock); err == nil {
+			closeAgent = func() { conn.Close() }
+			methods = append(methods, ssh.PublicKeysCallback(agent.NewClient(conn).Signers))
+		}
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		var signers []ssh.Signer
+		for _, name := range defaultSSHKeys {
+			if signer, err := loadSSHKey(filepath.Join(home, ".ssh", name)); err == nil {
+				signers = append(signers, signer)
+			}
+		}
+		if len(signers) > 0 {
+			methods = append(methods, ssh.PublicKeys(signers...))
+		}
+	}
+	if len(methods) == 0 {
+		return nil, closeAgent, fmt.Errorf("no SSH key available: set ssh_key or start an SSH agent")
+	}
+	return methods, closeAgent, nil
+}
+
+// loadSSHKey reads an unencrypted private key
+func loadSSHKey(path string) (ssh.Signer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	signer, err := ssh.ParsePrivateKey(data)
+	if err != nil {
+		if _, ok := err.(*ssh.PassphraseMissingError); ok {
+			return nil, fmt.Errorf("SSH key %s is passphrase protected; add it to your SSH agent instead", path)
+		}
+		return nil, fmt.Errorf("invalid SSH key %s: %w", path, err)
+	}
+	return signer, nil
+}
+
+// sshDialer dials database connections through a bastion. It implements
+// pq.Dialer and pq.DialerContext; addresses are resolved on the bastion.
+type sshDialer struct {
+	target sshTarget
+}
+
+// Dial implements pq.Dialer
+func (d sshDialer) Dial(network, address string) (net.Conn, error) {
+	return d.DialContext(context.Background(), network, address)
+}
+
+// DialTimeout implements pq.Dialer
+func (d sshDialer) DialTimeout(network, address string, timeout time.Duration) (net.Conn, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return d.DialContext(ctx, network, address)
+}
+
+// DialContext implements pq.DialerContext. A dead SSH client is replaced
+// and the dial retried once, so tunnels survive bastion reconnects. A
+// client that still answers is kept: the bastion refusing the forward, or
+// the dial timing out, leaves the connections shared with other services
+// open.
+func (d sshDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	for attempt := 0; ; attempt++ {
+		c, err := tunnels.client(d.target)
+		if err != nil {
+			return nil, err
+		}
+		conn, err := c.DialContext(ctx, network, address)
+		if err == nil {
+			return conn, nil
+		}
+		if ctx.Err() != nil || sshClientAlive(c, err) {
+			return nil, fmt.Errorf("dial %s via SSH %s: %w", address, d.target.addr, err)
+		}
+		tunnels.drop(d.target, c)
+		if attempt > 0 {
+			return nil, fmt.Errorf("dial %s via SSH %s: %w", address, d.target.addr, err)
+		}
+	}
+}
+
+// sshClientAlive reports whether a client whose dial failed with err still
+// works. A rejected channel means the session answered; otherwise the
+// client is asked for a keepalive reply.
+func sshClientAlive(c *ssh.Client, err error) bool {
+	var rejected *ssh.OpenChannelError
+	if errors.As(err, &rejected) {
+		return true
+	}
+	_, _, err = c.SendRequest("keepalive@openssh.com", true, nil)
+	return err == nil
+}
@@ -0,0 +1,633 @@
+// Package server answers natural language questions over HTTP with the
+// same engine, schema cache and history as the TUI, so scripts and other
+// tools can query a service without a terminal.
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kartoza/kartoza-pg-ai/internal/config"
+	"github.com/kartoza/kartoza-pg-ai/internal/llm"
+	"github.com/kartoza/kartoza-pg-ai/internal/logging"
+	"github.com/kartoza/kartoza-pg-ai/internal/postgres"
+	"github.com/kartoza/kartoza-pg-ai/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Defaults of Options left zero
+const (
+	DefaultTimeout = 2 * time.Minute
+	DefaultMaxRows = 1000
+)
+
+// Limits of GET /history
+const (
+	defaultHistoryLimit = 50
+	maxHistoryLimit     = 1000
+)
+
+// maxRequestBody bounds the JSON body of POST /query
+const maxRequestBody = 1 << 20
+
+// Options configures a Server
+type Options struct {
+	Token   string        // Bearer token every request must carry; empty allows any request
+	Timeout time.Duration // Limit on answering one question, harvest included
+	MaxRows int           // Rows returned per query; more are reported as truncated
+}
+
+// Server serves the REST API. Its handlers may run concurrently.
+type Server struct {
+	opts Options
+
+	mu      sync.Mutex // Guards cfg and engines
+	cfg     *config.Config
+	engines map[string]*serviceEngine
+
+	harvestMu sync.Mutex // Harvests one schema at a time
+
+	// services lists the services questions may be asked on
+	services func() ([]postgres.ServiceEntry, error)
+}
+
+// serviceEngine is the query engine of one service and the schema it was
+// built from
+type serviceEngine struct {
+	mu     sync.Mutex // The engine answers one question at a time
+	engine *llm.QueryEngine
+	schema *config.SchemaCache
+}
+
+// New creates a server answering from the services of pg_service.conf and
+// the PG* environment, with the settings and schema cache of cfg
+func New(cfg *config.Config, opts Options) *Server {
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultTimeout
+	}
+	if opts.MaxRows <= 0 {
+		opts.MaxRows = DefaultMaxRows
+	}
+	return &Server{
+		opts:     opts,
+		cfg:      cfg,
+		engines:  make(map[string]*serviceEngine),
+		services: loadServices,
+	}
+}
+
+// loadServices lists the pg_service.conf entries, preceded by the one from
+// the PG* environment variables unless an entry of that name exists
+func loadServices() ([]postgres.ServiceEntry, error) {
+	services, err := postgres.ParsePGServiceFile()
+	if env, ok := postgres.EnvServiceEntry(); ok {
+		if !postgres.PGServiceFileExists() {
+			err = nil
+		}
+		if err == nil {
+			if _, lookupErr := postgres.GetServiceByName(services, env.Name); lookupErr != nil {
+				services = append([]postgres.ServiceEntry{env}, services...)
+			}
+		}
+	}
+	return services, err
+}
+
+// Handler returns the HTTP handler of the API
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /query", s.handleQuery)
+	mux.HandleFunc("GET /schema/{service}", s.handleSchema)
+	mux.HandleFunc("GET /history", s.handleHistory)
+	return s.logRequests(s.authenticate(mux))
+}
+
+// authenticate rejects requests without the bearer token, when one is set
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	if s.opts.Token == "" {
+		return next
+	}
+	want := []byte("Bearer " + s.opts.Token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got := []byte(r.Header.Get("Authorization"))
+		if subtle.ConstantTimeCompare(got, want) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, "missing or invalid bearer token")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// statusRecorder remembers the status code written by a handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// logRequests logs every request with its status and duration
+func (s *Server) logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		logging.Info("api request", "method", r.Method, "path", r.URL.Path, "status", rec.status,
+			"remote", r.RemoteAddr, "duration_ms", time.Since(start).Milliseconds())
+	})
+}
+
+// errorResponse is the body of every failed request
+type errorResponse struct {
+	Error  string   `json:"error"`
+	Params []string `json:"params,omitempty"` // Template parameters still needing values
+}
+
+// writeJSON writes v as the JSON body of a response
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logging.Warn("api response not written", "error", err)
+	}
+}
+
+// writeError writes an error response
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, errorResponse{Error: message})
+}
+
+// lookupService finds a service by name
+func (s *Server) lookupService(name string) (*postgres.ServiceEntry, int, error) {
+	services, err := s.services()
+	if err != nil && !postgres.PGServiceFileExists() {
+		return nil, http.StatusNotFound, fmt.Errorf("service %q not found: %w", name, err)
+	}
+	if err != nil {
+		return nil, http.StatusInternalServerError, fmt.Errorf("reading services: %w", err)
+	}
+	service, err := postgres.GetServiceByName(services, name)
+	if err != nil {
+		return nil, http.StatusNotFound, err
+	}
+	return service, http.StatusOK, nil
+}
+
+// connection returns the shared connection manager of a service, sized
+// from the settings
+func (s *Server) connection(service *postgres.ServiceEntry) *postgres.ConnectionManager {
+	s.mu.Lock()
+	opts := postgres.PoolOptions{
+		MaxConns:          s.cfg.Settings.PoolMaxConns,
+		HealthCheckPeriod: time.Duration(s.cfg.Settings.HealthCheckSec) * time.Second,
+	}
+	s.mu.Unlock()
+	return postgres.SharedConnection(*service, opts)
+}
+
+// schema returns the cached schema of a service, harvesting and caching it
+// first when there is none or refresh is set
+func (s *Server) schema(ctx context.Context, service *postgres.ServiceEntry, refresh bool) (*config.SchemaCache, error) {
+	if !refresh {
+		if schema := s.cachedSchema(service.Name); schema != nil {
+			return schema, nil
+		}
+	}
+
+	s.harvestMu.Lock()
+	defer s.harvestMu.Unlock()
+	// Another request may have harvested it while this one waited
+	if !refresh {
+		if schema := s.cachedSchema(service.Name); schema != nil {
+			return schema, nil
+		}
+	}
+
+	db, err := s.connection(service).Connect(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("database unavailable: %w", err)
+	}
+
+	s.mu.Lock()
+	workers, stats := s.cfg.Settings.HarvestWorkers, s.cfg.Settings.HarvestStats
+	s.mu.Unlock()
+
+	_, span := tracing.Start(ctx, "harvest", tracing.Service.String(service.Name))
+	start := time.Now()
+	harvester := postgres.NewSchemaHarvester(db)
+	if workers > 0 {
+		harvester.SetConcurrency(workers)
+	}
+	harvester.SetColumnStats(stats)
+	harvester.SetFilter(service.HarvestFilter())
+	harvester.SetDbtManifest(service.DbtManifest)
+	schema, err := harvester.Harvest(service.Name)
+	tracing.End(span, err)
+	if err != nil {
+		logging.Error("harvest failed", "service", service.Name, "error", err)
+		return nil, fmt.Errorf("harvesting schema: %w", err)
+	}
+	logging.Info("harvest complete", "service", service.Name, "tables", len(schema.Tables),
+		"duration_ms", time.Since(start).Milliseconds())
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cfg.CachedSchemas[service.Name] = schema
+	if err := s.cfg.Save(); err != nil {
+		logging.Warn("schema cache not saved", "service", service.Name, "error", err)
+	}
+	return schema, nil
+}
+
+// cachedSchema returns the cached schema of a service, or nil
+func (s *Server) cachedSchema(name string) *config.SchemaCache {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.cfg.IsSchemaCacheValid(name) {
+		return nil
+	}
+	return s.cfg.CachedSchemas[name]
+}
+
+// engine returns the query engine of a service, creating it when there is
+// none yet or the schema has been harvested again since
+func (s *Server) engine(service *postgres.ServiceEntry, schema *config.SchemaCache, db *sql.DB) *serviceEngine {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if se, ok := s.engines[service.Name]; ok && se.schema == schema {
+		return se
+	}
+
+	engine, embedder, warning := llm.NewServiceEngine(s.cfg, service, schema)
+	if warning != "" {
+		logging.Warn(warning, "service", service.Name)
+	}
+	engine.SetDB(db)
+	se := &serviceEngine{engine: engine, schema: schema}
+	s.engines[service.Name] = se
+
+	if embedder != nil {
+		// Questions are answered without semantic search until the index is built
+		go func() {
+			index, err := llm.BuildSemanticIndex(context.Background(), embedder, schema)
+			if err != nil {
+				logging.Warn("semantic index not built", "service", service.Name, "error", err)
+				return
+			}
+			se.mu.Lock()
+			se.engine.SetSemanticIndex(index)
+			se.mu.Unlock()
+		}()
+	}
+	return se
+}
+
+// queryRequest is the body of POST /query
+type queryRequest struct {
+	Service  string            `json:"service"`
+	Question string            `json:"question"`
+	Params   map[string]string `json:"params,omitempty"` // Values of the SQL's {{name}} placeholders
+	// What answers the question: "auto" (the default), "rules", "nn" or a provider name
+	Generator string `json:"generator,omitempty"`
+}
+
+// queryResponse is the answer to a question
+type queryResponse struct {
+	SQL         string             `json:"sql"`
+	Columns     []string           `json:"columns"`
+	ColumnTypes []string           `json:"column_types"`
+	Rows        [][]any            `json:"rows"`
+	Truncated   bool               `json:"truncated"` // More rows were returned than MaxRows
+	ExecutionMS float64            `json:"execution_ms"`
+	Repairs     []config.FailedSQL `json:"repairs,omitempty"` // Generated SQL that failed and was repaired, oldest first
+	Usage       *usageResponse     `json:"usage,omitempty"`   // LLM provider requests made answering the question
+}
+
+// usageResponse is what LLM providers used answering a question
+type usageResponse struct {
+	Requests     int     `json:"requests"`
+	InputTokens  int     `json:"input_tokens"`
+	OutputTokens int     `json:"output_tokens"`
+	Cost         float64 `json:"cost"` // Estimated, in US dollars
+}
+
+// handleQuery answers POST /query: it generates SQL for the question, runs
+// it read-only and returns the rows
+func (s *Server) handleQuery(w http.ResponseWriter, r *http.Request) {
+	var req queryRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBody)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+	req.Service = strings.TrimSpace(req.Service)
+	req.Question = strings.TrimSpace(req.Question)
+	if req.Service == "" || req.Question == "" {
+		writeError(w, http.StatusBadRequest, "service and question are required")
+		return
+	}
+
+	service, status, err := s.lookupService(req.Service)
+	if err != nil {
+		writeError(w, status, err.Error())
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), s.opts.Timeout)
+	defer cancel()
+	ctx, span := tracing.Start(ctx, "question", tracing.Service.String(service.Name), tracing.Question.String(req.Question))
+	defer span.End()
+	ctx, usage := llm.MeterUsage(ctx)
+	fail := func(status int, err error) {
+		tracing.Fail(span, err)
+		writeError(w, status, err.Error())
+	}
+
+	schema, err := s.schema(ctx, service, false)
+	if err != nil {
+		fail(http.StatusBadGateway, err)
+		return
+	}
+	db, err := s.connection(service).Connect(ctx)
+	if err != nil {
+		fail(http.StatusBadGateway, fmt.Errorf("database unavailable: %w", err))
+		return
+	}
+	se := s.engine(service, schema, db)
+
+	start := time.Now()
+	se.mu.Lock()
+	generated, err := se.engine.GenerateSQLWith(ctx, req.Generator, req.Question, "")
+	generator := se.engine.GeneratorLabel(req.Generator)
+	se.mu.Unlock()
+	if err != nil {
+		logging.Warn("sql generation failed", "service", service.Name, "question", req.Question, "error", err)
+		fail(http.StatusUnprocessableEntity, fmt.Errorf("could not generate SQL: %w", err))
+		return
+	}
+	logging.Info("sql generated", "service", service.Name, "question", req.Question, "sql", generated,
+		"duration_ms", time.Since(start).Milliseconds())
+
+	if missing := missingParams(req.Params, postgres.ParseTemplate(generated).Params); len(missing) > 0 {
+		tracing.Fail(span, errors.New("missing template parameters"))
+		writeJSON(w, http.StatusBadRequest, errorResponse{
+			Error:  "the generated SQL needs values for: " + strings.Join(missing, ", "),
+			Params: missing,
+		})
+		return
+	}
+	if class := postgres.ClassifyStatement(generated); class.IsMutating() {
+		fail(http.StatusForbidden, fmt.Errorf("blocked %s statement: the API only runs read-only SQL\nSQL: %s", class, generated))
+		return
+	}
+
+	resp, err := s.run(ctx, se, service, req.Question, generated, req.Params, db)
+	if err != nil {
+		fail(http.StatusUnprocessableEntity, err)
+		return
+	}
+	span.SetAttributes(tracing.DBRows.Int(len(resp.Rows)))
+	used := usage.Total()
+	if used.Requests > 0 {
+		resp.Usage = &usageResponse{
+			Requests:     used.Requests,
+			InputTokens:  used.InputTokens,
+			OutputTokens: used.OutputTokens,
+			Cost:         used.Cost,
+		}
+	}
+
+	s.mu.Lock()
+	s.cfg.AddQueryToHistory(config.QueryHistoryEntry{
+		Timestamp:     time.Now(),
+		NaturalQuery:  req.Question,
+		GeneratedSQL:  resp.SQL,
+		ServiceName:   service.Name,
+		RowsAffected:  len(resp.Rows),
+		ExecutionTime: resp.ExecutionMS,
+		Success:       true,
+		Generator:     generator,
+		InputTokens:   used.InputTokens,
+		OutputTokens:  used.OutputTokens,
+		Cost:          used.Cost,
+	})
+	s.mu.Unlock()
+	se.mu.Lock()
+	se.engine.AddExample(req.Question, resp.SQL)
+	se.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// missingParams returns the names that have no value in params
+func missingParams(params map[string]string, names []string) []string {
+	var missing []string
+	for _, name := range names {
+		if _, ok := params[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	return missing
+}
+
+// run executes generated SQL read-only once the harvested schema holds the
+// tables and columns it names, or the check is unsure. When the schema or PostgreSQL rejects it,
+// the engine is given the error for up to llm.MaxRepairAttempts corrected
+// versions, as in the TUI.
+func (s *Server) run(ctx context.Context, se *serviceEngine, service *postgres.ServiceEntry, question, sqlQuery string, params map[string]string, db *sql.DB) (*queryResponse, error) {
+	var failed []config.FailedSQL
+	for {
+		se.mu.Lock()
+		err := se.engine.CheckIdentifiers(sqlQuery)
+		se.mu.Unlock()
+		var resp *queryResponse
+		var unknown *llm.UnknownIdentifiersError
+		if err != nil {
+			logging.Warn("sql names unknown identifiers", "service", service.Name, "sql", sqlQuery, "error", err)
+		}
+		if err == nil || errors.As(err, &unknown) && unknown.Unsure() {
+			resp, err = s.execute(ctx, service, sqlQuery, params, db)
+		}
+		if err == nil {
+			resp.Repairs = failed
+			return resp, nil
+		}
+		cause, repairable := postgres.StatementError(err)
+		if !repairable {
+			return nil, err
+		}
+		failed = append(failed, config.FailedSQL{SQL: sqlQuery, Error: cause})
+		if len(failed) > llm.MaxRepairAttempts {
+			return nil, err
+		}
+
+		se.mu.Lock()
+		repaired, repairErr := se.engine.RepairSQL(ctx, question, "", failed)
+		se.mu.Unlock()
+		if repairErr != nil {
+			logging.Warn("sql repair failed", "service", service.Name, "attempt", len(failed), "error", repairErr)
+			return nil, err
+		}
+		// A repair never turns a question into a write
+		if class := postgres.ClassifyStatement(repaired); class.IsMutating() {
+			logging.Warn("sql repair rejected", "service", service.Name, "attempt", len(failed), "class", class.String(), "sql", repaired)
+			return nil, err
+		}
+		logging.Info("sql repaired", "service", service.Name, "attempt", len(failed), "sql", repaired)
+		sqlQuery = repaired
+	}
+}
+
+// execute runs SQL once as a read script: in a transaction that turns read
+// only after any temporary tables it starts by creating, and is rolled
+// back. It returns the rows of its last statement that returns any. Each
+// statement is bound by the service's statement timeout.
+func (s *Server) execute(ctx context.Context, service *postgres.ServiceEntry, sqlQuery string, params map[string]string, db *sql.DB) (*queryResponse, error) {
+	s.mu.Lock()
+	timeout := s.cfg.SettingsFor(service.Name).StatementTimeout()
+	s.mu.Unlock()
+	ctx = postgres.WithStatementTimeout(ctx, timeout)
+
+	var script []postgres.ScriptStatement
+	for _, stmt := range postgres.SplitStatements(sqlQuery) {
+		bound, args, err := postgres.BindTemplate(stmt, params)
+		if err != nil {
+			return nil, err
+		}
+		script = append(script, postgres.ScriptStatement{SQL: bound, Args: args})
+	}
+
+	start := time.Now()
+	execCtx, span := tracing.Start(ctx, "execute", dbSpanAttributes(service, sqlQuery)...)
+	results, err := postgres.RunScript(execCtx, db, script, false, s.opts.MaxRows)
+	tracing.End(span, err)
+	attrs := []any{"service", service.Name, "sql", sqlQuery, "source", "api", "duration_ms", time.Since(start).Milliseconds()}
+	if err != nil {
+		if ctx.Err() != nil {
+			logging.Info("sql cancelled", attrs...)
+		} else {
+			logging.Error("sql failed", append(attrs, "error", err)...)
+		}
+		return nil, err
+	}
+
+	resp := &queryResponse{SQL: sqlQuery, Columns: []string{}, ColumnTypes: []string{}, Rows: [][]any{}}
+	for _, result := range results {
+		if result.Columns == nil {
+			continue
+		}
+		resp.Columns = result.Columns
+		resp.ColumnTypes = result.ColumnTypes
+		resp.Rows = jsonRows(result.Rows)
+		resp.Truncated = result.Truncated
+	}
+	resp.ExecutionMS = time.Since(start).Seconds() * 1000
+	logging.Info("sql executed", append(attrs, "rows", len(resp.Rows))...)
+	return resp, nil
+}
+
+// jsonRows converts scanned values to ones JSON can encode: bytes become
+// text and non-finite floats their PostgreSQL spelling
+func jsonRows(rows [][]any) [][]any {
+	for _, row := range rows {
+		for i, v := range row {
+			switch v := v.(type) {
+			case []byte:
+				row[i] = string(v)
+			case float64:
+				if math.IsNaN(v) || math.IsInf(v, 0) {
+					row[i] = strconv.FormatFloat(v, 'g', -1, 64)
+				}
+			}
+		}
+	}
+	return rows
+}
+
+// dbSpanAttributes describes a statement on a service's database for a span
+func dbSpanAttributes(service *postgres.ServiceEntry, sqlText string) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{
+		tracing.DBSystem.String("postgresql"),
+		tracing.DBQueryText.String(sqlText),
+		tracing.DBNamespace.String(service.DBName),
+		tracing.ServerAddress.String(service.Host),
+	}
+	if port, err := strconv.Atoi(service.Port); err == nil {
+		attrs = append(attrs, tracing.ServerPort.Int(port))
+	}
+	return attrs
+}
+
+// handleSchema answers GET /schema/{service} with the cached schema,
+// harvesting it first when there is none or ?refresh=true is given
+func (s *Server) handleSchema(w http.ResponseWriter, r *http.Request) {
+	service, status, err := s.lookupService(r.PathValue("service"))
+	if err != nil {
+		writeError(w, status, err.Error())
+		return
+	}
+	refresh, _ := strconv.ParseBool(r.URL.Query().Get("refresh"))
+
+	ctx, cancel := context.WithTimeout(r.Context(), s.opts.Timeout)
+	defer cancel()
+	schema, err := s.schema(ctx, service, refresh)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	writeJSON(w, http.StatusOK, schema)
+}
+
+// historyResponse is the body of GET /history
+type historyResponse struct {
+	Entries []config.QueryHistoryEntry `json:"entries"`
+}
+
+// handleHistory answers GET /history with the newest questions first,
+// narrowed by the service, search and limit query parameters
+func (s *Server) handleHistory(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	limit := defaultHistoryLimit
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			writeError(w, http.StatusBadRequest, "limit must be a positive number")
+			return
+		}
+		limit = min(n, maxHistoryLimit)
+	}
+
+	store, err := config.History()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	entries, err := store.List(config.HistoryFilter{
+		ServiceName: q.Get("service"),
+		Search:      q.Get("search"),
+		Limit:       limit,
+	})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if entries == nil {
+		entries = []config.QueryHistoryEntry{}
+	}
+	writeJSON(w, http.StatusOK, historyResponse{Entries: entries})
+}
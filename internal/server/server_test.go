@@ -0,0 +1,159 @@
+package server
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kartoza/kartoza-pg-ai/internal/config"
+	"github.com/kartoza/kartoza-pg-ai/internal/postgres"
+)
+
+// newTestServer creates a server over one service, "prod", that is never
+// connected to
+func newTestServer(t *testing.T, opts Options) *Server {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	t.Cleanup(config.CloseHistory)
+
+	s := New(config.DefaultConfig(), opts)
+	s.services = func() ([]postgres.ServiceEntry, error) {
+		return []postgres.ServiceEntry{{Name: "prod", Host: "db.invalid", Port: "5432", DBName: "gis"}}, nil
+	}
+	return s
+}
+
+// do sends a request to the server's handler
+func do(s *Server, method, target, body string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, req)
+	return rec
+}
+
+func TestQueryValidation(t *testing.T) {
+	s := newTestServer(t, Options{})
+
+	tests := []struct {
+		name   string
+		method string
+		body   string
+		status int
+	}{
+		{"wrong method", http.MethodGet, "", http.StatusMethodNotAllowed},
+		{"invalid json", http.MethodPost, "{", http.StatusBadRequest},
+		{"missing question", http.MethodPost, `{"service":"prod"}`, http.StatusBadRequest},
+		{"missing service", http.MethodPost, `{"question":"how many roads?"}`, http.StatusBadRequest},
+		{"unknown service", http.MethodPost, `{"service":"staging","question":"how many roads?"}`, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		rec := do(s, tt.method, "/query", tt.body, nil)
+		if rec.Code != tt.status {
+			t.Errorf("%s: status = %d, want %d (%s)", tt.name, rec.Code, tt.status, rec.Body)
+			continue
+		}
+		if tt.status == http.StatusMethodNotAllowed {
+			continue
+		}
+		var resp errorResponse
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil || resp.Error == "" {
+			t.Errorf("%s: error body = %+v, %v", tt.name, resp, err)
+		}
+	}
+
+	if rec := do(s, http.MethodGet, "/schema/staging", "", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("schema of unknown service: status = %d", rec.Code)
+	}
+}
+
+func TestAuthentication(t *testing.T) {
+	s := newTestServer(t, Options{Token: "secret"})
+
+	if rec := do(s, http.MethodGet, "/history", "", nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("without token: status = %d", rec.Code)
+	}
+	wrong := http.Header{"Authorization": {"Bearer guess"}}
+	if rec := do(s, http.MethodGet, "/history", "", wrong); rec.Code != http.StatusUnauthorized {
+		t.Errorf("wrong token: status = %d", rec.Code)
+	}
+	right := http.Header{"Authorization": {"Bearer secret"}}
+	if rec := do(s, http.MethodGet, "/history", "", right); rec.Code != http.StatusOK {
+		t.Errorf("right token: status = %d (%s)", rec.Code, rec.Body)
+	}
+}
+
+func TestHistory(t *testing.T) {
+	s := newTestServer(t, Options{})
+	now := time.Now()
+	for i, q := range []string{"how many roads?", "list the rivers", "how many buildings?"} {
+		service := "prod"
+		if i == 1 {
+			service = "staging"
+		}
+		s.cfg.AddQueryToHistory(config.QueryHistoryEntry{
+			Timestamp:    now.Add(time.Duration(i) * time.Minute),
+			NaturalQuery: q,
+			GeneratedSQL: "SELECT 1",
+			ServiceName:  service,
+			Success:      true,
+		})
+	}
+
+	tests := []struct {
+		target string
+		want   []string
+	}{
+		{"/history", []string{"how many buildings?", "list the rivers", "how many roads?"}},
+		{"/history?service=prod", []string{"how many buildings?", "how many roads?"}},
+		{"/history?search=many&limit=1", []string{"how many buildings?"}},
+	}
+	for _, tt := range tests {
+		rec := do(s, http.MethodGet, tt.target, "", nil)
+		if rec.Code != http.StatusOK {
+			t.Errorf("%s: status = %d (%s)", tt.target, rec.Code, rec.Body)
+			continue
+		}
+		var resp historyResponse
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("%s: decode: %v", tt.target, err)
+		}
+		var got []string
+		for _, e := range resp.Entries {
+			got = append(got, e.NaturalQuery)
+		}
+		if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("%s = %q, want %q", tt.target, got, tt.want)
+		}
+	}
+
+	if rec := do(s, http.MethodGet, "/history?limit=none", "", nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid limit: status = %d", rec.Code)
+	}
+}
+
+func TestMissingParams(t *testing.T) {
+	names := postgres.ParseTemplate("SELECT * FROM roads WHERE region = {{region}} AND kind = {{kind}}").Params
+	if got := missingParams(map[string]string{"kind": "primary"}, names); len(got) != 1 || got[0] != "region" {
+		t.Errorf("missingParams = %q, want [region]", got)
+	}
+	if got := missingParams(map[string]string{"kind": "", "region": "north"}, names); len(got) != 0 {
+		t.Errorf("missingParams with every value = %q", got)
+	}
+}
+
+func TestJSONRows(t *testing.T) {
+	rows := jsonRows([][]any{{[]byte("12.5"), math.Inf(1), int64(3), nil}})
+	if _, err := json.Marshal(rows); err != nil {
+		t.Fatalf("rows not encodable: %v", err)
+	}
+	if rows[0][0] != "12.5" || rows[0][1] != "+Inf" || rows[0][2] != int64(3) {
+		t.Errorf("jsonRows = %#v", rows[0])
+	}
+}
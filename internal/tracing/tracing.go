@@ -0,0 +1,115 @@
+// Package tracing exports OpenTelemetry spans of the query pipeline to an
+// OTLP endpoint. Without one, spans are created by the no-op provider and
+// cost next to nothing.
+package tracing
+
+import (
+	"context"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ServiceName identifies the application in exported spans
+const ServiceName = "kartoza-pg-ai"
+
+// instrumentationName names the tracer of the application's spans
+const instrumentationName = "github.com/kartoza/kartoza-pg-ai"
+
+// Environment variables the OTLP exporter reads its endpoint from
+var endpointEnv = []string{"OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "OTEL_EXPORTER_OTLP_ENDPOINT"}
+
+// Endpoint returns where spans are exported: the configured endpoint, else
+// the one set in the standard OTLP environment variables, else "" when
+// tracing is off
+func Endpoint(configured string) string {
+	if configured != "" {
+		return configured
+	}
+	for _, name := range endpointEnv {
+		if v := os.Getenv(name); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// Setup exports spans over OTLP/HTTP to endpoint, a URL such as
+// http://localhost:4318, or to the endpoint of the OTLP environment
+// variables when it is empty. Other OTEL_EXPORTER_OTLP_* variables, e.g.
+// headers, apply as usual. The returned function flushes pending spans and
+// must be called before exiting. With no endpoint at all, Setup does
+// nothing.
+func Setup(ctx context.Context, endpoint, version string) (func(context.Context) error, error) {
+	if Endpoint(endpoint) == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	var opts []otlptracehttp.Option
+	if endpoint != "" {
+		opts = append(opts, otlptracehttp.WithEndpointURL(endpoint))
+	}
+	exporter, err := otlptracehttp.New(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(
+		attribute.String("service.name", ServiceName),
+		attribute.String("service.version", version),
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// Start starts a span as a child of any span in ctx
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End records err, if any, on span and ends it
+func End(span trace.Span, err error) {
+	Fail(span, err)
+	span.End()
+}
+
+// Fail records err, if any, on span and marks it failed
+func Fail(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// Attribute keys of the pipeline spans, following the OpenTelemetry
+// semantic conventions where one exists
+const (
+	DBSystem      = attribute.Key("db.system.name")
+	DBNamespace   = attribute.Key("db.namespace")
+	DBQueryText   = attribute.Key("db.query.text")
+	DBRows        = attribute.Key("db.response.returned_rows")
+	ServerAddress = attribute.Key("server.address")
+	ServerPort    = attribute.Key("server.port")
+	GenAISystem   = attribute.Key("gen_ai.system")
+
+	Service   = attribute.Key("pgai.service")    // pg_service.conf entry queried
+	Question  = attribute.Key("pgai.question")   // Natural language question
+	SQLSource = attribute.Key("pgai.sql.source") // What produced the SQL: provider, neural_network or rules
+	Generator = attribute.Key("pgai.generator")  // Generator chosen for the question, e.g. auto or ollama
+	Attempt   = attribute.Key("pgai.repair.attempt")
+)
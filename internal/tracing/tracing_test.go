@@ -0,0 +1,71 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestEndpoint(t *testing.T) {
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "")
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+	if got := Endpoint(""); got != "" {
+		t.Errorf("Endpoint with nothing set = %q", got)
+	}
+
+	shutdown, err := Setup(context.Background(), "", "test")
+	if err != nil {
+		t.Fatalf("Setup without endpoint: %v", err)
+	}
+	if err := shutdown(context.Background()); err != nil {
+		t.Errorf("shutdown: %v", err)
+	}
+
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "http://collector:4318")
+	if got := Endpoint(""); got != "http://collector:4318" {
+		t.Errorf("Endpoint from environment = %q", got)
+	}
+	if got := Endpoint("http://localhost:4318"); got != "http://localhost:4318" {
+		t.Errorf("configured Endpoint = %q", got)
+	}
+}
+
+func TestSpans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	defer otel.SetTracerProvider(previous)
+
+	ctx, question := Start(context.Background(), "question", Question.String("how many roads?"))
+	_, explain := Start(ctx, "explain", DBQueryText.String("SELECT count(*) FROM roads"))
+	End(explain, nil)
+	_, execute := Start(ctx, "execute")
+	End(execute, errors.New(`relation "roads" does not exist`))
+	question.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 3 {
+		t.Fatalf("recorded %d spans, want 3", len(spans))
+	}
+	root := spans[2]
+	for _, s := range spans[:2] {
+		if s.Parent().SpanID() != root.SpanContext().SpanID() {
+			t.Errorf("%s is not a child of the question span", s.Name())
+		}
+	}
+	if spans[0].Status().Code != codes.Unset {
+		t.Errorf("explain status = %v, want unset", spans[0].Status().Code)
+	}
+	if status := spans[1].Status(); status.Code != codes.Error || status.Description != `relation "roads" does not exist` {
+		t.Errorf("execute status = %+v", status)
+	}
+	if len(spans[1].Events()) != 1 {
+		t.Errorf("execute recorded %d events, want the error", len(spans[1].Events()))
+	}
+}
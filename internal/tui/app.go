@@ -2,24 +2,20 @@ package tui
 
 import (
 	"fmt"
-	"os"
 	"time"
 
 	"github.com/charmbracelet/bubbles/spinner"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/kartoza/kartoza-pg-ai/internal/config"
+	"github.com/kartoza/kartoza-pg-ai/internal/logging"
 	"github.com/kartoza/kartoza-pg-ai/internal/postgres"
+	"github.com/mattn/go-runewidth"
 )
 
-// debugLog writes debug info to a file for troubleshooting
+// debugLog writes troubleshooting detail to the application log
 func debugLog(msg string) {
-	f, err := os.OpenFile("/tmp/kartoza-pg-ai-debug.log", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	if err != nil {
-		return
-	}
-	defer f.Close()
-	f.WriteString(time.Now().Format("15:04:05") + " " + msg + "\n")
+	logging.Debug(msg)
 }
 
 func boolStr(b bool) string {
@@ -40,6 +36,14 @@ const (
 	ScreenSettings
 	ScreenHarvest
 	ScreenServiceEditor
+	ScreenTraining
+	ScreenImport
+	ScreenFederation
+	ScreenNotifications
+	ScreenStatus
+	ScreenLogs
+	ScreenUsage
+	ScreenModels
 )
 
 // AppModel is the main application model
@@ -54,6 +58,14 @@ type AppModel struct {
 	history        *HistoryModel
 	settings       *SettingsModel
 	serviceEditor  *ServiceEditorModel
+	training       *TrainingModel
+	importer       *ImportModel
+	federation     *FederationModel
+	notifications  *NotificationsModel
+	status         *StatusModel
+	logs           *LogsModel
+	usage          *UsageModel
+	models         *ModelsModel
 	spinner        spinner.Model
 	loading        bool
 	loadingMessage string
@@ -64,6 +76,7 @@ type AppModel struct {
 	activeSchema   *config.SchemaCache
 	services       []postgres.ServiceEntry
 	pendingScreen  Screen // Screen to navigate to after connection
+	pendingResume  bool   // Restore the saved conversation once the query screen opens
 }
 
 // blinkTickMsg for status bar blinking
@@ -77,14 +90,13 @@ type goToHistoryMsg struct{}
 
 // NewAppModel creates a new application model
 func NewAppModel() *AppModel {
-	s := spinner.New()
-	s.Spinner = spinner.Dot
-	s.Style = lipgloss.NewStyle().Foreground(ColorOrange)
-
 	cfg, err := config.Load()
 	if err != nil {
-		debugLog("NewAppModel: error loading config: " + err.Error())
+		logging.Error("config load failed", "error", err)
 	} else {
+		logging.SetLevel(logging.ParseLevel(cfg.Settings.LogLevel))
+		ApplyTheme(cfg)
+		SetGeometryStyle(cfg.Settings)
 		debugLog(fmt.Sprintf("NewAppModel: config loaded, %d cached schemas", len(cfg.CachedSchemas)))
 		for k, v := range cfg.CachedSchemas {
 			if v != nil {
@@ -93,6 +105,11 @@ func NewAppModel() *AppModel {
 		}
 	}
 
+	// Created once the theme is applied, to take its colour
+	s := spinner.New()
+	s.Spinner = spinner.Dot
+	s.Style = lipgloss.NewStyle().Foreground(ColorOrange)
+
 	return &AppModel{
 		screen:   ScreenMenu,
 		menu:     NewMenuModel(),
@@ -117,9 +134,14 @@ func (m *AppModel) startBlinkTicker() tea.Cmd {
 	})
 }
 
+// loadInitialState connects to the last used service when the Connect
+// Last Service setting asks for it; otherwise the menu is shown
 func (m *AppModel) loadInitialState() tea.Cmd {
-	// Don't auto-connect - stay on the menu and let user choose
-	return nil
+	if m.cfg == nil || !m.cfg.Settings.ConnectLastService || m.cfg.ActiveService == "" {
+		return nil
+	}
+	m.screen = ScreenDatabase
+	return tea.Batch(m.database.Init(), m.database.quickConnect(m.cfg.ActiveService))
 }
 
 // Update handles all messages for the application
@@ -168,6 +190,26 @@ func (m *AppModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.database.height = m.height
 			return m, m.database.Init()
 
+		case MenuResume:
+			if m.activeService != nil && m.activeSchema != nil {
+				m.screen = ScreenQuery
+				if m.query == nil {
+					m.query = NewQueryModel(m.activeService, m.activeSchema)
+				}
+				m.query.width = m.width
+				m.query.height = m.height
+				m.pendingResume = true
+				m.applyPendingResume()
+				return m, m.query.Init()
+			}
+			// No service connected - pick one first, then resume its conversation
+			m.pendingResume = true
+			m.screen = ScreenDatabase
+			m.database = NewDatabaseModel()
+			m.database.width = m.width
+			m.database.height = m.height
+			return m, m.database.Init()
+
 		case MenuDatabases:
 			m.screen = ScreenDatabase
 			m.database = NewDatabaseModel()
@@ -191,6 +233,54 @@ func (m *AppModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.database.height = m.height
 			return m, m.database.Init()
 
+		case MenuImport:
+			if m.activeService != nil {
+				return m, m.openImport()
+			}
+			// No active service - go to database selection first, then import
+			m.pendingScreen = ScreenImport
+			m.screen = ScreenDatabase
+			m.database = NewDatabaseModel()
+			m.database.width = m.width
+			m.database.height = m.height
+			return m, m.database.Init()
+
+		case MenuFederation:
+			if m.activeService != nil {
+				return m, m.openFederation()
+			}
+			// No active service - go to database selection first, then link
+			m.pendingScreen = ScreenFederation
+			m.screen = ScreenDatabase
+			m.database = NewDatabaseModel()
+			m.database.width = m.width
+			m.database.height = m.height
+			return m, m.database.Init()
+
+		case MenuNotifications:
+			if m.activeService != nil {
+				return m, m.openNotifications()
+			}
+			// No active service - go to database selection first, then listen
+			m.pendingScreen = ScreenNotifications
+			m.screen = ScreenDatabase
+			m.database = NewDatabaseModel()
+			m.database.width = m.width
+			m.database.height = m.height
+			return m, m.database.Init()
+
+		case MenuStatus:
+			if m.activeService != nil {
+				return m, m.openStatus()
+			}
+			// No active service - go to database selection first, then the status
+			m.pendingScreen = ScreenStatus
+			m.screen = ScreenDatabase
+			m.database = NewDatabaseModel()
+			m.database.width = m.width
+			m.database.height = m.height
+			return m, m.database.Init()
+
 		case MenuSettings:
 			m.screen = ScreenSettings
 			m.settings = NewSettingsModel(m.cfg)
@@ -206,13 +296,15 @@ func (m *AppModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.activeService = &msg.service
 		GlobalAppState.IsConnected = true
 		GlobalAppState.ActiveService = msg.service.Name
+		GlobalAppState.Production = msg.service.IsProduction()
+		GlobalAppState.Connection = sharedConnection(&msg.service, m.cfg)
 		GlobalAppState.Status = "Connected"
 
 		debugLog("serviceSelectedMsg: selected service " + msg.service.Name)
 
 		// Save to config
 		if m.cfg != nil {
-			m.cfg.ActiveService = msg.service.Name
+			m.cfg.MarkServiceUsed(msg.service.Name)
 			m.cfg.Save()
 		}
 
@@ -232,13 +324,8 @@ func (m *AppModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			GlobalAppState.HasPostGIS = m.activeSchema.HasPostGIS
 
 			// Check if we have a pending screen to navigate to
-			if m.pendingScreen == ScreenHistory {
-				m.pendingScreen = ScreenMenu // Reset pending
-				m.screen = ScreenHistory
-				m.history = NewHistoryModel(m.activeService.Name)
-				m.history.width = m.width
-				m.history.height = m.height
-				return m, m.history.Init()
+			if cmd, ok := m.openPendingScreen(); ok {
+				return m, cmd
 			}
 
 			// Go to query screen
@@ -246,6 +333,7 @@ func (m *AppModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.query = NewQueryModel(m.activeService, m.activeSchema)
 			m.query.width = m.width
 			m.query.height = m.height
+			m.applyPendingResume()
 			return m, m.query.Init()
 		}
 
@@ -285,13 +373,8 @@ func (m *AppModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 
 			// Check if we have a pending screen to navigate to
-			if m.pendingScreen == ScreenHistory {
-				m.pendingScreen = ScreenMenu // Reset pending
-				m.screen = ScreenHistory
-				m.history = NewHistoryModel(m.activeService.Name)
-				m.history.width = m.width
-				m.history.height = m.height
-				return m, m.history.Init()
+			if cmd, ok := m.openPendingScreen(); ok {
+				return m, cmd
 			}
 
 			// Go to query screen
@@ -299,6 +382,7 @@ func (m *AppModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.query = NewQueryModel(m.activeService, m.activeSchema)
 			m.query.width = m.width
 			m.query.height = m.height
+			m.applyPendingResume()
 			return m, m.query.Init()
 		} else if msg.err != nil {
 			// Handle error - go back to database selection
@@ -314,18 +398,26 @@ func (m *AppModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		if m.activeService != nil && m.activeSchema != nil {
 			m.screen = ScreenQuery
 			m.query = NewQueryModel(m.activeService, m.activeSchema)
-			// Set the query text in the editor
-			m.query.SetInitialQuery(msg.query)
 			m.query.width = m.width
 			m.query.height = m.height
+			if msg.sql != "" {
+				// Load the saved template SQL for editing and running
+				editCmd := m.query.startSQLEdit(msg.query, msg.sql)
+				return m, tea.Batch(editCmd, m.query.Init())
+			}
+			// Set the query text in the editor
+			m.query.SetInitialQuery(msg.query)
 			return m, m.query.Init()
 		}
 
 	case harvestCancelledMsg:
 		// User cancelled harvesting - go back to database selection
 		m.activeService = nil
+		m.pendingResume = false
 		GlobalAppState.IsConnected = false
 		GlobalAppState.ActiveService = ""
+		GlobalAppState.Production = false
+		GlobalAppState.Connection = nil
 		GlobalAppState.Status = "Ready"
 		m.screen = ScreenDatabase
 		m.database = NewDatabaseModel()
@@ -333,6 +425,64 @@ func (m *AppModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.database.height = m.height
 		return m, m.database.Init()
 
+	case goToTrainingMsg:
+		m.screen = ScreenTraining
+		m.training = NewTrainingModel()
+		m.training.width = m.width
+		m.training.height = m.height
+		return m, m.training.Init()
+
+	case importFinishedMsg:
+		// Harvest again so the new table can be queried
+		if m.activeService != nil {
+			if m.cfg != nil {
+				delete(m.cfg.CachedSchemas, m.activeService.Name)
+				m.cfg.Save()
+			}
+			m.screen = ScreenHarvest
+			m.harvest = NewHarvestModel(*m.activeService)
+			m.harvest.width = m.width
+			m.harvest.height = m.height
+			return m, m.harvest.Init()
+		}
+		m.screen = ScreenMenu
+		return m, nil
+
+	case goToLogsMsg:
+		m.screen = ScreenLogs
+		m.logs = NewLogsModel()
+		m.logs.width = m.width
+		m.logs.height = m.height
+		return m, m.logs.Init()
+
+	case goToUsageMsg:
+		m.screen = ScreenUsage
+		m.usage = NewUsageModel()
+		m.usage.width = m.width
+		m.usage.height = m.height
+		return m, m.usage.Init()
+
+	case goToModelsMsg:
+		m.screen = ScreenModels
+		m.models = NewModelsModel(m.cfg)
+		m.models.width = m.width
+		m.models.height = m.height
+		return m, m.models.Init()
+
+	case goToSettingsMsg:
+		m.screen = ScreenSettings
+		m.settings = NewSettingsModel(m.cfg)
+		m.settings.width = m.width
+		m.settings.height = m.height
+		return m, m.settings.Init()
+
+	case draftSaveMsg:
+		// Saved whichever screen is showing, as typing pauses on leaving the query screen
+		if m.query != nil {
+			m.query.saveDraft(msg)
+		}
+		return m, nil
+
 	case goToMenuMsg:
 		// Return to menu screen
 		m.screen = ScreenMenu
@@ -426,6 +576,62 @@ func (m *AppModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.serviceEditor, cmd = m.serviceEditor.Update(msg)
 			cmds = append(cmds, cmd)
 		}
+
+	case ScreenTraining:
+		if m.training != nil {
+			var cmd tea.Cmd
+			m.training, cmd = m.training.Update(msg)
+			cmds = append(cmds, cmd)
+		}
+
+	case ScreenImport:
+		if m.importer != nil {
+			var cmd tea.Cmd
+			m.importer, cmd = m.importer.Update(msg)
+			cmds = append(cmds, cmd)
+		}
+
+	case ScreenFederation:
+		if m.federation != nil {
+			var cmd tea.Cmd
+			m.federation, cmd = m.federation.Update(msg)
+			cmds = append(cmds, cmd)
+		}
+
+	case ScreenNotifications:
+		if m.notifications != nil {
+			var cmd tea.Cmd
+			m.notifications, cmd = m.notifications.Update(msg)
+			cmds = append(cmds, cmd)
+		}
+
+	case ScreenStatus:
+		if m.status != nil {
+			var cmd tea.Cmd
+			m.status, cmd = m.status.Update(msg)
+			cmds = append(cmds, cmd)
+		}
+
+	case ScreenLogs:
+		if m.logs != nil {
+			var cmd tea.Cmd
+			m.logs, cmd = m.logs.Update(msg)
+			cmds = append(cmds, cmd)
+		}
+
+	case ScreenUsage:
+		if m.usage != nil {
+			var cmd tea.Cmd
+			m.usage, cmd = m.usage.Update(msg)
+			cmds = append(cmds, cmd)
+		}
+
+	case ScreenModels:
+		if m.models != nil {
+			var cmd tea.Cmd
+			m.models, cmd = m.models.Update(msg)
+			cmds = append(cmds, cmd)
+		}
 	}
 
 	return m, tea.Batch(cmds...)
@@ -440,13 +646,29 @@ func (m *AppModel) harvestSchema(service postgres.ServiceEntry) tea.Cmd {
 		defer db.Close()
 
 		harvester := postgres.NewSchemaHarvester(db)
+		harvester.SetFilter(service.HarvestFilter())
+		harvester.SetDbtManifest(service.DbtManifest)
+		if m.cfg != nil {
+			if m.cfg.Settings.HarvestWorkers > 0 {
+				harvester.SetConcurrency(m.cfg.Settings.HarvestWorkers)
+			}
+			harvester.SetColumnStats(m.cfg.Settings.HarvestStats)
+		}
 		schema, err := harvester.Harvest(service.Name)
 		return schemaLoadedMsg{schema: schema, err: err}
 	}
 }
 
-// View renders the application
+// View renders the application, as plain text in plain output mode
 func (m *AppModel) View() string {
+	if plainOutput {
+		return plainText(m.view())
+	}
+	return m.view()
+}
+
+// view renders the current screen
+func (m *AppModel) view() string {
 	if m.width == 0 || m.height == 0 {
 		return ""
 	}
@@ -485,6 +707,46 @@ func (m *AppModel) View() string {
 			return m.serviceEditor.View()
 		}
 		return m.database.View()
+	case ScreenTraining:
+		if m.training != nil {
+			return m.training.View()
+		}
+		return m.menu.View()
+	case ScreenImport:
+		if m.importer != nil {
+			return m.importer.View()
+		}
+		return m.menu.View()
+	case ScreenFederation:
+		if m.federation != nil {
+			return m.federation.View()
+		}
+		return m.menu.View()
+	case ScreenNotifications:
+		if m.notifications != nil {
+			return m.notifications.View()
+		}
+		return m.menu.View()
+	case ScreenStatus:
+		if m.status != nil {
+			return m.status.View()
+		}
+		return m.menu.View()
+	case ScreenLogs:
+		if m.logs != nil {
+			return m.logs.View()
+		}
+		return m.menu.View()
+	case ScreenUsage:
+		if m.usage != nil {
+			return m.usage.View()
+		}
+		return m.menu.View()
+	case ScreenModels:
+		if m.models != nil {
+			return m.models.View()
+		}
+		return m.menu.View()
 	default:
 		return m.menu.View()
 	}
@@ -506,17 +768,99 @@ func (m *AppModel) renderLoading() string {
 }
 
 
+// truncate cuts s to maxLen terminal columns, ending it with "..." when cut
 func truncate(s string, maxLen int) string {
-	if len(s) > maxLen {
-		return s[:maxLen-3] + "..."
-	}
-	return s
+	return runewidth.Truncate(s, maxLen, "...")
 }
 
 // RunApp runs the main TUI application
 func RunApp() error {
+	if dir, err := config.LogDir(); err == nil {
+		if err := logging.Open(dir); err == nil {
+			defer logging.Close()
+		}
+	}
 	app := NewAppModel()
+	logging.Info("application started")
+	defer postgres.CloseTunnels()
+	defer postgres.CloseConnections()
+	defer config.CloseHistory()
+	// Query the terminal's graphics while nothing else reads its replies
+	terminalGraphics()
 	p := tea.NewProgram(app, tea.WithAltScreen(), tea.WithMouseCellMotion())
 	_, err := p.Run()
 	return err
 }
+
+// openPendingScreen opens the screen chosen from the menu before a service
+// was connected, reporting whether there was one
+func (m *AppModel) openPendingScreen() (tea.Cmd, bool) {
+	pending := m.pendingScreen
+	m.pendingScreen = ScreenMenu // Reset pending
+	switch pending {
+	case ScreenHistory:
+		m.screen = ScreenHistory
+		m.history = NewHistoryModel(m.activeService.Name)
+		m.history.width = m.width
+		m.history.height = m.height
+		return m.history.Init(), true
+	case ScreenImport:
+		return m.openImport(), true
+	case ScreenFederation:
+		return m.openFederation(), true
+	case ScreenNotifications:
+		return m.openNotifications(), true
+	case ScreenStatus:
+		return m.openStatus(), true
+	}
+	return nil, false
+}
+
+// openStatus shows the health dashboard for the active service
+func (m *AppModel) openStatus() tea.Cmd {
+	m.screen = ScreenStatus
+	m.status = NewStatusModel(m.activeService, m.cfg)
+	m.status.width = m.width
+	m.status.height = m.height
+	return m.status.Init()
+}
+
+// openImport shows the import wizard for the active service
+func (m *AppModel) openImport() tea.Cmd {
+	m.screen = ScreenImport
+	m.importer = NewImportModel(m.activeService, m.activeSchema, m.cfg)
+	m.importer.width = m.width
+	m.importer.height = m.height
+	return m.importer.Init()
+}
+
+// openFederation shows the wizard linking another service's tables into
+// the active service
+func (m *AppModel) openFederation() tea.Cmd {
+	m.screen = ScreenFederation
+	m.federation = NewFederationModel(m.activeService, m.cfg)
+	m.federation.width = m.width
+	m.federation.height = m.height
+	return m.federation.Init()
+}
+
+// openNotifications shows the notification viewer for the active service
+func (m *AppModel) openNotifications() tea.Cmd {
+	m.screen = ScreenNotifications
+	m.notifications = NewNotificationsModel(m.activeService, m.cfg)
+	m.notifications.width = m.width
+	m.notifications.height = m.height
+	return m.notifications.Init()
+}
+
+// applyPendingResume restores the saved conversation into the query screen
+// if "Resume Last Conversation" was chosen
+func (m *AppModel) applyPendingResume() {
+	if !m.pendingResume || m.query == nil {
+		return
+	}
+	m.pendingResume = false
+	if !m.query.ResumeConversation() && m.activeService != nil {
+		m.query.statusMsg = "✗ No saved conversation for " + m.activeService.Name
+	}
+}
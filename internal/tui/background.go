@@ -0,0 +1,75 @@
+package tui
+
+import (
+	"fmt"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/kartoza/kartoza-pg-ai/internal/llm"
+)
+
+// backgroundQuery is a question answered in the background: the editor was
+// cleared when it was asked, so the next question can be typed meanwhile
+type backgroundQuery struct {
+	query   string
+	usage   llm.Usage // LLM use of generating the SQL it runs, when edited first
+	started time.Time
+}
+
+// runInBackground records query as answered in the background and clears
+// the editor for the next question
+func (m *QueryModel) runInBackground(query string, usage llm.Usage) tea.Cmd {
+	m.background = &backgroundQuery{query: query, usage: usage, started: time.Now()}
+	m.sqlEdit = nil
+	return m.clearEditor()
+}
+
+// finishBackground ends the background question entry answered, with a
+// toast saying how it went and, while the next question is being typed, a
+// badge on the entry until the conversation is looked at again
+func (m *QueryModel) finishBackground(entry *ConversationEntry) {
+	bg := m.background
+	m.background = nil
+	took := time.Since(bg.started).Round(100 * time.Millisecond)
+	question := truncate(bg.query, 50)
+	switch {
+	case entry.Error != "":
+		m.statusMsg = fmt.Sprintf("✗ Failed after %s: %s", took, question)
+	case entry.Results != nil && entry.Results.Mutating:
+		m.statusMsg = fmt.Sprintf("✓ Done in %s: %s", took, question)
+	case entry.Results != nil:
+		m.statusMsg = fmt.Sprintf("✓ Answered in %s: %s (%d rows)", took, question, entry.Results.RowCount)
+	}
+	entry.Unseen = m.focusEditor
+}
+
+// backgroundQuestion returns the question an entry is being added for: the
+// one answered in the background, or the one still in the editor
+func (m *QueryModel) backgroundQuestion() string {
+	if m.background != nil {
+		return m.background.query
+	}
+	return m.getEditorText()
+}
+
+// markSeen takes the badges off entries answered in the background
+func (m *QueryModel) markSeen() {
+	for i := range m.history {
+		m.history[i].Unseen = false
+	}
+}
+
+// renderRunning renders the line below the conversation while a question
+// is answered
+func (m *QueryModel) renderRunning() string {
+	text := m.spinner.View() + " Generating and executing query..."
+	if bg := m.background; bg != nil {
+		text = fmt.Sprintf("%s Answering %q (%s) • keep typing • ctrl+c: cancel",
+			m.spinner.View(), truncate(bg.query, 40), time.Since(bg.started).Round(time.Second))
+	}
+	return lipgloss.NewStyle().
+		Width(m.width - 10).
+		Align(lipgloss.Center).
+		Render(text)
+}
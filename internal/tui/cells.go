@@ -0,0 +1,175 @@
+package tui
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/kartoza/kartoza-pg-ai/internal/config"
+	"github.com/kartoza/kartoza-pg-ai/internal/postgres"
+	"github.com/mattn/go-runewidth"
+)
+
+// dateStyle is how dates and times in results are shown, from the Date
+// Style setting (see ApplyTheme)
+var dateStyle string
+
+// dateStyles are the date styles the settings cycle through, "" being ISO
+var dateStyles = []string{"", "us", "european"}
+
+// dateStyleNames describe each date style in the settings
+var dateStyleNames = map[string]string{
+	"":         "ISO (2024-03-31 14:05)",
+	"us":       "US (03/31/2024 2:05 PM)",
+	"european": "European (31/03/2024 14:05)",
+}
+
+// cellKind is how the values of a result column are shown, from its
+// database type
+type cellKind int
+
+const (
+	cellText      cellKind = iota
+	cellNumber             // Right-aligned
+	cellBool               // ✓ or ✗
+	cellTimestamp          // In the date style
+	cellDate               // In the date style, without the midnight time
+	cellTime               // In the date style's clock, without the date the driver gives it
+	cellInterval           // Right-aligned as PostgreSQL writes it
+)
+
+// columnKinds maps database type names to how their values are shown
+var columnKinds = map[string]cellKind{
+	"INT2": cellNumber, "INT4": cellNumber, "INT8": cellNumber, "OID": cellNumber,
+	"NUMERIC": cellNumber, "FLOAT4": cellNumber, "FLOAT8": cellNumber, "MONEY": cellNumber,
+	"BOOL":      cellBool,
+	"TIMESTAMP": cellTimestamp, "TIMESTAMPTZ": cellTimestamp,
+	"DATE": cellDate,
+	"TIME": cellTime, "TIMETZ": cellTime,
+	"INTERVAL": cellInterval,
+}
+
+// columnKind returns how the values of column i are shown; text when its
+// type is unknown
+func (r *QueryResults) columnKind(i int) cellKind {
+	if i < len(r.ColumnTypes) {
+		return columnKinds[r.ColumnTypes[i]]
+	}
+	return cellText
+}
+
+// rightAligned reports whether the values of column i are quantities,
+// aligned to the right so their digits line up
+func (r *QueryResults) rightAligned(i int) bool {
+	kind := r.columnKind(i)
+	return kind == cellNumber || kind == cellInterval
+}
+
+// isNull reports whether a cell is NULL. Results restored from a saved
+// conversation do not know, and take the text "NULL" for it.
+func (r *QueryResults) isNull(row, col int) bool {
+	if r.Nulls == nil {
+		return row < len(r.Rows) && col < len(r.Rows[row]) && r.Rows[row][col] == "NULL"
+	}
+	return row < len(r.Nulls) && col < len(r.Nulls[row]) && r.Nulls[row][col]
+}
+
+// displayValue returns a cell as tables show it: booleans as ✓ and ✗
+// (true and false in plain output) and dates and times in the date style
+func (r *QueryResults) displayValue(row, col int) string {
+	if row >= len(r.Rows) || col >= len(r.Rows[row]) {
+		return ""
+	}
+	value := r.Rows[row][col]
+	if r.isNull(row, col) {
+		return "NULL"
+	}
+	if r.humanized[col] {
+		return humanValue(value, r.columnKind(col))
+	}
+	switch r.columnKind(col) {
+	case cellBool:
+		if plainOutput {
+			return value
+		}
+		switch value {
+		case "true":
+			return "✓"
+		case "false":
+			return "✗"
+		}
+	case cellTimestamp:
+		return formatDateTime(value, timestampLayouts)
+	case cellDate:
+		return formatDateTime(value, dateLayouts)
+	case cellTime:
+		return formatDateTime(value, timeLayouts)
+	}
+	return value
+}
+
+// humanValue returns a number as a byte count, e.g. "1.2 GB", or an
+// interval as a duration, e.g. "3m40s". Values it cannot read are kept.
+func humanValue(value string, kind cellKind) string {
+	switch kind {
+	case cellNumber:
+		if n, err := strconv.ParseFloat(value, 64); err == nil {
+			return config.HumanBytes(int64(n))
+		}
+	case cellInterval:
+		if d, ok := postgres.ParseInterval(value); ok {
+			return config.HumanDuration(d)
+		}
+	}
+	return value
+}
+
+// Layouts of timestamps, dates and times in each date style
+var (
+	timestampLayouts = map[string]string{"": "2006-01-02 15:04:05", "us": "01/02/2006 3:04:05 PM", "european": "02/01/2006 15:04:05"}
+	dateLayouts      = map[string]string{"": "2006-01-02", "us": "01/02/2006", "european": "02/01/2006"}
+	timeLayouts      = map[string]string{"": "15:04:05", "us": "3:04:05 PM", "european": "15:04:05"}
+)
+
+// formatDateTime rewrites a date or time, as postgres.FormatValue writes
+// them, in the date style's layout of layouts. Values it cannot read, such
+// as infinity, are kept.
+func formatDateTime(value string, layouts map[string]string) string {
+	t, err := time.Parse("2006-01-02 15:04:05", value)
+	if err != nil {
+		return value
+	}
+	style, ok := layouts[dateStyle]
+	if !ok {
+		style = layouts[""]
+	}
+	return t.Format(style)
+}
+
+// alignCell fits a value to width terminal columns, truncated with … when
+// too wide and padded on the left when right is set
+func alignCell(s string, width int, right bool) string {
+	if !right || runewidth.StringWidth(s) >= width {
+		return fitCell(s, width)
+	}
+	return runewidth.FillLeft(strings.ReplaceAll(s, "\n", " "), width)
+}
+
+// cellStyle returns the style of a table cell on a row drawn in style:
+// NULL dimmed and in italics, so it stands apart from the text "NULL", and
+// booleans in the success and error colours
+func cellStyle(results *QueryResults, row, col int, style lipgloss.Style) lipgloss.Style {
+	if results.isNull(row, col) {
+		return style.Foreground(ColorGray).Italic(true)
+	}
+	if results.columnKind(col) == cellBool && col < len(results.Rows[row]) {
+		switch results.Rows[row][col] {
+		case "true":
+			return style.Foreground(ColorGreen)
+		case "false":
+			return style.Foreground(ColorRed)
+		}
+	}
+	return style
+}
@@ -0,0 +1,389 @@
+package tui
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+// chartKind is how a result set is charted in the conversation
+type chartKind int
+
+const (
+	chartNone chartKind = iota
+	chartBar
+	chartLine
+)
+
+// String returns the chart name shown in the conversation
+func (k chartKind) String() string {
+	switch k {
+	case chartBar:
+		return "Bar chart"
+	case chartLine:
+		return "Line chart"
+	default:
+		return "No chart"
+	}
+}
+
+// Chart layout
+const (
+	chartPNGWidth    = 480
+	chartPNGHeight   = 240
+	chartMaxBars     = 100 // Bars beyond this are left out of bar charts
+	brailleRows      = 4   // Terminal rows of a braille chart
+	chartLabelMargin = 16  // Pixels below the plot for category labels
+
+	maxPlainChartPoints = 20 // Values listed in place of a chart in plain output
+)
+
+// chartColumns picks the category and value columns to chart: the first
+// non-numeric, non-geometry column against the first numeric one. When
+// every column is numeric the first is used as the category (e.g. a year).
+func chartColumns(results *QueryResults) (labelCol, valueCol int, ok bool) {
+	if results == nil || len(results.Rows) < 2 {
+		return 0, 0, false
+	}
+	numeric := numericColumns(results)
+	isNumeric := make(map[int]bool, len(numeric))
+	for _, i := range numeric {
+		isNumeric[i] = true
+	}
+	isGeometry := make(map[int]bool, len(results.GeometryColumns))
+	for _, i := range results.GeometryColumns {
+		isGeometry[i] = true
+	}
+
+	labelCol = -1
+	for i := range results.Columns {
+		if !isNumeric[i] && !isGeometry[i] {
+			labelCol = i
+			break
+		}
+	}
+	switch {
+	case labelCol >= 0 && len(numeric) > 0:
+		return labelCol, numeric[0], true
+	case labelCol < 0 && len(numeric) > 1:
+		return numeric[0], numeric[1], true
+	}
+	return 0, 0, false
+}
+
+// chartPoints returns the category labels and numeric values to chart,
+// skipping rows whose value is NULL
+func (r *QueryResults) chartPoints() ([]string, []float64, bool) {
+	labelCol, valueCol, ok := chartColumns(r)
+	if !ok {
+		return nil, nil, false
+	}
+	var labels []string
+	var values []float64
+	for _, row := range r.Rows {
+		if valueCol >= len(row) || labelCol >= len(row) {
+			continue
+		}
+		v, err := strconv.ParseFloat(row[valueCol], 64)
+		if err != nil {
+			continue
+		}
+		labels = append(labels, row[labelCol])
+		values = append(values, v)
+	}
+	if r.chart == chartBar && len(values) > chartMaxBars {
+		labels, values = labels[:chartMaxBars], values[:chartMaxBars]
+	}
+	return labels, values, len(values) > 0
+}
+
+// renderChart draws the chosen chart into chartImage when the terminal
+// shows Kitty graphics or sixel images; braille charts are drawn at render time since
+// they depend on the screen width
+func (r *QueryResults) renderChart() {
+	r.chartImage = ""
+	if r.chart == chartNone || terminalGraphics() == graphicsNone {
+		return
+	}
+	labels, values, ok := r.chartPoints()
+	if !ok {
+		return
+	}
+	b64, err := RenderChartToPNG(labels, values, r.chart, chartPNGWidth, chartPNGHeight)
+	if err == nil {
+		r.chartImage = Base64ToTerminalGraphics(b64)
+	}
+}
+
+// cycleChart switches the selected entry's chart between none, bar and line
+func (m *QueryModel) cycleChart() {
+	if m.selectedEntry < 0 || m.selectedEntry >= len(m.history) {
+		return
+	}
+	results := m.history[m.selectedEntry].Results
+	if _, _, ok := chartColumns(results); !ok {
+		m.statusMsg = "✗ Charts need a category column and a numeric column"
+		return
+	}
+	results.chart = (results.chart + 1) % (chartLine + 1)
+	results.renderChart()
+}
+
+// valueRange returns the span of values. Bar charts widen it to include
+// zero so bars grow from a baseline; line charts use the data range.
+func valueRange(values []float64, kind chartKind) (lo, hi float64) {
+	lo, hi = values[0], values[0]
+	if kind == chartBar {
+		lo, hi = math.Min(0, lo), math.Max(0, hi)
+	}
+	for _, v := range values {
+		lo, hi = math.Min(lo, v), math.Max(hi, v)
+	}
+	if hi == lo {
+		hi = lo + 1
+	}
+	return lo, hi
+}
+
+// formatChartValue renders an axis value compactly
+func formatChartValue(v float64) string {
+	return strconv.FormatFloat(v, 'g', 6, 64)
+}
+
+// RenderChartToPNG renders a bar or line chart and returns base64-encoded PNG data
+func RenderChartToPNG(labels []string, values []float64, kind chartKind, width, height int) (string, error) {
+	if len(values) == 0 {
+		return "", fmt.Errorf("no values to chart")
+	}
+
+	r := NewGeometryRenderer(width, height)
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(r.Background), image.Point{}, draw.Src)
+
+	lo, hi := valueRange(values, kind)
+	left, right := float64(r.Padding), float64(width-r.Padding)
+	top, bottom := float64(r.Padding+12), float64(height-r.Padding-chartLabelMargin)
+	yOf := func(v float64) float64 {
+		return bottom - (v-lo)/(hi-lo)*(bottom-top)
+	}
+	shapes := newShapeLayer(width, height)
+	n := len(values)
+	switch kind {
+	case chartBar:
+		shapes.addStroke([]Point{{left, yOf(0)}, {right, yOf(0)}}, 1, false)
+		shapes.paint(img, color.NRGBA{120, 120, 120, 255})
+
+		slot := (right - left) / float64(n)
+		gap := 0.0
+		if slot >= 4 {
+			gap = math.Max(1, slot/5)
+		}
+		for i, v := range values {
+			x0 := left + float64(i)*slot
+			x1 := math.Max(left+float64(i+1)*slot-gap, x0+1)
+			shapes.addRing([]Point{{x0, yOf(0)}, {x1, yOf(0)}, {x1, yOf(v)}, {x0, yOf(v)}}, true)
+		}
+		shapes.paint(img, r.LineColor)
+	default:
+		xOf := func(i int) float64 {
+			if n == 1 {
+				return (left + right) / 2
+			}
+			return left + float64(i)*(right-left)/float64(n-1)
+		}
+		line := make([]Point, n)
+		for i, v := range values {
+			line[i] = Point{xOf(i), yOf(v)}
+		}
+		shapes.addStroke(line, r.StrokeWidth, false)
+		shapes.paint(img, r.PointColor)
+		if n <= 60 {
+			for _, p := range line {
+				shapes.addCircle(p, r.PointRadius)
+			}
+			shapes.paint(img, r.PointColor)
+		}
+	}
+
+	// Value range at the top and bottom left, first and last category below
+	textColor := color.RGBA{200, 200, 200, 255}
+	drawChartText(img, r.Padding, r.Padding+10, formatChartValue(hi), textColor)
+	drawChartText(img, r.Padding, int(bottom)-2, formatChartValue(lo), textColor)
+	if len(labels) > 0 {
+		first := truncate(labels[0], 30)
+		last := truncate(labels[len(labels)-1], 30)
+		drawChartText(img, r.Padding, height-r.Padding, first, textColor)
+		if len(labels) > 1 {
+			drawChartText(img, int(right)-7*len([]rune(last)), height-r.Padding, last, textColor)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// drawChartText writes text with its baseline at y
+func drawChartText(img *image.RGBA, x, y int, text string, c color.Color) {
+	d := &font.Drawer{
+		Dst:  img,
+		Src:  image.NewUniform(c),
+		Face: basicfont.Face7x13,
+		Dot:  fixed.P(x, y),
+	}
+	d.DrawString(text)
+}
+
+// brailleChart draws values as a chart of width x brailleRows braille
+// characters; each character holds a 2x4 grid of dots
+func brailleChart(values []float64, kind chartKind, width int) []string {
+	dotsX, dotsY := width*2, brailleRows*4
+	grid := make([][]bool, dotsY)
+	for y := range grid {
+		grid[y] = make([]bool, dotsX)
+	}
+
+	lo, hi := valueRange(values, kind)
+	yOf := func(v float64) int {
+		return dotsY - 1 - int(math.Round((v-lo)/(hi-lo)*float64(dotsY-1)))
+	}
+	fill := func(x, y0, y1 int) {
+		if y0 > y1 {
+			y0, y1 = y1, y0
+		}
+		for y := y0; y <= y1; y++ {
+			grid[y][x] = true
+		}
+	}
+
+	n := len(values)
+	prev := -1
+	for x := 0; x < dotsX; x++ {
+		switch kind {
+		case chartBar:
+			i := x * n / dotsX
+			// Leave a gap between bars that are at least three dots wide
+			if dotsX/n >= 3 && (x+1)*n/dotsX != i {
+				continue
+			}
+			fill(x, yOf(0), yOf(values[i]))
+		default:
+			// Interpolate between neighbouring values
+			pos := 0.0
+			if dotsX > 1 {
+				pos = float64(x) * float64(n-1) / float64(dotsX-1)
+			}
+			i := min(int(pos), n-1)
+			v := values[i]
+			if i+1 < n {
+				v += (values[i+1] - v) * (pos - float64(i))
+			}
+			y := yOf(v)
+			if prev < 0 {
+				prev = y
+			}
+			fill(x, prev, y)
+			prev = y
+		}
+	}
+
+	// Dot bits of a braille cell, indexed by [row][column]
+	bits := [4][2]rune{{0x01, 0x08}, {0x02, 0x10}, {0x04, 0x20}, {0x40, 0x80}}
+	lines := make([]string, brailleRows)
+	for row := range lines {
+		var b strings.Builder
+		for col := 0; col < width; col++ {
+			cell := rune(0x2800)
+			for dy := 0; dy < 4; dy++ {
+				for dx := 0; dx < 2; dx++ {
+					if grid[row*4+dy][col*2+dx] {
+						cell |= bits[dy][dx]
+					}
+				}
+			}
+			b.WriteRune(cell)
+		}
+		lines[row] = b.String()
+	}
+	return lines
+}
+
+// renderEntryChart renders the chart of an entry's results: the Kitty
+// image when one was drawn, otherwise braille with the value range
+func (m *QueryModel) renderEntryChart(results *QueryResults) []string {
+	if results.chart == chartNone {
+		return nil
+	}
+	labels, values, ok := results.chartPoints()
+	if !ok {
+		return nil
+	}
+	labelCol, valueCol, _ := chartColumns(results)
+
+	titleStyle := lipgloss.NewStyle().Foreground(ColorOrange).Bold(true)
+	hintStyle := lipgloss.NewStyle().Foreground(ColorGray).Italic(true)
+	next := "line chart"
+	if results.chart == chartLine {
+		next = "hide chart"
+	}
+	lines := []string{"  " + titleStyle.Render(fmt.Sprintf("📊 %s of %s by %s", results.chart,
+		results.Columns[valueCol], results.Columns[labelCol])) + " " + hintStyle.Render("(v: "+next+")")}
+
+	if plainOutput {
+		// Read out as values rather than drawn
+		for i, label := range labels[:min(len(labels), maxPlainChartPoints)] {
+			lines = append(lines, fmt.Sprintf("  %s: %s", truncate(label, 40), formatChartValue(values[i])))
+		}
+		if more := len(labels) - maxPlainChartPoints; more > 0 {
+			lines = append(lines, fmt.Sprintf("  ... and %d more", more))
+		}
+		return append(lines, "")
+	}
+	if results.chartImage != "" {
+		return append(lines, results.chartImage, "")
+	}
+
+	lo, hi := valueRange(values, results.chart)
+	loLabel, hiLabel := formatChartValue(lo), formatChartValue(hi)
+	axisWidth := max(len(loLabel), len(hiLabel))
+	width := max(m.width-axisWidth-14, 10)
+	if results.chart == chartBar {
+		// Bars two characters wide, where the screen allows
+		width = min(width, max(2*len(values), 10))
+	}
+
+	chartStyle := lipgloss.NewStyle().Foreground(ColorOrange)
+	if results.chart == chartLine {
+		chartStyle = lipgloss.NewStyle().Foreground(ColorCyan)
+	}
+	axisStyle := lipgloss.NewStyle().Foreground(ColorGray)
+	for i, line := range brailleChart(values, results.chart, width) {
+		axis := ""
+		switch i {
+		case 0:
+			axis = hiLabel
+		case brailleRows - 1:
+			axis = loLabel
+		}
+		lines = append(lines, "  "+axisStyle.Render(fmt.Sprintf("%*s ┤", axisWidth, axis))+chartStyle.Render(line))
+	}
+
+	first, last := truncate(labels[0], 20), truncate(labels[len(labels)-1], 20)
+	pad := max(width-len([]rune(first))-len([]rune(last)), 1)
+	lines = append(lines, "  "+strings.Repeat(" ", axisWidth+2)+axisStyle.Render(first+strings.Repeat(" ", pad)+last), "")
+	return lines
+}
@@ -0,0 +1,216 @@
+package tui
+
+import (
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"slices"
+	"sort"
+	"strconv"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// choroplethRamp is the graduated colour ramp (ColorBrewer YlOrRd) classes
+// are coloured with, from lowest to highest
+var choroplethRamp = []color.NRGBA{
+	{255, 255, 178, 255},
+	{254, 204, 92, 255},
+	{253, 141, 60, 255},
+	{240, 59, 32, 255},
+	{189, 0, 38, 255},
+}
+
+// noDataColor colours geometries whose value is NULL
+var noDataColor = color.NRGBA{128, 128, 128, 255}
+
+const (
+	// choroplethFillAlpha is the fill opacity of coloured polygons; the
+	// colour carries the value, so fills are more opaque than plain previews
+	choroplethFillAlpha = 210
+	legendLineHeight    = 14
+	legendSwatch        = 10
+	legendPadding       = 6
+)
+
+// Legend explains the colours of a thematic map
+type Legend struct {
+	Title  string
+	Labels []string // One label per colour
+	Colors []color.NRGBA
+}
+
+// thematicColumns returns the numeric, non-geometry columns geometries can
+// be coloured by
+func thematicColumns(results *QueryResults) []int {
+	var cols []int
+	for _, i := range numericColumns(results) {
+		if !slices.Contains(results.GeometryColumns, i) {
+			cols = append(cols, i)
+		}
+	}
+	return cols
+}
+
+// cycleChoropleth colours the preview by the next numeric column; after
+// the last one the preview returns to a single colour
+func (m *QueryModel) cycleChoropleth() {
+	if m.selectedEntry < 0 || m.selectedEntry >= len(m.history) {
+		return
+	}
+	results := m.history[m.selectedEntry].Results
+	if results == nil || len(results.previewColumns()) == 0 {
+		m.statusMsg = "✗ No geometries to colour"
+		return
+	}
+	cols := thematicColumns(results)
+	if len(cols) == 0 {
+		m.statusMsg = "✗ Colouring geometries needs a numeric column"
+		return
+	}
+
+	pos := slices.Index(cols, results.choropleth-1)
+	if pos == len(cols)-1 {
+		results.choropleth = 0
+	} else {
+		results.choropleth = cols[pos+1] + 1
+	}
+	results.renderGeometry()
+}
+
+// quantileBreaks splits values into at most classes classes holding about
+// as many values each, returning the upper bound of every class. Repeated
+// values can merge classes, so fewer may be returned.
+func quantileBreaks(values []float64, classes int) []float64 {
+	sorted := slices.Sorted(slices.Values(values))
+	var breaks []float64
+	for k := 1; k <= classes; k++ {
+		b := sorted[(len(sorted)*k+classes-1)/classes-1]
+		if len(breaks) == 0 || b > breaks[len(breaks)-1] {
+			breaks = append(breaks, b)
+		}
+	}
+	return breaks
+}
+
+// rampColors picks n colours spread evenly along choroplethRamp
+func rampColors(n int) []color.NRGBA {
+	if n == 1 {
+		return []color.NRGBA{choroplethRamp[len(choroplethRamp)/2]}
+	}
+	colors := make([]color.NRGBA, n)
+	for i := range colors {
+		colors[i] = choroplethRamp[i*(len(choroplethRamp)-1)/(n-1)]
+	}
+	return colors
+}
+
+// choroplethLayers groups the previewed geometries into one layer per
+// quantile class of the choropleth column, plus a last layer for rows
+// whose value is NULL, and builds the matching legend
+func (r *QueryResults) choroplethLayers() ([][]string, *Legend, bool) {
+	col := r.choropleth - 1
+	var values []float64
+	for _, row := range r.Rows {
+		if col < len(row) {
+			if v, err := strconv.ParseFloat(row[col], 64); err == nil {
+				values = append(values, v)
+			}
+		}
+	}
+	if len(values) == 0 {
+		return nil, nil, false
+	}
+
+	breaks := quantileBreaks(values, len(choroplethRamp))
+	legend := &Legend{Title: r.Columns[col], Colors: rampColors(len(breaks))}
+	lower := slices.Min(values)
+	for _, upper := range breaks {
+		label := formatChartValue(upper)
+		if lower != upper {
+			label = formatChartValue(lower) + " to " + label
+		}
+		legend.Labels = append(legend.Labels, label)
+		lower = upper
+	}
+
+	layers := make([][]string, len(breaks)+1)
+	for _, row := range r.Rows {
+		class := len(breaks)
+		if col < len(row) {
+			if v, err := strconv.ParseFloat(row[col], 64); err == nil {
+				class = sort.SearchFloat64s(breaks, v)
+			}
+		}
+		for _, geomCol := range r.previewColumns() {
+			if geomCol < len(row) && row[geomCol] != "" && row[geomCol] != "NULL" {
+				layers[class] = append(layers[class], row[geomCol])
+			}
+		}
+	}
+	if len(layers[len(breaks)]) > 0 {
+		legend.Labels = append(legend.Labels, "no data")
+		legend.Colors = append(legend.Colors, noDataColor)
+	}
+	return layers, legend, true
+}
+
+// renderChoropleth renders the previewed geometries coloured by the
+// choropleth column, with a legend, and returns base64-encoded PNG data
+func (r *QueryResults) renderChoropleth(width, height int) (string, error) {
+	layers, legend, ok := r.choroplethLayers()
+	if !ok {
+		return "", fmt.Errorf("no values to colour by")
+	}
+	renderer := NewGeometryRenderer(width, height)
+	renderer.LayerColors = append(slices.Clone(legend.Colors[:len(layers)-1]), noDataColor)
+	renderer.FillColor.A = choroplethFillAlpha
+	renderer.Legend = legend
+	pngData, err := renderer.RenderLayers(layers)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(pngData), nil
+}
+
+// drawLegend draws a legend box in the bottom-right corner of img
+func (r *GeometryRenderer) drawLegend(img *image.RGBA, legend Legend) {
+	textWidth := len(legend.Title)
+	for _, label := range legend.Labels {
+		textWidth = max(textWidth, len(label)+2)
+	}
+	boxWidth := textWidth*7 + 2*legendPadding
+	boxHeight := (len(legend.Labels)+1)*legendLineHeight + 2*legendPadding
+	box := image.Rect(r.Width-r.Padding-boxWidth, r.Height-r.Padding-boxHeight, r.Width-r.Padding, r.Height-r.Padding)
+
+	background := r.Background
+	background.A = 200
+	draw.Draw(img, box, image.NewUniform(background), image.Point{}, draw.Over)
+
+	text := color.NRGBA{240, 240, 240, 255}
+	if luminance(r.Background) > 0.5 {
+		text = color.NRGBA{30, 30, 30, 255}
+	}
+	x, y := box.Min.X+legendPadding, box.Min.Y+legendPadding
+	drawChartText(img, x, y+11, legend.Title, text)
+	for i, label := range legend.Labels {
+		y += legendLineHeight
+		swatch := image.Rect(x, y+2, x+legendSwatch, y+2+legendSwatch)
+		draw.Draw(img, swatch, image.NewUniform(legend.Colors[i]), image.Point{}, draw.Src)
+		drawChartText(img, x+legendSwatch+4, y+11, label, text)
+	}
+}
+
+// luminance returns the relative brightness of c between 0 and 1
+func luminance(c color.NRGBA) float64 {
+	return (0.2126*float64(c.R) + 0.7152*float64(c.G) + 0.0722*float64(c.B)) / 255
+}
+
+// renderChoroplethPicker names the column the preview is coloured by
+func renderChoroplethPicker(results *QueryResults) string {
+	hint := lipgloss.NewStyle().Foreground(ColorGray)
+	name := lipgloss.NewStyle().Foreground(ColorOrange).Bold(true).Render(results.Columns[results.choropleth-1])
+	return hint.Render("coloured by ") + name + hint.Render(" (t: next column)")
+}
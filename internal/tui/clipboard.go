@@ -0,0 +1,101 @@
+package tui
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/atotto/clipboard"
+	"github.com/aymanbagabas/go-osc52/v2"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/kartoza/kartoza-pg-ai/internal/postgres"
+)
+
+// clipboardCopiedMsg indicates text was copied to the clipboard
+type clipboardCopiedMsg struct {
+	what   string // Description for the status line, e.g. "SQL"
+	native bool   // Whether the native clipboard accepted the text
+	err    error
+}
+
+// copyToClipboard copies text with an OSC 52 escape sequence, which works
+// over SSH and in most modern terminals, and also with the native clipboard
+// where one is available (xclip/xsel/wl-copy, pbcopy or the Windows API)
+func copyToClipboard(what, text string) tea.Cmd {
+	return func() tea.Msg {
+		seq := osc52.New(text)
+		if os.Getenv("TMUX") != "" {
+			seq = seq.Tmux()
+		} else if strings.HasPrefix(os.Getenv("TERM"), "screen") {
+			seq = seq.Screen()
+		}
+		_, oscErr := seq.WriteTo(os.Stderr)
+
+		nativeErr := clipboard.WriteAll(text)
+		if nativeErr != nil && oscErr != nil {
+			return clipboardCopiedMsg{what: what, err: nativeErr}
+		}
+		return clipboardCopiedMsg{what: what, native: nativeErr == nil}
+	}
+}
+
+// resultsTSV returns results as tab-separated values with a header row,
+// ready to paste into a spreadsheet. Tabs and line breaks inside values
+// are replaced by spaces.
+func resultsTSV(results *QueryResults) string {
+	clean := strings.NewReplacer("\t", " ", "\r\n", " ", "\n", " ", "\r", " ")
+
+	var b strings.Builder
+	for i, col := range results.Columns {
+		if i > 0 {
+			b.WriteByte('\t')
+		}
+		b.WriteString(clean.Replace(col))
+	}
+	b.WriteByte('\n')
+	for _, row := range results.Rows {
+		for i, v := range row {
+			if i > 0 {
+				b.WriteByte('\t')
+			}
+			b.WriteString(clean.Replace(v))
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// copyTarget returns the selected conversation entry, or the latest one
+func (m *QueryModel) copyTarget() *ConversationEntry {
+	if m.selectedEntry >= 0 && m.selectedEntry < len(m.history) {
+		return &m.history[m.selectedEntry]
+	}
+	if len(m.history) > 0 {
+		return &m.history[len(m.history)-1]
+	}
+	return nil
+}
+
+// copySQL copies the SQL of the selected entry, as edited if it was edited
+func (m *QueryModel) copySQL() tea.Cmd {
+	entry := m.copyTarget()
+	if entry == nil || entry.SQL == "" {
+		m.statusMsg = "✗ No SQL to copy"
+		return nil
+	}
+	sqlText := entry.SQL
+	if entry.EditedSQL != "" {
+		sqlText = entry.EditedSQL
+	}
+	return copyToClipboard("SQL", postgres.FormatSQL(strings.TrimSpace(sqlText), sqlTextWidth)+"\n")
+}
+
+// copyResults copies the fetched rows of the selected entry as TSV
+func (m *QueryModel) copyResults() tea.Cmd {
+	results := m.exportTarget()
+	if results == nil || len(results.Columns) == 0 {
+		m.statusMsg = "✗ No results to copy"
+		return nil
+	}
+	return copyToClipboard(fmt.Sprintf("%d rows", len(results.Rows)), resultsTSV(results))
+}
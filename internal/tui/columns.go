@@ -0,0 +1,240 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// columnSelectState tracks the highlighted column while choosing one to
+// sort by or hide
+type columnSelectState struct {
+	entry int // Conversation entry whose table is being browsed
+	col   int
+}
+
+// columnSort records how a result set was re-sorted, so sorting again
+// starts from the original SQL instead of nesting ORDER BY wrappers
+type columnSort struct {
+	baseSQL   string // SQL the sort wraps
+	baseQuery string // Question the original results answered
+	column    int
+	desc      bool
+}
+
+// sortSQL wraps source in a query ordering it by column (1-based position
+// pos). Names shared by several columns are ambiguous, so those sort by
+// position instead.
+func sortSQL(source string, columns []string, pos int, desc bool) string {
+	source = strings.TrimRight(strings.TrimSpace(source), "; \n\t")
+
+	orderBy := fmt.Sprintf("%d", pos)
+	name := columns[pos-1]
+	duplicate := false
+	for i, col := range columns {
+		if i != pos-1 && col == name {
+			duplicate = true
+		}
+	}
+	if !duplicate {
+		orderBy = "sort_source." + quoteIdentifier(name)
+	}
+
+	direction := "ASC"
+	if desc {
+		direction = "DESC"
+	}
+	return fmt.Sprintf("SELECT * FROM (%s) AS sort_source ORDER BY %s %s", source, orderBy, direction)
+}
+
+// isHidden reports whether column i was hidden from display
+func (r *QueryResults) isHidden(i int) bool {
+	return r.hidden[i]
+}
+
+// hiddenCount returns how many columns are hidden from display
+func (r *QueryResults) hiddenCount() int {
+	return len(r.hidden)
+}
+
+// selectedColumn returns the highlighted column of results, or -1
+func (m *QueryModel) selectedColumn(results *QueryResults) int {
+	if m.colSelect == nil || m.history[m.colSelect.entry].Results != results {
+		return -1
+	}
+	return m.colSelect.col
+}
+
+// startColumnSelect highlights the first visible column of the selected entry's results
+func (m *QueryModel) startColumnSelect() {
+	if m.selectedEntry < 0 || m.selectedEntry >= len(m.history) {
+		return
+	}
+	results := m.history[m.selectedEntry].Results
+	if results == nil || len(results.Columns) == 0 || results.Mutating {
+		m.statusMsg = "✗ No result columns for this entry"
+		return
+	}
+	visible, _ := m.visibleColumns(results, columnWidths(results))
+	if len(visible) == 0 {
+		m.statusMsg = "✗ All columns are hidden (u: show them again)"
+		return
+	}
+	m.colSelect = &columnSelectState{entry: m.selectedEntry, col: visible[0]}
+}
+
+// moveColumnSelection moves the highlight to the next shown column in
+// direction delta and scrolls the table so it stays on screen
+func (m *QueryModel) moveColumnSelection(delta int) {
+	sel := m.colSelect
+	results := m.history[sel.entry].Results
+	for i := sel.col + delta; i >= 0 && i < len(results.Columns); i += delta {
+		if results.isHidden(i) {
+			continue
+		}
+		sel.col = i
+		break
+	}
+
+	widths := columnWidths(results)
+	if sel.col >= m.frozenColumns(results) && sel.col < results.colOffset {
+		results.colOffset = sel.col
+	}
+	for {
+		visible, hiddenRight := m.visibleColumns(results, widths)
+		if !hiddenRight || sel.col <= visible[len(visible)-1] {
+			break
+		}
+		results.colOffset = max(results.colOffset, m.frozenColumns(results)) + 1
+	}
+}
+
+// handleColumnSelectKey moves the column highlight, sorts by, hides or
+// humanizes the highlighted column and shows hidden columns again
+func (m *QueryModel) handleColumnSelectKey(msg tea.KeyMsg) (*QueryModel, tea.Cmd) {
+	sel := m.colSelect
+	results := m.history[sel.entry].Results
+
+	switch msg.String() {
+	case "left":
+		m.moveColumnSelection(-1)
+	case "right", "tab":
+		m.moveColumnSelection(1)
+	case "s":
+		m.colSelect = nil
+		return m.runColumnSort(results, sel.col)
+	case "h":
+		if len(results.Columns)-results.hiddenCount() <= 1 {
+			m.statusMsg = "✗ The last shown column cannot be hidden"
+			return m, nil
+		}
+		if results.hidden == nil {
+			results.hidden = make(map[int]bool)
+		}
+		results.hidden[sel.col] = true
+		m.moveColumnSelection(1)
+		if results.isHidden(sel.col) {
+			m.moveColumnSelection(-1)
+		}
+	case "u":
+		results.hidden = nil
+	case "r":
+		m.toggleHumanized(results, sel.col)
+	case "esc", "q", "enter":
+		m.colSelect = nil
+	}
+	return m, nil
+}
+
+// toggleHumanized switches column col between its values and readable
+// sizes or durations: numbers as byte counts, e.g. "1.2 GB", and intervals
+// as "3m40s"
+func (m *QueryModel) toggleHumanized(results *QueryResults, col int) {
+	kind := results.columnKind(col)
+	if kind != cellNumber && kind != cellInterval {
+		m.statusMsg = "✗ Only numeric and interval columns have readable sizes or durations"
+		return
+	}
+	if results.humanized[col] {
+		delete(results.humanized, col)
+		return
+	}
+	if results.humanized == nil {
+		results.humanized = make(map[int]bool)
+	}
+	results.humanized[col] = true
+}
+
+// runColumnSort re-runs the SQL behind results ordered by column col as a new
+// conversation entry. Sorting again by the same column flips the direction.
+func (m *QueryModel) runColumnSort(results *QueryResults, col int) (*QueryModel, tea.Cmd) {
+	if m.loading {
+		return m, nil
+	}
+	order := columnSort{baseSQL: results.ExecutedSQL(), baseQuery: results.NaturalQuery, column: col}
+	if results.sort != nil {
+		order.baseSQL = results.sort.baseSQL
+		order.baseQuery = results.sort.baseQuery
+		order.desc = results.sort.column == col && !results.sort.desc
+	}
+	if order.baseSQL == "" {
+		m.statusMsg = "✗ No SQL to sort"
+		return m, nil
+	}
+
+	direction := "ascending"
+	if order.desc {
+		direction = "descending"
+	}
+	query := fmt.Sprintf("%s (sorted by %s, %s)", order.baseQuery, results.Columns[col], direction)
+	sqlQuery := sortSQL(order.baseSQL, results.Columns, col+1, order.desc)
+	hidden := make(map[int]bool, len(results.hidden))
+	for i := range results.hidden {
+		hidden[i] = true
+	}
+	humanized := make(map[int]bool, len(results.humanized))
+	for i := range results.humanized {
+		humanized[i] = true
+	}
+	params := results.Params
+
+	m.loading = true
+	ctx := m.newQueryContext()
+	return m, tea.Batch(m.spinner.Tick, cancellable(ctx, query, func() tea.Msg {
+		if m.database() == nil {
+			return queryExecutedMsg{err: fmt.Errorf("no database connection")}
+		}
+		msg := m.prepareSQL(ctx, query, sqlQuery, sqlQuery, params)
+		if executed, ok := msg.(queryExecutedMsg); ok && executed.results != nil {
+			executed.results.sort = &order
+			if len(hidden) > 0 {
+				executed.results.hidden = hidden
+			}
+			if len(humanized) > 0 {
+				executed.results.humanized = humanized
+			}
+		}
+		return msg
+	}))
+}
+
+// renderColumnPicker renders the prompt line while choosing a column
+func (m *QueryModel) renderColumnPicker() string {
+	results := m.history[m.colSelect.entry].Results
+	highlight := lipgloss.NewStyle().Foreground(ColorOrange).Bold(true)
+
+	prompt := PromptStyle.Render("↕ Sort or hide column: ") + highlight.Render("◀ "+results.Columns[m.colSelect.col]+" ▶")
+	if s := results.sort; s != nil && s.column == m.colSelect.col {
+		direction := "ascending"
+		if s.desc {
+			direction = "descending"
+		}
+		prompt += PromptStyle.Render("  (sorted " + direction + ")")
+	}
+	if results.humanized[m.colSelect.col] {
+		prompt += PromptStyle.Render("  (readable)")
+	}
+	return prompt
+}
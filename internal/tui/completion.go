@@ -0,0 +1,278 @@
+package tui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/kartoza/kartoza-pg-ai/internal/config"
+	"github.com/kujtimiihoxha/vimtea"
+)
+
+// maxCompletions caps the schema names offered for one word
+const maxCompletions = 20
+
+// cursorMarker is typed into the vim editor to find its cursor, which the
+// editor does not expose, and removed straight away
+const cursorMarker = "\x01"
+
+// completionState is a schema name completion in progress: Tab inserts the
+// best match for the word before the cursor, and pressing it again
+// replaces it with the next
+type completionState struct {
+	candidates []string
+	index      int
+	row        int    // Editor line of the word
+	start      int    // Column the word starts at: bytes in vim, runes in the textarea
+	inserted   string // Candidate now in the editor
+}
+
+// isCompletionRune reports whether r belongs to a name being completed
+func isCompletionRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '.'
+}
+
+// fuzzyScore ranks how well name matches the typed pattern, case-insensitively:
+// prefixes first, then matches at a word start (after "_"), then substrings,
+// then the pattern's letters in order. Shorter names rank higher within each.
+func fuzzyScore(name, pattern string) (int, bool) {
+	n, p := strings.ToLower(name), strings.ToLower(pattern)
+	switch {
+	case strings.HasPrefix(n, p):
+		return 4000 - len(n), true
+	case strings.Contains(n, "_"+p):
+		return 3000 - len(n), true
+	case strings.Contains(n, p):
+		return 2000 - len(n), true
+	}
+	// Subsequence, penalising the letters skipped between matches
+	want := []rune(p)
+	gaps, i := 0, 0
+	for _, r := range n {
+		if i < len(want) && r == want[i] {
+			i++
+		} else if i > 0 && i < len(want) {
+			gaps++
+		}
+	}
+	if i < len(want) {
+		return 0, false
+	}
+	return 1000 - 10*gaps - len(n), true
+}
+
+// schemaCompletions returns the table, view and column names matching the
+// word typed, best first. A word qualified with a table ("roads.na")
+// completes that table's columns. Columns of tables the text mentions
+// rank above others.
+func schemaCompletions(schema *config.SchemaCache, word, text string) []string {
+	if schema == nil || word == "" {
+		return nil
+	}
+	type relation struct {
+		name    string
+		columns []config.ColumnInfo
+	}
+	var relations []relation
+	for _, t := range schema.Tables {
+		relations = append(relations, relation{t.Name, t.Columns})
+	}
+	for _, v := range schema.Views {
+		relations = append(relations, relation{v.Name, v.Columns})
+	}
+
+	scores := make(map[string]int)
+	add := func(name, pattern string, bonus int) {
+		score, ok := fuzzyScore(name, pattern)
+		if ok && score+bonus > scores[name] {
+			scores[name] = score + bonus
+		}
+	}
+
+	if dot := strings.LastIndex(word, "."); dot >= 0 {
+		qualifier, pattern := word[:dot], word[dot+1:]
+		for _, r := range relations {
+			if !strings.EqualFold(r.name, qualifier) {
+				continue
+			}
+			for _, c := range r.columns {
+				if pattern == "" {
+					scores[qualifier+"."+c.Name] = 1
+				} else {
+					add(qualifier+"."+c.Name, qualifier+"."+pattern, 0)
+				}
+			}
+		}
+	} else {
+		lower := strings.ToLower(text)
+		for _, r := range relations {
+			add(r.name, word, 0)
+			bonus := 0
+			if strings.Contains(lower, strings.ToLower(r.name)) {
+				bonus = 500
+			}
+			for _, c := range r.columns {
+				add(c.Name, word, bonus)
+			}
+		}
+	}
+
+	names := make([]string, 0, len(scores))
+	for name := range scores {
+		if !strings.EqualFold(name, word) {
+			names = append(names, name)
+		}
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if scores[names[i]] != scores[names[j]] {
+			return scores[names[i]] > scores[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	if len(names) > maxCompletions {
+		names = names[:maxCompletions]
+	}
+	return names
+}
+
+// completeSchemaName completes the word before the editor's cursor with a
+// table or column name, or moves to the next (previous with shift+tab) of
+// the names offered by the Tab before. It reports false when there is no
+// word to complete, so Tab keeps its usual meaning.
+func (m *QueryModel) completeSchemaName(backward bool) (bool, tea.Cmd) {
+	if m.vimMode && m.vimEditor.GetMode() != vimtea.ModeInsert {
+		return false, nil
+	}
+	if c := m.completion; c != nil {
+		step := 1
+		if backward {
+			step = len(c.candidates) - 1
+		}
+		c.index = (c.index + step) % len(c.candidates)
+		cmd := m.replaceEditorWord(c.row, c.start, c.inserted, c.candidates[c.index])
+		c.inserted = c.candidates[c.index]
+		return true, cmd
+	}
+
+	row, col, line, cmd := m.editorCursor()
+	start := col
+	for start > 0 && isCompletionRune(line[start-1]) {
+		start--
+	}
+	word := string(line[start:col])
+	if word == "" {
+		return false, cmd
+	}
+	candidates := schemaCompletions(m.schema, word, m.getEditorText())
+	if len(candidates) == 0 {
+		m.statusMsg = fmt.Sprintf("✗ No table or column matches %q", word)
+		return true, cmd
+	}
+	if m.vimMode {
+		// The vim buffer counts columns in bytes
+		start = len(string(line[:start]))
+	}
+	m.statusMsg = ""
+	m.completion = &completionState{candidates: candidates, row: row, start: start, inserted: candidates[0]}
+	return true, tea.Batch(cmd, m.replaceEditorWord(row, start, word, candidates[0]))
+}
+
+// editorCursor returns the cursor's line and rune column, and the line's text
+func (m *QueryModel) editorCursor() (int, int, []rune, tea.Cmd) {
+	if !m.vimMode {
+		lines := strings.Split(m.textArea.Value(), "\n")
+		row := min(m.textArea.Line(), len(lines)-1)
+		info := m.textArea.LineInfo()
+		line := []rune(lines[row])
+		return row, min(info.StartColumn+info.ColumnOffset, len(line)), line, nil
+	}
+
+	updated, cmd := m.vimEditor.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(cursorMarker)})
+	m.vimEditor = updated.(vimtea.Editor)
+	buf := m.vimEditor.GetBuffer()
+	for row, text := range buf.Lines() {
+		if col := strings.Index(text, cursorMarker); col >= 0 {
+			buf.DeleteAt(row, col, row, col)
+			m.moveVimCursor(row, col)
+			return row, len([]rune(text[:col])), []rune(text[:col] + text[col+1:]), cmd
+		}
+	}
+	return 0, 0, nil, cmd
+}
+
+// replaceEditorWord replaces old, just before the cursor at row and start,
+// with word, leaving the cursor after it
+func (m *QueryModel) replaceEditorWord(row, start int, old, word string) tea.Cmd {
+	if !m.vimMode {
+		var cmds []tea.Cmd
+		for range []rune(old) {
+			var cmd tea.Cmd
+			m.textArea, cmd = m.textArea.Update(tea.KeyMsg{Type: tea.KeyBackspace})
+			cmds = append(cmds, cmd)
+		}
+		m.textArea.InsertString(word)
+		return tea.Batch(cmds...)
+	}
+
+	buf := m.vimEditor.GetBuffer()
+	if old != "" {
+		buf.DeleteAt(row, start, row, start+len(old)-1)
+	}
+	buf.InsertAt(row, start, word)
+	m.moveVimCursor(row, start+len(word))
+	return nil
+}
+
+// moveVimCursor places the vim editor's cursor. The editor takes a cursor
+// position only with an undo or redo, so one is reported.
+func (m *QueryModel) moveVimCursor(row, col int) {
+	updated, _ := m.vimEditor.Update(vimtea.UndoRedoMsg{NewCursor: vimtea.Cursor{Row: row, Col: col}, Success: true})
+	m.vimEditor = updated.(vimtea.Editor)
+}
+
+// renderCompletions renders the names offered by the completion in progress
+func (m *QueryModel) renderCompletions() string {
+	c := m.completion
+	if c == nil || len(c.candidates) < 2 {
+		return ""
+	}
+	current := lipgloss.NewStyle().Foreground(ColorOrange).Bold(true)
+	other := lipgloss.NewStyle().Foreground(ColorGray)
+	parts := make([]string, len(c.candidates))
+	for i, name := range c.candidates {
+		if note := tableNote(m.schema, name); note != "" {
+			name += " (" + note + ")"
+		}
+		if i == c.index {
+			parts[i] = current.Render(name)
+		} else {
+			parts[i] = other.Render(name)
+		}
+	}
+	line := other.Render("Tab: ") + strings.Join(parts, other.Render(" · "))
+	return lipgloss.NewStyle().MaxWidth(max(m.width-6, 10)).Render(line)
+}
+
+// tableNote returns what limits the rows of the table name to the
+// connected role: "no access" when it cannot select from it, "row
+// security" when policies filter its rows, otherwise ""
+func tableNote(schema *config.SchemaCache, name string) string {
+	if schema == nil {
+		return ""
+	}
+	for _, t := range schema.Tables {
+		if t.Name != name {
+			continue
+		}
+		switch {
+		case !t.CanRead():
+			return "no access"
+		case t.RowSecurityActive():
+			return "row security"
+		}
+	}
+	return ""
+}
@@ -0,0 +1,110 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/kartoza/kartoza-pg-ai/internal/config"
+)
+
+// themeGeneration counts the themes applied, so renderings made in an
+// earlier one are not reused
+var themeGeneration int
+
+// entryView is everything the rendering of a conversation entry depends
+// on. An entry is rendered again only when its view changes.
+type entryView struct {
+	query, sql, editedSQL string
+	service               string
+	explanation, err      string
+	repairs               int
+	showSQL, unseen       bool
+	selected, latest      bool
+	width                 int
+	theme                 int
+	schema                *config.SchemaCache // Row security warnings come from it
+	results               *QueryResults
+	resultsView           string // See resultsView
+}
+
+// renderedEntry is a rendered conversation entry, one terminal line per
+// element, with the line each searchable part starts on
+type renderedEntry struct {
+	view  entryView
+	lines []string
+	marks map[searchField]int
+}
+
+// entryView returns the view conversation entry i is rendered from
+func (m *QueryModel) entryView(i int) entryView {
+	entry := &m.history[i]
+	view := entryView{
+		query:       entry.Query,
+		service:     entry.Service,
+		sql:         entry.SQL,
+		editedSQL:   entry.EditedSQL,
+		explanation: entry.Explanation,
+		err:         entry.Error,
+		repairs:     len(entry.Repairs),
+		showSQL:     entry.ShowSQL,
+		unseen:      entry.Unseen,
+		selected:    i == m.selectedEntry,
+		latest:      i == len(m.history)-1,
+		width:       m.width,
+		theme:       themeGeneration,
+		schema:      m.schema,
+		results:     entry.Results,
+	}
+	if r := entry.Results; r != nil {
+		view.resultsView = m.resultsView(r, view.latest)
+	}
+	return view
+}
+
+// resultsView describes the state of results their rendering depends on:
+// rows loaded and left to fetch, scrolling, hidden and humanized columns, the chart, the
+// geometry preview and the highlighted row and column. Lengths stand in
+// for the images, which change along with what they are drawn from.
+func (m *QueryModel) resultsView(r *QueryResults, latest bool) string {
+	return fmt.Sprint(len(r.Rows), r.HasMoreRows(), r.RowCount, r.RowsEstimated, r.ExecutionTime, len(r.Steps),
+		r.colOffset, m.frozenColumns(r), r.hidden, r.humanized,
+		r.chart, len(r.chartImage), r.choropleth,
+		r.GeometryColIdx, r.GeometryOverlay, len(r.GeometryImage),
+		m.displayedRows(r, latest), m.selectedRow(r), m.selectedColumn(r))
+}
+
+// renderedEntries returns every conversation entry rendered, rendering
+// again only those whose view changed since the last frame
+func (m *QueryModel) renderedEntries() []*renderedEntry {
+	if len(m.convCache) > len(m.history) {
+		m.convCache = m.convCache[:len(m.history)]
+	}
+	for len(m.convCache) < len(m.history) {
+		m.convCache = append(m.convCache, nil)
+	}
+	for i := range m.history {
+		view := m.entryView(i)
+		if cached := m.convCache[i]; cached != nil && cached.view == view {
+			continue
+		}
+		m.convCache[i] = m.renderEntryLines(i, view)
+	}
+	return m.convCache
+}
+
+// renderEntryLines renders conversation entry i, splitting it into
+// terminal lines
+func (m *QueryModel) renderEntryLines(i int, view entryView) *renderedEntry {
+	parts, marks := m.renderEntry(i)
+	rendered := &renderedEntry{view: view, marks: make(map[searchField]int, len(marks))}
+	starts := make([]int, len(parts)+1)
+	for j, part := range parts {
+		starts[j] = len(rendered.lines)
+		rendered.lines = append(rendered.lines, strings.Split(part, "\n")...)
+	}
+	starts[len(parts)] = len(rendered.lines)
+	for field, part := range marks {
+		rendered.marks[field] = starts[part]
+	}
+	return rendered
+}
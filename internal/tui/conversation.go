@@ -0,0 +1,138 @@
+package tui
+
+import (
+	"time"
+
+	"github.com/kartoza/kartoza-pg-ai/internal/config"
+)
+
+// saveConversation persists the active session's conversation so it survives
+// leaving the query screen and restarts. Errors are only logged; saving is
+// best effort.
+func (m *QueryModel) saveConversation() {
+	if m.service == nil {
+		return
+	}
+
+	conv := &config.Conversation{ServiceName: m.service.Name, Session: m.sessionName()}
+	for _, entry := range m.history {
+		turn := config.ConversationTurn{
+			Query:     entry.Query,
+			SQL:       entry.SQL,
+			EditedSQL: entry.EditedSQL,
+			Error:     entry.Error,
+			Repairs:   entry.Repairs,
+			Service:   entry.Service,
+		}
+		if r := entry.Results; r != nil {
+			turn.Columns = r.Columns
+			turn.ColumnTypes = r.ColumnTypes
+			turn.Rows = r.Rows
+			turn.RowCount = r.RowCount
+			turn.RowsEstimated = r.RowsEstimated
+			turn.ExecutionTime = r.ExecutionTime
+			turn.Mutating = r.Mutating
+			turn.Params = r.Params
+		}
+		conv.Turns = append(conv.Turns, turn)
+	}
+
+	if err := config.SaveConversation(conv); err != nil {
+		debugLog("saveConversation: " + err.Error())
+	}
+}
+
+// ResumeConversation restores every saved session of the active service,
+// replacing the current ones, and activates the most recently updated. It
+// returns false if there is nothing to restore.
+func (m *QueryModel) ResumeConversation() bool {
+	if m.service == nil {
+		return false
+	}
+	convs, err := config.LoadConversations(m.service.Name)
+	if err != nil {
+		debugLog("ResumeConversation: " + err.Error())
+		return false
+	}
+
+	var sessions []*querySession
+	var active int
+	var latest time.Time
+	for _, conv := range convs {
+		if len(conv.Turns) == 0 {
+			continue
+		}
+		if conv.UpdatedAt.After(latest) {
+			active, latest = len(sessions), conv.UpdatedAt
+		}
+		sessions = append(sessions, restoreSession(conv))
+	}
+	if len(sessions) == 0 {
+		return false
+	}
+
+	m.results.closeCursor()
+	for _, s := range m.sessions {
+		s.results.closeCursor()
+	}
+	m.sessions = sessions
+	m.activateSession(active)
+	return true
+}
+
+// restoreSession rebuilds a session from its saved conversation
+func restoreSession(conv *config.Conversation) *querySession {
+	name := conv.Session
+	if name == "" {
+		name = config.DefaultSession
+	}
+	s := newQuerySession(name)
+	for _, turn := range conv.Turns {
+		entry := ConversationEntry{
+			Query:     turn.Query,
+			SQL:       turn.SQL,
+			EditedSQL: turn.EditedSQL,
+			Error:     turn.Error,
+			ShowSQL:   turn.EditedSQL != "",
+			Repairs:   turn.Repairs,
+			Service:   turn.Service,
+		}
+		if turn.Error == "" {
+			entry.Results = restoreResults(turn)
+		}
+		s.history = append(s.history, entry)
+	}
+	s.selectedEntry = len(s.history) - 1
+
+	// Restored rows are a saved snapshot; there is no cursor to fetch more from
+	if last := s.history[len(s.history)-1].Results; last != nil {
+		s.results = last
+		s.totalFetched = len(last.Rows)
+	}
+	return s
+}
+
+// restoreResults rebuilds QueryResults from a saved turn, re-detecting and
+// re-rendering geometry from the saved rows
+func restoreResults(turn config.ConversationTurn) *QueryResults {
+	results := &QueryResults{
+		Columns:        turn.Columns,
+		ColumnTypes:    turn.ColumnTypes,
+		Rows:           turn.Rows,
+		RowCount:       turn.RowCount,
+		RowsEstimated:  turn.RowsEstimated,
+		ExecutionTime:  turn.ExecutionTime,
+		GeneratedSQL:   turn.SQL,
+		EditedSQL:      turn.EditedSQL,
+		NaturalQuery:   turn.Query,
+		GeometryColIdx: -1,
+		Mutating:       turn.Mutating,
+		Params:         turn.Params,
+	}
+	if len(turn.Rows) > 0 {
+		results.GeometryColIdx = DetectGeometryColumn(turn.Columns, turn.Rows[0])
+		results.GeometryColumns = DetectGeometryColumns(turn.Columns, turn.Rows[0])
+		results.renderGeometry()
+	}
+	return results
+}
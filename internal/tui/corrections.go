@@ -0,0 +1,32 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/kartoza/kartoza-pg-ai/internal/logging"
+)
+
+// learnCorrections ties the words of a question to the tables and columns
+// the user's edit of its generated SQL swapped in, so later questions using
+// those words are matched to them, and returns a note of what was learned
+// ("" when nothing was)
+func (m *QueryModel) learnCorrections(r *QueryResults) string {
+	aliases := m.queryEngine.LearnCorrections(r.NaturalQuery, r.GeneratedSQL, r.EditedSQL)
+	if len(aliases) == 0 {
+		return ""
+	}
+	var learned []string
+	for _, a := range aliases {
+		m.cfg.LearnAlias(m.service.Name, a)
+		target := a.Schema + "." + a.Table
+		if a.Column != "" {
+			target += "." + a.Column
+		}
+		learned = append(learned, fmt.Sprintf("%s → %s", a.Word, target))
+		logging.Info("alias learned", "service", m.service.Name, "word", a.Word, "target", target)
+	}
+	m.cfg.Save()
+	m.queryEngine.SetAliases(m.cfg.Aliases[m.service.Name])
+	return "Learned " + strings.Join(learned, ", ")
+}
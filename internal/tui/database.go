@@ -2,6 +2,8 @@ package tui
 
 import (
 	"fmt"
+	"sort"
+	"strings"
 
 	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/spinner"
@@ -9,6 +11,7 @@ import (
 	"github.com/charmbracelet/lipgloss"
 	"github.com/kartoza/kartoza-pg-ai/internal/config"
 	"github.com/kartoza/kartoza-pg-ai/internal/postgres"
+	"github.com/mattn/go-runewidth"
 )
 
 // DatabaseModel represents the database connection screen
@@ -21,6 +24,7 @@ type DatabaseModel struct {
 	spinner        spinner.Model
 	error          string
 	testingService string
+	tagFilter      string // Only services with this tag are listed, when set
 	cfg            *config.Config
 }
 
@@ -32,8 +36,8 @@ type servicesLoadedMsg struct {
 
 // serviceTestedMsg indicates a service connection test completed
 type serviceTestedMsg struct {
-	serviceName string
-	err         error
+	service postgres.ServiceEntry
+	err     error
 }
 
 // serviceSelectedMsg indicates a service was selected
@@ -79,20 +83,148 @@ func (m *DatabaseModel) Init() tea.Cmd {
 	)
 }
 
-// loadServices loads the pg_service.conf file
+// loadServices loads the pg_service.conf file, plus a service built from the
+// libpq environment variables when they are set
 func (m *DatabaseModel) loadServices() tea.Cmd {
 	return func() tea.Msg {
-		services, err := postgres.ParsePGServiceFile()
+		services, err := availableServices()
 		return servicesLoadedMsg{services: services, err: err}
 	}
 }
 
+// availableServices returns the services of pg_service.conf, plus a
+// service built from the libpq environment variables when they are set
+func availableServices() ([]postgres.ServiceEntry, error) {
+	services, err := postgres.ParsePGServiceFile()
+	if env, ok := postgres.EnvServiceEntry(); ok {
+		if !postgres.PGServiceFileExists() {
+			err = nil
+		}
+		// A pg_service.conf entry of the same name takes precedence
+		if err == nil {
+			if _, lookupErr := postgres.GetServiceByName(services, env.Name); lookupErr != nil {
+				services = append([]postgres.ServiceEntry{env}, services...)
+			}
+		}
+	}
+	return services, err
+}
+
 // testService tests the connection to a service
 func (m *DatabaseModel) testService(service postgres.ServiceEntry) tea.Cmd {
 	return func() tea.Msg {
 		err := service.TestConnection()
-		return serviceTestedMsg{serviceName: service.Name, err: err}
+		return serviceTestedMsg{service: service, err: err}
+	}
+}
+
+// quickConnect connects to the named service as if it had been chosen from
+// the list, for connecting to the last used service on startup. A failure
+// is shown on this screen.
+func (m *DatabaseModel) quickConnect(name string) tea.Cmd {
+	m.testingService = name
+	return tea.Batch(m.spinner.Tick, func() tea.Msg {
+		services, err := availableServices()
+		if err != nil {
+			return serviceTestedMsg{service: postgres.ServiceEntry{Name: name}, err: err}
+		}
+		service, err := postgres.GetServiceByName(services, name)
+		if err != nil {
+			return serviceTestedMsg{service: postgres.ServiceEntry{Name: name}, err: err}
+		}
+		return serviceTestedMsg{service: *service, err: service.TestConnection()}
+	})
+}
+
+// byRecency orders services by when they were last connected to, most
+// recent first; services never used keep their order after them
+func (m *DatabaseModel) byRecency(services []postgres.ServiceEntry) []postgres.ServiceEntry {
+	if m.cfg == nil || len(m.cfg.LastUsed) == 0 {
+		return services
+	}
+	sorted := append([]postgres.ServiceEntry(nil), services...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return m.cfg.LastUsed[sorted[i].Name].After(m.cfg.LastUsed[sorted[j].Name])
+	})
+	return sorted
+}
+
+// serviceGroup is the group a service is listed under: its first tag
+func serviceGroup(s postgres.ServiceEntry) string {
+	if len(s.Tags) == 0 {
+		return ""
+	}
+	return s.Tags[0]
+}
+
+// groupServices orders services by group, keeping their order within each
+// group and listing untagged services last
+func groupServices(services []postgres.ServiceEntry) []postgres.ServiceEntry {
+	var groups []string
+	byGroup := make(map[string][]postgres.ServiceEntry)
+	for _, s := range services {
+		group := serviceGroup(s)
+		if _, ok := byGroup[group]; !ok && group != "" {
+			groups = append(groups, group)
+		}
+		byGroup[group] = append(byGroup[group], s)
+	}
+	grouped := make([]postgres.ServiceEntry, 0, len(services))
+	for _, group := range append(groups, "") {
+		grouped = append(grouped, byGroup[group]...)
+	}
+	return grouped
+}
+
+// serviceTags returns every tag of the services, in the order first used
+func serviceTags(services []postgres.ServiceEntry) []string {
+	var tags []string
+	seen := make(map[string]bool)
+	for _, s := range services {
+		for _, tag := range s.Tags {
+			if !seen[tag] {
+				seen[tag] = true
+				tags = append(tags, tag)
+			}
+		}
+	}
+	return tags
+}
+
+// visibleServices returns the services listed: those with the tag being
+// filtered by, or all of them
+func (m *DatabaseModel) visibleServices() []postgres.ServiceEntry {
+	if m.tagFilter == "" {
+		return m.services
+	}
+	var services []postgres.ServiceEntry
+	for _, s := range m.services {
+		if s.HasTag(m.tagFilter) {
+			services = append(services, s)
+		}
 	}
+	return services
+}
+
+// cycleTagFilter lists only the services with the next tag, then all
+// services again after the last tag
+func (m *DatabaseModel) cycleTagFilter() {
+	tags := serviceTags(m.services)
+	if len(tags) == 0 {
+		m.error = "No services are tagged; add a '# tags: prod, gis' comment to a service"
+		return
+	}
+	next := tags[0]
+	for i, tag := range tags {
+		if tag == m.tagFilter {
+			next = ""
+			if i+1 < len(tags) {
+				next = tags[i+1]
+			}
+		}
+	}
+	m.tagFilter = next
+	m.selectedItem = 0
 }
 
 // Update handles messages for the database model
@@ -119,7 +251,7 @@ func (m *DatabaseModel) Update(msg tea.Msg) (*DatabaseModel, tea.Cmd) {
 			}
 			m.error = msg.err.Error()
 		} else {
-			m.services = msg.services
+			m.services = groupServices(m.byRecency(msg.services))
 			// If file exists but is empty, prompt to add first entry
 			if len(m.services) == 0 {
 				return m, func() tea.Msg {
@@ -132,18 +264,13 @@ func (m *DatabaseModel) Update(msg tea.Msg) (*DatabaseModel, tea.Cmd) {
 	case serviceTestedMsg:
 		m.testingService = ""
 		if msg.err != nil {
-			m.error = fmt.Sprintf("Connection to '%s' failed: %v", msg.serviceName, msg.err)
-		} else {
-			// Connection successful, select this service
-			for _, s := range m.services {
-				if s.Name == msg.serviceName {
-					return m, func() tea.Msg {
-						return serviceSelectedMsg{service: s}
-					}
-				}
-			}
+			m.error = fmt.Sprintf("Connection to '%s' failed: %v", msg.service.Name, msg.err)
+			return m, nil
+		}
+		// Connection successful, select this service
+		return m, func() tea.Msg {
+			return serviceSelectedMsg{service: msg.service}
 		}
-		return m, nil
 
 	case tea.KeyMsg:
 		// Clear error on any key
@@ -151,8 +278,14 @@ func (m *DatabaseModel) Update(msg tea.Msg) (*DatabaseModel, tea.Cmd) {
 			m.error = ""
 		}
 
+		services := m.visibleServices()
 		switch {
 		case key.Matches(msg, key.NewBinding(key.WithKeys("esc"))):
+			if m.tagFilter != "" {
+				m.tagFilter = ""
+				m.selectedItem = 0
+				return m, nil
+			}
 			return m, func() tea.Msg {
 				return goToMenuMsg{} // Go back to menu
 			}
@@ -161,26 +294,26 @@ func (m *DatabaseModel) Update(msg tea.Msg) (*DatabaseModel, tea.Cmd) {
 			return m, tea.Quit
 
 		case key.Matches(msg, key.NewBinding(key.WithKeys("up", "k"))):
-			if len(m.services) > 0 {
+			if len(services) > 0 {
 				m.selectedItem--
 				if m.selectedItem < 0 {
-					m.selectedItem = len(m.services) - 1
+					m.selectedItem = len(services) - 1
 				}
 			}
 			return m, nil
 
 		case key.Matches(msg, key.NewBinding(key.WithKeys("down", "j"))):
-			if len(m.services) > 0 {
+			if len(services) > 0 {
 				m.selectedItem++
-				if m.selectedItem >= len(m.services) {
+				if m.selectedItem >= len(services) {
 					m.selectedItem = 0
 				}
 			}
 			return m, nil
 
 		case key.Matches(msg, key.NewBinding(key.WithKeys("enter", " "))):
-			if len(m.services) > 0 && m.selectedItem < len(m.services) {
-				service := m.services[m.selectedItem]
+			if len(services) > 0 && m.selectedItem < len(services) {
+				service := services[m.selectedItem]
 				m.testingService = service.Name
 				m.error = ""
 				return m, tea.Batch(
@@ -200,8 +333,8 @@ func (m *DatabaseModel) Update(msg tea.Msg) (*DatabaseModel, tea.Cmd) {
 
 		case key.Matches(msg, key.NewBinding(key.WithKeys("R"))):
 			// Force reharvest schema for selected service
-			if len(m.services) > 0 && m.selectedItem < len(m.services) {
-				service := m.services[m.selectedItem]
+			if len(services) > 0 && m.selectedItem < len(services) {
+				service := services[m.selectedItem]
 				// Invalidate cache for this service
 				if m.cfg != nil {
 					delete(m.cfg.CachedSchemas, service.Name)
@@ -218,8 +351,8 @@ func (m *DatabaseModel) Update(msg tea.Msg) (*DatabaseModel, tea.Cmd) {
 
 		case key.Matches(msg, key.NewBinding(key.WithKeys("e"))):
 			// Edit selected service
-			if len(m.services) > 0 && m.selectedItem < len(m.services) {
-				service := m.services[m.selectedItem]
+			if len(services) > 0 && m.selectedItem < len(services) {
+				service := services[m.selectedItem]
 				return m, func() tea.Msg {
 					return editServiceMsg{service: &service}
 				}
@@ -231,6 +364,10 @@ func (m *DatabaseModel) Update(msg tea.Msg) (*DatabaseModel, tea.Cmd) {
 			return m, func() tea.Msg {
 				return newServiceMsg{}
 			}
+
+		case key.Matches(msg, key.NewBinding(key.WithKeys("t"))):
+			m.cycleTagFilter()
+			return m, nil
 		}
 	}
 
@@ -245,7 +382,7 @@ func (m *DatabaseModel) View() string {
 
 	header := RenderHeader("Database Connections")
 	content := m.renderContent()
-	helpText := "↑/k: up • ↓/j: down • enter: connect • n: new • e: edit • r: refresh • R: reharvest • esc: back"
+	helpText := "↑/k: up • ↓/j: down • enter: connect • n: new • e: edit • t: filter by tag • r: refresh • R: reharvest • esc: back"
 	footer := RenderHelpFooter(helpText, m.width)
 
 	return LayoutWithHeaderFooter(header, content, footer, m.width, m.height)
@@ -259,7 +396,11 @@ func (m *DatabaseModel) renderContent() string {
 		Foreground(ColorGray).
 		Italic(true).
 		Align(lipgloss.Center)
-	sections = append(sections, subtitleStyle.Render("Select a database service from pg_service.conf"))
+	subtitle := "Select a database service from pg_service.conf"
+	if m.tagFilter != "" {
+		subtitle = fmt.Sprintf("Services tagged %q (t: next tag • esc: all services)", m.tagFilter)
+	}
+	sections = append(sections, subtitleStyle.Render(subtitle))
 	sections = append(sections, "")
 
 	// Loading state
@@ -301,6 +442,11 @@ func (m *DatabaseModel) renderContent() string {
 		sections = append(sections, noServicesStyle.Render("Create ~/.pg_service.conf with your database connections"))
 	} else {
 		sections = append(sections, m.renderServicesList())
+		services := m.visibleServices()
+		if m.selectedItem < len(services) && services[m.selectedItem].IsProduction() {
+			sections = append(sections, "", productionBadge()+ErrorStyle.Render(
+				" "+services[m.selectedItem].Name+" is a production database; take care with what you run"))
+		}
 	}
 
 	// Legend
@@ -308,14 +454,14 @@ func (m *DatabaseModel) renderContent() string {
 	legendStyle := lipgloss.NewStyle().
 		Foreground(ColorGray).
 		Align(lipgloss.Center)
-	sections = append(sections, legendStyle.Render("● cached schema  ○ not harvested"))
+	sections = append(sections, legendStyle.Render("● cached schema  ○ not harvested  •  tag services with a '# tags: prod, gis' comment"))
 
 	return lipgloss.JoinVertical(lipgloss.Center, sections...)
 }
 
 func (m *DatabaseModel) renderServicesList() string {
 	// Table styles
-	tableWidth := 70
+	tableWidth := 86
 	borderStyle := lipgloss.NewStyle().
 		Foreground(ColorOrange)
 
@@ -332,6 +478,8 @@ func (m *DatabaseModel) renderServicesList() string {
 		borderStyle.Render(repeatChar("─", 25)) +
 		borderStyle.Render("┬") +
 		borderStyle.Render(repeatChar("─", 15)) +
+		borderStyle.Render("┬") +
+		borderStyle.Render(repeatChar("─", 16)) +
 		borderStyle.Render("┐")
 
 	headerContent := borderStyle.Render("│") +
@@ -342,6 +490,8 @@ func (m *DatabaseModel) renderServicesList() string {
 		headerStyle.Render(padRight(" Host", 25)) +
 		borderStyle.Render("│") +
 		headerStyle.Render(padRight(" Database", 15)) +
+		borderStyle.Render("│") +
+		headerStyle.Render(padRight(" Tags", 16)) +
 		borderStyle.Render("│")
 
 	separatorLine := borderStyle.Render("├") +
@@ -352,6 +502,8 @@ func (m *DatabaseModel) renderServicesList() string {
 		borderStyle.Render(repeatChar("─", 25)) +
 		borderStyle.Render("┼") +
 		borderStyle.Render(repeatChar("─", 15)) +
+		borderStyle.Render("┼") +
+		borderStyle.Render(repeatChar("─", 16)) +
 		borderStyle.Render("┤")
 
 	var rows []string
@@ -359,8 +511,21 @@ func (m *DatabaseModel) renderServicesList() string {
 	rows = append(rows, headerContent)
 	rows = append(rows, separatorLine)
 
-	// Build service rows
-	for i, service := range m.services {
+	// Build service rows, under a heading for each group when services are
+	// tagged and not filtered
+	services := m.visibleServices()
+	showGroups := m.tagFilter == "" && len(serviceTags(services)) > 0
+	groupStyle := lipgloss.NewStyle().Foreground(ColorGray).Bold(true)
+	for i, service := range services {
+		if group := serviceGroup(service); showGroups && (i == 0 || group != serviceGroup(services[i-1])) {
+			if group == "" {
+				group = "untagged"
+			}
+			rows = append(rows, borderStyle.Render("│")+
+				groupStyle.Render(padRight(" "+strings.ToUpper(group), 83))+
+				borderStyle.Render("│"))
+		}
+
 		// Check if schema is cached
 		isCached := m.cfg != nil && m.cfg.IsSchemaCacheValid(service.Name)
 
@@ -389,6 +554,12 @@ func (m *DatabaseModel) renderServicesList() string {
 			nameStyle = lipgloss.NewStyle().Foreground(ColorOrange).Bold(true)
 		}
 
+		// Production services stand out in red
+		tagStyle := lipgloss.NewStyle().Foreground(ColorGray)
+		if service.IsProduction() {
+			tagStyle = lipgloss.NewStyle().Foreground(ColorRed).Bold(true)
+		}
+
 		// Build the first cell content: selector + status icon (fixed width cell)
 		// We render each part separately and then combine
 		firstCell := selector + statusIcon + " "
@@ -401,6 +572,8 @@ func (m *DatabaseModel) renderServicesList() string {
 			lipgloss.NewStyle().Foreground(ColorWhite).Render(padRight(" "+truncateStr(service.Host, 23), 25)) +
 			borderStyle.Render("│") +
 			lipgloss.NewStyle().Foreground(ColorWhite).Render(padRight(" "+truncateStr(service.DBName, 13), 15)) +
+			borderStyle.Render("│") +
+			tagStyle.Render(padRight(" "+truncateStr(strings.Join(service.Tags, ","), 14), 16)) +
 			borderStyle.Render("│")
 
 		rows = append(rows, row)
@@ -415,6 +588,8 @@ func (m *DatabaseModel) renderServicesList() string {
 		borderStyle.Render(repeatChar("─", 25)) +
 		borderStyle.Render("┴") +
 		borderStyle.Render(repeatChar("─", 15)) +
+		borderStyle.Render("┴") +
+		borderStyle.Render(repeatChar("─", 16)) +
 		borderStyle.Render("┘")
 	rows = append(rows, footerLine)
 
@@ -426,6 +601,15 @@ func (m *DatabaseModel) renderServicesList() string {
 	return tableContainer.Render(lipgloss.JoinVertical(lipgloss.Left, rows...))
 }
 
+// productionBadge renders the red badge marking a production service
+func productionBadge() string {
+	return lipgloss.NewStyle().
+		Foreground(ColorOnAccent).
+		Background(ColorRed).
+		Bold(true).
+		Render(" PROD ")
+}
+
 // Helper functions for table rendering
 func repeatChar(char string, count int) string {
 	result := ""
@@ -435,20 +619,14 @@ func repeatChar(char string, count int) string {
 	return result
 }
 
+// padRight pads s with spaces to length terminal columns, cutting it when
+// wider
 func padRight(s string, length int) string {
-	if len(s) >= length {
-		return s[:length]
-	}
-	result := s
-	for len(result) < length {
-		result += " "
-	}
-	return result
+	return runewidth.FillRight(runewidth.Truncate(s, length, ""), length)
 }
 
+// truncateStr cuts s to maxLen terminal columns, ending it with ".." when
+// cut
 func truncateStr(s string, maxLen int) string {
-	if len(s) > maxLen {
-		return s[:maxLen-2] + ".."
-	}
-	return s
+	return runewidth.Truncate(s, maxLen, "..")
 }
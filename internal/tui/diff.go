@@ -0,0 +1,250 @@
+package tui
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/kartoza/kartoza-pg-ai/internal/config"
+	"github.com/kartoza/kartoza-pg-ai/internal/postgres"
+	"github.com/mattn/go-runewidth"
+)
+
+// maxDiffCellWidth caps the width of a column in the diff table
+const maxDiffCellWidth = 30
+
+// resultDiff holds the rows that differ between an earlier and a later
+// result with the same columns
+type resultDiff struct {
+	*postgres.RowDiff
+	partial bool // Either result has rows not yet fetched, which are not compared
+}
+
+// diffResults compares the loaded rows of two results, matching rows by
+// the key columns (whole rows when key is nil). The results must have the
+// same columns, in any order.
+func diffResults(before, after *QueryResults, key []string) (*resultDiff, error) {
+	d, err := postgres.DiffRows(before.Columns, before.Rows, after.Columns, after.Rows, key)
+	if err != nil {
+		return nil, err
+	}
+	return &resultDiff{RowDiff: d, partial: before.HasMoreRows() || after.HasMoreRows()}, nil
+}
+
+// diffKey returns the columns identifying rows of results read by sql: the
+// primary key of a table the SQL names whose key columns are all in the
+// results, else an "id" column, else nil to match whole rows
+func diffKey(columns []string, sql string, schema *config.SchemaCache) []string {
+	have := make(map[string]bool, len(columns))
+	for _, col := range columns {
+		have[col] = true
+	}
+	if schema != nil {
+		lower := strings.ToLower(sql)
+		for _, t := range schema.Tables {
+			if !regexp.MustCompile(`\b` + regexp.QuoteMeta(strings.ToLower(t.Name)) + `\b`).MatchString(lower) {
+				continue
+			}
+			var pk []string
+			for _, c := range t.Columns {
+				if c.IsPrimaryKey {
+					pk = append(pk, c.Name)
+				}
+			}
+			complete := len(pk) > 0
+			for _, c := range pk {
+				complete = complete && have[c]
+			}
+			if complete {
+				return pk
+			}
+		}
+	}
+	if have["id"] {
+		return []string{"id"}
+	}
+	return nil
+}
+
+// toggleDiff marks the selected entry as the first of two to compare, or
+// compares it with the entry marked before
+func (m *QueryModel) toggleDiff() {
+	if m.selectedEntry < 0 || m.selectedEntry >= len(m.history) {
+		return
+	}
+	if m.history[m.selectedEntry].Results == nil || len(m.history[m.selectedEntry].Results.Columns) == 0 {
+		m.statusMsg = "✗ Only entries with results can be compared"
+		return
+	}
+	if m.diffFrom == nil || *m.diffFrom >= len(m.history) {
+		from := m.selectedEntry
+		m.diffFrom = &from
+		m.statusMsg = "Diff: select the entry to compare with (Tab) and press D"
+		return
+	}
+
+	from := *m.diffFrom
+	m.diffFrom = nil
+	if from == m.selectedEntry {
+		m.statusMsg = ""
+		return
+	}
+	first, second := min(from, m.selectedEntry), max(from, m.selectedEntry)
+	before, after := m.history[first].Results, m.history[second].Results
+	d, err := diffResults(before, after, diffKey(after.Columns, after.ExecutedSQL(), m.schema))
+	if err != nil {
+		m.statusMsg = "✗ Cannot compare: " + err.Error()
+		return
+	}
+	m.statusMsg = ""
+	m.diffView = NewDiffViewModel(d, m.history[first].Query, m.history[second].Query, m.width, m.height)
+}
+
+// DiffViewModel shows the rows that differ between two results as a
+// table: added rows in green, removed rows in red and changed values in
+// orange as "before → after"
+type DiffViewModel struct {
+	width     int
+	height    int
+	diff      *resultDiff
+	before    string // Questions of the compared entries
+	after     string
+	offset    int // First change shown
+	colOffset int // First column shown
+}
+
+// NewDiffViewModel creates a view of a diff between two entries' results
+func NewDiffViewModel(d *resultDiff, before, after string, width, height int) *DiffViewModel {
+	return &DiffViewModel{width: width, height: height, diff: d, before: before, after: after}
+}
+
+// visibleRows is the number of changed rows that fit on screen
+func (m *DiffViewModel) visibleRows() int {
+	return max(m.height-16, 3)
+}
+
+// Update handles scrolling; closing is left to the parent
+func (m *DiffViewModel) Update(msg tea.Msg) (*DiffViewModel, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		m.height = msg.Height
+
+	case tea.KeyMsg:
+		maxOffset := max(len(m.diff.Changes)-m.visibleRows(), 0)
+		switch msg.String() {
+		case "up", "k":
+			m.offset = max(m.offset-1, 0)
+		case "down", "j":
+			m.offset = min(m.offset+1, maxOffset)
+		case "pgup":
+			m.offset = max(m.offset-m.visibleRows(), 0)
+		case "pgdown", " ":
+			m.offset = min(m.offset+m.visibleRows(), maxOffset)
+		case "home", "g":
+			m.offset = 0
+		case "end", "G":
+			m.offset = maxOffset
+		case "left", "h":
+			m.colOffset = max(m.colOffset-1, 0)
+		case "right", "l":
+			m.colOffset = min(m.colOffset+1, len(m.diff.Columns)-1)
+		}
+	}
+	return m, nil
+}
+
+// fitCell pads or truncates s to width terminal columns, on one line
+func fitCell(s string, width int) string {
+	return padOrTruncate(strings.ReplaceAll(s, "\n", " "), width)
+}
+
+// View renders the diff screen
+func (m *DiffViewModel) View() string {
+	header := RenderHeader("Result Diff")
+	d := m.diff
+
+	labelStyle := lipgloss.NewStyle().Foreground(ColorGray)
+	addedStyle := lipgloss.NewStyle().Foreground(ColorGreen)
+	removedStyle := lipgloss.NewStyle().Foreground(ColorRed)
+	changedStyle := lipgloss.NewStyle().Foreground(ColorOrange)
+	headStyle := lipgloss.NewStyle().Foreground(ColorCyan).Bold(true)
+
+	keyText := "whole rows (no key column found)"
+	if d.Key != nil {
+		keyText = strings.Join(d.Key, ", ")
+	}
+	lines := []string{
+		labelStyle.Render("Before: ") + truncate(m.before, max(m.width-20, 10)),
+		labelStyle.Render("After:  ") + truncate(m.after, max(m.width-20, 10)),
+		labelStyle.Render("Rows matched by: ") + keyText,
+		addedStyle.Render(fmt.Sprintf("+%d added", d.Count(postgres.RowAdded))) + "  " +
+			removedStyle.Render(fmt.Sprintf("-%d removed", d.Count(postgres.RowRemoved))) + "  " +
+			changedStyle.Render(fmt.Sprintf("~%d changed", d.Count(postgres.RowChanged))) + "  " +
+			labelStyle.Render(fmt.Sprintf("%d unchanged", d.Unchanged)),
+	}
+	if d.partial {
+		lines = append(lines, labelStyle.Italic(true).Render("Only the rows loaded are compared; press n on an entry to load more first"))
+	}
+	lines = append(lines, "")
+
+	if len(d.Changes) == 0 {
+		lines = append(lines, addedStyle.Render("✓ The results are the same"))
+	} else {
+		// Column widths from the header and the changes shown
+		shown := d.Changes[m.offset:min(m.offset+m.visibleRows(), len(d.Changes))]
+		widths := make([]int, len(d.Columns))
+		for i, col := range d.Columns {
+			widths[i] = runewidth.StringWidth(col)
+			for _, c := range shown {
+				widths[i] = max(widths[i], runewidth.StringWidth(c.Cell(i)))
+			}
+			widths[i] = min(max(widths[i], 3), maxDiffCellWidth)
+		}
+		var cols []int
+		used := 2
+		for i := m.colOffset; i < len(d.Columns); i++ {
+			if len(cols) > 0 && used+widths[i]+2 > m.width-8 {
+				break
+			}
+			cols = append(cols, i)
+			used += widths[i] + 2
+		}
+
+		head := "  "
+		for _, i := range cols {
+			head += fitCell(d.Columns[i], widths[i]) + "  "
+		}
+		lines = append(lines, headStyle.Render(head))
+		for _, c := range shown {
+			marker, style := "+ ", addedStyle
+			switch c.Kind {
+			case postgres.RowRemoved:
+				marker, style = "- ", removedStyle
+			case postgres.RowChanged:
+				marker, style = "~ ", lipgloss.NewStyle().Foreground(ColorWhite)
+			}
+			line := style.Render(marker)
+			for _, i := range cols {
+				cell := fitCell(c.Cell(i), widths[i])
+				if c.CellChanged(i) {
+					line += changedStyle.Bold(true).Render(cell) + "  "
+				} else {
+					line += style.Render(cell) + "  "
+				}
+			}
+			lines = append(lines, line)
+		}
+		if len(d.Changes) > len(shown) {
+			lines = append(lines, "", labelStyle.Italic(true).Render(fmt.Sprintf("Changes %d-%d of %d",
+				m.offset+1, m.offset+len(shown), len(d.Changes))))
+		}
+	}
+
+	content := BoxStyle.Width(m.width - 6).Render(lipgloss.JoinVertical(lipgloss.Left, lines...))
+	helpText := "j/k: scroll • ←/→: columns • g/G: top/bottom • esc/q: close"
+	footer := RenderHelpFooter(helpText, m.width)
+	return LayoutWithHeaderFooter(header, content, footer, m.width, m.height)
+}
@@ -0,0 +1,68 @@
+package tui
+
+import (
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/kartoza/kartoza-pg-ai/internal/config"
+)
+
+// draftSaveDelay is how long typing pauses before the draft is saved
+const draftSaveDelay = time.Second
+
+// draftSaveMsg asks for the draft to be saved once typing has paused
+type draftSaveMsg struct {
+	seq int // Change the save was scheduled for
+}
+
+// restoreDraft puts the question left half written for the service back
+// into the editor
+func (m *QueryModel) restoreDraft() {
+	if m.service == nil {
+		return
+	}
+	text, err := config.LoadDraft(m.service.Name)
+	if err != nil {
+		debugLog("restoreDraft: " + err.Error())
+		return
+	}
+	if text != "" {
+		m.SetInitialQuery(text)
+	}
+	m.draft = text
+}
+
+// trackDraft schedules saving the question in the editor when it has
+// changed since the last save. SQL being edited is not a draft.
+func (m *QueryModel) trackDraft() tea.Cmd {
+	if m.service == nil || m.sqlEdit != nil {
+		return nil
+	}
+	text := m.getEditorText()
+	if text == m.draft {
+		return nil
+	}
+	m.draft = text
+	m.draftSeq++
+	seq := m.draftSeq
+	return tea.Tick(draftSaveDelay, func(time.Time) tea.Msg {
+		return draftSaveMsg{seq: seq}
+	})
+}
+
+// saveDraft saves the draft unless it changed again after the save was
+// scheduled. Errors are only logged; saving is best effort.
+func (m *QueryModel) saveDraft(msg draftSaveMsg) {
+	if msg.seq != m.draftSeq || m.service == nil {
+		return
+	}
+	if err := config.SaveDraft(m.service.Name, m.draft); err != nil {
+		debugLog("saveDraft: " + err.Error())
+	}
+}
+
+// flushDraft saves the draft straight away, before leaving the query screen
+func (m *QueryModel) flushDraft() {
+	m.trackDraft()
+	m.saveDraft(draftSaveMsg{seq: m.draftSeq})
+}
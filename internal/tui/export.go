@@ -0,0 +1,334 @@
+package tui
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/kartoza/kartoza-pg-ai/internal/postgres"
+)
+
+// ExportFormat identifies a result export file format
+type ExportFormat string
+
+const (
+	ExportCSV     ExportFormat = "csv"
+	ExportJSON    ExportFormat = "json"
+	ExportGeoJSON ExportFormat = "geojson"
+	ExportGPKG    ExportFormat = "gpkg"
+)
+
+// geoJSONColumn is the alias used for the ST_AsGeoJSON output when exporting
+const geoJSONColumn = "__export_geojson"
+
+// exportCompletedMsg indicates an export finished
+type exportCompletedMsg struct {
+	path      string
+	rows      int
+	err       error
+	cancelled bool // ctx was cancelled, by ctrl+c
+}
+
+// exportFileName returns a timestamped file name in the working directory
+func exportFileName(format ExportFormat) string {
+	name := fmt.Sprintf("kartoza-pg-ai-export-%s.%s", time.Now().Format("20060102-150405"), format)
+	if wd, err := os.Getwd(); err == nil {
+		return filepath.Join(wd, name)
+	}
+	return name
+}
+
+// startExport starts exporting results as a query that ctrl+c cancels
+func (m *QueryModel) startExport(results *QueryResults, format ExportFormat, name string) tea.Cmd {
+	m.statusMsg = "Exporting " + name + "... (ctrl+c: cancel)"
+	m.loading = true
+	return m.exportResults(m.newQueryContext(), results, format)
+}
+
+// exportResults re-runs the SQL without LIMIT and streams every row to a
+// file, in a read-only transaction bound by the service's statement timeout.
+// Cancelling ctx stops the export.
+func (m *QueryModel) exportResults(ctx context.Context, results *QueryResults, format ExportFormat) tea.Cmd {
+	db := m.database()
+	ctx = postgres.WithStatementTimeout(ctx, m.statementTimeout())
+	return func() tea.Msg {
+		msg := exportQueryResults(ctx, db, results, format)
+		msg.cancelled = ctx.Err() != nil
+		return msg
+	}
+}
+
+// exportQueryResults runs the export exportResults starts
+func exportQueryResults(ctx context.Context, db *sql.DB, results *QueryResults, format ExportFormat) exportCompletedMsg {
+	if db == nil {
+		return exportCompletedMsg{err: fmt.Errorf("no database connection")}
+	}
+	if results == nil || results.ExecutedSQL() == "" {
+		return exportCompletedMsg{err: fmt.Errorf("no results to export")}
+	}
+
+	tx, err := postgres.BeginTx(ctx, db, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return exportCompletedMsg{err: fmt.Errorf("export query failed: %w", err)}
+	}
+	defer tx.Rollback()
+
+	query := results.ExecutedSQL()
+	geomCol := ""
+	var ref spatialRef
+	if format == ExportGeoJSON || format == ExportGPKG {
+		if results.GeometryColIdx < 0 || results.GeometryColIdx >= len(results.Columns) {
+			return exportCompletedMsg{err: fmt.Errorf("%s export needs a geometry column", format)}
+		}
+		geomCol = results.Columns[results.GeometryColIdx]
+
+		// The CRS comes from the column SRID so QGIS places the layer correctly
+		boundSource, args, err := postgres.BindTemplate(query, results.Params)
+		if err != nil {
+			return exportCompletedMsg{err: err}
+		}
+		if ref, err = lookupSpatialRef(ctx, tx, boundSource, args, geomCol); err != nil {
+			return exportCompletedMsg{err: err}
+		}
+
+		if format == ExportGPKG {
+			query = gpkgExportQuery(query, geomCol)
+		} else {
+			// Let PostGIS produce the geometry JSON; the cast also accepts WKT text columns
+			query = fmt.Sprintf("SELECT export_query.*, ST_AsGeoJSON(export_query.\"%s\"::geometry) AS %s FROM (%s) AS export_query",
+				geomCol, geoJSONColumn, results.ExecutedSQL())
+		}
+	}
+
+	boundQuery, args, err := postgres.BindTemplate(query, results.Params)
+	if err != nil {
+		return exportCompletedMsg{err: err}
+	}
+	rows, err := tx.QueryContext(ctx, boundQuery, args...)
+	if err != nil {
+		return exportCompletedMsg{err: fmt.Errorf("export query failed: %w", err)}
+	}
+	defer rows.Close()
+
+	path := exportFileName(format)
+	if format == ExportGPKG {
+		// SQLite writes the file itself
+		count, err := writeGeoPackage(path, rows, geomCol, ref, results.NaturalQuery, results.ExecutedSQL())
+		if err != nil {
+			os.Remove(path)
+			return exportCompletedMsg{err: err}
+		}
+		return exportCompletedMsg{path: path, rows: count}
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return exportCompletedMsg{err: err}
+	}
+
+	w := bufio.NewWriter(f)
+	var count int
+	switch format {
+	case ExportCSV:
+		count, err = writeCSV(w, rows)
+	case ExportJSON:
+		count, err = writeJSON(w, rows)
+	case ExportGeoJSON:
+		count, err = writeGeoJSON(w, rows, geomCol, ref)
+	default:
+		err = fmt.Errorf("unknown export format: %s", format)
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return exportCompletedMsg{err: err}
+	}
+
+	return exportCompletedMsg{path: path, rows: count}
+}
+
+// scanRow scans the current row into a slice of raw values
+func scanRow(rows *sql.Rows, colCount int) ([]interface{}, error) {
+	values := make([]interface{}, colCount)
+	valuePtrs := make([]interface{}, colCount)
+	for i := range values {
+		valuePtrs[i] = &values[i]
+	}
+	if err := rows.Scan(valuePtrs...); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// jsonValue converts a scanned value into something encoding/json renders sensibly
+func jsonValue(val interface{}) interface{} {
+	switch v := val.(type) {
+	case []byte:
+		return string(v)
+	case time.Time:
+		return v.Format(time.RFC3339)
+	default:
+		return v
+	}
+}
+
+// writeCSV streams rows as CSV with a header line
+func writeCSV(w io.Writer, rows *sql.Rows) (int, error) {
+	columns, err := rows.Columns()
+	if err != nil {
+		return 0, err
+	}
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write(columns); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	record := make([]string, len(columns))
+	for rows.Next() {
+		values, err := scanRow(rows, len(columns))
+		if err != nil {
+			return count, err
+		}
+		for i, val := range values {
+			if val == nil {
+				record[i] = ""
+			} else {
+				record[i] = postgres.FormatValue(val)
+			}
+		}
+		if err := cw.Write(record); err != nil {
+			return count, err
+		}
+		count++
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return count, err
+	}
+	return count, rows.Err()
+}
+
+// writeJSON streams rows as a JSON array of objects
+func writeJSON(w io.Writer, rows *sql.Rows) (int, error) {
+	columns, err := rows.Columns()
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := io.WriteString(w, "[\n"); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for rows.Next() {
+		values, err := scanRow(rows, len(columns))
+		if err != nil {
+			return count, err
+		}
+		obj := make(map[string]interface{}, len(columns))
+		for i, col := range columns {
+			obj[col] = jsonValue(values[i])
+		}
+		data, err := json.Marshal(obj)
+		if err != nil {
+			return count, err
+		}
+		if count > 0 {
+			io.WriteString(w, ",\n")
+		}
+		if _, err := w.Write(data); err != nil {
+			return count, err
+		}
+		count++
+	}
+	if err := rows.Err(); err != nil {
+		return count, err
+	}
+
+	_, err = io.WriteString(w, "\n]\n")
+	return count, err
+}
+
+// writeGeoJSON streams rows as a GeoJSON FeatureCollection. The geometry comes
+// from the ST_AsGeoJSON column; the source geometry column is left out of properties.
+// A known SRID is written as a named "crs" member, which QGIS and GDAL honour.
+func writeGeoJSON(w io.Writer, rows *sql.Rows, geomCol string, ref spatialRef) (int, error) {
+	columns, err := rows.Columns()
+	if err != nil {
+		return 0, err
+	}
+
+	header := "{\"type\":\"FeatureCollection\","
+	if ref.srid > 0 {
+		crs, err := json.Marshal(map[string]interface{}{
+			"type":       "name",
+			"properties": map[string]string{"name": ref.name()},
+		})
+		if err != nil {
+			return 0, err
+		}
+		header += "\"crs\":" + string(crs) + ","
+	}
+	if _, err := io.WriteString(w, header+"\"features\":[\n"); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for rows.Next() {
+		values, err := scanRow(rows, len(columns))
+		if err != nil {
+			return count, err
+		}
+
+		var geometry json.RawMessage = json.RawMessage("null")
+		properties := make(map[string]interface{}, len(columns))
+		for i, col := range columns {
+			switch col {
+			case geoJSONColumn:
+				if values[i] != nil {
+					geometry = json.RawMessage(postgres.FormatValue(values[i]))
+				}
+			case geomCol:
+				// Represented by the feature geometry
+			default:
+				properties[col] = jsonValue(values[i])
+			}
+		}
+
+		data, err := json.Marshal(map[string]interface{}{
+			"type":       "Feature",
+			"geometry":   geometry,
+			"properties": properties,
+		})
+		if err != nil {
+			return count, err
+		}
+		if count > 0 {
+			io.WriteString(w, ",\n")
+		}
+		if _, err := w.Write(data); err != nil {
+			return count, err
+		}
+		count++
+	}
+	if err := rows.Err(); err != nil {
+		return count, err
+	}
+
+	_, err = io.WriteString(w, "\n]}\n")
+	return count, err
+}
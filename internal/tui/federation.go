@@ -0,0 +1,366 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/kartoza/kartoza-pg-ai/internal/config"
+	"github.com/kartoza/kartoza-pg-ai/internal/postgres"
+)
+
+// federationStep is a step of the federation wizard
+type federationStep int
+
+const (
+	federationStepSource federationStep = iota // Choosing the service to link
+	federationStepReview                       // Naming the server and schemas
+	federationStepRun                          // Setting up postgres_fdw
+	federationStepDone
+)
+
+// Inputs of the review step, in tab order
+const (
+	federationServer = iota
+	federationRemoteSchema
+	federationLocalSchema
+)
+
+// federationDoneMsg carries the outcome of setting up the federation
+type federationDoneMsg struct {
+	tables []string // Foreign tables of the local schema
+	err    error
+}
+
+// FederationModel is the wizard that links the tables of another service
+// into the active one with postgres_fdw, so questions can join them
+type FederationModel struct {
+	width   int
+	height  int
+	service *postgres.ServiceEntry // Local service the foreign tables are created on
+	conn    *postgres.ConnectionManager
+	cfg     *config.Config
+
+	step     federationStep
+	remotes  []postgres.ServiceEntry // Services that can be linked
+	selected int                     // Highlighted remote service
+	inputs   []textinput.Model
+	focused  int // Focused input of the review step, -1 for none
+	tables   []string
+	err      error
+	cancel   context.CancelFunc
+}
+
+// NewFederationModel creates the federation wizard for a service
+func NewFederationModel(service *postgres.ServiceEntry, cfg *config.Config) *FederationModel {
+	m := &FederationModel{
+		service: service,
+		conn:    sharedConnection(service, cfg),
+		cfg:     cfg,
+		focused: -1,
+	}
+	services, err := availableServices()
+	m.err = err
+	for _, s := range services {
+		if service == nil || s.Name != service.Name {
+			m.remotes = append(m.remotes, s)
+		}
+	}
+
+	placeholders := []string{"pgai_prod", "public", "prod"}
+	m.inputs = make([]textinput.Model, len(placeholders))
+	for i, placeholder := range placeholders {
+		m.inputs[i] = textinput.New()
+		m.inputs[i].Placeholder = placeholder
+		m.inputs[i].CharLimit = 63
+		m.inputs[i].Width = 30
+		m.inputs[i].Prompt = ""
+	}
+	return m
+}
+
+// Init initializes the federation wizard
+func (m *FederationModel) Init() tea.Cmd {
+	return textinput.Blink
+}
+
+// writeModeEnabled reports whether the local service may be written to
+func (m *FederationModel) writeModeEnabled() bool {
+	return m.cfg != nil && m.service != nil && m.cfg.SettingsFor(m.service.Name).WriteModeEnabled
+}
+
+// federation returns the federation as named in the review step
+func (m *FederationModel) federation() postgres.Federation {
+	return postgres.Federation{
+		Remote:       m.remotes[m.selected],
+		Server:       strings.TrimSpace(m.inputs[federationServer].Value()),
+		RemoteSchema: strings.TrimSpace(m.inputs[federationRemoteSchema].Value()),
+		LocalSchema:  strings.TrimSpace(m.inputs[federationLocalSchema].Value()),
+	}
+}
+
+// review moves to the review step with the names proposed for the
+// highlighted service
+func (m *FederationModel) review() {
+	f := postgres.NewFederation(m.remotes[m.selected])
+	m.inputs[federationServer].SetValue(f.Server)
+	m.inputs[federationRemoteSchema].SetValue(f.RemoteSchema)
+	m.inputs[federationLocalSchema].SetValue(f.LocalSchema)
+	m.step = federationStepReview
+	m.err = nil
+}
+
+// focus focuses input i of the review step, or none for -1
+func (m *FederationModel) focus(i int) tea.Cmd {
+	if m.focused >= 0 {
+		m.inputs[m.focused].Blur()
+	}
+	m.focused = i
+	if i < 0 {
+		return nil
+	}
+	m.inputs[i].Focus()
+	return textinput.Blink
+}
+
+// startFederation sets up postgres_fdw on the local service
+func (m *FederationModel) startFederation() tea.Cmd {
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancel = cancel
+	if m.cfg != nil && m.service != nil {
+		ctx = postgres.WithStatementTimeout(ctx, m.cfg.SettingsFor(m.service.Name).StatementTimeout())
+	}
+	f, conn := m.federation(), m.conn
+	return func() tea.Msg {
+		if conn == nil {
+			return federationDoneMsg{err: fmt.Errorf("no service configured")}
+		}
+		db, err := conn.Connect(ctx)
+		if err != nil {
+			return federationDoneMsg{err: err}
+		}
+		tables, err := postgres.Federate(ctx, db, f)
+		return federationDoneMsg{tables: tables, err: err}
+	}
+}
+
+// Update handles messages for the federation wizard
+func (m *FederationModel) Update(msg tea.Msg) (*FederationModel, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		m.height = msg.Height
+		return m, nil
+
+	case federationDoneMsg:
+		m.step = federationStepDone
+		m.tables = msg.tables
+		m.err = msg.err
+		return m, nil
+
+	case tea.KeyMsg:
+		return m.handleKey(msg)
+	}
+
+	// Keep the cursor of the focused input blinking
+	var cmd tea.Cmd
+	if m.step == federationStepReview && m.focused >= 0 {
+		m.inputs[m.focused], cmd = m.inputs[m.focused].Update(msg)
+	}
+	return m, cmd
+}
+
+// handleKey handles key presses for the current step
+func (m *FederationModel) handleKey(msg tea.KeyMsg) (*FederationModel, tea.Cmd) {
+	toMenu := func() tea.Msg { return goToMenuMsg{} }
+
+	switch m.step {
+	case federationStepSource:
+		switch msg.String() {
+		case "ctrl+c", "esc":
+			return m, toMenu
+		case "up", "k":
+			if m.selected > 0 {
+				m.selected--
+			}
+		case "down", "j":
+			if m.selected < len(m.remotes)-1 {
+				m.selected++
+			}
+		case "enter":
+			if len(m.remotes) > 0 {
+				m.review()
+			}
+		}
+		return m, nil
+
+	case federationStepReview:
+		switch msg.Type {
+		case tea.KeyCtrlC:
+			return m, toMenu
+		case tea.KeyEscape:
+			m.step = federationStepSource
+			m.err = nil
+			return m, m.focus(-1)
+		case tea.KeyTab:
+			return m, m.focus((m.focused+2)%(len(m.inputs)+1) - 1)
+		case tea.KeyShiftTab:
+			return m, m.focus((m.focused+len(m.inputs)+1)%(len(m.inputs)+1) - 1)
+		case tea.KeyEnter:
+			if !m.writeModeEnabled() {
+				m.err = fmt.Errorf("linking a database creates a server and schema: enable Write Mode in settings or this service's profile")
+				return m, nil
+			}
+			m.err = nil
+			m.step = federationStepRun
+			m.focus(-1)
+			return m, m.startFederation()
+		}
+		if m.focused >= 0 {
+			var cmd tea.Cmd
+			m.inputs[m.focused], cmd = m.inputs[m.focused].Update(msg)
+			return m, cmd
+		}
+		return m, nil
+
+	case federationStepRun:
+		if msg.Type == tea.KeyCtrlC || msg.Type == tea.KeyEscape {
+			// The transaction rolls back, leaving nothing behind
+			m.cancel()
+		}
+		return m, nil
+
+	case federationStepDone:
+		switch {
+		case msg.Type == tea.KeyEnter && m.err == nil:
+			return m, func() tea.Msg { return importFinishedMsg{} }
+		case msg.Type == tea.KeyEnter:
+			m.step = federationStepReview
+			return m, nil
+		case msg.Type == tea.KeyCtrlC || msg.Type == tea.KeyEscape:
+			return m, toMenu
+		}
+	}
+	return m, nil
+}
+
+// View renders the federation wizard
+func (m *FederationModel) View() string {
+	if m.width == 0 || m.height == 0 {
+		return ""
+	}
+
+	header := RenderHeader("Link Remote Database")
+
+	var content, helpText string
+	switch m.step {
+	case federationStepSource:
+		content, helpText = m.renderSource(), "↑/↓: service • enter: choose • esc: back to menu"
+	case federationStepReview:
+		content = m.renderReview()
+		helpText = "tab: edit names • enter: link • esc: choose another service"
+	case federationStepRun:
+		content, helpText = lipgloss.NewStyle().Foreground(ColorOrange).Render("Setting up postgres_fdw..."), "esc: cancel (nothing is kept)"
+	case federationStepDone:
+		content = m.renderDone()
+		helpText = "enter: harvest the schema and query the linked tables • esc: back to menu"
+		if m.err != nil {
+			helpText = "enter: back to the names • esc: back to menu"
+		}
+	}
+
+	footer := RenderHelpFooter(helpText, m.width)
+	return LayoutWithHeaderFooter(header, content, footer, m.width, m.height)
+}
+
+// renderSource renders the services that can be linked
+func (m *FederationModel) renderSource() string {
+	labelStyle := lipgloss.NewStyle().Foreground(ColorOrange).Bold(true)
+	hintStyle := lipgloss.NewStyle().Foreground(ColorGray).Italic(true)
+	selectedStyle := lipgloss.NewStyle().Foreground(ColorOrange).Bold(true)
+
+	lines := []string{labelStyle.Render("Service to link into " + m.service.Name), ""}
+	if m.err != nil {
+		return lipgloss.JoinVertical(lipgloss.Left, append(lines, ErrorStyle.Render("✗ "+m.err.Error()))...)
+	}
+	if len(m.remotes) == 0 {
+		return lipgloss.JoinVertical(lipgloss.Left, append(lines, hintStyle.Render("There is no other service in pg_service.conf to link"))...)
+	}
+	for i, remote := range m.remotes {
+		line := fmt.Sprintf("%s  %s", remote.Name, hintStyle.Render(remote.Host+"/"+remote.DBName))
+		if i == m.selected {
+			lines = append(lines, selectedStyle.Render("▶ ")+line)
+		} else {
+			lines = append(lines, "  "+line)
+		}
+	}
+	lines = append(lines, "",
+		hintStyle.Render("Its tables become foreign tables of "+m.service.Name+" through postgres_fdw, so one"),
+		hintStyle.Render("question can join them with local data. The database server connects to the"),
+		hintStyle.Render("other one itself: its host must be reachable from there, not only from here."),
+	)
+	return lipgloss.JoinVertical(lipgloss.Left, lines...)
+}
+
+// renderReview renders the names of the federation and the SQL setting it
+// up, the password masked
+func (m *FederationModel) renderReview() string {
+	labelStyle := lipgloss.NewStyle().Foreground(ColorGray).Width(16)
+	labels := []string{"Server:", "Remote schema:", "Local schema:"}
+
+	lines := []string{
+		labelStyle.Render("Link:") + lipgloss.NewStyle().Foreground(ColorOrange).Bold(true).
+			Render(m.remotes[m.selected].Name+" → "+m.service.Name),
+	}
+	for i, label := range labels {
+		border := ColorDarkGray
+		if i == m.focused {
+			border = ColorOrange
+		}
+		lines = append(lines, labelStyle.Render(label)+lipgloss.NewStyle().
+			Border(lipgloss.NormalBorder(), false, false, true, false).
+			BorderForeground(border).
+			Render(m.inputs[i].View()))
+	}
+	lines = append(lines, "")
+
+	var body []string
+	for _, statement := range m.federation().Statements(true) {
+		body = append(body, highlightSQL(statement+";"))
+	}
+	lines = append(lines, lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(ColorDarkGray).
+		Padding(0, 1).
+		Render(lipgloss.JoinVertical(lipgloss.Left, body...)))
+
+	if !m.writeModeEnabled() {
+		lines = append(lines, ErrorStyle.Render("⚠ Write Mode is off for this service: enable it in settings to link"))
+	}
+	if m.err != nil {
+		lines = append(lines, ErrorStyle.Render("✗ "+m.err.Error()))
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, lines...)
+}
+
+// renderDone renders the outcome of the federation
+func (m *FederationModel) renderDone() string {
+	if m.err != nil {
+		return lipgloss.JoinVertical(lipgloss.Left,
+			ErrorStyle.Render("✗ Linking failed; nothing was created"),
+			"",
+			ErrorStyle.Render(m.err.Error()),
+		)
+	}
+	f := m.federation()
+	lines := []string{SuccessStyle.Render(fmt.Sprintf("✓ Linked %d tables of %s into schema %s",
+		len(m.tables), f.Remote.Name, f.LocalSchema))}
+	if len(m.tables) > 0 {
+		lines = append(lines, "", lipgloss.NewStyle().Foreground(ColorGray).
+			Render(truncate(strings.Join(m.tables, ", "), max(m.width-10, 20))))
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, lines...)
+}
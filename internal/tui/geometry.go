@@ -8,9 +8,14 @@ import (
 	"fmt"
 	"image"
 	"image/color"
+	"image/draw"
 	"image/png"
+	"io"
 	"math"
 	"strings"
+	"sync/atomic"
+
+	"github.com/kartoza/kartoza-pg-ai/internal/config"
 )
 
 // Geometry types for rendering
@@ -42,60 +47,152 @@ type GeometryCollection struct {
 	Geometries []interface{}
 }
 
+// Extent is a bounding box in data coordinates
+type Extent struct {
+	MinX, MinY, MaxX, MaxY float64
+}
+
+// Center returns the middle of the extent
+func (e Extent) Center() Point {
+	return Point{X: (e.MinX + e.MaxX) / 2, Y: (e.MinY + e.MaxY) / 2}
+}
+
 // GeometryRenderer renders geometries to PNG images
 type GeometryRenderer struct {
-	Width      int
-	Height     int
-	Padding    int
-	Background color.Color
-	LineColor  color.Color
-	FillColor  color.Color
-	PointColor color.Color
+	Width       int
+	Height      int
+	Padding     int
+	StrokeWidth float64 // Outline width in pixels
+	PointRadius float64 // Point radius in pixels
+	Background  color.NRGBA
+	LineColor   color.NRGBA
+	FillColor   color.NRGBA // Alpha sets the fill opacity
+	PointColor  color.NRGBA
+	Viewport    *Extent       // Extent to draw; nil fits all geometries
+	LayerColors []color.NRGBA // Colour of each layer; nil uses layerColors
+	Legend      *Legend       // Legend drawn over the map; nil for none
+}
+
+// GeometryStyle is how rendered geometries look
+type GeometryStyle struct {
+	StrokeWidth float64
+	PointRadius float64
+	Background  color.NRGBA
+	LineColor   color.NRGBA
+	FillColor   color.NRGBA
+	PointColor  color.NRGBA
+}
+
+// geometryStyle is the style new renderers use; see SetGeometryStyle
+var geometryStyle atomic.Pointer[GeometryStyle]
+
+// GeometryStyleFromSettings builds a style from the user's settings, using
+// the defaults for missing or unparseable values
+func GeometryStyleFromSettings(s config.Settings) GeometryStyle {
+	d := config.DefaultConfig().Settings
+	colour := func(value, fallback string, alpha float64) color.NRGBA {
+		c, ok := parseHexColor(value)
+		if !ok {
+			c, _ = parseHexColor(fallback)
+		}
+		c.A = uint8(math.Round(math.Max(0, math.Min(alpha, 1)) * 255))
+		return c
+	}
+	positive := func(v, fallback float64) float64 {
+		if v > 0 {
+			return v
+		}
+		return fallback
+	}
+	return GeometryStyle{
+		StrokeWidth: positive(s.GeometryStrokeWidth, d.GeometryStrokeWidth),
+		PointRadius: positive(s.GeometryPointSize, d.GeometryPointSize),
+		Background:  colour(s.GeometryBackground, d.GeometryBackground, 1),
+		LineColor:   colour(s.GeometryLineColor, d.GeometryLineColor, 1),
+		FillColor:   colour(s.GeometryFillColor, d.GeometryFillColor, s.GeometryFillOpacity),
+		PointColor:  colour(s.GeometryPointColor, d.GeometryPointColor, 1),
+	}
 }
 
-// NewGeometryRenderer creates a new renderer with default settings
+// SetGeometryStyle makes renderers created from now on use the style in settings
+func SetGeometryStyle(s config.Settings) {
+	style := GeometryStyleFromSettings(s)
+	geometryStyle.Store(&style)
+}
+
+// parseHexColor parses "#rrggbb" or "#rgb" into an opaque colour
+func parseHexColor(s string) (color.NRGBA, bool) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "#")
+	if len(s) == 3 {
+		s = string([]byte{s[0], s[0], s[1], s[1], s[2], s[2]})
+	}
+	if len(s) != 6 {
+		return color.NRGBA{}, false
+	}
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return color.NRGBA{}, false
+	}
+	return color.NRGBA{b[0], b[1], b[2], 255}, true
+}
+
+// NewGeometryRenderer creates a new renderer with the current geometry style
 func NewGeometryRenderer(width, height int) *GeometryRenderer {
+	style := geometryStyle.Load()
+	if style == nil {
+		defaults := GeometryStyleFromSettings(config.DefaultConfig().Settings)
+		style = &defaults
+	}
 	return &GeometryRenderer{
-		Width:      width,
-		Height:     height,
-		Padding:    10,
-		Background: color.RGBA{30, 30, 30, 255},     // Dark gray
-		LineColor:  color.RGBA{255, 165, 0, 255},    // Orange
-		FillColor:  color.RGBA{255, 165, 0, 80},     // Semi-transparent orange
-		PointColor: color.RGBA{0, 200, 255, 255},    // Cyan
+		Width:       width,
+		Height:      height,
+		Padding:     10,
+		StrokeWidth: style.StrokeWidth,
+		PointRadius: style.PointRadius,
+		Background:  style.Background,
+		LineColor:   style.LineColor,
+		FillColor:   style.FillColor,
+		PointColor:  style.PointColor,
 	}
 }
 
+// layerColors are the line colours used for each layer when several
+// geometry columns are overlaid; fills use the same colour, semi-transparent
+var layerColors = []color.NRGBA{
+	{255, 165, 0, 255},  // Orange
+	{0, 200, 255, 255},  // Cyan
+	{120, 220, 90, 255}, // Green
+	{230, 90, 200, 255}, // Magenta
+	{250, 230, 80, 255}, // Yellow
+}
+
 // RenderGeometries renders a slice of geometry values to a PNG image
 func (r *GeometryRenderer) RenderGeometries(geomValues []string) ([]byte, error) {
-	// Parse all geometries
-	var allGeoms []interface{}
-	for _, val := range geomValues {
-		geom, err := parseGeometry(val)
-		if err != nil {
-			continue // Skip unparseable geometries
-		}
-		if geom != nil {
-			allGeoms = append(allGeoms, geom)
-		}
-	}
+	return r.RenderLayers([][]string{geomValues})
+}
 
+// RenderLayers renders several sets of geometry values into one image with a
+// shared extent. A single layer uses the renderer colours; multiple layers
+// are drawn in order, each in its own colour from LayerColors or layerColors.
+func (r *GeometryRenderer) RenderLayers(layers [][]string) ([]byte, error) {
+	allGeoms, geomLayers := parseLayers(layers)
 	if len(allGeoms) == 0 {
 		return nil, fmt.Errorf("no valid geometries to render")
 	}
 
-	// Calculate bounding box
-	minX, minY, maxX, maxY := r.calculateBounds(allGeoms)
+	// Calculate bounding box, unless a viewport was given
+	var minX, minY, maxX, maxY float64
+	if r.Viewport != nil {
+		minX, minY, maxX, maxY = r.Viewport.MinX, r.Viewport.MinY, r.Viewport.MaxX, r.Viewport.MaxY
+	} else {
+		minX, minY, maxX, maxY = r.calculateBounds(allGeoms)
+	}
 
 	// Create image
 	img := image.NewRGBA(image.Rect(0, 0, r.Width, r.Height))
 
 	// Fill background
-	for y := 0; y < r.Height; y++ {
-		for x := 0; x < r.Width; x++ {
-			img.Set(x, y, r.Background)
-		}
-	}
+	draw.Draw(img, img.Bounds(), image.NewUniform(r.Background), image.Point{}, draw.Src)
 
 	// Calculate transform
 	dataWidth := maxX - minX
@@ -114,15 +211,40 @@ func (r *GeometryRenderer) RenderGeometries(geomValues []string) ([]byte, error)
 	offsetX := float64(r.Padding) + (drawWidth-dataWidth*scale)/2
 	offsetY := float64(r.Padding) + (drawHeight-dataHeight*scale)/2
 
-	transform := func(p Point) (int, int) {
-		x := int(offsetX + (p.X-minX)*scale)
-		y := int(float64(r.Height) - offsetY - (p.Y-minY)*scale) // Flip Y
-		return x, y
+	transform := func(p Point) Point {
+		return Point{
+			X: offsetX + (p.X-minX)*scale,
+			Y: float64(r.Height) - offsetY - (p.Y-minY)*scale, // Flip Y
+		}
 	}
 
-	// Render all geometries
-	for _, geom := range allGeoms {
-		r.renderGeometry(img, geom, transform)
+	// Render layer by layer: fills first, then outlines, then points
+	shapes := geometryShapes{
+		fill:   newShapeLayer(r.Width, r.Height),
+		stroke: newShapeLayer(r.Width, r.Height),
+		points: newShapeLayer(r.Width, r.Height),
+	}
+	for layer := range layers {
+		lineColor, fillColor, pointColor := r.LineColor, r.FillColor, r.PointColor
+		if len(layers) > 1 || r.LayerColors != nil {
+			c := layerColors[layer%len(layerColors)]
+			if r.LayerColors != nil {
+				c = r.LayerColors[layer%len(r.LayerColors)]
+			}
+			lineColor, pointColor = c, c
+			fillColor = color.NRGBA{c.R, c.G, c.B, r.FillColor.A}
+		}
+		for i, geom := range allGeoms {
+			if geomLayers[i] == layer {
+				r.addGeometry(shapes, geom, transform)
+			}
+		}
+		shapes.fill.paint(img, fillColor)
+		shapes.stroke.paint(img, lineColor)
+		shapes.points.paint(img, pointColor)
+	}
+	if r.Legend != nil {
+		r.drawLegend(img, *r.Legend)
 	}
 
 	// Encode to PNG
@@ -134,6 +256,38 @@ func (r *GeometryRenderer) RenderGeometries(geomValues []string) ([]byte, error)
 	return buf.Bytes(), nil
 }
 
+// parseLayers parses every layer's geometry values, returning the geometries
+// and the layer index of each
+func parseLayers(layers [][]string) ([]interface{}, []int) {
+	var allGeoms []interface{}
+	var geomLayers []int
+	for layer, geomValues := range layers {
+		for _, val := range geomValues {
+			geom, err := parseGeometry(val)
+			if err != nil {
+				continue // Skip unparseable geometries
+			}
+			if geom != nil {
+				allGeoms = append(allGeoms, geom)
+				geomLayers = append(geomLayers, layer)
+			}
+		}
+	}
+	return allGeoms, geomLayers
+}
+
+// LayersExtent returns the buffered extent of all geometries in the layers,
+// or false if none could be parsed
+func LayersExtent(layers [][]string) (Extent, bool) {
+	geoms, _ := parseLayers(layers)
+	if len(geoms) == 0 {
+		return Extent{}, false
+	}
+	var e Extent
+	e.MinX, e.MinY, e.MaxX, e.MaxY = (&GeometryRenderer{}).calculateBounds(geoms)
+	return e, true
+}
+
 func (r *GeometryRenderer) calculateBounds(geoms []interface{}) (minX, minY, maxX, maxY float64) {
 	minX, minY = math.MaxFloat64, math.MaxFloat64
 	maxX, maxY = -math.MaxFloat64, -math.MaxFloat64
@@ -154,7 +308,8 @@ func (r *GeometryRenderer) calculateBounds(geoms []interface{}) (minX, minY, max
 		}
 	}
 
-	for _, geom := range geoms {
+	var addBounds func(geom interface{})
+	addBounds = func(geom interface{}) {
 		switch g := geom.(type) {
 		case Point:
 			updateBounds(g)
@@ -186,8 +341,15 @@ func (r *GeometryRenderer) calculateBounds(geoms []interface{}) (minX, minY, max
 					}
 				}
 			}
+		case GeometryCollection:
+			for _, member := range g.Geometries {
+				addBounds(member)
+			}
 		}
 	}
+	for _, geom := range geoms {
+		addBounds(geom)
+	}
 
 	// Add small buffer
 	buffer := math.Max(maxX-minX, maxY-minY) * 0.05
@@ -202,167 +364,62 @@ func (r *GeometryRenderer) calculateBounds(geoms []interface{}) (minX, minY, max
 	return
 }
 
-func (r *GeometryRenderer) renderGeometry(img *image.RGBA, geom interface{}, transform func(Point) (int, int)) {
+// geometryShapes holds the fill, stroke and point shapes of one layer
+type geometryShapes struct {
+	fill, stroke, points *shapeLayer
+}
+
+// addGeometry adds a geometry's polygon fills, outlines and points to the layer shapes
+func (r *GeometryRenderer) addGeometry(shapes geometryShapes, geom interface{}, transform func(Point) Point) {
 	switch g := geom.(type) {
 	case Point:
-		x, y := transform(g)
-		r.drawPoint(img, x, y)
+		shapes.points.addCircle(transform(g), r.PointRadius)
 	case LineString:
-		r.drawLineString(img, g.Points, transform)
+		shapes.stroke.addStroke(transformPoints(g.Points, transform), r.StrokeWidth, false)
 	case Polygon:
-		r.drawPolygon(img, g, transform)
+		r.addPolygon(shapes, g, transform)
 	case MultiPoint:
 		for _, p := range g.Points {
-			x, y := transform(p)
-			r.drawPoint(img, x, y)
+			shapes.points.addCircle(transform(p), r.PointRadius)
 		}
 	case MultiLineString:
 		for _, line := range g.Lines {
-			r.drawLineString(img, line.Points, transform)
+			shapes.stroke.addStroke(transformPoints(line.Points, transform), r.StrokeWidth, false)
 		}
 	case MultiPolygon:
 		for _, poly := range g.Polygons {
-			r.drawPolygon(img, poly, transform)
+			r.addPolygon(shapes, poly, transform)
 		}
-	}
-}
-
-func (r *GeometryRenderer) drawPoint(img *image.RGBA, x, y int) {
-	radius := 3
-	for dy := -radius; dy <= radius; dy++ {
-		for dx := -radius; dx <= radius; dx++ {
-			if dx*dx+dy*dy <= radius*radius {
-				px, py := x+dx, y+dy
-				if px >= 0 && px < r.Width && py >= 0 && py < r.Height {
-					img.Set(px, py, r.PointColor)
-				}
-			}
+	case GeometryCollection:
+		for _, member := range g.Geometries {
+			r.addGeometry(shapes, member, transform)
 		}
 	}
 }
 
-func (r *GeometryRenderer) drawLineString(img *image.RGBA, points []Point, transform func(Point) (int, int)) {
-	if len(points) < 2 {
-		return
-	}
-
-	for i := 0; i < len(points)-1; i++ {
-		x1, y1 := transform(points[i])
-		x2, y2 := transform(points[i+1])
-		r.drawLine(img, x1, y1, x2, y2, r.LineColor)
-	}
-}
-
-func (r *GeometryRenderer) drawPolygon(img *image.RGBA, poly Polygon, transform func(Point) (int, int)) {
-	// Draw fill for exterior ring (simple scanline fill)
-	if len(poly.Rings) > 0 && len(poly.Rings[0]) > 2 {
-		r.fillPolygon(img, poly.Rings[0], transform)
-	}
-
-	// Draw outline for all rings
+// addPolygon fills a polygon with its holes cut out and outlines every ring
+func (r *GeometryRenderer) addPolygon(shapes geometryShapes, poly Polygon, transform func(Point) Point) {
+	rings := make([][]Point, 0, len(poly.Rings))
 	for _, ring := range poly.Rings {
 		if len(ring) > 1 {
-			r.drawLineString(img, ring, transform)
-			// Close the ring
-			if len(ring) > 2 {
-				x1, y1 := transform(ring[len(ring)-1])
-				x2, y2 := transform(ring[0])
-				r.drawLine(img, x1, y1, x2, y2, r.LineColor)
-			}
-		}
-	}
-}
-
-func (r *GeometryRenderer) fillPolygon(img *image.RGBA, points []Point, transform func(Point) (int, int)) {
-	if len(points) < 3 {
-		return
-	}
-
-	// Transform all points
-	transformed := make([]struct{ x, y int }, len(points))
-	minY, maxY := r.Height, 0
-	for i, p := range points {
-		transformed[i].x, transformed[i].y = transform(p)
-		if transformed[i].y < minY {
-			minY = transformed[i].y
-		}
-		if transformed[i].y > maxY {
-			maxY = transformed[i].y
-		}
-	}
-
-	// Scanline fill
-	for y := minY; y <= maxY; y++ {
-		var intersections []int
-		n := len(transformed)
-		for i := 0; i < n; i++ {
-			j := (i + 1) % n
-			y1, y2 := transformed[i].y, transformed[j].y
-			x1, x2 := transformed[i].x, transformed[j].x
-
-			if (y1 <= y && y < y2) || (y2 <= y && y < y1) {
-				x := x1 + (y-y1)*(x2-x1)/(y2-y1)
-				intersections = append(intersections, x)
-			}
-		}
-
-		// Sort intersections
-		for i := 0; i < len(intersections)-1; i++ {
-			for j := i + 1; j < len(intersections); j++ {
-				if intersections[i] > intersections[j] {
-					intersections[i], intersections[j] = intersections[j], intersections[i]
-				}
-			}
-		}
-
-		// Fill between pairs
-		for i := 0; i+1 < len(intersections); i += 2 {
-			for x := intersections[i]; x <= intersections[i+1]; x++ {
-				if x >= 0 && x < r.Width && y >= 0 && y < r.Height {
-					img.Set(x, y, r.FillColor)
-				}
-			}
+			rings = append(rings, transformPoints(ring, transform))
 		}
 	}
-}
-
-// Bresenham's line algorithm
-func (r *GeometryRenderer) drawLine(img *image.RGBA, x1, y1, x2, y2 int, c color.Color) {
-	dx := abs(x2 - x1)
-	dy := abs(y2 - y1)
-	sx, sy := 1, 1
-	if x1 >= x2 {
-		sx = -1
+	if len(rings) > 0 && len(rings[0]) > 2 {
+		shapes.fill.addPolygon(rings)
 	}
-	if y1 >= y2 {
-		sy = -1
-	}
-	err := dx - dy
-
-	for {
-		if x1 >= 0 && x1 < r.Width && y1 >= 0 && y1 < r.Height {
-			img.Set(x1, y1, c)
-		}
-		if x1 == x2 && y1 == y2 {
-			break
-		}
-		e2 := 2 * err
-		if e2 > -dy {
-			err -= dy
-			x1 += sx
-		}
-		if e2 < dx {
-			err += dx
-			y1 += sy
-		}
+	for _, ring := range rings {
+		shapes.stroke.addStroke(ring, r.StrokeWidth, true)
 	}
 }
 
-func abs(x int) int {
-	if x < 0 {
-		return -x
+// transformPoints maps data coordinates to pixel coordinates
+func transformPoints(points []Point, transform func(Point) Point) []Point {
+	out := make([]Point, len(points))
+	for i, p := range points {
+		out[i] = transform(p)
 	}
-	return x
+	return out
 }
 
 // parseGeometry parses WKB (hex) or WKT geometry strings
@@ -412,49 +469,126 @@ func parseWKB(hexStr string) (interface{}, error) {
 		return nil, fmt.Errorf("WKB too short")
 	}
 
+	return readWKBGeometry(bytes.NewReader(data))
+}
+
+// WKB geometry type codes, without dimension flags
+const (
+	wkbPoint              = 1
+	wkbLineString         = 2
+	wkbPolygon            = 3
+	wkbMultiPoint         = 4
+	wkbMultiLineString    = 5
+	wkbMultiPolygon       = 6
+	wkbGeometryCollection = 7
+	wkbCircularString     = 8
+	wkbCompoundCurve      = 9
+	wkbCurvePolygon       = 10
+	wkbMultiCurve         = 11
+	wkbMultiSurface       = 12
+	wkbPolyhedralSurface  = 15
+	wkbTIN                = 16
+	wkbTriangle           = 17
+)
+
+// readWKBHeader reads a geometry's byte order and type, returning the base
+// type code and how many ordinates each coordinate has (2 for XY, 3 for
+// XYZ or XYM, 4 for XYZM). Both PostGIS EWKB flags and ISO type codes
+// (1000 + type for Z, 2000 for M, 3000 for ZM) are understood.
+func readWKBHeader(r *bytes.Reader) (binary.ByteOrder, uint32, int, error) {
 	// First byte is byte order (0=big endian, 1=little endian)
-	var byteOrder binary.ByteOrder = binary.LittleEndian
-	if data[0] == 0 {
-		byteOrder = binary.BigEndian
+	orderByte, err := r.ReadByte()
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	var order binary.ByteOrder = binary.LittleEndian
+	if orderByte == 0 {
+		order = binary.BigEndian
 	}
 
 	// Next 4 bytes are geometry type
-	geomType := byteOrder.Uint32(data[1:5])
-
-	// Handle EWKB (PostGIS extended WKB with SRID)
-	offset := 5
-	if geomType&0x20000000 != 0 { // Has SRID
-		offset += 4 // Skip SRID
-		geomType = geomType & 0x0FFFFFFF
+	var geomType uint32
+	if err := binary.Read(r, order, &geomType); err != nil {
+		return nil, 0, 0, err
 	}
+
+	dims := 2
 	if geomType&0x80000000 != 0 { // Has Z
-		geomType = geomType & 0x7FFFFFFF
+		dims++
 	}
 	if geomType&0x40000000 != 0 { // Has M
-		geomType = geomType & 0xBFFFFFFF
+		dims++
+	}
+	if geomType&0x20000000 != 0 { // Has SRID (PostGIS extended WKB)
+		if err := skipWKB(r, 4); err != nil {
+			return nil, 0, 0, err
+		}
 	}
+	geomType &= 0x0FFFFFFF
 
-	reader := bytes.NewReader(data[offset:])
+	switch geomType / 1000 {
+	case 1, 2: // Z or M
+		dims++
+	case 3: // ZM
+		dims += 2
+	}
+	return order, geomType % 1000, dims, nil
+}
+
+// readWKBGeometry reads one geometry, header included. Curves are
+// linearized, so every geometry comes back as one of the plain types or a
+// GeometryCollection.
+func readWKBGeometry(r *bytes.Reader) (interface{}, error) {
+	order, geomType, dims, err := readWKBHeader(r)
+	if err != nil {
+		return nil, err
+	}
 
 	switch geomType {
-	case 1: // Point
-		return readWKBPoint(reader, byteOrder)
-	case 2: // LineString
-		return readWKBLineString(reader, byteOrder)
-	case 3: // Polygon
-		return readWKBPolygon(reader, byteOrder)
-	case 4: // MultiPoint
-		return readWKBMultiPoint(reader, byteOrder, data[0])
-	case 5: // MultiLineString
-		return readWKBMultiLineString(reader, byteOrder, data[0])
-	case 6: // MultiPolygon
-		return readWKBMultiPolygon(reader, byteOrder, data[0])
+	case wkbPoint:
+		return readWKBPoint(r, order, dims)
+	case wkbLineString:
+		return readWKBLineString(r, order, dims)
+	case wkbPolygon, wkbTriangle:
+		return readWKBPolygon(r, order, dims)
+	case wkbCircularString:
+		points, err := readWKBPoints(r, order, dims)
+		if err != nil {
+			return nil, err
+		}
+		return LineString{Points: linearizeCircularString(points)}, nil
+	case wkbCompoundCurve:
+		return readWKBCompoundCurve(r, order)
+	case wkbCurvePolygon:
+		return readWKBCurvePolygon(r, order)
+	case wkbMultiPoint:
+		return readWKBMultiPoint(r, order)
+	case wkbMultiLineString, wkbMultiCurve:
+		return readWKBMultiLineString(r, order)
+	case wkbMultiPolygon, wkbMultiSurface, wkbPolyhedralSurface, wkbTIN:
+		return readWKBMultiPolygon(r, order)
+	case wkbGeometryCollection:
+		return readWKBGeometryCollection(r, order)
 	default:
 		return nil, fmt.Errorf("unsupported WKB geometry type: %d", geomType)
 	}
 }
 
-func readWKBPoint(r *bytes.Reader, order binary.ByteOrder) (Point, error) {
+// readWKBCount reads the number of points, rings or parts that follows,
+// refusing counts the remaining data cannot hold
+func readWKBCount(r *bytes.Reader, order binary.ByteOrder) (uint32, error) {
+	var n uint32
+	if err := binary.Read(r, order, &n); err != nil {
+		return 0, err
+	}
+	if int64(n) > int64(r.Len()) {
+		return 0, fmt.Errorf("WKB count %d exceeds remaining data", n)
+	}
+	return n, nil
+}
+
+// readWKBPoint reads one coordinate, skipping any Z and M ordinates
+func readWKBPoint(r *bytes.Reader, order binary.ByteOrder, dims int) (Point, error) {
 	var x, y float64
 	if err := binary.Read(r, order, &x); err != nil {
 		return Point{}, err
@@ -462,117 +596,248 @@ func readWKBPoint(r *bytes.Reader, order binary.ByteOrder) (Point, error) {
 	if err := binary.Read(r, order, &y); err != nil {
 		return Point{}, err
 	}
+	if err := skipWKB(r, int64(dims-2)*8); err != nil {
+		return Point{}, err
+	}
 	return Point{X: x, Y: y}, nil
 }
 
-func readWKBLineString(r *bytes.Reader, order binary.ByteOrder) (LineString, error) {
-	var numPoints uint32
-	if err := binary.Read(r, order, &numPoints); err != nil {
-		return LineString{}, err
+// skipWKB skips n bytes, failing when fewer remain; seeking a bytes.Reader
+// past its end does not
+func skipWKB(r *bytes.Reader, n int64) error {
+	if int64(r.Len()) < n {
+		return io.ErrUnexpectedEOF
+	}
+	_, err := r.Seek(n, io.SeekCurrent)
+	return err
+}
+
+// readWKBPoints reads a point count followed by that many coordinates
+func readWKBPoints(r *bytes.Reader, order binary.ByteOrder, dims int) ([]Point, error) {
+	numPoints, err := readWKBCount(r, order)
+	if err != nil {
+		return nil, err
 	}
 
 	points := make([]Point, numPoints)
-	for i := uint32(0); i < numPoints; i++ {
-		p, err := readWKBPoint(r, order)
+	for i := range points {
+		p, err := readWKBPoint(r, order, dims)
 		if err != nil {
-			return LineString{}, err
+			return nil, err
 		}
 		points[i] = p
 	}
+	return points, nil
+}
+
+func readWKBLineString(r *bytes.Reader, order binary.ByteOrder, dims int) (LineString, error) {
+	points, err := readWKBPoints(r, order, dims)
+	if err != nil {
+		return LineString{}, err
+	}
 	return LineString{Points: points}, nil
 }
 
-func readWKBPolygon(r *bytes.Reader, order binary.ByteOrder) (Polygon, error) {
-	var numRings uint32
-	if err := binary.Read(r, order, &numRings); err != nil {
+func readWKBPolygon(r *bytes.Reader, order binary.ByteOrder, dims int) (Polygon, error) {
+	numRings, err := readWKBCount(r, order)
+	if err != nil {
 		return Polygon{}, err
 	}
 
 	rings := make([][]Point, numRings)
-	for i := uint32(0); i < numRings; i++ {
-		var numPoints uint32
-		if err := binary.Read(r, order, &numPoints); err != nil {
+	for i := range rings {
+		ring, err := readWKBPoints(r, order, dims)
+		if err != nil {
 			return Polygon{}, err
 		}
-		ring := make([]Point, numPoints)
-		for j := uint32(0); j < numPoints; j++ {
-			p, err := readWKBPoint(r, order)
-			if err != nil {
-				return Polygon{}, err
-			}
-			ring[j] = p
-		}
 		rings[i] = ring
 	}
 	return Polygon{Rings: rings}, nil
 }
 
-func readWKBMultiPoint(r *bytes.Reader, order binary.ByteOrder, byteOrderByte byte) (MultiPoint, error) {
-	var numGeoms uint32
-	if err := binary.Read(r, order, &numGeoms); err != nil {
-		return MultiPoint{}, err
+// readWKBParts reads the count and member geometries of a multi-geometry,
+// collection or compound curve; each member has its own header
+func readWKBParts(r *bytes.Reader, order binary.ByteOrder) ([]interface{}, error) {
+	numGeoms, err := readWKBCount(r, order)
+	if err != nil {
+		return nil, err
 	}
 
-	points := make([]Point, 0, numGeoms)
+	parts := make([]interface{}, 0, numGeoms)
 	for i := uint32(0); i < numGeoms; i++ {
-		// Skip byte order and type for each geometry
-		r.ReadByte()
-		var geomType uint32
-		binary.Read(r, order, &geomType)
-
-		p, err := readWKBPoint(r, order)
+		part, err := readWKBGeometry(r)
 		if err != nil {
-			return MultiPoint{}, err
+			return nil, err
+		}
+		parts = append(parts, part)
+	}
+	return parts, nil
+}
+
+// readWKBCompoundCurve joins the linearized segments of a compound curve
+// into one line; each segment starts where the previous one ended
+func readWKBCompoundCurve(r *bytes.Reader, order binary.ByteOrder) (LineString, error) {
+	parts, err := readWKBParts(r, order)
+	if err != nil {
+		return LineString{}, err
+	}
+
+	var points []Point
+	for _, part := range parts {
+		line, ok := part.(LineString)
+		if !ok {
+			return LineString{}, fmt.Errorf("compound curve segment is %T, not a curve", part)
+		}
+		if len(points) > 0 && len(line.Points) > 0 {
+			line.Points = line.Points[1:]
+		}
+		points = append(points, line.Points...)
+	}
+	return LineString{Points: points}, nil
+}
+
+// readWKBCurvePolygon reads a polygon whose rings may be curves
+func readWKBCurvePolygon(r *bytes.Reader, order binary.ByteOrder) (Polygon, error) {
+	parts, err := readWKBParts(r, order)
+	if err != nil {
+		return Polygon{}, err
+	}
+
+	rings := make([][]Point, 0, len(parts))
+	for _, part := range parts {
+		line, ok := part.(LineString)
+		if !ok {
+			return Polygon{}, fmt.Errorf("curve polygon ring is %T, not a curve", part)
+		}
+		rings = append(rings, line.Points)
+	}
+	return Polygon{Rings: rings}, nil
+}
+
+func readWKBMultiPoint(r *bytes.Reader, order binary.ByteOrder) (MultiPoint, error) {
+	parts, err := readWKBParts(r, order)
+	if err != nil {
+		return MultiPoint{}, err
+	}
+
+	points := make([]Point, 0, len(parts))
+	for _, part := range parts {
+		p, ok := part.(Point)
+		if !ok {
+			return MultiPoint{}, fmt.Errorf("multipoint member is %T, not a point", part)
 		}
 		points = append(points, p)
 	}
 	return MultiPoint{Points: points}, nil
 }
 
-func readWKBMultiLineString(r *bytes.Reader, order binary.ByteOrder, byteOrderByte byte) (MultiLineString, error) {
-	var numGeoms uint32
-	if err := binary.Read(r, order, &numGeoms); err != nil {
+// readWKBMultiLineString reads a multilinestring, or a multicurve with its
+// curves linearized
+func readWKBMultiLineString(r *bytes.Reader, order binary.ByteOrder) (MultiLineString, error) {
+	parts, err := readWKBParts(r, order)
+	if err != nil {
 		return MultiLineString{}, err
 	}
 
-	lines := make([]LineString, 0, numGeoms)
-	for i := uint32(0); i < numGeoms; i++ {
-		// Skip byte order and type
-		r.ReadByte()
-		var geomType uint32
-		binary.Read(r, order, &geomType)
-
-		line, err := readWKBLineString(r, order)
-		if err != nil {
-			return MultiLineString{}, err
+	lines := make([]LineString, 0, len(parts))
+	for _, part := range parts {
+		line, ok := part.(LineString)
+		if !ok {
+			return MultiLineString{}, fmt.Errorf("multilinestring member is %T, not a line", part)
 		}
 		lines = append(lines, line)
 	}
 	return MultiLineString{Lines: lines}, nil
 }
 
-func readWKBMultiPolygon(r *bytes.Reader, order binary.ByteOrder, byteOrderByte byte) (MultiPolygon, error) {
-	var numGeoms uint32
-	if err := binary.Read(r, order, &numGeoms); err != nil {
+// readWKBMultiPolygon reads a multipolygon, or a multisurface, polyhedral
+// surface or TIN, whose members are all polygons once curves are linearized
+func readWKBMultiPolygon(r *bytes.Reader, order binary.ByteOrder) (MultiPolygon, error) {
+	parts, err := readWKBParts(r, order)
+	if err != nil {
 		return MultiPolygon{}, err
 	}
 
-	polygons := make([]Polygon, 0, numGeoms)
-	for i := uint32(0); i < numGeoms; i++ {
-		// Skip byte order and type
-		r.ReadByte()
-		var geomType uint32
-		binary.Read(r, order, &geomType)
-
-		poly, err := readWKBPolygon(r, order)
-		if err != nil {
-			return MultiPolygon{}, err
+	polygons := make([]Polygon, 0, len(parts))
+	for _, part := range parts {
+		poly, ok := part.(Polygon)
+		if !ok {
+			return MultiPolygon{}, fmt.Errorf("multipolygon member is %T, not a polygon", part)
 		}
 		polygons = append(polygons, poly)
 	}
 	return MultiPolygon{Polygons: polygons}, nil
 }
 
+func readWKBGeometryCollection(r *bytes.Reader, order binary.ByteOrder) (GeometryCollection, error) {
+	parts, err := readWKBParts(r, order)
+	if err != nil {
+		return GeometryCollection{}, err
+	}
+	return GeometryCollection{Geometries: parts}, nil
+}
+
+// arcSegments is how many straight segments a full circle is linearized into
+const arcSegments = 64
+
+// linearizeCircularString turns a circular string, a chain of arcs each
+// running through three points that share their end points, into a line
+func linearizeCircularString(points []Point) []Point {
+	if len(points) < 3 {
+		return points
+	}
+	line := []Point{points[0]}
+	for i := 0; i+2 < len(points); i += 2 {
+		line = append(line, linearizeArc(points[i], points[i+1], points[i+2])...)
+	}
+	return line
+}
+
+// linearizeArc returns points along the circular arc from a through b to c,
+// excluding a. Collinear points give a straight line; a == c is a full circle.
+func linearizeArc(a, b, c Point) []Point {
+	var cx, cy float64
+	sweep := 2 * math.Pi
+	if a == c {
+		cx, cy = (a.X+b.X)/2, (a.Y+b.Y)/2
+	} else {
+		d := 2 * (a.X*(b.Y-c.Y) + b.X*(c.Y-a.Y) + c.X*(a.Y-b.Y))
+		if d == 0 {
+			return []Point{b, c}
+		}
+		a2, b2, c2 := a.X*a.X+a.Y*a.Y, b.X*b.X+b.Y*b.Y, c.X*c.X+c.Y*c.Y
+		cx = (a2*(b.Y-c.Y) + b2*(c.Y-a.Y) + c2*(a.Y-b.Y)) / d
+		cy = (a2*(c.X-b.X) + b2*(a.X-c.X) + c2*(b.X-a.X)) / d
+
+		// Sweep counter-clockwise from a to c, unless b lies the other way
+		start := math.Atan2(a.Y-cy, a.X-cx)
+		sweep = normalizeAngle(math.Atan2(c.Y-cy, c.X-cx) - start)
+		if normalizeAngle(math.Atan2(b.Y-cy, b.X-cx)-start) > sweep {
+			sweep -= 2 * math.Pi
+		}
+	}
+
+	radius := math.Hypot(a.X-cx, a.Y-cy)
+	start := math.Atan2(a.Y-cy, a.X-cx)
+	steps := max(2, int(math.Ceil(math.Abs(sweep)/(2*math.Pi)*arcSegments)))
+	points := make([]Point, steps)
+	for i := range points {
+		angle := start + sweep*float64(i+1)/float64(steps)
+		points[i] = Point{X: cx + radius*math.Cos(angle), Y: cy + radius*math.Sin(angle)}
+	}
+	points[steps-1] = c
+	return points
+}
+
+// normalizeAngle maps an angle into [0, 2π)
+func normalizeAngle(a float64) float64 {
+	a = math.Mod(a, 2*math.Pi)
+	if a < 0 {
+		a += 2 * math.Pi
+	}
+	return a
+}
+
 // parseWKT parses Well-Known Text
 func parseWKT(wkt string) (interface{}, error) {
 	wkt = strings.TrimSpace(wkt)
@@ -752,6 +1017,16 @@ func RenderGeometriesToPNG(geomValues []string, width, height int) (string, erro
 	return base64.StdEncoding.EncodeToString(pngData), nil
 }
 
+// RenderLayersToPNG renders overlaid geometry layers and returns base64-encoded PNG data
+func RenderLayersToPNG(layers [][]string, width, height int) (string, error) {
+	renderer := NewGeometryRenderer(width, height)
+	pngData, err := renderer.RenderLayers(layers)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(pngData), nil
+}
+
 // Base64ToKittyGraphics converts base64 PNG data to Kitty graphics protocol escape sequence
 func Base64ToKittyGraphics(b64Data string) string {
 	if b64Data == "" {
@@ -807,13 +1082,9 @@ func RenderToKittyGraphics(geomValues []string, width, height int) (string, erro
 // DetectGeometryColumn finds geometry column index in results
 func DetectGeometryColumn(columns []string, sampleRow []string) int {
 	// First check column names for common geometry column names
-	geomNames := []string{"geom", "geometry", "the_geom", "wkb_geometry", "shape", "geo"}
 	for i, col := range columns {
-		colLower := strings.ToLower(col)
-		for _, name := range geomNames {
-			if colLower == name || strings.Contains(colLower, "geom") {
-				return i
-			}
+		if isGeometryColumnName(col) {
+			return i
 		}
 	}
 
@@ -829,6 +1100,30 @@ func DetectGeometryColumn(columns []string, sampleRow []string) int {
 	return -1
 }
 
+// DetectGeometryColumns finds every geometry column in results, in column
+// order, using the same name and sample value checks as DetectGeometryColumn
+func DetectGeometryColumns(columns []string, sampleRow []string) []int {
+	var indices []int
+	for i, col := range columns {
+		if isGeometryColumnName(col) || (i < len(sampleRow) && isGeometryValue(sampleRow[i])) {
+			indices = append(indices, i)
+		}
+	}
+	return indices
+}
+
+// isGeometryColumnName checks if a column name is a common geometry column name
+func isGeometryColumnName(col string) bool {
+	geomNames := []string{"geom", "geometry", "the_geom", "wkb_geometry", "shape", "geo"}
+	colLower := strings.ToLower(col)
+	for _, name := range geomNames {
+		if colLower == name || strings.Contains(colLower, "geom") {
+			return true
+		}
+	}
+	return false
+}
+
 // isGeometryValue checks if a value looks like geometry data
 func isGeometryValue(val string) bool {
 	val = strings.TrimSpace(val)
@@ -0,0 +1,153 @@
+package tui
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestParseWKBPoints(t *testing.T) {
+	tests := []struct {
+		name     string
+		hex      string
+		expected Point
+	}{
+		{"XY big endian", "00000000013FF00000000000004000000000000000", Point{1, 2}},
+		{"XYZ ISO", "01E9030000000000000000F03F00000000000000400000000000000840", Point{1, 2}},
+		{"XYZ EWKB", "0101000080000000000000F03F00000000000000400000000000000840", Point{1, 2}},
+		{"XYM ISO", "01D1070000000000000000F03F00000000000000400000000000001040", Point{1, 2}},
+		{"XYM EWKB", "0101000040000000000000F03F00000000000000400000000000001040", Point{1, 2}},
+		{"XYZM ISO", "01B90B0000000000000000F03F000000000000004000000000000008400000000000001040", Point{1, 2}},
+		{"XYZM EWKB", "01010000C0000000000000F03F000000000000004000000000000008400000000000001040", Point{1, 2}},
+		{"EWKB with SRID", "0101000020E6100000EC51B81E856B3240F6285C8FC2F540C0", Point{18.42, -33.92}},
+	}
+
+	for _, tt := range tests {
+		got, err := parseWKB(tt.hex)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+			continue
+		}
+		if got != tt.expected {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.expected, got)
+		}
+	}
+}
+
+func TestParseWKBLineStringZWithSRID(t *testing.T) {
+	got, err := parseWKB("01020000A0E610000002000000000000000000000000000000000000000000000000002440000000000000084000000000000010400000000000003440")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := LineString{Points: []Point{{0, 0}, {3, 4}}}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+}
+
+func TestParseWKBGeometryCollection(t *testing.T) {
+	got, err := parseWKB("0107000000020000000101000000000000000000F03F00000000000000400102000000020000000000000000000000000000000000000000000000000008400000000000001040")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := GeometryCollection{Geometries: []interface{}{
+		Point{1, 2},
+		LineString{Points: []Point{{0, 0}, {3, 4}}},
+	}}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+}
+
+// onCircle reports whether every point lies on the circle about center
+func onCircle(points []Point, center Point, radius float64) bool {
+	for _, p := range points {
+		if math.Abs(math.Hypot(p.X-center.X, p.Y-center.Y)-radius) > 1e-9 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestParseWKBCurves(t *testing.T) {
+	// CIRCULARSTRING(0 0, 1 1, 2 0): the upper half of the unit circle about (1 0)
+	got, err := parseWKB("01080000000300000000000000000000000000000000000000000000000000F03F000000000000F03F00000000000000400000000000000000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	arc, ok := got.(LineString)
+	if !ok {
+		t.Fatalf("circular string: expected a LineString, got %T", got)
+	}
+	if n := len(arc.Points); n != arcSegments/2+1 {
+		t.Errorf("circular string: expected %d points, got %d", arcSegments/2+1, n)
+	}
+	if arc.Points[0] != (Point{0, 0}) || arc.Points[len(arc.Points)-1] != (Point{2, 0}) {
+		t.Errorf("circular string: expected to run from (0 0) to (2 0), got %v to %v", arc.Points[0], arc.Points[len(arc.Points)-1])
+	}
+	if !onCircle(arc.Points, Point{1, 0}, 1) {
+		t.Errorf("circular string: points leave the arc: %v", arc.Points)
+	}
+	if mid := arc.Points[len(arc.Points)/2]; math.Abs(mid.X-1) > 1e-9 || math.Abs(mid.Y-1) > 1e-9 {
+		t.Errorf("circular string: expected to pass through (1 1), got %v", mid)
+	}
+
+	// COMPOUNDCURVE(CIRCULARSTRING(0 0, 1 1, 2 0), (2 0, 4 0))
+	got, err = parseWKB("01090000000200000001080000000300000000000000000000000000000000000000000000000000F03F000000000000F03F000000000000004000000000000000000102000000020000000000000000000040000000000000000000000000000010400000000000000000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	curve, ok := got.(LineString)
+	if !ok {
+		t.Fatalf("compound curve: expected a LineString, got %T", got)
+	}
+	if n := len(curve.Points); n != len(arc.Points)+1 {
+		t.Errorf("compound curve: expected %d points, the shared end point once, got %d", len(arc.Points)+1, n)
+	}
+	if last := curve.Points[len(curve.Points)-1]; last != (Point{4, 0}) {
+		t.Errorf("compound curve: expected to end at (4 0), got %v", last)
+	}
+
+	// CURVEPOLYGON(CIRCULARSTRING(0 0, 2 0, 0 0)): the full circle through both points
+	got, err = parseWKB("010A00000001000000010800000003000000000000000000000000000000000000000000000000000040000000000000000000000000000000000000000000000000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	poly, ok := got.(Polygon)
+	if !ok {
+		t.Fatalf("curve polygon: expected a Polygon, got %T", got)
+	}
+	if len(poly.Rings) != 1 {
+		t.Fatalf("curve polygon: expected 1 ring, got %d", len(poly.Rings))
+	}
+	ring := poly.Rings[0]
+	if n := len(ring); n != arcSegments+1 {
+		t.Errorf("curve polygon: expected %d points, got %d", arcSegments+1, n)
+	}
+	if ring[0] != ring[len(ring)-1] {
+		t.Errorf("curve polygon: expected a closed ring, got %v to %v", ring[0], ring[len(ring)-1])
+	}
+	if !onCircle(ring, Point{1, 0}, 1) {
+		t.Errorf("curve polygon: points leave the circle: %v", ring)
+	}
+}
+
+func TestParseWKBTruncated(t *testing.T) {
+	for _, full := range []string{
+		"01B90B0000000000000000F03F000000000000004000000000000008400000000000001040",
+		"0101000020E6100000EC51B81E856B3240F6285C8FC2F540C0",
+		"0107000000020000000101000000000000000000F03F00000000000000400102000000020000000000000000000000000000000000000000000000000008400000000000001040",
+		"01090000000200000001080000000300000000000000000000000000000000000000000000000000F03F000000000000F03F000000000000004000000000000000000102000000020000000000000000000040000000000000000000000000000010400000000000000000",
+	} {
+		for n := 0; n < len(full); n += 2 {
+			if got, err := parseWKB(full[:n]); err == nil {
+				t.Errorf("parseWKB(%q): expected an error, got %v", full[:n], got)
+			}
+		}
+	}
+
+	// A count far beyond the data must not be allocated
+	if _, err := parseWKB("0102000000FFFFFFFF"); err == nil {
+		t.Error("expected an error for a count exceeding the data")
+	}
+}
@@ -0,0 +1,343 @@
+package tui
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite" // Pure Go SQLite driver
+)
+
+// Aliases of the per-row geometry columns added when exporting a GeoPackage
+const (
+	gpkgWKBColumn  = "__export_wkb"
+	gpkgTypeColumn = "__export_geomtype"
+	gpkgMinXColumn = "__export_minx"
+	gpkgMinYColumn = "__export_miny"
+	gpkgMaxXColumn = "__export_maxx"
+	gpkgMaxYColumn = "__export_maxy"
+)
+
+// gpkgTable is the feature table written to exported GeoPackages
+const gpkgTable = "query_results"
+
+// gpkgSchema creates the mandatory GeoPackage 1.3 metadata tables with the
+// three spatial reference systems every GeoPackage must define
+const gpkgSchema = `
+PRAGMA application_id = 1196444487; -- "GPKG"
+PRAGMA user_version = 10300;
+CREATE TABLE gpkg_spatial_ref_sys (
+	srs_name                 TEXT    NOT NULL,
+	srs_id                   INTEGER NOT NULL PRIMARY KEY,
+	organization             TEXT    NOT NULL,
+	organization_coordsys_id INTEGER NOT NULL,
+	definition               TEXT    NOT NULL,
+	description              TEXT
+);
+CREATE TABLE gpkg_contents (
+	table_name  TEXT     NOT NULL PRIMARY KEY,
+	data_type   TEXT     NOT NULL,
+	identifier  TEXT     UNIQUE,
+	description TEXT     DEFAULT '',
+	last_change DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now')),
+	min_x       DOUBLE,
+	min_y       DOUBLE,
+	max_x       DOUBLE,
+	max_y       DOUBLE,
+	srs_id      INTEGER,
+	CONSTRAINT fk_gc_r_srs_id FOREIGN KEY (srs_id) REFERENCES gpkg_spatial_ref_sys (srs_id)
+);
+CREATE TABLE gpkg_geometry_columns (
+	table_name         TEXT    NOT NULL,
+	column_name        TEXT    NOT NULL,
+	geometry_type_name TEXT    NOT NULL,
+	srs_id             INTEGER NOT NULL,
+	z                  TINYINT NOT NULL,
+	m                  TINYINT NOT NULL,
+	CONSTRAINT pk_geom_cols PRIMARY KEY (table_name, column_name),
+	CONSTRAINT uk_gc_table_name UNIQUE (table_name),
+	CONSTRAINT fk_gc_tn FOREIGN KEY (table_name) REFERENCES gpkg_contents (table_name),
+	CONSTRAINT fk_gc_srs FOREIGN KEY (srs_id) REFERENCES gpkg_spatial_ref_sys (srs_id)
+);
+INSERT INTO gpkg_spatial_ref_sys VALUES
+	('Undefined cartesian SRS', -1, 'NONE', -1, 'undefined', 'undefined cartesian coordinate reference system'),
+	('Undefined geographic SRS', 0, 'NONE', 0, 'undefined', 'undefined geographic coordinate reference system'),
+	('WGS 84 geodetic', 4326, 'EPSG', 4326, 'GEOGCS["WGS 84",DATUM["WGS_1984",SPHEROID["WGS 84",6378137,298.257223563,AUTHORITY["EPSG","7030"]],AUTHORITY["EPSG","6326"]],PRIMEM["Greenwich",0,AUTHORITY["EPSG","8901"]],UNIT["degree",0.0174532925199433,AUTHORITY["EPSG","9122"]],AUTHORITY["EPSG","4326"]]', 'longitude/latitude coordinates in decimal degrees on the WGS 84 spheroid');
+`
+
+// spatialRef is the coordinate reference system of exported geometries
+type spatialRef struct {
+	srid     int    // PostGIS SRID; 0 when unknown
+	authName string // e.g. "EPSG"
+	authSRID int
+	wkt      string // OGC WKT definition from spatial_ref_sys
+}
+
+// name returns the CRS as an OGC URN, e.g. "urn:ogc:def:crs:EPSG::27700"
+func (s spatialRef) name() string {
+	return fmt.Sprintf("urn:ogc:def:crs:%s::%d", s.authName, s.authSRID)
+}
+
+// title returns the CRS name from its WKT, e.g. "OSGB36 / British National Grid"
+func (s spatialRef) title() string {
+	if m := regexp.MustCompile(`^\w+\["([^"]*)"`).FindStringSubmatch(s.wkt); m != nil {
+		return m[1]
+	}
+	return fmt.Sprintf("%s:%d", s.authName, s.authSRID)
+}
+
+// lookupSpatialRef finds the SRID of the first non-NULL geometry produced by
+// query, and its definition in spatial_ref_sys. A zero SRID is not an error.
+func lookupSpatialRef(ctx context.Context, tx *sql.Tx, query string, args []interface{}, geomCol string) (spatialRef, error) {
+	var ref spatialRef
+	sridQuery := fmt.Sprintf("SELECT ST_SRID(srid_query.%[1]s::geometry) FROM (%[2]s) AS srid_query WHERE srid_query.%[1]s IS NOT NULL LIMIT 1",
+		quoteIdentifier(geomCol), query)
+	if err := tx.QueryRowContext(ctx, sridQuery, args...).Scan(&ref.srid); err != nil && err != sql.ErrNoRows {
+		return ref, fmt.Errorf("failed to read geometry SRID: %w", err)
+	}
+	if ref.srid <= 0 {
+		return spatialRef{}, nil
+	}
+
+	ref.authName, ref.authSRID = "EPSG", ref.srid
+	var authName sql.NullString
+	var authSRID sql.NullInt64
+	err := tx.QueryRowContext(ctx, "SELECT auth_name, auth_srid, srtext FROM spatial_ref_sys WHERE srid = $1", ref.srid).
+		Scan(&authName, &authSRID, &ref.wkt)
+	if err != nil && err != sql.ErrNoRows {
+		return ref, fmt.Errorf("failed to read spatial_ref_sys: %w", err)
+	}
+	if authName.Valid && authName.String != "" && authSRID.Valid {
+		ref.authName, ref.authSRID = authName.String, int(authSRID.Int64)
+	}
+	return ref, nil
+}
+
+// gpkgExportQuery wraps source so each row also carries the geometry as WKB
+// with its type and bounding box
+func gpkgExportQuery(source, geomCol string) string {
+	g := fmt.Sprintf("export_query.%s::geometry", quoteIdentifier(geomCol))
+	return fmt.Sprintf("SELECT export_query.*, ST_AsBinary(%[1]s) AS %[2]s, GeometryType(%[1]s) AS %[3]s, "+
+		"ST_XMin(%[1]s) AS %[4]s, ST_YMin(%[1]s) AS %[5]s, ST_XMax(%[1]s) AS %[6]s, ST_YMax(%[1]s) AS %[7]s FROM (%[8]s) AS export_query",
+		g, gpkgWKBColumn, gpkgTypeColumn, gpkgMinXColumn, gpkgMinYColumn, gpkgMaxXColumn, gpkgMaxYColumn, source)
+}
+
+// gpkgColumnType maps a PostgreSQL type name to a GeoPackage column type
+func gpkgColumnType(dbType string) string {
+	switch dbType {
+	case "INT2", "INT4", "INT8", "OID":
+		return "INTEGER"
+	case "FLOAT4", "FLOAT8", "NUMERIC":
+		return "DOUBLE"
+	case "BOOL":
+		return "BOOLEAN"
+	case "DATE":
+		return "DATE"
+	case "TIMESTAMP", "TIMESTAMPTZ":
+		return "DATETIME"
+	case "BYTEA":
+		return "BLOB"
+	default:
+		return "TEXT"
+	}
+}
+
+// gpkgValue converts a scanned value for a column of GeoPackage type colType
+func gpkgValue(val interface{}, colType string) interface{} {
+	switch v := val.(type) {
+	case []byte:
+		switch colType {
+		case "BLOB":
+			return v
+		case "DOUBLE":
+			if f, err := strconv.ParseFloat(string(v), 64); err == nil {
+				return f
+			}
+		}
+		return string(v)
+	case time.Time:
+		if colType == "DATE" {
+			return v.Format("2006-01-02")
+		}
+		return v.UTC().Format("2006-01-02T15:04:05.000Z")
+	default:
+		return v
+	}
+}
+
+// gpkgGeometry encodes WKB as a GeoPackage geometry blob: the "GP" header
+// with the SRS id and an XY envelope, followed by the WKB itself
+func gpkgGeometry(wkb []byte, srsID int32, minX, minY, maxX, maxY sql.NullFloat64) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("GP")
+	buf.WriteByte(0) // Version 1
+
+	flags := byte(0x01) // Little-endian header values
+	hasEnvelope := minX.Valid && minY.Valid && maxX.Valid && maxY.Valid
+	if hasEnvelope {
+		flags |= 0x01 << 1 // [minx, maxx, miny, maxy]
+	} else {
+		flags |= 0x10 // Empty geometry
+	}
+	buf.WriteByte(flags)
+	binary.Write(&buf, binary.LittleEndian, srsID)
+	if hasEnvelope {
+		for _, v := range []float64{minX.Float64, maxX.Float64, minY.Float64, maxY.Float64} {
+			binary.Write(&buf, binary.LittleEndian, v)
+		}
+	}
+	buf.Write(wkb)
+	return buf.Bytes()
+}
+
+// writeGeoPackage streams rows of gpkgExportQuery into a new GeoPackage at
+// path with a single feature table, so the results open directly in QGIS
+func writeGeoPackage(path string, rows *sql.Rows, geomCol string, ref spatialRef, identifier, description string) (int, error) {
+	columns, err := rows.Columns()
+	if err != nil {
+		return 0, err
+	}
+	colTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return 0, err
+	}
+
+	gpkg, err := sql.Open("sqlite", path)
+	if err != nil {
+		return 0, err
+	}
+	defer gpkg.Close()
+	if _, err := gpkg.Exec(gpkgSchema); err != nil {
+		return 0, fmt.Errorf("failed to create GeoPackage: %w", err)
+	}
+
+	srsID := int32(-1)
+	if ref.srid > 0 {
+		srsID = int32(ref.srid)
+		if ref.srid != 4326 {
+			definition := ref.wkt
+			if definition == "" {
+				definition = "undefined"
+			}
+			if _, err := gpkg.Exec("INSERT INTO gpkg_spatial_ref_sys VALUES (?, ?, ?, ?, ?, NULL)",
+				ref.title(), ref.srid, ref.authName, ref.authSRID, definition); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	// Attribute columns: everything except the source geometry and the
+	// export helpers, with names kept unique next to fid and the geometry
+	geomName := geomCol
+	used := map[string]bool{"fid": true, strings.ToLower(geomName): true}
+	var attrIdx []int
+	var attrNames, attrTypes []string
+	index := make(map[string]int, len(columns))
+	for i, col := range columns {
+		index[col] = i
+		if col == geomCol || strings.HasPrefix(col, "__export_") {
+			continue
+		}
+		name := col
+		for n := 1; used[strings.ToLower(name)]; n++ {
+			name = fmt.Sprintf("%s_%d", col, n)
+		}
+		used[strings.ToLower(name)] = true
+		attrIdx = append(attrIdx, i)
+		attrNames = append(attrNames, name)
+		attrTypes = append(attrTypes, gpkgColumnType(colTypes[i].DatabaseTypeName()))
+	}
+
+	defs := []string{"fid INTEGER PRIMARY KEY AUTOINCREMENT", quoteIdentifier(geomName) + " GEOMETRY"}
+	insertCols := []string{quoteIdentifier(geomName)}
+	placeholders := []string{"?"}
+	for i, name := range attrNames {
+		defs = append(defs, quoteIdentifier(name)+" "+attrTypes[i])
+		insertCols = append(insertCols, quoteIdentifier(name))
+		placeholders = append(placeholders, "?")
+	}
+	if _, err := gpkg.Exec(fmt.Sprintf("CREATE TABLE %s (%s)", gpkgTable, strings.Join(defs, ", "))); err != nil {
+		return 0, err
+	}
+
+	tx, err := gpkg.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	insert, err := tx.Prepare(fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
+		gpkgTable, strings.Join(insertCols, ", "), strings.Join(placeholders, ", ")))
+	if err != nil {
+		return 0, err
+	}
+	defer insert.Close()
+
+	count := 0
+	geomType := ""
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for rows.Next() {
+		values, err := scanRow(rows, len(columns))
+		if err != nil {
+			return count, err
+		}
+
+		var geom interface{}
+		if wkb, ok := values[index[gpkgWKBColumn]].([]byte); ok {
+			var env [4]sql.NullFloat64
+			for j, col := range []string{gpkgMinXColumn, gpkgMinYColumn, gpkgMaxXColumn, gpkgMaxYColumn} {
+				env[j].Scan(values[index[col]])
+			}
+			geom = gpkgGeometry(wkb, srsID, env[0], env[1], env[2], env[3])
+			if env[0].Valid {
+				minX, minY = math.Min(minX, env[0].Float64), math.Min(minY, env[1].Float64)
+				maxX, maxY = math.Max(maxX, env[2].Float64), math.Max(maxY, env[3].Float64)
+			}
+
+			t := strings.TrimSuffix(fmt.Sprint(gpkgValue(values[index[gpkgTypeColumn]], "TEXT")), "M")
+			if geomType == "" {
+				geomType = t
+			} else if geomType != t {
+				geomType = "GEOMETRY"
+			}
+		}
+
+		args := []interface{}{geom}
+		for j, i := range attrIdx {
+			args = append(args, gpkgValue(values[i], attrTypes[j]))
+		}
+		if _, err := insert.Exec(args...); err != nil {
+			return count, err
+		}
+		count++
+	}
+	if err := rows.Err(); err != nil {
+		return count, err
+	}
+
+	if geomType == "" {
+		geomType = "GEOMETRY"
+	}
+	var bounds []interface{}
+	if count > 0 && !math.IsInf(minX, 1) {
+		bounds = []interface{}{minX, minY, maxX, maxY}
+	} else {
+		bounds = []interface{}{nil, nil, nil, nil}
+	}
+	if _, err := tx.Exec("INSERT INTO gpkg_contents (table_name, data_type, identifier, description, min_x, min_y, max_x, max_y, srs_id) VALUES (?, 'features', ?, ?, ?, ?, ?, ?, ?)",
+		append(append([]interface{}{gpkgTable, identifier, description}, bounds...), srsID)...); err != nil {
+		return count, err
+	}
+	// z and m are "optional" (2) since PostGIS columns may mix dimensions
+	if _, err := tx.Exec("INSERT INTO gpkg_geometry_columns VALUES (?, ?, ?, ?, 2, 2)", gpkgTable, geomName, geomType, srsID); err != nil {
+		return count, err
+	}
+	return count, tx.Commit()
+}
@@ -0,0 +1,132 @@
+package tui
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image/png"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/blacktop/go-termimg"
+	"github.com/mattn/go-sixel"
+	"github.com/nfnt/resize"
+)
+
+// graphicsProtocol is how the terminal shows images
+type graphicsProtocol int
+
+const (
+	graphicsNone  graphicsProtocol = iota // Braille charts only
+	graphicsKitty                         // Kitty graphics protocol
+	graphicsSixel                         // Sixel, as in Windows Terminal, foot and WezTerm
+)
+
+// da1Timeout is how long the terminal has to answer the device attributes
+// query
+const da1Timeout = 200 * time.Millisecond
+
+// terminalGraphics reports, once per process, how the terminal shows images.
+// It queries the terminal, so it is first called before Bubble Tea starts
+// reading input.
+var terminalGraphics = sync.OnceValue(detectGraphics)
+
+// detectGraphics works out how the terminal shows images, preferring Kitty
+// graphics where both are supported
+func detectGraphics() graphicsProtocol {
+	switch {
+	case detectKittySupport():
+		return graphicsKitty
+	case detectSixelSupport():
+		return graphicsSixel
+	}
+	return graphicsNone
+}
+
+// detectSixelSupport checks if the terminal shows sixel images, as it says
+// in its answer to the primary device attributes (DA1) query. Terminals
+// that cannot be queried, as without /dev/tty, are recognised by their TERM
+// and TERM_PROGRAM.
+func detectSixelSupport() bool {
+	if supported, ok := querySixelSupport(); ok {
+		return supported
+	}
+	return termimg.DetectSixelFromEnvironment()
+}
+
+// querySixelSupport asks the terminal for its device attributes, reporting
+// whether they include sixel graphics and whether the terminal answered
+func querySixelSupport() (supported, ok bool) {
+	tq, err := termimg.NewTerminalQuerier()
+	if err != nil || tq == nil {
+		return false, false
+	}
+	defer tq.Close()
+	reply, err := tq.Query("\x1b[c", da1Timeout)
+	if err != nil {
+		return false, false
+	}
+	return da1ReportsSixel(reply)
+}
+
+// da1ReportsSixel reads a DA1 reply, ESC [ ? class ; attr ; ... c, in which
+// attribute 4 is sixel graphics. ok is false when reply is not one.
+func da1ReportsSixel(reply string) (supported, ok bool) {
+	start := strings.Index(reply, "\x1b[?")
+	if start < 0 {
+		return false, false
+	}
+	reply = reply[start+3:]
+	end := strings.IndexByte(reply, 'c')
+	if end < 0 {
+		return false, false
+	}
+	params := strings.Split(reply[:end], ";")
+	for _, attr := range params[1:] {
+		if attr == "4" {
+			return true, true
+		}
+	}
+	return false, true
+}
+
+// Base64ToTerminalGraphics converts base64 PNG data to the escape sequence
+// showing it in this terminal: sixel where that is all the terminal shows,
+// otherwise Kitty graphics
+func Base64ToTerminalGraphics(b64Data string) string {
+	if b64Data == "" || terminalGraphics() != graphicsSixel {
+		return Base64ToKittyGraphics(b64Data)
+	}
+	data, err := base64.StdEncoding.DecodeString(b64Data)
+	if err != nil {
+		return ""
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return ""
+	}
+	var buf bytes.Buffer
+	if err := sixel.NewEncoder(&buf).Encode(img); err != nil {
+		return ""
+	}
+	return buf.String()
+}
+
+// Base64ToThumbnailGraphics shrinks base64 PNG data to fit within width by
+// height pixels, keeping its aspect, and converts it as
+// Base64ToTerminalGraphics does. It returns "" for data that is not a PNG.
+func Base64ToThumbnailGraphics(b64Data string, width, height uint) string {
+	data, err := base64.StdEncoding.DecodeString(b64Data)
+	if err != nil {
+		return ""
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return ""
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, resize.Thumbnail(width, height, img, resize.Lanczos3)); err != nil {
+		return ""
+	}
+	return Base64ToTerminalGraphics(base64.StdEncoding.EncodeToString(buf.Bytes()))
+}
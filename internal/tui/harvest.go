@@ -2,12 +2,15 @@ package tui
 
 import (
 	"fmt"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/charmbracelet/bubbles/progress"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/kartoza/kartoza-pg-ai/internal/config"
+	"github.com/kartoza/kartoza-pg-ai/internal/logging"
 	"github.com/kartoza/kartoza-pg-ai/internal/postgres"
 )
 
@@ -48,6 +51,8 @@ type HarvestModel struct {
 	schema      *config.SchemaCache
 	service     postgres.ServiceEntry
 	harvestChan chan harvestProgressMsg
+	workers     int  // Parallel column queries
+	stats       bool // Harvest column statistics
 }
 
 // NewHarvestModel creates a new harvest model
@@ -61,9 +66,20 @@ func NewHarvestModel(service postgres.ServiceEntry) *HarvestModel {
 	prog.FullColor = string(ColorOrange)
 	prog.EmptyColor = string(ColorDarkGray)
 
+	workers := postgres.DefaultHarvestConcurrency
+	stats := true
+	if cfg, err := config.Load(); err == nil {
+		if cfg.Settings.HarvestWorkers > 0 {
+			workers = cfg.Settings.HarvestWorkers
+		}
+		stats = cfg.Settings.HarvestStats
+	}
+
 	return &HarvestModel{
 		progress:    prog,
 		service:     service,
+		workers:     workers,
+		stats:       stats,
 		message:     "Initializing...",
 		harvestChan: make(chan harvestProgressMsg, 100),
 	}
@@ -99,13 +115,17 @@ func (m *HarvestModel) startHarvest() tea.Cmd {
 		debugLog("startHarvest: connecting to database")
 		db, err := m.service.Connect()
 		if err != nil {
-			debugLog("startHarvest: connection error: " + err.Error())
+			logging.Error("harvest connection failed", "service", m.service.Name, "error", err)
 			return schemaLoadedMsg{err: err}
 		}
 		defer db.Close()
 		debugLog("startHarvest: connected, creating harvester")
 
 		harvester := postgres.NewSchemaHarvester(db)
+		harvester.SetConcurrency(m.workers)
+		harvester.SetColumnStats(m.stats)
+		harvester.SetFilter(m.service.HarvestFilter())
+		harvester.SetDbtManifest(m.service.DbtManifest)
 
 		// Set up progress callback that sends to channel
 		harvester.SetProgressCallback(func(current, total int, message string) {
@@ -124,6 +144,9 @@ func (m *HarvestModel) startHarvest() tea.Cmd {
 					entityType = "View"
 					fullName := message[6:]
 					schemaName, entityName = parseSchemaEntity(fullName)
+				} else if strings.HasPrefix(message, "Materialized view: ") {
+					entityType = "Materialized view"
+					schemaName, entityName = parseSchemaEntity(strings.TrimPrefix(message, "Materialized view: "))
 				} else if message[:9] == "Function:" {
 					entityType = "Function"
 					fullName := message[10:]
@@ -152,15 +175,16 @@ func (m *HarvestModel) startHarvest() tea.Cmd {
 		})
 
 		debugLog("startHarvest: starting harvest for " + m.service.Name)
+		start := time.Now()
 		schema, err := harvester.Harvest(m.service.Name)
 		debugLog("startHarvest: harvest returned, closing channel")
 		close(m.harvestChan)
 
 		if err != nil {
-			debugLog("startHarvest: harvest error: " + err.Error())
+			logging.Error("harvest failed", "service", m.service.Name, "error", err)
 		} else {
-			debugLog(fmt.Sprintf("startHarvest: harvest complete, %d tables, %d views, %d functions",
-				len(schema.Tables), len(schema.Views), len(schema.Functions)))
+			logging.Info("harvest complete", "service", m.service.Name, "tables", len(schema.Tables),
+				"views", len(schema.Views), "functions", len(schema.Functions), "duration_ms", time.Since(start).Milliseconds())
 		}
 		debugLog("startHarvest: returning schemaLoadedMsg")
 		return schemaLoadedMsg{schema: schema, err: err}
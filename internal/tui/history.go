@@ -7,6 +7,7 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/kartoza/kartoza-pg-ai/internal/config"
+	"github.com/kartoza/kartoza-pg-ai/internal/postgres"
 )
 
 // HistoryModel represents the query history screen
@@ -17,35 +18,78 @@ type HistoryModel struct {
 	selectedItem  int
 	serviceName   string
 	cfg           *config.Config
-	showingImage  bool   // Whether we're currently showing an image
-	currentImage  string // Kitty graphics escape sequence for current image
+	showingImage  bool    // Whether we're currently showing an image
+	currentImage  string  // Kitty graphics escape sequence for current image
+	search        string  // Active search filter
+	searchInput   *string // Search text being typed (nil when not searching)
+	loadErr       string  // Error reading the history database
+	thumbnails    map[string]string // Thumbnails of geometry images shown, by image ID
 }
 
+// Bounds of history thumbnails in pixels; images keep their aspect within them
+const (
+	historyThumbnailWidth  = 240
+	historyThumbnailHeight = 120
+)
+
 // rerunQueryMsg indicates user wants to rerun a query
 type rerunQueryMsg struct {
 	query string
+	sql   string // Saved template SQL to load into the editor instead of the question
 }
 
 // NewHistoryModel creates a new history model for a specific service
 func NewHistoryModel(serviceName string) *HistoryModel {
 	cfg, _ := config.Load()
 
-	// Filter entries for this service
-	var entries []config.QueryHistoryEntry
-	if cfg != nil {
-		for _, entry := range cfg.QueryHistory {
-			if entry.ServiceName == serviceName {
-				entries = append(entries, entry)
-			}
-		}
-	}
-
-	return &HistoryModel{
-		entries:      entries,
+	m := &HistoryModel{
 		selectedItem: 0,
 		serviceName:  serviceName,
 		cfg:          cfg,
 	}
+	m.loadEntries()
+	return m
+}
+
+// loadEntries reads this service's history matching the search filter
+func (m *HistoryModel) loadEntries() {
+	m.entries = nil
+	m.loadErr = ""
+	store, err := config.History()
+	if err == nil {
+		m.entries, err = store.List(config.HistoryFilter{ServiceName: m.serviceName, Search: m.search})
+	}
+	if err != nil {
+		m.loadErr = err.Error()
+	}
+	if m.selectedItem >= len(m.entries) {
+		m.selectedItem = max(len(m.entries)-1, 0)
+	}
+}
+
+// handleSearchKey edits the search filter, refreshing the list as it changes
+func (m *HistoryModel) handleSearchKey(msg tea.KeyMsg) (*HistoryModel, tea.Cmd) {
+	switch msg.Type {
+	case tea.KeyEnter:
+		m.searchInput = nil
+		return m, nil
+	case tea.KeyEsc:
+		m.searchInput = nil
+		m.search = ""
+	case tea.KeyBackspace:
+		if r := []rune(*m.searchInput); len(r) > 0 {
+			*m.searchInput = string(r[:len(r)-1])
+		}
+		m.search = *m.searchInput
+	case tea.KeyRunes, tea.KeySpace:
+		*m.searchInput += string(msg.Runes)
+		m.search = *m.searchInput
+	default:
+		return m, nil
+	}
+	m.selectedItem = 0
+	m.loadEntries()
+	return m, nil
 }
 
 // Init initializes the history model
@@ -69,7 +113,22 @@ func (m *HistoryModel) Update(msg tea.Msg) (*HistoryModel, tea.Cmd) {
 			return m, nil
 		}
 
+		// Search prompt captures keys while open
+		if m.searchInput != nil && msg.String() != "ctrl+c" {
+			return m.handleSearchKey(msg)
+		}
+
 		switch {
+		case key.Matches(msg, key.NewBinding(key.WithKeys("/"))):
+			search := m.search
+			m.searchInput = &search
+			return m, nil
+
+		case key.Matches(msg, key.NewBinding(key.WithKeys("esc"))) && m.search != "":
+			m.search = ""
+			m.loadEntries()
+			return m, nil
+
 		case key.Matches(msg, key.NewBinding(key.WithKeys("esc"))):
 			return m, func() tea.Msg {
 				return goToMenuMsg{}
@@ -99,8 +158,13 @@ func (m *HistoryModel) Update(msg tea.Msg) (*HistoryModel, tea.Cmd) {
 		case key.Matches(msg, key.NewBinding(key.WithKeys("enter", " "))):
 			if len(m.entries) > 0 && m.selectedItem < len(m.entries) {
 				entry := m.entries[m.selectedItem]
+				rerun := rerunQueryMsg{query: entry.NaturalQuery}
+				// Templates are re-run as SQL so their parameters are asked for again
+				if postgres.HasTemplateParams(entry.EditedSQL) {
+					rerun.sql = entry.EditedSQL
+				}
 				return m, func() tea.Msg {
-					return rerunQueryMsg{query: entry.NaturalQuery}
+					return rerun
 				}
 			}
 			return m, nil
@@ -136,22 +200,19 @@ func (m *HistoryModel) Update(msg tea.Msg) (*HistoryModel, tea.Cmd) {
 }
 
 func (m *HistoryModel) deleteEntry(index int) {
-	if m.cfg == nil || index >= len(m.entries) {
+	if index >= len(m.entries) {
 		return
 	}
 
-	// Remove from local list
-	entry := m.entries[index]
-	m.entries = append(m.entries[:index], m.entries[index+1:]...)
-
-	// Remove from config (find matching entry)
-	for i, e := range m.cfg.QueryHistory {
-		if e.Timestamp == entry.Timestamp && e.NaturalQuery == entry.NaturalQuery {
-			m.cfg.QueryHistory = append(m.cfg.QueryHistory[:i], m.cfg.QueryHistory[i+1:]...)
-			m.cfg.Save()
-			break
-		}
+	store, err := config.History()
+	if err == nil {
+		err = store.Delete(m.entries[index].ID)
+	}
+	if err != nil {
+		m.loadErr = err.Error()
+		return
 	}
+	m.entries = append(m.entries[:index], m.entries[index+1:]...)
 }
 
 // View renders the history screen
@@ -167,7 +228,20 @@ func (m *HistoryModel) View() string {
 
 	header := RenderHeader("Query History - " + m.serviceName)
 	content := m.renderContent()
-	helpText := "↑/k: up • ↓/j: down • enter: rerun • v: view image • d: delete • esc: back"
+	if m.searchInput != nil {
+		content = PromptStyle.Render("🔍 Search: ") + *m.searchInput + "█\n\n" + content
+	} else if m.search != "" {
+		content = lipgloss.NewStyle().Foreground(ColorGray).Render("🔍 Matching \""+m.search+"\"") + "\n\n" + content
+	}
+	if m.loadErr != "" {
+		content = ErrorStyle.Render("✗ History unavailable: "+m.loadErr) + "\n\n" + content
+	}
+	helpText := "↑/k: up • ↓/j: down • enter: rerun • v: view image • d: delete • /: search • esc: back"
+	if m.searchInput != nil {
+		helpText = "type to filter • Enter: done • Esc: clear search"
+	} else if m.search != "" {
+		helpText = "↑/k: up • ↓/j: down • enter: rerun • v: view image • d: delete • /: search • esc: clear search"
+	}
 	footer := RenderHelpFooter(helpText, m.width)
 
 	return LayoutWithHeaderFooter(header, content, footer, m.width, m.height)
@@ -206,6 +280,12 @@ func (m *HistoryModel) renderContent() string {
 			Foreground(ColorGray).
 			Italic(true).
 			Render("No query history for " + m.serviceName)
+		if m.search != "" {
+			noHistory = lipgloss.NewStyle().
+				Foreground(ColorGray).
+				Italic(true).
+				Render("No queries match the search")
+		}
 		return noHistory
 	}
 
@@ -353,14 +433,25 @@ func (m *HistoryModel) renderContent() string {
 			BorderForeground(ColorBlue).
 			Width(min(80, m.width-10))
 
-		sqlStyle := lipgloss.NewStyle().Foreground(ColorCyan)
 		labelStyle := lipgloss.NewStyle().Foreground(ColorGray)
 
 		detailParts := []string{
 			labelStyle.Render("Generated SQL:"),
-			sqlStyle.Render(entry.GeneratedSQL),
+			highlightSQL(postgres.FormatSQL(entry.GeneratedSQL, min(80, m.width-10))),
+		}
+		if entry.EditedSQL != "" {
+			detailParts = append(detailParts, "", labelStyle.Render("Edited SQL:"), highlightSQL(postgres.FormatSQL(entry.EditedSQL, min(80, m.width-10))))
+		}
+		detailParts = append(detailParts,
 			"",
 			labelStyle.Render(fmt.Sprintf("Execution time: %.2fms", entry.ExecutionTime)),
+		)
+		if entry.Generator != "" {
+			detailParts = append(detailParts, labelStyle.Render("Generated by: "+entry.Generator))
+		}
+		if entry.InputTokens+entry.OutputTokens > 0 {
+			detailParts = append(detailParts, labelStyle.Render(fmt.Sprintf("LLM usage: %d prompt + %d completion tokens, %s",
+				entry.InputTokens, entry.OutputTokens, formatCost(entry.Cost))))
 		}
 
 		// Add geometry info if available
@@ -372,7 +463,31 @@ func (m *HistoryModel) renderContent() string {
 
 		details := lipgloss.JoinVertical(lipgloss.Left, detailParts...)
 		rows = append(rows, detailBox.Render(details))
+		if thumbnail := m.thumbnail(entry); thumbnail != "" {
+			rows = append(rows, "", thumbnail)
+		}
 	}
 
 	return lipgloss.JoinVertical(lipgloss.Center, rows...)
 }
+
+// thumbnail returns a small picture of the geometry an entry returned, for
+// finding a spatial result without opening it, or "" when the entry has
+// none or the terminal shows no images. Thumbnails are made once.
+func (m *HistoryModel) thumbnail(entry config.QueryHistoryEntry) string {
+	if !entry.HasGeometry || entry.GeometryImageID == "" || plainOutput || terminalGraphics() == graphicsNone {
+		return ""
+	}
+	if thumbnail, ok := m.thumbnails[entry.GeometryImageID]; ok {
+		return thumbnail
+	}
+	var thumbnail string
+	if data, err := config.LoadGeometryImage(entry.GeometryImageID); err == nil && data != "" {
+		thumbnail = Base64ToThumbnailGraphics(data, historyThumbnailWidth, historyThumbnailHeight)
+	}
+	if m.thumbnails == nil {
+		m.thumbnails = make(map[string]string)
+	}
+	m.thumbnails[entry.GeometryImageID] = thumbnail
+	return thumbnail
+}
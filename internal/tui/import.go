@@ -0,0 +1,535 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/progress"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/kartoza/kartoza-pg-ai/internal/config"
+	"github.com/kartoza/kartoza-pg-ai/internal/postgres"
+)
+
+// importVisibleColumns is how many columns of the proposed table are
+// listed at once
+const importVisibleColumns = 12
+
+// importStep is a step of the import wizard
+type importStep int
+
+const (
+	importStepFile   importStep = iota // Choosing the file
+	importStepReview                   // Reviewing the proposed table
+	importStepLoad                     // Copying the data
+	importStepDone
+)
+
+// importPlannedMsg carries the table proposed for a file
+type importPlannedMsg struct {
+	plan *postgres.ImportPlan
+	err  error
+}
+
+// importProgressMsg is the number of records copied so far
+type importProgressMsg int
+
+// importDoneMsg carries the outcome of loading the file
+type importDoneMsg struct {
+	rows int64
+	err  error
+}
+
+// importFinishedMsg asks for the schema to be harvested again so the new
+// table, or the tables of a linked database, can be queried
+type importFinishedMsg struct{}
+
+// ImportModel is the wizard that loads a CSV or GeoJSON file into a new table
+type ImportModel struct {
+	width      int
+	height     int
+	service    *postgres.ServiceEntry
+	conn       *postgres.ConnectionManager
+	cfg        *config.Config
+	hasPostGIS bool
+
+	step         importStep
+	pathInput    textinput.Model
+	tableInput   textinput.Model
+	tableFocused bool // The table name is being edited rather than the columns
+	plan         *postgres.ImportPlan
+	selected     int  // Selected column
+	offset       int  // First column listed
+	noGeometry   bool // Geometry columns were made plain for lack of PostGIS
+	planning     bool
+	progress     progress.Model
+	copied       int
+	rows         int64
+	err          error
+	progressChan chan importProgressMsg
+	cancel       context.CancelFunc
+}
+
+// NewImportModel creates the import wizard for a service
+func NewImportModel(service *postgres.ServiceEntry, schema *config.SchemaCache, cfg *config.Config) *ImportModel {
+	pathInput := textinput.New()
+	pathInput.Placeholder = "~/data/places.csv or ~/data/parks.geojson"
+	pathInput.CharLimit = 500
+	pathInput.Width = 60
+	pathInput.Prompt = ""
+	pathInput.Focus()
+
+	tableInput := textinput.New()
+	tableInput.CharLimit = 127
+	tableInput.Width = 40
+	tableInput.Prompt = ""
+
+	prog := progress.New(
+		progress.WithDefaultGradient(),
+		progress.WithWidth(50),
+		progress.WithoutPercentage(),
+	)
+	prog.FullColor = string(ColorOrange)
+	prog.EmptyColor = string(ColorDarkGray)
+
+	return &ImportModel{
+		service:    service,
+		conn:       sharedConnection(service, cfg),
+		cfg:        cfg,
+		hasPostGIS: schema != nil && schema.HasPostGIS,
+		pathInput:  pathInput,
+		tableInput: tableInput,
+		progress:   prog,
+	}
+}
+
+// Init initializes the import wizard
+func (m *ImportModel) Init() tea.Cmd {
+	return textinput.Blink
+}
+
+// writeModeEnabled reports whether the service may be written to
+func (m *ImportModel) writeModeEnabled() bool {
+	return m.cfg != nil && m.service != nil && m.cfg.SettingsFor(m.service.Name).WriteModeEnabled
+}
+
+// targetSchema returns the schema new tables go in by default: the first
+// of the service's profile schemas, or public
+func (m *ImportModel) targetSchema() string {
+	if m.cfg != nil && m.service != nil {
+		if schemas := m.cfg.Profile(m.service.Name).Schemas; len(schemas) > 0 {
+			return schemas[0]
+		}
+	}
+	return "public"
+}
+
+// planImport reads the file and proposes a table for it
+func (m *ImportModel) planImport(path string) tea.Cmd {
+	schema := m.targetSchema()
+	return func() tea.Msg {
+		if strings.HasPrefix(path, "~/") || strings.HasPrefix(path, `~\`) {
+			if home, err := os.UserHomeDir(); err == nil {
+				path = filepath.Join(home, path[2:])
+			}
+		}
+		plan, err := postgres.PlanImport(path, schema)
+		return importPlannedMsg{plan: plan, err: err}
+	}
+}
+
+// listenForProgress listens for copy progress from the loading goroutine
+func (m *ImportModel) listenForProgress() tea.Cmd {
+	return func() tea.Msg {
+		msg, ok := <-m.progressChan
+		if !ok {
+			return nil
+		}
+		return msg
+	}
+}
+
+// startLoad creates the table and copies the file into it
+func (m *ImportModel) startLoad() tea.Cmd {
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancel = cancel
+	if m.cfg != nil && m.service != nil {
+		ctx = postgres.WithStatementTimeout(ctx, m.cfg.SettingsFor(m.service.Name).StatementTimeout())
+	}
+	m.progressChan = make(chan importProgressMsg, 100)
+	plan, conn, progressChan := m.plan, m.conn, m.progressChan
+	load := func() tea.Msg {
+		defer close(progressChan)
+		if conn == nil {
+			return importDoneMsg{err: fmt.Errorf("no service configured")}
+		}
+		db, err := conn.Connect(ctx)
+		if err != nil {
+			return importDoneMsg{err: err}
+		}
+		rows, err := plan.Load(ctx, db, func(copied int) {
+			select {
+			case progressChan <- importProgressMsg(copied):
+			default:
+				// Channel full, skip this update
+			}
+		})
+		return importDoneMsg{rows: rows, err: err}
+	}
+	return tea.Batch(load, m.listenForProgress())
+}
+
+// setTarget applies the schema-qualified table name typed by the user
+func (m *ImportModel) setTarget() error {
+	target := strings.TrimSpace(m.tableInput.Value())
+	schema, table := m.targetSchema(), target
+	if dot := strings.Index(target, "."); dot >= 0 {
+		schema, table = target[:dot], target[dot+1:]
+	}
+	if schema == "" || table == "" {
+		return fmt.Errorf("enter the new table as name or schema.name")
+	}
+	m.plan.Schema, m.plan.Table = schema, table
+	return nil
+}
+
+// cycleType gives the selected column the next or previous import type
+func (m *ImportModel) cycleType(delta int) {
+	column := &m.plan.Columns[m.selected]
+	if column.IsGeometry() {
+		return
+	}
+	types := postgres.ImportTypes
+	current := 0
+	for i, t := range types {
+		if t == column.Type {
+			current = i
+		}
+	}
+	column.Type = types[(current+delta+len(types))%len(types)]
+}
+
+// Update handles messages for the import wizard
+func (m *ImportModel) Update(msg tea.Msg) (*ImportModel, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		m.height = msg.Height
+		m.progress.Width = min(50, msg.Width-20)
+		return m, nil
+
+	case importPlannedMsg:
+		m.planning = false
+		m.err = msg.err
+		if msg.err != nil {
+			return m, nil
+		}
+		m.plan = msg.plan
+		m.noGeometry = false
+		if m.plan.HasGeometry() && !m.hasPostGIS {
+			m.plan.DropGeometry()
+			m.noGeometry = true
+		}
+		m.selected, m.offset = 0, 0
+		m.step = importStepReview
+		m.pathInput.Blur()
+		m.tableInput.SetValue(m.plan.Schema + "." + m.plan.Table)
+		m.tableFocused = false
+		return m, nil
+
+	case importProgressMsg:
+		m.copied = int(msg)
+		return m, m.listenForProgress()
+
+	case importDoneMsg:
+		m.step = importStepDone
+		m.rows = msg.rows
+		m.err = msg.err
+		return m, nil
+
+	case progress.FrameMsg:
+		progressModel, cmd := m.progress.Update(msg)
+		m.progress = progressModel.(progress.Model)
+		return m, cmd
+
+	case tea.KeyMsg:
+		return m.handleKey(msg)
+	}
+
+	// Keep the cursor of the focused input blinking
+	var cmd tea.Cmd
+	switch {
+	case m.step == importStepFile:
+		m.pathInput, cmd = m.pathInput.Update(msg)
+	case m.step == importStepReview && m.tableFocused:
+		m.tableInput, cmd = m.tableInput.Update(msg)
+	}
+	return m, cmd
+}
+
+// handleKey handles key presses for the current step
+func (m *ImportModel) handleKey(msg tea.KeyMsg) (*ImportModel, tea.Cmd) {
+	toMenu := func() tea.Msg { return goToMenuMsg{} }
+
+	switch m.step {
+	case importStepFile:
+		switch msg.Type {
+		case tea.KeyCtrlC, tea.KeyEscape:
+			return m, toMenu
+		case tea.KeyEnter:
+			path := strings.TrimSpace(m.pathInput.Value())
+			if path == "" || m.planning {
+				return m, nil
+			}
+			m.planning = true
+			m.err = nil
+			return m, m.planImport(path)
+		}
+		var cmd tea.Cmd
+		m.pathInput, cmd = m.pathInput.Update(msg)
+		return m, cmd
+
+	case importStepReview:
+		switch msg.Type {
+		case tea.KeyCtrlC:
+			return m, toMenu
+		case tea.KeyEscape:
+			m.step = importStepFile
+			m.err = nil
+			m.tableInput.Blur()
+			m.pathInput.Focus()
+			return m, textinput.Blink
+		case tea.KeyTab, tea.KeyShiftTab:
+			m.tableFocused = !m.tableFocused
+			if m.tableFocused {
+				m.tableInput.Focus()
+				return m, textinput.Blink
+			}
+			m.tableInput.Blur()
+			return m, nil
+		case tea.KeyEnter:
+			if !m.writeModeEnabled() {
+				m.err = fmt.Errorf("importing creates a table: enable Write Mode in settings or this service's profile")
+				return m, nil
+			}
+			if err := m.setTarget(); err != nil {
+				m.err = err
+				return m, nil
+			}
+			m.err = nil
+			m.copied = 0
+			m.step = importStepLoad
+			m.tableInput.Blur()
+			return m, m.startLoad()
+		}
+		if m.tableFocused {
+			var cmd tea.Cmd
+			m.tableInput, cmd = m.tableInput.Update(msg)
+			return m, cmd
+		}
+		switch msg.String() {
+		case "up", "k":
+			if m.selected > 0 {
+				m.selected--
+			}
+		case "down", "j":
+			if m.selected < len(m.plan.Columns)-1 {
+				m.selected++
+			}
+		case "left", "h":
+			m.cycleType(-1)
+		case "right", "l":
+			m.cycleType(1)
+		}
+		if m.selected < m.offset {
+			m.offset = m.selected
+		} else if m.selected >= m.offset+importVisibleColumns {
+			m.offset = m.selected - importVisibleColumns + 1
+		}
+		return m, nil
+
+	case importStepLoad:
+		if msg.Type == tea.KeyCtrlC || msg.Type == tea.KeyEscape {
+			// The transaction rolls back, leaving no table behind
+			m.cancel()
+		}
+		return m, nil
+
+	case importStepDone:
+		switch {
+		case msg.Type == tea.KeyEnter && m.err == nil:
+			return m, func() tea.Msg { return importFinishedMsg{} }
+		case msg.Type == tea.KeyEnter:
+			m.step = importStepReview
+			return m, nil
+		case msg.Type == tea.KeyCtrlC || msg.Type == tea.KeyEscape:
+			return m, toMenu
+		}
+	}
+	return m, nil
+}
+
+// View renders the import wizard
+func (m *ImportModel) View() string {
+	if m.width == 0 || m.height == 0 {
+		return ""
+	}
+
+	header := RenderHeader("Import Data")
+
+	var content, helpText string
+	switch m.step {
+	case importStepFile:
+		content, helpText = m.renderFileStep(), "enter: read file • esc: back to menu"
+	case importStepReview:
+		content = m.renderReview()
+		helpText = "↑/↓: column • ←/→: change type • tab: edit table name • enter: import • esc: choose another file"
+		if m.tableFocused {
+			helpText = "tab: back to columns • enter: import • esc: choose another file"
+		}
+	case importStepLoad:
+		content, helpText = m.renderLoad(), "esc: cancel (nothing is kept)"
+	case importStepDone:
+		content = m.renderDone()
+		helpText = "enter: harvest the schema and query the new table • esc: back to menu"
+		if m.err != nil {
+			helpText = "enter: back to the proposed table • esc: back to menu"
+		}
+	}
+
+	footer := RenderHelpFooter(helpText, m.width)
+	return LayoutWithHeaderFooter(header, content, footer, m.width, m.height)
+}
+
+// renderFileStep renders the file path prompt
+func (m *ImportModel) renderFileStep() string {
+	labelStyle := lipgloss.NewStyle().Foreground(ColorOrange).Bold(true)
+	hintStyle := lipgloss.NewStyle().Foreground(ColorGray).Italic(true)
+
+	lines := []string{
+		labelStyle.Render("File to import"),
+		"",
+		lipgloss.NewStyle().
+			Border(lipgloss.RoundedBorder()).
+			BorderForeground(ColorOrange).
+			Padding(0, 1).
+			Render(m.pathInput.View()),
+		"",
+		hintStyle.Render("CSV (.csv, .tsv, .txt) or GeoJSON (.geojson, .json). Column types and geometry are"),
+		hintStyle.Render("inferred from the values, and the data is loaded into a new table with COPY."),
+	}
+	if m.service != nil {
+		lines = append(lines, "", hintStyle.Render("Target: "+m.service.Name))
+	}
+	if m.planning {
+		lines = append(lines, "", lipgloss.NewStyle().Foreground(ColorOrange).Render("Reading file..."))
+	}
+	if m.err != nil {
+		lines = append(lines, "", ErrorStyle.Render("✗ "+m.err.Error()))
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, lines...)
+}
+
+// renderReview renders the proposed table; each column is a line of the
+// CREATE TABLE statement with where it comes from
+func (m *ImportModel) renderReview() string {
+	labelStyle := lipgloss.NewStyle().Foreground(ColorGray)
+	valueStyle := lipgloss.NewStyle().Foreground(ColorOrange).Bold(true)
+	commentStyle := lipgloss.NewStyle().Foreground(ColorGray).Italic(true)
+	selectedStyle := lipgloss.NewStyle().Foreground(ColorOrange).Bold(true)
+
+	format := "GeoJSON"
+	if m.plan.Format == postgres.ImportCSV {
+		format = fmt.Sprintf("CSV, separated by %q", m.plan.Delimiter)
+	}
+	tableBorder := ColorDarkGray
+	if m.tableFocused {
+		tableBorder = ColorOrange
+	}
+
+	lines := []string{
+		labelStyle.Render("File:   ") + valueStyle.Render(filepath.Base(m.plan.Path)) +
+			labelStyle.Render(fmt.Sprintf(" (%s, %d records)", format, m.plan.Rows)),
+		labelStyle.Render("Table:  ") + lipgloss.NewStyle().
+			Border(lipgloss.NormalBorder(), false, false, true, false).
+			BorderForeground(tableBorder).
+			Render(m.tableInput.View()),
+		"",
+	}
+
+	var body []string
+	body = append(body, highlightSQL("CREATE TABLE "+m.plan.QualifiedTable()+" ("))
+	end := min(m.offset+importVisibleColumns, len(m.plan.Columns))
+	if m.offset > 0 {
+		body = append(body, commentStyle.Render(fmt.Sprintf("    … %d more", m.offset)))
+	}
+	for i := m.offset; i < end; i++ {
+		c := m.plan.Columns[i]
+		def := fmt.Sprintf("%s %s", quoteIdentifier(c.Name), c.Type)
+		if i < len(m.plan.Columns)-1 {
+			def += ","
+		}
+		note := "-- " + c.Source
+		if c.Sample != "" {
+			note += ", e.g. " + truncate(strings.Join(strings.Fields(c.Sample), " "), 30)
+		}
+		marker := "    "
+		if i == m.selected && !m.tableFocused {
+			marker = selectedStyle.Render("  ▶ ")
+		}
+		body = append(body, marker+highlightSQL(fmt.Sprintf("%-40s", def))+" "+commentStyle.Render(note))
+	}
+	if end < len(m.plan.Columns) {
+		body = append(body, commentStyle.Render(fmt.Sprintf("    … %d more", len(m.plan.Columns)-end)))
+	}
+	body = append(body, highlightSQL(")"))
+
+	lines = append(lines, lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(ColorDarkGray).
+		Padding(0, 1).
+		Render(lipgloss.JoinVertical(lipgloss.Left, body...)))
+
+	if m.noGeometry {
+		lines = append(lines, commentStyle.Render("PostGIS is not installed, so geometry is imported as text or jsonb"))
+	}
+	if !m.writeModeEnabled() {
+		lines = append(lines, ErrorStyle.Render("⚠ Write Mode is off for this service: enable it in settings to import"))
+	}
+	if m.err != nil {
+		lines = append(lines, ErrorStyle.Render("✗ "+m.err.Error()))
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, lines...)
+}
+
+// renderLoad renders the copy progress
+func (m *ImportModel) renderLoad() string {
+	container := lipgloss.NewStyle().Width(60).Align(lipgloss.Center)
+	percent := 0.0
+	if m.plan.Rows > 0 {
+		percent = float64(m.copied) / float64(m.plan.Rows)
+	}
+	return lipgloss.JoinVertical(lipgloss.Center,
+		container.Render(fmt.Sprintf("Copying into %s", m.plan.QualifiedTable())),
+		"",
+		container.Render(m.progress.ViewAs(percent)),
+		"",
+		container.Render(fmt.Sprintf("%d / %d records", m.copied, m.plan.Rows)),
+	)
+}
+
+// renderDone renders the outcome of the import
+func (m *ImportModel) renderDone() string {
+	if m.err != nil {
+		return lipgloss.JoinVertical(lipgloss.Left,
+			ErrorStyle.Render("✗ Import failed; nothing was created"),
+			"",
+			ErrorStyle.Render(m.err.Error()),
+		)
+	}
+	return SuccessStyle.Render(fmt.Sprintf("✓ Imported %d rows into %s", m.rows, m.plan.QualifiedTable()))
+}
@@ -0,0 +1,324 @@
+package tui
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/kartoza/kartoza-pg-ai/internal/logging"
+)
+
+// logsRefreshInterval is how often the log screen reads the file again
+const logsRefreshInterval = 2 * time.Second
+
+// logsMaxEntries bounds the entries read back from the log
+const logsMaxEntries = 2000
+
+// goToLogsMsg requests the log screen
+type goToLogsMsg struct{}
+
+// logsTickMsg asks a log screen to read the file again
+type logsTickMsg struct {
+	model *LogsModel // The screen that scheduled it; ticks of closed screens are dropped
+}
+
+// logsReadMsg carries the entries read from the log
+type logsReadMsg struct {
+	entries []logging.Entry
+	err     error
+}
+
+// LogsModel shows the application log, newest at the bottom
+type LogsModel struct {
+	width    int
+	height   int
+	path     string
+	entries  []logging.Entry
+	err      error
+	loading  bool
+	minLevel slog.Level
+	selected int  // Index among the entries at or above minLevel
+	offset   int  // First entry shown
+	follow   bool // Keep the newest entry selected as entries arrive
+	expanded bool // Show every attribute of the selected entry
+}
+
+// NewLogsModel creates the log screen
+func NewLogsModel() *LogsModel {
+	return &LogsModel{
+		path:     logging.Path(),
+		minLevel: slog.LevelDebug,
+		follow:   true,
+	}
+}
+
+// Init reads the log and starts the refresh timer
+func (m *LogsModel) Init() tea.Cmd {
+	m.loading = true
+	return tea.Batch(m.read(), m.tick())
+}
+
+// tick schedules the next read
+func (m *LogsModel) tick() tea.Cmd {
+	return tea.Tick(logsRefreshInterval, func(time.Time) tea.Msg {
+		return logsTickMsg{model: m}
+	})
+}
+
+// read loads the newest entries of the log
+func (m *LogsModel) read() tea.Cmd {
+	path := m.path
+	return func() tea.Msg {
+		if path == "" {
+			return logsReadMsg{err: fmt.Errorf("logging is not enabled")}
+		}
+		entries, err := logging.ReadEntries(path, logsMaxEntries)
+		return logsReadMsg{entries: entries, err: err}
+	}
+}
+
+// filtered returns the entries at or above the chosen level
+func (m *LogsModel) filtered() []logging.Entry {
+	var entries []logging.Entry
+	for _, e := range m.entries {
+		if e.Level >= m.minLevel {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+// Update handles messages for the log screen
+func (m *LogsModel) Update(msg tea.Msg) (*LogsModel, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		m.height = msg.Height
+		return m, nil
+
+	case logsTickMsg:
+		if msg.model != m {
+			return m, nil
+		}
+		if m.loading {
+			return m, m.tick()
+		}
+		m.loading = true
+		return m, tea.Batch(m.read(), m.tick())
+
+	case logsReadMsg:
+		m.loading = false
+		m.err = msg.err
+		if msg.err == nil {
+			m.entries = msg.entries
+		}
+		m.clampSelection()
+		return m, nil
+
+	case tea.KeyMsg:
+		count := len(m.filtered())
+		switch msg.String() {
+		case "ctrl+c", "esc", "q":
+			return m, func() tea.Msg { return goToSettingsMsg{} }
+		case "up", "k":
+			m.moveTo(m.selected - 1)
+		case "down", "j":
+			m.moveTo(m.selected + 1)
+		case "pgup":
+			m.moveTo(m.selected - m.listHeight())
+		case "pgdown":
+			m.moveTo(m.selected + m.listHeight())
+		case "home", "g":
+			m.moveTo(0)
+		case "end", "G":
+			m.moveTo(count - 1)
+		case "f":
+			m.follow = !m.follow
+			m.clampSelection()
+		case "enter":
+			m.expanded = !m.expanded
+		case "l":
+			// Cycle the least severe level shown, keeping the selected entry when it is still shown
+			entries := m.filtered()
+			var current *logging.Entry
+			if m.selected < len(entries) {
+				current = &entries[m.selected]
+			}
+			m.minLevel = logging.ParseLevel(nextOption(logging.Levels, strings.ToLower(m.minLevel.String())))
+			if current != nil && !m.follow {
+				for i, e := range m.filtered() {
+					if e.Time.Equal(current.Time) && e.Message == current.Message {
+						m.selected = i
+						break
+					}
+				}
+			}
+			m.clampSelection()
+		case "r":
+			if !m.loading {
+				m.loading = true
+				return m, m.read()
+			}
+		}
+	}
+	return m, nil
+}
+
+// moveTo selects entry i; selecting the newest entry resumes following
+func (m *LogsModel) moveTo(i int) {
+	count := len(m.filtered())
+	if count == 0 {
+		return
+	}
+	m.selected = max(0, min(i, count-1))
+	m.follow = m.selected == count-1
+	m.clampSelection()
+}
+
+// clampSelection keeps the selection among the entries shown, on the
+// newest when following, and scrolls the list to it
+func (m *LogsModel) clampSelection() {
+	count := len(m.filtered())
+	if m.follow || m.selected >= count {
+		m.selected = count - 1
+	}
+	m.selected = max(0, m.selected)
+
+	height := m.listHeight()
+	if m.selected < m.offset {
+		m.offset = m.selected
+	}
+	if m.selected >= m.offset+height {
+		m.offset = m.selected - height + 1
+	}
+	m.offset = max(0, min(m.offset, count-height))
+}
+
+// detailHeight is the number of lines of the expanded entry
+func (m *LogsModel) detailHeight() int {
+	if !m.expanded {
+		return 0
+	}
+	return max(6, (m.height-8)/3)
+}
+
+// listHeight is the number of entries that fit above the detail pane
+func (m *LogsModel) listHeight() int {
+	return max(3, m.height-10-m.detailHeight())
+}
+
+// View renders the log screen
+func (m *LogsModel) View() string {
+	if m.width == 0 || m.height == 0 {
+		return ""
+	}
+
+	header := RenderHeader("Logs")
+
+	labelStyle := lipgloss.NewStyle().Foreground(ColorGray)
+	valueStyle := lipgloss.NewStyle().Foreground(ColorOrange).Bold(true)
+
+	entries := m.filtered()
+	summary := labelStyle.Render("File: ") + valueStyle.Render(m.path) +
+		labelStyle.Render(fmt.Sprintf(" • %s and above • %d entries", strings.ToLower(m.minLevel.String()), len(entries)))
+	if m.follow {
+		summary += lipgloss.NewStyle().Foreground(ColorGreen).Render(" • following")
+	}
+
+	lines := []string{summary, ""}
+	if m.err != nil {
+		lines = append(lines, ErrorStyle.Render("✗ "+m.err.Error()), "")
+	}
+
+	height := m.listHeight()
+	switch {
+	case len(entries) == 0 && m.loading:
+		lines = append(lines, labelStyle.Render("Reading the log..."))
+	case len(entries) == 0:
+		lines = append(lines, labelStyle.Render("No entries at this level yet"))
+	}
+	end := min(len(entries), m.offset+height)
+	for i := m.offset; i < end; i++ {
+		lines = append(lines, m.renderEntry(entries[i], i == m.selected))
+	}
+	for i := end - m.offset; i < height; i++ {
+		lines = append(lines, "")
+	}
+
+	if m.expanded && m.selected < len(entries) {
+		lines = append(lines, "", m.renderDetail(entries[m.selected]))
+	}
+
+	helpText := "↑/↓ pgup/pgdn: scroll • enter: details • l: level • f: follow • r: reload • esc: back"
+	footer := RenderHelpFooter(helpText, m.width)
+
+	content := lipgloss.NewStyle().Width(m.width - 4).Render(strings.Join(lines, "\n"))
+	return LayoutWithHeaderFooter(header, content, footer, m.width, m.height)
+}
+
+// levelStyle returns the colour of a level's label
+func levelStyle(level slog.Level) lipgloss.Style {
+	style := lipgloss.NewStyle().Bold(true)
+	switch {
+	case level >= slog.LevelError:
+		return style.Foreground(ColorRed)
+	case level >= slog.LevelWarn:
+		return style.Foreground(ColorOrange)
+	case level >= slog.LevelInfo:
+		return style.Foreground(ColorCyan)
+	default:
+		return style.Foreground(ColorGray)
+	}
+}
+
+// renderEntry renders one entry on a line
+func (m *LogsModel) renderEntry(e logging.Entry, selected bool) string {
+	timeStyle := lipgloss.NewStyle().Foreground(ColorGray)
+	textStyle := lipgloss.NewStyle().Foreground(ColorWhite)
+	prefix := "  "
+	if selected {
+		prefix = lipgloss.NewStyle().Foreground(ColorOrange).Render("▶ ")
+		textStyle = textStyle.Bold(true)
+	}
+
+	text := strings.Join(strings.Fields(e.Line()), " ")
+	return prefix +
+		timeStyle.Render(e.Time.Local().Format("01-02 15:04:05")) + " " +
+		levelStyle(e.Level).Render(padOrTruncate(e.Level.String(), 5)) + " " +
+		textStyle.Render(truncate(text, max(10, m.width-32)))
+}
+
+// renderDetail renders every attribute of the selected entry in a box
+func (m *LogsModel) renderDetail(e logging.Entry) string {
+	keyStyle := lipgloss.NewStyle().Foreground(ColorCyan)
+	width := m.width - 10
+
+	lines := []string{
+		levelStyle(e.Level).Render(e.Level.String()) + " " +
+			lipgloss.NewStyle().Foreground(ColorGray).Render(e.Time.Local().Format("2006-01-02 15:04:05.000")),
+		lipgloss.NewStyle().Bold(true).Render(e.Message),
+	}
+	for _, a := range e.Attrs {
+		value := a.Value
+		if a.Key == "sql" {
+			value = highlightSQL(value)
+		}
+		lines = append(lines, keyStyle.Render(a.Key+": ")+value)
+	}
+
+	body := lipgloss.NewStyle().Width(width - 4).Render(strings.Join(lines, "\n"))
+	bodyLines := strings.Split(body, "\n")
+	if limit := m.detailHeight(); len(bodyLines) > limit {
+		bodyLines = append(bodyLines[:limit-1], lipgloss.NewStyle().Foreground(ColorGray).Render("…"))
+	}
+
+	return lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(ColorDarkGray).
+		Padding(0, 1).
+		Width(width).
+		Render(strings.Join(bodyLines, "\n"))
+}
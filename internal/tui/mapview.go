@@ -0,0 +1,193 @@
+package tui
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Map view zoom limits, relative to the extent of all geometries
+const (
+	minMapZoom = 0.25
+	maxMapZoom = 4096
+)
+
+// mapPanStep is the fraction of the visible extent moved per arrow key press
+const mapPanStep = 0.25
+
+// MapViewModel shows a result's geometries in an interactive map that can be
+// panned and zoomed. Every move re-renders the PNG for the new viewport.
+type MapViewModel struct {
+	width  int
+	height int
+	layers [][]string // Geometry values, one layer per previewed column
+	labels []string   // Column name of each layer
+	full   Extent     // Extent of all geometries (zoom 1)
+	view   Extent     // Currently visible extent
+	image  string     // Kitty graphics or sixel escape sequence for the current view
+	err    string
+}
+
+// NewMapViewModel creates a map view of the geometry columns currently
+// previewed for results. Returns nil if there is nothing to draw.
+func NewMapViewModel(results *QueryResults, width, height int) *MapViewModel {
+	if results == nil {
+		return nil
+	}
+
+	layers := results.geometryLayers()
+	full, ok := LayersExtent(layers)
+	if !ok {
+		return nil
+	}
+
+	var labels []string
+	for _, col := range results.previewColumns() {
+		if col < len(results.Columns) {
+			labels = append(labels, results.Columns[col])
+		} else {
+			labels = append(labels, "?")
+		}
+	}
+
+	m := &MapViewModel{
+		width:  width,
+		height: height,
+		layers: layers,
+		labels: labels,
+		full:   full,
+		view:   full,
+	}
+	m.render()
+	return m
+}
+
+// Update handles pan/zoom keys; closing the view is left to the parent
+func (m *MapViewModel) Update(msg tea.Msg) (*MapViewModel, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		m.height = msg.Height
+		m.render()
+
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "+", "=":
+			m.zoom(2)
+		case "-", "_":
+			m.zoom(0.5)
+		case "left", "h":
+			m.pan(-mapPanStep, 0)
+		case "right", "l":
+			m.pan(mapPanStep, 0)
+		case "up", "k":
+			m.pan(0, mapPanStep)
+		case "down", "j":
+			m.pan(0, -mapPanStep)
+		case "0", "r":
+			m.view = m.full
+			m.render()
+		}
+	}
+	return m, nil
+}
+
+// zoomLevel returns the current magnification relative to the full extent
+func (m *MapViewModel) zoomLevel() float64 {
+	w := m.view.MaxX - m.view.MinX
+	if w == 0 {
+		return 1
+	}
+	return (m.full.MaxX - m.full.MinX) / w
+}
+
+// zoom scales the view around its centre by factor (>1 zooms in)
+func (m *MapViewModel) zoom(factor float64) {
+	next := m.zoomLevel() * factor
+	if next < minMapZoom || next > maxMapZoom {
+		return
+	}
+
+	c := m.view.Center()
+	halfW := (m.view.MaxX - m.view.MinX) / factor / 2
+	halfH := (m.view.MaxY - m.view.MinY) / factor / 2
+	m.view = Extent{MinX: c.X - halfW, MinY: c.Y - halfH, MaxX: c.X + halfW, MaxY: c.Y + halfH}
+	m.render()
+}
+
+// pan moves the view by fractions of its width and height
+func (m *MapViewModel) pan(fx, fy float64) {
+	dx := (m.view.MaxX - m.view.MinX) * fx
+	dy := (m.view.MaxY - m.view.MinY) * fy
+	m.view = Extent{MinX: m.view.MinX + dx, MinY: m.view.MinY + dy, MaxX: m.view.MaxX + dx, MaxY: m.view.MaxY + dy}
+	m.render()
+}
+
+// imageSize picks a PNG size that fits the terminal, assuming roughly
+// 7x14 pixel cells and leaving room for the header and footer
+func (m *MapViewModel) imageSize() (int, int) {
+	w := max(300, min((m.width-10)*7, 1200))
+	h := max(200, min((m.height-12)*14, 800))
+	return w, h
+}
+
+// render re-renders the geometries for the current viewport
+func (m *MapViewModel) render() {
+	w, h := m.imageSize()
+	renderer := NewGeometryRenderer(w, h)
+	renderer.Viewport = &m.view
+
+	pngData, err := renderer.RenderLayers(m.layers)
+	if err != nil {
+		m.err = err.Error()
+		m.image = ""
+		return
+	}
+	m.err = ""
+	m.image = Base64ToTerminalGraphics(base64.StdEncoding.EncodeToString(pngData))
+}
+
+// View renders the map screen
+func (m *MapViewModel) View() string {
+	header := RenderHeader("Map View")
+
+	var legend []string
+	for i, label := range m.labels {
+		style := lipgloss.NewStyle().Foreground(ColorOrange).Bold(true)
+		if len(m.labels) > 1 {
+			c := layerColors[i%len(layerColors)]
+			style = style.Foreground(lipgloss.Color(fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)))
+		}
+		legend = append(legend, style.Render(label))
+	}
+
+	c := m.view.Center()
+	status := lipgloss.NewStyle().Foreground(ColorGray).Render(
+		fmt.Sprintf("Zoom %.4gx • centre %.6g, %.6g", m.zoomLevel(), c.X, c.Y))
+
+	body := m.image
+	if m.err != "" {
+		body = ErrorStyle.Render("Render failed: " + m.err)
+	}
+
+	content := lipgloss.JoinVertical(lipgloss.Center,
+		"🗺️  "+strings.Join(legend, " | "),
+		status,
+		"",
+		body,
+	)
+
+	centeredContent := lipgloss.NewStyle().
+		Width(m.width-10).
+		Height(m.height-10).
+		Align(lipgloss.Center, lipgloss.Center).
+		Render(content)
+
+	helpText := "+/-: zoom • ←/→/↑/↓ or h/j/k/l: pan • 0: reset • esc/q: close"
+	footer := RenderHelpFooter(helpText, m.width)
+
+	return LayoutWithHeaderFooter(header, centeredContent, footer, m.width, m.height)
+}
@@ -13,8 +13,13 @@ type MenuItem int
 
 const (
 	MenuQuery MenuItem = iota
+	MenuResume
 	MenuDatabases
 	MenuHistory
+	MenuImport
+	MenuFederation
+	MenuNotifications
+	MenuStatus
 	MenuSettings
 	MenuQuit
 )
@@ -41,8 +46,13 @@ func NewMenuModel() *MenuModel {
 		selectedItem: 0,
 		menuItems: []menuItem{
 			{label: "Query Database", enabled: true, action: MenuQuery, icon: "󰆼"},
+			{label: "Resume Last Conversation", enabled: true, action: MenuResume, icon: "󰦛"},
 			{label: "Database Connections", enabled: true, action: MenuDatabases, icon: "󰒋"},
 			{label: "Query History", enabled: true, action: MenuHistory, icon: "󰋚"},
+			{label: "Import Data", enabled: true, action: MenuImport, icon: "󰋺"},
+			{label: "Link Remote Database", enabled: true, action: MenuFederation, icon: "󰌷"},
+			{label: "Listen for Notifications", enabled: true, action: MenuNotifications, icon: "󰂚"},
+			{label: "Database Status", enabled: true, action: MenuStatus, icon: "󰓅"},
 			{label: "Settings", enabled: true, action: MenuSettings, icon: "󰒓"},
 			{label: "Quit", enabled: true, action: MenuQuit, icon: "󰗼"},
 		},
@@ -102,6 +112,10 @@ func (m *MenuModel) handleSelection(action MenuItem) tea.Cmd {
 		return func() tea.Msg {
 			return menuActionMsg{action: MenuQuery}
 		}
+	case MenuResume:
+		return func() tea.Msg {
+			return menuActionMsg{action: MenuResume}
+		}
 	case MenuDatabases:
 		return func() tea.Msg {
 			return menuActionMsg{action: MenuDatabases}
@@ -110,6 +124,22 @@ func (m *MenuModel) handleSelection(action MenuItem) tea.Cmd {
 		return func() tea.Msg {
 			return menuActionMsg{action: MenuHistory}
 		}
+	case MenuImport:
+		return func() tea.Msg {
+			return menuActionMsg{action: MenuImport}
+		}
+	case MenuFederation:
+		return func() tea.Msg {
+			return menuActionMsg{action: MenuFederation}
+		}
+	case MenuNotifications:
+		return func() tea.Msg {
+			return menuActionMsg{action: MenuNotifications}
+		}
+	case MenuStatus:
+		return func() tea.Msg {
+			return menuActionMsg{action: MenuStatus}
+		}
 	case MenuSettings:
 		return func() tea.Msg {
 			return menuActionMsg{action: MenuSettings}
@@ -0,0 +1,324 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/progress"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/kartoza/kartoza-pg-ai/internal/config"
+	"github.com/kartoza/kartoza-pg-ai/internal/llm"
+	"github.com/kartoza/kartoza-pg-ai/internal/logging"
+)
+
+// goToModelsMsg requests the local model download screen
+type goToModelsMsg struct{}
+
+// modelsInstalledMsg carries the recommended models already downloaded
+type modelsInstalledMsg struct {
+	model     *ModelsModel // The screen that asked; answers for closed screens are dropped
+	installed map[string]bool
+	err       error
+}
+
+// modelProgressMsg reports how far the download in progress has got
+type modelProgressMsg llm.DownloadProgress
+
+// modelProgressClosedMsg signals that a download's progress channel was closed
+type modelProgressClosedMsg struct{}
+
+// modelDownloadedMsg carries the outcome of a download
+type modelDownloadedMsg struct {
+	model *ModelsModel
+	local llm.LocalModel
+	ref   string // Ollama tag or GGUF path, as returned by llm.DownloadModel
+	err   error
+}
+
+// ModelsModel lists the recommended local models, downloads the chosen
+// one and makes it the LLM provider
+type ModelsModel struct {
+	width     int
+	height    int
+	cfg       *config.Config
+	selected  int
+	installed map[string]bool
+	ollamaErr error // Why Ollama's models could not be listed
+
+	progress     progress.Model
+	downloading  *llm.LocalModel // Model being downloaded (nil when idle)
+	current      llm.DownloadProgress
+	progressChan chan modelProgressMsg
+	cancel       context.CancelFunc
+
+	status string // Outcome of the latest download
+	err    error
+}
+
+// NewModelsModel creates the local model download screen
+func NewModelsModel(cfg *config.Config) *ModelsModel {
+	prog := progress.New(
+		progress.WithDefaultGradient(),
+		progress.WithWidth(50),
+		progress.WithoutPercentage(),
+	)
+	prog.FullColor = string(ColorOrange)
+	prog.EmptyColor = string(ColorDarkGray)
+
+	return &ModelsModel{cfg: cfg, progress: prog}
+}
+
+// Init looks up the models already downloaded
+func (m *ModelsModel) Init() tea.Cmd {
+	return m.checkInstalled()
+}
+
+// checkInstalled asks which recommended models are downloaded
+func (m *ModelsModel) checkInstalled() tea.Cmd {
+	baseURL := m.cfg.Settings.OllamaBaseURL
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		installed, err := llm.InstalledModels(ctx, baseURL)
+		return modelsInstalledMsg{model: m, installed: installed, err: err}
+	}
+}
+
+// listenForProgress waits for the next progress report of the download
+func (m *ModelsModel) listenForProgress() tea.Cmd {
+	ch := m.progressChan
+	return func() tea.Msg {
+		msg, ok := <-ch
+		if !ok {
+			return modelProgressClosedMsg{}
+		}
+		return msg
+	}
+}
+
+// startDownload downloads the selected model in the background
+func (m *ModelsModel) startDownload() tea.Cmd {
+	local := llm.RecommendedModels[m.selected]
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancel = cancel
+	m.downloading = &local
+	m.current = llm.DownloadProgress{Status: "starting"}
+	m.status, m.err = "", nil
+	m.progressChan = make(chan modelProgressMsg, 100)
+	ch := m.progressChan
+	baseURL := m.cfg.Settings.OllamaBaseURL
+
+	logging.Info("model download started", "model", local.Name, "provider", local.Provider)
+	download := func() tea.Msg {
+		defer close(ch)
+		ref, err := llm.DownloadModel(ctx, local, baseURL, func(p llm.DownloadProgress) {
+			select {
+			case ch <- modelProgressMsg(p):
+			default:
+				// Channel full, skip this update
+			}
+		})
+		return modelDownloadedMsg{model: m, local: local, ref: ref, err: err}
+	}
+	return tea.Batch(download, m.listenForProgress())
+}
+
+// Update handles messages for the local model download screen
+func (m *ModelsModel) Update(msg tea.Msg) (*ModelsModel, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		m.height = msg.Height
+		m.progress.Width = min(50, msg.Width-20)
+		return m, nil
+
+	case modelsInstalledMsg:
+		if msg.model != m {
+			return m, nil
+		}
+		m.installed = msg.installed
+		m.ollamaErr = msg.err
+		return m, nil
+
+	case modelProgressMsg:
+		if m.downloading == nil {
+			return m, nil
+		}
+		m.current = llm.DownloadProgress(msg)
+		return m, m.listenForProgress()
+
+	case modelProgressClosedMsg:
+		return m, nil
+
+	case modelDownloadedMsg:
+		if msg.model != m {
+			return m, nil
+		}
+		m.downloading = nil
+		m.cancel()
+		if msg.err != nil {
+			logging.Warn("model download failed", "model", msg.local.Name, "error", msg.err)
+			m.err = msg.err
+			return m, nil
+		}
+		logging.Info("model downloaded", "model", msg.local.Name, "ref", msg.ref)
+		return m, m.use(msg.local, msg.ref)
+
+	case progress.FrameMsg:
+		progressModel, cmd := m.progress.Update(msg)
+		m.progress = progressModel.(progress.Model)
+		return m, cmd
+
+	case tea.KeyMsg:
+		if m.downloading != nil {
+			if msg.Type == tea.KeyEscape || msg.Type == tea.KeyCtrlC {
+				// A cancelled GGUF download resumes from its partial file next time
+				m.cancel()
+			}
+			return m, nil
+		}
+		switch msg.String() {
+		case "ctrl+c", "esc", "q":
+			return m, func() tea.Msg { return goToSettingsMsg{} }
+		case "up", "k":
+			m.selected = max(0, m.selected-1)
+		case "down", "j":
+			m.selected = min(len(llm.RecommendedModels)-1, m.selected+1)
+		case "enter":
+			local := llm.RecommendedModels[m.selected]
+			if m.installed[local.Name] {
+				ref := local.Name
+				if local.Provider == llm.ProviderLlamaCpp {
+					path, err := llm.LocalModelPath(local)
+					if err != nil {
+						m.err = err
+						return m, nil
+					}
+					ref = path
+				}
+				return m, m.use(local, ref)
+			}
+			return m, m.startDownload()
+		case "r":
+			return m, m.checkInstalled()
+		}
+	}
+	return m, nil
+}
+
+// use makes a downloaded model the LLM provider
+func (m *ModelsModel) use(local llm.LocalModel, ref string) tea.Cmd {
+	llm.UseLocalModel(&m.cfg.Settings, local, ref)
+	if err := m.cfg.Save(); err != nil {
+		m.err = fmt.Errorf("could not save settings: %w", err)
+		return nil
+	}
+	m.err = nil
+	m.status = fmt.Sprintf("✓ %s/%s now answers questions", local.Provider, local.Name)
+	if local.Provider == llm.ProviderLlamaCpp {
+		m.status += "; start its server with: llama-server -m " + ref
+	}
+	return m.checkInstalled()
+}
+
+// View renders the local model download screen
+func (m *ModelsModel) View() string {
+	if m.width == 0 || m.height == 0 {
+		return ""
+	}
+
+	header := RenderHeader("Local Models")
+
+	labelStyle := lipgloss.NewStyle().Foreground(ColorGray)
+	textStyle := lipgloss.NewStyle().Foreground(ColorWhite)
+	installedStyle := lipgloss.NewStyle().Foreground(ColorGreen)
+	activeStyle := lipgloss.NewStyle().Foreground(ColorOrange).Bold(true)
+
+	modelsDir, _ := config.ModelsDir()
+	lines := []string{
+		labelStyle.Render("Models that answer questions without a hosted provider. Ollama models are pulled into Ollama;"),
+		labelStyle.Render("GGUF models are saved in " + modelsDir + " for llama.cpp."),
+		"",
+	}
+
+	lastProvider := ""
+	for i, local := range llm.RecommendedModels {
+		if local.Provider != lastProvider {
+			if lastProvider != "" {
+				lines = append(lines, "")
+			}
+			lines = append(lines, lipgloss.NewStyle().Foreground(ColorCyan).Bold(true).Render(localProviderTitle(local.Provider)))
+			lastProvider = local.Provider
+		}
+
+		prefix := "  "
+		style := textStyle
+		if i == m.selected {
+			prefix = activeStyle.Render("▶ ")
+			style = style.Bold(true)
+		}
+		state := labelStyle.Render(fmt.Sprintf("%8s", config.HumanBytes(local.Size)))
+		switch {
+		case m.isActive(local):
+			state = activeStyle.Render("  in use")
+		case m.installed[local.Name]:
+			state = installedStyle.Render("✓ ready ")
+		}
+		lines = append(lines, prefix+style.Render(padOrTruncate(local.Name, 40))+" "+state+"  "+labelStyle.Render(local.Description))
+	}
+	if m.ollamaErr != nil {
+		lines = append(lines, "", lipgloss.NewStyle().Foreground(ColorOrange).Render("⚠ "+m.ollamaErr.Error()))
+	}
+	lines = append(lines, "")
+
+	helpText := "↑/↓: select • enter: download and use • r: refresh • esc: back"
+	switch {
+	case m.downloading != nil:
+		percent := 0.0
+		if m.current.Total > 0 {
+			percent = float64(m.current.Completed) / float64(m.current.Total)
+		}
+		detail := m.current.Status
+		if m.current.Total > 0 {
+			detail += fmt.Sprintf(" %s / %s", config.HumanBytes(m.current.Completed), config.HumanBytes(m.current.Total))
+		}
+		lines = append(lines,
+			textStyle.Render("Downloading "+m.downloading.Name),
+			m.progress.ViewAs(percent),
+			labelStyle.Render(detail),
+		)
+		helpText = "esc: cancel download"
+	case m.err != nil:
+		lines = append(lines, ErrorStyle.Render("✗ "+m.err.Error()))
+	case m.status != "":
+		lines = append(lines, installedStyle.Bold(true).Render(m.status))
+	}
+
+	footer := RenderHelpFooter(helpText, m.width)
+	content := lipgloss.NewStyle().Width(m.width - 4).Render(strings.Join(lines, "\n"))
+	return LayoutWithHeaderFooter(header, content, footer, m.width, m.height)
+}
+
+// isActive reports whether a model is the configured LLM provider's
+func (m *ModelsModel) isActive(local llm.LocalModel) bool {
+	s := m.cfg.Settings
+	if s.LLMProvider != local.Provider {
+		return false
+	}
+	if local.Provider == llm.ProviderOllama {
+		return s.OllamaModel == local.Name
+	}
+	path, err := llm.LocalModelPath(local)
+	return err == nil && s.LLMModelPath == path
+}
+
+// localProviderTitle heads the models of a provider
+func localProviderTitle(provider string) string {
+	if provider == llm.ProviderLlamaCpp {
+		return "llama.cpp (GGUF)"
+	}
+	return "Ollama"
+}
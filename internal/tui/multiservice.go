@@ -0,0 +1,327 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/kartoza/kartoza-pg-ai/internal/config"
+	"github.com/kartoza/kartoza-pg-ai/internal/llm"
+	"github.com/kartoza/kartoza-pg-ai/internal/logging"
+	"github.com/kartoza/kartoza-pg-ai/internal/postgres"
+	"github.com/kartoza/kartoza-pg-ai/internal/tracing"
+)
+
+// servicePickerState tracks the services a question is to be asked on,
+// e.g. staging and production, while they are chosen
+type servicePickerState struct {
+	query    string                  // Question asked
+	sqlEdit  *sqlEditState           // SQL in the editor to run instead of generating it (nil when none)
+	services []postgres.ServiceEntry // Every service of the service file
+	chosen   map[string]bool
+	selected int // Highlighted service
+}
+
+// serviceAnswer is the result of a question on one of several services
+type serviceAnswer struct {
+	service string
+	results *QueryResults
+	err     error
+}
+
+// servicesAnsweredMsg carries the answers of a question asked on several
+// services, in the order the services were chosen in
+type servicesAnsweredMsg struct {
+	query   string
+	answers []serviceAnswer
+	err     error // Generating the SQL failed, so no service was asked
+}
+
+// openServicePicker starts choosing the services to ask the question in
+// the editor on, the current service and those chosen last time ticked
+func (m *QueryModel) openServicePicker(query string) {
+	services, err := postgres.ParsePGServiceFile()
+	if err != nil {
+		m.statusMsg = "✗ Could not read the service file: " + err.Error()
+		return
+	}
+	if len(services) < 2 {
+		m.statusMsg = "✗ Asking on several services needs more than one service"
+		return
+	}
+	p := &servicePickerState{query: query, sqlEdit: m.sqlEdit, services: services, chosen: make(map[string]bool)}
+	for _, name := range m.compareServices {
+		p.chosen[name] = true
+	}
+	if m.service != nil {
+		p.chosen[m.service.Name] = true
+	}
+	m.servicePicker = p
+}
+
+// handleServicePickerKey moves between services and ticks them; Enter asks
+// the question on those ticked
+func (m *QueryModel) handleServicePickerKey(msg tea.KeyMsg) (*QueryModel, tea.Cmd) {
+	p := m.servicePicker
+	switch msg.String() {
+	case "esc":
+		m.servicePicker = nil
+	case "left", "up", "shift+tab":
+		p.selected = (p.selected + len(p.services) - 1) % len(p.services)
+	case "right", "down", "tab":
+		p.selected = (p.selected + 1) % len(p.services)
+	case " ", "x":
+		name := p.services[p.selected].Name
+		p.chosen[name] = !p.chosen[name]
+	case "a":
+		// Tick every service, or none when all are ticked
+		all := len(p.chosenServices()) == len(p.services)
+		for _, service := range p.services {
+			p.chosen[service.Name] = !all
+		}
+	case "enter":
+		services := p.chosenServices()
+		if len(services) < 2 {
+			m.statusMsg = "✗ Choose at least two services to compare"
+			return m, nil
+		}
+		m.servicePicker = nil
+		m.compareServices = m.compareServices[:0]
+		for _, service := range services {
+			m.compareServices = append(m.compareServices, service.Name)
+		}
+
+		m.loading = true
+		m.scrollOffset = 0
+		ctx := m.newQueryContext()
+		generatedSQL, sqlQuery := "", ""
+		var usage llm.Usage
+		if p.sqlEdit != nil {
+			generatedSQL, sqlQuery, usage = p.sqlEdit.generatedSQL, p.query, p.sqlEdit.usage
+			p.query = p.sqlEdit.query
+		}
+		return m, tea.Batch(
+			m.spinner.Tick,
+			m.askOnServices(ctx, p.query, generatedSQL, sqlQuery, services),
+			m.runInBackground(p.query, usage),
+		)
+	}
+	return m, nil
+}
+
+// chosenServices returns the services ticked, in service file order
+func (p *servicePickerState) chosenServices() []postgres.ServiceEntry {
+	var services []postgres.ServiceEntry
+	for _, service := range p.services {
+		if p.chosen[service.Name] {
+			services = append(services, service)
+		}
+	}
+	return services
+}
+
+// askOnServices generates SQL for a question once, on the current service's
+// schema, and runs it on every service at the same time. sqlQuery is run
+// instead when set, generatedSQL being what it was edited from. Only
+// read-only SQL is run on several services.
+func (m *QueryModel) askOnServices(ctx context.Context, query, generatedSQL, sqlQuery string, services []postgres.ServiceEntry) tea.Cmd {
+	generator := ""
+	if sqlQuery == "" {
+		generator = m.askWith()
+	}
+	cfg, batchSize := m.cfg, m.fetchBatchSize
+	return cancellable(ctx, query, func() tea.Msg {
+		if sqlQuery == "" {
+			var err error
+			if generatedSQL, err = m.generateSQL(ctx, generator, query); err != nil {
+				return servicesAnsweredMsg{query: query, err: fmt.Errorf("failed to generate SQL: %w", err)}
+			}
+			sqlQuery = generatedSQL
+		}
+		if class := postgres.ClassifyStatement(sqlQuery); class.IsMutating() {
+			return servicesAnsweredMsg{query: query, err: fmt.Errorf("only read-only SQL is run on several services, not a %s statement\nSQL: %s", class, sqlQuery)}
+		}
+		if len(postgres.SplitStatements(sqlQuery)) > 1 || len(postgres.ParseTemplate(sqlQuery).Params) > 0 {
+			return servicesAnsweredMsg{query: query, err: fmt.Errorf("scripts and templates cannot be run on several services\nSQL: %s", sqlQuery)}
+		}
+
+		answers := make([]serviceAnswer, len(services))
+		var wg sync.WaitGroup
+		for i := range services {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				answers[i] = queryService(ctx, &services[i], cfg, query, generatedSQL, sqlQuery, batchSize)
+			}()
+		}
+		wg.Wait()
+		return servicesAnsweredMsg{query: query, answers: answers}
+	})
+}
+
+// queryService runs read-only SQL on a service within its statement
+// timeout and fetches the first batch of rows. The cursor is closed
+// straight away, so the rest of the rows are counted but not fetched.
+func queryService(ctx context.Context, service *postgres.ServiceEntry, cfg *config.Config, query, generatedSQL, sqlQuery string, batchSize int) serviceAnswer {
+	answer := serviceAnswer{service: service.Name}
+	if cfg != nil {
+		ctx = postgres.WithStatementTimeout(ctx, cfg.SettingsFor(service.Name).StatementTimeout())
+	}
+	ctx, span := tracing.Start(ctx, "execute", tracing.Service.String(service.Name),
+		tracing.DBSystem.String("postgresql"), tracing.DBQueryText.String(sqlQuery), tracing.DBNamespace.String(service.DBName))
+	attrs := []any{"service", service.Name, "sql", sqlQuery}
+	fail := func(err error) serviceAnswer {
+		tracing.End(span, err)
+		logging.Error("sql failed", append(attrs, "error", err)...)
+		answer.err = err
+		return answer
+	}
+
+	db, err := sharedConnection(service, cfg).Connect(ctx)
+	if err != nil {
+		return fail(fmt.Errorf("database unavailable: %w", err))
+	}
+	count, _ := postgres.CountRows(ctx, db, sqlQuery, maxCountedRows)
+
+	start := time.Now()
+	cursor, err := postgres.OpenCursor(ctx, db, sqlQuery)
+	if err != nil {
+		return fail(fmt.Errorf("query failed: %w", err))
+	}
+	defer cursor.Close()
+	batch, err := cursor.Fetch(ctx, batchSize)
+	if err != nil {
+		return fail(fmt.Errorf("query failed: %w", err))
+	}
+	took := time.Since(start)
+	tracing.End(span, nil)
+	logging.Info("sql executed", append(attrs, "rows", len(batch), "total_rows", count.Rows, "duration_ms", took.Milliseconds())...)
+
+	rows := postgres.FormatRows(batch)
+	editedSQL := ""
+	if sqlQuery != generatedSQL {
+		editedSQL = sqlQuery
+	}
+	results := &QueryResults{
+		Columns:        cursor.Columns(),
+		ColumnTypes:    cursor.ColumnTypes(),
+		Rows:           rows,
+		Nulls:          postgres.NullRows(batch),
+		RowCount:       count.Rows,
+		RowsEstimated:  count.Estimated,
+		ExecutionTime:  took.Seconds() * 1000,
+		GeneratedSQL:   generatedSQL,
+		EditedSQL:      editedSQL,
+		NaturalQuery:   query,
+		GeometryColIdx: -1,
+	}
+	if len(rows) > 0 {
+		results.GeometryColIdx = DetectGeometryColumn(results.Columns, rows[0])
+		results.GeometryColumns = DetectGeometryColumns(results.Columns, rows[0])
+	}
+	results.renderGeometry()
+	answer.results = results
+	return answer
+}
+
+// addServiceAnswers adds an entry labelled with its service for each answer
+// of a question asked on several services, stacked in the order chosen, so
+// they can be compared by eye or with D
+func (m *QueryModel) addServiceAnswers(msg servicesAnsweredMsg) (*QueryModel, tea.Cmd) {
+	m.loading = false
+	if m.querySpan != nil {
+		tracing.Fail(m.querySpan, msg.err)
+		m.renderSpan, m.querySpan = m.querySpan, nil
+	}
+	m.releaseQueryContext()
+	m.sqlEdit = nil
+	var took time.Duration
+	if m.background != nil {
+		took = time.Since(m.background.started).Round(100 * time.Millisecond)
+		m.background = nil
+	}
+
+	if msg.err != nil {
+		m.error = msg.err.Error()
+		m.history = append(m.history, ConversationEntry{Query: msg.query, Error: msg.err.Error(), Unseen: m.focusEditor})
+		m.selectedEntry = len(m.history) - 1
+		m.statusMsg = fmt.Sprintf("✗ Failed after %s: %s", took, truncate(msg.query, 50))
+		m.saveConversation()
+		return m, nil
+	}
+
+	m.results.closeCursor()
+	failed := 0
+	for _, answer := range msg.answers {
+		entry := ConversationEntry{Query: msg.query, Service: answer.service, Unseen: m.focusEditor}
+		if answer.err != nil {
+			failed++
+			entry.Error = answer.err.Error()
+		} else {
+			r := answer.results
+			entry.SQL, entry.EditedSQL, entry.Results = r.GeneratedSQL, r.EditedSQL, r
+			entry.ShowSQL = r.EditedSQL != ""
+			m.results = r
+			GlobalAppState.QueryCount++
+			if m.cfg != nil {
+				m.cfg.AddQueryToHistory(config.QueryHistoryEntry{
+					Timestamp:     time.Now(),
+					NaturalQuery:  r.NaturalQuery,
+					GeneratedSQL:  r.GeneratedSQL,
+					EditedSQL:     r.EditedSQL,
+					ServiceName:   answer.service,
+					RowsAffected:  r.RowCount,
+					ExecutionTime: r.ExecutionTime,
+					Success:       true,
+					HasGeometry:   r.GeometryColIdx >= 0,
+					Generator:     m.askedWith,
+				})
+			}
+		}
+		m.history = append(m.history, entry)
+	}
+	m.selectedEntry = len(m.history) - 1
+	m.error = ""
+	m.scrollOffset = 0
+	m.visibleRows = defaultVisibleRows
+	m.totalFetched = 0
+	if m.results != nil {
+		m.totalFetched = len(m.results.Rows)
+	}
+	m.hasMoreRows = false
+
+	m.statusMsg = fmt.Sprintf("✓ Answered on %d services in %s: %s (D: diff two of them)", len(msg.answers), took, truncate(msg.query, 40))
+	if failed > 0 {
+		m.statusMsg = fmt.Sprintf("⚠ Answered on %d of %d services in %s: %s", len(msg.answers)-failed, len(msg.answers), took, truncate(msg.query, 40))
+	}
+	m.saveConversation()
+	return m, nil
+}
+
+// renderServicePicker renders the prompt line listing the services to tick
+func (m *QueryModel) renderServicePicker() string {
+	p := m.servicePicker
+	highlight := lipgloss.NewStyle().Foreground(ColorOrange).Bold(true)
+	dim := lipgloss.NewStyle().Foreground(ColorGray)
+
+	options := make([]string, len(p.services))
+	for i, service := range p.services {
+		box := "[ ]"
+		if p.chosen[service.Name] {
+			box = "[x]"
+		}
+		option := box + " " + service.Name
+		if i == p.selected {
+			options[i] = highlight.Render("▶ " + option)
+		} else if p.chosen[service.Name] {
+			options[i] = option
+		} else {
+			options[i] = dim.Render(option)
+		}
+	}
+	return PromptStyle.Render("🔀 Ask on: ") + strings.Join(options, "  ")
+}
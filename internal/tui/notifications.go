@@ -0,0 +1,390 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/kartoza/kartoza-pg-ai/internal/config"
+	"github.com/kartoza/kartoza-pg-ai/internal/llm"
+	"github.com/kartoza/kartoza-pg-ai/internal/postgres"
+)
+
+// notificationLogLimit bounds the notifications kept in the log
+const notificationLogLimit = 1000
+
+// notificationMsg is a notification received on a channel
+type notificationMsg postgres.Notification
+
+// listenerEventMsg reports a change in the listener's connection
+type listenerEventMsg postgres.ListenerEvent
+
+// listenerClosedMsg signals that the listener's notifications ended
+type listenerClosedMsg struct{}
+
+// channelsDiscoveredMsg carries the channels functions in the database notify
+type channelsDiscoveredMsg struct {
+	channels []string
+	err      error
+}
+
+// channelListenedMsg carries the outcome of starting or stopping listening
+type channelListenedMsg struct {
+	channel     string
+	description string // What the user typed when it was matched to channel
+	stopped     bool
+	err         error
+}
+
+// NotificationsModel is the screen that listens on channels of the active
+// service and logs the notifications that arrive
+type NotificationsModel struct {
+	width    int
+	height   int
+	service  *postgres.ServiceEntry
+	conn     *postgres.ConnectionManager
+	listener *postgres.NotificationListener
+	events   chan postgres.ListenerEvent
+	done     chan struct{} // Closed when the screen is left
+
+	input     textinput.Model
+	known     []string // Channels notified by functions in the database
+	listening []string
+	log       []postgres.Notification
+	scroll    int // Entries scrolled back from the newest
+	state     postgres.ConnState
+	stateErr  error
+	status    string
+}
+
+// NewNotificationsModel creates the notification viewer for a service
+func NewNotificationsModel(service *postgres.ServiceEntry, cfg *config.Config) *NotificationsModel {
+	input := textinput.New()
+	input.Placeholder = "orders_changed, or describe it: \"new orders\""
+	input.CharLimit = 200
+	input.Width = 50
+	input.Prompt = ""
+	input.Focus()
+
+	return &NotificationsModel{
+		service: service,
+		conn:    sharedConnection(service, cfg),
+		events:  make(chan postgres.ListenerEvent, 10),
+		done:    make(chan struct{}),
+		input:   input,
+		state:   postgres.ConnConnecting,
+	}
+}
+
+// Init opens the listener and looks for the channels the database notifies
+func (m *NotificationsModel) Init() tea.Cmd {
+	if m.service == nil {
+		m.status = "✗ No service selected"
+		return nil
+	}
+	events := m.events
+	m.listener = m.service.Listen(func(e postgres.ListenerEvent) {
+		select {
+		case events <- e:
+		default:
+			// Channel full, skip this update
+		}
+	})
+	return tea.Batch(
+		textinput.Blink,
+		m.waitForNotification(),
+		m.waitForEvent(),
+		m.discoverChannels(),
+	)
+}
+
+// waitForNotification waits for the next notification
+func (m *NotificationsModel) waitForNotification() tea.Cmd {
+	notifications := m.listener.Notifications()
+	return func() tea.Msg {
+		n, ok := <-notifications
+		if !ok {
+			return listenerClosedMsg{}
+		}
+		return notificationMsg(n)
+	}
+}
+
+// waitForEvent waits for the next change in the listener's connection
+func (m *NotificationsModel) waitForEvent() tea.Cmd {
+	events, done := m.events, m.done
+	return func() tea.Msg {
+		select {
+		case e := <-events:
+			return listenerEventMsg(e)
+		case <-done:
+			return nil
+		}
+	}
+}
+
+// discoverChannels finds the channels functions in the database notify
+func (m *NotificationsModel) discoverChannels() tea.Cmd {
+	conn := m.conn
+	return func() tea.Msg {
+		if conn == nil {
+			return channelsDiscoveredMsg{err: fmt.Errorf("no service configured")}
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		db, err := conn.Connect(ctx)
+		if err != nil {
+			return channelsDiscoveredMsg{err: err}
+		}
+		channels, err := postgres.DiscoverChannels(ctx, db)
+		return channelsDiscoveredMsg{channels: channels, err: err}
+	}
+}
+
+// toggleChannel starts listening on the channel the input names or
+// describes, or stops listening when it names one already listened on
+func (m *NotificationsModel) toggleChannel(text string) tea.Cmd {
+	listener := m.listener
+	if slices.Contains(m.listening, text) {
+		return func() tea.Msg {
+			return channelListenedMsg{channel: text, stopped: true, err: listener.StopListening(text)}
+		}
+	}
+
+	channel, description := text, ""
+	if strings.ContainsAny(text, " \t") && !slices.Contains(m.known, text) {
+		// A description rather than a name: pick the closest channel
+		matches := llm.MatchChannels(text, append(append([]string{}, m.known...), m.listening...))
+		if len(matches) == 0 {
+			m.status = fmt.Sprintf("✗ No known channel matches %q", text)
+			return nil
+		}
+		channel, description = matches[0], text
+		if slices.Contains(m.listening, channel) {
+			m.status = fmt.Sprintf("Already listening on %s", channel)
+			return nil
+		}
+	}
+
+	m.status = "Listening on " + channel + "..."
+	return func() tea.Msg {
+		return channelListenedMsg{channel: channel, description: description, err: listener.ListenTo(channel)}
+	}
+}
+
+// close stops the listener
+func (m *NotificationsModel) close() {
+	select {
+	case <-m.done:
+		return
+	default:
+		close(m.done)
+	}
+	if m.listener != nil {
+		m.listener.Close()
+	}
+}
+
+// Update handles messages for the notification viewer
+func (m *NotificationsModel) Update(msg tea.Msg) (*NotificationsModel, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		m.height = msg.Height
+		return m, nil
+
+	case notificationMsg:
+		m.log = append(m.log, postgres.Notification(msg))
+		if len(m.log) > notificationLogLimit {
+			m.log = m.log[len(m.log)-notificationLogLimit:]
+		}
+		if m.scroll > 0 {
+			// Keep the entries being read in place
+			m.scroll = min(m.scroll+1, len(m.log)-1)
+		}
+		return m, m.waitForNotification()
+
+	case listenerEventMsg:
+		m.state = msg.State
+		m.stateErr = msg.Err
+		return m, m.waitForEvent()
+
+	case listenerClosedMsg:
+		return m, nil
+
+	case channelsDiscoveredMsg:
+		if msg.err != nil {
+			m.status = "✗ Could not look for channels: " + msg.err.Error()
+			return m, nil
+		}
+		m.known = msg.channels
+		return m, nil
+
+	case channelListenedMsg:
+		switch {
+		case msg.err != nil:
+			m.status = fmt.Sprintf("✗ %s: %v", msg.channel, msg.err)
+		case msg.stopped:
+			m.status = "Stopped listening on " + msg.channel
+		case msg.description != "":
+			m.status = fmt.Sprintf("✓ Listening on %s (matched %q)", msg.channel, msg.description)
+		default:
+			m.status = "✓ Listening on " + msg.channel
+		}
+		if msg.err == nil {
+			m.listening = m.listener.Channels()
+			m.input.SetValue("")
+		}
+		return m, nil
+
+	case tea.KeyMsg:
+		switch msg.Type {
+		case tea.KeyCtrlC, tea.KeyEscape:
+			m.close()
+			return m, func() tea.Msg { return goToMenuMsg{} }
+		case tea.KeyEnter:
+			text := strings.TrimSpace(m.input.Value())
+			if text == "" || m.listener == nil {
+				return m, nil
+			}
+			return m, m.toggleChannel(text)
+		case tea.KeyUp:
+			m.scroll = min(m.scroll+1, max(0, len(m.log)-1))
+			return m, nil
+		case tea.KeyDown:
+			m.scroll = max(0, m.scroll-1)
+			return m, nil
+		case tea.KeyPgUp:
+			m.scroll = min(m.scroll+m.logHeight(), max(0, len(m.log)-1))
+			return m, nil
+		case tea.KeyPgDown:
+			m.scroll = max(0, m.scroll-m.logHeight())
+			return m, nil
+		case tea.KeyCtrlL:
+			m.log = nil
+			m.scroll = 0
+			return m, nil
+		}
+	}
+
+	var cmd tea.Cmd
+	m.input, cmd = m.input.Update(msg)
+	return m, cmd
+}
+
+// logHeight is the number of log lines that fit on the screen
+func (m *NotificationsModel) logHeight() int {
+	return max(3, m.height-18)
+}
+
+// View renders the notification viewer
+func (m *NotificationsModel) View() string {
+	if m.width == 0 || m.height == 0 {
+		return ""
+	}
+
+	header := RenderHeader("Notifications")
+
+	labelStyle := lipgloss.NewStyle().Foreground(ColorGray)
+	valueStyle := lipgloss.NewStyle().Foreground(ColorOrange).Bold(true)
+	hintStyle := lipgloss.NewStyle().Foreground(ColorGray).Italic(true)
+
+	var state string
+	switch m.state {
+	case postgres.ConnConnected:
+		state = lipgloss.NewStyle().Foreground(ColorGreen).Render("● connected")
+	case postgres.ConnConnecting:
+		state = lipgloss.NewStyle().Foreground(ColorOrange).Render("● connecting")
+	default:
+		state = lipgloss.NewStyle().Foreground(ColorRed).Render("● " + m.state.String())
+		if m.stateErr != nil {
+			state += hintStyle.Render(" (" + truncate(m.stateErr.Error(), 60) + ")")
+		}
+	}
+
+	serviceName := ""
+	if m.service != nil {
+		serviceName = m.service.Name
+	}
+	listening := hintStyle.Render("nothing yet")
+	if len(m.listening) > 0 {
+		listening = valueStyle.Render(strings.Join(m.listening, ", "))
+	}
+	known := hintStyle.Render("none found in functions")
+	if len(m.known) > 0 {
+		known = truncate(strings.Join(m.known, ", "), m.width-30)
+	}
+
+	lines := []string{
+		labelStyle.Render("Service:    ") + valueStyle.Render(serviceName) + "  " + state,
+		labelStyle.Render("Listening:  ") + listening,
+		labelStyle.Render("Notified:   ") + known,
+		"",
+		labelStyle.Render("Channel:    ") + lipgloss.NewStyle().
+			Border(lipgloss.NormalBorder(), false, false, true, false).
+			BorderForeground(ColorOrange).
+			Render(m.input.View()),
+	}
+	if m.status != "" {
+		style := lipgloss.NewStyle().Foreground(ColorGreen)
+		if strings.HasPrefix(m.status, "✗") {
+			style = ErrorStyle
+		}
+		lines = append(lines, style.Render(m.status))
+	}
+	lines = append(lines, "", m.renderLog())
+
+	helpText := "enter: listen (again to stop) • ↑/↓ pgup/pgdn: scroll • ctrl+l: clear • esc: back to menu"
+	footer := RenderHelpFooter(helpText, m.width)
+
+	return LayoutWithHeaderFooter(header, lipgloss.JoinVertical(lipgloss.Left, lines...), footer, m.width, m.height)
+}
+
+// renderLog renders the newest notifications that fit, oldest first
+func (m *NotificationsModel) renderLog() string {
+	timeStyle := lipgloss.NewStyle().Foreground(ColorGray)
+	channelStyle := lipgloss.NewStyle().Foreground(ColorCyan).Bold(true)
+	pidStyle := lipgloss.NewStyle().Foreground(ColorDarkGray)
+	hintStyle := lipgloss.NewStyle().Foreground(ColorGray).Italic(true)
+
+	height := m.logHeight()
+	width := m.width - 8
+
+	var lines []string
+	if len(m.log) == 0 {
+		lines = append(lines, hintStyle.Render("Waiting for notifications..."))
+	}
+	end := len(m.log) - m.scroll
+	for _, n := range m.log[max(0, end-height):end] {
+		payload := strings.Join(strings.Fields(n.Payload), " ")
+		if payload == "" {
+			payload = hintStyle.Render("(no payload)")
+		}
+		prefix := fmt.Sprintf("%s  %s  %s  ",
+			timeStyle.Render(n.Received.Format("15:04:05")),
+			channelStyle.Render(n.Channel),
+			pidStyle.Render(fmt.Sprintf("pid %d", n.PID)))
+		room := width - lipgloss.Width(prefix)
+		if room > 3 && lipgloss.Width(payload) > room {
+			payload = truncate(payload, room)
+		}
+		lines = append(lines, prefix+payload)
+	}
+
+	title := fmt.Sprintf("%d notifications", len(m.log))
+	if m.scroll > 0 {
+		title += fmt.Sprintf(" • %d newer below", m.scroll)
+	}
+	return lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(ColorDarkGray).
+		Width(width).
+		Height(height).
+		Padding(0, 1).
+		Render(lipgloss.JoinVertical(lipgloss.Left, append([]string{hintStyle.Render(title)}, lines...)...))
+}
@@ -0,0 +1,422 @@
+package tui
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/mattn/go-runewidth"
+)
+
+// maxPagerColWidth caps the width of a column in the pager
+const maxPagerColWidth = 40
+
+// pagerPrompt is a search pattern or row number being typed in the pager
+type pagerPrompt struct {
+	kind rune // '/' search forward, '?' search backward, ':' jump to row
+	text string
+}
+
+// columnStats summarises the loaded values of one column
+type columnStats struct {
+	values   int // Non-NULL values
+	nulls    int
+	distinct int
+	numeric  bool // Every value parses as a number
+	min, max string
+	sum      float64
+}
+
+// PagerModel shows every loaded row of a result full screen, like less:
+// rows scroll by line and page, / and ? search the cells, : jumps to a row
+// and the footer summarises the current column
+type PagerModel struct {
+	width   int
+	height  int
+	results *QueryResults
+
+	row       int // Current row
+	col       int // Current column
+	top       int // First visible row
+	colOffset int // First visible column
+
+	prompt   *pagerPrompt
+	search   string // Last pattern searched for, lower-cased
+	backward bool   // Last search went backward
+	status   string
+
+	stats     columnStats
+	statsCol  int // Column and row count stats were computed for
+	statsRows int
+}
+
+// NewPagerModel creates a pager over the rows of results
+func NewPagerModel(results *QueryResults, width, height int) *PagerModel {
+	return &PagerModel{width: width, height: height, results: results, statsCol: -1}
+}
+
+// prompting reports whether a search or row number is being typed, so
+// keys like q go to the prompt rather than closing the pager
+func (m *PagerModel) prompting() bool {
+	return m.prompt != nil
+}
+
+// atEnd reports whether the last loaded row is current, so the parent
+// can fetch more
+func (m *PagerModel) atEnd() bool {
+	return m.row >= len(m.results.Rows)-1
+}
+
+// visibleRows is the number of rows that fit between the header and footer
+func (m *PagerModel) visibleRows() int {
+	return max(m.height-5, 1)
+}
+
+// Update handles moving, searching and jumping; closing is left to the parent
+func (m *PagerModel) Update(msg tea.Msg) (*PagerModel, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		m.height = msg.Height
+		m.scrollIntoView()
+
+	case tea.KeyMsg:
+		if m.prompt != nil {
+			m.handlePromptKey(msg)
+			return m, nil
+		}
+		m.status = ""
+		page := m.visibleRows()
+		switch msg.String() {
+		case "down", "j", "enter":
+			m.moveRow(1)
+		case "up", "k":
+			m.moveRow(-1)
+		case "pgdown", " ", "f", "ctrl+f":
+			m.moveRow(page)
+		case "pgup", "b", "ctrl+b":
+			m.moveRow(-page)
+		case "d", "ctrl+d":
+			m.moveRow(page / 2)
+		case "u", "ctrl+u":
+			m.moveRow(-page / 2)
+		case "home", "g":
+			m.moveRow(-len(m.results.Rows))
+		case "end", "G":
+			m.moveRow(len(m.results.Rows))
+		case "right", "l":
+			m.col = min(m.col+1, len(m.results.Columns)-1)
+		case "left", "h":
+			m.col = max(m.col-1, 0)
+		case "0", "^":
+			m.col = 0
+		case "$":
+			m.col = len(m.results.Columns) - 1
+		case "/", "?", ":":
+			m.prompt = &pagerPrompt{kind: []rune(msg.String())[0]}
+		case "n":
+			m.find(m.backward)
+		case "N":
+			m.find(!m.backward)
+		}
+		m.scrollIntoView()
+	}
+	return m, nil
+}
+
+// handlePromptKey edits the prompt, running the search or jump on enter
+func (m *PagerModel) handlePromptKey(msg tea.KeyMsg) {
+	p := m.prompt
+	switch msg.String() {
+	case "esc":
+		m.prompt = nil
+	case "enter":
+		m.prompt = nil
+		switch p.kind {
+		case ':':
+			n, err := strconv.Atoi(strings.TrimSpace(p.text))
+			if err != nil || n < 1 || n > len(m.results.Rows) {
+				m.status = fmt.Sprintf("No row %q; rows 1-%d are loaded", p.text, len(m.results.Rows))
+				return
+			}
+			m.row = n - 1
+		default:
+			if p.text != "" {
+				m.search = strings.ToLower(p.text)
+			}
+			m.backward = p.kind == '?'
+			m.find(m.backward)
+		}
+		m.scrollIntoView()
+	case "backspace":
+		if r := []rune(p.text); len(r) > 0 {
+			p.text = string(r[:len(r)-1])
+		} else {
+			m.prompt = nil
+		}
+	default:
+		if msg.Type == tea.KeyRunes || msg.Type == tea.KeySpace {
+			p.text += string(msg.Runes)
+		}
+	}
+}
+
+// moveRow moves the current row by delta, within the loaded rows
+func (m *PagerModel) moveRow(delta int) {
+	m.row = max(min(m.row+delta, len(m.results.Rows)-1), 0)
+}
+
+// find moves to the next cell containing the search pattern after the
+// current one, in reading order, wrapping around at the end
+func (m *PagerModel) find(backward bool) {
+	if m.search == "" {
+		m.status = "No previous search"
+		return
+	}
+	cols := len(m.results.Columns)
+	cells := len(m.results.Rows) * cols
+	if cells == 0 {
+		return
+	}
+	step := 1
+	if backward {
+		step = -1
+	}
+	start := m.row*cols + m.col
+	for i := 1; i <= cells; i++ {
+		pos := ((start+step*i)%cells + cells) % cells
+		row, col := pos/cols, pos%cols
+		if col < len(m.results.Rows[row]) && strings.Contains(strings.ToLower(m.results.Rows[row][col]), m.search) {
+			if pos <= start && !backward || pos >= start && backward {
+				m.status = "Search wrapped around"
+			}
+			m.row, m.col = row, col
+			return
+		}
+	}
+	m.status = fmt.Sprintf("Pattern not found: %s", m.search)
+}
+
+// columnWidths returns the display width of every column over the loaded rows
+func (m *PagerModel) columnWidths() []int {
+	widths := make([]int, len(m.results.Columns))
+	for i, col := range m.results.Columns {
+		widths[i] = runewidth.StringWidth(col)
+	}
+	for r, row := range m.results.Rows {
+		for i := range row {
+			if i < len(widths) {
+				widths[i] = max(widths[i], runewidth.StringWidth(m.results.displayValue(r, i)))
+			}
+		}
+	}
+	for i := range widths {
+		widths[i] = min(max(widths[i], 3), maxPagerColWidth)
+	}
+	return widths
+}
+
+// gutterWidth is the width of the row number column
+func (m *PagerModel) gutterWidth() int {
+	return len(strconv.Itoa(max(len(m.results.Rows), 1)))
+}
+
+// shownColumns returns the columns that fit the width from the column offset
+func (m *PagerModel) shownColumns(widths []int) []int {
+	var cols []int
+	used := m.gutterWidth() + 2
+	for i := m.colOffset; i < len(widths); i++ {
+		if len(cols) > 0 && used+widths[i]+2 > m.width {
+			break
+		}
+		cols = append(cols, i)
+		used += widths[i] + 2
+	}
+	return cols
+}
+
+// scrollIntoView scrolls so the current row and column are visible
+func (m *PagerModel) scrollIntoView() {
+	if m.row < m.top {
+		m.top = m.row
+	} else if m.row >= m.top+m.visibleRows() {
+		m.top = m.row - m.visibleRows() + 1
+	}
+	m.top = max(min(m.top, len(m.results.Rows)-m.visibleRows()), 0)
+
+	if m.col < m.colOffset {
+		m.colOffset = m.col
+	}
+	widths := m.columnWidths()
+	for m.colOffset < m.col {
+		cols := m.shownColumns(widths)
+		if len(cols) > 0 && cols[len(cols)-1] >= m.col {
+			break
+		}
+		m.colOffset++
+	}
+}
+
+// columnStats summarises the current column, recomputed as rows are loaded
+func (m *PagerModel) columnStats() columnStats {
+	if m.statsCol == m.col && m.statsRows == len(m.results.Rows) {
+		return m.stats
+	}
+	s := columnStats{numeric: true}
+	seen := make(map[string]bool)
+	var minNum, maxNum float64
+	for r, row := range m.results.Rows {
+		if m.col >= len(row) || m.results.isNull(r, m.col) {
+			s.nulls++
+			continue
+		}
+		v := row[m.col]
+		s.values++
+		seen[v] = true
+		if s.values == 1 || v < s.min {
+			s.min = v
+		}
+		if s.values == 1 || v > s.max {
+			s.max = v
+		}
+		if !s.numeric {
+			continue
+		}
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			s.numeric = false
+			continue
+		}
+		if s.values == 1 || f < minNum {
+			minNum = f
+		}
+		if s.values == 1 || f > maxNum {
+			maxNum = f
+		}
+		s.sum += f
+	}
+	s.distinct = len(seen)
+	s.numeric = s.numeric && s.values > 0
+	if s.numeric {
+		s.min = strconv.FormatFloat(minNum, 'g', -1, 64)
+		s.max = strconv.FormatFloat(maxNum, 'g', -1, 64)
+	}
+	m.stats, m.statsCol, m.statsRows = s, m.col, len(m.results.Rows)
+	return s
+}
+
+// renderStats renders the summary of the current column
+func (m *PagerModel) renderStats() string {
+	if len(m.results.Columns) == 0 {
+		return ""
+	}
+	s := m.columnStats()
+	parts := []string{
+		fmt.Sprintf("%d values", s.values),
+		fmt.Sprintf("%d NULL", s.nulls),
+		fmt.Sprintf("%d distinct", s.distinct),
+	}
+	if s.values > 0 {
+		parts = append(parts, "min "+truncateStr(s.min, 24), "max "+truncateStr(s.max, 24))
+	}
+	if s.numeric {
+		parts = append(parts,
+			"sum "+strconv.FormatFloat(s.sum, 'g', 10, 64),
+			"avg "+strconv.FormatFloat(s.sum/float64(s.values), 'g', 6, 64))
+	}
+	name := lipgloss.NewStyle().Foreground(ColorOrange).Bold(true).Render(m.results.Columns[m.col])
+	return name + lipgloss.NewStyle().Foreground(ColorGray).Render(": "+strings.Join(parts, " • "))
+}
+
+// View renders the pager
+func (m *PagerModel) View() string {
+	titleStyle := lipgloss.NewStyle().Foreground(ColorOrange).Bold(true)
+	headStyle := lipgloss.NewStyle().Foreground(ColorCyan).Bold(true)
+	gutterStyle := lipgloss.NewStyle().Foreground(ColorGray)
+	cellStyle := lipgloss.NewStyle().Foreground(ColorWhite)
+	nullStyle := lipgloss.NewStyle().Foreground(ColorGray).Italic(true)
+	matchStyle := lipgloss.NewStyle().Foreground(ColorOrange).Underline(true)
+	currentStyle := lipgloss.NewStyle().Foreground(ColorOnAccent).Background(ColorOrange).Bold(true)
+	helpStyle := lipgloss.NewStyle().Foreground(ColorGray).Italic(true)
+
+	rows := m.results.Rows
+	end := min(m.top+m.visibleRows(), len(rows))
+	position := fmt.Sprintf("rows %d-%d of %d", min(m.top+1, end), end, len(rows))
+	if m.results.HasMoreRows() {
+		position += "+"
+	}
+	if len(m.results.Columns) > 0 {
+		position += fmt.Sprintf(" • column %d of %d", m.col+1, len(m.results.Columns))
+	}
+	about := m.results.NaturalQuery
+	if about == "" {
+		about = strings.Join(strings.Fields(m.results.ExecutedSQL()), " ")
+	}
+	title := titleStyle.Render(truncateStr(about, max(m.width-lipgloss.Width(position)-3, 10)))
+	lines := []string{title + strings.Repeat(" ", max(m.width-lipgloss.Width(title)-lipgloss.Width(position), 1)) + gutterStyle.Render(position)}
+
+	widths := m.columnWidths()
+	cols := m.shownColumns(widths)
+	gutter := m.gutterWidth()
+
+	head := strings.Repeat(" ", gutter+2)
+	for _, i := range cols {
+		head += fitCell(m.results.Columns[i], widths[i]) + "  "
+	}
+	lines = append(lines, headStyle.Render(head))
+
+	for r := m.top; r < end; r++ {
+		line := gutterStyle.Render(fmt.Sprintf("%*d  ", gutter, r+1))
+		if r == m.row && plainOutput {
+			// The current row and cell are otherwise shown only by colour
+			line = fmt.Sprintf("%*d> ", gutter, r+1)
+		}
+		for _, i := range cols {
+			value := ""
+			if i < len(rows[r]) {
+				value = rows[r][i]
+			}
+			shown := m.results.displayValue(r, i)
+			cell := alignCell(shown, widths[i], m.results.rightAligned(i))
+			if r == m.row && i == m.col && plainOutput {
+				cell = alignCell("["+shown+"]", widths[i], m.results.rightAligned(i))
+			}
+			style := cellStyle
+			switch {
+			case r == m.row && i == m.col:
+				style = currentStyle
+			case m.search != "" && strings.Contains(strings.ToLower(value), m.search):
+				style = matchStyle
+			case m.results.isNull(r, i):
+				style = nullStyle
+			}
+			if r == m.row && style.GetBackground() == (lipgloss.NoColor{}) {
+				style = style.Background(ColorDarkGray)
+			}
+			line += style.Render(cell) + cellStyle.Render("  ")
+		}
+		lines = append(lines, line)
+	}
+	if len(rows) == 0 {
+		lines = append(lines, nullStyle.Render("(no rows)"))
+	}
+	for len(lines) < m.visibleRows()+2 {
+		lines = append(lines, "")
+	}
+
+	lines = append(lines, m.renderStats())
+	switch {
+	case m.prompt != nil:
+		label := map[rune]string{'/': "/", '?': "?", ':': "Row: "}[m.prompt.kind]
+		lines = append(lines, PromptStyle.Render(label)+m.prompt.text+"█")
+	case m.status != "":
+		lines = append(lines, lipgloss.NewStyle().Foreground(ColorOrange).Render(m.status))
+	default:
+		lines = append(lines, helpStyle.Render(truncateStr(
+			"j/k/space/b: scroll • g/G: top/end • h/l/0/$: column • / ?: search • n/N: match • :: go to row • q: close",
+			max(m.width, 10))))
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,105 @@
+package tui
+
+import (
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// templateParamsMsg asks for the values of a query's {{name}} placeholders
+// before it runs
+type templateParamsMsg struct {
+	query        string
+	generatedSQL string
+	sql          string
+	names        []string // Placeholder names in $n order
+}
+
+// paramPromptState tracks the prompt for template parameter values
+type paramPromptState struct {
+	templateParamsMsg
+	values map[string]string
+	pos    int    // Index into names of the parameter being entered
+	input  string // Value being typed, prefilled with the last one used
+}
+
+// hasParamValues reports whether params holds a value for every name
+func hasParamValues(params map[string]string, names []string) bool {
+	for _, name := range names {
+		if _, ok := params[name]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// lastParamValue returns the value last entered for a parameter
+func (m *QueryModel) lastParamValue(name string) string {
+	if m.cfg == nil {
+		return ""
+	}
+	return m.cfg.TemplateParams[name]
+}
+
+// openParamPrompt starts asking for the parameters of a template query
+func (m *QueryModel) openParamPrompt(msg templateParamsMsg) {
+	m.paramPrompt = &paramPromptState{
+		templateParamsMsg: msg,
+		values:            make(map[string]string),
+		input:             m.lastParamValue(msg.names[0]),
+	}
+}
+
+// handleParamPromptKey edits the current parameter value; Enter moves to the
+// next parameter and runs the query after the last one
+func (m *QueryModel) handleParamPromptKey(msg tea.KeyMsg) (*QueryModel, tea.Cmd) {
+	p := m.paramPrompt
+	switch msg.Type {
+	case tea.KeyEsc:
+		m.paramPrompt = nil
+		m.background = nil
+		m.statusMsg = "Query cancelled"
+	case tea.KeyCtrlU:
+		p.input = ""
+	case tea.KeyBackspace:
+		if r := []rune(p.input); len(r) > 0 {
+			p.input = string(r[:len(r)-1])
+		}
+	case tea.KeyRunes, tea.KeySpace:
+		p.input += string(msg.Runes)
+	case tea.KeyEnter:
+		p.values[p.names[p.pos]] = p.input
+		p.pos++
+		if p.pos < len(p.names) {
+			p.input = m.lastParamValue(p.names[p.pos])
+			return m, nil
+		}
+		m.paramPrompt = nil
+		return m, m.runWithParams(p)
+	}
+	return m, nil
+}
+
+// runWithParams remembers the entered values as defaults and runs the query
+// with them bound as parameters
+func (m *QueryModel) runWithParams(p *paramPromptState) tea.Cmd {
+	if m.cfg != nil {
+		m.cfg.SetTemplateParams(p.values)
+		m.cfg.Save()
+	}
+
+	m.loading = true
+	ctx := m.newQueryContext()
+	return tea.Batch(m.spinner.Tick, cancellable(ctx, p.query, func() tea.Msg {
+		return m.prepareSQL(ctx, p.query, p.generatedSQL, p.sql, p.values)
+	}))
+}
+
+// renderParamPrompt renders the prompt line for the current parameter
+func (m *QueryModel) renderParamPrompt() string {
+	p := m.paramPrompt
+	highlight := lipgloss.NewStyle().Foreground(ColorOrange).Bold(true)
+	label := fmt.Sprintf("🧩 Value for {{%s}} (%d/%d): ", p.names[p.pos], p.pos+1, len(p.names))
+	return PromptStyle.Render(label) + highlight.Render(p.input+"█")
+}
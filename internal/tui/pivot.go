@@ -0,0 +1,195 @@
+package tui
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// PivotAggregate is an aggregate function offered by the pivot view
+type PivotAggregate string
+
+const (
+	PivotCount PivotAggregate = "count"
+	PivotSum   PivotAggregate = "sum"
+	PivotAvg   PivotAggregate = "avg"
+)
+
+// Pivot picker steps
+const (
+	pivotPickGroup = iota
+	pivotPickAggregate
+	pivotPickValue
+)
+
+// pivotState tracks the pivot picker while the user chooses a group-by
+// column, an aggregate and (for sum/avg) the column to aggregate
+type pivotState struct {
+	results   *QueryResults
+	step      int
+	groupCol  int
+	aggregate PivotAggregate
+	valueCols []int // Numeric columns that can be summed or averaged
+	valuePos  int   // Position in valueCols
+}
+
+// newPivotState starts a pivot of results, or returns nil if they cannot be pivoted
+func newPivotState(results *QueryResults) *pivotState {
+	if results == nil || results.Mutating || results.ExecutedSQL() == "" || len(results.Columns) == 0 {
+		return nil
+	}
+	return &pivotState{results: results, valueCols: numericColumns(results)}
+}
+
+// numericColumns returns the columns whose fetched non-NULL values all parse as numbers
+func numericColumns(results *QueryResults) []int {
+	var cols []int
+	for i := range results.Columns {
+		seen := false
+		numeric := true
+		for _, row := range results.Rows {
+			if i >= len(row) || row[i] == "NULL" || row[i] == "" {
+				continue
+			}
+			seen = true
+			if _, err := strconv.ParseFloat(row[i], 64); err != nil {
+				numeric = false
+				break
+			}
+		}
+		if seen && numeric {
+			cols = append(cols, i)
+		}
+	}
+	return cols
+}
+
+// pivotSQL wraps source in a GROUP BY query aggregating valueCol (ignored for count)
+func pivotSQL(source, groupCol string, aggregate PivotAggregate, valueCol string) string {
+	source = strings.TrimRight(strings.TrimSpace(source), "; \n\t")
+
+	aggExpr := "COUNT(*)"
+	alias := "count"
+	if aggregate != PivotCount {
+		aggExpr = fmt.Sprintf("%s(pivot_source.%s)", strings.ToUpper(string(aggregate)), quoteIdentifier(valueCol))
+		alias = fmt.Sprintf("%s_%s", aggregate, valueCol)
+	}
+
+	return fmt.Sprintf("SELECT pivot_source.%s, %s AS %s FROM (%s) AS pivot_source GROUP BY 1 ORDER BY 2 DESC",
+		quoteIdentifier(groupCol), aggExpr, quoteIdentifier(alias), source)
+}
+
+// quoteIdentifier double-quotes a SQL identifier
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// description returns a short label for the pivot, used as the conversation query
+func (p *pivotState) description() string {
+	group := p.results.Columns[p.groupCol]
+	if p.aggregate == PivotCount {
+		return fmt.Sprintf("Pivot: count of rows by %s", group)
+	}
+	value := p.results.Columns[p.valueCols[p.valuePos]]
+	return fmt.Sprintf("Pivot: %s of %s by %s", p.aggregate, value, group)
+}
+
+// sql returns the derived SQL for the chosen pivot
+func (p *pivotState) sql() string {
+	valueCol := ""
+	if p.aggregate != PivotCount {
+		valueCol = p.results.Columns[p.valueCols[p.valuePos]]
+	}
+	return pivotSQL(p.results.ExecutedSQL(), p.results.Columns[p.groupCol], p.aggregate, valueCol)
+}
+
+// handlePivotKey drives the pivot picker; esc cancels at any step
+func (m *QueryModel) handlePivotKey(msg tea.KeyMsg) (*QueryModel, tea.Cmd) {
+	p := m.pivot
+	if msg.Type == tea.KeyEsc {
+		m.pivot = nil
+		return m, nil
+	}
+
+	switch p.step {
+	case pivotPickGroup:
+		switch msg.String() {
+		case "left", "h":
+			p.groupCol = (p.groupCol + len(p.results.Columns) - 1) % len(p.results.Columns)
+		case "right", "l", "tab":
+			p.groupCol = (p.groupCol + 1) % len(p.results.Columns)
+		case "enter":
+			p.step = pivotPickAggregate
+		}
+
+	case pivotPickAggregate:
+		switch msg.String() {
+		case "c":
+			p.aggregate = PivotCount
+			return m.runPivot()
+		case "s", "a":
+			if len(p.valueCols) == 0 {
+				return m, nil
+			}
+			p.aggregate = PivotSum
+			if msg.String() == "a" {
+				p.aggregate = PivotAvg
+			}
+			p.step = pivotPickValue
+		}
+
+	case pivotPickValue:
+		switch msg.String() {
+		case "left", "h":
+			p.valuePos = (p.valuePos + len(p.valueCols) - 1) % len(p.valueCols)
+		case "right", "l", "tab":
+			p.valuePos = (p.valuePos + 1) % len(p.valueCols)
+		case "enter":
+			return m.runPivot()
+		}
+	}
+	return m, nil
+}
+
+// runPivot closes the picker and executes the derived SQL as a new conversation entry
+func (m *QueryModel) runPivot() (*QueryModel, tea.Cmd) {
+	p := m.pivot
+	m.pivot = nil
+	if m.loading {
+		return m, nil
+	}
+
+	query := p.description()
+	sqlQuery := p.sql()
+	m.loading = true
+	ctx := m.newQueryContext()
+	return m, tea.Batch(m.spinner.Tick, cancellable(ctx, query, func() tea.Msg {
+		if m.database() == nil {
+			return queryExecutedMsg{err: fmt.Errorf("no database connection")}
+		}
+		return m.prepareSQL(ctx, query, sqlQuery, sqlQuery, p.results.Params)
+	}))
+}
+
+// renderPivotPicker renders the prompt line for the current pivot step
+func (m *QueryModel) renderPivotPicker() string {
+	p := m.pivot
+	highlight := lipgloss.NewStyle().Foreground(ColorOrange).Bold(true)
+
+	switch p.step {
+	case pivotPickGroup:
+		return PromptStyle.Render("📊 Pivot - group by: ") + highlight.Render("◀ "+p.results.Columns[p.groupCol]+" ▶")
+	case pivotPickAggregate:
+		options := "[c]ount"
+		if len(p.valueCols) > 0 {
+			options += "  [s]um  [a]vg"
+		}
+		return PromptStyle.Render(fmt.Sprintf("📊 Pivot by %s - aggregate: %s", p.results.Columns[p.groupCol], options))
+	default:
+		return PromptStyle.Render(fmt.Sprintf("📊 Pivot by %s - %s of: ", p.results.Columns[p.groupCol], p.aggregate)) +
+			highlight.Render("◀ "+p.results.Columns[p.valueCols[p.valuePos]]+" ▶")
+	}
+}
@@ -0,0 +1,99 @@
+package tui
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/charmbracelet/x/ansi"
+)
+
+// plainForced turns plain output on for the whole run, whatever the
+// setting says (the --plain flag)
+var plainForced bool
+
+// plainOutput is set while the interface is drawn as plain ASCII text
+// for screen readers and logs (see ApplyTheme)
+var plainOutput bool
+
+// SetPlainOutput turns plain output on for this run regardless of the
+// Plain Output setting
+func SetPlainOutput(on bool) {
+	plainForced = on
+	plainOutput = on
+}
+
+// PlainOutput reports whether the interface is drawn as plain text
+func PlainOutput() bool {
+	return plainOutput
+}
+
+// plainSymbols are the symbols given words or ASCII in plain output; other
+// symbols and emoji are dropped
+var plainSymbols = map[rune]string{
+	'✓': "OK:", '✔': "OK:", '✅': "OK:",
+	'✗': "Error:", '✘': "Error:", '❌': "Error:",
+	'⚠': "Warning:",
+	'▶': ">", '▸': ">", '▾': "-", '►': ">", '◀': "<", '◂': "<",
+	'→': "->", '←': "<-", '↑': "^", '↓': "v", '↻': "*",
+	'•': "-", '·': "-", '…': "...", '█': "_",
+	'“': "\"", '”': "\"", '‘': "'", '’': "'",
+}
+
+// plainText turns a rendered screen into plain text: colours and other
+// escape sequences are removed, box drawing becomes ASCII, marker symbols
+// become words or ASCII and emoji are dropped. Letters of every script
+// are kept.
+func plainText(view string) string {
+	view = ansi.Strip(view)
+	var b strings.Builder
+	b.Grow(len(view))
+	runes := []rune(view)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		if r < 0x80 {
+			b.WriteRune(r)
+			continue
+		}
+		if s, ok := plainSymbols[r]; ok {
+			b.WriteString(s)
+			continue
+		}
+		switch {
+		case r >= 0x2500 && r <= 0x257F: // Box drawing
+			b.WriteByte(boxDrawingASCII(r))
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsPunct(r) || unicode.IsSpace(r) ||
+			unicode.Is(unicode.Sc, r) || unicode.Is(unicode.Mn, r):
+			b.WriteRune(r)
+		default:
+			// An emoji or decoration: dropped with the selectors and joined
+			// emoji after it, and one space when it starts a word
+			start := i
+			for i+1 < len(runes) && isEmojiPart(runes[i+1]) {
+				i++
+			}
+			if i+1 < len(runes) && runes[i+1] == ' ' && (start == 0 || runes[start-1] == ' ' || runes[start-1] == '\n') {
+				i++
+			}
+		}
+	}
+	return b.String()
+}
+
+// isEmojiPart reports whether r continues the emoji before it: a
+// variation selector, zero width joiner, skin tone or joined emoji
+func isEmojiPart(r rune) bool {
+	return (r >= 0xFE00 && r <= 0xFE0F) || r == 0x200D || r >= 0x1F000 ||
+		(r >= 0x2600 && r <= 0x27BF)
+}
+
+// boxDrawingASCII returns the ASCII character drawing the same line as a
+// box drawing character
+func boxDrawingASCII(r rune) byte {
+	switch r {
+	case '─', '━', '╌', '╍', '┄', '┅', '┈', '┉', '═', '╴', '╶', '╸', '╺':
+		return '-'
+	case '│', '┃', '╎', '╏', '┆', '┇', '┊', '┋', '║', '╵', '╷', '╹', '╻':
+		return '|'
+	}
+	return '+'
+}
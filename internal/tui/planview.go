@@ -0,0 +1,225 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/kartoza/kartoza-pg-ai/internal/postgres"
+)
+
+// planLoadedMsg carries the result of an EXPLAIN ANALYZE run
+type planLoadedMsg struct {
+	sql       string
+	result    *postgres.ExplainResult
+	err       error
+	cancelled bool // ctrl+c stopped the run
+}
+
+// planLine is a visible node of the plan tree with its depth
+type planLine struct {
+	node  *postgres.PlanNode
+	depth int
+}
+
+// PlanViewModel shows an EXPLAIN ANALYZE plan as a collapsible tree
+type PlanViewModel struct {
+	width     int
+	height    int
+	sql       string
+	result    *postgres.ExplainResult
+	collapsed map[*postgres.PlanNode]bool
+	lines     []planLine // Visible nodes, rebuilt when collapsing changes
+	selected  int
+	offset    int // First visible line
+}
+
+// NewPlanViewModel creates a plan viewer with every node expanded
+func NewPlanViewModel(sql string, result *postgres.ExplainResult, width, height int) *PlanViewModel {
+	m := &PlanViewModel{
+		width:     width,
+		height:    height,
+		sql:       sql,
+		result:    result,
+		collapsed: make(map[*postgres.PlanNode]bool),
+	}
+	m.rebuild()
+	return m
+}
+
+// explainAnalyze runs EXPLAIN ANALYZE for sql in the background, binding
+// params to its {{name}} placeholders. Since ANALYZE runs the query, it is
+// bound by the service's statement timeout and cancelled with ctx.
+func (m *QueryModel) explainAnalyze(ctx context.Context, sql string, params map[string]string) tea.Cmd {
+	db := m.database()
+	ctx = postgres.WithStatementTimeout(ctx, m.statementTimeout())
+	return func() tea.Msg {
+		if db == nil {
+			return planLoadedMsg{err: fmt.Errorf("no database connection")}
+		}
+		boundSQL, args, err := postgres.BindTemplate(sql, params)
+		if err != nil {
+			return planLoadedMsg{err: err}
+		}
+		result, err := postgres.ExplainAnalyze(ctx, db, boundSQL, args...)
+		return planLoadedMsg{sql: sql, result: result, err: err, cancelled: ctx.Err() != nil}
+	}
+}
+
+// rebuild flattens the expanded part of the tree into lines
+func (m *PlanViewModel) rebuild() {
+	m.lines = nil
+	var walk func(n *postgres.PlanNode, depth int)
+	walk = func(n *postgres.PlanNode, depth int) {
+		m.lines = append(m.lines, planLine{node: n, depth: depth})
+		if m.collapsed[n] {
+			return
+		}
+		for _, child := range n.Plans {
+			walk(child, depth+1)
+		}
+	}
+	walk(m.result.Plan, 0)
+	if m.selected >= len(m.lines) {
+		m.selected = len(m.lines) - 1
+	}
+}
+
+// Update handles navigation and collapsing; closing is left to the parent
+func (m *PlanViewModel) Update(msg tea.Msg) (*PlanViewModel, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		m.height = msg.Height
+
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "up", "k":
+			if m.selected > 0 {
+				m.selected--
+			}
+		case "down", "j":
+			if m.selected < len(m.lines)-1 {
+				m.selected++
+			}
+		case "enter", " ":
+			node := m.lines[m.selected].node
+			if len(node.Plans) > 0 {
+				m.collapsed[node] = !m.collapsed[node]
+				m.rebuild()
+			}
+		case "left", "h":
+			m.collapsed[m.lines[m.selected].node] = true
+			m.rebuild()
+		case "right", "l":
+			delete(m.collapsed, m.lines[m.selected].node)
+			m.rebuild()
+		}
+	}
+	return m, nil
+}
+
+// describeNode returns the one-line tree label for a node
+func describeNode(n *postgres.PlanNode) string {
+	label := n.NodeType
+	if n.JoinType != "" {
+		label = n.JoinType + " " + label
+	}
+	if n.RelationName != "" {
+		label += " on " + n.RelationName
+		if n.Alias != "" && n.Alias != n.RelationName {
+			label += " " + n.Alias
+		}
+	}
+	if n.IndexName != "" {
+		label += " using " + n.IndexName
+	}
+	return label + fmt.Sprintf("  (rows %.0f of est. %.0f, %.3f ms, loops %.0f)",
+		n.ActualRows, n.PlanRows, n.ActualTotalTime, n.ActualLoops)
+}
+
+// View renders the plan screen
+func (m *PlanViewModel) View() string {
+	header := RenderHeader("EXPLAIN ANALYZE")
+
+	seqStyle := lipgloss.NewStyle().Foreground(ColorOrange)
+	warnStyle := lipgloss.NewStyle().Foreground(ColorRed).Bold(true)
+	normalStyle := lipgloss.NewStyle().Foreground(ColorWhite)
+	selectedStyle := lipgloss.NewStyle().Background(ColorDarkGray)
+	dimStyle := lipgloss.NewStyle().Foreground(ColorGray)
+
+	var lines []string
+	lines = append(lines, SQLStyle.Render(truncate(strings.Join(strings.Fields(m.sql), " "), m.width-12)))
+	lines = append(lines, dimStyle.Render(fmt.Sprintf("Planning %.3f ms • Execution %.3f ms", m.result.PlanningTime, m.result.ExecutionTime)))
+	lines = append(lines, "")
+
+	// Keep the selected node inside the visible window
+	visible := m.height - 20
+	if visible < 5 {
+		visible = 5
+	}
+	if m.selected < m.offset {
+		m.offset = m.selected
+	} else if m.selected >= m.offset+visible {
+		m.offset = m.selected - visible + 1
+	}
+
+	for i := m.offset; i < len(m.lines) && i < m.offset+visible; i++ {
+		line := m.lines[i]
+		marker := "•"
+		if len(line.node.Plans) > 0 {
+			marker = "▾"
+			if m.collapsed[line.node] {
+				marker = "▸"
+			}
+		}
+
+		text := strings.Repeat("  ", line.depth) + marker + " " + describeNode(line.node)
+		style := normalStyle
+		switch {
+		case line.node.IsIndexCandidate():
+			text += "  ⚠ index candidate"
+			style = warnStyle
+		case line.node.IsSeqScan():
+			style = seqStyle
+		}
+		if i == m.selected {
+			style = style.Inherit(selectedStyle)
+			if plainOutput {
+				text = "> " + text
+			}
+		}
+		lines = append(lines, style.Render(truncate(text, m.width-12)))
+	}
+
+	// Details of the selected node
+	if m.selected >= 0 && m.selected < len(m.lines) {
+		n := m.lines[m.selected].node
+		lines = append(lines, "")
+		details := []string{fmt.Sprintf("Cost %.2f..%.2f", n.StartupCost, n.TotalCost)}
+		if n.Filter != "" {
+			details = append(details, fmt.Sprintf("Filter: %s (removed %.0f rows)", n.Filter, n.RowsRemovedByFilter))
+		}
+		if n.IndexCond != "" {
+			details = append(details, "Index Cond: "+n.IndexCond)
+		}
+		if n.HashCond != "" {
+			details = append(details, "Hash Cond: "+n.HashCond)
+		}
+		if n.IsIndexCandidate() {
+			details = append(details, warnStyle.Render(fmt.Sprintf("Sequential scan discards most rows of %s - consider an index on the filtered columns", n.RelationName)))
+		}
+		for _, d := range details {
+			lines = append(lines, dimStyle.Render("  "+d))
+		}
+	}
+
+	content := BoxStyle.Width(m.width - 6).Render(lipgloss.JoinVertical(lipgloss.Left, lines...))
+
+	helpText := "j/k: move • Enter/Space: expand/collapse • h/l: collapse/expand • esc/q: close"
+	footer := RenderHelpFooter(helpText, m.width)
+
+	return LayoutWithHeaderFooter(header, content, footer, m.width, m.height)
+}
@@ -1,8 +1,12 @@
 package tui
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
@@ -13,8 +17,13 @@ import (
 	"github.com/charmbracelet/lipgloss"
 	"github.com/kartoza/kartoza-pg-ai/internal/config"
 	"github.com/kartoza/kartoza-pg-ai/internal/llm"
+	"github.com/kartoza/kartoza-pg-ai/internal/logging"
 	"github.com/kartoza/kartoza-pg-ai/internal/postgres"
+	"github.com/kartoza/kartoza-pg-ai/internal/tracing"
 	"github.com/kujtimiihoxha/vimtea"
+	"github.com/mattn/go-runewidth"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // ConversationScrollState tracks scroll position in conversation view
@@ -37,54 +46,292 @@ type QueryModel struct {
 	service     *postgres.ServiceEntry
 	schema      *config.SchemaCache
 	queryEngine *llm.QueryEngine
+	embedder    llm.Embedder // Semantic schema search; nil when disabled
+	generator   string       // What answers the next question: llm.GeneratorAuto, rules, nn or a provider
+	askedWith   string       // Label of the generator that answered the latest question
 	error       string
 	history     []ConversationEntry
 	cfg         *config.Config
-	db          *sql.DB // Persistent database connection
+	conn        *postgres.ConnectionManager // Shared connection pool of the service
 	// Table endless scroll (within a single result)
 	scrollOffset   int // Current scroll position (top visible row in table)
 	visibleRows    int // Number of rows visible in results area
 	fetchBatchSize int // Number of rows to fetch per batch
 	totalFetched   int // Total rows fetched so far
 	hasMoreRows    bool // Whether there are more rows to fetch
-	currentSQL     string // Current SQL query for fetching more rows
+	fetchingMore   bool // Whether a batch fetch is in flight
+	cancelQuery    context.CancelFunc // Cancels the in-flight query (nil when idle)
+	querySpan      trace.Span         // Span of the in-flight question (nil when idle)
+	usage          *llm.UsageMeter    // LLM provider requests of the latest question
+	renderSpan     trace.Span         // Span of an answered question, ended once its result is drawn
 	// Conversation scroll (entire conversation area)
 	convScroll     ConversationScrollState
 	selectedEntry  int  // Currently selected conversation entry (for toggling SQL)
 	focusEditor    bool // Whether focus is on the editor (true) or conversation area (false)
+	// Export
+	exportPicker bool   // Whether the export format picker is showing
+	statusMsg    string // Transient status line (e.g. export result)
+	// Write mode confirmation
+	pendingWrite *confirmWriteMsg // Mutating statement awaiting confirmation
+	// SQL edit-before-execute
+	sqlEdit *sqlEditState // Non-nil while the editor holds SQL instead of a question
+	// Interactive map of a result's geometries
+	mapView *MapViewModel // Non-nil while the map view is open
+	// Pivot/aggregate of a result set
+	pivot *pivotState // Non-nil while the pivot picker is showing
+	// EXPLAIN ANALYZE plan of an entry
+	planView *PlanViewModel // Non-nil while the plan viewer is open
+	// Key/value inspection of a single result row
+	rowSelect *rowSelectState // Non-nil while choosing a result row to inspect
+	rowDetail *RowDetailModel // Non-nil while a row is being inspected
+	// Interactive sorting and hiding of result columns
+	colSelect *columnSelectState // Non-nil while choosing a result column
+	// Comparison of two entries' results
+	diffFrom *int           // Entry chosen first, while choosing the other (nil otherwise)
+	diffView *DiffViewModel // Non-nil while a diff is shown
+	pager    *PagerModel    // Non-nil while a result is paged full screen
+	// Table and column name completion in the editor
+	completion *completionState    // Non-nil while Tab cycles through names
+	search     *conversationSearch // Non-nil while the conversation is searched
+	// Example questions about this schema, for the welcome screen and type-ahead
+	suggestions []string
+	// Question in the editor as last saved for the service, and the count
+	// of its changes, so only the save scheduled last is done
+	draft    string
+	draftSeq int
+	edits    editHistory // Undo and redo of the textarea editor
+	// Name of a snapshot to save a result as or compare a fresh run with
+	snapshotPrompt *snapshotPromptState // Non-nil while the name is typed
+	// Named sessions, each with its own conversation
+	sessions      []*querySession // The active session's state lives in the fields above
+	activeSession int             // Index of the active session
+	sessionPrompt *string         // Name being typed for a new session (nil when closed)
+	// Values for {{name}} placeholders of a template query
+	paramPrompt *paramPromptState // Non-nil while prompting for parameter values
+	// Table name found in several schemas, waiting for one to be chosen
+	schemaPrompt *schemaPromptState
+	// Conversation entries as last rendered, reused while they are unchanged
+	convCache []*renderedEntry
+	// Question answered while the next is typed (nil when none)
+	background *backgroundQuery
+	// Services to ask a question on at once, for comparing environments
+	servicePicker   *servicePickerState // Non-nil while the services are chosen
+	compareServices []string            // Services chosen last time
 }
 
+// maxCountedRows is how many rows of a result are counted; the planner
+// estimates the count of larger ones
+const maxCountedRows = 100000
+
+// defaultVisibleRows is how many rows of the latest result are shown before loading more
+const defaultVisibleRows = 15
+
+// sqlPreviewLines is how many lines of SQL the edit preview shows
+const sqlPreviewLines = 8
+
 // QueryResults holds the results of a query
 type QueryResults struct {
 	Columns         []string
+	ColumnTypes     []string // Database type names, e.g. "JSONB" (nil when unknown)
 	Rows            [][]string
+	Nulls           [][]bool // Per row, which cells are NULL rather than the text "NULL" (nil when unknown)
 	RowCount        int
+	RowsEstimated   bool // RowCount is the planner's estimate, the rows being too many to count
 	ExecutionTime   float64
 	GeneratedSQL    string
+	EditedSQL       string // User-edited SQL that was run instead of GeneratedSQL
 	NaturalQuery    string
-	GeometryColIdx  int    // Index of geometry column (-1 if none)
-	GeometryImage   string // Rendered geometry as Kitty graphics escape sequence
-	GeometryPNGData string // Base64-encoded PNG data (for saving to history)
+	GeometryColIdx  int               // Index of geometry column (-1 if none)
+	GeometryColumns []int             // Indices of all detected geometry columns
+	GeometryOverlay bool              // Render all geometry columns overlaid instead of GeometryColIdx
+	GeometryImage   string            // Rendered geometry as a Kitty graphics or sixel escape sequence
+	GeometryPNGData string            // Base64-encoded PNG data (for saving to history)
+	Mutating        bool              // Statement changed the database; never re-run it
+	Params          map[string]string // Values bound to the SQL's {{name}} placeholders
+	Steps           []ScriptStep      // Every statement of a multi-statement script, in order
+
+	cursor     *postgres.ResultCursor // Open cursor for fetching further rows (nil when exhausted)
+	colOffset  int                    // First column shown after horizontal scrolling
+	hidden     map[int]bool           // Columns hidden from display
+	humanized  map[int]bool           // Columns of byte counts or intervals shown as "1.2 GB" or "3m40s"
+	sort       *columnSort            // How these results were re-sorted (nil when not)
+	chart      chartKind              // Chart shown above the table
+	chartImage string                 // Chart as a Kitty graphics or sixel escape sequence (empty for braille)
+	choropleth int                    // 1-based numeric column colouring the geometry preview (0 for none)
+}
+
+// mayHoldJSON reports whether column i holds json or jsonb. When the types
+// are unknown any column may, and values are checked by parsing them.
+func (r *QueryResults) mayHoldJSON(i int) bool {
+	if i < len(r.ColumnTypes) && r.ColumnTypes[i] != "" {
+		return r.ColumnTypes[i] == "JSON" || r.ColumnTypes[i] == "JSONB"
+	}
+	return true
+}
+
+// HasMoreRows reports whether further rows can be fetched from the cursor
+func (r *QueryResults) HasMoreRows() bool {
+	return r.cursor != nil && !r.cursor.Done()
+}
+
+// closeCursor releases the cursor's transaction and connection
+func (r *QueryResults) closeCursor() {
+	if r != nil && r.cursor != nil {
+		r.cursor.Close()
+		r.cursor = nil
+	}
+}
+
+// renderGeometry renders the selected geometry column, or every geometry
+// column when overlaid, into GeometryImage and GeometryPNGData. With a
+// choropleth column the geometries are coloured by its values instead.
+func (r *QueryResults) renderGeometry() {
+	r.GeometryImage = ""
+	r.GeometryPNGData = ""
+
+	if r.choropleth > 0 {
+		if png, err := r.renderChoropleth(400, 300); err == nil {
+			r.GeometryPNGData = png
+			r.GeometryImage = Base64ToTerminalGraphics(png)
+			return
+		}
+	}
+
+	layers := r.geometryLayers()
+	total := 0
+	for _, layer := range layers {
+		total += len(layer)
+	}
+
+	// Render geometries to PNG (400x300 pixels)
+	if total > 0 {
+		r.GeometryPNGData, _ = RenderLayersToPNG(layers, 400, 300)
+		r.GeometryImage = Base64ToTerminalGraphics(r.GeometryPNGData)
+	}
+}
+
+// previewColumns returns the geometry columns currently being previewed
+func (r *QueryResults) previewColumns() []int {
+	if r.GeometryOverlay {
+		return r.GeometryColumns
+	}
+	if r.GeometryColIdx < 0 {
+		return nil
+	}
+	return []int{r.GeometryColIdx}
+}
+
+// geometryLayers extracts the non-empty geometry values of each previewed
+// column from all rows, one layer per column
+func (r *QueryResults) geometryLayers() [][]string {
+	cols := r.previewColumns()
+	layers := make([][]string, len(cols))
+	for i, col := range cols {
+		for _, row := range r.Rows {
+			if col < len(row) && row[col] != "" && row[col] != "NULL" {
+				layers[i] = append(layers[i], row[col])
+			}
+		}
+	}
+	return layers
+}
+
+// cycleGeometryColumn moves the preview to the next geometry column; after
+// the last one all columns are overlaid. Returns false if there is no choice.
+func (r *QueryResults) cycleGeometryColumn() bool {
+	if len(r.GeometryColumns) < 2 {
+		return false
+	}
+
+	if r.GeometryOverlay {
+		r.GeometryOverlay = false
+		r.GeometryColIdx = r.GeometryColumns[0]
+	} else {
+		pos := 0
+		for i, col := range r.GeometryColumns {
+			if col == r.GeometryColIdx {
+				pos = i
+			}
+		}
+		if pos == len(r.GeometryColumns)-1 {
+			r.GeometryOverlay = true
+		} else {
+			r.GeometryColIdx = r.GeometryColumns[pos+1]
+		}
+	}
+
+	r.renderGeometry()
+	return true
+}
+
+// ExecutedSQL returns the SQL that actually produced these results
+func (r *QueryResults) ExecutedSQL() string {
+	if r.EditedSQL != "" {
+		return r.EditedSQL
+	}
+	return r.GeneratedSQL
 }
 
 // ConversationEntry holds a conversation turn
 type ConversationEntry struct {
-	Query    string
-	SQL      string
+	Query     string
+	SQL       string
+	EditedSQL string // Set when the user edited SQL before running it
 	Results  *QueryResults
 	Error    string
 	ShowSQL  bool // Whether SQL is visible for this entry
+	Explanation string // Plain-English description of the SQL, once asked for
+	Repairs     []config.FailedSQL // Generated SQL that failed and was repaired, oldest first
+	Unseen      bool               // Answered in the background while the next question was typed
+	Service     string             // Service answered on, when the question was asked on several
+}
+
+// sqlExplainedMsg carries the plain-English description of an entry's SQL
+type sqlExplainedMsg struct {
+	sql         string
+	explanation string
+	err         error
 }
 
 // queryExecutedMsg indicates a query was executed
 type queryExecutedMsg struct {
 	results *QueryResults
 	err     error
+	repairs []config.FailedSQL // Generated SQL that failed before the SQL that ran or errored
+}
+
+// confirmWriteMsg asks the user to confirm a mutating statement before it runs
+type confirmWriteMsg struct {
+	query        string
+	generatedSQL string
+	sql          string
+	class        postgres.StatementClass
+	params       map[string]string // Values for the SQL's {{name}} placeholders
+}
+
+// queryCancelledMsg indicates the user cancelled a running query
+type queryCancelledMsg struct {
+	query string
+}
+
+// sqlGeneratedMsg carries SQL generated for editing before execution
+type sqlGeneratedMsg struct {
+	query string
+	sql   string
+	err   error
+}
+
+// sqlEditState tracks generated SQL loaded into the editor for tweaking
+type sqlEditState struct {
+	query        string    // Natural language question the SQL answers
+	generatedSQL string    // SQL as produced by the query engine
+	usage        llm.Usage // What LLM providers used generating it
 }
 
 // moreRowsFetchedMsg indicates more rows were fetched for endless scroll
 type moreRowsFetchedMsg struct {
 	rows    [][]string
+	nulls   [][]bool
 	hasMore bool
 	err     error
 }
@@ -95,6 +342,17 @@ type schemaLoadedMsg struct {
 	err    error
 }
 
+// semanticIndexMsg carries the schema embeddings built in the background
+type semanticIndexMsg struct {
+	index *llm.SemanticIndex
+	err   error
+}
+
+// nnRetrainedMsg reports the background retraining of an outdated model
+type nnRetrainedMsg struct {
+	err error
+}
+
 // dbConnectedMsg indicates database connection was established
 type dbConnectedMsg struct {
 	db  *sql.DB
@@ -126,12 +384,12 @@ func NewQueryModel(service *postgres.ServiceEntry, schema *config.SchemaCache) *
 
 	statusStyle := lipgloss.NewStyle().
 		Foreground(ColorOrange).
-		Background(lipgloss.Color("#1a1a1a")).
+		Background(ColorStatusBar).
 		Padding(0, 1)
 
 	cursorStyle := lipgloss.NewStyle().
 		Background(ColorOrange).
-		Foreground(lipgloss.Color("#000000"))
+		Foreground(ColorOnAccent)
 
 	vimEditor := vimtea.NewEditor(
 		vimtea.WithLineNumberStyle(lineNumStyle),
@@ -162,26 +420,41 @@ func NewQueryModel(service *postgres.ServiceEntry, schema *config.SchemaCache) *
 	s.Spinner = spinner.Dot
 	s.Style = lipgloss.NewStyle().Foreground(ColorOrange)
 
-	return &QueryModel{
+	// Create query engine with the configured LLM provider, applying the
+	// service's profile on top of the global settings
+	queryEngine, embedder, initError := llm.NewServiceEngine(cfg, service, schema)
+	generator := llm.GeneratorAuto
+	if cfg != nil && slices.Contains(queryEngine.Generators(), cfg.Settings.QueryGenerator) {
+		generator = cfg.Settings.QueryGenerator
+	}
+
+	m := &QueryModel{
 		vimEditor:      vimEditor,
 		textArea:       ta,
 		vimMode:        vimMode,
 		spinner:        s,
 		service:        service,
 		schema:         schema,
-		queryEngine:    llm.NewQueryEngine(schema),
+		suggestions:    llm.SuggestQuestions(schema, maxSuggestions),
+		queryEngine:    queryEngine,
+		embedder:       embedder,
+		generator:      generator,
+		error:          initError,
 		history:        []ConversationEntry{},
 		cfg:            cfg,
-		db:             nil, // Will be established asynchronously in Init
+		conn:           sharedConnection(service, cfg), // Connected asynchronously in Init
 		scrollOffset:   0,
-		visibleRows:    15, // Will be adjusted based on screen height
+		visibleRows:    defaultVisibleRows,
 		fetchBatchSize: 50, // Fetch 50 rows at a time
 		totalFetched:   0,
 		hasMoreRows:    false,
 		convScroll:     ConversationScrollState{},
 		selectedEntry:  -1, // No entry selected initially
 		focusEditor:    true, // Start with focus on editor
+		sessions:       []*querySession{newQuerySession(config.DefaultSession)},
 	}
+	m.restoreDraft()
+	return m
 }
 
 // Init initializes the query model
@@ -216,28 +489,86 @@ func (m *QueryModel) Init() tea.Cmd {
 		cmds = append(cmds, textarea.Blink)
 	}
 
-	cmds = append(cmds, m.connectToDatabase())
+	cmds = append(cmds, m.connectToDatabase(), m.buildSemanticIndex(), m.retrainOutdatedModel())
 	return tea.Batch(cmds...)
 }
 
+// retrainOutdatedModel retrains the neural network in the background when
+// its saved model could not be loaded; nil when it is disabled or loaded
+func (m *QueryModel) retrainOutdatedModel() tea.Cmd {
+	if m.cfg == nil || !m.cfg.Settings.NeuralNetEnabled || !m.queryEngine.NNNeedsRetraining() {
+		return nil
+	}
+	engine := m.queryEngine
+	return func() tea.Msg {
+		store, err := config.History()
+		if err != nil {
+			return nnRetrainedMsg{err: err}
+		}
+		history, err := store.List(config.HistoryFilter{})
+		if err != nil {
+			return nnRetrainedMsg{err: err}
+		}
+		return nnRetrainedMsg{err: engine.TrainFromHistory(history, trainingEpochs)}
+	}
+}
+
+// buildSemanticIndex embeds the schema in the background for semantic
+// schema search; nil when no embedder is configured
+func (m *QueryModel) buildSemanticIndex() tea.Cmd {
+	if m.embedder == nil || m.schema == nil {
+		return nil
+	}
+	embedder, schema := m.embedder, m.schema
+	return func() tea.Msg {
+		index, err := llm.BuildSemanticIndex(context.Background(), embedder, schema)
+		return semanticIndexMsg{index: index, err: err}
+	}
+}
+
+// sharedConnection returns the shared connection manager of a service,
+// sized from the settings. It returns nil without a service.
+func sharedConnection(service *postgres.ServiceEntry, cfg *config.Config) *postgres.ConnectionManager {
+	if service == nil {
+		return nil
+	}
+	var opts postgres.PoolOptions
+	if cfg != nil {
+		opts.MaxConns = cfg.Settings.PoolMaxConns
+		opts.HealthCheckPeriod = time.Duration(cfg.Settings.HealthCheckSec) * time.Second
+	}
+	return postgres.SharedConnection(*service, opts)
+}
+
 // connectToDatabase establishes the database connection asynchronously
 func (m *QueryModel) connectToDatabase() tea.Cmd {
+	conn, service := m.conn, m.service.Name
 	return func() tea.Msg {
-		if m.service == nil {
+		if conn == nil {
 			return dbConnectedMsg{err: fmt.Errorf("no service configured")}
 		}
-		db, err := m.service.Connect()
+		db, err := conn.Connect(context.Background())
 		if err != nil {
-			return dbConnectedMsg{err: err}
-		}
-		// Ping to verify connection
-		if err := db.Ping(); err != nil {
+			logging.Error("connection failed", "service", service, "error", err)
 			return dbConnectedMsg{err: err}
 		}
 		return dbConnectedMsg{db: db}
 	}
 }
 
+// database returns the pool of the shared connection, or nil before it
+// has been opened
+func (m *QueryModel) database() *sql.DB {
+	if m.conn == nil {
+		return nil
+	}
+	db, err := m.conn.DB()
+	if err != nil {
+		return nil
+	}
+	return db
+}
+
 // getEditorText returns the current text from whichever editor is active
 func (m *QueryModel) getEditorText() string {
 	if m.vimMode {
@@ -246,10 +577,15 @@ func (m *QueryModel) getEditorText() string {
 	return m.textArea.Value()
 }
 
-// SetInitialQuery sets the initial text in the editor
+// SetInitialQuery replaces the text in the editor, leaving the cursor after it
 func (m *QueryModel) SetInitialQuery(query string) {
 	if m.vimMode {
-		m.vimEditor.GetBuffer().InsertAt(0, 0, query)
+		buf := m.vimEditor.GetBuffer()
+		last := buf.LineCount() - 1
+		buf.DeleteAt(0, 0, last, buf.LineLength(last))
+		buf.InsertAt(0, 0, query)
+		lines := strings.Split(query, "\n")
+		m.moveVimCursor(len(lines)-1, len(lines[len(lines)-1]))
 	} else {
 		m.textArea.SetValue(query)
 	}
@@ -275,8 +611,23 @@ func (m *QueryModel) clearEditor() tea.Cmd {
 	return nil
 }
 
-// Update handles messages for the query model
+// Update handles messages for the query model, recording the changes of
+// the textarea for undo and the question typed as the service's draft
 func (m *QueryModel) Update(msg tea.Msg) (*QueryModel, tea.Cmd) {
+	if m.vimMode {
+		_, cmd := m.update(msg)
+		return m, tea.Batch(cmd, m.trackDraft())
+	}
+	before := m.textareaSnapshot()
+	_, cmd := m.update(msg)
+	if !isUndoKey(msg) {
+		m.recordEdit(before, msg)
+	}
+	return m, tea.Batch(cmd, m.trackDraft())
+}
+
+// update handles messages for the query model
+func (m *QueryModel) update(msg tea.Msg) (*QueryModel, tea.Cmd) {
 	var cmds []tea.Cmd
 
 	switch msg := msg.(type) {
@@ -296,6 +647,21 @@ func (m *QueryModel) Update(msg tea.Msg) (*QueryModel, tea.Cmd) {
 			m.textArea.SetWidth(editorWidth)
 			m.textArea.SetHeight(5)
 		}
+		if m.mapView != nil {
+			m.mapView.Update(msg)
+		}
+		if m.planView != nil {
+			m.planView.Update(msg)
+		}
+		if m.rowDetail != nil {
+			m.rowDetail.Update(msg)
+		}
+		if m.diffView != nil {
+			m.diffView.Update(msg)
+		}
+		if m.pager != nil {
+			m.pager.Update(msg)
+		}
 		return m, tea.Batch(cmds...)
 
 	case spinner.TickMsg:
@@ -307,79 +673,264 @@ func (m *QueryModel) Update(msg tea.Msg) (*QueryModel, tea.Cmd) {
 		if msg.err != nil {
 			m.error = "Connection failed: " + msg.err.Error()
 		} else {
-			m.db = msg.db
+			m.queryEngine.SetDB(msg.db)
 		}
 		return m, nil
 
 	case queryExecutedMsg:
 		m.loading = false
+		// The question's span ends once the answer has been drawn
+		if m.querySpan != nil {
+			tracing.Fail(m.querySpan, msg.err)
+			m.renderSpan, m.querySpan = m.querySpan, nil
+		}
+		m.releaseQueryContext()
+		used := m.questionUsage()
+		m.sqlEdit = nil
+		learned := ""
 		if msg.err != nil {
 			m.error = msg.err.Error()
 			m.history = append(m.history, ConversationEntry{
-				Query:   m.getEditorText(),
+				Query:   m.backgroundQuestion(),
 				Error:   msg.err.Error(),
 				ShowSQL: false,
+				Repairs: msg.repairs,
 			})
 			m.selectedEntry = len(m.history) - 1
 		} else {
+			// Only the latest entry scrolls, so older cursors can be released
+			m.results.closeCursor()
 			m.results = msg.results
 			m.error = ""
 			m.history = append(m.history, ConversationEntry{
-				Query:   msg.results.NaturalQuery,
-				SQL:     msg.results.GeneratedSQL,
-				Results: msg.results,
-				ShowSQL: false, // SQL hidden by default
+				Query:     msg.results.NaturalQuery,
+				SQL:       msg.results.GeneratedSQL,
+				EditedSQL: msg.results.EditedSQL,
+				Results:   msg.results,
+				ShowSQL:   msg.results.EditedSQL != "", // SQL hidden by default unless edited
+				Repairs:   msg.repairs,
 			})
 			m.selectedEntry = len(m.history) - 1
 
 			// Initialize endless scroll state
 			m.scrollOffset = 0
+			m.visibleRows = defaultVisibleRows
 			m.totalFetched = len(msg.results.Rows)
-			m.currentSQL = msg.results.GeneratedSQL
-			m.hasMoreRows = msg.results.RowCount > m.totalFetched || m.totalFetched == m.fetchBatchSize
+			m.hasMoreRows = msg.results.HasMoreRows()
 
 			// Update global state
 			GlobalAppState.QueryCount++
 			GlobalAppState.LastQueryTime = msg.results.ExecutionTime
 
-			// Save to config history
+			// Record in the query history database
 			if m.cfg != nil && m.service != nil {
 				// Save geometry image to file if present
 				var geomImageID string
 				if msg.results.GeometryPNGData != "" {
 					geomImageID, _ = config.SaveGeometryImage(msg.results.GeometryPNGData)
+					config.PruneGeometryImages(int64(m.cfg.Settings.GeometryCacheMB) << 20)
 				}
 
 				m.cfg.AddQueryToHistory(config.QueryHistoryEntry{
 					Timestamp:       time.Now(),
 					NaturalQuery:    msg.results.NaturalQuery,
 					GeneratedSQL:    msg.results.GeneratedSQL,
+					EditedSQL:       msg.results.EditedSQL,
 					ServiceName:     m.service.Name,
 					RowsAffected:    msg.results.RowCount,
 					ExecutionTime:   msg.results.ExecutionTime,
 					Success:         true,
 					HasGeometry:     msg.results.GeometryColIdx >= 0,
 					GeometryImageID: geomImageID,
+					Generator:       m.askedWith,
+					InputTokens:     used.InputTokens,
+					OutputTokens:    used.OutputTokens,
+					Cost:            used.Cost,
 				})
-				m.cfg.Save()
+				sql := msg.results.EditedSQL
+				if sql == "" {
+					sql = msg.results.GeneratedSQL
+				}
+				m.queryEngine.AddExample(msg.results.NaturalQuery, sql)
+				if msg.results.EditedSQL != "" {
+					learned = m.learnCorrections(msg.results)
+				}
 			}
 		}
+		if m.background != nil {
+			// The editor was cleared when the question was asked and may
+			// hold the next one by now
+			m.finishBackground(&m.history[len(m.history)-1])
+			if learned != "" {
+				m.statusMsg += " · " + learned
+			}
+			m.saveConversation()
+			return m, nil
+		}
+		if learned != "" {
+			m.statusMsg = "✓ " + learned
+		}
+		m.saveConversation()
 		// Clear editor content
 		return m, m.clearEditor()
 
 	case moreRowsFetchedMsg:
+		m.fetchingMore = false
 		if msg.err != nil {
 			m.error = "Failed to fetch more rows: " + msg.err.Error()
 		} else if msg.rows != nil && len(msg.rows) > 0 {
 			// Append new rows to results
 			m.results.Rows = append(m.results.Rows, msg.rows...)
+			m.results.Nulls = append(m.results.Nulls, msg.nulls...)
 			m.totalFetched = len(m.results.Rows)
 			m.hasMoreRows = msg.hasMore
+			m.visibleRows += len(msg.rows)
+			if m.results.chart != chartNone {
+				m.results.renderChart()
+			}
 		} else {
 			m.hasMoreRows = false
 		}
 		return m, nil
 
+	case planLoadedMsg:
+		if msg.cancelled {
+			// ctrl+c already stopped loading; another query may be running
+			m.statusMsg = "EXPLAIN ANALYZE cancelled"
+			return m, nil
+		}
+		m.loading = false
+		m.releaseQueryContext()
+		if msg.err != nil {
+			m.statusMsg = "✗ EXPLAIN ANALYZE failed: " + msg.err.Error()
+		} else {
+			m.statusMsg = ""
+			m.planView = NewPlanViewModel(msg.sql, msg.result, m.width, m.height)
+		}
+		return m, nil
+
+	case sqlExplainedMsg:
+		if msg.err != nil {
+			m.statusMsg = "✗ Could not explain SQL: " + msg.err.Error()
+			return m, nil
+		}
+		m.statusMsg = ""
+		for i := range m.history {
+			entry := &m.history[i]
+			if entry.EditedSQL == msg.sql || entry.EditedSQL == "" && entry.SQL == msg.sql {
+				entry.Explanation = msg.explanation
+			}
+		}
+		return m, nil
+
+	case servicesAnsweredMsg:
+		return m.addServiceAnswers(msg)
+
+	case queryCancelledMsg:
+		m.background = nil
+		m.history = append(m.history, ConversationEntry{
+			Query: msg.query,
+			Error: "Query cancelled",
+		})
+		m.selectedEntry = len(m.history) - 1
+		m.saveConversation()
+		return m, nil
+
+	case sqlGeneratedMsg:
+		m.loading = false
+		m.releaseQueryContext()
+		if msg.err != nil {
+			m.history = append(m.history, ConversationEntry{
+				Query: msg.query,
+				Error: msg.err.Error(),
+			})
+			m.selectedEntry = len(m.history) - 1
+			return m, nil
+		}
+		cmd := m.startSQLEdit(msg.query, msg.sql)
+		m.sqlEdit.usage = m.questionUsage()
+		return m, cmd
+
+	case confirmWriteMsg:
+		m.loading = false
+		m.releaseQueryContext()
+		m.pendingWrite = &msg
+		return m, nil
+
+	case templateParamsMsg:
+		m.loading = false
+		m.releaseQueryContext()
+		m.openParamPrompt(msg)
+		return m, nil
+
+	case nnRetrainedMsg:
+		if msg.err != nil {
+			m.statusMsg = "✗ Neural network retraining failed: " + msg.err.Error()
+		} else {
+			m.statusMsg = "✓ Neural network retrained from history"
+		}
+		return m, nil
+
+	case semanticIndexMsg:
+		if msg.err != nil {
+			m.statusMsg = "✗ Semantic schema search unavailable: " + msg.err.Error()
+		} else {
+			m.queryEngine.SetSemanticIndex(msg.index)
+			m.statusMsg = fmt.Sprintf("✓ Semantic schema search ready (%d descriptions)", msg.index.Len())
+		}
+		return m, nil
+
+	case schemaChoiceMsg:
+		m.loading = false
+		m.releaseQueryContext()
+		m.schemaPrompt = &schemaPromptState{schemaChoiceMsg: msg}
+		return m, nil
+
+	case snapshotSavedMsg:
+		if msg.err != nil {
+			m.statusMsg = fmt.Sprintf("✗ Snapshot %q failed: %s", msg.name, msg.err)
+		} else {
+			m.statusMsg = fmt.Sprintf("✓ Saved %d rows as snapshot %q", msg.rows, msg.name)
+		}
+		return m, nil
+
+	case snapshotDiffMsg:
+		m.showSnapshotDiff(msg)
+		return m, nil
+
+	case exportCompletedMsg:
+		if msg.cancelled {
+			// ctrl+c already stopped loading; another query may be running
+			m.statusMsg = "Export cancelled"
+			return m, nil
+		}
+		m.loading = false
+		m.releaseQueryContext()
+		if msg.err != nil {
+			m.statusMsg = "✗ Export failed: " + msg.err.Error()
+		} else {
+			m.statusMsg = fmt.Sprintf("✓ Exported %d rows to %s", msg.rows, msg.path)
+		}
+		return m, nil
+
+	case clipboardCopiedMsg:
+		if msg.err != nil {
+			m.statusMsg = "✗ Copy failed: " + msg.err.Error()
+		} else if msg.native {
+			m.statusMsg = fmt.Sprintf("✓ Copied %s to the clipboard", msg.what)
+		} else {
+			m.statusMsg = fmt.Sprintf("✓ Copied %s via the terminal (OSC 52)", msg.what)
+		}
+		return m, nil
+
+	case reportCompletedMsg:
+		if msg.err != nil {
+			m.statusMsg = "✗ Report failed: " + msg.err.Error()
+		} else {
+			m.statusMsg = fmt.Sprintf("✓ Wrote report of %d entries to %s", msg.entries, msg.path)
+		}
+		return m, nil
+
 	case tea.MouseMsg:
 		// Handle mouse scroll for conversation area
 		if len(m.history) > 0 {
@@ -408,17 +959,231 @@ func (m *QueryModel) Update(msg tea.Msg) (*QueryModel, tea.Cmd) {
 		}
 
 	case tea.KeyMsg:
+		// Any key but Tab ends a name completion
+		if msg.Type != tea.KeyTab && msg.Type != tea.KeyShiftTab {
+			m.completion = nil
+		}
+
 		// Handle ctrl+c - cancel or quit (always intercept this, don't pass to editor)
 		if msg.Type == tea.KeyCtrlC {
 			if m.loading {
+				// Cancelling the context makes lib/pq send a cancel request,
+				// so the statement is aborted server-side too
+				m.releaseQueryContext()
 				m.loading = false
 				return m, nil
 			}
+			m.flushDraft()
 			return m, tea.Quit
 		}
 
+		// Map view captures keys while open
+		if m.mapView != nil {
+			if msg.Type == tea.KeyEsc || msg.String() == "q" {
+				m.mapView = nil
+				return m, nil
+			}
+			var cmd tea.Cmd
+			m.mapView, cmd = m.mapView.Update(msg)
+			return m, cmd
+		}
+
+		// Plan viewer captures keys while open
+		if m.planView != nil {
+			if msg.Type == tea.KeyEsc || msg.String() == "q" {
+				m.planView = nil
+				return m, nil
+			}
+			var cmd tea.Cmd
+			m.planView, cmd = m.planView.Update(msg)
+			return m, cmd
+		}
+
+		// Row detail view captures keys while open
+		if m.rowDetail != nil {
+			if msg.Type == tea.KeyEsc || msg.String() == "q" {
+				m.rowSelect.row = min(m.rowDetail.row, m.displayedRows(m.rowDetail.results, m.rowSelect.entry == len(m.history)-1)-1)
+				m.rowDetail = nil
+				return m, nil
+			}
+			var cmd tea.Cmd
+			m.rowDetail, cmd = m.rowDetail.Update(msg)
+			return m, cmd
+		}
+
+		// Diff view captures keys while open
+		if m.diffView != nil {
+			if msg.Type == tea.KeyEsc || msg.String() == "q" {
+				m.diffView = nil
+				return m, nil
+			}
+			var cmd tea.Cmd
+			m.diffView, cmd = m.diffView.Update(msg)
+			return m, cmd
+		}
+
+		// Pager captures keys while open, fetching further rows of the
+		// latest result once its last loaded row is reached
+		if m.pager != nil {
+			if !m.pager.prompting() && (msg.Type == tea.KeyEsc || msg.String() == "q") {
+				m.pager = nil
+				return m, nil
+			}
+			var cmd tea.Cmd
+			m.pager, cmd = m.pager.Update(msg)
+			if m.pager.atEnd() && m.pager.results == m.results && m.hasMoreRows && !m.fetchingMore {
+				m.fetchingMore = true
+				cmd = tea.Batch(cmd, m.fetchMoreRows())
+			}
+			return m, cmd
+		}
+
+		// Write confirmation modal captures keys while open
+		if m.pendingWrite != nil {
+			pending := m.pendingWrite
+			m.pendingWrite = nil
+			if msg.String() == "y" {
+				m.loading = true
+				ctx := m.newQueryContext()
+				return m, tea.Batch(m.spinner.Tick, cancellable(ctx, pending.query, func() tea.Msg {
+					return m.runSQL(ctx, pending.query, pending.generatedSQL, pending.sql, pending.class, pending.params)
+				}))
+			}
+			return m, func() tea.Msg {
+				return queryExecutedMsg{err: fmt.Errorf("cancelled %s statement\nSQL: %s", pending.class, pending.sql)}
+			}
+		}
+
+		// Template parameter prompt captures keys while open
+		if m.paramPrompt != nil {
+			return m.handleParamPromptKey(msg)
+		}
+
+		// Schema prompt captures keys while open
+		if m.schemaPrompt != nil {
+			return m.handleSchemaPromptKey(msg)
+		}
+
+		// Service picker captures keys while open
+		if m.servicePicker != nil {
+			return m.handleServicePickerKey(msg)
+		}
+
+		// New session name prompt captures keys while open
+		if m.sessionPrompt != nil {
+			return m.handleSessionPromptKey(msg)
+		}
+
+		// Snapshot name prompt captures keys while open
+		if m.snapshotPrompt != nil {
+			return m.handleSnapshotPromptKey(msg)
+		}
+
+		// Conversation search pattern captures keys while typed
+		if m.search != nil && m.search.prompt != nil {
+			return m.handleSearchPromptKey(msg)
+		}
+
+		// Handle ctrl+t to start a new named session
+		if key.Matches(msg, key.NewBinding(key.WithKeys("ctrl+t"))) {
+			if m.canSwitchSession() {
+				name := ""
+				m.sessionPrompt = &name
+			}
+			return m, nil
+		}
+
+		// Handle ctrl+n/ctrl+p to switch to the next/previous session
+		if key.Matches(msg, key.NewBinding(key.WithKeys("ctrl+n"))) {
+			m.switchSession(1)
+			return m, nil
+		}
+		if key.Matches(msg, key.NewBinding(key.WithKeys("ctrl+p"))) {
+			m.switchSession(-1)
+			return m, nil
+		}
+
+		// Handle ctrl+w to close the active session (browse mode only, so it
+		// never steals the editor's delete-word)
+		if !m.focusEditor && key.Matches(msg, key.NewBinding(key.WithKeys("ctrl+w"))) {
+			m.closeSession()
+			return m, nil
+		}
+
+		// Row selection captures keys while active
+		if m.rowSelect != nil {
+			return m.handleRowSelectKey(msg)
+		}
+
+		// Column selection captures keys while active
+		if m.colSelect != nil {
+			return m.handleColumnSelectKey(msg)
+		}
+
+		// Pivot picker captures keys while open
+		if m.pivot != nil {
+			return m.handlePivotKey(msg)
+		}
+
+		// Export format picker captures keys while open
+		if m.exportPicker {
+			m.exportPicker = false
+			results := m.exportTarget()
+			switch msg.String() {
+			case "c", "j", "g", "p":
+				if results == nil {
+					m.statusMsg = ""
+					return m, nil
+				}
+				if m.loading {
+					m.statusMsg = "✗ A query is still running (ctrl+c: cancel it)"
+					return m, nil
+				}
+			}
+			switch msg.String() {
+			case "c":
+				return m, m.startExport(results, ExportCSV, "CSV")
+			case "j":
+				return m, m.startExport(results, ExportJSON, "JSON")
+			case "g":
+				if m.canExportGeoJSON() {
+					return m, m.startExport(results, ExportGeoJSON, "GeoJSON")
+				}
+			case "p":
+				if m.canExportGeoJSON() {
+					return m, m.startExport(results, ExportGPKG, "GeoPackage")
+				}
+			case "m":
+				m.statusMsg = "Writing Markdown report..."
+				return m, m.exportReport(ExportMarkdown)
+			case "h":
+				m.statusMsg = "Writing HTML report..."
+				return m, m.exportReport(ExportHTML)
+			}
+			m.statusMsg = ""
+			return m, nil
+		}
+
+		// Handle ctrl+e to export the selected (or latest) result set, or
+		// the whole conversation as a report
+		if key.Matches(msg, key.NewBinding(key.WithKeys("ctrl+e"))) {
+			if len(m.history) > 0 && !m.loading {
+				m.exportPicker = true
+			}
+			return m, nil
+		}
+
+		// Handle F2 to switch what answers the next question
+		if msg.Type == tea.KeyF2 {
+			if !m.loading {
+				m.switchGenerator()
+			}
+			return m, nil
+		}
+
 		// Handle F1 to go back to menu (vim-friendly) - don't pass to editor
 		if msg.Type == tea.KeyF1 {
+			m.flushDraft()
 			return m, func() tea.Msg {
 				return goToMenuMsg{}
 			}
@@ -426,6 +1191,7 @@ func (m *QueryModel) Update(msg tea.Msg) (*QueryModel, tea.Cmd) {
 
 		// Handle ctrl+h to go to history
 		if key.Matches(msg, key.NewBinding(key.WithKeys("ctrl+h"))) {
+			m.flushDraft()
 			return m, func() tea.Msg {
 				return goToHistoryMsg{}
 			}
@@ -433,6 +1199,7 @@ func (m *QueryModel) Update(msg tea.Msg) (*QueryModel, tea.Cmd) {
 
 		// Handle ctrl+l to clear results
 		if key.Matches(msg, key.NewBinding(key.WithKeys("ctrl+l"))) {
+			m.results.closeCursor()
 			m.results = nil
 			m.error = ""
 			m.scrollOffset = 0
@@ -451,6 +1218,7 @@ func (m *QueryModel) Update(msg tea.Msg) (*QueryModel, tea.Cmd) {
 				} else {
 					// Leave editor focus, go to conversation
 					m.focusEditor = false
+					m.markSeen()
 					if len(m.history) > 0 && m.selectedEntry < 0 {
 						m.selectedEntry = len(m.history) - 1
 					}
@@ -479,6 +1247,32 @@ func (m *QueryModel) Update(msg tea.Msg) (*QueryModel, tea.Cmd) {
 			}
 		}
 
+		// Handle ctrl+y in the editor to take the first question suggested for the text typed
+		if m.focusEditor && msg.String() == "ctrl+y" {
+			if m.acceptSuggestion() {
+				return m, nil
+			}
+		}
+
+		// Handle ctrl+z/ctrl+r in the editor to undo and redo changes
+		if m.focusEditor && isUndoKey(msg) {
+			if m.vimMode {
+				if msg.String() == "ctrl+r" {
+					return m, m.vimEditor.GetBuffer().Redo()
+				}
+				return m, m.vimEditor.GetBuffer().Undo()
+			}
+			m.undoEdit(msg.String() == "ctrl+r")
+			return m, nil
+		}
+
+		// Handle tab in the editor to complete the table or column name being typed
+		if m.focusEditor && (msg.Type == tea.KeyTab || msg.Type == tea.KeyShiftTab) {
+			if handled, cmd := m.completeSchemaName(msg.Type == tea.KeyShiftTab); handled {
+				return m, cmd
+			}
+		}
+
 		// Handle tab to cycle through conversation entries (when not focused on editor)
 		if msg.Type == tea.KeyTab && len(m.history) > 0 && !m.focusEditor {
 			m.selectedEntry++
@@ -497,20 +1291,253 @@ func (m *QueryModel) Update(msg tea.Msg) (*QueryModel, tea.Cmd) {
 			return m, nil
 		}
 
-		// Handle ctrl+s to execute query (works in any vim mode)
+		// Handle ctrl+o to generate SQL into the editor (or cancel SQL editing)
+		if key.Matches(msg, key.NewBinding(key.WithKeys("ctrl+o"))) && !m.loading {
+			if m.sqlEdit != nil {
+				m.sqlEdit = nil
+				return m, m.clearEditor()
+			}
+			content := strings.TrimSpace(m.getEditorText())
+			if content != "" {
+				m.loading = true
+				return m, tea.Batch(m.spinner.Tick, m.generateForEdit(m.newQueryContext(), content))
+			}
+			return m, nil
+		}
+
+		// Handle '/' and '?' to search the conversation forward and backward
+		if !m.focusEditor && (msg.String() == "/" || msg.String() == "?") && len(m.history) > 0 {
+			m.openSearchPrompt(msg.String() == "?")
+			return m, nil
+		}
+
+		// While searching, 'n' and 'N' move to the next and previous match
+		// and Esc ends the search
+		if !m.focusEditor && m.search != nil {
+			switch {
+			case msg.String() == "n":
+				m.findMatch(m.search.backward)
+				return m, nil
+			case msg.String() == "N":
+				m.findMatch(!m.search.backward)
+				return m, nil
+			case msg.Type == tea.KeyEsc:
+				m.search = nil
+				m.statusMsg = ""
+				return m, nil
+			}
+		}
+
+		// Handle 'n' to show more rows of the latest result, fetching from its cursor as needed
+		if !m.focusEditor && msg.String() == "n" && m.results != nil {
+			if m.visibleRows < len(m.results.Rows) {
+				m.visibleRows = min(m.visibleRows+m.fetchBatchSize, len(m.results.Rows))
+				return m, nil
+			}
+			if m.hasMoreRows && !m.fetchingMore {
+				m.fetchingMore = true
+				return m, m.fetchMoreRows()
+			}
+			return m, nil
+		}
+
+		// Handle 'l' to switch the geometry preview of the selected entry to the next geometry column
+		if !m.focusEditor && msg.String() == "l" && m.selectedEntry >= 0 && m.selectedEntry < len(m.history) {
+			if results := m.history[m.selectedEntry].Results; results != nil {
+				results.cycleGeometryColumn()
+			}
+			return m, nil
+		}
+
+		// Handle 't' to colour the selected entry's geometry preview by a numeric column
+		if !m.focusEditor && msg.String() == "t" {
+			m.cycleChoropleth()
+			return m, nil
+		}
+
+		// Handle 'm' to open the selected entry's geometries in the map view
+		if !m.focusEditor && msg.String() == "m" && m.selectedEntry >= 0 && m.selectedEntry < len(m.history) {
+			if plainOutput {
+				m.statusMsg = "✗ The map is drawn as graphics; turn Plain Output off to see it"
+			} else if mapView := NewMapViewModel(m.history[m.selectedEntry].Results, m.width, m.height); mapView != nil {
+				m.mapView = mapView
+			} else {
+				m.statusMsg = "No geometries to show on a map"
+			}
+			return m, nil
+		}
+
+		// Handle 'p' to pivot the selected entry's results by a column
+		if !m.focusEditor && msg.String() == "p" && !m.loading && m.selectedEntry >= 0 && m.selectedEntry < len(m.history) {
+			m.pivot = newPivotState(m.history[m.selectedEntry].Results)
+			if m.pivot == nil {
+				m.statusMsg = "✗ Nothing to pivot for this entry"
+			}
+			return m, nil
+		}
+
+		// Handle 'D' to compare the selected entry's results with another
+		// entry's, and Esc to stop choosing the other
+		if !m.focusEditor && msg.String() == "D" && !m.loading {
+			m.toggleDiff()
+			return m, nil
+		}
+		if !m.focusEditor && msg.Type == tea.KeyEsc && m.diffFrom != nil {
+			m.diffFrom = nil
+			m.statusMsg = ""
+			return m, nil
+		}
+
+		// Handle 'S' to save the selected entry's rows as a named snapshot,
+		// or compare a fresh run with one
+		if !m.focusEditor && msg.String() == "S" && !m.loading {
+			m.openSnapshotPrompt()
+			return m, nil
+		}
+
+		// Handle left/right to scroll the selected entry's table sideways
+		if !m.focusEditor && (msg.String() == "left" || msg.String() == "right") {
+			if msg.String() == "left" {
+				m.scrollColumns(-1)
+			} else {
+				m.scrollColumns(1)
+			}
+			return m, nil
+		}
+
+		// Handle 'f' to keep the first column in place while scrolling sideways
+		if !m.focusEditor && msg.String() == "f" && m.cfg != nil {
+			m.cfg.Settings.FreezeFirstColumn = !m.cfg.Settings.FreezeFirstColumn
+			m.cfg.Save()
+			return m, nil
+		}
+
+		// Handle 'v' to chart the selected entry's results as bars, a line or not at all
+		if !m.focusEditor && msg.String() == "v" {
+			m.cycleChart()
+			return m, nil
+		}
+
+		// Handle 'c' to pick a column of the selected entry's results to sort by or hide
+		if !m.focusEditor && msg.String() == "c" {
+			m.startColumnSelect()
+			return m, nil
+		}
+
+		// Handle 'o' to open the selected entry's results in the full-screen pager
+		if !m.focusEditor && msg.String() == "o" && m.selectedEntry >= 0 && m.selectedEntry < len(m.history) {
+			if results := m.history[m.selectedEntry].Results; results != nil && len(results.Columns) > 0 {
+				m.pager = NewPagerModel(results, m.width, m.height)
+			} else {
+				m.statusMsg = "✗ No results to page for this entry"
+			}
+			return m, nil
+		}
+
+		// Handle 'r' to pick a row of the selected entry's results to inspect
+		if !m.focusEditor && msg.String() == "r" {
+			m.startRowSelect()
+			return m, nil
+		}
+
+		// Handle 'x' to EXPLAIN ANALYZE the selected entry's SQL
+		if !m.focusEditor && msg.String() == "x" && m.selectedEntry >= 0 && m.selectedEntry < len(m.history) {
+			if m.loading {
+				m.statusMsg = "✗ A query is still running (ctrl+c: cancel it)"
+				return m, nil
+			}
+			if results := m.history[m.selectedEntry].Results; results != nil && !results.Mutating {
+				m.statusMsg = "Running EXPLAIN ANALYZE... (ctrl+c: cancel)"
+				m.loading = true
+				return m, m.explainAnalyze(m.newQueryContext(), results.ExecutedSQL(), results.Params)
+			}
+			m.statusMsg = "✗ Only successful read queries can be explained"
+			return m, nil
+		}
+
+		// Handle 'd' to describe the selected entry's SQL in plain English
+		if !m.focusEditor && msg.String() == "d" && m.selectedEntry >= 0 && m.selectedEntry < len(m.history) {
+			entry := &m.history[m.selectedEntry]
+			if entry.SQL == "" {
+				m.statusMsg = "✗ This entry has no SQL to explain"
+				return m, nil
+			}
+			entry.ShowSQL = true
+			if entry.Explanation != "" {
+				return m, nil
+			}
+			sqlText := entry.SQL
+			if entry.EditedSQL != "" {
+				sqlText = entry.EditedSQL
+			}
+			m.statusMsg = "Explaining SQL..."
+			return m, m.explainSQL(entry.Query, sqlText)
+		}
+
+		// Handle 'y' to copy the selected entry's SQL, and 'Y' its results as TSV
+		if !m.focusEditor && msg.String() == "y" {
+			return m, m.copySQL()
+		}
+		if !m.focusEditor && msg.String() == "Y" {
+			return m, m.copyResults()
+		}
+
+		// Handle 'e' on a selected entry to edit and re-run its SQL
+		if !m.focusEditor && msg.String() == "e" && m.selectedEntry >= 0 && m.selectedEntry < len(m.history) {
+			entry := m.history[m.selectedEntry]
+			if entry.SQL != "" && !m.loading {
+				sqlText := entry.SQL
+				if entry.EditedSQL != "" {
+					sqlText = entry.EditedSQL
+				}
+				m.focusEditor = true
+				return m, m.startSQLEdit(entry.Query, sqlText)
+			}
+			return m, nil
+		}
+
+		// Handle ctrl+s to execute query (works in any vim mode). It runs in
+		// the background, the editor cleared for the next question.
 		if key.Matches(msg, key.NewBinding(key.WithKeys("ctrl+s"))) {
 			content := strings.TrimSpace(m.getEditorText())
+			if m.loading && content != "" {
+				m.statusMsg = "✗ A query is still running (ctrl+c: cancel it)"
+				return m, nil
+			}
+			if !m.loading && content != "" && m.sqlEdit != nil {
+				m.loading = true
+				m.scrollOffset = 0
+				run := m.executeEditedSQL(m.newQueryContext(), content)
+				return m, tea.Batch(
+					m.spinner.Tick,
+					run,
+					m.runInBackground(m.sqlEdit.query, m.sqlEdit.usage),
+				)
+			}
 			if !m.loading && content != "" {
 				m.loading = true
 				m.scrollOffset = 0 // Reset scroll on new query
 				return m, tea.Batch(
 					m.spinner.Tick,
-					m.executeQuery(content),
+					m.executeQuery(m.newQueryContext(), content),
+					m.runInBackground(content, llm.Usage{}),
 				)
 			}
 			return m, nil
 		}
 
+		// Handle ctrl+x to ask the question (or run the SQL being edited)
+		// on several services at once, e.g. to compare staging and production
+		if m.focusEditor && key.Matches(msg, key.NewBinding(key.WithKeys("ctrl+x"))) {
+			content := strings.TrimSpace(m.getEditorText())
+			if m.loading && content != "" {
+				m.statusMsg = "✗ A query is still running (ctrl+c: cancel it)"
+			} else if content != "" {
+				m.openServicePicker(content)
+			}
+			return m, nil
+		}
+
 		// Conversation scroll controls - only when NOT focused on editor
 		if len(m.history) > 0 && !m.focusEditor {
 			maxScroll := m.convScroll.totalLines - m.convScroll.visibleLines
@@ -594,64 +1621,400 @@ func (m *QueryModel) Update(msg tea.Msg) (*QueryModel, tea.Cmd) {
 	return m, tea.Batch(cmds...)
 }
 
-// executeQuery executes a natural language query with initial batch fetch
-func (m *QueryModel) executeQuery(query string) tea.Cmd {
+// newQueryContext creates a cancellable context for the next query, cancelling any previous one,
+// and starts the span of the question and the meter of its provider requests in it
+func (m *QueryModel) newQueryContext() context.Context {
+	m.releaseQueryContext()
+	ctx, cancel := context.WithCancel(sessionUsageContext)
+	ctx, m.querySpan = tracing.Start(ctx, "question", tracing.Service.String(m.service.Name))
+	ctx, m.usage = llm.MeterUsage(ctx)
+	m.cancelQuery = cancel
+	return ctx
+}
+
+// questionUsage returns what LLM providers used answering the latest
+// question, including generating SQL that is being edited
+func (m *QueryModel) questionUsage() llm.Usage {
+	var used llm.Usage
+	if m.usage != nil {
+		used = m.usage.Total()
+	}
+	if m.background != nil {
+		used = used.Add(m.background.usage)
+	}
+	if m.sqlEdit != nil {
+		used = used.Add(m.sqlEdit.usage)
+	}
+	return used
+}
+
+// releaseQueryContext cancels the current query context, if any, and ends
+// the span of its question
+func (m *QueryModel) releaseQueryContext() {
+	if m.cancelQuery != nil {
+		m.cancelQuery()
+		m.cancelQuery = nil
+	}
+	if m.querySpan != nil {
+		m.querySpan.End()
+		m.querySpan = nil
+	}
+}
+
+// cancellable runs cmd and replaces its result with queryCancelledMsg when
+// ctx was cancelled while it ran, so stale results never reach the model
+func cancellable(ctx context.Context, query string, cmd tea.Cmd) tea.Cmd {
 	return func() tea.Msg {
-		if m.db == nil {
+		trace.SpanFromContext(ctx).SetAttributes(tracing.Question.String(query))
+		msg := cmd()
+		if ctx.Err() == nil {
+			return msg
+		}
+		if executed, ok := msg.(queryExecutedMsg); ok && executed.results != nil {
+			executed.results.closeCursor()
+		}
+		return queryCancelledMsg{query: query}
+	}
+}
+
+// executeQuery executes a natural language query with initial batch fetch
+func (m *QueryModel) executeQuery(ctx context.Context, query string) tea.Cmd {
+	generator := m.askWith()
+	return cancellable(ctx, query, func() tea.Msg {
+		if m.database() == nil {
 			return queryExecutedMsg{err: fmt.Errorf("no database connection")}
 		}
 
 		// Generate SQL from natural language
-		sqlQuery, err := m.queryEngine.GenerateSQL(query, m.getConversationContext())
+		sqlQuery, err := m.generateSQL(ctx, generator, query)
+		if choice, ok := schemaChoiceFor(err, query, false); ok {
+			return choice
+		}
 		if err != nil {
 			return queryExecutedMsg{err: fmt.Errorf("failed to generate SQL: %w", err)}
 		}
 
-		// Ensure connection is alive
-		if err := m.db.Ping(); err != nil {
-			// Try to reconnect
-			if m.service != nil {
-				m.db, _ = m.service.Connect()
-			}
-			if m.db == nil {
-				return queryExecutedMsg{err: fmt.Errorf("failed to reconnect: %w", err)}
-			}
+		return m.prepareSQL(ctx, query, sqlQuery, sqlQuery, nil)
+	})
+}
+
+// generateForEdit generates SQL for a question without executing it
+func (m *QueryModel) generateForEdit(ctx context.Context, query string) tea.Cmd {
+	generator := m.askWith()
+	return cancellable(ctx, query, func() tea.Msg {
+		sqlQuery, err := m.generateSQL(ctx, generator, query)
+		if choice, ok := schemaChoiceFor(err, query, true); ok {
+			return choice
+		}
+		if err != nil {
+			return sqlGeneratedMsg{query: query, err: fmt.Errorf("failed to generate SQL: %w", err)}
 		}
+		return sqlGeneratedMsg{query: query, sql: sqlQuery}
+	})
+}
+
+// generateSQL asks the engine for SQL answering a question, logging the
+// SQL or the failure and how long generation took
+func (m *QueryModel) generateSQL(ctx context.Context, generator, query string) (string, error) {
+	start := time.Now()
+	sqlQuery, err := m.queryEngine.GenerateSQLWith(ctx, generator, query, m.getConversationContext())
+	attrs := []any{"service", m.service.Name, "question", query, "generator", generator, "duration_ms", time.Since(start).Milliseconds()}
+	if err != nil {
+		logging.Warn("sql generation failed", append(attrs, "error", err)...)
+	} else {
+		logging.Info("sql generated", append(attrs, "sql", sqlQuery)...)
+	}
+	return sqlQuery, err
+}
+
+// askWith returns the generator chosen for the next question, recording
+// its label for the question's history entry
+func (m *QueryModel) askWith() string {
+	m.askedWith = m.queryEngine.GeneratorLabel(m.generator)
+	return m.generator
+}
+
+// switchGenerator chooses the next generator available for questions
+func (m *QueryModel) switchGenerator() {
+	m.generator = nextOption(m.queryEngine.Generators(), m.generator)
+	m.statusMsg = "Next questions answered by: " + m.queryEngine.GeneratorLabel(m.generator)
+}
+
+// explainSQL describes sql in plain English in the background, using the
+// configured provider or the rule-based description
+func (m *QueryModel) explainSQL(query, sqlText string) tea.Cmd {
+	engine := m.queryEngine
+	return func() tea.Msg {
+		if engine == nil {
+			return sqlExplainedMsg{sql: sqlText, explanation: llm.DescribeSQL(sqlText)}
+		}
+		explanation, err := engine.ExplainSQL(sessionUsageContext, sqlText, query)
+		return sqlExplainedMsg{sql: sqlText, explanation: explanation, err: err}
+	}
+}
+
+// startSQLEdit loads SQL into the editor so it can be tweaked before running
+func (m *QueryModel) startSQLEdit(query, sqlQuery string) tea.Cmd {
+	cmd := m.clearEditor()
+	m.SetInitialQuery(sqlQuery)
+	m.sqlEdit = &sqlEditState{query: query, generatedSQL: sqlQuery}
+	return cmd
+}
+
+// executeEditedSQL runs the SQL in the editor in place of the generated SQL
+func (m *QueryModel) executeEditedSQL(ctx context.Context, sqlQuery string) tea.Cmd {
+	edit := *m.sqlEdit
+	return cancellable(ctx, edit.query, func() tea.Msg {
+		if m.database() == nil {
+			return queryExecutedMsg{err: fmt.Errorf("no database connection")}
+		}
+		return m.prepareSQL(ctx, edit.query, edit.generatedSQL, sqlQuery, nil)
+	})
+}
+
+// prepareSQL asks for the values of any {{name}} placeholders not in params,
+// blocks mutating statements unless write mode is on, asks for confirmation
+// when it is, and otherwise runs the SQL straight away
+func (m *QueryModel) prepareSQL(ctx context.Context, query, generatedSQL, sqlQuery string, params map[string]string) tea.Msg {
+	if names := postgres.ParseTemplate(sqlQuery).Params; !hasParamValues(params, names) {
+		return templateParamsMsg{query: query, generatedSQL: generatedSQL, sql: sqlQuery, names: names}
+	}
+
+	class := postgres.ClassifyStatement(sqlQuery)
+	if class.IsMutating() {
+		if m.cfg == nil || !m.cfg.SettingsFor(m.service.Name).WriteModeEnabled {
+			return queryExecutedMsg{err: fmt.Errorf("blocked %s statement in read-only mode (enable Write Mode in settings or this service's profile)\nSQL: %s", class, sqlQuery)}
+		}
+		return confirmWriteMsg{query: query, generatedSQL: generatedSQL, sql: sqlQuery, class: class, params: params}
+	}
+
+	return m.runSQL(ctx, query, generatedSQL, sqlQuery, class, params)
+}
+
+// runSQL executes SQL and fetches the initial batch of rows. When the
+// schema or PostgreSQL rejects generated read-only SQL, the engine is given
+// the error and the SQL for up to llm.MaxRepairAttempts corrected versions;
+// every failed attempt is kept in the message. SQL the user edited runs
+// once as-is.
+func (m *QueryModel) runSQL(ctx context.Context, query, generatedSQL, sqlQuery string, class postgres.StatementClass, params map[string]string) tea.Msg {
+	if sqlQuery != generatedSQL || class.IsMutating() || m.queryEngine == nil {
+		return m.executeLogged(ctx, query, generatedSQL, sqlQuery, class, params)
+	}
+	msg := m.executeGenerated(ctx, query, sqlQuery, class, params)
+
+	var failed []config.FailedSQL
+	for {
+		result, ok := msg.(queryExecutedMsg)
+		if !ok || result.err == nil {
+			break
+		}
+		cause, repairable := postgres.StatementError(result.err)
+		if !repairable {
+			break
+		}
+		failed = append(failed, config.FailedSQL{SQL: sqlQuery, Error: cause})
+		if len(failed) > llm.MaxRepairAttempts {
+			break
+		}
+		repaired, err := m.queryEngine.RepairSQL(ctx, query, m.getConversationContext(), failed)
+		if err != nil {
+			logging.Warn("sql repair failed", "service", m.service.Name, "attempt", len(failed), "error", err)
+			break
+		}
+		// A repair never turns a question into a write
+		if class = postgres.ClassifyStatement(repaired); class.IsMutating() {
+			logging.Warn("sql repair rejected", "service", m.service.Name, "attempt", len(failed), "class", class.String(), "sql", repaired)
+			break
+		}
+		logging.Info("sql repaired", "service", m.service.Name, "attempt", len(failed), "sql", repaired)
+		sqlQuery = repaired
+		msg = m.executeGenerated(ctx, query, repaired, class, params)
+	}
+
+	if result, ok := msg.(queryExecutedMsg); ok {
+		result.repairs = failed
+		return result
+	}
+	return msg
+}
+
+// executeGenerated runs generated SQL once its tables and columns are found
+// in the harvested schema, rather than leaving PostgreSQL to reject names
+// the model made up. SQL the check is unsure of runs anyway.
+func (m *QueryModel) executeGenerated(ctx context.Context, query, sqlQuery string, class postgres.StatementClass, params map[string]string) tea.Msg {
+	if err := m.queryEngine.CheckIdentifiers(sqlQuery); err != nil {
+		logging.Warn("sql names unknown identifiers", "service", m.service.Name, "sql", sqlQuery, "error", err)
+		var unknown *llm.UnknownIdentifiersError
+		if !errors.As(err, &unknown) || !unknown.Unsure() {
+			return queryExecutedMsg{err: fmt.Errorf("%w\nSQL: %s", err, sqlQuery)}
+		}
+	}
+	return m.executeLogged(ctx, query, sqlQuery, sqlQuery, class, params)
+}
+
+// dbSpanAttributes describes a statement on the service's database for a span
+func (m *QueryModel) dbSpanAttributes(sqlText string) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{tracing.DBSystem.String("postgresql"), tracing.DBQueryText.String(sqlText)}
+	if m.service != nil {
+		attrs = append(attrs, tracing.DBNamespace.String(m.service.DBName), tracing.ServerAddress.String(m.service.Host))
+		if port, err := strconv.Atoi(m.service.Port); err == nil {
+			attrs = append(attrs, tracing.ServerPort.Int(port))
+		}
+	}
+	return attrs
+}
+
+// executeLogged runs executeSQL within the service's statement timeout,
+// logging the statement with its timing and row count or error
+func (m *QueryModel) executeLogged(ctx context.Context, query, generatedSQL, sqlQuery string, class postgres.StatementClass, params map[string]string) tea.Msg {
+	start := time.Now()
+	timeout := m.statementTimeout()
+	msg := m.executeSQL(postgres.WithStatementTimeout(ctx, timeout), query, generatedSQL, sqlQuery, class, params)
+	result, ok := msg.(queryExecutedMsg)
+	if !ok {
+		return msg
+	}
+	if postgres.IsStatementTimeout(result.err) {
+		result.err = fmt.Errorf("query stopped after %s by the statement timeout: narrow it down with a filter or LIMIT, or raise Statement Timeout in settings", timeout)
+		msg = result
+	}
+
+	attrs := []any{"service", m.service.Name, "sql", sqlQuery, "edited", sqlQuery != generatedSQL,
+		"duration_ms", time.Since(start).Milliseconds()}
+	switch {
+	case result.err != nil && ctx.Err() != nil:
+		logging.Info("sql cancelled", attrs...)
+	case result.err != nil:
+		logging.Error("sql failed", append(attrs, "error", result.err)...)
+	case class.IsMutating():
+		logging.Info("sql executed", append(attrs, "class", class.String())...)
+	default:
+		logging.Info("sql executed", append(attrs, "rows", len(result.results.Rows), "total_rows", result.results.RowCount)...)
+	}
+	return msg
+}
+
+// statementTimeout returns how long a statement may run on the service
+// before PostgreSQL cancels it, 0 for no limit
+func (m *QueryModel) statementTimeout() time.Duration {
+	if m.cfg == nil || m.service == nil {
+		return 0
+	}
+	return m.cfg.SettingsFor(m.service.Name).StatementTimeout()
+}
+
+// executeSQL runs SQL once and fetches the initial batch of rows. sqlQuery
+// differs from generatedSQL when the user edited it. Mutating statements run
+// as-is, without the COUNT and LIMIT wrappers. params are bound to the
+// SQL's {{name}} placeholders as query parameters.
+func (m *QueryModel) executeSQL(ctx context.Context, query, generatedSQL, sqlQuery string, class postgres.StatementClass, params map[string]string) tea.Msg {
+	if statements := postgres.SplitStatements(sqlQuery); len(statements) > 1 {
+		return m.runScript(ctx, query, generatedSQL, sqlQuery, statements, class, params)
+	}
+
+	boundSQL, args, err := postgres.BindTemplate(sqlQuery, params)
+	if err != nil {
+		return queryExecutedMsg{err: err}
+	}
+
+	// Ensure connection is alive; a failed check flushes dead connections
+	// so the statement below runs on a fresh one
+	db := m.database()
+	if db == nil {
+		return queryExecutedMsg{err: fmt.Errorf("no database connection")}
+	}
+	if err := m.conn.Check(ctx); err != nil {
+		return queryExecutedMsg{err: fmt.Errorf("database unavailable: %w", err)}
+	}
 
-		// Validate query using EXPLAIN before executing
-		explainRows, err := m.db.Query("EXPLAIN " + sqlQuery)
+	// Validate query using EXPLAIN before executing; DDL and utility
+	// statements cannot be explained and go straight to running
+	if postgres.IsPlannable(boundSQL) {
+		explainCtx, span := tracing.Start(ctx, "explain", m.dbSpanAttributes(boundSQL)...)
+		explainRows, err := db.QueryContext(explainCtx, "EXPLAIN "+boundSQL, args...)
+		tracing.End(span, err)
 		if err != nil {
 			return queryExecutedMsg{err: fmt.Errorf("invalid query generated: %w\nSQL: %s", err, sqlQuery)}
 		}
 		explainRows.Close()
+	}
 
-		// First, get total count (wrapped in subquery)
-		countQuery := fmt.Sprintf("SELECT COUNT(*) FROM (%s) AS count_query", sqlQuery)
-		var totalCount int
-		_ = m.db.QueryRow(countQuery).Scan(&totalCount) // Ignore error, totalCount will be 0
-
-		// Add LIMIT to fetch initial batch
-		limitedQuery := fmt.Sprintf("%s LIMIT %d OFFSET 0", sqlQuery, m.fetchBatchSize)
-
-		startTime := time.Now()
-		rows, err := m.db.Query(limitedQuery)
-		if err != nil {
-			// If LIMIT fails, try the original query (might be a non-SELECT)
-			rows, err = m.db.Query(sqlQuery)
+	mutating := class.IsMutating()
+	editedSQL := ""
+	if sqlQuery != generatedSQL {
+		editedSQL = sqlQuery
+	}
+	var totalCount int
+	var estimated bool
+	var columns, columnTypes []string
+	var results [][]string
+	var nulls [][]bool
+	var cursor *postgres.ResultCursor
+	fetched := false
+	startTime := time.Now()
+	execCtx, execSpan := tracing.Start(ctx, "execute", m.dbSpanAttributes(boundSQL)...)
+	defer execSpan.End()
+	failed := func(err error) tea.Msg {
+		tracing.Fail(execSpan, err)
+		return queryExecutedMsg{err: err}
+	}
+	if !mutating {
+		// First, get the total count; failures leave it 0
+		countCtx, span := tracing.Start(execCtx, "count", m.dbSpanAttributes(boundSQL)...)
+		count, err := postgres.CountRows(countCtx, db, boundSQL, maxCountedRows, args...)
+		tracing.End(span, err)
+		totalCount, estimated = count.Rows, count.Estimated
+
+		// Stream through a server-side cursor so later batches continue
+		// exactly where this one stopped
+		startTime = time.Now()
+		if c, err := postgres.OpenCursor(execCtx, db, boundSQL, args...); err == nil {
+			batch, err := c.Fetch(execCtx, m.fetchBatchSize)
 			if err != nil {
-				return queryExecutedMsg{err: fmt.Errorf("query failed: %w", err)}
+				return failed(fmt.Errorf("query failed: %w", err))
 			}
+			columns = c.Columns()
+			columnTypes = c.ColumnTypes()
+			results = postgres.FormatRows(batch)
+			nulls = postgres.NullRows(batch)
+			fetched = true
+			if !c.Done() {
+				cursor = c
+			}
+		}
+	}
+
+	if !fetched {
+		// Mutating statements and ones DECLARE cannot wrap (e.g. SHOW) run
+		// directly, in a transaction bounding them by the statement timeout
+		// unless they cannot run in one (e.g. VACUUM). Reads are rolled back,
+		// so a temporary table they create does not outlive them.
+		var tx *sql.Tx
+		if postgres.RunsInTransaction(boundSQL) {
+			var err error
+			if tx, err = postgres.BeginTx(execCtx, db, nil); err != nil {
+				return failed(fmt.Errorf("query failed: %w", err))
+			}
+			defer tx.Rollback()
+		}
+		run := db.QueryContext
+		if tx != nil {
+			run = tx.QueryContext
+		}
+		rows, err := run(execCtx, boundSQL, args...)
+		if err != nil {
+			return failed(fmt.Errorf("query failed: %w", err))
 		}
 		defer rows.Close()
 
 		// Get column names
-		columns, err := rows.Columns()
+		columns, err = rows.Columns()
 		if err != nil {
-			return queryExecutedMsg{err: fmt.Errorf("failed to get columns: %w", err)}
+			return failed(fmt.Errorf("failed to get columns: %w", err))
 		}
+		columnTypes = postgres.ColumnTypeNames(rows)
 
 		// Read results
-		var results [][]string
 		for rows.Next() {
 			values := make([]interface{}, len(columns))
 			valuePtrs := make([]interface{}, len(columns))
@@ -663,104 +2026,100 @@ func (m *QueryModel) executeQuery(query string) tea.Cmd {
 				continue
 			}
 
-			row := make([]string, len(columns))
-			for i, val := range values {
-				row[i] = formatValue(val)
+			results = append(results, postgres.FormatRow(values))
+			nulls = append(nulls, postgres.NullCells(values))
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return failed(fmt.Errorf("query failed: %w", err))
+		}
+		if tx != nil && mutating {
+			if err := tx.Commit(); err != nil {
+				return failed(fmt.Errorf("query failed: %w", err))
 			}
-			results = append(results, row)
 		}
+	}
 
-		executionTime := time.Since(startTime).Seconds() * 1000
+	executionTime := time.Since(startTime).Seconds() * 1000
+	execSpan.SetAttributes(tracing.DBRows.Int(len(results)))
+	execSpan.End()
 
-		// Detect geometry column and render if present
-		geomColIdx := -1
-		var geomImage string
-		var geomPNGData string
-		if len(results) > 0 {
-			geomColIdx = DetectGeometryColumn(columns, results[0])
-			if geomColIdx >= 0 {
-				// Extract geometry values from all rows
-				var geomValues []string
-				for _, row := range results {
-					if geomColIdx < len(row) && row[geomColIdx] != "" && row[geomColIdx] != "NULL" {
-						geomValues = append(geomValues, row[geomColIdx])
-					}
-				}
-				// Render geometries to PNG (400x300 pixels)
-				if len(geomValues) > 0 {
-					geomPNGData, _ = RenderGeometriesToPNG(geomValues, 400, 300)
-					geomImage = Base64ToKittyGraphics(geomPNGData)
-				}
-			}
-		}
+	// Detect geometry columns
+	geomColIdx := -1
+	var geomCols []int
+	if len(results) > 0 {
+		geomColIdx = DetectGeometryColumn(columns, results[0])
+		geomCols = DetectGeometryColumns(columns, results[0])
+	}
 
-		return queryExecutedMsg{
-			results: &QueryResults{
-				Columns:         columns,
-				Rows:            results,
-				RowCount:        totalCount, // Report total count if known
-				ExecutionTime:   executionTime,
-				GeneratedSQL:    sqlQuery, // Store original SQL (without LIMIT)
-				NaturalQuery:    query,
-				GeometryColIdx:  geomColIdx,
-				GeometryImage:   geomImage,
-				GeometryPNGData: geomPNGData,
-			},
-		}
+	queryResults := &QueryResults{
+		Columns:         columns,
+		ColumnTypes:     columnTypes,
+		Rows:            results,
+		Nulls:           nulls,
+		RowCount:        totalCount, // Report total count if known
+		RowsEstimated:   estimated,
+		ExecutionTime:   executionTime,
+		GeneratedSQL:    generatedSQL, // Store original SQL (without LIMIT)
+		EditedSQL:       editedSQL,
+		NaturalQuery:    query,
+		GeometryColIdx:  geomColIdx,
+		GeometryColumns: geomCols,
+		Mutating:        mutating,
+		Params:          params,
+		cursor:          cursor,
 	}
+	// Render the detected geometry column if present
+	_, renderSpan := tracing.Start(ctx, "render_geometry")
+	queryResults.renderGeometry()
+	renderSpan.End()
+
+	return queryExecutedMsg{results: queryResults}
 }
 
-// fetchMoreRows fetches additional rows for endless scroll
+// fetchMoreRows fetches the next batch from the latest result's cursor
 func (m *QueryModel) fetchMoreRows() tea.Cmd {
+	results := m.results
+	batchSize := m.fetchBatchSize
 	return func() tea.Msg {
-		if m.db == nil || m.currentSQL == "" || !m.hasMoreRows {
+		if results == nil || !results.HasMoreRows() {
 			return moreRowsFetchedMsg{rows: nil, hasMore: false}
 		}
 
-		// Fetch next batch with OFFSET
-		limitedQuery := fmt.Sprintf("%s LIMIT %d OFFSET %d", m.currentSQL, m.fetchBatchSize, m.totalFetched)
-
-		rows, err := m.db.Query(limitedQuery)
+		batch, err := results.cursor.Fetch(context.Background(), batchSize)
 		if err != nil {
 			return moreRowsFetchedMsg{err: err}
 		}
-		defer rows.Close()
-
-		// Get column count from existing results
-		colCount := 0
-		if m.results != nil {
-			colCount = len(m.results.Columns)
-		}
-
-		var results [][]string
-		for rows.Next() {
-			values := make([]interface{}, colCount)
-			valuePtrs := make([]interface{}, colCount)
-			for i := range values {
-				valuePtrs[i] = &values[i]
-			}
-
-			if err := rows.Scan(valuePtrs...); err != nil {
-				continue
-			}
-
-			row := make([]string, colCount)
-			for i, val := range values {
-				row[i] = formatValue(val)
-			}
-			results = append(results, row)
-		}
-
-		// Determine if there are more rows
-		hasMore := len(results) == m.fetchBatchSize
 
 		return moreRowsFetchedMsg{
-			rows:    results,
-			hasMore: hasMore,
+			rows:    postgres.FormatRows(batch),
+			nulls:   postgres.NullRows(batch),
+			hasMore: results.HasMoreRows(),
 		}
 	}
 }
 
+// exportTarget returns the results of the selected conversation entry,
+// falling back to the latest results. Mutating statements are never
+// exported since that would run them again.
+func (m *QueryModel) exportTarget() *QueryResults {
+	results := m.results
+	if m.selectedEntry >= 0 && m.selectedEntry < len(m.history) && m.history[m.selectedEntry].Results != nil {
+		results = m.history[m.selectedEntry].Results
+	}
+	if results != nil && results.Mutating {
+		return nil
+	}
+	return results
+}
+
+// canExportGeoJSON reports whether the export target has a geometry column,
+// as GeoJSON and GeoPackage exports need
+func (m *QueryModel) canExportGeoJSON() bool {
+	results := m.exportTarget()
+	return results != nil && results.GeometryColIdx >= 0
+}
+
 func (m *QueryModel) getConversationContext() string {
 	if len(m.history) == 0 {
 		return ""
@@ -781,7 +2140,11 @@ func (m *QueryModel) getConversationContext() string {
 			context.WriteString(fmt.Sprintf("SQL: %s\n", entry.SQL))
 		}
 		if entry.Results != nil && entry.Results.RowCount > 0 {
-			context.WriteString(fmt.Sprintf("(Returned %d rows)\n", entry.Results.RowCount))
+			if entry.Results.RowsEstimated {
+				context.WriteString(fmt.Sprintf("(Returned about %d rows)\n", entry.Results.RowCount))
+			} else {
+				context.WriteString(fmt.Sprintf("(Returned %d rows)\n", entry.Results.RowCount))
+			}
 		}
 		context.WriteString("\n")
 	}
@@ -794,14 +2157,85 @@ func (m *QueryModel) View() string {
 	if m.width == 0 || m.height == 0 {
 		return ""
 	}
+	if m.renderSpan != nil {
+		question := m.renderSpan
+		m.renderSpan = nil
+		_, span := tracing.Start(trace.ContextWithSpan(context.Background(), question), "render")
+		defer question.End()
+		defer span.End()
+	}
+
+	if m.mapView != nil {
+		return m.mapView.View()
+	}
+	if m.planView != nil {
+		return m.planView.View()
+	}
+	if m.rowDetail != nil {
+		return m.rowDetail.View()
+	}
+	if m.diffView != nil {
+		return m.diffView.View()
+	}
+	if m.pager != nil {
+		return m.pager.View()
+	}
 
-	header := RenderHeader("Query")
+	title := "Query"
+	if len(m.sessions) > 1 {
+		title += " • " + m.sessionName()
+	}
+	header := RenderHeader(title)
 	content := m.renderContent()
 	var helpText string
 	if m.focusEditor {
-		helpText = "ctrl+s: run • Esc: browse results • ctrl+g: SQL • ctrl+h: history • F1: menu"
+		helpText = "ctrl+s: run • ctrl+o: edit SQL first • ctrl+x: ask on several services • Tab: complete name • ctrl+y: use suggestion • ctrl+z/r: undo/redo • Esc: browse results • ctrl+g: SQL • ctrl+e: export • ctrl+t: new session • ctrl+h: history • F2: engine • F1: menu"
+		if m.sqlEdit != nil {
+			helpText = "ctrl+s: run edited SQL • ctrl+x: run on several services • ctrl+o: discard SQL • Esc: browse results • F1: menu"
+		}
 	} else {
-		helpText = "i/Enter: edit • j/k: scroll • Tab: select • ctrl+g: SQL • mouse: scroll • F1: menu"
+		helpText = "i/Enter: edit • j/k: scroll • Tab: select • / ?: search • n: more rows • ←/→: columns • f: freeze column • c: sort/hide column • o: pager • r: inspect row • l: geometry column • t: colour by value • m: map • v: chart • p: pivot • D: diff • S: snapshot • x: explain • d: describe SQL • e: edit SQL • y/Y: copy SQL/TSV • ctrl+g: SQL • ctrl+e: export • ctrl+t/n/p: sessions • ctrl+w: close session • F1: menu"
+	}
+	if m.pendingWrite != nil {
+		helpText = "y: execute statement • any other key: cancel"
+	} else if m.paramPrompt != nil {
+		helpText = "Enter: next value • ctrl+u: clear • Esc: cancel query"
+	} else if m.schemaPrompt != nil {
+		helpText = "←/→ or 1-9: choose schema • Enter: use for this session • d: make service default • Esc: cancel query"
+	} else if m.servicePicker != nil {
+		helpText = "←/→: move • Space: tick service • a: all/none • Enter: ask on the services ticked • Esc: cancel"
+	} else if m.sessionPrompt != nil {
+		helpText = "Enter: create session • Esc: cancel"
+	} else if m.snapshotPrompt != nil {
+		helpText = "Enter: save the selected entry's rows • ctrl+d: diff a fresh run against the snapshot • Tab: complete • Esc: cancel"
+	} else if m.search != nil && m.search.prompt != nil {
+		helpText = "Enter: search questions, SQL and result cells • Esc: cancel"
+	} else if m.search != nil && !m.focusEditor {
+		helpText = "n/N: next/previous match • / ?: search again • Tab: select • i/Enter: edit • Esc: end search • F1: menu"
+	} else if m.diffFrom != nil {
+		helpText = "Tab/shift+Tab: select the entry to compare with • D: compare • Esc: cancel"
+	} else if m.rowSelect != nil {
+		helpText = "j/k: move • Enter: inspect row • Esc: done"
+	} else if m.colSelect != nil {
+		helpText = "←/→: choose column • s: sort (again to reverse) • h: hide • u: show all • r: readable sizes/durations • Esc: done"
+	} else if m.exportPicker {
+		helpText = ""
+		if m.exportTarget() != nil {
+			helpText = "c: CSV • j: JSON • "
+			if m.canExportGeoJSON() {
+				helpText += "g: GeoJSON • p: GeoPackage • "
+			}
+		}
+		helpText += "m: Markdown report • h: HTML report • any other key: cancel"
+	} else if m.pivot != nil {
+		switch m.pivot.step {
+		case pivotPickGroup:
+			helpText = "←/→: choose group-by column • Enter: select • Esc: cancel"
+		case pivotPickAggregate:
+			helpText = "c: count • s: sum • a: avg • Esc: cancel"
+		default:
+			helpText = "←/→: choose column • Enter: run pivot • Esc: cancel"
+		}
 	}
 	footer := RenderHelpFooter(helpText, m.width)
 
@@ -813,20 +2247,30 @@ func (m *QueryModel) renderContent() string {
 
 	// Conversation area (takes most of the screen)
 	conversationHeight := m.height - 20 // Leave room for header, prompt, footer
+	if tabs := m.renderSessionTabs(); tabs != "" {
+		sections = append(sections, tabs)
+		conversationHeight--
+	}
+	sqlPreview := m.renderSQLPreview()
+	if sqlPreview != "" {
+		conversationHeight -= lipgloss.Height(sqlPreview)
+	}
 	if conversationHeight < 10 {
 		conversationHeight = 10
 	}
 
-	if m.loading {
+	if m.pendingWrite != nil {
+		// Confirmation modal for mutating statements
+		if len(m.history) > 0 {
+			sections = append(sections, m.renderConversation(conversationHeight-8))
+		}
+		sections = append(sections, m.renderWriteConfirm())
+	} else if m.loading {
 		// Show conversation with loading indicator at the bottom
 		if len(m.history) > 0 {
 			sections = append(sections, m.renderConversation(conversationHeight-3))
 		}
-		loadingContent := lipgloss.NewStyle().
-			Width(m.width - 10).
-			Align(lipgloss.Center).
-			Render(m.spinner.View() + " Generating and executing query...")
-		sections = append(sections, loadingContent)
+		sections = append(sections, m.renderRunning())
 	} else if len(m.history) > 0 {
 		// Render scrollable conversation
 		sections = append(sections, m.renderConversation(conversationHeight))
@@ -838,12 +2282,50 @@ func (m *QueryModel) renderContent() string {
 
 	// Prompt area
 	sections = append(sections, "")
+	if m.paramPrompt != nil {
+		sections = append(sections, m.renderParamPrompt())
+	} else if m.schemaPrompt != nil {
+		sections = append(sections, m.renderSchemaPrompt())
+	} else if m.servicePicker != nil {
+		sections = append(sections, m.renderServicePicker())
+	} else if m.sessionPrompt != nil {
+		sections = append(sections, PromptStyle.Render("🗂  New session name: ")+*m.sessionPrompt+"█")
+	} else if m.snapshotPrompt != nil {
+		sections = append(sections, m.renderSnapshotPrompt())
+	} else if m.search != nil && m.search.prompt != nil {
+		sections = append(sections, m.renderSearchPrompt())
+	} else if m.pivot != nil {
+		sections = append(sections, m.renderPivotPicker())
+	} else if m.colSelect != nil {
+		sections = append(sections, m.renderColumnPicker())
+	} else if m.exportPicker {
+		options := ""
+		if m.exportTarget() != nil {
+			options = "[c]sv  [j]son  "
+			if m.canExportGeoJSON() {
+				options += "[g]eojson  geo[p]ackage  "
+			}
+		}
+		options += "[m]arkdown report  [h]tml report"
+		sections = append(sections, PromptStyle.Render("💾 Export as: "+options))
+	} else if m.statusMsg != "" {
+		statusStyle := SuccessStyle
+		if strings.HasPrefix(m.statusMsg, "✗") {
+			statusStyle = ErrorStyle
+		}
+		sections = append(sections, statusStyle.Render(m.statusMsg))
+	}
 	var promptLabel string
-	if m.focusEditor {
+	if m.sqlEdit != nil {
+		promptLabel = PromptStyle.Render("✏️  Edit SQL for: " + truncate(m.sqlEdit.query, m.width-30))
+	} else if m.focusEditor {
 		promptLabel = PromptStyle.Render("🔮 Ask your database (editing):")
 	} else {
 		promptLabel = lipgloss.NewStyle().Foreground(ColorGray).Render("🔮 Ask your database (press i to edit):")
 	}
+	if m.sqlEdit == nil {
+		promptLabel += lipgloss.NewStyle().Foreground(ColorCyan).Render(" [" + m.queryEngine.GeneratorLabel(m.generator) + "]")
+	}
 	sections = append(sections, promptLabel)
 
 	// Determine border color based on focus
@@ -875,17 +2357,140 @@ func (m *QueryModel) renderContent() string {
 		}
 		sections = append(sections, m.textArea.View())
 	}
+	if completions := m.renderCompletions(); completions != "" {
+		sections = append(sections, completions)
+	} else if typeAhead := m.renderTypeAhead(); typeAhead != "" {
+		sections = append(sections, typeAhead)
+	}
+	if sqlPreview != "" {
+		sections = append(sections, sqlPreview)
+	}
 
 	return lipgloss.JoinVertical(lipgloss.Center, sections...)
 }
 
-// renderConversation renders the scrollable conversation view with all query/result pairs
+// renderSQLPreview renders the SQL being edited with syntax highlighting,
+// or nothing when the editor holds a question
+func (m *QueryModel) renderSQLPreview() string {
+	if m.sqlEdit == nil {
+		return ""
+	}
+	sql := strings.TrimSpace(m.getEditorText())
+	if sql == "" {
+		return ""
+	}
+	lines := strings.Split(sql, "\n")
+	if len(lines) > sqlPreviewLines {
+		lines = append(lines[:sqlPreviewLines], "…")
+	}
+	return lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(ColorDarkGray).
+		Width(m.width - 6).
+		Padding(0, 1).
+		Render(highlightSQL(strings.Join(lines, "\n")))
+}
+
+// renderWriteConfirm renders the confirmation box for a pending mutating statement
+func (m *QueryModel) renderWriteConfirm() string {
+	titleStyle := lipgloss.NewStyle().Foreground(ColorRed).Bold(true)
+
+	var lines []string
+	lines = append(lines, titleStyle.Render(fmt.Sprintf("⚠ This %s statement will modify the database", m.pendingWrite.class)))
+	lines = append(lines, "")
+	lines = append(lines, highlightSQL(m.pendingWrite.sql))
+	lines = append(lines, "")
+	lines = append(lines, lipgloss.NewStyle().Foreground(ColorGray).Render("Press y to execute, any other key to cancel"))
+
+	return lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(ColorRed).
+		Padding(0, 2).
+		Width(m.width - 10).
+		Render(lipgloss.JoinVertical(lipgloss.Left, lines...))
+}
+
+// renderConversation renders the scrollable conversation view with all
+// query/result pairs. Entries are rendered once and reused until they
+// change (see renderedEntry), and only the lines in view are put together.
 func (m *QueryModel) renderConversation(height int) string {
 	if len(m.history) == 0 {
 		return ""
 	}
 
-	var lines []string
+	// Lay out the entries, finding the line of the current search match
+	entries := m.renderedEntries()
+	totalLines, searchLine := 0, -1
+	for i, entry := range entries {
+		if line := m.searchLine(i, entry); line >= 0 {
+			searchLine = totalLines + line
+		}
+		totalLines += len(entry.lines)
+	}
+	visibleLines := height
+
+	// Calculate scroll position (auto-scroll to show latest entry)
+	m.convScroll.totalLines = totalLines
+	m.convScroll.visibleLines = visibleLines
+
+	// Bring a match just searched for into view
+	if searchLine >= 0 && m.search.scroll {
+		m.scrollToLine(searchLine)
+		m.search.scroll = false
+	}
+
+	// Default: show from bottom (latest entries)
+	startLine := totalLines - visibleLines
+	if startLine < 0 {
+		startLine = 0
+	}
+
+	// Apply scroll offset
+	startLine -= m.convScroll.scrollOffset
+	if startLine < 0 {
+		startLine = 0
+	}
+
+	endLine := startLine + visibleLines
+	if endLine > totalLines {
+		endLine = totalLines
+	}
+
+	// Copy out the lines in view, leaving the rendered entries untouched
+	shown := make([]string, 0, endLine-startLine)
+	at := 0
+	for _, entry := range entries {
+		if at < endLine && at+len(entry.lines) > startLine {
+			shown = append(shown, entry.lines[max(startLine-at, 0):min(endLine-at, len(entry.lines))]...)
+		}
+		at += len(entry.lines)
+	}
+	currentLine := -1
+	if searchLine >= 0 {
+		currentLine = searchLine - startLine
+	}
+	m.highlightMatches(shown, currentLine)
+	visibleContent := strings.Join(shown, "\n")
+
+	// Add scroll indicator if content overflows
+	if totalLines > visibleLines {
+		scrollIndicator := lipgloss.NewStyle().
+			Foreground(ColorGray).
+			Italic(true)
+
+		scrollInfo := fmt.Sprintf(" ↑↓ scroll • Showing lines %d-%d of %d", startLine+1, endLine, totalLines)
+		visibleContent += "\n" + scrollIndicator.Render(scrollInfo)
+	}
+
+	return visibleContent
+}
+
+// renderEntry renders conversation entry i as text, each element possibly
+// several lines, and where in it the parts a search can match start
+func (m *QueryModel) renderEntry(i int) (lines []string, marks map[searchField]int) {
+	entry := m.history[i]
+	isSelected := i == m.selectedEntry
+	marks = make(map[searchField]int)
 
 	// Styles
 	userQueryStyle := lipgloss.NewStyle().
@@ -900,7 +2505,24 @@ func (m *QueryModel) renderConversation(height int) string {
 		Width(min(80, m.width-10)).
 		Padding(0, 1)
 
-	sqlStyle := lipgloss.NewStyle().Foreground(ColorCyan)
+
+	failedSQLBoxStyle := BoxStyle.Copy().
+		BorderForeground(ColorRed).
+		Width(min(80, m.width-10)).
+		Padding(0, 1)
+
+	failedSQLErrorStyle := lipgloss.NewStyle().
+		Foreground(ColorRed).
+		Width(min(80, m.width-10)).
+		Padding(0, 2)
+
+	explanationStyle := lipgloss.NewStyle().
+		Foreground(ColorWhite).
+		Width(min(80, m.width-10)).
+		Padding(0, 2)
+
+	// SQL is laid out to fit within the padding of its box
+	sqlWidth := min(80, m.width-10) - 2
 
 	errorStyle := lipgloss.NewStyle().
 		Foreground(ColorRed).
@@ -914,134 +2536,181 @@ func (m *QueryModel) renderConversation(height int) string {
 		Foreground(ColorGray).
 		Italic(true)
 
-	// Render each conversation entry
-	for i, entry := range m.history {
-		isSelected := i == m.selectedEntry
+	// Entry separator
+	if i > 0 {
+		separator := lipgloss.NewStyle().
+			Foreground(ColorDarkGray).
+			Render(strings.Repeat("─", min(60, m.width-20)))
+		lines = append(lines, separator)
+		lines = append(lines, "")
+	}
 
-		// Entry separator
-		if i > 0 {
-			separator := lipgloss.NewStyle().
-				Foreground(ColorDarkGray).
-				Render(strings.Repeat("─", min(60, m.width-20)))
-			lines = append(lines, separator)
-			lines = append(lines, "")
-		}
+	// User query with selection indicator
+	var queryPrefix string
+	if isSelected {
+		queryPrefix = selectedIndicator.Render("▶ ") + userQueryLabelStyle.Render("You: ")
+	} else {
+		queryPrefix = "  " + userQueryLabelStyle.Render("You: ")
+	}
+	marks[searchQuestion] = len(lines)
+	question := queryPrefix + userQueryStyle.Render(entry.Query)
+	if entry.Service != "" {
+		question += lipgloss.NewStyle().Foreground(ColorBlue).Bold(true).Render("  @ " + entry.Service)
+	}
+	if entry.Unseen {
+		question += lipgloss.NewStyle().Foreground(ColorGreen).Bold(true).Render("  ● new")
+	}
+	lines = append(lines, question)
 
-		// User query with selection indicator
-		var queryPrefix string
-		if isSelected {
-			queryPrefix = selectedIndicator.Render("▶ ") + userQueryLabelStyle.Render("You: ")
-		} else {
-			queryPrefix = "  " + userQueryLabelStyle.Render("You: ")
+	if n := len(entry.Repairs); n > 0 {
+		attempts := "1 failed attempt"
+		if n > 1 {
+			attempts = fmt.Sprintf("%d failed attempts", n)
 		}
-		lines = append(lines, queryPrefix+userQueryStyle.Render(entry.Query))
-
-		// Show SQL toggle button hint for selected entry
-		if isSelected && entry.SQL != "" {
-			var toggleText string
-			if entry.ShowSQL {
-				toggleText = toggleHintStyle.Render("  [ctrl+g: hide SQL]")
-			} else {
-				toggleText = toggleHintStyle.Render("  [ctrl+g: show SQL]")
-			}
-			lines = append(lines, toggleText)
+		note := "  ↻ SQL repaired after " + attempts
+		if entry.Error != "" {
+			note = "  ↻ Gave up repairing the SQL after " + attempts
 		}
+		lines = append(lines, toggleHintStyle.Render(note))
+	}
 
-		// SQL (if toggled on)
-		if entry.ShowSQL && entry.SQL != "" {
-			lines = append(lines, "")
-			sqlLabel := lipgloss.NewStyle().
-				Foreground(ColorCyan).
-				Bold(true).
-				Render("  SQL:")
-			lines = append(lines, sqlLabel)
-			lines = append(lines, sqlBoxStyle.Render(sqlStyle.Render(entry.SQL)))
+	// Show SQL toggle button hint for selected entry
+	if isSelected && (entry.SQL != "" || len(entry.Repairs) > 0) {
+		var toggleText string
+		if entry.ShowSQL {
+			toggleText = toggleHintStyle.Render("  [ctrl+g: hide SQL]")
+		} else {
+			toggleText = toggleHintStyle.Render("  [ctrl+g: show SQL]")
 		}
+		lines = append(lines, toggleText)
+	}
 
-		// Error (if any)
-		if entry.Error != "" {
+	// Failed attempts the SQL was repaired from (if toggled on)
+	if entry.ShowSQL {
+		for i, f := range entry.Repairs {
 			lines = append(lines, "")
-			lines = append(lines, "  "+errorStyle.Render("Error: "+entry.Error))
+			lines = append(lines, errorStyle.Render(fmt.Sprintf("  Failed attempt %d:", i+1)))
+			lines = append(lines, failedSQLBoxStyle.Render(highlightSQL(postgres.FormatSQL(f.SQL, sqlWidth))))
+			lines = append(lines, failedSQLErrorStyle.Render(f.Error))
 		}
+	}
 
-		// Results
-		if entry.Results != nil {
-			lines = append(lines, "")
-
-			// Geometry image if available
-			if entry.Results.GeometryImage != "" {
-				geomLabel := lipgloss.NewStyle().
-					Foreground(ColorOrange).
-					Bold(true).
-					Render("  🗺️  Geometry Preview:")
-				lines = append(lines, geomLabel)
-				lines = append(lines, entry.Results.GeometryImage)
-				lines = append(lines, "")
-			}
-
-			// Results table
-			if len(entry.Results.Rows) > 0 {
-				tableLines := m.renderEntryTable(entry.Results, i == len(m.history)-1)
-				lines = append(lines, tableLines...)
-			} else {
-				noResults := lipgloss.NewStyle().
-					Foreground(ColorGray).
-					Italic(true).
-					Render("  No results returned")
-				lines = append(lines, noResults)
-			}
-
-			// Stats line
-			statsStyle := lipgloss.NewStyle().Foreground(ColorGray).Italic(true)
-			statLine := fmt.Sprintf("  %d rows • %.2fms", entry.Results.RowCount, entry.Results.ExecutionTime)
-			lines = append(lines, statsStyle.Render(statLine))
+	// SQL (if toggled on)
+	if entry.ShowSQL && entry.SQL != "" {
+		lines = append(lines, "")
+		sqlLabel := lipgloss.NewStyle().
+			Foreground(ColorCyan).
+			Bold(true).
+			Render("  SQL:")
+		lines = append(lines, sqlLabel)
+		marks[searchSQL] = len(lines)
+		lines = append(lines, sqlBoxStyle.Render(highlightSQL(postgres.FormatSQL(entry.SQL, sqlWidth))))
+		if entry.EditedSQL != "" {
+			editedLabel := lipgloss.NewStyle().
+				Foreground(ColorOrange).
+				Bold(true).
+				Render("  Edited SQL (executed):")
+			lines = append(lines, editedLabel)
+			marks[searchEditedSQL] = len(lines)
+			lines = append(lines, sqlBoxStyle.Render(highlightSQL(postgres.FormatSQL(entry.EditedSQL, sqlWidth))))
+		}
+		if entry.Explanation != "" {
+			explanationLabel := lipgloss.NewStyle().
+				Foreground(ColorGreen).
+				Bold(true).
+				Render("  Explanation:")
+			lines = append(lines, explanationLabel)
+			lines = append(lines, explanationStyle.Render(entry.Explanation))
 		}
+	}
 
+	// Error (if any)
+	if entry.Error != "" {
 		lines = append(lines, "")
+		lines = append(lines, "  "+errorStyle.Render("Error: "+entry.Error))
 	}
 
-	// Join all lines
-	content := strings.Join(lines, "\n")
+	// Results
+	if entry.Results != nil {
+		lines = append(lines, "")
 
-	// Create a scrollable viewport
-	contentLines := strings.Split(content, "\n")
-	totalLines := len(contentLines)
-	visibleLines := height
+		// Geometry image if available
+		if entry.Results.GeometryImage != "" && !plainOutput {
+			geomLabel := lipgloss.NewStyle().
+				Foreground(ColorOrange).
+				Bold(true).
+				Render("  🗺️  Geometry Preview:")
+			if len(entry.Results.GeometryColumns) > 1 {
+				geomLabel += " " + renderGeometryPicker(entry.Results)
+			}
+			if entry.Results.choropleth > 0 {
+				geomLabel += "  " + renderChoroplethPicker(entry.Results)
+			}
+			lines = append(lines, geomLabel)
+			lines = append(lines, entry.Results.GeometryImage)
+			lines = append(lines, "")
+		}
 
-	// Calculate scroll position (auto-scroll to show latest entry)
-	m.convScroll.totalLines = totalLines
-	m.convScroll.visibleLines = visibleLines
+		// Statements of a script
+		lines = append(lines, m.renderScriptSteps(entry.Results)...)
 
-	// Default: show from bottom (latest entries)
-	startLine := totalLines - visibleLines
-	if startLine < 0 {
-		startLine = 0
-	}
+		// Chart if toggled on
+		lines = append(lines, m.renderEntryChart(entry.Results)...)
 
-	// Apply scroll offset
-	startLine -= m.convScroll.scrollOffset
-	if startLine < 0 {
-		startLine = 0
-	}
+		// Results table
+		if len(entry.Results.Rows) > 0 {
+			marks[searchCell] = len(lines)
+			lines = append(lines, m.renderEntryTable(entry.Results, i == len(m.history)-1)...)
+		} else {
+			noResults := lipgloss.NewStyle().
+				Foreground(ColorGray).
+				Italic(true).
+				Render("  No results returned")
+			lines = append(lines, noResults)
+			if warning := m.rowSecurityWarning(entry.Results); warning != "" {
+				lines = append(lines, lipgloss.NewStyle().Foreground(ColorOrange).Render("  "+warning))
+			}
+		}
 
-	endLine := startLine + visibleLines
-	if endLine > totalLines {
-		endLine = totalLines
+		// Stats line
+		statsStyle := lipgloss.NewStyle().Foreground(ColorGray).Italic(true)
+		statLine := fmt.Sprintf("  %d rows • %.2fms", entry.Results.RowCount, entry.Results.ExecutionTime)
+		if entry.Results.RowsEstimated {
+			statLine = fmt.Sprintf("  ~%s rows (estimated) • %.2fms", config.HumanCount(int64(entry.Results.RowCount)), entry.Results.ExecutionTime)
+		}
+		lines = append(lines, statsStyle.Render(statLine))
 	}
 
-	visibleContent := strings.Join(contentLines[startLine:endLine], "\n")
+	lines = append(lines, "")
 
-	// Add scroll indicator if content overflows
-	if totalLines > visibleLines {
-		scrollIndicator := lipgloss.NewStyle().
-			Foreground(ColorGray).
-			Italic(true)
+	return lines, marks
+}
 
-		scrollInfo := fmt.Sprintf(" ↑↓ scroll • Showing lines %d-%d of %d", startLine+1, endLine, totalLines)
-		visibleContent += "\n" + scrollIndicator.Render(scrollInfo)
+// renderGeometryPicker lists the geometry columns of a result, highlighting the
+// one being previewed. When overlaid each column is shown in its layer colour.
+func renderGeometryPicker(results *QueryResults) string {
+	var parts []string
+	for i, col := range results.GeometryColumns {
+		name := "?"
+		if col < len(results.Columns) {
+			name = results.Columns[col]
+		}
+
+		style := lipgloss.NewStyle().Foreground(ColorGray)
+		if results.GeometryOverlay {
+			c := layerColors[i%len(layerColors)]
+			style = lipgloss.NewStyle().Foreground(lipgloss.Color(fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B))).Bold(true)
+		} else if col == results.GeometryColIdx {
+			style = lipgloss.NewStyle().Foreground(ColorOrange).Bold(true)
+		}
+		parts = append(parts, style.Render(name))
 	}
 
-	return visibleContent
+	mode := "l: next column"
+	if results.GeometryOverlay {
+		mode = "overlay • l: next column"
+	}
+	return strings.Join(parts, " | ") + lipgloss.NewStyle().Foreground(ColorGray).Render("  ("+mode+")")
 }
 
 // renderEntryTable renders a table for a single conversation entry's results
@@ -1050,26 +2719,9 @@ func (m *QueryModel) renderEntryTable(results *QueryResults, isLatest bool) []st
 		return nil
 	}
 
-	// Calculate column widths
-	colWidths := make([]int, len(results.Columns))
-	for i, col := range results.Columns {
-		colWidths[i] = len(col)
-	}
-	for _, row := range results.Rows {
-		for i, cell := range row {
-			if i < len(colWidths) && len(cell) > colWidths[i] {
-				colWidths[i] = len(cell)
-			}
-		}
-	}
-
-	// Limit column widths
-	maxColWidth := 25
-	for i := range colWidths {
-		if colWidths[i] > maxColWidth {
-			colWidths[i] = maxColWidth
-		}
-	}
+	// Column widths, and the window of columns that fits the screen
+	colWidths := columnWidths(results)
+	visible, hiddenRight := m.visibleColumns(results, colWidths)
 
 	var lines []string
 
@@ -1078,47 +2730,70 @@ func (m *QueryModel) renderEntryTable(results *QueryResults, isLatest bool) []st
 		Bold(true).
 		Foreground(ColorOrange)
 
+	selectedHeaderStyle := headerStyle.Reverse(true)
+	selectedCol := m.selectedColumn(results)
+
 	var headerCells []string
-	for i, col := range results.Columns {
-		cell := padOrTruncate(col, colWidths[i])
-		headerCells = append(headerCells, headerStyle.Render(cell))
+	for _, i := range visible {
+		cell := padOrTruncate(results.Columns[i], colWidths[i])
+		if i == selectedCol && plainOutput {
+			// Reverse video does not survive plain output
+			cell = padOrTruncate("*"+results.Columns[i], colWidths[i])
+		}
+		if i == selectedCol {
+			headerCells = append(headerCells, selectedHeaderStyle.Render(cell))
+		} else {
+			headerCells = append(headerCells, headerStyle.Render(cell))
+		}
 	}
 	lines = append(lines, "  "+strings.Join(headerCells, " │ "))
 
 	// Separator
 	var sepParts []string
-	for _, w := range colWidths {
-		sepParts = append(sepParts, strings.Repeat("─", w))
+	for _, i := range visible {
+		sepParts = append(sepParts, strings.Repeat("─", colWidths[i]))
 	}
 	sepStyle := lipgloss.NewStyle().Foreground(ColorGray)
 	lines = append(lines, "  "+sepStyle.Render(strings.Join(sepParts, "─┼─")))
 
 	// Show limited rows for non-latest entries, more for latest
-	maxRows := 5
-	if isLatest {
-		maxRows = m.visibleRows
-	}
-
-	// Rows
 	rowStyle := lipgloss.NewStyle().Foreground(ColorWhite)
+	selectedStyle := rowStyle.Background(ColorDarkGray)
+	selectedIndicatorStyle := lipgloss.NewStyle().Foreground(ColorOrange).Bold(true)
 	rowCount := len(results.Rows)
-	displayRows := min(rowCount, maxRows)
+	displayRows := m.displayedRows(results, isLatest)
+	selectedRow := m.selectedRow(results)
 
 	for i := 0; i < displayRows; i++ {
-		row := results.Rows[i]
+		style := rowStyle
+		if i == selectedRow {
+			style = selectedStyle
+		}
 		var cells []string
-		for j, cell := range row {
-			if j < len(colWidths) {
-				cells = append(cells, padOrTruncate(cell, colWidths[j]))
-			}
+		for _, j := range visible {
+			cell := alignCell(results.displayValue(i, j), colWidths[j], results.rightAligned(j))
+			cells = append(cells, cellStyle(results, i, j, style).Render(cell))
+		}
+		if i == selectedRow {
+			lines = append(lines, selectedIndicatorStyle.Render("▶ ")+strings.Join(cells, style.Render(" │ ")))
+		} else {
+			lines = append(lines, "  "+strings.Join(cells, style.Render(" │ ")))
 		}
-		lines = append(lines, "  "+rowStyle.Render(strings.Join(cells, " │ ")))
 	}
 
 	// Show truncation indicator if rows were hidden
+	moreStyle := lipgloss.NewStyle().Foreground(ColorGray).Italic(true)
+	loadHint := ""
+	if isLatest {
+		loadHint = " (n: load more)"
+	}
 	if rowCount > displayRows {
-		moreStyle := lipgloss.NewStyle().Foreground(ColorGray).Italic(true)
-		lines = append(lines, "  "+moreStyle.Render(fmt.Sprintf("... and %d more rows", rowCount-displayRows)))
+		lines = append(lines, "  "+moreStyle.Render(fmt.Sprintf("... and %d more rows%s", rowCount-displayRows, loadHint)))
+	} else if isLatest && results.HasMoreRows() {
+		lines = append(lines, "  "+moreStyle.Render("... more rows available"+loadHint))
+	}
+	if hint := columnScrollHint(results, visible, m.frozenColumns(results), hiddenRight); hint != "" {
+		lines = append(lines, "  "+moreStyle.Render(hint))
 	}
 
 	return lines
@@ -1159,7 +2834,7 @@ func (m *QueryModel) renderVimStatusBar() string {
 
 	// Mode section
 	modeStyle := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("#000000")).
+		Foreground(ColorOnAccent).
 		Background(modeColor).
 		Bold(true).
 		Padding(0, 1)
@@ -1225,6 +2900,26 @@ func (m *QueryModel) renderWelcome(height int) string {
 		}
 		schemaInfo = fmt.Sprintf("Tables: %d • Views: %d • Functions: %d • PostGIS: %s",
 			tablesCount, viewsCount, functionsCount, postgisStatus)
+
+		// Partitions are counted in their parents' sizes
+		var rows, bytes int64
+		sized := false
+		for _, t := range m.schema.WithoutPartitions().Tables {
+			if t.Size != nil {
+				sized = true
+				rows += max(t.Size.RowEstimate, 0)
+				bytes += t.Size.TotalBytes
+			}
+		}
+		if sized {
+			schemaInfo += fmt.Sprintf(" • ~%s rows, %s", config.HumanCount(rows), config.HumanBytes(bytes))
+		}
+		if unreadable := tablesCount - len(m.schema.Readable().Tables); unreadable > 0 {
+			schemaInfo += fmt.Sprintf(" • %d not readable", unreadable)
+		}
+		if secured := len(m.rowSecurityTables()); secured > 0 {
+			schemaInfo += fmt.Sprintf(" • %d with row security", secured)
+		}
 	}
 
 	schemaStyle := lipgloss.NewStyle().
@@ -1236,16 +2931,17 @@ func (m *QueryModel) renderWelcome(height int) string {
 		Bold(true).
 		Render("Example queries:")
 
-	examples := []string{
-		"• \"How many records are in each table?\"",
-		"• \"Show me the first 10 customers\"",
-		"• \"What are the columns in users?\"",
-		"• \"Describe the products table\"",
+	var examples []string
+	for _, question := range m.suggestions[:min(len(m.suggestions), welcomeSuggestions)] {
+		examples = append(examples, fmt.Sprintf("• %q", question))
 	}
-
-	if m.schema != nil && m.schema.HasPostGIS {
-		examples = append(examples, "• \"Find all roads within 1km\" (PostGIS)")
-		examples = append(examples, "• \"What is the total length of roads?\"")
+	if len(examples) == 0 {
+		// Nothing harvested to suggest questions about
+		examples = []string{
+			"• \"How many records are in each table?\"",
+			"• \"What tables are there?\"",
+			"• \"What are the largest tables?\"",
+		}
 	}
 
 	examplesStyle := lipgloss.NewStyle().
@@ -1282,42 +2978,13 @@ func (m *QueryModel) renderWelcome(height int) string {
 }
 
 
-// formatValue formats a SQL value for display
-func formatValue(val interface{}) string {
-	if val == nil {
-		return "NULL"
-	}
-
-	switch v := val.(type) {
-	case []byte:
-		return string(v)
-	case time.Time:
-		return v.Format("2006-01-02 15:04:05")
-	case sql.NullString:
-		if v.Valid {
-			return v.String
-		}
-		return "NULL"
-	case sql.NullInt64:
-		if v.Valid {
-			return fmt.Sprintf("%d", v.Int64)
-		}
-		return "NULL"
-	case sql.NullFloat64:
-		if v.Valid {
-			return fmt.Sprintf("%.2f", v.Float64)
-		}
-		return "NULL"
-	default:
-		return fmt.Sprintf("%v", v)
-	}
-}
-
+// padOrTruncate fits s to width terminal columns, cut short with … when
+// wider; CJK characters and emoji take two columns
 func padOrTruncate(s string, width int) string {
-	if len(s) > width {
-		return s[:width-1] + "…"
+	if runewidth.StringWidth(s) > width {
+		s = runewidth.Truncate(s, width, "…")
 	}
-	return s + strings.Repeat(" ", width-len(s))
+	return runewidth.FillRight(s, width)
 }
 
 func min(a, b int) int {
@@ -0,0 +1,203 @@
+package tui
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+
+	"golang.org/x/image/vector"
+)
+
+// clipMargin is how far outside the image shapes are kept when clipping, so
+// strokes and anti-aliasing along the border stay intact
+const clipMargin = 16
+
+// shapeLayer collects anti-aliased shapes of one colour and paints them in a
+// single pass. Overlapping shapes wound the same way cover a pixel once, so
+// translucent fills do not darken where features overlap.
+type shapeLayer struct {
+	z      *vector.Rasterizer
+	width  int
+	height int
+	empty  bool
+}
+
+// newShapeLayer creates a layer covering an image of the given size
+func newShapeLayer(width, height int) *shapeLayer {
+	return &shapeLayer{z: vector.NewRasterizer(width, height), width: width, height: height, empty: true}
+}
+
+// signedArea returns twice the signed area of a ring; positive rings run
+// clockwise on screen, where y grows downwards
+func signedArea(ring []Point) float64 {
+	area := 0.0
+	for i := range ring {
+		j := (i + 1) % len(ring)
+		area += ring[i].X*ring[j].Y - ring[j].X*ring[i].Y
+	}
+	return area
+}
+
+// clipRing clips a ring to the image plus clipMargin (Sutherland-Hodgman) so
+// vertices far outside a zoomed viewport never reach the rasterizer
+func (l *shapeLayer) clipRing(ring []Point) []Point {
+	minX, minY := -float64(clipMargin), -float64(clipMargin)
+	maxX, maxY := float64(l.width+clipMargin), float64(l.height+clipMargin)
+
+	edges := []struct {
+		inside    func(Point) bool
+		intersect func(a, b Point) Point
+	}{
+		{func(p Point) bool { return p.X >= minX }, func(a, b Point) Point { return lerpX(a, b, minX) }},
+		{func(p Point) bool { return p.X <= maxX }, func(a, b Point) Point { return lerpX(a, b, maxX) }},
+		{func(p Point) bool { return p.Y >= minY }, func(a, b Point) Point { return lerpY(a, b, minY) }},
+		{func(p Point) bool { return p.Y <= maxY }, func(a, b Point) Point { return lerpY(a, b, maxY) }},
+	}
+
+	out := ring
+	for _, e := range edges {
+		if len(out) == 0 {
+			break
+		}
+		in := out
+		out = nil
+		prev := in[len(in)-1]
+		for _, p := range in {
+			switch {
+			case e.inside(p) && e.inside(prev):
+				out = append(out, p)
+			case e.inside(p):
+				out = append(out, e.intersect(prev, p), p)
+			case e.inside(prev):
+				out = append(out, e.intersect(prev, p))
+			}
+			prev = p
+		}
+	}
+	return out
+}
+
+// lerpX returns the point on segment ab where it crosses the vertical line x
+func lerpX(a, b Point, x float64) Point {
+	t := (x - a.X) / (b.X - a.X)
+	return Point{X: x, Y: a.Y + t*(b.Y-a.Y)}
+}
+
+// lerpY returns the point on segment ab where it crosses the horizontal line y
+func lerpY(a, b Point, y float64) Point {
+	t := (y - a.Y) / (b.Y - a.Y)
+	return Point{X: a.X + t*(b.X-a.X), Y: y}
+}
+
+// addRing adds a closed ring, wound clockwise or counter-clockwise as asked
+func (l *shapeLayer) addRing(ring []Point, clockwise bool) {
+	ring = l.clipRing(ring)
+	if len(ring) < 3 {
+		return
+	}
+	if (signedArea(ring) > 0) != clockwise {
+		reversed := make([]Point, len(ring))
+		for i, p := range ring {
+			reversed[len(ring)-1-i] = p
+		}
+		ring = reversed
+	}
+	l.z.MoveTo(float32(ring[0].X), float32(ring[0].Y))
+	for _, p := range ring[1:] {
+		l.z.LineTo(float32(p.X), float32(p.Y))
+	}
+	l.z.ClosePath()
+	l.empty = false
+}
+
+// addPolygon adds a polygon's exterior ring and cuts out its holes, whatever
+// the winding order of the source data
+func (l *shapeLayer) addPolygon(rings [][]Point) {
+	for i, ring := range rings {
+		l.addRing(ring, i == 0)
+	}
+}
+
+// addCircle adds a filled circle
+func (l *shapeLayer) addCircle(c Point, radius float64) {
+	if c.X < -radius || c.Y < -radius || c.X > float64(l.width)+radius || c.Y > float64(l.height)+radius {
+		return
+	}
+	steps := max(8, int(radius*4))
+	ring := make([]Point, steps)
+	for i := range ring {
+		a := 2 * math.Pi * float64(i) / float64(steps)
+		ring[i] = Point{X: c.X + radius*math.Cos(a), Y: c.Y + radius*math.Sin(a)}
+	}
+	l.addRing(ring, true)
+}
+
+// addStroke adds a line of the given width through points, with round
+// joins when the line is wide enough for corners to show
+func (l *shapeLayer) addStroke(points []Point, width float64, closed bool) {
+	if closed && len(points) > 2 {
+		points = append(points[:len(points):len(points)], points[0])
+	}
+	half := width / 2
+	for i := 0; i+1 < len(points); i++ {
+		a, b, ok := l.clipSegment(points[i], points[i+1], half)
+		if !ok {
+			continue
+		}
+		dx, dy := b.X-a.X, b.Y-a.Y
+		length := math.Hypot(dx, dy)
+		if length == 0 {
+			continue
+		}
+		nx, ny := -dy/length*half, dx/length*half
+		l.addRing([]Point{{a.X + nx, a.Y + ny}, {b.X + nx, b.Y + ny}, {b.X - nx, b.Y - ny}, {a.X - nx, a.Y - ny}}, true)
+		if width > 2 {
+			l.addCircle(b, half)
+		}
+	}
+}
+
+// clipSegment clips a segment to the image plus the stroke width
+// (Liang-Barsky), reporting false when it lies entirely outside
+func (l *shapeLayer) clipSegment(a, b Point, pad float64) (Point, Point, bool) {
+	dx, dy := b.X-a.X, b.Y-a.Y
+	t0, t1 := 0.0, 1.0
+	edges := []struct{ p, q float64 }{
+		{-dx, a.X + pad},
+		{dx, float64(l.width) + pad - a.X},
+		{-dy, a.Y + pad},
+		{dy, float64(l.height) + pad - a.Y},
+	}
+	for _, e := range edges {
+		if e.p == 0 {
+			if e.q < 0 {
+				return a, b, false
+			}
+			continue
+		}
+		t := e.q / e.p
+		if e.p < 0 {
+			if t > t1 {
+				return a, b, false
+			}
+			t0 = math.Max(t0, t)
+		} else {
+			if t < t0 {
+				return a, b, false
+			}
+			t1 = math.Min(t1, t)
+		}
+	}
+	return Point{a.X + t0*dx, a.Y + t0*dy}, Point{a.X + t1*dx, a.Y + t1*dy}, true
+}
+
+// paint composites the collected shapes onto img in colour c and clears the layer
+func (l *shapeLayer) paint(img draw.Image, c color.Color) {
+	if !l.empty {
+		l.z.DrawOp = draw.Over
+		l.z.Draw(img, img.Bounds(), image.NewUniform(c), image.Point{})
+	}
+	l.z.Reset(l.width, l.height)
+	l.empty = true
+}
@@ -0,0 +1,272 @@
+package tui
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/kartoza/kartoza-pg-ai/internal/postgres"
+)
+
+// Report formats, offered alongside the result exports
+const (
+	ExportMarkdown ExportFormat = "md"
+	ExportHTML     ExportFormat = "html"
+)
+
+// Limits that keep reports readable
+const (
+	reportMaxRows     = 100 // Result rows written per entry
+	reportMaxCellLen  = 120 // Longer cells (e.g. WKB geometry) are cut short
+	reportImageSubdir = "_images"
+)
+
+// reportCompletedMsg indicates a conversation report was written
+type reportCompletedMsg struct {
+	path    string
+	entries int
+	err     error
+}
+
+// reportItem is a snapshot of one conversation entry, taken before the
+// report is written in the background
+type reportItem struct {
+	query    string
+	sql      string
+	params   map[string]string
+	err      string
+	columns  []string
+	rows     [][]string
+	rowCount int
+	execMs   float64
+	mutating bool
+	png      string // Base64 PNG of the geometry preview
+}
+
+// reportFileName returns a timestamped report file name in the working directory
+func reportFileName(format ExportFormat) string {
+	name := fmt.Sprintf("kartoza-pg-ai-report-%s.%s", time.Now().Format("20060102-150405"), format)
+	if wd, err := os.Getwd(); err == nil {
+		return filepath.Join(wd, name)
+	}
+	return name
+}
+
+// exportReport writes the conversation of the active session to a Markdown
+// or HTML report
+func (m *QueryModel) exportReport(format ExportFormat) tea.Cmd {
+	title := "Kartoza PG AI report"
+	if m.service != nil {
+		title += " - " + m.service.Name
+	}
+	if len(m.sessions) > 1 {
+		title += " (" + m.sessionName() + ")"
+	}
+
+	items := make([]reportItem, 0, len(m.history))
+	for _, entry := range m.history {
+		item := reportItem{query: entry.Query, sql: entry.SQL, err: entry.Error}
+		if entry.EditedSQL != "" {
+			item.sql = entry.EditedSQL
+		}
+		if r := entry.Results; r != nil {
+			item.params = r.Params
+			item.columns = r.Columns
+			item.rows = r.Rows[:min(len(r.Rows), reportMaxRows)]
+			item.rowCount = max(r.RowCount, len(r.Rows))
+			item.execMs = r.ExecutionTime
+			item.mutating = r.Mutating
+			item.png = r.GeometryPNGData
+		}
+		items = append(items, item)
+	}
+
+	return func() tea.Msg {
+		if len(items) == 0 {
+			return reportCompletedMsg{err: fmt.Errorf("the conversation is empty")}
+		}
+		path := reportFileName(format)
+		err := writeReportFile(path, format, title, items)
+		return reportCompletedMsg{path: path, entries: len(items), err: err}
+	}
+}
+
+// writeReportFile writes the report, removing partial output on failure
+func writeReportFile(path string, format ExportFormat, title string, items []reportItem) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(f)
+	switch format {
+	case ExportMarkdown:
+		err = writeMarkdownReport(w, path, title, items)
+	case ExportHTML:
+		err = writeHTMLReport(w, title, items)
+	default:
+		err = fmt.Errorf("unknown report format: %s", format)
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+	}
+	return err
+}
+
+// reportCell shortens a cell value for a report table
+func reportCell(v string) string {
+	v = strings.Join(strings.Fields(v), " ")
+	if r := []rune(v); len(r) > reportMaxCellLen {
+		return string(r[:reportMaxCellLen-1]) + "…"
+	}
+	return v
+}
+
+// summary describes an entry's outcome, e.g. "12 rows in 3.1 ms"
+func (it reportItem) summary() string {
+	if it.mutating {
+		return fmt.Sprintf("Statement affected %d rows in %.1f ms", it.rowCount, it.execMs)
+	}
+	s := fmt.Sprintf("%d rows in %.1f ms", it.rowCount, it.execMs)
+	if len(it.rows) < it.rowCount {
+		s += fmt.Sprintf(" (first %d shown)", len(it.rows))
+	}
+	return s
+}
+
+// sortedParams returns "name = value" pairs in name order
+func sortedParams(params map[string]string) []string {
+	var pairs []string
+	for name, v := range params {
+		pairs = append(pairs, fmt.Sprintf("%s = %s", name, v))
+	}
+	sort.Strings(pairs)
+	return pairs
+}
+
+// writeMarkdownReport writes a Markdown report. Geometry previews are saved
+// as PNG files in a directory next to the report and linked relatively.
+func writeMarkdownReport(w io.Writer, path, title string, items []reportItem) error {
+	imageDir := strings.TrimSuffix(path, filepath.Ext(path)) + reportImageSubdir
+	mdCell := func(v string) string {
+		return strings.ReplaceAll(reportCell(v), "|", `\|`)
+	}
+
+	fmt.Fprintf(w, "# %s\n\n_Generated %s_\n", title, time.Now().Format("2006-01-02 15:04"))
+	for i, it := range items {
+		fmt.Fprintf(w, "\n## %d. %s\n\n", i+1, it.query)
+		if it.sql != "" {
+			fmt.Fprintf(w, "```sql\n%s\n```\n\n", postgres.FormatSQL(strings.TrimSpace(it.sql), sqlTextWidth))
+		}
+		if params := sortedParams(it.params); len(params) > 0 {
+			fmt.Fprintf(w, "Parameters: `%s`\n\n", strings.Join(params, "`, `"))
+		}
+		if it.err != "" {
+			fmt.Fprintf(w, "> **Error:** %s\n", strings.ReplaceAll(it.err, "\n", "\n> "))
+			continue
+		}
+		fmt.Fprintf(w, "_%s_\n", it.summary())
+
+		if len(it.columns) > 0 && len(it.rows) > 0 {
+			fmt.Fprintln(w)
+			header := make([]string, len(it.columns))
+			for c, col := range it.columns {
+				header[c] = mdCell(col)
+			}
+			fmt.Fprintf(w, "| %s |\n|%s\n", strings.Join(header, " | "), strings.Repeat(" --- |", len(header)))
+			for _, row := range it.rows {
+				cells := make([]string, len(row))
+				for c, v := range row {
+					cells[c] = mdCell(v)
+				}
+				fmt.Fprintf(w, "| %s |\n", strings.Join(cells, " | "))
+			}
+		}
+
+		if it.png != "" {
+			data, err := base64.StdEncoding.DecodeString(it.png)
+			if err != nil {
+				return err
+			}
+			if err := os.MkdirAll(imageDir, 0755); err != nil {
+				return err
+			}
+			name := fmt.Sprintf("entry-%d.png", i+1)
+			if err := os.WriteFile(filepath.Join(imageDir, name), data, 0644); err != nil {
+				return err
+			}
+			fmt.Fprintf(w, "\n![Geometry of entry %d](%s/%s)\n", i+1, filepath.Base(imageDir), name)
+		}
+	}
+	return nil
+}
+
+// writeHTMLReport writes a self-contained HTML report with geometry
+// previews embedded as data URIs
+func writeHTMLReport(w io.Writer, title string, items []reportItem) error {
+	esc := html.EscapeString
+	fmt.Fprintf(w, `<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>%s</title>
+<style>
+body{font-family:sans-serif;max-width:1100px;margin:2em auto;color:#222}
+h1{color:#DDA036}h2{border-bottom:1px solid #ccc;padding-bottom:.2em}
+pre{background:#f4f4f4;padding:.8em;overflow-x:auto}
+table{border-collapse:collapse;font-size:.9em;margin:.5em 0}
+th,td{border:1px solid #ccc;padding:.2em .5em;text-align:left}th{background:#569FC6;color:#fff}
+.meta{color:#777;font-style:italic}.error{color:#E95420}
+</style></head><body>
+<h1>%s</h1>
+<p class="meta">Generated %s</p>
+`, esc(title), esc(title), time.Now().Format("2006-01-02 15:04"))
+
+	for i, it := range items {
+		fmt.Fprintf(w, "<h2>%d. %s</h2>\n", i+1, esc(it.query))
+		if it.sql != "" {
+			fmt.Fprintf(w, "<pre><code>%s</code></pre>\n", esc(postgres.FormatSQL(strings.TrimSpace(it.sql), sqlTextWidth)))
+		}
+		if params := sortedParams(it.params); len(params) > 0 {
+			fmt.Fprintf(w, "<p>Parameters: <code>%s</code></p>\n", esc(strings.Join(params, ", ")))
+		}
+		if it.err != "" {
+			fmt.Fprintf(w, "<p class=\"error\"><strong>Error:</strong> %s</p>\n", esc(it.err))
+			continue
+		}
+		fmt.Fprintf(w, "<p class=\"meta\">%s</p>\n", esc(it.summary()))
+
+		if len(it.columns) > 0 && len(it.rows) > 0 {
+			io.WriteString(w, "<table><tr>")
+			for _, col := range it.columns {
+				fmt.Fprintf(w, "<th>%s</th>", esc(col))
+			}
+			io.WriteString(w, "</tr>\n")
+			for _, row := range it.rows {
+				io.WriteString(w, "<tr>")
+				for _, v := range row {
+					fmt.Fprintf(w, "<td>%s</td>", esc(reportCell(v)))
+				}
+				io.WriteString(w, "</tr>\n")
+			}
+			io.WriteString(w, "</table>\n")
+		}
+		if it.png != "" {
+			fmt.Fprintf(w, "<p><img alt=\"Geometry of entry %d\" src=\"data:image/png;base64,%s\"></p>\n", i+1, it.png)
+		}
+	}
+
+	_, err := io.WriteString(w, "</body></html>\n")
+	return err
+}
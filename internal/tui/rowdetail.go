@@ -0,0 +1,237 @@
+package tui
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Row detail layout limits
+const (
+	maxDetailKeyWidth = 30 // Column name width cap
+	jsonFoldLines     = 12 // Pretty-printed JSON longer than this is folded
+)
+
+// rowSelectState tracks the highlighted row while choosing one to inspect
+type rowSelectState struct {
+	entry int // Conversation entry whose table is being browsed
+	row   int
+}
+
+// RowDetailModel shows every column of one result row as key/value pairs,
+// with long values wrapped and JSON values pretty-printed
+type RowDetailModel struct {
+	width    int
+	height   int
+	results  *QueryResults
+	row      int
+	offset   int // First visible line
+	lines    []string
+	unfolded bool // Show long JSON values in full
+}
+
+// NewRowDetailModel creates a detail view of row index row of results
+func NewRowDetailModel(results *QueryResults, row, width, height int) *RowDetailModel {
+	m := &RowDetailModel{width: width, height: height, results: results, row: row}
+	m.rebuild()
+	return m
+}
+
+// prettyJSON indents a JSON object or array; other values are returned unchanged
+func prettyJSON(value string) (string, bool) {
+	trimmed := strings.TrimSpace(value)
+	if !strings.HasPrefix(trimmed, "{") && !strings.HasPrefix(trimmed, "[") {
+		return value, false
+	}
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, []byte(trimmed), "", "  "); err != nil {
+		return value, false
+	}
+	return buf.String(), true
+}
+
+// rebuild lays out the row for the current width
+func (m *RowDetailModel) rebuild() {
+	m.lines = nil
+	if m.row < 0 || m.row >= len(m.results.Rows) {
+		return
+	}
+
+	keyWidth := 0
+	for _, col := range m.results.Columns {
+		keyWidth = max(keyWidth, lipgloss.Width(col))
+	}
+	keyWidth = min(keyWidth, maxDetailKeyWidth)
+	valueWidth := max(m.width-keyWidth-14, 20)
+
+	keyStyle := lipgloss.NewStyle().Foreground(ColorOrange).Bold(true).Width(keyWidth).MaxHeight(1)
+	valueStyle := lipgloss.NewStyle().Foreground(ColorWhite)
+	nullStyle := lipgloss.NewStyle().Foreground(ColorGray).Italic(true)
+	jsonStyle := lipgloss.NewStyle().Foreground(ColorCyan)
+	sepStyle := lipgloss.NewStyle().Foreground(ColorGray)
+	indent := strings.Repeat(" ", keyWidth+3)
+
+	row := m.results.Rows[m.row]
+	for i, col := range m.results.Columns {
+		value := ""
+		if i < len(row) {
+			value = row[i]
+		}
+
+		style := valueStyle
+		if m.results.isNull(m.row, i) {
+			style = nullStyle
+		}
+		var valueLines []string
+		var folded int // JSON lines hidden by folding
+		if pretty, ok := prettyJSON(value); ok && m.results.mayHoldJSON(i) {
+			style = jsonStyle
+			jsonLines := strings.Split(pretty, "\n")
+			if !m.unfolded && len(jsonLines) > jsonFoldLines {
+				folded = len(jsonLines) - jsonFoldLines
+				jsonLines = jsonLines[:jsonFoldLines]
+			}
+			for _, line := range jsonLines {
+				valueLines = append(valueLines, strings.Split(lipgloss.NewStyle().Width(valueWidth).Render(line), "\n")...)
+			}
+		} else {
+			valueLines = strings.Split(lipgloss.NewStyle().Width(valueWidth).Render(value), "\n")
+		}
+
+		key := keyStyle.Render(col)
+		for j, line := range valueLines {
+			prefix := indent
+			if j == 0 {
+				prefix = key + sepStyle.Render(" │ ")
+			}
+			m.lines = append(m.lines, prefix+style.Render(strings.TrimRight(line, " ")))
+		}
+		if folded > 0 {
+			m.lines = append(m.lines, indent+nullStyle.Render(fmt.Sprintf("… %d more lines (z: unfold)", folded)))
+		}
+	}
+}
+
+// visibleLines is the number of detail lines that fit on screen
+func (m *RowDetailModel) visibleLines() int {
+	return max(m.height-12, 5)
+}
+
+// Update handles scrolling and moving between rows; closing is left to the parent
+func (m *RowDetailModel) Update(msg tea.Msg) (*RowDetailModel, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		m.height = msg.Height
+		m.rebuild()
+
+	case tea.KeyMsg:
+		maxOffset := max(len(m.lines)-m.visibleLines(), 0)
+		switch msg.String() {
+		case "up", "k":
+			m.offset = max(m.offset-1, 0)
+		case "down", "j":
+			m.offset = min(m.offset+1, maxOffset)
+		case "pgup":
+			m.offset = max(m.offset-m.visibleLines(), 0)
+		case "pgdown", " ":
+			m.offset = min(m.offset+m.visibleLines(), maxOffset)
+		case "home", "g":
+			m.offset = 0
+		case "end", "G":
+			m.offset = maxOffset
+		case "z":
+			m.unfolded = !m.unfolded
+			m.offset = 0
+			m.rebuild()
+		case "left", "h":
+			if m.row > 0 {
+				m.row--
+				m.offset = 0
+				m.rebuild()
+			}
+		case "right", "l":
+			if m.row < len(m.results.Rows)-1 {
+				m.row++
+				m.offset = 0
+				m.rebuild()
+			}
+		}
+	}
+	return m, nil
+}
+
+// View renders the row detail screen
+func (m *RowDetailModel) View() string {
+	header := RenderHeader(fmt.Sprintf("Row %d of %d", m.row+1, len(m.results.Rows)))
+
+	end := min(m.offset+m.visibleLines(), len(m.lines))
+	lines := append([]string(nil), m.lines[m.offset:end]...)
+	if len(m.lines) > m.visibleLines() {
+		lines = append(lines, "", lipgloss.NewStyle().Foreground(ColorGray).Italic(true).
+			Render(fmt.Sprintf("Lines %d-%d of %d", m.offset+1, end, len(m.lines))))
+	}
+	content := BoxStyle.Width(m.width - 6).Render(lipgloss.JoinVertical(lipgloss.Left, lines...))
+
+	helpText := "j/k: scroll • h/l: previous/next row • g/G: top/bottom • z: fold/unfold JSON • esc/q: close"
+	footer := RenderHelpFooter(helpText, m.width)
+
+	return LayoutWithHeaderFooter(header, content, footer, m.width, m.height)
+}
+
+// displayedRows returns how many rows of a result the conversation shows:
+// a few for earlier entries, the loaded window for the latest one
+func (m *QueryModel) displayedRows(results *QueryResults, isLatest bool) int {
+	maxRows := 5
+	if isLatest {
+		maxRows = m.visibleRows
+	}
+	return min(len(results.Rows), maxRows)
+}
+
+// selectedRow returns the highlighted row of results, or -1
+func (m *QueryModel) selectedRow(results *QueryResults) int {
+	if m.rowSelect == nil || m.history[m.rowSelect.entry].Results != results {
+		return -1
+	}
+	return m.rowSelect.row
+}
+
+// startRowSelect highlights the first row of the selected entry's results
+func (m *QueryModel) startRowSelect() {
+	if m.selectedEntry < 0 || m.selectedEntry >= len(m.history) {
+		return
+	}
+	if results := m.history[m.selectedEntry].Results; results == nil || len(results.Rows) == 0 {
+		m.statusMsg = "✗ No rows to inspect for this entry"
+		return
+	}
+	m.rowSelect = &rowSelectState{entry: m.selectedEntry}
+}
+
+// handleRowSelectKey moves the row highlight and opens the detail view
+func (m *QueryModel) handleRowSelectKey(msg tea.KeyMsg) (*QueryModel, tea.Cmd) {
+	sel := m.rowSelect
+	results := m.history[sel.entry].Results
+	shown := m.displayedRows(results, sel.entry == len(m.history)-1)
+
+	switch msg.String() {
+	case "up", "k":
+		if sel.row > 0 {
+			sel.row--
+		}
+	case "down", "j":
+		if sel.row < shown-1 {
+			sel.row++
+		}
+	case "enter":
+		m.rowDetail = NewRowDetailModel(results, sel.row, m.width, m.height)
+	case "esc", "q":
+		m.rowSelect = nil
+	}
+	return m, nil
+}
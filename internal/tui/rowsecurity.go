@@ -0,0 +1,63 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/kartoza/kartoza-pg-ai/internal/config"
+	"github.com/kartoza/kartoza-pg-ai/internal/postgres"
+)
+
+// rowSecurityTables returns the tables whose rows row-level security
+// policies filter for the connected role
+func (m *QueryModel) rowSecurityTables() []config.TableInfo {
+	if m.schema == nil {
+		return nil
+	}
+	var tables []config.TableInfo
+	for _, t := range m.schema.Tables {
+		if t.RowSecurityActive() {
+			tables = append(tables, t)
+		}
+	}
+	return tables
+}
+
+// rowSecurityWarning explains results without rows when the SQL read a
+// table whose policies may have hidden them, or returns ""
+func (m *QueryModel) rowSecurityWarning(results *QueryResults) string {
+	if results == nil || len(results.Rows) > 0 || results.Mutating || len(results.Columns) == 0 {
+		return ""
+	}
+	sql := results.EditedSQL
+	if sql == "" {
+		sql = results.GeneratedSQL
+	}
+	names := make(map[string]bool)
+	for _, tok := range postgres.LexSQL(sql) {
+		switch tok.Kind {
+		case postgres.TokenIdentifier:
+			names[strings.ToLower(tok.Text)] = true
+		case postgres.TokenQuotedIdentifier:
+			names[strings.ReplaceAll(strings.Trim(tok.Text, `"`), `""`, `"`)] = true
+		}
+	}
+
+	var notes []string
+	for _, t := range m.rowSecurityTables() {
+		if !names[t.Name] {
+			continue
+		}
+		note := t.Schema + "." + t.Name
+		if policies := t.RowSecurity.PolicyNames(); len(policies) > 0 {
+			note += " (policies " + strings.Join(policies, ", ") + ")"
+		} else {
+			note += " (no policy lets this role read any rows)"
+		}
+		notes = append(notes, note)
+	}
+	if len(notes) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("⚠ Row-level security may have hidden rows of %s", strings.Join(notes, ", "))
+}
@@ -0,0 +1,110 @@
+package tui
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/kartoza/kartoza-pg-ai/internal/llm"
+	"github.com/kartoza/kartoza-pg-ai/internal/postgres"
+)
+
+// schemaChoiceMsg asks which schema a question's table refers to when its
+// name exists in several schemas
+type schemaChoiceMsg struct {
+	query   string
+	table   string
+	schemas []string
+	edit    bool // Generate SQL into the editor instead of running it
+}
+
+// schemaPromptState tracks the highlighted schema while choosing one
+type schemaPromptState struct {
+	schemaChoiceMsg
+	selected int
+}
+
+// schemaChoiceFor turns an ambiguous table error into a schema prompt
+func schemaChoiceFor(err error, query string, edit bool) (schemaChoiceMsg, bool) {
+	var ambiguous *llm.AmbiguousTableError
+	if !errors.As(err, &ambiguous) {
+		return schemaChoiceMsg{}, false
+	}
+	return schemaChoiceMsg{query: query, table: ambiguous.Table, schemas: ambiguous.Schemas, edit: edit}, true
+}
+
+// handleSchemaPromptKey moves the highlight between schemas; Enter uses the
+// highlighted schema for this session and d also makes it the service's
+// default schema
+func (m *QueryModel) handleSchemaPromptKey(msg tea.KeyMsg) (*QueryModel, tea.Cmd) {
+	p := m.schemaPrompt
+	switch msg.String() {
+	case "esc", "q":
+		m.schemaPrompt = nil
+		m.background = nil
+		m.statusMsg = "Query cancelled"
+	case "left", "up", "shift+tab":
+		p.selected = (p.selected + len(p.schemas) - 1) % len(p.schemas)
+	case "right", "down", "tab":
+		p.selected = (p.selected + 1) % len(p.schemas)
+	case "1", "2", "3", "4", "5", "6", "7", "8", "9":
+		if i := int(msg.String()[0] - '1'); i < len(p.schemas) {
+			p.selected = i
+			return m.chooseSchema(false)
+		}
+	case "enter":
+		return m.chooseSchema(false)
+	case "d":
+		return m.chooseSchema(true)
+	}
+	return m, nil
+}
+
+// chooseSchema resolves the table to the highlighted schema, optionally
+// saving it as the service's default, and asks the question again
+func (m *QueryModel) chooseSchema(asDefault bool) (*QueryModel, tea.Cmd) {
+	p := m.schemaPrompt
+	m.schemaPrompt = nil
+	schema := p.schemas[p.selected]
+
+	if asDefault && m.service != nil {
+		entry := *m.service
+		entry.DefaultSchema = schema
+		if err := postgres.SaveServiceEntry(entry); err != nil {
+			m.statusMsg = "✗ Failed to save default schema: " + err.Error()
+		} else {
+			m.service.DefaultSchema = schema
+			m.queryEngine.SetDefaultSchema(schema)
+			m.statusMsg = fmt.Sprintf("✓ %s is now the default schema of %s", schema, m.service.Name)
+		}
+	}
+	m.queryEngine.ChooseSchema(p.table, schema)
+
+	m.loading = true
+	ctx := m.newQueryContext()
+	if p.edit {
+		return m, tea.Batch(m.spinner.Tick, m.generateForEdit(ctx, p.query))
+	}
+	m.scrollOffset = 0
+	return m, tea.Batch(m.spinner.Tick, m.executeQuery(ctx, p.query))
+}
+
+// renderSchemaPrompt renders the prompt line listing the candidate schemas
+func (m *QueryModel) renderSchemaPrompt() string {
+	p := m.schemaPrompt
+	highlight := lipgloss.NewStyle().Foreground(ColorOrange).Bold(true)
+	dim := lipgloss.NewStyle().Foreground(ColorGray)
+
+	options := make([]string, len(p.schemas))
+	for i, schema := range p.schemas {
+		option := fmt.Sprintf("%d) %s.%s", i+1, schema, p.table)
+		if i == p.selected {
+			options[i] = highlight.Render("▶ " + option)
+		} else {
+			options[i] = dim.Render(option)
+		}
+	}
+	return PromptStyle.Render(fmt.Sprintf("❓ %q is in several schemas: ", p.table)) + strings.Join(options, "  ")
+}
@@ -0,0 +1,155 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/kartoza/kartoza-pg-ai/internal/postgres"
+	"github.com/kartoza/kartoza-pg-ai/internal/tracing"
+)
+
+// scriptMaxRows bounds the rows kept of each statement of a script, which
+// are fetched at once rather than through a cursor
+const scriptMaxRows = 1000
+
+// ScriptStep is the outcome of one statement of a multi-statement script
+type ScriptStep struct {
+	SQL           string
+	Results       *QueryResults // Rows of the statement; nil when it returns none
+	Shown         bool          // Results are the entry's own results
+	Truncated     bool          // Only the first scriptMaxRows rows were kept
+	RowsAffected  int64         // Rows changed by a statement returning none; -1 when unknown
+	ExecutionTime float64       // Milliseconds
+}
+
+// runScript runs the statements of a multi-statement script in order on
+// one connection, so temporary tables carry over between them. Read-only
+// scripts are rolled back afterwards. The rows of the last statement that
+// returns any become the entry's results, and every statement is listed in
+// its Steps.
+func (m *QueryModel) runScript(ctx context.Context, query, generatedSQL, sqlQuery string, statements []string, class postgres.StatementClass, params map[string]string) tea.Msg {
+	script := make([]postgres.ScriptStatement, 0, len(statements))
+	for _, stmt := range statements {
+		boundSQL, args, err := postgres.BindTemplate(stmt, params)
+		if err != nil {
+			return queryExecutedMsg{err: err}
+		}
+		script = append(script, postgres.ScriptStatement{SQL: boundSQL, Args: args})
+	}
+
+	db := m.database()
+	if db == nil {
+		return queryExecutedMsg{err: fmt.Errorf("no database connection")}
+	}
+	if err := m.conn.Check(ctx); err != nil {
+		return queryExecutedMsg{err: fmt.Errorf("database unavailable: %w", err)}
+	}
+
+	mutating := class.IsMutating()
+	scriptCtx, span := tracing.Start(ctx, "execute_script", m.dbSpanAttributes(sqlQuery)...)
+	results, err := postgres.RunScript(scriptCtx, db, script, mutating, scriptMaxRows)
+	tracing.End(span, err)
+	if err != nil {
+		return queryExecutedMsg{err: fmt.Errorf("script failed: %w\nSQL: %s", err, sqlQuery)}
+	}
+
+	editedSQL := ""
+	if sqlQuery != generatedSQL {
+		editedSQL = sqlQuery
+	}
+	queryResults := &QueryResults{
+		GeneratedSQL:   generatedSQL,
+		EditedSQL:      editedSQL,
+		NaturalQuery:   query,
+		GeometryColIdx: -1,
+		Mutating:       mutating,
+		Params:         params,
+	}
+
+	shown := -1
+	for i, r := range results {
+		step := ScriptStep{
+			SQL:           statements[i],
+			Truncated:     r.Truncated,
+			RowsAffected:  r.RowsAffected,
+			ExecutionTime: r.Duration.Seconds() * 1000,
+		}
+		if r.Columns != nil {
+			rows := postgres.FormatRows(r.Rows)
+			step.Results = &QueryResults{
+				Columns:        r.Columns,
+				ColumnTypes:    r.ColumnTypes,
+				Rows:           rows,
+				Nulls:          postgres.NullRows(r.Rows),
+				RowCount:       len(rows),
+				GeneratedSQL:   statements[i],
+				GeometryColIdx: -1,
+			}
+			shown = i
+		}
+		queryResults.ExecutionTime += step.ExecutionTime
+		queryResults.Steps = append(queryResults.Steps, step)
+	}
+
+	if shown >= 0 {
+		step := &queryResults.Steps[shown]
+		step.Shown = true
+		queryResults.Columns = step.Results.Columns
+		queryResults.ColumnTypes = step.Results.ColumnTypes
+		queryResults.Rows = step.Results.Rows
+		queryResults.Nulls = step.Results.Nulls
+		queryResults.RowCount = step.Results.RowCount
+		if len(queryResults.Rows) > 0 {
+			queryResults.GeometryColIdx = DetectGeometryColumn(queryResults.Columns, queryResults.Rows[0])
+			queryResults.GeometryColumns = DetectGeometryColumns(queryResults.Columns, queryResults.Rows[0])
+		}
+		queryResults.renderGeometry()
+	}
+
+	return queryExecutedMsg{results: queryResults}
+}
+
+// renderScriptSteps lists the statements of a script with what each did,
+// and the rows of those whose results are not the entry's own
+func (m *QueryModel) renderScriptSteps(results *QueryResults) []string {
+	if len(results.Steps) == 0 {
+		return nil
+	}
+
+	labelStyle := lipgloss.NewStyle().Foreground(ColorCyan).Bold(true)
+	okStyle := lipgloss.NewStyle().Foreground(ColorGreen)
+	statsStyle := lipgloss.NewStyle().Foreground(ColorGray).Italic(true)
+
+	lines := []string{labelStyle.Render(fmt.Sprintf("  Script (%d statements):", len(results.Steps)))}
+	for i, step := range results.Steps {
+		var outcome string
+		switch {
+		case step.Results != nil:
+			outcome = fmt.Sprintf("%d rows", step.Results.RowCount)
+			if step.Truncated {
+				outcome = fmt.Sprintf("first %d rows", step.Results.RowCount)
+			}
+			if step.Shown {
+				outcome += " (below)"
+			}
+		case step.RowsAffected > 0:
+			outcome = fmt.Sprintf("%d rows affected", step.RowsAffected)
+		default:
+			outcome = "done"
+		}
+
+		sqlText := truncate(strings.Join(strings.Fields(step.SQL), " "), m.width-40)
+		lines = append(lines, fmt.Sprintf("  %s %s %s",
+			okStyle.Render(fmt.Sprintf("✓ %d.", i+1)),
+			highlightSQL(sqlText),
+			statsStyle.Render(fmt.Sprintf("• %s • %.2fms", outcome, step.ExecutionTime))))
+
+		if step.Results != nil && !step.Shown && len(step.Results.Rows) > 0 {
+			lines = append(lines, m.renderEntryTable(step.Results, false)...)
+		}
+	}
+	return append(lines, "")
+}
@@ -0,0 +1,294 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// searchField is the part of a conversation entry a match is in, in the
+// order entries show them
+type searchField int
+
+const (
+	searchQuestion searchField = iota
+	searchSQL
+	searchEditedSQL
+	searchCell
+)
+
+// searchMatch is a question, SQL or result cell containing the pattern
+type searchMatch struct {
+	entry    int
+	field    searchField
+	row, col int // Result cell, for searchCell
+}
+
+// before reports whether a comes before b in the conversation
+func (a searchMatch) before(b searchMatch) bool {
+	if a.entry != b.entry {
+		return a.entry < b.entry
+	}
+	if a.field != b.field {
+		return a.field < b.field
+	}
+	if a.row != b.row {
+		return a.row < b.row
+	}
+	return a.col < b.col
+}
+
+// conversationSearch is a search of the whole conversation in browse mode,
+// like less: / and ? search forward and backward, n and N move between
+// matches and every match on screen is highlighted
+type conversationSearch struct {
+	prompt   *string // Pattern being typed; nil once searched
+	pattern  string  // Last pattern searched for, lower-cased
+	backward bool    // Last search went backward
+	current  *searchMatch
+	scroll   bool // Scroll the current match into view on the next render
+}
+
+// openSearchPrompt starts typing a pattern to search the conversation for
+func (m *QueryModel) openSearchPrompt(backward bool) {
+	if m.search == nil {
+		m.search = &conversationSearch{}
+	}
+	text := ""
+	m.search.prompt = &text
+	m.search.backward = backward
+}
+
+// handleSearchPromptKey edits the search pattern, searching on enter
+func (m *QueryModel) handleSearchPromptKey(msg tea.KeyMsg) (*QueryModel, tea.Cmd) {
+	s := m.search
+	switch msg.String() {
+	case "esc":
+		s.prompt = nil
+		if s.pattern == "" {
+			m.search = nil
+		}
+	case "enter":
+		text := strings.ToLower(strings.TrimSpace(*s.prompt))
+		s.prompt = nil
+		if text == "" && s.pattern == "" {
+			m.search = nil
+			return m, nil
+		}
+		if text != "" {
+			s.pattern = text
+			s.current = nil
+		}
+		m.findMatch(s.backward)
+	case "backspace":
+		if r := []rune(*s.prompt); len(r) > 0 {
+			*s.prompt = string(r[:len(r)-1])
+		}
+	default:
+		if msg.Type == tea.KeyRunes || msg.Type == tea.KeySpace {
+			*s.prompt += string(msg.Runes)
+		}
+	}
+	return m, nil
+}
+
+// searchMatches returns the questions, SQL and shown result cells of the
+// conversation containing the pattern, in conversation order
+func (m *QueryModel) searchMatches(pattern string) []searchMatch {
+	contains := func(text string) bool {
+		return text != "" && strings.Contains(strings.ToLower(text), pattern)
+	}
+	var matches []searchMatch
+	for i, entry := range m.history {
+		if contains(entry.Query) {
+			matches = append(matches, searchMatch{entry: i, field: searchQuestion})
+		}
+		if contains(entry.SQL) {
+			matches = append(matches, searchMatch{entry: i, field: searchSQL})
+		}
+		if contains(entry.EditedSQL) {
+			matches = append(matches, searchMatch{entry: i, field: searchEditedSQL})
+		}
+		results := entry.Results
+		if results == nil {
+			continue
+		}
+		for r, row := range results.Rows {
+			for c, cell := range row {
+				if c < len(results.Columns) && !results.isHidden(c) && contains(cell) {
+					matches = append(matches, searchMatch{entry: i, field: searchCell, row: r, col: c})
+				}
+			}
+		}
+	}
+	return matches
+}
+
+// findMatch moves to the next match after the current one, or the one
+// before it, wrapping around. Without a current match it starts from the
+// selected entry.
+func (m *QueryModel) findMatch(backward bool) {
+	s := m.search
+	matches := m.searchMatches(s.pattern)
+	if len(matches) == 0 {
+		s.current = nil
+		m.statusMsg = fmt.Sprintf("✗ Pattern not found: %s", s.pattern)
+		return
+	}
+
+	from := s.current
+	if from == nil {
+		// Start just before (or after) the selected entry so its own
+		// matches come first
+		start := searchMatch{entry: m.selectedEntry, field: -1}
+		if backward {
+			start = searchMatch{entry: m.selectedEntry + 1, field: -1}
+		}
+		from = &start
+	}
+	index, wrapped := -1, false
+	if backward {
+		for i := len(matches) - 1; i >= 0; i-- {
+			if matches[i].before(*from) {
+				index = i
+				break
+			}
+		}
+		if index < 0 {
+			index, wrapped = len(matches)-1, true
+		}
+	} else {
+		for i, match := range matches {
+			if from.before(match) {
+				index = i
+				break
+			}
+		}
+		if index < 0 {
+			index, wrapped = 0, true
+		}
+	}
+	if wrapped && s.current == nil {
+		wrapped = false
+	}
+
+	match := matches[index]
+	s.current = &match
+	s.scroll = true
+	m.showMatch(match)
+	m.statusMsg = fmt.Sprintf("🔎 Match %d of %d", index+1, len(matches))
+	if wrapped {
+		m.statusMsg += " (search wrapped around)"
+	}
+}
+
+// showMatch selects the entry of a match and reveals it: its SQL, or the
+// row and column of its cell
+func (m *QueryModel) showMatch(match searchMatch) {
+	m.selectedEntry = match.entry
+	entry := &m.history[match.entry]
+	switch match.field {
+	case searchSQL, searchEditedSQL:
+		entry.ShowSQL = true
+	case searchCell:
+		results := entry.Results
+		if match.entry == len(m.history)-1 && match.row >= m.visibleRows {
+			m.visibleRows = match.row + 1
+		}
+		widths := columnWidths(results)
+		if match.col >= m.frozenColumns(results) && match.col < results.colOffset {
+			results.colOffset = match.col
+		}
+		for {
+			visible, hiddenRight := m.visibleColumns(results, widths)
+			if !hiddenRight || len(visible) == 0 || match.col <= visible[len(visible)-1] {
+				break
+			}
+			results.colOffset = max(results.colOffset, m.frozenColumns(results)) + 1
+		}
+		if shown := m.displayedRows(results, match.entry == len(m.history)-1); match.row >= shown {
+			m.statusMsg = fmt.Sprintf("row %d is not shown here (o: pager)", match.row+1)
+		}
+	}
+}
+
+// searchLine returns the line of rendered entry i to scroll to for the
+// current match: its question, the box of its SQL or the row of its cell.
+// It is -1 when the current match is in another entry or not shown.
+func (m *QueryModel) searchLine(i int, entry *renderedEntry) int {
+	if m.search == nil || m.search.current == nil || m.search.current.entry != i {
+		return -1
+	}
+	c := m.search.current
+	line, ok := entry.marks[c.field]
+	if !ok {
+		return -1
+	}
+	if c.field == searchCell {
+		// Below the header and separator; rows not shown lead to the "more rows" line
+		line += 2 + min(c.row, m.displayedRows(m.history[i].Results, i == len(m.history)-1))
+	}
+	return line
+}
+
+// highlightMatches highlights every occurrence of the search pattern in
+// the lines shown, the current match's line in the accent colour. Lines
+// holding inline images are left alone. Plain output marks the current
+// match's line with > instead.
+func (m *QueryModel) highlightMatches(lines []string, current int) {
+	if m.search == nil || m.search.pattern == "" {
+		return
+	}
+	pattern := m.search.pattern
+	matchStyle := lipgloss.NewStyle().Foreground(ColorOnAccent).Background(ColorBlue)
+	currentStyle := lipgloss.NewStyle().Foreground(ColorOnAccent).Background(ColorOrange).Bold(true)
+	for i, line := range lines {
+		if strings.Contains(line, "\x1b_") || strings.Contains(line, "\x1bP") {
+			continue
+		}
+		plain := strings.ToLower(ansi.Strip(line))
+		var ranges []lipgloss.Range
+		for at := 0; ; {
+			n := strings.Index(plain[at:], pattern)
+			if n < 0 {
+				break
+			}
+			start := ansi.StringWidth(plain[:at+n])
+			end := start + ansi.StringWidth(pattern)
+			style := matchStyle
+			if i == current {
+				style = currentStyle
+			}
+			ranges = append(ranges, lipgloss.NewRange(start, end, style))
+			at += n + len(pattern)
+		}
+		if len(ranges) > 0 && plainOutput {
+			// Without colours only the current match's line is marked
+			if i == current && strings.HasPrefix(line, " ") {
+				lines[i] = ">" + line[1:]
+			}
+		} else if len(ranges) > 0 {
+			lines[i] = lipgloss.StyleRanges(line, ranges...)
+		}
+	}
+}
+
+// scrollToLine scrolls the conversation so line, counted from the top of
+// its content, shows a third of the way down
+func (m *QueryModel) scrollToLine(line int) {
+	total, visible := m.convScroll.totalLines, m.convScroll.visibleLines
+	start := max(line-visible/3, 0)
+	m.convScroll.scrollOffset = max(min(total-visible-start, total-visible), 0)
+}
+
+// renderSearchPrompt renders the pattern being typed
+func (m *QueryModel) renderSearchPrompt() string {
+	label := "🔎 Search conversation: /"
+	if m.search.backward {
+		label = "🔎 Search conversation backward: ?"
+	}
+	return PromptStyle.Render(label) + *m.search.prompt + "█"
+}
@@ -1,6 +1,9 @@
 package tui
 
 import (
+	"strings"
+
+	"github.com/atotto/clipboard"
 	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
@@ -16,18 +19,32 @@ type ServiceEditorModel struct {
 	focusedInput int
 	isNew        bool // true if creating new, false if editing
 	originalName string
+	options      map[string]string // Other connection parameters, kept as they are
 	error        string
+	status       string // Note of the last action, such as copying the URI
 }
 
 // Field indices
 const (
 	fieldName = iota
+	fieldTags
 	fieldHost
 	fieldPort
 	fieldDBName
 	fieldUser
 	fieldPassword
 	fieldSSLMode
+	fieldSSLCert
+	fieldSSLKey
+	fieldSSLRootCert
+	fieldSSHHost
+	fieldSSHUser
+	fieldSSHKey
+	fieldDefaultSchema
+	fieldHarvestInclude
+	fieldHarvestExclude
+	fieldDbtManifest
+	fieldGlossary
 )
 
 // serviceSavedMsg indicates service was saved
@@ -40,7 +57,7 @@ type serviceEditorCancelledMsg struct{}
 
 // NewServiceEditorModel creates a new service editor
 func NewServiceEditorModel(entry *postgres.ServiceEntry) *ServiceEditorModel {
-	inputs := make([]textinput.Model, 7)
+	inputs := make([]textinput.Model, 19)
 
 	// Service Name
 	inputs[fieldName] = textinput.New()
@@ -49,6 +66,13 @@ func NewServiceEditorModel(entry *postgres.ServiceEntry) *ServiceEditorModel {
 	inputs[fieldName].Width = 40
 	inputs[fieldName].Prompt = ""
 
+	// Tags, such as the environment, for grouping services (optional)
+	inputs[fieldTags] = textinput.New()
+	inputs[fieldTags].Placeholder = "prod, gis (optional)"
+	inputs[fieldTags].CharLimit = 100
+	inputs[fieldTags].Width = 40
+	inputs[fieldTags].Prompt = ""
+
 	// Host
 	inputs[fieldHost] = textinput.New()
 	inputs[fieldHost].Placeholder = "localhost"
@@ -92,19 +116,107 @@ func NewServiceEditorModel(entry *postgres.ServiceEntry) *ServiceEditorModel {
 	inputs[fieldSSLMode].Width = 40
 	inputs[fieldSSLMode].Prompt = ""
 
+	// TLS client certificate (optional)
+	inputs[fieldSSLCert] = textinput.New()
+	inputs[fieldSSLCert].Placeholder = "~/.postgresql/postgresql.crt (optional)"
+	inputs[fieldSSLCert].CharLimit = 200
+	inputs[fieldSSLCert].Width = 40
+	inputs[fieldSSLCert].Prompt = ""
+
+	inputs[fieldSSLKey] = textinput.New()
+	inputs[fieldSSLKey].Placeholder = "~/.postgresql/postgresql.key (optional)"
+	inputs[fieldSSLKey].CharLimit = 200
+	inputs[fieldSSLKey].Width = 40
+	inputs[fieldSSLKey].Prompt = ""
+
+	inputs[fieldSSLRootCert] = textinput.New()
+	inputs[fieldSSLRootCert].Placeholder = "CA for verify-ca/verify-full (optional)"
+	inputs[fieldSSLRootCert].CharLimit = 200
+	inputs[fieldSSLRootCert].Width = 40
+	inputs[fieldSSLRootCert].Prompt = ""
+
+	// SSH tunnel (optional)
+	inputs[fieldSSHHost] = textinput.New()
+	inputs[fieldSSHHost].Placeholder = "bastion.example.com:22 (optional)"
+	inputs[fieldSSHHost].CharLimit = 100
+	inputs[fieldSSHHost].Width = 40
+	inputs[fieldSSHHost].Prompt = ""
+
+	inputs[fieldSSHUser] = textinput.New()
+	inputs[fieldSSHUser].Placeholder = "current user"
+	inputs[fieldSSHUser].CharLimit = 100
+	inputs[fieldSSHUser].Width = 40
+	inputs[fieldSSHUser].Prompt = ""
+
+	inputs[fieldSSHKey] = textinput.New()
+	inputs[fieldSSHKey].Placeholder = "~/.ssh/id_ed25519 (or SSH agent)"
+	inputs[fieldSSHKey].CharLimit = 200
+	inputs[fieldSSHKey].Width = 40
+	inputs[fieldSSHKey].Prompt = ""
+
+	// Schema used when a table name exists in several schemas (optional)
+	inputs[fieldDefaultSchema] = textinput.New()
+	inputs[fieldDefaultSchema].Placeholder = "preferred when names clash (optional)"
+	inputs[fieldDefaultSchema].CharLimit = 63
+	inputs[fieldDefaultSchema].Width = 40
+	inputs[fieldDefaultSchema].Prompt = ""
+
+	// Glob patterns limiting which schemas and tables are harvested
+	inputs[fieldHarvestInclude] = textinput.New()
+	inputs[fieldHarvestInclude].Placeholder = "harvest only: public, gis.* (all when empty)"
+	inputs[fieldHarvestInclude].CharLimit = 500
+	inputs[fieldHarvestInclude].Width = 40
+	inputs[fieldHarvestInclude].Prompt = ""
+
+	inputs[fieldHarvestExclude] = textinput.New()
+	inputs[fieldHarvestExclude].Placeholder = "skip: audit, *.*_p20* (optional)"
+	inputs[fieldHarvestExclude].CharLimit = 500
+	inputs[fieldHarvestExclude].Width = 40
+	inputs[fieldHarvestExclude].Prompt = ""
+
+	// dbt manifest documenting the models (optional)
+	inputs[fieldDbtManifest] = textinput.New()
+	inputs[fieldDbtManifest].Placeholder = "~/project/target/manifest.json (optional)"
+	inputs[fieldDbtManifest].CharLimit = 500
+	inputs[fieldDbtManifest].Width = 40
+	inputs[fieldDbtManifest].Prompt = ""
+
+	// Glossary of synonyms used in questions (optional)
+	inputs[fieldGlossary] = textinput.New()
+	inputs[fieldGlossary].Placeholder = "~/project/glossary.yaml (optional)"
+	inputs[fieldGlossary].CharLimit = 500
+	inputs[fieldGlossary].Width = 40
+	inputs[fieldGlossary].Prompt = ""
+
 	isNew := entry == nil
 	originalName := ""
+	options := make(map[string]string)
 
 	// Pre-populate if editing
 	if entry != nil {
 		inputs[fieldName].SetValue(entry.Name)
+		inputs[fieldTags].SetValue(strings.Join(entry.Tags, ", "))
 		inputs[fieldHost].SetValue(entry.Host)
 		inputs[fieldPort].SetValue(entry.Port)
 		inputs[fieldDBName].SetValue(entry.DBName)
 		inputs[fieldUser].SetValue(entry.User)
 		inputs[fieldPassword].SetValue(entry.Password)
 		inputs[fieldSSLMode].SetValue(entry.SSLMode)
+		inputs[fieldSSLCert].SetValue(entry.SSLCert)
+		inputs[fieldSSLKey].SetValue(entry.SSLKey)
+		inputs[fieldSSLRootCert].SetValue(entry.SSLRootCert)
+		inputs[fieldSSHHost].SetValue(entry.SSHHost)
+		inputs[fieldSSHUser].SetValue(entry.SSHUser)
+		inputs[fieldSSHKey].SetValue(entry.SSHKey)
+		inputs[fieldDefaultSchema].SetValue(entry.DefaultSchema)
+		inputs[fieldHarvestInclude].SetValue(entry.HarvestInclude)
+		inputs[fieldHarvestExclude].SetValue(entry.HarvestExclude)
+		inputs[fieldDbtManifest].SetValue(entry.DbtManifest)
+		inputs[fieldGlossary].SetValue(entry.Glossary)
 		originalName = entry.Name
+		for k, v := range entry.Options {
+			options[k] = v
+		}
 	} else {
 		// Set defaults for new entry
 		inputs[fieldPort].SetValue("5432")
@@ -119,6 +231,7 @@ func NewServiceEditorModel(entry *postgres.ServiceEntry) *ServiceEditorModel {
 		focusedInput: fieldName,
 		isNew:        isNew,
 		originalName: originalName,
+		options:      options,
 	}
 }
 
@@ -137,8 +250,34 @@ func (m *ServiceEditorModel) Update(msg tea.Msg) (*ServiceEditorModel, tea.Cmd)
 		m.height = msg.Height
 		return m, nil
 
+	case clipboardCopiedMsg:
+		if msg.err != nil {
+			m.error = "Copy failed: " + msg.err.Error()
+		} else {
+			m.status = "✓ Copied the " + msg.what + " to the clipboard"
+		}
+		return m, nil
+
 	case tea.KeyMsg:
+		m.status = ""
+
+		// A pasted connection URI fills in the connection fields
+		if msg.Paste && postgres.IsConnectionURI(string(msg.Runes)) {
+			m.applyURI(string(msg.Runes))
+			return m, nil
+		}
+		if msg.String() == "ctrl+v" {
+			if text, err := clipboard.ReadAll(); err == nil && postgres.IsConnectionURI(text) {
+				m.applyURI(text)
+				return m, nil
+			}
+		}
+
 		switch {
+		case key.Matches(msg, key.NewBinding(key.WithKeys("ctrl+y"))):
+			entry := m.entry()
+			return m, copyToClipboard("connection URI", entry.URI())
+
 		case key.Matches(msg, key.NewBinding(key.WithKeys("esc"))):
 			return m, func() tea.Msg {
 				return serviceEditorCancelledMsg{}
@@ -166,16 +305,7 @@ func (m *ServiceEditorModel) Update(msg tea.Msg) (*ServiceEditorModel, tea.Cmd)
 				return m, nil
 			}
 
-			entry := postgres.ServiceEntry{
-				Name:     m.inputs[fieldName].Value(),
-				Host:     m.inputs[fieldHost].Value(),
-				Port:     m.inputs[fieldPort].Value(),
-				DBName:   m.inputs[fieldDBName].Value(),
-				User:     m.inputs[fieldUser].Value(),
-				Password: m.inputs[fieldPassword].Value(),
-				SSLMode:  m.inputs[fieldSSLMode].Value(),
-				Options:  make(map[string]string),
-			}
+			entry := m.entry()
 
 			// If editing and name changed, delete old entry first
 			if !m.isNew && m.originalName != entry.Name {
@@ -206,6 +336,65 @@ func (m *ServiceEditorModel) Update(msg tea.Msg) (*ServiceEditorModel, tea.Cmd)
 	return m, tea.Batch(cmds...)
 }
 
+// entry returns the service as entered in the form
+func (m *ServiceEditorModel) entry() postgres.ServiceEntry {
+	options := make(map[string]string, len(m.options))
+	for k, v := range m.options {
+		options[k] = v
+	}
+	return postgres.ServiceEntry{
+		Name:     m.inputs[fieldName].Value(),
+		Host:     m.inputs[fieldHost].Value(),
+		Port:     m.inputs[fieldPort].Value(),
+		DBName:   m.inputs[fieldDBName].Value(),
+		User:     m.inputs[fieldUser].Value(),
+		Password: m.inputs[fieldPassword].Value(),
+		SSLMode:  m.inputs[fieldSSLMode].Value(),
+		SSHHost:  m.inputs[fieldSSHHost].Value(),
+		SSHUser:  m.inputs[fieldSSHUser].Value(),
+		SSHKey:   m.inputs[fieldSSHKey].Value(),
+		Options:  options,
+
+		Tags:           postgres.ParseTags(m.inputs[fieldTags].Value()),
+		SSLCert:        m.inputs[fieldSSLCert].Value(),
+		SSLKey:         m.inputs[fieldSSLKey].Value(),
+		SSLRootCert:    m.inputs[fieldSSLRootCert].Value(),
+		DefaultSchema:  m.inputs[fieldDefaultSchema].Value(),
+		HarvestInclude: m.inputs[fieldHarvestInclude].Value(),
+		HarvestExclude: m.inputs[fieldHarvestExclude].Value(),
+		DbtManifest:    m.inputs[fieldDbtManifest].Value(),
+		Glossary:       m.inputs[fieldGlossary].Value(),
+	}
+}
+
+// applyURI fills in the connection fields from a connection URI, naming
+// the service after its database when it has no name yet. Other
+// parameters of the URI replace the service's options.
+func (m *ServiceEditorModel) applyURI(uri string) {
+	entry, err := postgres.ParseConnectionURI(uri)
+	if err != nil {
+		m.error = err.Error()
+		return
+	}
+	m.inputs[fieldHost].SetValue(entry.Host)
+	m.inputs[fieldPort].SetValue(entry.Port)
+	m.inputs[fieldDBName].SetValue(entry.DBName)
+	m.inputs[fieldUser].SetValue(entry.User)
+	m.inputs[fieldPassword].SetValue(entry.Password)
+	if entry.SSLMode != "" {
+		m.inputs[fieldSSLMode].SetValue(entry.SSLMode)
+	}
+	m.inputs[fieldSSLCert].SetValue(entry.SSLCert)
+	m.inputs[fieldSSLKey].SetValue(entry.SSLKey)
+	m.inputs[fieldSSLRootCert].SetValue(entry.SSLRootCert)
+	if m.inputs[fieldName].Value() == "" {
+		m.inputs[fieldName].SetValue(entry.DBName)
+	}
+	m.options = entry.Options
+	m.error = ""
+	m.status = "✓ Filled in from the connection URI"
+}
+
 func (m *ServiceEditorModel) nextInput() {
 	m.inputs[m.focusedInput].Blur()
 	m.focusedInput = (m.focusedInput + 1) % len(m.inputs)
@@ -235,7 +424,7 @@ func (m *ServiceEditorModel) View() string {
 	}
 	header := RenderHeader(title)
 	content := m.renderContent()
-	helpText := "tab/↓: next • shift+tab/↑: prev • ctrl+s/enter: save • esc: cancel"
+	helpText := "tab/↓: next • shift+tab/↑: prev • ctrl+s/enter: save • ctrl+y: copy as URI • esc: cancel"
 	footer := RenderHelpFooter(helpText, m.width)
 
 	return LayoutWithHeaderFooter(header, content, footer, m.width, m.height)
@@ -264,6 +453,9 @@ func (m *ServiceEditorModel) renderContent() string {
 			Align(lipgloss.Center)
 		sections = append(sections, errorBox.Render(ErrorStyle.Render("Error: "+m.error)))
 		sections = append(sections, "")
+	} else if m.status != "" {
+		sections = append(sections, SuccessStyle.Render(m.status))
+		sections = append(sections, "")
 	}
 
 	// Form fields
@@ -292,12 +484,24 @@ func (m *ServiceEditorModel) renderContent() string {
 
 	labels := []string{
 		"Service Name:",
+		"Tags:",
 		"Host:",
 		"Port:",
 		"Database:",
 		"User:",
 		"Password:",
 		"SSL Mode:",
+		"SSL Cert:",
+		"SSL Key:",
+		"SSL Root CA:",
+		"SSH Host:",
+		"SSH User:",
+		"SSH Key:",
+		"Schema:",
+		"Include:",
+		"Exclude:",
+		"dbt Manifest:",
+		"Glossary:",
 	}
 
 	for i, label := range labels {
@@ -323,6 +527,11 @@ func (m *ServiceEditorModel) renderContent() string {
 		Italic(true).
 		Align(lipgloss.Center)
 	sections = append(sections, hintStyle.Render("SSL modes: disable, allow, prefer, require, verify-ca, verify-full"))
+	sections = append(sections, hintStyle.Render("Certificate paths may start with ~; the client certificate defaults to ~/.postgresql/postgresql.crt"))
+	sections = append(sections, hintStyle.Render("With an SSH host, Host and Port are resolved on the bastion"))
+	sections = append(sections, hintStyle.Render("A dbt manifest.json adds model and column docs to the schema when it is harvested"))
+	sections = append(sections, hintStyle.Render("A glossary maps words of questions to the schema's, one \"client: customer\" per line"))
+	sections = append(sections, hintStyle.Render("Paste a postgresql:// URI into any field to fill in the connection"))
 
 	return lipgloss.JoinVertical(lipgloss.Center, sections...)
 }
@@ -0,0 +1,175 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/kartoza/kartoza-pg-ai/internal/config"
+)
+
+// maxSessionNameLen bounds the length of a session name
+const maxSessionNameLen = 24
+
+// querySession is the state of one named conversation on the query screen.
+// Only the active session's state lives in QueryModel; the others are
+// stashed here so their history, scroll state and LLM context stay separate.
+type querySession struct {
+	name          string
+	history       []ConversationEntry
+	results       *QueryResults
+	selectedEntry int
+	convScroll    ConversationScrollState
+	scrollOffset  int
+	visibleRows   int
+	totalFetched  int
+	hasMoreRows   bool
+}
+
+// newQuerySession creates an empty session
+func newQuerySession(name string) *querySession {
+	return &querySession{
+		name:          name,
+		selectedEntry: -1,
+		visibleRows:   defaultVisibleRows,
+	}
+}
+
+// sessionName returns the name of the active session
+func (m *QueryModel) sessionName() string {
+	if m.activeSession < len(m.sessions) {
+		return m.sessions[m.activeSession].name
+	}
+	return config.DefaultSession
+}
+
+// stashSession copies the active conversation state into its session
+func (m *QueryModel) stashSession() {
+	s := m.sessions[m.activeSession]
+	s.history = m.history
+	s.results = m.results
+	s.selectedEntry = m.selectedEntry
+	s.convScroll = m.convScroll
+	s.scrollOffset = m.scrollOffset
+	s.visibleRows = m.visibleRows
+	s.totalFetched = m.totalFetched
+	s.hasMoreRows = m.hasMoreRows
+}
+
+// activateSession makes session i active, restoring its conversation state
+func (m *QueryModel) activateSession(i int) {
+	m.activeSession = i
+	s := m.sessions[i]
+	m.history = s.history
+	m.results = s.results
+	m.selectedEntry = s.selectedEntry
+	m.convScroll = s.convScroll
+	m.scrollOffset = s.scrollOffset
+	m.visibleRows = s.visibleRows
+	m.totalFetched = s.totalFetched
+	m.hasMoreRows = s.hasMoreRows
+	m.search = nil
+	m.error = ""
+	m.statusMsg = ""
+}
+
+// canSwitchSession reports whether the active session can be left. A query
+// or row fetch in flight delivers its results to whichever session is active,
+// and row and column pickers point into the active conversation.
+func (m *QueryModel) canSwitchSession() bool {
+	return !m.loading && !m.fetchingMore && m.sqlEdit == nil && m.rowSelect == nil && m.colSelect == nil && m.diffFrom == nil
+}
+
+// switchSession moves delta sessions forward (or back), wrapping around
+func (m *QueryModel) switchSession(delta int) {
+	if len(m.sessions) < 2 || !m.canSwitchSession() {
+		return
+	}
+	m.stashSession()
+	n := len(m.sessions)
+	m.activateSession(((m.activeSession+delta)%n + n) % n)
+}
+
+// newSession stashes the active session and starts an empty one. A name
+// already in use switches to that session instead.
+func (m *QueryModel) newSession(name string) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = fmt.Sprintf("session %d", len(m.sessions)+1)
+	}
+	m.stashSession()
+	for i, s := range m.sessions {
+		if strings.EqualFold(s.name, name) {
+			m.activateSession(i)
+			return
+		}
+	}
+	m.sessions = append(m.sessions, newQuerySession(name))
+	m.activateSession(len(m.sessions) - 1)
+}
+
+// closeSession discards the active session and its saved conversation. The
+// last remaining session cannot be closed.
+func (m *QueryModel) closeSession() {
+	if len(m.sessions) < 2 || !m.canSwitchSession() {
+		return
+	}
+	name := m.sessionName()
+	m.results.closeCursor()
+	for _, entry := range m.history {
+		entry.Results.closeCursor()
+	}
+	if m.service != nil {
+		// Saving an empty conversation removes its file
+		if err := config.SaveConversation(&config.Conversation{ServiceName: m.service.Name, Session: name}); err != nil {
+			debugLog("closeSession: " + err.Error())
+		}
+	}
+
+	m.sessions = append(m.sessions[:m.activeSession], m.sessions[m.activeSession+1:]...)
+	m.activateSession(max(m.activeSession-1, 0))
+	m.statusMsg = fmt.Sprintf("✓ Closed session %q", name)
+}
+
+// handleSessionPromptKey edits the name of a new session while its prompt is open
+func (m *QueryModel) handleSessionPromptKey(msg tea.KeyMsg) (*QueryModel, tea.Cmd) {
+	switch msg.Type {
+	case tea.KeyEsc:
+		m.sessionPrompt = nil
+	case tea.KeyEnter:
+		name := *m.sessionPrompt
+		m.sessionPrompt = nil
+		m.newSession(name)
+	case tea.KeyBackspace:
+		if r := []rune(*m.sessionPrompt); len(r) > 0 {
+			*m.sessionPrompt = string(r[:len(r)-1])
+		}
+	case tea.KeyRunes, tea.KeySpace:
+		if len([]rune(*m.sessionPrompt)) < maxSessionNameLen {
+			*m.sessionPrompt += string(msg.Runes)
+		}
+	}
+	return m, nil
+}
+
+// renderSessionTabs renders the session list, highlighting the active one.
+// Nothing is shown while only one session exists.
+func (m *QueryModel) renderSessionTabs() string {
+	if len(m.sessions) < 2 {
+		return ""
+	}
+	activeStyle := lipgloss.NewStyle().Foreground(ColorOnAccent).Background(ColorOrange).Bold(true).Padding(0, 1)
+	inactiveStyle := lipgloss.NewStyle().Foreground(ColorGray).Padding(0, 1)
+
+	var tabs []string
+	for i, s := range m.sessions {
+		label := fmt.Sprintf("%d %s", i+1, s.name)
+		if i == m.activeSession {
+			tabs = append(tabs, activeStyle.Render(label))
+		} else {
+			tabs = append(tabs, inactiveStyle.Render(label))
+		}
+	}
+	return lipgloss.NewStyle().MaxWidth(m.width - 6).Render(strings.Join(tabs, " "))
+}
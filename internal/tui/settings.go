@@ -2,20 +2,290 @@ package tui
 
 import (
 	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/charmbracelet/bubbles/key"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/kartoza/kartoza-pg-ai/internal/config"
+	"github.com/kartoza/kartoza-pg-ai/internal/llm"
+	"github.com/kartoza/kartoza-pg-ai/internal/logging"
+	"github.com/kartoza/kartoza-pg-ai/internal/tracing"
 )
 
+// llmProviderOptions lists the providers the LLM Provider setting cycles through
+var llmProviderOptions = []string{llm.ProviderRules, llm.ProviderOpenAI, llm.ProviderOllama, llm.ProviderLlamaCpp, llm.ProviderClaude}
+
+// queryGeneratorOptions are what can answer questions by default
+var queryGeneratorOptions = []string{llm.GeneratorAuto, llm.ProviderRules, llm.GeneratorNN, llm.ProviderOllama, llm.ProviderLlamaCpp, llm.ProviderOpenAI, llm.ProviderClaude}
+
+// activeQueryGenerator returns what answers questions by default, treating empty as automatic
+func activeQueryGenerator(c *config.Config) string {
+	if c.Settings.QueryGenerator == "" {
+		return llm.GeneratorAuto
+	}
+	return c.Settings.QueryGenerator
+}
+
+// activeProviderName returns the configured provider, treating empty as the rule engine
+func activeProviderName(c *config.Config) string {
+	if c.Settings.LLMProvider == "" {
+		return llm.ProviderRules
+	}
+	return c.Settings.LLMProvider
+}
+
+// logDirHint returns where the log is written, for setting descriptions
+func logDirHint() string {
+	if dir, err := config.LogDir(); err == nil {
+		return dir
+	}
+	return "the logs directory"
+}
+
+// cacheDirHint returns where caches are kept, for setting descriptions
+func cacheDirHint() string {
+	if dir, err := config.CacheDir(); err == nil {
+		return dir
+	}
+	return "the cache directory"
+}
+
+// nextCacheLimit returns the geometry cache limit in MB following current;
+// 0 is unlimited
+func nextCacheLimit(current int) int {
+	limits := []int{64, 128, 256, 512, 1024, 0}
+	for i, limit := range limits {
+		if limit == current {
+			return limits[(i+1)%len(limits)]
+		}
+	}
+	return limits[0]
+}
+
+// embeddingProviderOptions lists the embedders the Semantic Search setting cycles through
+var embeddingProviderOptions = []string{llm.EmbeddingNone, llm.ProviderOllama, llm.ProviderOpenAI}
+
+// activeEmbeddingProvider returns the configured embedder, treating empty as none
+func activeEmbeddingProvider(c *config.Config) string {
+	if c.Settings.EmbeddingProvider == "" {
+		return llm.EmbeddingNone
+	}
+	return c.Settings.EmbeddingProvider
+}
+
+// geometryColors are the colours the geometry style settings cycle through;
+// any other hex colour can be set in config.json
+var geometryColors = []struct{ name, hex string }{
+	{"orange", "#ffa500"},
+	{"cyan", "#00c8ff"},
+	{"green", "#78dc5a"},
+	{"magenta", "#e65ac8"},
+	{"yellow", "#fae650"},
+	{"red", "#e64b3c"},
+	{"blue", "#3c78e6"},
+	{"white", "#ffffff"},
+	{"dark gray", "#1e1e1e"},
+	{"black", "#000000"},
+}
+
+// colorName returns the palette name of a hex colour, or the hex itself
+func colorName(hex string) string {
+	for _, c := range geometryColors {
+		if strings.EqualFold(c.hex, hex) {
+			return c.name
+		}
+	}
+	return hex
+}
+
+// nextColor returns the palette colour following hex, wrapping around
+func nextColor(hex string) string {
+	for i, c := range geometryColors {
+		if strings.EqualFold(c.hex, hex) {
+			return geometryColors[(i+1)%len(geometryColors)].hex
+		}
+	}
+	return geometryColors[0].hex
+}
+
+// nextSize returns the size following current in sizes, wrapping around
+func nextSize(sizes []float64, current float64) float64 {
+	for _, size := range sizes {
+		if size > current {
+			return size
+		}
+	}
+	return sizes[0]
+}
+
+// nextOption returns the option following current, wrapping around
+func nextOption(options []string, current string) string {
+	for i, opt := range options {
+		if opt == current {
+			return options[(i+1)%len(options)]
+		}
+	}
+	return options[0]
+}
+
+// profileRowLimits are the row limits a service profile cycles through; 0
+// keeps the global limit
+var profileRowLimits = []int{0, 10, 50, 100, 500, 1000}
+
+// statementTimeouts are the statement timeouts in seconds the settings
+// cycle through; 0 leaves statements unlimited
+var statementTimeouts = []int{15, 30, 60, 120, 300, 0}
+
+// profileStatementTimeouts are the timeouts a service profile cycles
+// through; 0 keeps the global timeout and -1 leaves statements unlimited
+var profileStatementTimeouts = []int{0, 5, 15, 30, 60, 300, 900, -1}
+
+// formatTimeout describes a statement timeout in seconds
+func formatTimeout(seconds int) string {
+	if seconds <= 0 {
+		return "No limit"
+	}
+	return (time.Duration(seconds) * time.Second).String()
+}
+
+// nextInt returns the value following current in values, wrapping around
+func nextInt(values []int, current int) int {
+	for i, v := range values {
+		if v == current && i+1 < len(values) {
+			return values[i+1]
+		}
+	}
+	return values[0]
+}
+
+// promptTokenBudgets are the schema token budgets the settings cycle
+// through; 0 sends the whole schema
+var promptTokenBudgets = []int{4000, 8000, 12000, 24000, 48000, 0}
+
+// serviceProfileItems returns the settings overriding the global ones for
+// a service; they apply the next time the service is opened
+func serviceProfileItems(service string) []SettingItem {
+	update := func(c *config.Config, change func(p *config.ServiceProfile)) {
+		p := c.Profile(service)
+		change(&p)
+		c.SetProfile(service, p)
+	}
+	global := func(value string) string {
+		return "global (" + value + ")"
+	}
+
+	return []SettingItem{
+		{
+			Name:        "Row Limit: " + service,
+			Description: "LIMIT of generated queries on this service",
+			Type:        "toggle",
+			GetValue: func(c *config.Config) string {
+				if limit := c.Profile(service).DefaultRowLimit; limit > 0 {
+					return strconv.Itoa(limit)
+				}
+				return global(strconv.Itoa(c.Settings.DefaultRowLimit))
+			},
+			Toggle: func(c *config.Config) {
+				update(c, func(p *config.ServiceProfile) {
+					next := profileRowLimits[0]
+					for i, limit := range profileRowLimits {
+						if limit == p.DefaultRowLimit && i+1 < len(profileRowLimits) {
+							next = profileRowLimits[i+1]
+						}
+					}
+					p.DefaultRowLimit = next
+				})
+			},
+		},
+		{
+			Name:        "Write Mode: " + service,
+			Description: "Allow or forbid writes on this service whatever the global Write Mode",
+			Type:        "toggle",
+			GetValue: func(c *config.Config) string {
+				value := func(enabled bool) string {
+					if enabled {
+						return "Enabled"
+					}
+					return "Read-only"
+				}
+				if enabled := c.Profile(service).WriteModeEnabled; enabled != nil {
+					return value(*enabled)
+				}
+				return global(value(c.Settings.WriteModeEnabled))
+			},
+			Toggle: func(c *config.Config) {
+				update(c, func(p *config.ServiceProfile) {
+					switch {
+					case p.WriteModeEnabled == nil:
+						enabled := true
+						p.WriteModeEnabled = &enabled
+					case *p.WriteModeEnabled:
+						enabled := false
+						p.WriteModeEnabled = &enabled
+					default:
+						p.WriteModeEnabled = nil
+					}
+				})
+			},
+		},
+		{
+			Name:        "Statement Timeout: " + service,
+			Description: "How long a statement may run on this service before PostgreSQL cancels it",
+			Type:        "toggle",
+			GetValue: func(c *config.Config) string {
+				if timeout := c.Profile(service).StatementTimeoutSec; timeout != 0 {
+					return formatTimeout(timeout)
+				}
+				return global(formatTimeout(c.Settings.StatementTimeoutSec))
+			},
+			Toggle: func(c *config.Config) {
+				update(c, func(p *config.ServiceProfile) {
+					p.StatementTimeoutSec = nextInt(profileStatementTimeouts, p.StatementTimeoutSec)
+				})
+			},
+		},
+		{
+			Name:        "LLM Provider: " + service,
+			Description: "Backend for SQL generation on this service",
+			Type:        "toggle",
+			GetValue: func(c *config.Config) string {
+				if provider := c.Profile(service).LLMProvider; provider != "" {
+					return provider
+				}
+				return global(activeProviderName(c))
+			},
+			Toggle: func(c *config.Config) {
+				update(c, func(p *config.ServiceProfile) {
+					p.LLMProvider = nextOption(append([]string{""}, llmProviderOptions...), p.LLMProvider)
+				})
+			},
+		},
+		{
+			Name:        "Schemas: " + service,
+			Description: "Schemas questions on this service are answered from (service_profiles in config.json)",
+			Type:        "display",
+			GetValue: func(c *config.Config) string {
+				if schemas := c.Profile(service).Schemas; len(schemas) > 0 {
+					return strings.Join(schemas, ", ")
+				}
+				return "All"
+			},
+		},
+	}
+}
+
 // SettingItem represents a single setting
 type SettingItem struct {
 	Name        string
 	Description string
-	Type        string // "toggle", "number", "text"
+	Type        string // "toggle", "action", "number", "text"
 	GetValue    func(*config.Config) string
 	Toggle      func(*config.Config) // For toggle types
+	Action      tea.Cmd              // For action types
 }
 
 // SettingsModel represents the settings screen
@@ -30,6 +300,9 @@ type SettingsModel struct {
 // settingsChangedMsg indicates settings were changed
 type settingsChangedMsg struct{}
 
+// clearCachesMsg asks the settings screen to clear the caches
+type clearCachesMsg struct{}
+
 // NewSettingsModel creates a new settings model
 func NewSettingsModel(cfg *config.Config) *SettingsModel {
 	items := []SettingItem{
@@ -75,6 +348,324 @@ func NewSettingsModel(cfg *config.Config) *SettingsModel {
 				c.Settings.NeuralNetEnabled = !c.Settings.NeuralNetEnabled
 			},
 		},
+		{
+			Name:        "Write Mode",
+			Description: "Allow INSERT/UPDATE/DELETE/DDL (each statement asks for confirmation)",
+			Type:        "toggle",
+			GetValue: func(c *config.Config) string {
+				if c.Settings.WriteModeEnabled {
+					return "Enabled"
+				}
+				return "Read-only"
+			},
+			Toggle: func(c *config.Config) {
+				c.Settings.WriteModeEnabled = !c.Settings.WriteModeEnabled
+			},
+		},
+		{
+			Name:        "Column Statistics",
+			Description: "Harvest pg_stats values so questions can match real column values (sent to the LLM)",
+			Type:        "toggle",
+			GetValue: func(c *config.Config) string {
+				if c.Settings.HarvestStats {
+					return "Enabled"
+				}
+				return "Disabled"
+			},
+			Toggle: func(c *config.Config) {
+				c.Settings.HarvestStats = !c.Settings.HarvestStats
+			},
+		},
+		{
+			Name:        "Encrypt at Rest",
+			Description: "Encrypt config.json (cached schemas, API keys), geometry images, conversations, snapshots and drafts with AES-GCM. The query history database is NOT encrypted",
+			Type:        "toggle",
+			GetValue: func(c *config.Config) string {
+				active, _ := config.EncryptionActive()
+				switch {
+				case c.Settings.EncryptAtRest && active:
+					return "Enabled"
+				case c.Settings.EncryptAtRest:
+					return "Enabled at next start"
+				}
+				return "Disabled"
+			},
+			Toggle: func(c *config.Config) {
+				c.Settings.EncryptAtRest = !c.Settings.EncryptAtRest
+			},
+		},
+		{
+			Name:        "Encryption Key",
+			Description: "Where the key is kept when encryption is turned on: the OS keyring, or a passphrase asked for at startup (KARTOZA_PG_AI_PASSPHRASE for serve)",
+			Type:        "toggle",
+			GetValue: func(c *config.Config) string {
+				if c.Settings.EncryptionKeySource == config.KeySourcePassphrase {
+					return config.KeySourcePassphrase
+				}
+				return config.KeySourceKeyring
+			},
+			Toggle: func(c *config.Config) {
+				if c.Settings.EncryptionKeySource == config.KeySourcePassphrase {
+					c.Settings.EncryptionKeySource = config.KeySourceKeyring
+				} else {
+					c.Settings.EncryptionKeySource = config.KeySourcePassphrase
+				}
+			},
+		},
+		{
+			Name:        "Freeze First Column",
+			Description: "Keep the first result column (usually an ID) visible when scrolling tables sideways",
+			Type:        "toggle",
+			GetValue: func(c *config.Config) string {
+				if c.Settings.FreezeFirstColumn {
+					return "Enabled"
+				}
+				return "Disabled"
+			},
+			Toggle: func(c *config.Config) {
+				c.Settings.FreezeFirstColumn = !c.Settings.FreezeFirstColumn
+			},
+		},
+		{
+			Name:        "Date Style",
+			Description: "How dates and times in results are shown",
+			Type:        "toggle",
+			GetValue: func(c *config.Config) string {
+				return dateStyleNames[c.Settings.DateStyle]
+			},
+			Toggle: func(c *config.Config) {
+				c.Settings.DateStyle = nextOption(dateStyles, c.Settings.DateStyle)
+			},
+		},
+		{
+			Name:        "Connect Last Service",
+			Description: "Connect to the last used service on startup instead of showing the menu",
+			Type:        "toggle",
+			GetValue: func(c *config.Config) string {
+				if c.Settings.ConnectLastService {
+					return "Enabled"
+				}
+				return "Disabled"
+			},
+			Toggle: func(c *config.Config) {
+				c.Settings.ConnectLastService = !c.Settings.ConnectLastService
+			},
+		},
+		{
+			Name:        "Plain Output",
+			Description: "Plain ASCII text without colours, box drawing or emoji, for screen readers and logs (also --plain)",
+			Type:        "toggle",
+			GetValue: func(c *config.Config) string {
+				if c.Settings.PlainOutput {
+					return "Enabled"
+				}
+				return "Disabled"
+			},
+			Toggle: func(c *config.Config) {
+				c.Settings.PlainOutput = !c.Settings.PlainOutput
+			},
+		},
+		{
+			Name:        "Theme",
+			Description: "Interface colours; also sets the geometry colours. Add palettes under \"themes\" in config.json",
+			Type:        "toggle",
+			GetValue: func(c *config.Config) string {
+				if _, ok := builtinThemes[c.Settings.Theme]; ok {
+					return c.Settings.Theme
+				}
+				if _, ok := c.Themes[c.Settings.Theme]; ok {
+					return c.Settings.Theme
+				}
+				return DefaultTheme
+			},
+			Toggle: func(c *config.Config) {
+				c.Settings.Theme = nextOption(ThemeNames(c), c.Settings.Theme)
+				UseThemeGeometry(c)
+			},
+		},
+		{
+			Name:        "Geometry Stroke",
+			Description: "Outline width of geometry previews, maps and report images",
+			Type:        "toggle",
+			GetValue: func(c *config.Config) string {
+				return strconv.FormatFloat(c.Settings.GeometryStrokeWidth, 'g', -1, 64) + " px"
+			},
+			Toggle: func(c *config.Config) {
+				c.Settings.GeometryStrokeWidth = nextSize([]float64{1, 1.5, 2, 3, 4}, c.Settings.GeometryStrokeWidth)
+			},
+		},
+		{
+			Name:        "Geometry Point Size",
+			Description: "Radius of points in geometry previews, maps and report images",
+			Type:        "toggle",
+			GetValue: func(c *config.Config) string {
+				return strconv.FormatFloat(c.Settings.GeometryPointSize, 'g', -1, 64) + " px"
+			},
+			Toggle: func(c *config.Config) {
+				c.Settings.GeometryPointSize = nextSize([]float64{2, 3, 4, 6, 8}, c.Settings.GeometryPointSize)
+			},
+		},
+		{
+			Name:        "Geometry Line Colour",
+			Description: "Colour of lines and polygon outlines",
+			Type:        "toggle",
+			GetValue: func(c *config.Config) string {
+				return colorName(c.Settings.GeometryLineColor)
+			},
+			Toggle: func(c *config.Config) {
+				c.Settings.GeometryLineColor = nextColor(c.Settings.GeometryLineColor)
+			},
+		},
+		{
+			Name:        "Geometry Fill Colour",
+			Description: "Colour of polygon fills (opacity is geometry_fill_opacity in config.json)",
+			Type:        "toggle",
+			GetValue: func(c *config.Config) string {
+				return colorName(c.Settings.GeometryFillColor)
+			},
+			Toggle: func(c *config.Config) {
+				c.Settings.GeometryFillColor = nextColor(c.Settings.GeometryFillColor)
+			},
+		},
+		{
+			Name:        "Geometry Point Colour",
+			Description: "Colour of points",
+			Type:        "toggle",
+			GetValue: func(c *config.Config) string {
+				return colorName(c.Settings.GeometryPointColor)
+			},
+			Toggle: func(c *config.Config) {
+				c.Settings.GeometryPointColor = nextColor(c.Settings.GeometryPointColor)
+			},
+		},
+		{
+			Name:        "Geometry Background",
+			Description: "Background of geometry images; white suits printed reports",
+			Type:        "toggle",
+			GetValue: func(c *config.Config) string {
+				return colorName(c.Settings.GeometryBackground)
+			},
+			Toggle: func(c *config.Config) {
+				c.Settings.GeometryBackground = nextColor(c.Settings.GeometryBackground)
+			},
+		},
+		{
+			Name:        "Geometry Image Cache",
+			Description: "Space kept for geometry images; the least recently viewed are removed beyond it",
+			Type:        "toggle",
+			GetValue: func(c *config.Config) string {
+				used, _ := config.DiskUsage()
+				limit := "unlimited"
+				if c.Settings.GeometryCacheMB > 0 {
+					limit = fmt.Sprintf("%d MB", c.Settings.GeometryCacheMB)
+				}
+				return fmt.Sprintf("%s (%s used)", limit, config.HumanBytes(used.GeometryImages))
+			},
+			Toggle: func(c *config.Config) {
+				c.Settings.GeometryCacheMB = nextCacheLimit(c.Settings.GeometryCacheMB)
+				config.PruneGeometryImages(int64(c.Settings.GeometryCacheMB) << 20)
+			},
+		},
+		{
+			Name:        "Clear Caches",
+			Description: "Remove cached schemas (harvested again when next used), geometry images and embeddings from " + cacheDirHint(),
+			Type:        "action",
+			GetValue: func(c *config.Config) string {
+				used, _ := config.DiskUsage()
+				return fmt.Sprintf("%s in schemas, images and embeddings; %s in models",
+					config.HumanBytes(used.Schemas+used.GeometryImages+used.Embeddings), config.HumanBytes(used.NNModel+used.Models))
+			},
+			Action: func() tea.Msg {
+				return clearCachesMsg{}
+			},
+		},
+		{
+			Name:        "LLM Provider",
+			Description: "Backend for SQL generation (openai/claude need an API key, ollama and llamacpp a local server)",
+			Type:        "toggle",
+			GetValue: func(c *config.Config) string {
+				return activeProviderName(c)
+			},
+			Toggle: func(c *config.Config) {
+				c.Settings.LLMProvider = nextOption(llmProviderOptions, activeProviderName(c))
+			},
+		},
+		{
+			Name:        "Question Engine",
+			Description: "What answers questions when the query screen opens (F2 there switches it per question; auto tries the LLM provider, neural network, then rules)",
+			Type:        "toggle",
+			GetValue: func(c *config.Config) string {
+				return activeQueryGenerator(c)
+			},
+			Toggle: func(c *config.Config) {
+				c.Settings.QueryGenerator = nextOption(queryGeneratorOptions, activeQueryGenerator(c))
+			},
+		},
+		{
+			Name:        "Ollama Model",
+			Description: "Local model and server used by the ollama provider (edit config.json to change)",
+			Type:        "display",
+			GetValue: func(c *config.Config) string {
+				return fmt.Sprintf("%s @ %s", c.Settings.OllamaModel, c.Settings.OllamaBaseURL)
+			},
+		},
+		{
+			Name:        "llama.cpp Model",
+			Description: "GGUF model the llamacpp provider's llama-server runs (llamacpp_base_url in config.json sets its address)",
+			Type:        "display",
+			GetValue: func(c *config.Config) string {
+				if c.Settings.LLMModelPath == "" {
+					return "None"
+				}
+				return filepath.Base(c.Settings.LLMModelPath)
+			},
+		},
+		{
+			Name:        "Download Models",
+			Description: "Download a recommended local model for Ollama or llama.cpp and make it the LLM provider",
+			Type:        "action",
+			GetValue: func(c *config.Config) string {
+				return fmt.Sprintf("%d recommended", len(llm.RecommendedModels))
+			},
+			Action: func() tea.Msg {
+				return goToModelsMsg{}
+			},
+		},
+		{
+			Name:        "Semantic Search",
+			Description: "Embeddings matching questions to tables by meaning; embedding_model in config.json picks the model",
+			Type:        "toggle",
+			GetValue: func(c *config.Config) string {
+				return activeEmbeddingProvider(c)
+			},
+			Toggle: func(c *config.Config) {
+				c.Settings.EmbeddingProvider = nextOption(embeddingProviderOptions, activeEmbeddingProvider(c))
+			},
+		},
+		{
+			Name:        "Prompt Schema Budget",
+			Description: "Estimated tokens of schema sent to the LLM; larger schemas send only the most relevant tables (prompt_max_tables in config.json caps the count)",
+			Type:        "toggle",
+			GetValue: func(c *config.Config) string {
+				budget := "unlimited"
+				if c.Settings.PromptTokenBudget > 0 {
+					budget = fmt.Sprintf("%d tokens", c.Settings.PromptTokenBudget)
+				}
+				if c.Settings.PromptMaxTables > 0 {
+					budget += fmt.Sprintf(", %d tables", c.Settings.PromptMaxTables)
+				}
+				return budget
+			},
+			Toggle: func(c *config.Config) {
+				next := promptTokenBudgets[0]
+				for i, budget := range promptTokenBudgets {
+					if budget == c.Settings.PromptTokenBudget && i+1 < len(promptTokenBudgets) {
+						next = promptTokenBudgets[i+1]
+					}
+				}
+				c.Settings.PromptTokenBudget = next
+			},
+		},
 		{
 			Name:        "Max History Size",
 			Description: "Maximum number of queries to keep in history",
@@ -85,12 +676,37 @@ func NewSettingsModel(cfg *config.Config) *SettingsModel {
 		},
 		{
 			Name:        "Default Row Limit",
-			Description: "Default number of rows to fetch per page",
+			Description: "LIMIT of generated queries (edit config.json to change)",
 			Type:        "display",
 			GetValue: func(c *config.Config) string {
 				return fmt.Sprintf("%d", c.Settings.DefaultRowLimit)
 			},
 		},
+		{
+			Name:        "Auto LIMIT",
+			Description: "Add the row limit to generated queries listing rows without a LIMIT or FETCH FIRST of their own",
+			Type:        "toggle",
+			GetValue: func(c *config.Config) string {
+				if c.Settings.AutoLimit {
+					return "Enabled"
+				}
+				return "Disabled"
+			},
+			Toggle: func(c *config.Config) {
+				c.Settings.AutoLimit = !c.Settings.AutoLimit
+			},
+		},
+		{
+			Name:        "Statement Timeout",
+			Description: "How long a statement may run before PostgreSQL cancels it (SET LOCAL statement_timeout)",
+			Type:        "toggle",
+			GetValue: func(c *config.Config) string {
+				return formatTimeout(c.Settings.StatementTimeoutSec)
+			},
+			Toggle: func(c *config.Config) {
+				c.Settings.StatementTimeoutSec = nextInt(statementTimeouts, c.Settings.StatementTimeoutSec)
+			},
+		},
 		{
 			Name:        "Schema Cache",
 			Description: "Schema is cached until manually refreshed (R on connections screen)",
@@ -100,18 +716,81 @@ func NewSettingsModel(cfg *config.Config) *SettingsModel {
 			},
 		},
 		{
-			Name:        "NN Training Status",
-			Description: "Train NN model (needs 10+ queries in history)",
+			Name:        "Connection Pool",
+			Description: "Shared connections per service and health check interval (edit config.json to change)",
 			Type:        "display",
 			GetValue: func(c *config.Config) string {
-				historyCount := len(c.QueryHistory)
+				return fmt.Sprintf("%d connections, ping every %ds", c.Settings.PoolMaxConns, c.Settings.HealthCheckSec)
+			},
+		},
+		{
+			Name:        "Log Level",
+			Description: "Least severe messages written to the log in " + logDirHint(),
+			Type:        "toggle",
+			GetValue: func(c *config.Config) string {
+				return strings.ToLower(logging.ParseLevel(c.Settings.LogLevel).String())
+			},
+			Toggle: func(c *config.Config) {
+				current := strings.ToLower(logging.ParseLevel(c.Settings.LogLevel).String())
+				c.Settings.LogLevel = nextOption(logging.Levels, current)
+				logging.SetLevel(logging.ParseLevel(c.Settings.LogLevel))
+			},
+		},
+		{
+			Name:        "Tracing",
+			Description: "OTLP endpoint receiving OpenTelemetry spans (otlp_endpoint in config.json or OTEL_EXPORTER_OTLP_ENDPOINT; restart to apply)",
+			Type:        "display",
+			GetValue: func(c *config.Config) string {
+				if endpoint := tracing.Endpoint(c.Settings.OTLPEndpoint); endpoint != "" {
+					return endpoint
+				}
+				return "Off"
+			},
+		},
+		{
+			Name:        "View Logs",
+			Description: "Browse generated SQL, timings and errors in the log",
+			Type:        "action",
+			GetValue: func(c *config.Config) string {
+				return "Open"
+			},
+			Action: func() tea.Msg {
+				return goToLogsMsg{}
+			},
+		},
+		{
+			Name:        "LLM Usage",
+			Description: "Tokens and estimated cost of LLM provider requests by day and service (prices in model_prices of config.json)",
+			Type:        "action",
+			GetValue: func(c *config.Config) string {
+				return formatUsage(GlobalAppState.Usage.Total()) + " this session"
+			},
+			Action: func() tea.Msg {
+				return goToUsageMsg{}
+			},
+		},
+		{
+			Name:        "Train Model",
+			Description: "Train the NN on query history (needs 10+ queries)",
+			Type:        "action",
+			GetValue: func(c *config.Config) string {
+				var historyCount int
+				if store, err := config.History(); err == nil {
+					historyCount, _ = store.Count("")
+				}
 				if historyCount >= 10 {
 					return fmt.Sprintf("%d queries (ready)", historyCount)
 				}
 				return fmt.Sprintf("%d queries (need 10+)", historyCount)
 			},
+			Action: func() tea.Msg {
+				return goToTrainingMsg{}
+			},
 		},
 	}
+	if cfg.ActiveService != "" {
+		items = append(items, serviceProfileItems(cfg.ActiveService)...)
+	}
 
 	return &SettingsModel{
 		cfg:          cfg,
@@ -133,6 +812,16 @@ func (m *SettingsModel) Update(msg tea.Msg) (*SettingsModel, tea.Cmd) {
 		m.height = msg.Height
 		return m, nil
 
+	case clearCachesMsg:
+		if err := config.ClearCaches(); err != nil {
+			debugLog("clear caches: " + err.Error())
+		}
+		m.cfg.CachedSchemas = make(map[string]*config.SchemaCache)
+		m.cfg.Save()
+		return m, func() tea.Msg {
+			return settingsChangedMsg{}
+		}
+
 	case tea.KeyMsg:
 		switch {
 		case key.Matches(msg, key.NewBinding(key.WithKeys("esc"))):
@@ -167,10 +856,15 @@ func (m *SettingsModel) Update(msg tea.Msg) (*SettingsModel, tea.Cmd) {
 				if item.Type == "toggle" && item.Toggle != nil {
 					item.Toggle(m.cfg)
 					m.cfg.Save()
+					ApplyTheme(m.cfg)
+					SetGeometryStyle(m.cfg.Settings)
 					return m, func() tea.Msg {
 						return settingsChangedMsg{}
 					}
 				}
+				if item.Type == "action" && item.Action != nil {
+					return m, item.Action
+				}
 			}
 			return m, nil
 		}
@@ -187,7 +881,7 @@ func (m *SettingsModel) View() string {
 
 	header := RenderHeader("Settings")
 	content := m.renderContent()
-	helpText := "↑/k: up • ↓/j: down • enter/space: toggle • esc: back • ctrl+c: quit"
+	helpText := "↑/k: up • ↓/j: down • enter/space: toggle/run • esc: back • ctrl+c: quit"
 	footer := RenderHelpFooter(helpText, m.width)
 
 	return LayoutWithHeaderFooter(header, content, footer, m.width, m.height)
@@ -284,6 +978,8 @@ func (m *SettingsModel) renderSettingsTable() string {
 		var typeIcon string
 		if item.Type == "toggle" {
 			typeIcon = lipgloss.NewStyle().Foreground(ColorBlue).Render("◉")
+		} else if item.Type == "action" {
+			typeIcon = lipgloss.NewStyle().Foreground(ColorOrange).Render("◆")
 		} else {
 			typeIcon = lipgloss.NewStyle().Foreground(ColorGray).Render("○")
 		}
@@ -340,7 +1036,7 @@ func (m *SettingsModel) renderSettingsTable() string {
 	legendStyle := lipgloss.NewStyle().
 		Foreground(ColorGray).
 		Align(lipgloss.Center)
-	rows = append(rows, legendStyle.Render("◉ toggleable  ○ read-only"))
+	rows = append(rows, legendStyle.Render("◉ toggleable  ◆ action  ○ read-only"))
 
 	return lipgloss.JoinVertical(lipgloss.Center, rows...)
 }
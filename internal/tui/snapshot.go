@@ -0,0 +1,174 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/kartoza/kartoza-pg-ai/internal/config"
+	"github.com/kartoza/kartoza-pg-ai/internal/postgres"
+)
+
+// maxSnapshotNameLen caps the length of a snapshot name typed in the prompt
+const maxSnapshotNameLen = 60
+
+// snapshotPromptState is the name being typed for a snapshot to save or
+// compare with
+type snapshotPromptState struct {
+	name  string
+	names []string // Saved snapshots, for completion
+}
+
+// snapshotSavedMsg reports a result saved as a snapshot
+type snapshotSavedMsg struct {
+	name string
+	rows int
+	err  error
+}
+
+// snapshotDiffMsg carries a fresh run of a snapshot's SQL compared with it
+type snapshotDiffMsg struct {
+	snap *config.Snapshot
+	diff *postgres.RowDiff
+	err  error
+}
+
+// openSnapshotPrompt asks for the name of a snapshot
+func (m *QueryModel) openSnapshotPrompt() {
+	names, err := config.SnapshotNames()
+	if err != nil {
+		debugLog("snapshot names: " + err.Error())
+	}
+	m.snapshotPrompt = &snapshotPromptState{names: names}
+}
+
+// handleSnapshotPromptKey edits the snapshot name while its prompt is open.
+// Enter saves the selected entry's result under it; ctrl+d compares a
+// fresh run of the snapshot's SQL with it.
+func (m *QueryModel) handleSnapshotPromptKey(msg tea.KeyMsg) (*QueryModel, tea.Cmd) {
+	p := m.snapshotPrompt
+	switch msg.String() {
+	case "esc":
+		m.snapshotPrompt = nil
+	case "enter":
+		results := m.exportTarget()
+		if results == nil || len(results.Columns) == 0 {
+			m.statusMsg = "✗ Only entries with results can be snapshotted"
+			m.snapshotPrompt = nil
+			return m, nil
+		}
+		m.snapshotPrompt = nil
+		m.statusMsg = fmt.Sprintf("Saving snapshot %q...", p.name)
+		return m, m.saveSnapshot(results, p.name)
+	case "ctrl+d":
+		m.snapshotPrompt = nil
+		return m, m.diffSnapshot(p.name)
+	case "tab":
+		for _, name := range p.names {
+			if strings.HasPrefix(name, p.name) && name != p.name {
+				p.name = name
+				break
+			}
+		}
+	case "backspace":
+		if r := []rune(p.name); len(r) > 0 {
+			p.name = string(r[:len(r)-1])
+		}
+	default:
+		if msg.Type == tea.KeyRunes && len([]rune(p.name)) < maxSnapshotNameLen {
+			p.name += string(msg.Runes)
+		}
+	}
+	return m, nil
+}
+
+// renderSnapshotPrompt renders the snapshot name prompt and the names of
+// the snapshots it may complete to
+func (m *QueryModel) renderSnapshotPrompt() string {
+	p := m.snapshotPrompt
+	line := PromptStyle.Render("📸 Snapshot name: ") + p.name + "█"
+	var matches []string
+	for _, name := range p.names {
+		if strings.HasPrefix(name, p.name) {
+			matches = append(matches, name)
+		}
+	}
+	if len(matches) > 0 {
+		hint := truncate("Saved: "+strings.Join(matches, ", "), max(m.width-8, 10))
+		line += "\n" + lipgloss.NewStyle().Foreground(ColorGray).Render(hint)
+	}
+	return line
+}
+
+// saveSnapshot runs a result's SQL again for all its rows, as exporting
+// does, and saves them under name
+func (m *QueryModel) saveSnapshot(results *QueryResults, name string) tea.Cmd {
+	db := m.database()
+	service := m.service.Name
+	schema := m.schema
+	return func() tea.Msg {
+		if db == nil {
+			return snapshotSavedMsg{name: name, err: fmt.Errorf("no database connection")}
+		}
+		sqlText := results.ExecutedSQL()
+		columns, rows, err := postgres.QueryAll(context.Background(), db, sqlText, results.Params)
+		if err != nil {
+			return snapshotSavedMsg{name: name, err: err}
+		}
+		err = config.SaveSnapshot(&config.Snapshot{
+			Name:        name,
+			ServiceName: service,
+			Query:       results.NaturalQuery,
+			SQL:         sqlText,
+			Params:      results.Params,
+			Key:         diffKey(columns, sqlText, schema),
+			Columns:     columns,
+			Rows:        rows,
+		})
+		return snapshotSavedMsg{name: name, rows: len(rows), err: err}
+	}
+}
+
+// diffSnapshot runs a snapshot's SQL again and compares the rows with it
+func (m *QueryModel) diffSnapshot(name string) tea.Cmd {
+	snap, err := config.LoadSnapshot(name)
+	if err == nil && snap.ServiceName != m.service.Name {
+		err = fmt.Errorf("snapshot %q was taken on %s", name, snap.ServiceName)
+	}
+	if err != nil {
+		m.statusMsg = "✗ " + err.Error()
+		return nil
+	}
+	m.statusMsg = fmt.Sprintf("Comparing with snapshot %q...", name)
+
+	db := m.database()
+	return func() tea.Msg {
+		if db == nil {
+			return snapshotDiffMsg{snap: snap, err: fmt.Errorf("no database connection")}
+		}
+		columns, rows, err := postgres.QueryAll(context.Background(), db, snap.SQL, snap.Params)
+		if err != nil {
+			return snapshotDiffMsg{snap: snap, err: err}
+		}
+		d, err := postgres.DiffRows(snap.Columns, snap.Rows, columns, rows, snap.Key)
+		return snapshotDiffMsg{snap: snap, diff: d, err: err}
+	}
+}
+
+// showSnapshotDiff opens the diff of a snapshot and its fresh run
+func (m *QueryModel) showSnapshotDiff(msg snapshotDiffMsg) {
+	if msg.err != nil {
+		m.statusMsg = fmt.Sprintf("✗ Cannot compare with snapshot %q: %s", msg.snap.Name, msg.err)
+		return
+	}
+	m.statusMsg = ""
+	about := msg.snap.Query
+	if about == "" {
+		about = msg.snap.SQL
+	}
+	before := fmt.Sprintf("snapshot %q of %s (%s, %d rows)",
+		msg.snap.Name, about, msg.snap.CreatedAt.Format("2006-01-02 15:04"), len(msg.snap.Rows))
+	m.diffView = NewDiffViewModel(&resultDiff{RowDiff: msg.diff}, before, "a fresh run of the snapshot's SQL", m.width, m.height)
+}
@@ -0,0 +1,89 @@
+package tui
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/kartoza/kartoza-pg-ai/internal/postgres"
+)
+
+// SQL highlighting styles, rebuilt by buildSQLStyles when the theme changes
+var (
+	sqlKeywordStyle    lipgloss.Style
+	sqlIdentifierStyle lipgloss.Style
+	sqlFunctionStyle   lipgloss.Style
+	sqlStringStyle     lipgloss.Style
+	sqlNumberStyle     lipgloss.Style
+	sqlParameterStyle  lipgloss.Style
+	sqlCommentStyle    lipgloss.Style
+	sqlOperatorStyle   lipgloss.Style
+)
+
+// sqlTextWidth is the width SQL is laid out to when it is copied or
+// written to a report
+const sqlTextWidth = 80
+
+// buildSQLStyles builds the SQL highlighting styles from the theme colours
+func buildSQLStyles() {
+	sqlKeywordStyle = lipgloss.NewStyle().Foreground(ColorBlue).Bold(true)
+	sqlIdentifierStyle = lipgloss.NewStyle().Foreground(ColorWhite)
+	sqlFunctionStyle = lipgloss.NewStyle().Foreground(ColorCyan)
+	sqlStringStyle = lipgloss.NewStyle().Foreground(ColorGreen)
+	sqlNumberStyle = lipgloss.NewStyle().Foreground(ColorOrange)
+	sqlParameterStyle = lipgloss.NewStyle().Foreground(ColorOrange).Bold(true)
+	sqlCommentStyle = lipgloss.NewStyle().Foreground(ColorGray).Italic(true)
+	sqlOperatorStyle = lipgloss.NewStyle().Foreground(ColorGray)
+}
+
+// highlightSQL colours the keywords, identifiers, function names, strings,
+// numbers, parameters and comments of SQL for display
+func highlightSQL(sql string) string {
+	tokens := postgres.LexSQL(sql)
+	var b strings.Builder
+	for i, tok := range tokens {
+		var style lipgloss.Style
+		switch tok.Kind {
+		case postgres.TokenWhitespace:
+			b.WriteString(tok.Text)
+			continue
+		case postgres.TokenKeyword:
+			style = sqlKeywordStyle
+		case postgres.TokenIdentifier, postgres.TokenQuotedIdentifier:
+			style = sqlIdentifierStyle
+			if nextSignificant(tokens, i) == "(" {
+				style = sqlFunctionStyle
+			}
+		case postgres.TokenString:
+			style = sqlStringStyle
+		case postgres.TokenNumber:
+			style = sqlNumberStyle
+		case postgres.TokenParameter:
+			style = sqlParameterStyle
+		case postgres.TokenComment:
+			style = sqlCommentStyle
+		default:
+			style = sqlOperatorStyle
+		}
+		// Lines are styled separately, as lipgloss pads multi-line text
+		for j, line := range strings.Split(tok.Text, "\n") {
+			if j > 0 {
+				b.WriteString("\n")
+			}
+			if line != "" {
+				b.WriteString(style.Render(line))
+			}
+		}
+	}
+	return b.String()
+}
+
+// nextSignificant returns the text of the first token after i that is not
+// whitespace or a comment
+func nextSignificant(tokens []postgres.Token, i int) string {
+	for _, tok := range tokens[i+1:] {
+		if tok.Kind != postgres.TokenWhitespace && tok.Kind != postgres.TokenComment {
+			return tok.Text
+		}
+	}
+	return ""
+}
@@ -0,0 +1,456 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/kartoza/kartoza-pg-ai/internal/config"
+	"github.com/kartoza/kartoza-pg-ai/internal/postgres"
+)
+
+// statusRefreshInterval is how often the status screen reads the
+// statistics views again
+const statusRefreshInterval = 5 * time.Second
+
+// statusPanelRows bounds the rows shown of each panel
+const statusPanelRows = 10
+
+// statusTickMsg asks a status screen to refresh
+type statusTickMsg struct {
+	model *StatusModel // The screen that scheduled it; ticks of closed screens are dropped
+}
+
+// healthCollectedMsg carries a fresh reading of the statistics views
+type healthCollectedMsg struct {
+	panels []postgres.HealthPanel
+	err    error
+	at     time.Time
+}
+
+// backendSignal is a cancel or terminate waiting for confirmation
+type backendSignal struct {
+	pid       int
+	terminate bool
+	query     string
+}
+
+// backendSignalledMsg carries the outcome of cancelling or terminating a backend
+type backendSignalledMsg struct {
+	pid       int
+	terminate bool
+	ok        bool
+	err       error
+}
+
+// StatusModel is the database health dashboard of the active service
+type StatusModel struct {
+	width     int
+	height    int
+	service   *postgres.ServiceEntry
+	conn      *postgres.ConnectionManager
+	panels    []postgres.HealthPanel
+	collected time.Time
+	err       error
+	loading   bool
+	paused    bool
+	scroll    int // Lines scrolled down
+
+	selectedPID   string         // Backend selected in the running queries; "" for none
+	pendingSignal *backendSignal // Cancel or terminate awaiting confirmation
+	statusMsg     string
+}
+
+// NewStatusModel creates the health dashboard for a service
+func NewStatusModel(service *postgres.ServiceEntry, cfg *config.Config) *StatusModel {
+	return &StatusModel{
+		service: service,
+		conn:    sharedConnection(service, cfg),
+	}
+}
+
+// Init reads the statistics views and starts the refresh timer
+func (m *StatusModel) Init() tea.Cmd {
+	m.loading = true
+	return tea.Batch(m.collect(), m.tick())
+}
+
+// tick schedules the next refresh
+func (m *StatusModel) tick() tea.Cmd {
+	return tea.Tick(statusRefreshInterval, func(time.Time) tea.Msg {
+		return statusTickMsg{model: m}
+	})
+}
+
+// collect reads every panel of the dashboard
+func (m *StatusModel) collect() tea.Cmd {
+	conn := m.conn
+	return func() tea.Msg {
+		if conn == nil {
+			return healthCollectedMsg{err: fmt.Errorf("no service configured")}
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		db, err := conn.Connect(ctx)
+		if err != nil {
+			return healthCollectedMsg{err: err}
+		}
+		return healthCollectedMsg{panels: postgres.CollectHealth(ctx, db), at: time.Now()}
+	}
+}
+
+// Update handles messages for the health dashboard
+func (m *StatusModel) Update(msg tea.Msg) (*StatusModel, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		m.height = msg.Height
+		return m, nil
+
+	case statusTickMsg:
+		if msg.model != m {
+			return m, nil
+		}
+		if m.paused || m.loading {
+			return m, m.tick()
+		}
+		m.loading = true
+		return m, tea.Batch(m.collect(), m.tick())
+
+	case healthCollectedMsg:
+		m.loading = false
+		m.err = msg.err
+		if msg.err == nil {
+			m.panels = msg.panels
+			m.collected = msg.at
+			if m.selectedBackend() < 0 {
+				// The selected backend finished
+				m.selectedPID = ""
+			}
+		}
+		return m, nil
+
+	case backendSignalledMsg:
+		action := "Cancelled the query of"
+		if msg.terminate {
+			action = "Terminated"
+		}
+		switch {
+		case msg.err != nil:
+			m.statusMsg = fmt.Sprintf("✗ Backend %d: %v", msg.pid, msg.err)
+		case !msg.ok:
+			m.statusMsg = fmt.Sprintf("✗ Backend %d is no longer running", msg.pid)
+		default:
+			m.statusMsg = fmt.Sprintf("✓ %s backend %d", action, msg.pid)
+		}
+		if m.loading {
+			return m, nil
+		}
+		m.loading = true
+		return m, m.collect()
+
+	case tea.KeyMsg:
+		if m.pendingSignal != nil {
+			signal := m.pendingSignal
+			m.pendingSignal = nil
+			if msg.String() == "y" {
+				return m, m.signalBackend(signal.pid, signal.terminate)
+			}
+			m.statusMsg = ""
+			return m, nil
+		}
+
+		switch msg.String() {
+		case "ctrl+c", "esc", "q":
+			return m, func() tea.Msg { return goToMenuMsg{} }
+		case "r":
+			if !m.loading {
+				m.loading = true
+				return m, m.collect()
+			}
+		case "p":
+			m.paused = !m.paused
+		case "up", "k":
+			m.scroll = max(0, m.scroll-1)
+		case "down", "j":
+			m.scroll++
+		case "pgup":
+			m.scroll = max(0, m.scroll-m.contentHeight())
+		case "pgdown":
+			m.scroll += m.contentHeight()
+		case "home", "g":
+			m.scroll = 0
+		case "tab":
+			m.moveSelection(1)
+		case "shift+tab":
+			m.moveSelection(-1)
+		case "c", "x":
+			if i := m.selectedBackend(); i >= 0 {
+				pid, err := strconv.Atoi(m.selectedPID)
+				if err != nil {
+					return m, nil
+				}
+				row := m.backendPanel().Rows[i]
+				m.pendingSignal = &backendSignal{pid: pid, terminate: msg.String() == "x", query: row[len(row)-1]}
+			} else {
+				m.statusMsg = "Select a running query with tab first"
+			}
+		}
+	}
+	return m, nil
+}
+
+// backendPanel returns the panel whose rows are backends, or nil
+func (m *StatusModel) backendPanel() *postgres.HealthPanel {
+	for i := range m.panels {
+		if m.panels[i].Backends && m.panels[i].Err == nil {
+			return &m.panels[i]
+		}
+	}
+	return nil
+}
+
+// selectedBackend returns the row of the selected backend among those
+// shown, or -1
+func (m *StatusModel) selectedBackend() int {
+	panel := m.backendPanel()
+	if panel == nil || m.selectedPID == "" {
+		return -1
+	}
+	for i, row := range panel.Rows[:min(len(panel.Rows), statusPanelRows)] {
+		if len(row) > 0 && row[0] == m.selectedPID {
+			return i
+		}
+	}
+	return -1
+}
+
+// moveSelection selects the next or previous shown backend, wrapping around
+func (m *StatusModel) moveSelection(delta int) {
+	panel := m.backendPanel()
+	if panel == nil || len(panel.Rows) == 0 {
+		m.statusMsg = "No running queries to select"
+		return
+	}
+	shown := min(len(panel.Rows), statusPanelRows)
+	i := m.selectedBackend()
+	switch {
+	case i < 0 && delta < 0:
+		i = shown - 1
+	case i < 0:
+		i = 0
+	default:
+		i = (i + delta + shown) % shown
+	}
+	m.selectedPID = panel.Rows[i][0]
+	m.statusMsg = ""
+}
+
+// signalBackend cancels the query of a backend or terminates it
+func (m *StatusModel) signalBackend(pid int, terminate bool) tea.Cmd {
+	conn := m.conn
+	return func() tea.Msg {
+		if conn == nil {
+			return backendSignalledMsg{pid: pid, terminate: terminate, err: fmt.Errorf("no service configured")}
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		db, err := conn.Connect(ctx)
+		if err != nil {
+			return backendSignalledMsg{pid: pid, terminate: terminate, err: err}
+		}
+		ok, err := postgres.SignalBackend(ctx, db, pid, terminate)
+		return backendSignalledMsg{pid: pid, terminate: terminate, ok: ok, err: err}
+	}
+}
+
+// contentHeight is the number of dashboard lines that fit on the screen
+func (m *StatusModel) contentHeight() int {
+	return max(5, m.height-9)
+}
+
+// View renders the health dashboard
+func (m *StatusModel) View() string {
+	if m.width == 0 || m.height == 0 {
+		return ""
+	}
+
+	header := RenderHeader("Database Status")
+
+	labelStyle := lipgloss.NewStyle().Foreground(ColorGray)
+	valueStyle := lipgloss.NewStyle().Foreground(ColorOrange).Bold(true)
+
+	serviceName := ""
+	if m.service != nil {
+		serviceName = m.service.Name
+	}
+	summary := labelStyle.Render("Service: ") + valueStyle.Render(serviceName)
+	switch {
+	case m.collected.IsZero() && m.loading:
+		summary += labelStyle.Render(" • reading statistics...")
+	case !m.collected.IsZero():
+		summary += labelStyle.Render(" • updated " + m.collected.Format("15:04:05"))
+	}
+	if m.paused {
+		summary += lipgloss.NewStyle().Foreground(ColorRed).Render(" • paused")
+	} else {
+		summary += labelStyle.Render(fmt.Sprintf(" • every %s", statusRefreshInterval))
+	}
+
+	lines := []string{summary, ""}
+	if m.pendingSignal != nil {
+		lines = append(lines, m.renderSignalConfirm(), "")
+	} else if m.statusMsg != "" {
+		style := lipgloss.NewStyle().Foreground(ColorGreen)
+		if strings.HasPrefix(m.statusMsg, "✗") {
+			style = ErrorStyle
+		}
+		lines = append(lines, style.Render(m.statusMsg), "")
+	}
+	if m.err != nil {
+		lines = append(lines, ErrorStyle.Render("✗ "+m.err.Error()), "")
+	}
+	for _, panel := range m.panels {
+		lines = append(lines, m.renderPanel(panel)...)
+		lines = append(lines, "")
+	}
+
+	// Window of lines that fits, keeping the scroll within them
+	height := m.contentHeight()
+	m.scroll = min(m.scroll, max(0, len(lines)-height))
+	visible := lines[m.scroll:min(len(lines), m.scroll+height)]
+
+	helpText := "↑/↓ pgup/pgdn: scroll • tab: select query • c: cancel it • x: terminate it • r: refresh • p: pause • esc: back"
+	if m.pendingSignal != nil {
+		helpText = "y: confirm • any other key: keep it running"
+	}
+	footer := RenderHelpFooter(helpText, m.width)
+
+	content := lipgloss.NewStyle().Width(m.width - 4).Render(strings.Join(visible, "\n"))
+	return LayoutWithHeaderFooter(header, content, footer, m.width, m.height)
+}
+
+// renderPanel renders the title and table of one panel
+func (m *StatusModel) renderPanel(panel postgres.HealthPanel) []string {
+	titleStyle := lipgloss.NewStyle().Foreground(ColorCyan).Bold(true)
+	hintStyle := lipgloss.NewStyle().Foreground(ColorGray).Italic(true)
+
+	title := titleStyle.Render(panel.Title)
+	switch {
+	case panel.Err != nil:
+		return []string{title, "  " + ErrorStyle.Render("✗ "+truncate(panel.Err.Error(), m.width-10))}
+	case len(panel.Rows) == 0:
+		return []string{title + hintStyle.Render("  none")}
+	}
+	if len(panel.Rows) > 1 {
+		title += hintStyle.Render(fmt.Sprintf("  (%d)", len(panel.Rows)))
+	}
+
+	selected := -1
+	if panel.Backends {
+		selected = m.selectedBackend()
+	}
+	results := &QueryResults{Columns: panel.Columns, Rows: panel.Rows, RowCount: len(panel.Rows)}
+	return append([]string{title}, renderStatusTable(results, m.width-8, statusPanelRows, selected)...)
+}
+
+// renderSignalConfirm renders the confirmation box for cancelling or
+// terminating the selected backend
+func (m *StatusModel) renderSignalConfirm() string {
+	titleStyle := lipgloss.NewStyle().Foreground(ColorRed).Bold(true)
+	hintStyle := lipgloss.NewStyle().Foreground(ColorGray)
+
+	title := fmt.Sprintf("⚠ Cancel the running query of backend %d?", m.pendingSignal.pid)
+	effect := "The query stops with an error; the session stays connected."
+	if m.pendingSignal.terminate {
+		title = fmt.Sprintf("⚠ Terminate backend %d?", m.pendingSignal.pid)
+		effect = "The session is disconnected and its open transaction rolled back."
+	}
+
+	return lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(ColorRed).
+		Padding(0, 2).
+		Width(m.width - 10).
+		Render(lipgloss.JoinVertical(lipgloss.Left,
+			titleStyle.Render(title),
+			"",
+			highlightSQL(truncate(m.pendingSignal.query, m.width-16)),
+			"",
+			hintStyle.Render(effect),
+			hintStyle.Render("Press y to confirm, any other key to cancel"),
+		))
+}
+
+// renderStatusTable renders rows in the style of the conversation tables.
+// Columns are sized as there, then the widest are narrowed until the table
+// fits width and the last, often a query, takes any room left. The row at
+// index selected, if any, is highlighted.
+func renderStatusTable(results *QueryResults, width, maxRows, selected int) []string {
+	widths := columnWidths(results)
+	total := func() int {
+		sum := 3 * (len(widths) - 1)
+		for _, w := range widths {
+			sum += w
+		}
+		return sum
+	}
+	for total() > width {
+		widest := 0
+		for i, w := range widths {
+			if w > widths[widest] {
+				widest = i
+			}
+		}
+		if widths[widest] <= 6 {
+			break
+		}
+		widths[widest]--
+	}
+	last := len(widths) - 1
+	for _, row := range results.Rows {
+		if last < len(row) && len(row[last]) > widths[last] && total() < width {
+			widths[last] = min(len(row[last]), widths[last]+width-total())
+		}
+	}
+
+	headerStyle := lipgloss.NewStyle().Bold(true).Foreground(ColorOrange)
+	sepStyle := lipgloss.NewStyle().Foreground(ColorGray)
+	rowStyle := lipgloss.NewStyle().Foreground(ColorWhite)
+	moreStyle := lipgloss.NewStyle().Foreground(ColorGray).Italic(true)
+
+	var headerCells, sepParts []string
+	for i, col := range results.Columns {
+		headerCells = append(headerCells, headerStyle.Render(padOrTruncate(col, widths[i])))
+		sepParts = append(sepParts, strings.Repeat("─", widths[i]))
+	}
+	lines := []string{
+		"  " + strings.Join(headerCells, " │ "),
+		"  " + sepStyle.Render(strings.Join(sepParts, "─┼─")),
+	}
+	selectedStyle := rowStyle.Background(ColorDarkGray)
+	indicatorStyle := lipgloss.NewStyle().Foreground(ColorOrange).Bold(true)
+	for r, row := range results.Rows[:min(len(results.Rows), maxRows)] {
+		cells := make([]string, len(widths))
+		for i := range widths {
+			cell := ""
+			if i < len(row) {
+				cell = row[i]
+			}
+			cells[i] = padOrTruncate(cell, widths[i])
+		}
+		if r == selected {
+			lines = append(lines, indicatorStyle.Render("▶ ")+selectedStyle.Render(strings.Join(cells, " │ ")))
+		} else {
+			lines = append(lines, "  "+rowStyle.Render(strings.Join(cells, " │ ")))
+		}
+	}
+	if len(results.Rows) > maxRows {
+		lines = append(lines, "  "+moreStyle.Render(fmt.Sprintf("... and %d more rows", len(results.Rows)-maxRows)))
+	}
+	return lines
+}
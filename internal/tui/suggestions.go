@@ -0,0 +1,60 @@
+package tui
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/kartoza/kartoza-pg-ai/internal/llm"
+)
+
+// Numbers of example questions suggested
+const (
+	maxSuggestions       = 40 // Generated for a schema
+	welcomeSuggestions   = 6  // Shown on the welcome screen
+	typeAheadSuggestions = 3  // Shown below the editor while typing
+)
+
+// typeAhead returns the example questions matching the question being
+// typed, while a single-line question is typed in the editor
+func (m *QueryModel) typeAhead() []string {
+	if !m.focusEditor || m.loading || m.sqlEdit != nil || m.completion != nil {
+		return nil
+	}
+	text := m.getEditorText()
+	if strings.Contains(strings.TrimRight(text, "\n"), "\n") || len(strings.TrimSpace(text)) < 2 {
+		return nil
+	}
+	return llm.MatchSuggestions(m.suggestions, text, typeAheadSuggestions)
+}
+
+// acceptSuggestion replaces the question being typed with the first
+// example question matching it; false when none does
+func (m *QueryModel) acceptSuggestion() bool {
+	matches := m.typeAhead()
+	if len(matches) == 0 {
+		return false
+	}
+	m.SetInitialQuery(matches[0])
+	return true
+}
+
+// renderTypeAhead renders the example questions matching the question
+// being typed
+func (m *QueryModel) renderTypeAhead() string {
+	matches := m.typeAhead()
+	if len(matches) == 0 {
+		return ""
+	}
+	first := lipgloss.NewStyle().Foreground(ColorOrange)
+	other := lipgloss.NewStyle().Foreground(ColorGray)
+	parts := make([]string, len(matches))
+	for i, question := range matches {
+		if i == 0 {
+			parts[i] = first.Render(question)
+		} else {
+			parts[i] = other.Render(question)
+		}
+	}
+	line := other.Render("ctrl+y: ") + strings.Join(parts, other.Render(" · "))
+	return lipgloss.NewStyle().MaxWidth(max(m.width-6, 10)).Render(line)
+}
@@ -0,0 +1,111 @@
+package tui
+
+import (
+	"fmt"
+
+	"github.com/mattn/go-runewidth"
+)
+
+// maxColWidth caps the width of a column in conversation tables
+const maxColWidth = 25
+
+// columnWidths returns the display width of each result column: the widest
+// of its name and values as shown, capped at maxColWidth
+func columnWidths(results *QueryResults) []int {
+	widths := make([]int, len(results.Columns))
+	for i, col := range results.Columns {
+		widths[i] = runewidth.StringWidth(col)
+	}
+	for r, row := range results.Rows {
+		for i := range row {
+			if i < len(widths) {
+				widths[i] = max(widths[i], runewidth.StringWidth(results.displayValue(r, i)))
+			}
+		}
+	}
+	for i := range widths {
+		widths[i] = min(widths[i], maxColWidth)
+	}
+	return widths
+}
+
+// frozenColumns returns how many leading columns stay in place while
+// scrolling: the first (usually an ID) when the setting is on and it is shown
+func (m *QueryModel) frozenColumns(results *QueryResults) int {
+	if m.cfg != nil && m.cfg.Settings.FreezeFirstColumn && len(results.Columns) > 1 && !results.isHidden(0) {
+		return 1
+	}
+	return 0
+}
+
+// visibleColumns returns the shown columns that fit the screen, starting
+// with any frozen column and then from the scroll offset, and whether more
+// columns are cut off to the right
+func (m *QueryModel) visibleColumns(results *QueryResults, widths []int) ([]int, bool) {
+	frozen := m.frozenColumns(results)
+	available := m.width - 8 // Table indent and conversation margins
+
+	var cols []int
+	used := 0
+	add := func(i int) bool {
+		w := widths[i]
+		if len(cols) > 0 {
+			w += 3 // " │ " separator
+		}
+		if len(cols) > 0 && used+w > available {
+			return false
+		}
+		cols = append(cols, i)
+		used += w
+		return true
+	}
+
+	for i := 0; i < frozen; i++ {
+		add(i)
+	}
+	start := max(results.colOffset, frozen)
+	for i := start; i < len(widths); i++ {
+		if results.isHidden(i) {
+			continue
+		}
+		if !add(i) {
+			return cols, true
+		}
+	}
+	return cols, false
+}
+
+// scrollColumns moves the selected entry's table delta columns sideways
+func (m *QueryModel) scrollColumns(delta int) {
+	if m.selectedEntry < 0 || m.selectedEntry >= len(m.history) {
+		return
+	}
+	results := m.history[m.selectedEntry].Results
+	if results == nil || len(results.Columns) == 0 {
+		return
+	}
+
+	frozen := m.frozenColumns(results)
+	results.colOffset = max(results.colOffset, frozen)
+	if delta > 0 {
+		if _, hiddenRight := m.visibleColumns(results, columnWidths(results)); !hiddenRight {
+			return
+		}
+	}
+	results.colOffset = min(max(results.colOffset+delta, frozen), len(results.Columns)-1)
+}
+
+// columnScrollHint describes the visible column range when the table does
+// not fit or has hidden columns, e.g. "columns 4-9 of 14 (←/→: scroll)"
+func columnScrollHint(results *QueryResults, visible []int, frozen int, hiddenRight bool) string {
+	if len(visible) == 0 || (!hiddenRight && len(visible) == len(results.Columns)) {
+		return ""
+	}
+	// A frozen first column precedes the scrolled range
+	first, last := visible[min(frozen, len(visible)-1)], visible[len(visible)-1]
+	hint := fmt.Sprintf("columns %d-%d of %d", first+1, last+1, len(results.Columns))
+	if n := results.hiddenCount(); n > 0 {
+		hint += fmt.Sprintf(", %d hidden", n)
+	}
+	return hint + " (←/→: scroll • f: freeze first column • c: sort/hide columns)"
+}
@@ -0,0 +1,155 @@
+package tui
+
+import (
+	"sort"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/kartoza/kartoza-pg-ai/internal/config"
+)
+
+// DefaultTheme is the theme used when none is set or the one set is unknown
+const DefaultTheme = "dark"
+
+// builtinThemeNames lists the built-in themes in the order settings cycle
+// through them
+var builtinThemeNames = []string{"dark", "light", "high-contrast"}
+
+// builtinThemes are the themes every user has. Dark is the Kartoza palette
+// the interface was designed with; light suits terminals with a light
+// background, and high-contrast maximises legibility.
+var builtinThemes = map[string]config.ThemePalette{
+	"dark": {
+		Accent:             "#DDA036",
+		Secondary:          "#569FC6",
+		Muted:              "#9A9EA0",
+		Text:               "#FFFFFF",
+		Surface:            "#3A3A3A",
+		Error:              "#E95420",
+		Success:            "#4CAF50",
+		Info:               "#00BCD4",
+		OnAccent:           "#000000",
+		StatusBar:          "#1A1A1A",
+		GeometryBackground: "#1e1e1e",
+		GeometryLine:       "#ffa500",
+		GeometryFill:       "#ffa500",
+		GeometryPoint:      "#00c8ff",
+	},
+	"light": {
+		Accent:             "#A8650B",
+		Secondary:          "#1F6FA8",
+		Muted:              "#6B6F72",
+		Text:               "#1A1A1A",
+		Surface:            "#D6D6D6",
+		Error:              "#C2361A",
+		Success:            "#2E7D32",
+		Info:               "#00796B",
+		OnAccent:           "#FFFFFF",
+		StatusBar:          "#E8E8E8",
+		GeometryBackground: "#ffffff",
+		GeometryLine:       "#3c78e6",
+		GeometryFill:       "#3c78e6",
+		GeometryPoint:      "#e64b3c",
+	},
+	"high-contrast": {
+		Accent:             "#FFD700",
+		Secondary:          "#00BFFF",
+		Muted:              "#D0D0D0",
+		Text:               "#FFFFFF",
+		Surface:            "#0000AF",
+		Error:              "#FF5555",
+		Success:            "#00FF00",
+		Info:               "#00FFFF",
+		OnAccent:           "#000000",
+		StatusBar:          "#000000",
+		GeometryBackground: "#000000",
+		GeometryLine:       "#fae650",
+		GeometryFill:       "#fae650",
+		GeometryPoint:      "#00c8ff",
+	},
+}
+
+// ThemeNames returns the built-in themes followed by the user's, by name
+func ThemeNames(cfg *config.Config) []string {
+	names := append([]string{}, builtinThemeNames...)
+	var custom []string
+	for name := range cfg.Themes {
+		if _, builtin := builtinThemes[name]; !builtin {
+			custom = append(custom, name)
+		}
+	}
+	sort.Strings(custom)
+	return append(names, custom...)
+}
+
+// ThemePalette returns the palette of the theme the settings select, its
+// unset or invalid colours filled in from the built-in theme it is based on
+func ThemePalette(cfg *config.Config) config.ThemePalette {
+	name := cfg.Settings.Theme
+	if p, ok := builtinThemes[name]; ok {
+		return p
+	}
+	p, ok := cfg.Themes[name]
+	if !ok {
+		return builtinThemes[DefaultTheme]
+	}
+	base, ok := builtinThemes[p.Base]
+	if !ok {
+		base = builtinThemes[DefaultTheme]
+	}
+	baseColors := paletteColors(&base)
+	for i, c := range paletteColors(&p) {
+		if _, valid := parseHexColor(*c); !valid {
+			*c = *baseColors[i]
+		}
+	}
+	return p
+}
+
+// paletteColors returns pointers to every colour of a palette, in a fixed order
+func paletteColors(p *config.ThemePalette) []*string {
+	return []*string{
+		&p.Accent, &p.Secondary, &p.Muted, &p.Text, &p.Surface, &p.Error, &p.Success, &p.Info,
+		&p.OnAccent, &p.StatusBar, &p.GeometryBackground, &p.GeometryLine, &p.GeometryFill, &p.GeometryPoint,
+	}
+}
+
+// ApplyTheme switches the interface to the theme the settings select, or
+// to plain output, and sets the date style results are shown in. Screens
+// pick it up on their next render; editors created before keep their
+// colours until reopened.
+func ApplyTheme(cfg *config.Config) {
+	applyPalette(ThemePalette(cfg))
+	plainOutput = plainForced || cfg.Settings.PlainOutput
+	dateStyle = cfg.Settings.DateStyle
+	themeGeneration++
+}
+
+// UseThemeGeometry sets the geometry image colours to those of the theme
+// the settings select
+func UseThemeGeometry(cfg *config.Config) {
+	p := ThemePalette(cfg)
+	cfg.Settings.GeometryBackground = p.GeometryBackground
+	cfg.Settings.GeometryLineColor = p.GeometryLine
+	cfg.Settings.GeometryFillColor = p.GeometryFill
+	cfg.Settings.GeometryPointColor = p.GeometryPoint
+}
+
+// applyPalette sets the colour variables and rebuilds the shared styles
+func applyPalette(p config.ThemePalette) {
+	ColorOrange = lipgloss.Color(p.Accent)
+	ColorBlue = lipgloss.Color(p.Secondary)
+	ColorGray = lipgloss.Color(p.Muted)
+	ColorWhite = lipgloss.Color(p.Text)
+	ColorDarkGray = lipgloss.Color(p.Surface)
+	ColorRed = lipgloss.Color(p.Error)
+	ColorGreen = lipgloss.Color(p.Success)
+	ColorCyan = lipgloss.Color(p.Info)
+	ColorOnAccent = lipgloss.Color(p.OnAccent)
+	ColorStatusBar = lipgloss.Color(p.StatusBar)
+	buildStyles()
+	buildSQLStyles()
+}
+
+func init() {
+	applyPalette(builtinThemes[DefaultTheme])
+}
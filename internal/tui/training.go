@@ -0,0 +1,242 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/progress"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/kartoza/kartoza-pg-ai/internal/config"
+	"github.com/kartoza/kartoza-pg-ai/internal/nn"
+)
+
+// trainingEpochs is the number of passes over the history a training run makes
+const trainingEpochs = 20
+
+// trainingProgressMsg is sent after each training epoch
+type trainingProgressMsg nn.TrainingProgress
+
+// trainingCompleteMsg signals that the progress channel was closed
+type trainingCompleteMsg struct{}
+
+// trainingDoneMsg carries the outcome of a training run
+type trainingDoneMsg struct {
+	result nn.TrainingResult
+	err    error
+}
+
+// goToTrainingMsg requests the model training screen
+type goToTrainingMsg struct{}
+
+// goToSettingsMsg requests the settings screen
+type goToSettingsMsg struct{}
+
+// TrainingModel is the model for the neural network training screen
+type TrainingModel struct {
+	width        int
+	height       int
+	progress     progress.Model
+	epoch        int
+	epochs       int
+	losses       []float64 // Mean token loss of each finished epoch
+	done         bool
+	result       nn.TrainingResult
+	err          error
+	trainingChan chan trainingProgressMsg
+	cancel       context.CancelFunc
+}
+
+// NewTrainingModel creates a new training model
+func NewTrainingModel() *TrainingModel {
+	prog := progress.New(
+		progress.WithDefaultGradient(),
+		progress.WithWidth(50),
+		progress.WithoutPercentage(),
+	)
+	prog.FullColor = string(ColorOrange)
+	prog.EmptyColor = string(ColorDarkGray)
+
+	return &TrainingModel{
+		progress:     prog,
+		epochs:       trainingEpochs,
+		trainingChan: make(chan trainingProgressMsg, 100),
+	}
+}
+
+// Init initializes the training model
+func (m *TrainingModel) Init() tea.Cmd {
+	return tea.Batch(
+		m.startTraining(),
+		m.listenForProgress(),
+	)
+}
+
+// listenForProgress listens for epoch updates from the training goroutine
+func (m *TrainingModel) listenForProgress() tea.Cmd {
+	return func() tea.Msg {
+		msg, ok := <-m.trainingChan
+		if !ok {
+			return trainingCompleteMsg{}
+		}
+		return msg
+	}
+}
+
+// startTraining trains the model on the whole query history
+func (m *TrainingModel) startTraining() tea.Cmd {
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancel = cancel
+	return func() tea.Msg {
+		defer close(m.trainingChan)
+
+		store, err := config.History()
+		if err != nil {
+			return trainingDoneMsg{err: err}
+		}
+		history, err := store.List(config.HistoryFilter{})
+		if err != nil {
+			return trainingDoneMsg{err: err}
+		}
+		trainer, err := nn.NewQueryTrainer()
+		if err != nil {
+			return trainingDoneMsg{err: err}
+		}
+
+		result, err := trainer.TrainWithProgress(ctx, history, trainingEpochs, func(p nn.TrainingProgress) {
+			select {
+			case m.trainingChan <- trainingProgressMsg(p):
+			default:
+				// Channel full, skip this update
+			}
+		})
+		return trainingDoneMsg{result: result, err: err}
+	}
+}
+
+// Update handles messages for the training model
+func (m *TrainingModel) Update(msg tea.Msg) (*TrainingModel, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		m.height = msg.Height
+		m.progress.Width = min(50, msg.Width-20)
+		return m, nil
+
+	case tea.KeyMsg:
+		if msg.Type == tea.KeyCtrlC || msg.Type == tea.KeyEscape || (m.done && msg.Type == tea.KeyEnter) {
+			// Stops a run in progress; the model on disk is kept
+			m.cancel()
+			return m, func() tea.Msg {
+				return goToSettingsMsg{}
+			}
+		}
+
+	case trainingProgressMsg:
+		m.epoch = msg.Epoch
+		m.epochs = msg.Epochs
+		m.losses = append(m.losses, msg.Loss)
+		return m, m.listenForProgress()
+
+	case trainingCompleteMsg:
+		return m, nil
+
+	case trainingDoneMsg:
+		m.done = true
+		m.result = msg.result
+		m.err = msg.err
+		return m, nil
+
+	case progress.FrameMsg:
+		progressModel, cmd := m.progress.Update(msg)
+		m.progress = progressModel.(progress.Model)
+		return m, cmd
+	}
+
+	return m, nil
+}
+
+// View renders the training screen
+func (m *TrainingModel) View() string {
+	if m.width == 0 || m.height == 0 {
+		return ""
+	}
+
+	header := RenderHeader("Train Model")
+
+	labelStyle := lipgloss.NewStyle().Foreground(ColorGray)
+	valueStyle := lipgloss.NewStyle().Foreground(ColorOrange).Bold(true)
+	line := func(label, value string) string {
+		return labelStyle.Render(fmt.Sprintf("%-18s", label)) + valueStyle.Render(value)
+	}
+	container := lipgloss.NewStyle().Width(60).Align(lipgloss.Center)
+
+	var contentLines []string
+	contentLines = append(contentLines, "")
+
+	percent := 0.0
+	if m.epochs > 0 {
+		percent = float64(m.epoch) / float64(m.epochs)
+	}
+	contentLines = append(contentLines, container.Render(m.progress.ViewAs(percent)))
+	contentLines = append(contentLines, "")
+	contentLines = append(contentLines, container.Render(fmt.Sprintf("Epoch %d / %d", m.epoch, m.epochs)))
+	contentLines = append(contentLines, "")
+
+	var stats []string
+	if len(m.losses) > 0 {
+		stats = append(stats, line("Loss:", fmt.Sprintf("%.4f", m.losses[len(m.losses)-1])))
+		stats = append(stats, line("Loss by epoch:", lossSparkline(m.losses)))
+	}
+	if m.done && m.result.Pairs > 0 {
+		stats = append(stats, line("Dataset:", fmt.Sprintf("%d query-SQL pairs", m.result.Pairs)))
+		if m.err == nil {
+			stats = append(stats, line("Split:", fmt.Sprintf("%d train / %d held out", m.result.TrainPairs, m.result.TestPairs)))
+			accuracy := "n/a"
+			if m.result.TestPairs > 0 {
+				accuracy = fmt.Sprintf("%.0f%% exact SQL", m.result.Accuracy*100)
+			}
+			stats = append(stats, line("Accuracy:", accuracy))
+		}
+	}
+	if len(stats) > 0 {
+		contentLines = append(contentLines, lipgloss.JoinVertical(lipgloss.Left, stats...))
+		contentLines = append(contentLines, "")
+	}
+
+	helpText := "esc: cancel • Training on the query history..."
+	switch {
+	case m.done && m.err != nil:
+		contentLines = append(contentLines, lipgloss.NewStyle().Foreground(ColorRed).Render("✗ "+m.err.Error()))
+		helpText = "enter/esc: back to settings"
+	case m.done:
+		contentLines = append(contentLines, lipgloss.NewStyle().Foreground(ColorGreen).Bold(true).Render("✓ Model trained and saved"))
+		helpText = "enter/esc: back to settings"
+	}
+
+	content := lipgloss.JoinVertical(lipgloss.Center, contentLines...)
+	footer := RenderHelpFooter(helpText, m.width)
+
+	return LayoutWithHeaderFooter(header, content, footer, m.width, m.height)
+}
+
+// lossSparkline draws losses as a row of block characters scaled between
+// the smallest and largest loss
+func lossSparkline(losses []float64) string {
+	blocks := []rune("▁▂▃▄▅▆▇█")
+	lo, hi := losses[0], losses[0]
+	for _, l := range losses {
+		lo, hi = math.Min(lo, l), math.Max(hi, l)
+	}
+	var b strings.Builder
+	for _, l := range losses {
+		level := 0
+		if hi > lo {
+			level = int((l - lo) / (hi - lo) * float64(len(blocks)-1))
+		}
+		b.WriteRune(blocks[level])
+	}
+	return b.String()
+}
@@ -0,0 +1,82 @@
+package tui
+
+import (
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// maxUndo bounds the changes the textarea editor can undo
+const maxUndo = 200
+
+// textSnapshot is the textarea editor's text and cursor at one moment
+type textSnapshot struct {
+	value    string
+	row, col int
+}
+
+// editHistory holds the changes of the textarea editor, which has no undo
+// of its own; the vim editor keeps its own with u and ctrl+r
+type editHistory struct {
+	undo   []textSnapshot // Text before each change, oldest first
+	redo   []textSnapshot // Text before each undo, most recently undone last
+	typing bool           // The last change typed a word character, which the next joins
+}
+
+// isUndoKey reports whether msg undoes or redoes a change of the textarea
+func isUndoKey(msg tea.Msg) bool {
+	key, ok := msg.(tea.KeyMsg)
+	return ok && (key.String() == "ctrl+z" || key.String() == "ctrl+r")
+}
+
+// textareaSnapshot returns the textarea's text and cursor
+func (m *QueryModel) textareaSnapshot() textSnapshot {
+	info := m.textArea.LineInfo()
+	return textSnapshot{value: m.textArea.Value(), row: m.textArea.Line(), col: info.StartColumn + info.ColumnOffset}
+}
+
+// recordEdit records the change msg made to the textarea, from before.
+// Characters typed in a row undo together, up to the next space.
+func (m *QueryModel) recordEdit(before textSnapshot, msg tea.Msg) {
+	h := &m.edits
+	key, isKey := msg.(tea.KeyMsg)
+	if m.textArea.Value() == before.value {
+		if isKey {
+			// Moving the cursor ends the word being typed
+			h.typing = false
+		}
+		return
+	}
+
+	typing := isKey && key.Type == tea.KeyRunes && !key.Paste && len(key.Runes) == 1 && key.Runes[0] != ' '
+	if !typing || !h.typing {
+		h.undo = append(h.undo, before)
+		if len(h.undo) > maxUndo {
+			h.undo = h.undo[len(h.undo)-maxUndo:]
+		}
+	}
+	h.typing = typing
+	h.redo = nil
+}
+
+// undoEdit undoes the last change of the textarea, or redoes the last
+// change undone. It reports false when there is none.
+func (m *QueryModel) undoEdit(redo bool) bool {
+	h := &m.edits
+	from, to := &h.undo, &h.redo
+	if redo {
+		from, to = to, from
+	}
+	if len(*from) == 0 {
+		return false
+	}
+	snapshot := (*from)[len(*from)-1]
+	*from = (*from)[:len(*from)-1]
+	*to = append(*to, m.textareaSnapshot())
+	h.typing = false
+
+	m.textArea.SetValue(snapshot.value)
+	for guard := 0; m.textArea.Line() > snapshot.row && guard < len(snapshot.value); guard++ {
+		m.textArea.CursorUp()
+	}
+	m.textArea.SetCursor(snapshot.col)
+	return true
+}
@@ -0,0 +1,258 @@
+package tui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/kartoza/kartoza-pg-ai/internal/config"
+	"github.com/kartoza/kartoza-pg-ai/internal/llm"
+)
+
+// usagePeriods are the numbers of days the usage report cycles through
+var usagePeriods = []int{7, 30, 90, 365}
+
+// How the usage report groups provider requests
+const (
+	usageByDayAndService = "day and service"
+	usageByDay           = "day"
+	usageByService       = "service"
+)
+
+// usageGroupings are the groupings the usage report cycles through
+var usageGroupings = []string{usageByDayAndService, usageByDay, usageByService}
+
+// goToUsageMsg requests the usage report screen
+type goToUsageMsg struct{}
+
+// usageLoadedMsg carries the usage report read from the history database
+type usageLoadedMsg struct {
+	model  *UsageModel // The screen that asked; reports for closed screens are dropped
+	report []config.UsageSummary
+	err    error
+}
+
+// UsageModel shows the tokens and estimated cost of LLM provider requests
+// by day and service
+type UsageModel struct {
+	width    int
+	height   int
+	period   int    // Index in usagePeriods
+	grouping string // One of usageGroupings
+	report   []config.UsageSummary
+	err      error
+	loading  bool
+	offset   int // First row shown
+}
+
+// NewUsageModel creates the usage report screen
+func NewUsageModel() *UsageModel {
+	return &UsageModel{period: 1, grouping: usageByDayAndService}
+}
+
+// Init reads the report
+func (m *UsageModel) Init() tea.Cmd {
+	return m.load()
+}
+
+// load reads the report for the chosen period
+func (m *UsageModel) load() tea.Cmd {
+	m.loading = true
+	since := time.Now().AddDate(0, 0, -usagePeriods[m.period]+1)
+	since = time.Date(since.Year(), since.Month(), since.Day(), 0, 0, 0, 0, time.Local)
+	return func() tea.Msg {
+		store, err := config.History()
+		if err != nil {
+			return usageLoadedMsg{model: m, err: err}
+		}
+		report, err := store.UsageReport(since)
+		return usageLoadedMsg{model: m, report: report, err: err}
+	}
+}
+
+// rows returns the report in the chosen grouping
+func (m *UsageModel) rows() []config.UsageSummary {
+	if m.grouping == usageByDayAndService {
+		return m.report
+	}
+	var rows []config.UsageSummary
+	index := make(map[string]int)
+	for _, s := range m.report {
+		key := s.Day
+		if m.grouping == usageByService {
+			key = s.ServiceName
+			s.Day = ""
+		} else {
+			s.ServiceName = ""
+		}
+		i, ok := index[key]
+		if !ok {
+			index[key] = len(rows)
+			rows = append(rows, s)
+			continue
+		}
+		rows[i].Requests += s.Requests
+		rows[i].InputTokens += s.InputTokens
+		rows[i].OutputTokens += s.OutputTokens
+		rows[i].Cost += s.Cost
+	}
+	if m.grouping == usageByService {
+		sort.Slice(rows, func(a, b int) bool { return rows[a].ServiceName < rows[b].ServiceName })
+	}
+	return rows
+}
+
+// listHeight is the number of rows that fit on the screen
+func (m *UsageModel) listHeight() int {
+	return max(3, m.height-16)
+}
+
+// scrollTo shows rows from offset, kept within the report
+func (m *UsageModel) scrollTo(offset int) {
+	m.offset = max(0, min(offset, len(m.rows())-m.listHeight()))
+}
+
+// Update handles messages for the usage report screen
+func (m *UsageModel) Update(msg tea.Msg) (*UsageModel, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		m.height = msg.Height
+		m.scrollTo(m.offset)
+		return m, nil
+
+	case usageLoadedMsg:
+		if msg.model != m {
+			return m, nil
+		}
+		m.loading = false
+		m.err = msg.err
+		m.report = msg.report
+		m.scrollTo(0)
+		return m, nil
+
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "ctrl+c", "esc", "q":
+			return m, func() tea.Msg { return goToSettingsMsg{} }
+		case "up", "k":
+			m.scrollTo(m.offset - 1)
+		case "down", "j":
+			m.scrollTo(m.offset + 1)
+		case "pgup":
+			m.scrollTo(m.offset - m.listHeight())
+		case "pgdown":
+			m.scrollTo(m.offset + m.listHeight())
+		case "p":
+			m.period = (m.period + 1) % len(usagePeriods)
+			return m, m.load()
+		case "g":
+			m.grouping = nextOption(usageGroupings, m.grouping)
+			m.scrollTo(0)
+		case "r":
+			if !m.loading {
+				return m, m.load()
+			}
+		}
+	}
+	return m, nil
+}
+
+// View renders the usage report screen
+func (m *UsageModel) View() string {
+	if m.width == 0 || m.height == 0 {
+		return ""
+	}
+
+	header := RenderHeader("LLM Usage")
+
+	labelStyle := lipgloss.NewStyle().Foreground(ColorGray)
+	valueStyle := lipgloss.NewStyle().Foreground(ColorOrange).Bold(true)
+	headStyle := lipgloss.NewStyle().Foreground(ColorCyan).Bold(true)
+
+	lines := []string{
+		labelStyle.Render("This session: ") + valueStyle.Render(formatUsage(GlobalAppState.Usage.Total())),
+		labelStyle.Render(fmt.Sprintf("Last %d days by %s • costs are estimates from the model prices", usagePeriods[m.period], m.grouping)),
+		"",
+	}
+	if m.err != nil {
+		lines = append(lines, ErrorStyle.Render("✗ "+m.err.Error()), "")
+	}
+
+	rows := m.rows()
+	switch {
+	case len(rows) == 0 && m.loading:
+		lines = append(lines, labelStyle.Render("Reading the usage..."))
+	case len(rows) == 0:
+		lines = append(lines, labelStyle.Render("No LLM provider requests in this period"))
+	default:
+		lines = append(lines, headStyle.Render(usageRow("Day", "Service", "Requests", "Prompt", "Completion", "Cost")))
+		var total config.UsageSummary
+		for _, s := range rows {
+			total.Requests += s.Requests
+			total.InputTokens += s.InputTokens
+			total.OutputTokens += s.OutputTokens
+			total.Cost += s.Cost
+		}
+		end := min(len(rows), m.offset+m.listHeight())
+		for _, s := range rows[m.offset:end] {
+			lines = append(lines, usageSummaryRow(s))
+		}
+		if end < len(rows) {
+			lines = append(lines, labelStyle.Render(fmt.Sprintf("  … %d more", len(rows)-end)))
+		}
+		total.Day = "Total"
+		lines = append(lines, "", valueStyle.Render(usageSummaryRow(total)))
+	}
+
+	helpText := "↑/↓ pgup/pgdn: scroll • p: period • g: group by • r: reload • esc: back"
+	footer := RenderHelpFooter(helpText, m.width)
+
+	content := lipgloss.NewStyle().Width(m.width - 4).Render(strings.Join(lines, "\n"))
+	return LayoutWithHeaderFooter(header, content, footer, m.width, m.height)
+}
+
+// usageSummaryRow renders a row of the usage report
+func usageSummaryRow(s config.UsageSummary) string {
+	return usageRow(s.Day, s.ServiceName, fmt.Sprint(s.Requests),
+		formatTokens(s.InputTokens), formatTokens(s.OutputTokens), formatCost(s.Cost))
+}
+
+// usageRow lays out the columns of the usage report
+func usageRow(day, service, requests, input, output, cost string) string {
+	return fmt.Sprintf("%-10s  %-20s  %8s  %10s  %10s  %9s",
+		day, padOrTruncate(service, 20), requests, input, output, cost)
+}
+
+// formatUsage describes provider usage briefly, e.g. "12.3k tokens, $0.04"
+func formatUsage(u llm.Usage) string {
+	text := formatTokens(u.Tokens()) + " tokens"
+	if u.Cost > 0 {
+		text += ", " + formatCost(u.Cost)
+	}
+	return text
+}
+
+// formatTokens abbreviates a token count, e.g. "12.3k"
+func formatTokens(n int) string {
+	switch {
+	case n >= 1_000_000:
+		return fmt.Sprintf("%.1fM", float64(n)/1e6)
+	case n >= 1000:
+		return fmt.Sprintf("%.1fk", float64(n)/1e3)
+	default:
+		return fmt.Sprint(n)
+	}
+}
+
+// formatCost formats an estimated cost in US dollars, keeping cents of a
+// cent for cheap requests
+func formatCost(cost float64) string {
+	if cost > 0 && cost < 0.01 {
+		return fmt.Sprintf("$%.4f", cost)
+	}
+	return fmt.Sprintf("$%.2f", cost)
+}
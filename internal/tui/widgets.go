@@ -1,24 +1,31 @@
 package tui
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/charmbracelet/lipgloss"
+	"github.com/kartoza/kartoza-pg-ai/internal/llm"
+	"github.com/kartoza/kartoza-pg-ai/internal/postgres"
 )
 
 // ========================================
 // Brand Colors - Kartoza standard palette
 // ========================================
 
+// Colours of the active theme (see ApplyTheme). They are named after their
+// colour in the default dark theme, the Kartoza palette.
 var (
-	ColorOrange   = lipgloss.Color("#DDA036") // Primary/Active
-	ColorBlue     = lipgloss.Color("#569FC6") // Secondary/Links
-	ColorGray     = lipgloss.Color("#9A9EA0") // Inactive/Subtle
-	ColorWhite    = lipgloss.Color("#FFFFFF") // Text
-	ColorDarkGray = lipgloss.Color("#3A3A3A") // Background
-	ColorRed      = lipgloss.Color("#E95420") // Error/Active
-	ColorGreen    = lipgloss.Color("#4CAF50") // Success
-	ColorCyan     = lipgloss.Color("#00BCD4") // Info/SQL
+	ColorOrange    = lipgloss.Color("#DDA036") // Primary/Active
+	ColorBlue      = lipgloss.Color("#569FC6") // Secondary/Links
+	ColorGray      = lipgloss.Color("#9A9EA0") // Inactive/Subtle
+	ColorWhite     = lipgloss.Color("#FFFFFF") // Text
+	ColorDarkGray  = lipgloss.Color("#3A3A3A") // Background
+	ColorRed       = lipgloss.Color("#E95420") // Error/Active
+	ColorGreen     = lipgloss.Color("#4CAF50") // Success
+	ColorCyan      = lipgloss.Color("#00BCD4") // Info/SQL
+	ColorOnAccent  = lipgloss.Color("#000000") // Text on Primary backgrounds
+	ColorStatusBar = lipgloss.Color("#1A1A1A") // Editor status line
 )
 
 // HeaderWidth is the standard width for the header
@@ -36,11 +43,18 @@ type AppState struct {
 	TablesCount     int
 	QueryCount      int
 	HasPostGIS      bool
+	Production      bool   // Active service is tagged prod
 	Status          string // e.g., "Ready", "Querying", "Connected"
 	BlinkOn         bool   // For blinking indicator
 	LastQueryTime   float64
+	Connection      *postgres.ConnectionManager // Shared pool of the active service, for its health
+	Usage           *llm.UsageMeter             // LLM provider requests made this session
 }
 
+// sessionUsageContext counts the provider requests made with it, or with
+// contexts derived from it, in GlobalAppState.Usage
+var sessionUsageContext, sessionUsage = llm.MeterUsage(context.Background())
+
 // Global app state - updated by the main app model
 var GlobalAppState = &AppState{
 	IsConnected:   false,
@@ -51,6 +65,7 @@ var GlobalAppState = &AppState{
 	HasPostGIS:    false,
 	Status:        "Ready",
 	BlinkOn:       true,
+	Usage:         sessionUsage,
 }
 
 // ========================================
@@ -105,12 +120,31 @@ func RenderHeader(pageTitle string) string {
 	if GlobalAppState.IsConnected {
 		dbStatus = GlobalAppState.ActiveService
 		dbColor = ColorGreen
+		if conn := GlobalAppState.Connection; conn != nil {
+			status := conn.Status()
+			switch status.State {
+			case postgres.ConnConnected:
+				dbStatus += fmt.Sprintf(" ● %dms", status.Latency.Milliseconds())
+			case postgres.ConnConnecting:
+				dbStatus += " ○"
+				dbColor = ColorGray
+			case postgres.ConnReconnecting:
+				dbStatus += " ◌ reconnecting"
+				dbColor = ColorOrange
+			default:
+				dbStatus += " ✗ offline"
+				dbColor = ColorRed
+			}
+		}
 	}
 
 	dbStyled := lipgloss.NewStyle().
 		Foreground(dbColor).
 		Bold(GlobalAppState.IsConnected).
 		Render(dbStatus)
+	if GlobalAppState.IsConnected && GlobalAppState.Production {
+		dbStyled = productionBadge() + " " + dbStyled
+	}
 
 	// PostGIS status
 	postgisStatus := "-"
@@ -135,7 +169,10 @@ func RenderHeader(pageTitle string) string {
 		postgisStyled,
 		GlobalAppState.QueryCount,
 	)
-	status := statusStyle.Render(statusLine)
+	if used := GlobalAppState.Usage.Total(); used.Requests > 0 {
+		statusLine += " | LLM: " + formatUsage(used)
+	}
+	status := statusStyle.Width(max(HeaderWidth, lipgloss.Width(statusLine))).Render(statusLine)
 
 	return lipgloss.JoinVertical(
 		lipgloss.Center,
@@ -222,53 +259,60 @@ func CenterContent(content string, width, height int) string {
 // Common Styles
 // ========================================
 
-// Box style for content areas
-var BoxStyle = lipgloss.NewStyle().
-	Border(lipgloss.RoundedBorder()).
-	BorderForeground(ColorOrange).
-	Padding(1, 2)
-
-// Title style for section headings
-var TitleStyle = lipgloss.NewStyle().
-	Bold(true).
-	Foreground(ColorOrange)
-
-// Subtitle style
-var SubtitleStyle = lipgloss.NewStyle().
-	Foreground(ColorBlue)
-
-// Label style for form labels
-var LabelStyle = lipgloss.NewStyle().
-	Foreground(ColorGray)
-
-// Value style for displaying values
-var ValueStyle = lipgloss.NewStyle().
-	Foreground(ColorWhite)
-
-// Active style for active/selected items
-var ActiveStyle = lipgloss.NewStyle().
-	Foreground(ColorOrange).
-	Bold(true)
-
-// Inactive style for inactive items
-var InactiveStyle = lipgloss.NewStyle().
-	Foreground(ColorGray)
-
-// Error style for error messages
-var ErrorStyle = lipgloss.NewStyle().
-	Foreground(ColorRed).
-	Bold(true)
-
-// Success style for success messages
-var SuccessStyle = lipgloss.NewStyle().
-	Foreground(ColorGreen).
-	Bold(true)
-
-// SQL style for SQL code
-var SQLStyle = lipgloss.NewStyle().
-	Foreground(ColorCyan)
-
-// Prompt style for input prompts
-var PromptStyle = lipgloss.NewStyle().
-	Foreground(ColorOrange).
-	Bold(true)
+// Shared styles, rebuilt by buildStyles when the theme changes
+var (
+	BoxStyle      lipgloss.Style // Box style for content areas
+	TitleStyle    lipgloss.Style // Title style for section headings
+	SubtitleStyle lipgloss.Style // Subtitle style
+	LabelStyle    lipgloss.Style // Label style for form labels
+	ValueStyle    lipgloss.Style // Value style for displaying values
+	ActiveStyle   lipgloss.Style // Active style for active/selected items
+	InactiveStyle lipgloss.Style // Inactive style for inactive items
+	ErrorStyle    lipgloss.Style // Error style for error messages
+	SuccessStyle  lipgloss.Style // Success style for success messages
+	SQLStyle      lipgloss.Style // SQL style for SQL code
+	PromptStyle   lipgloss.Style // Prompt style for input prompts
+)
+
+// buildStyles builds the shared styles from the theme colours
+func buildStyles() {
+	BoxStyle = lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(ColorOrange).
+		Padding(1, 2)
+
+	TitleStyle = lipgloss.NewStyle().
+		Bold(true).
+		Foreground(ColorOrange)
+
+	SubtitleStyle = lipgloss.NewStyle().
+		Foreground(ColorBlue)
+
+	LabelStyle = lipgloss.NewStyle().
+		Foreground(ColorGray)
+
+	ValueStyle = lipgloss.NewStyle().
+		Foreground(ColorWhite)
+
+	ActiveStyle = lipgloss.NewStyle().
+		Foreground(ColorOrange).
+		Bold(true)
+
+	InactiveStyle = lipgloss.NewStyle().
+		Foreground(ColorGray)
+
+	ErrorStyle = lipgloss.NewStyle().
+		Foreground(ColorRed).
+		Bold(true)
+
+	SuccessStyle = lipgloss.NewStyle().
+		Foreground(ColorGreen).
+		Bold(true)
+
+	SQLStyle = lipgloss.NewStyle().
+		Foreground(ColorCyan)
+
+	PromptStyle = lipgloss.NewStyle().
+		Foreground(ColorOrange).
+		Bold(true)
+}